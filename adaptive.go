@@ -0,0 +1,79 @@
+package main
+
+import "math"
+
+// AdaptiveProfile tracks how strong the computer should play against one
+// human. Level runs from 0 (weakest) to 1 (strongest) and moves towards the
+// strength at which the human wins about half of the games.
+type AdaptiveProfile struct {
+	Level     float64 `json:"level"`
+	Step      float64 `json:"step"`
+	Games     int     `json:"games"`
+	HumanWins int     `json:"human_wins"`
+}
+
+const (
+	adaptiveMinPlayouts = 16
+	adaptiveMaxPlayouts = 2048
+	adaptiveMinStep     = 0.02
+)
+
+func profilesPath() string {
+	return dataPath("profiles.json")
+}
+
+// LoadProfile returns the stored profile for player, or a fresh one that
+// starts in the middle of the range with large adjustment steps.
+func LoadProfile(player string) (*AdaptiveProfile, error) {
+	profiles := map[string]*AdaptiveProfile{}
+	if err := loadJSON(profilesPath(), &profiles); err != nil {
+		return nil, err
+	}
+	if p, ok := profiles[player]; ok {
+		return p, nil
+	}
+	return &AdaptiveProfile{Level: 0.5, Step: 0.2}, nil
+}
+
+func SaveProfile(player string, p *AdaptiveProfile) error {
+	profiles := map[string]*AdaptiveProfile{}
+	if err := loadJSON(profilesPath(), &profiles); err != nil {
+		return err
+	}
+	profiles[player] = p
+	return saveJSON(profilesPath(), profiles)
+}
+
+// Engine configures an engine for the current level: playouts grow
+// exponentially with the level, while weak levels add noise to the move
+// choice and sometimes settle for the second or third best move.
+func (p *AdaptiveProfile) Engine(komi float64) *Engine {
+	e := NewEngine(int(adaptiveMinPlayouts*math.Pow(adaptiveMaxPlayouts/adaptiveMinPlayouts, p.Level)), komi)
+	e.Noise = 0.5 * (1 - p.Level)
+	switch {
+	case p.Level < 0.2:
+		e.Choice = 3
+	case p.Level < 0.4:
+		e.Choice = 2
+	}
+	return e
+}
+
+// Record adjusts the level after a game. Steps shrink as games accumulate
+// so the level settles instead of oscillating.
+func (p *AdaptiveProfile) Record(humanWon bool) {
+	p.Games++
+	if humanWon {
+		p.HumanWins++
+		p.Level = math.Min(1, p.Level+p.Step)
+	} else {
+		p.Level = math.Max(0, p.Level-p.Step)
+	}
+	p.Step = math.Max(adaptiveMinStep, p.Step*0.85)
+}
+
+// BotRank is the nominal rank of the computer at the current level, used
+// when archiving results for rating.
+func (p *AdaptiveProfile) BotRank() Rank {
+	return Rank(-25 + math.Round(p.Level*20))
+}
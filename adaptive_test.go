@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAdaptiveRecord(t *testing.T) {
+	p := &AdaptiveProfile{Level: 0.5, Step: 0.2}
+	p.Record(true)
+	if p.Level != 0.7 || p.Step != 0.17 || p.Games != 1 || p.HumanWins != 1 {
+		t.Errorf("after a human win: %+v", p)
+	}
+	p.Record(false)
+	if math.Abs(p.Level-0.53) > 1e-9 || p.Games != 2 || p.HumanWins != 1 {
+		t.Errorf("after a human loss: %+v", p)
+	}
+	// The level stays in range, and the step stops shrinking at its least.
+	for i := 0; i < 50; i++ {
+		p.Record(false)
+	}
+	if p.Level != 0 || p.Step != adaptiveMinStep {
+		t.Errorf("after many losses: %+v", p)
+	}
+	for i := 0; i < 100; i++ {
+		p.Record(true)
+	}
+	if p.Level != 1 {
+		t.Errorf("after many wins: %+v", p)
+	}
+}
+
+func TestAdaptiveConverges(t *testing.T) {
+	// A human who wins half the games against level skill, more against
+	// weaker levels and fewer against stronger.
+	rng := rand.New(rand.NewSource(1))
+	for _, skill := range []float64{0.2, 0.5, 0.8} {
+		p := &AdaptiveProfile{Level: 0.5, Step: 0.2}
+		sum := 0.0
+		for game := 0; game < 400; game++ {
+			p.Record(rng.Float64() < 1/(1+math.Exp(-10*(skill-p.Level))))
+			if game >= 200 {
+				sum += p.Level
+			}
+		}
+		if mean := sum / 200; math.Abs(mean-skill) > 0.1 {
+			t.Errorf("human of skill %g: level settles around %.2f", skill, mean)
+		}
+	}
+}
+
+func TestAdaptiveEngine(t *testing.T) {
+	for _, tc := range []struct {
+		level    float64
+		playouts int
+		noise    float64
+		choice   int
+	}{
+		{0, adaptiveMinPlayouts, 0.5, 3},
+		{0.3, 68, 0.35, 2},
+		{0.5, 181, 0.25, 1},
+		{1, adaptiveMaxPlayouts, 0, 1},
+	} {
+		e := (&AdaptiveProfile{Level: tc.level}).Engine(6.5)
+		if e.Playouts != tc.playouts || math.Abs(e.Noise-tc.noise) > 1e-9 || e.Choice != tc.choice {
+			t.Errorf("level %g: %d playouts, noise %g, choice %d", tc.level, e.Playouts, e.Noise, e.Choice)
+		}
+	}
+}
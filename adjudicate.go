@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	commands["adjudicate"] = runAdjudicate
+}
+
+// Adjudication settles games that stopped before the end, abandoned or
+// adjourned for good, the way a tournament director would: on how they
+// stood. The engine evaluates the last positions of the game, and the
+// side ahead in all of them by at least the threshold win rate is given
+// the game, with the result recorded without a margin (RE[B+]) and the
+// evaluation in the game comment. Requiring several positions in a row
+// keeps one misjudged position, or a blunder its opponent had yet to
+// punish, from deciding the game. Games with no clear leader are left
+// undecided, for a human to settle.
+
+const (
+	adjudicateThreshold = 0.9
+	adjudicatePositions = 3
+)
+
+// Adjudicator judges unfinished games.
+type Adjudicator struct {
+	Engine    *Engine
+	Cache     *AnalysisCache // nil to always search
+	Threshold float64        // win rate the leader needs in every position
+	Positions int            // positions judged, the last of the game
+}
+
+// NewAdjudicator is an adjudicator with the default threshold and number
+// of positions.
+func NewAdjudicator(e *Engine, cache *AnalysisCache) *Adjudicator {
+	return &Adjudicator{Engine: e, Cache: cache, Threshold: adjudicateThreshold, Positions: adjudicatePositions}
+}
+
+// Adjudication is an adjudicator's verdict on a game.
+type Adjudication struct {
+	Winner    Stone     // Empty if neither side was clearly ahead
+	BlackWins []float64 // Black's win rate in each position judged
+	Threshold float64
+}
+
+// Result is the game's result as in SGF RE, or "" if it is undecided.
+func (a Adjudication) Result() string {
+	if a.Winner == Empty {
+		return ""
+	}
+	return strings.ToUpper(colorKey(a.Winner)[:1]) + "+"
+}
+
+// Note describes the verdict, for the game comment.
+func (a Adjudication) Note() string {
+	rates := make([]string, len(a.BlackWins))
+	for i, r := range a.BlackWins {
+		rates[i] = fmt.Sprintf("%.0f%%", 100*r)
+	}
+	text := fmt.Sprintf("Black's win rate in the last %d positions: %s.", len(rates), strings.Join(rates, ", "))
+	if a.Winner == Empty {
+		return fmt.Sprintf("Not adjudicated: neither side reached %.0f%% throughout. %s", 100*a.Threshold, text)
+	}
+	return fmt.Sprintf("Adjudicated to %s by engine evaluation. %s", colorName(a.Winner), text)
+}
+
+// Adjudicate evaluates the last positions of g.
+func (a *Adjudicator) Adjudicate(ctx context.Context, g *GameRecord) (Adjudication, error) {
+	if len(g.Moves)+1 < a.Positions {
+		return Adjudication{}, fmt.Errorf("%d moves are too few to adjudicate on %d positions", len(g.Moves), a.Positions)
+	}
+	a.Engine.Komi = g.Komi
+	verdict := Adjudication{Threshold: a.Threshold}
+	b := g.Start()
+	from := len(g.Moves) + 1 - a.Positions
+	for i := 0; ; i++ {
+		if i >= from {
+			an, err := a.Cache.Analyze(ctx, a.Engine, b)
+			if err != nil {
+				return verdict, err
+			}
+			black := an.WinRate
+			if b.turn == White {
+				black = 1 - black
+			}
+			verdict.BlackWins = append(verdict.BlackWins, black)
+		}
+		if i == len(g.Moves) {
+			break
+		}
+		m := g.Moves[i]
+		b.turn = m.Color
+		if !b.Play(m.Move) {
+			return verdict, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	black, white := true, true
+	for _, r := range verdict.BlackWins {
+		black = black && r >= a.Threshold
+		white = white && 1-r >= a.Threshold
+	}
+	switch {
+	case black:
+		verdict.Winner = Black
+	case white:
+		verdict.Winner = White
+	}
+	return verdict, nil
+}
+
+// adjudicable reports whether a game with result needs adjudicating: it
+// has none, or an unknown or void one, or with timeouts, one decided on
+// time or by forfeit, which a server records when a player disappears.
+func adjudicable(result string, timeouts bool) bool {
+	switch result = strings.TrimSpace(result); {
+	case result == "", result == "?", strings.EqualFold(result, "void"):
+		return true
+	case timeouts:
+		return strings.HasSuffix(result, "+T") || strings.HasSuffix(result, "+Time") || strings.HasSuffix(result, "+F") || strings.HasSuffix(result, "+Forfeit")
+	}
+	return false
+}
+
+// adjudicateTree adjudicates the game of the tree root and, if it is
+// decided, records the result in it.
+func adjudicateTree(ctx context.Context, a *Adjudicator, root *SGFNode) (Adjudication, error) {
+	g, err := RecordFromSGF(root)
+	if err != nil {
+		return Adjudication{}, err
+	}
+	verdict, err := a.Adjudicate(ctx, g)
+	if err != nil {
+		return verdict, err
+	}
+	if verdict.Winner != Empty {
+		root.Set("RE", verdict.Result())
+		root.Set("GC", appendComment(root.Get("GC"), verdict.Note()))
+	}
+	return verdict, nil
+}
+
+func runAdjudicate(args []string) error {
+	fs := flag.NewFlagSet("adjudicate", flag.ExitOnError)
+	threshold := fs.Float64("threshold", adjudicateThreshold, "win rate the leader needs in every position judged")
+	positions := fs.Int("positions", adjudicatePositions, "positions judged, the last of the game")
+	playouts := fs.Int("playouts", 2000, "playouts per position")
+	timeouts := fs.Bool("timeouts", false, "adjudicate games lost on time or by forfeit too")
+	write := fs.Bool("w", false, "record the results in the files")
+	useCache := fs.Bool("cache", true, "look positions up in the analysis cache and keep new analyses there")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: adjudicate [-threshold 0.9] [-positions 3] [-timeouts] [-w] games.sgf...")
+	}
+	if *threshold <= 0.5 || *threshold > 1 || *positions < 1 {
+		return errors.New("-threshold must be over 0.5 and at most 1, and -positions at least 1")
+	}
+	cache, err := openAnalysisCache(*useCache)
+	if err != nil {
+		return err
+	}
+	a := NewAdjudicator(NewEngine(*playouts, 6.5), cache)
+	a.Threshold, a.Positions = *threshold, *positions
+	ctx := context.Background()
+	for _, path := range fs.Args() {
+		games, err := ReadSGFFile(path)
+		if err != nil {
+			return err
+		}
+		decided := 0
+		for n, root := range games {
+			name := path
+			if len(games) > 1 {
+				name = fmt.Sprintf("%s game %d", path, n+1)
+			}
+			if result := root.Get("RE"); !adjudicable(result, *timeouts) {
+				fmt.Printf("%s: %s, kept\n", name, result)
+				continue
+			}
+			verdict, err := adjudicateTree(ctx, a, root)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			result := verdict.Result()
+			if result == "" {
+				result = "undecided"
+			} else {
+				decided++
+			}
+			fmt.Printf("%s: %s. %s\n", name, result, verdict.Note())
+		}
+		if *write && decided > 0 {
+			if err := WriteSGFFile(path, games); err != nil {
+				return err
+			}
+		}
+	}
+	return saveAnalysisCache(cache)
+}
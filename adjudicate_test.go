@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAdjudicable(t *testing.T) {
+	for _, c := range []struct {
+		result   string
+		timeouts bool
+		want     bool
+	}{
+		{"", false, true},
+		{"?", false, true},
+		{"Void", false, true},
+		{"B+3.5", true, false},
+		{"W+R", true, false},
+		{"W+T", false, false},
+		{"W+T", true, true},
+		{"B+Forfeit", true, true},
+	} {
+		if got := adjudicable(c.result, c.timeouts); got != c.want {
+			t.Errorf("adjudicable(%q, %v) = %v", c.result, c.timeouts, got)
+		}
+	}
+}
+
+func TestAdjudicate(t *testing.T) {
+	// Black owns all of a small board but for a corner White has no room
+	// to live in.
+	g := &GameRecord{Size: 5, Komi: 0.5}
+	for p := 0; p < 25; p++ {
+		if p != 0 && p != 1 && p != 5 {
+			g.Setup = append(g.Setup, RecordedMove{Color: Black, Move: p})
+		}
+	}
+	g.Moves = []RecordedMove{{Color: White, Move: PassMove}, {Color: Black, Move: PassMove}}
+	a := NewAdjudicator(NewEngine(200, 0.5), nil)
+	a.Positions = 2
+	verdict, err := a.Adjudicate(context.Background(), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict.Winner != Black || verdict.Result() != "B+" || len(verdict.BlackWins) != 2 {
+		t.Fatalf("verdict = %+v", verdict)
+	}
+
+	a.Threshold = 1.01
+	if verdict, _ := a.Adjudicate(context.Background(), g); verdict.Winner != Empty || verdict.Result() != "" {
+		t.Errorf("verdict over an unreachable threshold = %+v", verdict)
+	}
+	a.Positions = 4
+	if _, err := a.Adjudicate(context.Background(), g); err == nil {
+		t.Error("adjudicated on more positions than the game has")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Worker placement for search on large machines. With -pin, each search
+// worker and the batch evaluator are pinned to a CPU of their own (see
+// Engine.PinWorker), so the scheduler does not move them between cores,
+// or between NUMA nodes, whose memory is slower to reach from the others:
+//
+//	compact     fill the CPUs of one node before the next, so the workers
+//	            sharing a tree share a node's memory
+//	spread      take the nodes in turn, for split trees (-split-tree),
+//	            each in its own worker's memory
+//	0-7,16-23   these CPUs, in this order
+//
+// Only the CPUs the process may run on are used. Nodes are read from
+// /sys/devices/system/node; a machine without them is one node. Threads
+// are pinned with sched_setaffinity (see affinity_linux.go), so pinning
+// needs Linux; elsewhere -pin fails. Other systems build the package,
+// not a list of its files, to leave that file out.
+
+// numaNodes lists the CPUs of each NUMA node that are in allowed.
+func numaNodes(allowed []int) [][]int {
+	ok := map[int]bool{}
+	for _, cpu := range allowed {
+		ok[cpu] = true
+	}
+	var nodes [][]int
+	dirs, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	sort.Slice(dirs, func(i, j int) bool { return nodeNumber(dirs[i]) < nodeNumber(dirs[j]) })
+	seen := map[int]bool{}
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		cpus, err := parseCPUList(strings.TrimSpace(string(data)))
+		if err != nil {
+			continue
+		}
+		var node []int
+		for _, cpu := range cpus {
+			if ok[cpu] {
+				node = append(node, cpu)
+				seen[cpu] = true
+			}
+		}
+		if len(node) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	var rest []int
+	for _, cpu := range allowed {
+		if !seen[cpu] {
+			rest = append(rest, cpu)
+		}
+	}
+	if len(rest) > 0 {
+		nodes = append(nodes, rest)
+	}
+	return nodes
+}
+
+func nodeNumber(dir string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+	return n
+}
+
+// parseCPUList reads a list of CPUs such as "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(lo)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(hi)
+		}
+		if err != nil || from < 0 || to < from {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// placeWorkers orders the CPUs workers are pinned to, worker i going to
+// the i-th, round the list again when there are more workers than CPUs.
+func placeWorkers(placement string, nodes [][]int) ([]int, error) {
+	var order []int
+	switch placement {
+	case "compact":
+		for _, node := range nodes {
+			order = append(order, node...)
+		}
+	case "spread":
+		for i, n := 0, len(slices.Concat(nodes...)); len(order) < n; i++ {
+			for _, node := range nodes {
+				if i < len(node) {
+					order = append(order, node[i])
+				}
+			}
+		}
+	default:
+		cpus, err := parseCPUList(placement)
+		if err != nil {
+			return nil, fmt.Errorf("-pin takes compact, spread or a CPU list: %v", err)
+		}
+		allowed := map[int]bool{}
+		for _, cpu := range slices.Concat(nodes...) {
+			allowed[cpu] = true
+		}
+		for _, cpu := range cpus {
+			if !allowed[cpu] {
+				return nil, fmt.Errorf("CPU %d is not one this process may run on", cpu)
+			}
+		}
+		order = cpus
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no CPUs to pin to")
+	}
+	return order, nil
+}
+
+// workerPinner returns an Engine.PinWorker for placement, or nil for
+// none. A worker that cannot be pinned runs where it is, and the first
+// failure is reported.
+func workerPinner(placement string) (func(worker int), error) {
+	if placement == "" {
+		return nil, nil
+	}
+	if setThreadAffinity == nil {
+		return nil, fmt.Errorf("-pin needs Linux")
+	}
+	order, err := placeWorkers(placement, numaNodes(allowedCPUs()))
+	if err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func(worker int) {
+		if err := setThreadAffinity(order[worker%len(order)]); err != nil {
+			once.Do(func() { fmt.Fprintln(os.Stderr, "-pin:", err) })
+		}
+	}, nil
+}
+
+// allowedCPUs lists the CPUs the process may run on, or every CPU where
+// the system does not say.
+func allowedCPUs() []int {
+	if data, err := os.ReadFile("/proc/self/status"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if list, ok := strings.CutPrefix(line, "Cpus_allowed_list:"); ok {
+				if cpus, err := parseCPUList(strings.TrimSpace(list)); err == nil {
+					return cpus
+				}
+			}
+		}
+	}
+	cpus := make([]int, runtime.NumCPU())
+	for i := range cpus {
+		cpus[i] = i
+	}
+	return cpus
+}
+
+// setThreadAffinity keeps the calling OS thread on cpu, where the system
+// has a way to; it is nil elsewhere.
+var setThreadAffinity func(cpu int) error
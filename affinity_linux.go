@@ -0,0 +1,29 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	setThreadAffinity = schedSetaffinity
+}
+
+// schedSetaffinity keeps the calling thread on cpu. syscall has the
+// system call's number but no wrapper for it.
+func schedSetaffinity(cpu int) error {
+	const wordBits = 32 << (^uint(0) >> 63)
+	var mask [1024 / wordBits]uint // a cpu_set_t, whose words are C longs
+	if cpu < 0 || cpu >= len(mask)*wordBits {
+		return fmt.Errorf("CPU %d is out of range", cpu)
+	}
+	mask[cpu/wordBits] |= 1 << (cpu % wordBits)
+	// Thread 0 is the calling one.
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask))); errno != 0 {
+		return fmt.Errorf("sched_setaffinity: %v", errno)
+	}
+	return nil
+}
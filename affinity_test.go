@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPlaceWorkers(t *testing.T) {
+	if cpus, err := parseCPUList("0-2,8,10-11"); err != nil || !reflect.DeepEqual(cpus, []int{0, 1, 2, 8, 10, 11}) {
+		t.Errorf("parseCPUList: %v, %v", cpus, err)
+	}
+	if _, err := parseCPUList("3-1"); err == nil {
+		t.Error("parsed a backwards range")
+	}
+	nodes := [][]int{{0, 1, 2}, {4, 5}}
+	for placement, want := range map[string][]int{
+		"compact": {0, 1, 2, 4, 5},
+		"spread":  {0, 4, 1, 5, 2},
+		"5,0":     {5, 0},
+	} {
+		if got, err := placeWorkers(placement, nodes); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %v, %v", placement, got, err)
+		}
+	}
+	if _, err := placeWorkers("3", nodes); err == nil {
+		t.Error("placed a worker on a CPU that is not allowed")
+	}
+}
+
+func TestSetThreadAffinity(t *testing.T) {
+	if setThreadAffinity == nil {
+		t.Skip("no thread affinity on", runtime.GOOS)
+	}
+	allowed := allowedCPUs()
+	cpu := allowed[len(allowed)-1]
+	done := make(chan string)
+	go func() {
+		// The thread is not unlocked, so it goes when the goroutine ends
+		// rather than back to the scheduler pinned.
+		runtime.LockOSThread()
+		if err := setThreadAffinity(cpu); err != nil {
+			done <- err.Error()
+			return
+		}
+		data, _ := os.ReadFile("/proc/thread-self/status")
+		for _, line := range strings.Split(string(data), "\n") {
+			if list, ok := strings.CutPrefix(line, "Cpus_allowed_list:"); ok {
+				done <- strings.TrimSpace(list)
+				return
+			}
+		}
+		done <- "no Cpus_allowed_list"
+	}()
+	if got, want := <-done, strconv.Itoa(cpu); got != want {
+		t.Errorf("pinned to %d: thread allowed %s", cpu, got)
+	}
+	if err := setThreadAffinity(1 << 20); err == nil {
+		t.Error("pinned to CPU 1048576")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// The analysis API serves the engine's view of a position as JSON over
+// HTTP, for GUIs and web pages that draw win rates and ownership shading:
+//
+//	POST /analyze  {"size": 9, "komi": 7, "moves": ["E5", "C3"], "ownership": true}
+//
+// Moves are GTP vertices, "pass" included, played alternately from the
+// empty board. The answer is an AnalysisResult; its ownership has a row of
+// the board per entry, from the top, and values from +1 where Black ends
+// up with the point to -1 where White does. "histogram_width": N adds how
+// the search's estimates of Black's final lead spread, in bins N points
+// wide (see scoredist.go). The same server runs games for clients too
+// (see gamesapi.go). openapi.json describes the API, and APIClient calls
+// it.
+//
+// Analyses are kept in the analysis cache, unless -cache=false, so that
+// asking again about a position is answered at once; a histogram always
+// needs a fresh search.
+
+func init() {
+	commands["analysis-api"] = runAnalysisAPI
+}
+
+// AnalysisRequest asks for the analysis of a position.
+type AnalysisRequest struct {
+	Size      int      `json:"size"`
+	Komi      float64  `json:"komi"`
+	Moves     []string `json:"moves"`
+	Ownership bool     `json:"ownership"`
+	Histogram int      `json:"histogram_width,omitempty"`
+	Playouts  int      `json:"playouts,omitempty"` // up to the server's, which is the default
+}
+
+// AnalysisResult is the engine's view of a position.
+type AnalysisResult struct {
+	ToMove     string              `json:"to_move"`
+	WinRate    float64             `json:"win_rate"`   // for the side to move
+	ScoreMean  float64             `json:"score_mean"` // the lead of the side to move
+	ScoreStdev float64             `json:"score_stdev"`
+	Playouts   int                 `json:"playouts"`
+	Candidates []AnalysisCandidate `json:"candidates"`
+	Ownership  [][]float64         `json:"ownership,omitempty"`
+	Histogram  []ScoreBin          `json:"score_histogram,omitempty"` // of Black's lead
+}
+
+// AnalysisCandidate is a move the search considered.
+type AnalysisCandidate struct {
+	Move    string  `json:"move"`
+	Visits  int     `json:"visits"`
+	WinRate float64 `json:"win_rate"`
+}
+
+// position replays the request's moves.
+func (r AnalysisRequest) position() (*Board, error) {
+	if r.Size < 2 || r.Size > MaxBoardSize {
+		return nil, fmt.Errorf("size must be between 2 and %d", MaxBoardSize)
+	}
+	b := NewBoard(r.Size)
+	for i, v := range r.Moves {
+		m, err := parseGTPVertex(v, r.Size)
+		if err != nil {
+			return nil, err
+		}
+		if !b.Play(m) {
+			return nil, fmt.Errorf("move %d, %s, is illegal", i+1, v)
+		}
+	}
+	return b, nil
+}
+
+// analysisHandler answers analysis requests with up to playouts each, one
+// at a time, each within timeout, through cache unless it is nil. The
+// engine of each request is set up by configure, if given.
+func analysisHandler(playouts int, timeout time.Duration, cache *AnalysisCache, configure func(*Engine)) http.Handler {
+	var mu sync.Mutex
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*") // for pages on other sites
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(w http.ResponseWriter, status int, err error) {
+		reply(w, status, map[string]string{"error": err.Error()})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("OPTIONS /analyze", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	})
+	mux.HandleFunc("POST /analyze", func(w http.ResponseWriter, r *http.Request) {
+		var req AnalysisRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		b, err := req.position()
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		n := playouts
+		if req.Playouts > 0 {
+			n = min(n, req.Playouts)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		e := NewEngine(n, req.Komi)
+		if configure != nil {
+			configure(e)
+		}
+		c := cache
+		if req.Histogram > 0 {
+			c = nil
+		}
+		res, err := AnalyzePosition(ctx, e, c, b, req.Ownership)
+		if req.Histogram > 0 {
+			res.Histogram = e.ScoreDistribution().Histogram(req.Histogram)
+		}
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			fail(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		reply(w, http.StatusOK, res)
+	})
+	return mux
+}
+
+// AnalyzePosition searches b with e, through cache unless it is nil, and
+// reports what it found, with the ownership of each point if asked. Like
+// Engine.Analyze it returns what it has with ctx.Err() when ctx is done
+// first.
+func AnalyzePosition(ctx context.Context, e *Engine, cache *AnalysisCache, b *Board, ownership bool) (AnalysisResult, error) {
+	a, err := cache.Analyze(ctx, e, b.Copy())
+	res := AnalysisResult{
+		ToMove: colorKey(b.turn), WinRate: a.WinRate, ScoreMean: a.ScoreMean, ScoreStdev: a.ScoreStdev,
+		Playouts: a.Playouts, Candidates: []AnalysisCandidate{},
+	}
+	for _, c := range a.Candidates {
+		res.Candidates = append(res.Candidates, AnalysisCandidate{Move: gtpVertex(c.Move, b.size), Visits: c.Visits, WinRate: c.WinRate})
+	}
+	if ownership && err == nil {
+		var own []float64
+		own, err = e.Ownership(ctx, b)
+		for row := 0; row < b.size; row++ {
+			res.Ownership = append(res.Ownership, own[row*b.size:(row+1)*b.size])
+		}
+	}
+	return res, err
+}
+
+func runAnalysisAPI(args []string) error {
+	fs := flag.NewFlagSet("analysis-api", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to serve the analysis API on")
+	playouts := fs.Int("playouts", 2000, "most playouts of an analysis, of its ownership and of an engine move in a game")
+	timeout := fs.Duration("timeout", 10*time.Second, "longest time to spend on a request")
+	useCache := fs.Bool("cache", true, "answer from the analysis cache and keep new analyses there")
+	threads := fs.Int("threads", 1, "parallel search workers")
+	split := fs.Bool("split-tree", false, "give each thread a search tree of its own, merged at the end")
+	pin := fs.String("pin", "", "pin the search workers to CPUs: compact, spread or a list such as 0-7 (see affinity.go)")
+	fs.Parse(args)
+	pinWorker, err := workerPinner(*pin)
+	if err != nil {
+		return err
+	}
+	cache, err := openAnalysisCache(*useCache)
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		// Saving after every request would rewrite the whole file each
+		// time; new analyses are saved once a minute instead.
+		go func() {
+			saved := 0
+			for range time.Tick(time.Minute) {
+				if _, misses := cache.Stats(); misses != saved {
+					if err := cache.Save(); err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						continue
+					}
+					saved = misses
+				}
+			}
+		}()
+	}
+	configure := func(e *Engine) {
+		e.Threads, e.SplitTree, e.PinWorker = *threads, *split, pinWorker
+	}
+	games := gamesHandler(*playouts, *timeout, configure)
+	mux := http.NewServeMux()
+	mux.Handle("/analyze", analysisHandler(*playouts, *timeout, cache, configure))
+	mux.Handle("/games", games)
+	mux.Handle("/games/", games)
+	fmt.Printf("Serving analysis on http://%s/analyze and games on http://%s/games\n", *addr, *addr)
+	return http.ListenAndServe(*addr, mux)
+}
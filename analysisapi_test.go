@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalysisHandler(t *testing.T) {
+	srv := httptest.NewServer(analysisHandler(400, 10*time.Second, nil, nil))
+	defer srv.Close()
+
+	body := `{"size": 5, "komi": 0.5, "moves": ["C3", "B2"], "ownership": true}`
+	resp, err := http.Post(srv.URL+"/analyze", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res AnalysisResult
+	json.NewDecoder(resp.Body).Decode(&res)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || res.ToMove != "black" || res.Playouts != 400 || len(res.Candidates) == 0 {
+		t.Fatalf("analyze = %s %+v", resp.Status, res)
+	}
+	if len(res.Ownership) != 5 || len(res.Ownership[2]) != 5 {
+		t.Fatalf("ownership is not 5 rows of 5: %v", res.Ownership)
+	}
+	// Black's stone in the centre mostly stays Black's.
+	if o := res.Ownership[2][2]; o <= 0 || o > 1 {
+		t.Errorf("ownership of C3 = %v, want Black's", o)
+	}
+
+	resp, err = http.Post(srv.URL+"/analyze", "application/json", strings.NewReader(`{"size": 5, "moves": ["C3", "C3"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("an illegal move: %s", resp.Status)
+	}
+}
+
+func TestGTPAnalysisLine(t *testing.T) {
+	b := NewBoard(2)
+	b.Play(0)
+	a := MoveAnalysis{Candidates: []CandidateMove{{Move: 3, Visits: 10, WinRate: 0.25}}}
+	want := "info move B1 visits 10 winrate 0.250000 prior 0 order 0 pv B1 ownership -1.0000 0.5000 0.0000 -0.2500"
+	if got := gtpAnalysisLine(a, b, true, []float64{1, -0.5, 0, 0.25}); got != want {
+		t.Errorf("kata-analyze line\n got %q\nwant %q", got, want)
+	}
+	if got := gtpAnalysisLine(a, b, false, nil); !strings.Contains(got, "winrate 2500 ") {
+		t.Errorf("lz-analyze line %q, want winrate 2500", got)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+func init() {
+	commands["analysis-cache"] = runAnalysisCache
+}
+
+// The analysis cache keeps the engine's analyses of positions on disk, so
+// that reviewing a game again, or another game through the same opening,
+// finds what was searched before instead of searching it again. Analyses
+// are kept by position, the side to move and whether the last move was a
+// pass, and by the settings that change what a search finds: the
+// playouts, komi, threads, tree, playout policy and root noise, and the
+// evaluator, which must be an IdentifiedEvaluator for its analyses to be
+// kept. A search cut short, by a timeout say, is not kept.
+//
+// The cache holds at most Max analyses and forgets the least recently
+// used beyond that when it is saved. analysis-cache shows how full it is,
+// changes the limit and clears it, which is needed after changing the
+// engine in ways the key does not know about.
+
+// defaultAnalysisCacheSize is the limit of a new cache, some 20 MB of
+// 19x19 analyses.
+const defaultAnalysisCacheSize = 1000
+
+// AnalysisCache is the analyses of earlier searches.
+type AnalysisCache struct {
+	Max     int                        `json:"max"`
+	Clock   int64                      `json:"clock"` // counts lookups, for Used
+	Entries map[string]*cachedAnalysis `json:"entries"`
+
+	path   string
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+type cachedAnalysis struct {
+	Analysis MoveAnalysis `json:"analysis"`
+	Used     int64        `json:"used"` // the Clock when last looked up
+}
+
+func analysisCachePath() string {
+	return dataPath("analysis-cache.json")
+}
+
+// OpenAnalysisCache loads the cache at path; a missing file is an empty
+// cache.
+func OpenAnalysisCache(path string) (*AnalysisCache, error) {
+	c := &AnalysisCache{Max: defaultAnalysisCacheSize, path: path}
+	if err := loadJSON(path, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if c.Entries == nil {
+		c.Entries = map[string]*cachedAnalysis{}
+	}
+	return c, nil
+}
+
+// Save evicts the least recently used analyses over the limit and writes
+// the cache back.
+func (c *AnalysisCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	// Unlike saveJSON's, without indenting, which would double the size.
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return saveFile(c.path, data)
+}
+
+// evict drops the least recently used analyses until at most Max are left.
+func (c *AnalysisCache) evict() {
+	over := len(c.Entries) - max(c.Max, 0)
+	if over <= 0 {
+		return
+	}
+	// Uses are distinct clock ticks, so the cut-off is the over-th oldest.
+	used := make([]int64, 0, len(c.Entries))
+	for _, e := range c.Entries {
+		used = append(used, e.Used)
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i] < used[j] })
+	cutoff := used[over-1]
+	for key, e := range c.Entries {
+		if e.Used <= cutoff {
+			delete(c.Entries, key)
+		}
+	}
+}
+
+// IdentifiedEvaluator is an Evaluator that can say what it evaluates
+// with, a network by a hash of its weights, say, so that the analysis
+// cache can tell its analyses from another's of the same type. Analyses by
+// other evaluators are not cached.
+type IdentifiedEvaluator interface {
+	Evaluator
+	EvaluatorID() string
+}
+
+// analysisKey identifies the analysis of b by e: the position, then a
+// hash of every setting of e that changes what its search finds. ok is
+// false when e's evaluator cannot be told apart from others.
+func analysisKey(e *Engine, b *Board) (key string, ok bool) {
+	evaluator := "playouts"
+	if e.Evaluator != nil {
+		id, ok := e.Evaluator.(IdentifiedEvaluator)
+		if !ok {
+			return "", false
+		}
+		evaluator = fmt.Sprintf("%T %s", e.Evaluator, id.EvaluatorID())
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d/%d/%d/%t/%d/%s/%s/%+v/%s",
+		strconv.FormatFloat(e.Komi, 'g', -1, 64), e.Playouts, e.Threads, e.BatchSize, e.SplitTree, e.MaxMemory,
+		strconv.FormatFloat(e.DirichletWeight, 'g', -1, 64), strconv.FormatFloat(e.DirichletAlpha, 'g', -1, 64),
+		e.Playout, evaluator)
+	return fmt.Sprintf("%d/%016x/%t/%016x", b.size, b.Hash(), b.passes > 0, h.Sum64()), true
+}
+
+// Analyze is e.Analyze(ctx, b) through the cache. A nil cache, or an
+// engine whose evaluator it cannot identify, always searches.
+func (c *AnalysisCache) Analyze(ctx context.Context, e *Engine, b *Board) (MoveAnalysis, error) {
+	key, ok := analysisKey(e, b)
+	if c == nil || !ok {
+		return e.Analyze(ctx, b)
+	}
+	c.mu.Lock()
+	c.Clock++
+	if hit, ok := c.Entries[key]; ok {
+		hit.Used = c.Clock
+		c.hits++
+		a := hit.Analysis
+		a.Candidates = append([]CandidateMove(nil), a.Candidates...)
+		c.mu.Unlock()
+		return a, nil
+	}
+	c.misses++
+	tick := c.Clock
+	c.mu.Unlock()
+
+	a, err := e.Analyze(ctx, b)
+	if err != nil {
+		return a, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := a
+	kept.MoveNumber = 0
+	kept.Candidates = append([]CandidateMove(nil), a.Candidates...)
+	c.Entries[key] = &cachedAnalysis{Analysis: kept, Used: tick}
+	return a, nil
+}
+
+// Stats is how many lookups since the cache was opened found an analysis
+// and how many had to search.
+func (c *AnalysisCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// openAnalysisCache opens the user's cache for a command, or returns nil
+// when use is false.
+func openAnalysisCache(use bool) (*AnalysisCache, error) {
+	if !use {
+		return nil, nil
+	}
+	return OpenAnalysisCache(analysisCachePath())
+}
+
+// saveAnalysisCache saves c, if any, and says how much it helped.
+func saveAnalysisCache(c *AnalysisCache) error {
+	if c == nil {
+		return nil
+	}
+	if hits, misses := c.Stats(); hits > 0 {
+		fmt.Printf("%d of %d positions were in the analysis cache\n", hits, hits+misses)
+	}
+	return c.Save()
+}
+
+func runAnalysisCache(args []string) error {
+	fs := flag.NewFlagSet("analysis-cache", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: analysis-cache [stats | clear | limit N]")
+	}
+	fs.Parse(args)
+	path := analysisCachePath()
+	c, err := OpenAnalysisCache(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() == 0 || fs.Arg(0) == "stats" && fs.NArg() == 1:
+		size := int64(0)
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		fmt.Printf("%s: %d of at most %d analyses, %d KB\n", path, len(c.Entries), c.Max, size/1024)
+		return nil
+	case fs.Arg(0) == "clear" && fs.NArg() == 1:
+		n := len(c.Entries)
+		c.Entries = map[string]*cachedAnalysis{}
+		if err := c.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %d analyses\n", n)
+		return nil
+	case fs.Arg(0) == "limit" && fs.NArg() == 2:
+		n, err := strconv.Atoi(fs.Arg(1))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid limit %q", fs.Arg(1))
+		}
+		c.Max = n
+		if err := c.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("The analysis cache keeps up to %d analyses; it has %d\n", n, len(c.Entries))
+		return nil
+	}
+	fs.Usage()
+	return errors.New("unknown analysis-cache command")
+}
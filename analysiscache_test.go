@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalysisCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := OpenAnalysisCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(20, 6.5)
+	b := NewBoard(5)
+	first, err := c.Analyze(context.Background(), e, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := c.Analyze(context.Background(), e, b)
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("hits %d, misses %d; want 1 and 1", hits, misses)
+	}
+	if again.WinRate != first.WinRate || len(again.Candidates) != len(first.Candidates) {
+		t.Errorf("cached analysis %+v, searched %+v", again, first)
+	}
+	// Other settings are another analysis.
+	e.Komi = 0.5
+	c.Analyze(context.Background(), e, b)
+	if len(c.Entries) != 2 {
+		t.Fatalf("%d entries after another komi, want 2", len(c.Entries))
+	}
+
+	// The least recently used goes first.
+	e.Komi = 6.5
+	c.Analyze(context.Background(), e, b)
+	b.Play(12)
+	c.Analyze(context.Background(), e, b)
+	c.Max = 2
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenAnalysisCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Komi = 0.5
+	if key, _ := analysisKey(e, NewBoard(5)); reopened.Entries[key] != nil || len(reopened.Entries) != 2 {
+		t.Errorf("kept %d entries, the oldest among them", len(reopened.Entries))
+	}
+}
+
+// namedEvaluator is a playout evaluator that says which it is.
+type namedEvaluator struct {
+	*playoutEvaluator
+	id string
+}
+
+func (n namedEvaluator) EvaluatorID() string { return n.id }
+
+func TestAnalysisKey(t *testing.T) {
+	b := NewBoard(9)
+	base, ok := analysisKey(NewEngine(100, 6.5), b)
+	if !ok {
+		t.Fatal("no key for the default engine")
+	}
+	keys := map[string]string{base: "defaults"}
+	for name, set := range map[string]func(*Engine){
+		"playouts":    func(e *Engine) { e.Playouts = 200 },
+		"komi":        func(e *Engine) { e.Komi = 7.5 },
+		"threads":     func(e *Engine) { e.Threads = 4 },
+		"batch size":  func(e *Engine) { e.BatchSize = 8 },
+		"split tree":  func(e *Engine) { e.SplitTree = true },
+		"max memory":  func(e *Engine) { e.MaxMemory = 1 << 20 },
+		"dirichlet":   func(e *Engine) { e.DirichletWeight, e.DirichletAlpha = 0.25, 0.03 },
+		"mercy":       func(e *Engine) { e.Playout.Mercy = 20 },
+		"eye rule":    func(e *Engine) { e.Playout.Eyes = 1 },
+		"network one": func(e *Engine) { e.Evaluator = namedEvaluator{id: "net-a"} },
+		"network two": func(e *Engine) { e.Evaluator = namedEvaluator{id: "net-b"} },
+	} {
+		e := NewEngine(100, 6.5)
+		set(e)
+		key, ok := analysisKey(e, b)
+		if !ok {
+			t.Errorf("%s: no key", name)
+		} else if other, dup := keys[key]; dup {
+			t.Errorf("%s has the key of %s", name, other)
+		}
+		keys[key] = name
+	}
+	// Settings that only pick among the candidates are the same analysis.
+	e := NewEngine(100, 6.5)
+	e.Noise, e.Choice = 0.3, 2
+	if key, _ := analysisKey(e, b); key != base {
+		t.Errorf("noise and choice change the key")
+	}
+
+	// An evaluator that cannot be told apart from others is never cached.
+	c, err := OpenAnalysisCache(filepath.Join(t.TempDir(), "cache.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = NewEngine(20, 6.5)
+	e.Evaluator = newPlayoutEvaluator(6.5, PlayoutPolicy{})
+	if _, ok := analysisKey(e, b); ok {
+		t.Error("a key for an evaluator without an identity")
+	}
+	c.Analyze(context.Background(), e, b)
+	if len(c.Entries) != 0 {
+		t.Errorf("cached %d analyses by an unidentified evaluator", len(c.Entries))
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	commands["analyze-dir"] = runAnalyzeDir
+}
+
+// analyze-dir reviews every SGF file under a directory as review -annotate
+// reviews one game, with a pool of workers that each have an engine, and
+// sums up one player's mistakes over them all. For each game it writes the
+// annotated record and the review's listing to the output directory, where
+// progress.json lists the files done: an interrupted run picks up where it
+// stopped, and a later one only looks at files added or changed since.
+//
+// Mistakes are the review's warnings, by their kind (self-atari and so on),
+// and the moves the engine marks bad or doubtful, which are also sorted by
+// type (see mistakes.go) for the training report that ends the summary.
+
+// DirGameReport is what analyze-dir found in one game of a file.
+type DirGameReport struct {
+	Game  int           `json:"game"` // within the file, from 1
+	Black DirSideReport `json:"black"`
+	White DirSideReport `json:"white"`
+}
+
+// DirSideReport counts the moves and mistakes of one side of a game.
+type DirSideReport struct {
+	Player   string              `json:"player"`
+	Moves    int                 `json:"moves"`
+	Mistakes map[string]int      `json:"mistakes,omitempty"` // by kind
+	Types    map[MistakeType]int `json:"types,omitempty"`    // of the bad and doubtful moves
+}
+
+// dirFileReport is what analyze-dir found in one file.
+type dirFileReport struct {
+	ModTime time.Time       `json:"mod_time"`
+	Games   []DirGameReport `json:"games,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// dirProgress is the progress file: the files done, by their path in the
+// directory. Files that failed are tried again.
+type dirProgress struct {
+	Playouts int                      `json:"playouts"`
+	Files    map[string]dirFileReport `json:"files"`
+}
+
+// side is the report of colour c.
+func (r *DirGameReport) side(c Stone) *DirSideReport {
+	if c == White {
+		return &r.White
+	}
+	return &r.Black
+}
+
+// gameMistakes counts the moves of each side of g, reviewed in report and
+// annotated, and their mistakes, with the types of the bad and doubtful
+// moves by move number.
+func gameMistakes(g *GameRecord, report map[int][]MoveWarning, types map[int]MistakeType) DirGameReport {
+	r := DirGameReport{Black: DirSideReport{Player: playerName(g.Black, "Black")}, White: DirSideReport{Player: playerName(g.White, "White")}}
+	for i, m := range g.Moves {
+		side := r.side(m.Color)
+		side.Moves++
+		kinds := make([]string, 0, len(report[i+1])+1)
+		for _, w := range report[i+1] {
+			kinds = append(kinds, w.Kind)
+		}
+		switch q, _ := m.Quality(); q {
+		case BadMove:
+			kinds = append(kinds, "bad-move")
+		case DoubtfulMove:
+			kinds = append(kinds, "doubtful-move")
+		}
+		for _, kind := range kinds {
+			if side.Mistakes == nil {
+				side.Mistakes = map[string]int{}
+			}
+			side.Mistakes[kind]++
+		}
+		if t, ok := types[i+1]; ok {
+			if side.Types == nil {
+				side.Types = map[MistakeType]int{}
+			}
+			side.Types[t]++
+		}
+	}
+	return r
+}
+
+// analyzeDirFile reviews and annotates the games of the file at rel in dir
+// with e, writing them and their listings under out.
+func analyzeDirFile(ctx context.Context, e *Engine, cache *AnalysisCache, dir, out, rel string) dirFileReport {
+	var report dirFileReport
+	fail := func(err error) dirFileReport {
+		report.Error = err.Error()
+		return report
+	}
+	info, err := os.Stat(filepath.Join(dir, rel))
+	if err != nil {
+		return fail(err)
+	}
+	report.ModTime = info.ModTime()
+	games, err := ReadSGFFile(filepath.Join(dir, rel))
+	if err != nil {
+		return fail(err)
+	}
+	base := filepath.Join(out, strings.TrimSuffix(rel, filepath.Ext(rel)))
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return fail(err)
+	}
+	for n, tree := range games {
+		g, err := RecordFromSGF(tree)
+		if err != nil {
+			return fail(fmt.Errorf("game %d: %v", n+1, err))
+		}
+		warnings, err := ReviewGame(g)
+		if err != nil {
+			return fail(fmt.Errorf("game %d: %v", n+1, err))
+		}
+		if _, err := AnnotateGame(ctx, e, cache, g); err != nil {
+			return fail(fmt.Errorf("game %d: %v", n+1, err))
+		}
+		types, err := ClassifyMistakes(ctx, e, g)
+		if err != nil {
+			return fail(fmt.Errorf("game %d: %v", n+1, err))
+		}
+		for i, t := range types {
+			g.Moves[i-1].Comment = appendComment(g.Moves[i-1].Comment, "Mistake type: "+string(t))
+		}
+		name := base
+		if len(games) > 1 {
+			name = fmt.Sprintf("%s-%d", base, n+1)
+		}
+		if err := WriteSGFFile(name+".sgf", []*SGFNode{g.SGF()}); err != nil {
+			return fail(err)
+		}
+		var listing strings.Builder
+		fmt.Fprintf(&listing, "%s game %d: %s (B) vs %s (W)\n", rel, n+1, playerName(g.Black, "Black"), playerName(g.White, "White"))
+		writeReview(&listing, g, warnings, Empty)
+		if err := saveFile(name+".txt", []byte(listing.String())); err != nil {
+			return fail(err)
+		}
+		r := gameMistakes(g, warnings, types)
+		r.Game = n + 1
+		report.Games = append(report.Games, r)
+	}
+	return report
+}
+
+// sgfFiles lists the SGF files under dir, by their paths in it, leaving
+// out the directory skip unless it is empty.
+func sgfFiles(dir, skip string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skip != "" && d.IsDir() && path == filepath.Clean(skip) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".sgf") {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// MistakeCount is how often a kind of mistake was made.
+type MistakeCount struct {
+	Kind  string
+	Count int
+}
+
+// SummarizeMistakes adds up player's mistakes in reports, most common
+// first, with the games and moves they come from. An empty player counts
+// both sides of every game.
+func SummarizeMistakes(player string, reports []DirGameReport) (counts []MistakeCount, games, moves int) {
+	byKind := map[string]int{}
+	for _, r := range reports {
+		played := false
+		for _, side := range []DirSideReport{r.Black, r.White} {
+			if player != "" && !strings.EqualFold(side.Player, player) {
+				continue
+			}
+			played = true
+			moves += side.Moves
+			for kind, n := range side.Mistakes {
+				byKind[kind] += n
+			}
+		}
+		if played {
+			games++
+		}
+	}
+	for kind, n := range byKind {
+		counts = append(counts, MistakeCount{kind, n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Kind < counts[j].Kind
+	})
+	return counts, games, moves
+}
+
+// writeMistakeSummary writes player's summary over progress and the
+// training report, with the problems of puzzles to practise.
+func writeMistakeSummary(w io.Writer, player string, progress *dirProgress, puzzles []*Puzzle) {
+	var reports []DirGameReport
+	for _, f := range progress.Files {
+		reports = append(reports, f.Games...)
+	}
+	counts, games, moves := SummarizeMistakes(player, reports)
+	who := player
+	if who == "" {
+		who = "both sides"
+	}
+	fmt.Fprintf(w, "Mistakes of %s in %d games, %d moves:\n", who, games, moves)
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "  none found")
+	}
+	for _, c := range counts {
+		fmt.Fprintf(w, "  %-14s %5d  %4.1f per 100 moves\n", c.Kind, c.Count, 100*float64(c.Count)/float64(moves))
+	}
+	writeTrainingReport(w, player, reports, puzzles)
+}
+
+func runAnalyzeDir(args []string) error {
+	fs := flag.NewFlagSet("analyze-dir", flag.ExitOnError)
+	player := fs.String("player", defaultPlayer(), "player whose mistakes to sum up, as named in the games; empty for both sides")
+	playouts := fs.Int("playouts", 2000, "playouts per position")
+	workers := fs.Int("workers", runtime.NumCPU(), "games analysed at once")
+	outDir := fs.String("o", "", "directory for the reports (default DIR/analysis)")
+	useCache := fs.Bool("cache", true, "look positions up in the analysis cache and keep new analyses there")
+	problems := fs.String("problems", "", "the problem library to recommend from, SGF files or directories separated by commas")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: analyze-dir [flags] DIR")
+	}
+	dir := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the directory
+	out := *outDir
+	if out == "" {
+		out = filepath.Join(dir, "analysis")
+	}
+	if *workers < 1 {
+		return fmt.Errorf("invalid -workers %d", *workers)
+	}
+
+	progressPath := filepath.Join(out, "progress.json")
+	progress := &dirProgress{Playouts: *playouts}
+	if err := loadJSON(progressPath, progress); err != nil {
+		return fmt.Errorf("%s: %v", progressPath, err)
+	}
+	if progress.Playouts != *playouts || progress.Files == nil {
+		if len(progress.Files) > 0 {
+			fmt.Printf("%s was made with %d playouts; starting over\n", progressPath, progress.Playouts)
+		}
+		progress = &dirProgress{Playouts: *playouts, Files: map[string]dirFileReport{}}
+	}
+	files, err := sgfFiles(dir, out)
+	if err != nil {
+		return err
+	}
+	present := map[string]bool{}
+	for _, rel := range files {
+		present[rel] = true
+	}
+	for rel := range progress.Files {
+		if !present[rel] {
+			delete(progress.Files, rel) // removed from the directory
+		}
+	}
+	var todo []string
+	for _, rel := range files {
+		done, ok := progress.Files[rel]
+		info, err := os.Stat(filepath.Join(dir, rel))
+		if ok && done.Error == "" && err == nil && info.ModTime().Equal(done.ModTime) {
+			continue
+		}
+		todo = append(todo, rel)
+	}
+	fmt.Printf("%d SGF files in %s, %d to analyse\n", len(files), dir, len(todo))
+	cache, err := openAnalysisCache(*useCache)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		rel    string
+		report dirFileReport
+	}
+	jobs := make(chan string)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for w := 0; w < min(*workers, len(todo)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := NewEngine(*playouts, 6.5)
+			for rel := range jobs {
+				results <- result{rel, analyzeDirFile(context.Background(), e, cache, dir, out, rel)}
+			}
+		}()
+	}
+	go func() {
+		for _, rel := range todo {
+			jobs <- rel
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	done := 0
+	for r := range results {
+		done++
+		progress.Files[r.rel] = r.report
+		// Saved after every file, so an interrupted run loses no more.
+		if err := saveJSON(progressPath, progress); err != nil {
+			return err
+		}
+		status := fmt.Sprintf("%d games", len(r.report.Games))
+		if r.report.Error != "" {
+			status = "error: " + r.report.Error
+		}
+		fmt.Printf("[%d/%d] %s: %s\n", done, len(todo), r.rel, status)
+	}
+	if err := saveAnalysisCache(cache); err != nil {
+		return err
+	}
+
+	var puzzles []*Puzzle
+	if *problems != "" {
+		if puzzles, err = LoadPuzzles(strings.Split(*problems, ",")); err != nil {
+			return err
+		}
+	}
+	var summary strings.Builder
+	writeMistakeSummary(&summary, *player, progress, puzzles)
+	fmt.Print(summary.String())
+	summaryPath := filepath.Join(out, "summary.txt")
+	if err := saveFile(summaryPath, []byte(summary.String())); err != nil {
+		return err
+	}
+	fmt.Printf("Reports in %s\n", out)
+	return nil
+}
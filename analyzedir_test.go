@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestSummarizeMistakes(t *testing.T) {
+	g := &GameRecord{Size: 9, Black: "Alice", White: "Bob", Moves: []RecordedMove{
+		{Color: Black, Move: 40}, {Color: White, Move: 0}, {Color: Black, Move: 8}, {Color: White, Move: 20},
+	}}
+	g.Moves[3].SetQuality(BadMove, 1)
+	report, err := ReviewGame(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := gameMistakes(g, report, map[int]MistakeType{4: OverplayMistake})
+	if first.Black.Moves != 2 || first.Black.Mistakes["first-line"] != 1 {
+		t.Errorf("Black: %+v", first.Black)
+	}
+	if first.White.Mistakes["first-line"] != 1 || first.White.Mistakes["bad-move"] != 1 || first.White.Types[OverplayMistake] != 1 {
+		t.Errorf("White: %+v", first.White)
+	}
+
+	second := DirGameReport{
+		Black: DirSideReport{Player: "bob", Moves: 10, Mistakes: map[string]int{"self-atari": 3}},
+		White: DirSideReport{Player: "Carol", Moves: 10, Mistakes: map[string]int{"own-eye": 5}},
+	}
+	counts, games, moves := SummarizeMistakes("bob", []DirGameReport{first, second})
+	want := []MistakeCount{{"self-atari", 3}, {"bad-move", 1}, {"first-line", 1}}
+	if games != 2 || moves != 12 || len(counts) != len(want) {
+		t.Fatalf("got %v in %d games, %d moves", counts, games, moves)
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Errorf("counts[%d] = %v, want %v", i, counts[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MoveQuality is an SGF move annotation: TE (good move), BM (bad move),
+// DO (doubtful) or IT (interesting). A record carries at most one per
+// move.
+type MoveQuality string
+
+const (
+	NoQuality       MoveQuality = ""
+	GoodMove        MoveQuality = "TE"
+	BadMove         MoveQuality = "BM"
+	DoubtfulMove    MoveQuality = "DO"
+	InterestingMove MoveQuality = "IT"
+)
+
+// Thresholds for annotating moves from analysis, as win-rate losses for
+// the player who moved.
+const (
+	badMoveLoss      = 0.12
+	veryBadMoveLoss  = 0.25
+	doubtfulMoveLoss = 0.06
+	// A move is good when it is the engine's first choice and the next
+	// best is this much worse, and interesting when the engine gave it
+	// less than interestingVisits of its playouts yet it loses less than
+	// interestingLoss.
+	goodMoveMargin    = 0.15
+	interestingVisits = 0.02
+	interestingLoss   = 0.03
+)
+
+// Quality returns the move's annotation and its emphasis: 2 for a very
+// good or very bad move (TE[2], BM[2]), otherwise 1.
+func (m RecordedMove) Quality() (MoveQuality, int) {
+	for _, p := range m.Markup {
+		switch q := MoveQuality(p.ID); q {
+		case GoodMove, BadMove:
+			if len(p.Values) > 0 && p.Values[0] == "2" {
+				return q, 2
+			}
+			return q, 1
+		case DoubtfulMove, InterestingMove:
+			return q, 1
+		}
+	}
+	return NoQuality, 0
+}
+
+// SetQuality replaces the move's annotation; NoQuality removes it.
+func (m *RecordedMove) SetQuality(q MoveQuality, emphasis int) {
+	kept := m.Markup[:0]
+	for _, p := range m.Markup {
+		switch MoveQuality(p.ID) {
+		case GoodMove, BadMove, DoubtfulMove, InterestingMove:
+		default:
+			kept = append(kept, p)
+		}
+	}
+	m.Markup = kept
+	switch q {
+	case GoodMove, BadMove:
+		m.Markup = append(m.Markup, SGFProperty{ID: string(q), Values: []string{fmt.Sprint(max(1, min(emphasis, 2)))}})
+	case DoubtfulMove, InterestingMove:
+		m.Markup = append(m.Markup, SGFProperty{ID: string(q), Values: []string{""}})
+	}
+}
+
+// qualitySymbol is the usual symbol for an annotation: !, !!, ?, ??, ?!
+// or !?.
+func qualitySymbol(q MoveQuality, emphasis int) string {
+	switch q {
+	case GoodMove:
+		return strings.Repeat("!", max(1, min(emphasis, 2)))
+	case BadMove:
+		return strings.Repeat("?", max(1, min(emphasis, 2)))
+	case DoubtfulMove:
+		return "?!"
+	case InterestingMove:
+		return "!?"
+	}
+	return ""
+}
+
+// qualityName describes an annotation in words.
+func qualityName(q MoveQuality, emphasis int) string {
+	names := map[MoveQuality]string{GoodMove: "good move", BadMove: "bad move", DoubtfulMove: "doubtful move", InterestingMove: "interesting move"}
+	if emphasis == 2 && (q == GoodMove || q == BadMove) {
+		return "very " + names[q]
+	}
+	return names[q]
+}
+
+// moveMark is the move as shown in move lists, with its annotation symbol.
+func moveMark(m RecordedMove, size int) string {
+	s := userCoords.Move(m.Move, size)
+	if q, emphasis := m.Quality(); q != NoQuality {
+		s += " " + qualitySymbol(q, emphasis)
+	}
+	return s
+}
+
+// judgeMove picks the annotation for a move from the analysis of the
+// position before it and the win rate the mover is left with after it.
+func judgeMove(before MoveAnalysis, move int, after float64) (MoveQuality, int) {
+	loss := before.WinRate - after
+	switch {
+	case loss >= veryBadMoveLoss:
+		return BadMove, 2
+	case loss >= badMoveLoss:
+		return BadMove, 1
+	case loss >= doubtfulMoveLoss:
+		return DoubtfulMove, 1
+	}
+	if len(before.Candidates) < 2 {
+		return NoQuality, 0
+	}
+	if before.Candidates[0].Move == move && before.Candidates[0].WinRate-before.Candidates[1].WinRate >= goodMoveMargin {
+		return GoodMove, 1
+	}
+	total, visits := 0, 0
+	for _, c := range before.Candidates {
+		total += c.Visits
+		if c.Move == move {
+			visits = c.Visits
+		}
+	}
+	if loss < interestingLoss && float64(visits) < interestingVisits*float64(total) {
+		return InterestingMove, 1
+	}
+	return NoQuality, 0
+}
+
+// AnnotateGame analyses every position of g with e, through cache unless
+// it is nil, keeps the analysis in g.Analysis and marks the moves that
+// stand out. Marks already in the record are left alone. Each marked
+// move's comment gets the win rates and, for mistakes, the engine's choice.
+func AnnotateGame(ctx context.Context, e *Engine, cache *AnalysisCache, g *GameRecord) (marked int, err error) {
+	e.Komi = g.Komi
+	b := g.Start()
+	analyses := make([]MoveAnalysis, 0, len(g.Moves)+1)
+	for i := 0; ; i++ {
+		a, err := cache.Analyze(ctx, e, b)
+		if err != nil {
+			return marked, err
+		}
+		a.MoveNumber = i
+		analyses = append(analyses, a)
+		if i == len(g.Moves) {
+			break
+		}
+		m := g.Moves[i]
+		b.turn = m.Color
+		if !b.Play(m.Move) {
+			return marked, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	g.Analysis = analyses
+
+	for i := range g.Moves {
+		m := &g.Moves[i]
+		if q, _ := m.Quality(); q != NoQuality || m.Move == PassMove {
+			continue
+		}
+		before, after := analyses[i], 1-analyses[i+1].WinRate
+		q, emphasis := judgeMove(before, m.Move, after)
+		if q == NoQuality {
+			continue
+		}
+		m.SetQuality(q, emphasis)
+		marked++
+		m.Comment = appendComment(m.Comment, moveNote(before, analyses[i+1], q, g.Size))
+	}
+	return marked, nil
+}
+
+// moveNote is the comment on a move between the analyses before and next:
+// the mover's win rates, their expected leads when the engine estimates
+// them and, for mistakes, the engine's choice.
+func moveNote(before, next MoveAnalysis, q MoveQuality, size int) string {
+	note := fmt.Sprintf("Win rate %.0f%% → %.0f%%", 100*before.WinRate, 100*(1-next.WinRate))
+	if before.ScoreStdev > 0 && next.ScoreStdev > 0 {
+		note += fmt.Sprintf(", lead %+.1f → %+.1f", before.ScoreMean, -next.ScoreMean)
+	}
+	if (q == BadMove || q == DoubtfulMove) && len(before.Candidates) > 0 {
+		note += fmt.Sprintf("; %s was better", userCoords.Move(before.Candidates[0].Move, size))
+	}
+	return note
+}
+
+// appendComment adds note on a line of its own after comment.
+func appendComment(comment, note string) string {
+	if comment == "" {
+		return note
+	}
+	return comment + "\n" + note
+}
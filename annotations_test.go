@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoveQuality(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader("(;GM[1]SZ[9];B[ee]TE[2];W[ef]DO[];B[fe]C[hm])"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := RecordFromSGF(games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := moveMark(g.Moves[0], 9); got != "4 4 !!" {
+		t.Errorf("first move shown as %q", got)
+	}
+	if q, _ := g.Moves[1].Quality(); q != DoubtfulMove {
+		t.Errorf("second move annotated %q", q)
+	}
+	g.Moves[1].SetQuality(BadMove, 1)
+	g.Moves[2].SetQuality(InterestingMove, 1)
+	var sb strings.Builder
+	if err := WriteSGF(&sb, []*SGFNode{g.SGF()}); err != nil {
+		t.Fatal(err)
+	}
+	if s := sb.String(); !strings.Contains(s, "W[ef]BM[1]") || strings.Contains(s, "DO") || !strings.Contains(s, "IT[]") {
+		t.Errorf("annotations not written: %s", s)
+	}
+
+	before := MoveAnalysis{WinRate: 0.6, Candidates: []CandidateMove{
+		{Move: 1, Visits: 500, WinRate: 0.6}, {Move: 2, Visits: 200, WinRate: 0.4}, {Move: 3, Visits: 200, WinRate: 0.3},
+		{Move: 4, Visits: 99, WinRate: 0.3}, {Move: 5, Visits: 1, WinRate: 0.2},
+	}}
+	for _, tc := range []struct {
+		move     int
+		after    float64
+		want     MoveQuality
+		emphasis int
+	}{
+		{1, 0.6, GoodMove, 1},
+		{2, 0.3, BadMove, 2},
+		{3, 0.45, BadMove, 1},
+		{4, 0.52, DoubtfulMove, 1},
+		{9, 0.59, InterestingMove, 1},
+		{5, 0.58, InterestingMove, 1},
+		{3, 0.58, NoQuality, 0},
+	} {
+		if q, e := judgeMove(before, tc.move, tc.after); q != tc.want || e != tc.emphasis {
+			t.Errorf("move %d to %g: %q %d, want %q %d", tc.move, tc.after, q, e, tc.want, tc.emphasis)
+		}
+	}
+}
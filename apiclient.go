@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// APIClient calls the puzzles and analysis-api servers, the games API
+// included, whose paths and JSON are described in openapi.json, with the
+// same types the servers use, so a Go program needs no HTTP code of its
+// own. It has a method for each operation of openapi.json, named after its
+// operationId:
+//
+//	c := &APIClient{Base: "http://localhost:8080"}
+//	res, err := c.Analyze(ctx, AnalysisRequest{Size: 9, Komi: 7, Moves: []string{"E5"}})
+//
+// A server's error comes back as an *APIError.
+type APIClient struct {
+	Base   string       // the server's URL, without a trailing slash
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// APIError is an error answered by a server.
+type APIError struct {
+	Status  int    // the HTTP status
+	Message string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// call sends body, if not nil, as JSON to path and decodes the answer into
+// result, if not nil.
+func (c *APIClient) call(ctx context.Context, method, path string, body, result any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		e := &APIError{Status: resp.StatusCode}
+		if json.NewDecoder(resp.Body).Decode(e) != nil || e.Message == "" {
+			e.Message = "no error message"
+		}
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// Puzzles lists the puzzles, without their stones.
+func (c *APIClient) Puzzles(ctx context.Context) ([]PuzzleInfo, error) {
+	var list []PuzzleInfo
+	err := c.call(ctx, http.MethodGet, "/puzzles", nil, &list)
+	return list, err
+}
+
+// Puzzle is the puzzle id, with its stones.
+func (c *APIClient) Puzzle(ctx context.Context, id string) (PuzzleInfo, error) {
+	var info PuzzleInfo
+	err := c.call(ctx, http.MethodGet, "/puzzles/"+url.PathEscape(id), nil, &info)
+	return info, err
+}
+
+// CheckPuzzle checks the line played on puzzle id so far, the player's
+// moves and the replies, as GTP vertices.
+func (c *APIClient) CheckPuzzle(ctx context.Context, id string, moves []string) (PuzzleAnswer, error) {
+	var ans PuzzleAnswer
+	req := struct {
+		Moves []string `json:"moves"`
+	}{moves}
+	if req.Moves == nil {
+		req.Moves = []string{}
+	}
+	err := c.call(ctx, http.MethodPost, "/puzzles/"+url.PathEscape(id)+"/check", req, &ans)
+	return ans, err
+}
+
+// Analyze asks the analysis server about a position.
+func (c *APIClient) Analyze(ctx context.Context, req AnalysisRequest) (AnalysisResult, error) {
+	var res AnalysisResult
+	err := c.call(ctx, http.MethodPost, "/analyze", req, &res)
+	return res, err
+}
+
+// Games lists the games the server is running.
+func (c *APIClient) Games(ctx context.Context) ([]GameState, error) {
+	var list []GameState
+	err := c.call(ctx, http.MethodGet, "/games", nil, &list)
+	return list, err
+}
+
+// NewGame starts a game.
+func (c *APIClient) NewGame(ctx context.Context, req NewGameRequest) (GameState, error) {
+	var g GameState
+	err := c.call(ctx, http.MethodPost, "/games", req, &g)
+	return g, err
+}
+
+// Game is the game id.
+func (c *APIClient) Game(ctx context.Context, id string) (GameState, error) {
+	var g GameState
+	err := c.call(ctx, http.MethodGet, "/games/"+url.PathEscape(id), nil, &g)
+	return g, err
+}
+
+// DeleteGame ends the game id and forgets it.
+func (c *APIClient) DeleteGame(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, "/games/"+url.PathEscape(id), nil, nil)
+}
+
+// PlayMove plays move, a GTP vertex, "pass" or "resign", for the side to
+// move in game id.
+func (c *APIClient) PlayMove(ctx context.Context, id, move string) (GameState, error) {
+	var g GameState
+	err := c.call(ctx, http.MethodPost, "/games/"+url.PathEscape(id)+"/moves", MoveRequest{Move: move}, &g)
+	return g, err
+}
+
+// GenMove has the engine play for the side to move in game id.
+func (c *APIClient) GenMove(ctx context.Context, id string) (GameState, error) {
+	var g GameState
+	err := c.call(ctx, http.MethodPost, "/games/"+url.PathEscape(id)+"/genmove", nil, &g)
+	return g, err
+}
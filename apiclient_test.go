@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPIClientPuzzles(t *testing.T) {
+	srv := httptest.NewServer(puzzleHandler([]*Puzzle{testPuzzle(t)}, nil))
+	defer srv.Close()
+	c, ctx := &APIClient{Base: srv.URL}, context.Background()
+
+	list, err := c.Puzzles(ctx)
+	if err != nil || len(list) != 1 || list[0].ID != "corner" || list[0].Black != nil {
+		t.Fatalf("Puzzles = %+v, %v", list, err)
+	}
+	info, err := c.Puzzle(ctx, "corner")
+	if err != nil || !reflect.DeepEqual(info.Black, []string{"G3"}) || info.ToMove != "black" {
+		t.Errorf("Puzzle = %+v, %v", info, err)
+	}
+	ans, err := c.CheckPuzzle(ctx, "corner", []string{"C7"})
+	if err != nil || !ans.Correct || ans.Solved || ans.Reply != "B7" {
+		t.Errorf("CheckPuzzle = %+v, %v", ans, err)
+	}
+	var apiErr *APIError
+	if _, err := c.Puzzle(ctx, "nope"); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Message != "no such puzzle" {
+		t.Errorf("an unknown puzzle: %v", err)
+	}
+	if _, err := c.CheckPuzzle(ctx, "corner", []string{"Z99"}); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("a bad vertex: %v", err)
+	}
+}
+
+func TestAPIClientGames(t *testing.T) {
+	srv := httptest.NewServer(gamesHandler(50, 10*time.Second, nil))
+	defer srv.Close()
+	c, ctx := &APIClient{Base: srv.URL}, context.Background()
+
+	g, err := c.NewGame(ctx, NewGameRequest{Size: 9, Komi: 7, Handicap: 2})
+	if err != nil || g.ID != "1" || g.ToMove != "white" || len(g.Board) != 9 || g.Board[2] != "......X.." || g.Board[6] != "..X......" {
+		t.Fatalf("NewGame = %+v, %v", g, err)
+	}
+	if g, err = c.GenMove(ctx, g.ID); err != nil || len(g.Moves) != 1 || g.Moves[0] == "pass" || g.ToMove != "black" {
+		t.Fatalf("GenMove = %+v, %v", g, err)
+	}
+	if g, err = c.PlayMove(ctx, g.ID, "pass"); err != nil || g.ToMove != "white" || g.Result != "" {
+		t.Fatalf("PlayMove(pass) = %+v, %v", g, err)
+	}
+	if g, err = c.PlayMove(ctx, g.ID, "pass"); err != nil || g.Result == "" {
+		t.Fatalf("two passes left %+v, %v", g, err)
+	}
+	var apiErr *APIError
+	if _, err := c.PlayMove(ctx, g.ID, "E5"); !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		t.Errorf("a move after the end: %v", err)
+	}
+
+	g2, err := c.NewGame(ctx, NewGameRequest{Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g2, err = c.PlayMove(ctx, g2.ID, "C3"); err != nil || g2.Board[2] != "..X.." {
+		t.Fatalf("PlayMove(C3) = %+v, %v", g2, err)
+	}
+	if _, err := c.PlayMove(ctx, g2.ID, "C3"); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("a move on a stone: %v", err)
+	}
+	if g2, err = c.PlayMove(ctx, g2.ID, "resign"); err != nil || g2.Result != "B+R" {
+		t.Errorf("White's resignation = %+v, %v", g2, err)
+	}
+	if list, err := c.Games(ctx); err != nil || len(list) != 2 || list[0].ID != "1" || list[1].ID != g2.ID {
+		t.Errorf("Games = %+v, %v", list, err)
+	}
+	if err := c.DeleteGame(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Game(ctx, "1"); !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("a deleted game: %v", err)
+	}
+	if _, err := c.NewGame(ctx, NewGameRequest{Size: 60}); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("a 60x60 board: %v", err)
+	}
+}
+
+// TestAPIClientMatchesSpec calls every APIClient method through a server
+// routed by openapi.json alone, so that each method is the operation its
+// name says, on the path and with the method the spec gives it.
+func TestAPIClientMatchesSpec(t *testing.T) {
+	data, err := os.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := http.NewServeMux()
+	puzzles := puzzleHandler([]*Puzzle{testPuzzle(t)}, nil)
+	games := gamesHandler(20, 10*time.Second, nil)
+	servers.Handle("/puzzles", puzzles)
+	servers.Handle("/puzzles/", puzzles)
+	servers.Handle("/analyze", analysisHandler(20, 10*time.Second, nil, nil))
+	servers.Handle("/games", games)
+	servers.Handle("/games/", games)
+
+	var (
+		mu     sync.Mutex
+		called string
+	)
+	routed := http.NewServeMux()
+	ops := map[string]bool{}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			ops[op.OperationID] = true
+			routed.HandleFunc(strings.ToUpper(method)+" "+path, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				called = op.OperationID
+				mu.Unlock()
+				servers.ServeHTTP(w, r)
+			})
+		}
+	}
+	srv := httptest.NewServer(routed)
+	defer srv.Close()
+	c, ctx := &APIClient{Base: srv.URL}, context.Background()
+
+	calls := []struct {
+		op   string
+		call func() error
+	}{
+		{"puzzles", func() error { _, err := c.Puzzles(ctx); return err }},
+		{"puzzle", func() error { _, err := c.Puzzle(ctx, "corner"); return err }},
+		{"checkPuzzle", func() error { _, err := c.CheckPuzzle(ctx, "corner", []string{"C7"}); return err }},
+		{"analyze", func() error { _, err := c.Analyze(ctx, AnalysisRequest{Size: 5}); return err }},
+		{"newGame", func() error { _, err := c.NewGame(ctx, NewGameRequest{Size: 5}); return err }},
+		{"games", func() error { _, err := c.Games(ctx); return err }},
+		{"game", func() error { _, err := c.Game(ctx, "1"); return err }},
+		{"playMove", func() error { _, err := c.PlayMove(ctx, "1", "C3"); return err }},
+		{"genMove", func() error { _, err := c.GenMove(ctx, "1"); return err }},
+		{"deleteGame", func() error { return c.DeleteGame(ctx, "1") }},
+	}
+	client := reflect.TypeOf(c)
+	if client.NumMethod() != len(ops) || len(calls) != len(ops) {
+		t.Errorf("APIClient has %d methods and the test calls %d, for %d operations", client.NumMethod(), len(calls), len(ops))
+	}
+	for _, call := range calls {
+		if !ops[call.op] {
+			t.Errorf("openapi.json has no operation %s", call.op)
+		}
+		if _, ok := client.MethodByName(strings.ToUpper(call.op[:1]) + call.op[1:]); !ok {
+			t.Errorf("APIClient has no method for operation %s", call.op)
+		}
+		err := call.call()
+		mu.Lock()
+		reached := called
+		mu.Unlock()
+		if err != nil || reached != call.op {
+			t.Errorf("the call for %s reached %q: %v", call.op, reached, err)
+		}
+	}
+}
+
+func TestAPIClientAnalyze(t *testing.T) {
+	srv := httptest.NewServer(analysisHandler(100, 10*time.Second, nil, nil))
+	defer srv.Close()
+	c, ctx := &APIClient{Base: srv.URL}, context.Background()
+
+	res, err := c.Analyze(ctx, AnalysisRequest{Size: 5, Komi: 0.5, Moves: []string{"C3"}, Histogram: 5})
+	if err != nil || res.ToMove != "white" || res.Playouts != 100 || len(res.Candidates) == 0 || len(res.Histogram) == 0 {
+		t.Errorf("Analyze = %+v, %v", res, err)
+	}
+	var apiErr *APIError
+	if _, err := c.Analyze(ctx, AnalysisRequest{Size: 1}); !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("a 1x1 board: %v", err)
+	}
+}
+
+// TestOpenAPISpec checks openapi.json against the servers' types, so that
+// the two do not drift apart.
+func TestOpenAPISpec(t *testing.T) {
+	data, err := os.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []string
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(routes)
+	want := []string{
+		"DELETE /games/{id}", "GET /games", "GET /games/{id}", "GET /puzzles", "GET /puzzles/{id}",
+		"POST /analyze", "POST /games", "POST /games/{id}/genmove", "POST /games/{id}/moves", "POST /puzzles/{id}/check",
+	}
+	if !slices.Equal(routes, want) {
+		t.Errorf("paths %v, want %v", routes, want)
+	}
+
+	for name, v := range map[string]any{
+		"Error":             APIError{},
+		"PuzzleInfo":        PuzzleInfo{},
+		"PuzzleAnswer":      PuzzleAnswer{},
+		"AnalysisRequest":   AnalysisRequest{},
+		"AnalysisResult":    AnalysisResult{},
+		"AnalysisCandidate": AnalysisCandidate{},
+		"ScoreBin":          ScoreBin{},
+		"NewGameRequest":    NewGameRequest{},
+		"MoveRequest":       MoveRequest{},
+		"GameState":         GameState{},
+	} {
+		var fields []string
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			if tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ","); tag != "" {
+				fields = append(fields, tag)
+			}
+		}
+		var props []string
+		for p := range spec.Components.Schemas[name].Properties {
+			props = append(props, p)
+		}
+		slices.Sort(fields)
+		slices.Sort(props)
+		if !slices.Equal(fields, props) {
+			t.Errorf("schema %s has %v, %s has %v", name, props, typ.Name(), fields)
+		}
+	}
+}
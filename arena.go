@@ -0,0 +1,117 @@
+package main
+
+import (
+	"slices"
+	"unsafe"
+)
+
+// Search tree nodes come from an arena: fixed-size blocks of nodes that
+// are reused from search to search, with a free list for nodes recycled
+// when the tree outgrows the engine's memory cap.
+
+const arenaBlock = 4096
+
+var (
+	nodeBytes = int64(unsafe.Sizeof(node{}))
+	moveBytes = int64(unsafe.Sizeof(int(0)))
+	ptrBytes  = int64(unsafe.Sizeof((*node)(nil)))
+)
+
+type nodeArena struct {
+	blocks [][]node
+	block  int // index of the block being filled
+	used   int // nodes handed out from blocks[block]
+	free   []*node
+	live   int
+	bytes  int64 // estimate of the memory held by live nodes
+}
+
+// reset makes every node available again, for a new search.
+func (a *nodeArena) reset() {
+	a.block, a.used, a.free, a.live, a.bytes = 0, 0, a.free[:0], 0, 0
+}
+
+// alloc returns a zeroed node.
+func (a *nodeArena) alloc() *node {
+	var n *node
+	if len(a.free) > 0 {
+		n = a.free[len(a.free)-1]
+		a.free = a.free[:len(a.free)-1]
+	} else {
+		if a.block < len(a.blocks) && a.used == arenaBlock {
+			a.block, a.used = a.block+1, 0
+		}
+		if a.block == len(a.blocks) {
+			a.blocks = append(a.blocks, make([]node, arenaBlock))
+		}
+		n = &a.blocks[a.block][a.used]
+		a.used++
+	}
+	*n = node{}
+	a.live++
+	a.bytes += nodeBytes + ptrBytes // the node and its slot in the parent
+	return n
+}
+
+// setUntried gives n its untried moves, accounting for their memory.
+func (a *nodeArena) setUntried(n *node, moves []int) {
+	n.untried = moves
+	a.bytes += int64(cap(moves)) * moveBytes
+}
+
+// releaseChildren returns every descendant of n to the free list and
+// makes n a leaf again. Its statistics are kept.
+func (a *nodeArena) releaseChildren(n *node) {
+	for _, c := range n.children {
+		a.releaseChildren(c)
+		a.bytes -= nodeBytes + ptrBytes + int64(cap(c.untried))*moveBytes
+		a.live--
+		*c = node{}
+		a.free = append(a.free, c)
+	}
+	a.bytes -= int64(cap(n.untried)) * moveBytes
+	n.children, n.untried = nil, nil
+	n.collapsed = true
+}
+
+// reroot makes keep, a node of the tree at root, the root of a tree of its
+// own, and frees the rest.
+func (a *nodeArena) reroot(root, keep *node) {
+	if keep == root {
+		return
+	}
+	p := keep.parent
+	p.children = slices.DeleteFunc(p.children, func(c *node) bool { return c == keep })
+	keep.parent = nil
+	a.releaseChildren(root)
+	a.bytes -= nodeBytes + ptrBytes
+	a.live--
+	*root = node{}
+	a.free = append(a.free, root)
+}
+
+// prune collapses the subtrees of the least visited nodes until at most
+// target bytes are in use, raising the visit threshold each pass. It
+// returns the number of nodes recycled.
+func (a *nodeArena) prune(root *node, target int64) int {
+	before := a.live
+	for threshold := 2; a.bytes > target && threshold <= root.visits; threshold *= 2 {
+		a.collapseBelow(root, threshold)
+	}
+	return before - a.live
+}
+
+func (a *nodeArena) collapseBelow(n *node, threshold int) {
+	for _, c := range n.children {
+		if c.pending > 0 {
+			// A worker is waiting to back up through this subtree.
+			a.collapseBelow(c, threshold)
+		} else if c.visits < threshold {
+			if len(c.children) > 0 {
+				a.releaseChildren(c)
+			}
+		} else {
+			a.collapseBelow(c, threshold)
+		}
+	}
+}
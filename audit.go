@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	commands["audit"] = runAudit
+}
+
+// Audit logs let a tournament show afterwards that its games were played
+// as recorded. serve -audit DIR writes one log per game to DIR, a line
+// for the start, every move and pass, every undo and the end, as they
+// happen. Each line is signed with the server's ed25519 key and carries
+// the SHA-256 of the line before it, so a line changed, removed, added or
+// moved is found by audit verify, given the public key the server printed
+// when it started. A stored SGF of the game can be checked against its
+// log the same way.
+//
+//	{"seq": 3, "time": "...", "game": "alice vs bob", "kind": "move", "color": "black",
+//	 "number": 2, "move": "2 3", "hash": "...", "prev": "9f2c...", "sig": "..."}
+//
+// A log cut short after its last line still verifies; that it ends
+// without an end or adjourn line is what gives it away.
+
+// AuditEntry is one line of an audit log. Kind is start, move, pass,
+// undo, adjourn or end.
+type AuditEntry struct {
+	Seq    int       `json:"seq"`
+	Time   time.Time `json:"time"`
+	Game   string    `json:"game"`
+	Kind   string    `json:"kind"`
+	Size   int       `json:"size,omitempty"`  // start
+	Komi   float64   `json:"komi,omitempty"`  // start
+	Black  string    `json:"black,omitempty"` // start, the players
+	White  string    `json:"white,omitempty"`
+	Color  string    `json:"color,omitempty"`  // move and pass
+	Number int       `json:"number,omitempty"` // moves and passes played, after a move, pass or undo
+	Move   string    `json:"move,omitempty"`
+	Hash   uint64    `json:"hash,string,omitempty"` // of the position after a move, pass or undo
+	Result string    `json:"result,omitempty"`      // end
+	Prev   string    `json:"prev"`                  // SHA-256 of the line before, in hex; empty for the first
+	Sig    string    `json:"sig,omitempty"`         // ed25519 signature of the line without sig, in hex
+}
+
+// signed is the bytes e's signature is of.
+func (e AuditEntry) signed() []byte {
+	e.Sig = ""
+	data, _ := json.Marshal(e)
+	return data
+}
+
+// auditor opens the audit logs of a server's games. A nil *auditor opens
+// none.
+type auditor struct {
+	dir string
+	key ed25519.PrivateKey
+
+	mu    sync.Mutex
+	games int
+}
+
+func newAuditor(dir string, key ed25519.PrivateKey) (*auditor, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &auditor{dir: dir, key: key}, nil
+}
+
+// open starts the log of a new game, in a file of its own.
+func (a *auditor) open(game string) *auditLog {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	a.games++
+	name := fmt.Sprintf("%s-%d.log", time.Now().Format("20060102-150405"), a.games)
+	a.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(a.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o444)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit:", err)
+		return nil
+	}
+	return &auditLog{f: f, key: a.key, game: game}
+}
+
+// auditLog is one game's audit log. A nil *auditLog records nothing.
+type auditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	key  ed25519.PrivateKey
+	game string
+	seq  int
+	prev string
+}
+
+// record signs e and appends it to the log. A log that cannot be written
+// is reported and closed, rather than left with a gap.
+func (l *auditLog) record(e AuditEntry) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	l.seq++
+	e.Seq, e.Time, e.Game, e.Prev = l.seq, time.Now().UTC(), l.game, l.prev
+	e.Sig = hex.EncodeToString(ed25519.Sign(l.key, e.signed()))
+	line, _ := json.Marshal(e)
+	line = append(line, '\n')
+	if _, err := l.f.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "audit: %s: %v\n", l.f.Name(), err)
+		l.f.Close()
+		l.f = nil
+		return
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(line, []byte("\n")))
+	l.prev = hex.EncodeToString(sum[:])
+}
+
+func (l *auditLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+}
+
+// VerifyAudit reads an audit log and checks that every line is signed
+// with pub and follows the line before it.
+func VerifyAudit(r io.Reader, pub ed25519.PublicKey) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	prev := ""
+	in := bufio.NewScanner(r)
+	in.Buffer(nil, 1<<20)
+	for n := 1; in.Scan(); n++ {
+		var e AuditEntry
+		if err := json.Unmarshal(in.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("line %d: %v", n, err)
+		}
+		sig, err := hex.DecodeString(e.Sig)
+		if err != nil || !ed25519.Verify(pub, e.signed(), sig) {
+			return entries, fmt.Errorf("line %d: bad signature", n)
+		}
+		if e.Seq != n || e.Prev != prev {
+			return entries, fmt.Errorf("line %d: does not follow the line before; lines were removed, added or moved", n)
+		}
+		sum := sha256.Sum256(in.Bytes())
+		prev = hex.EncodeToString(sum[:])
+		entries = append(entries, e)
+	}
+	return entries, in.Err()
+}
+
+// auditedGame replays the entries of a verified log: the board size, the
+// moves left after undos, and the result.
+func auditedGame(entries []AuditEntry) (size int, moves []RecordedMove, result string, err error) {
+	for _, e := range entries {
+		switch e.Kind {
+		case "start":
+			size = e.Size
+		case "move", "pass":
+			move, err := parseMoveInput(e.Move, size)
+			if err != nil {
+				return 0, nil, "", fmt.Errorf("line %d: %v", e.Seq, err)
+			}
+			moves = append(moves, RecordedMove{Color: map[string]Stone{"black": Black, "white": White}[e.Color], Move: move})
+		case "undo":
+			if e.Number < 0 || e.Number > len(moves) {
+				return 0, nil, "", fmt.Errorf("line %d: undo to move %d of %d", e.Seq, e.Number, len(moves))
+			}
+			moves = moves[:e.Number]
+		case "end":
+			result = e.Result
+		}
+	}
+	if size == 0 {
+		return 0, nil, "", errors.New("the log has no start")
+	}
+	return size, moves, result, nil
+}
+
+// compareAudit lists how the game g differs from its audit log.
+func compareAudit(entries []AuditEntry, g *GameRecord) ([]string, error) {
+	size, moves, result, err := auditedGame(entries)
+	if err != nil {
+		return nil, err
+	}
+	if g.Size != size {
+		return []string{fmt.Sprintf("the game is %dx%d, the log %dx%d", g.Size, g.Size, size, size)}, nil
+	}
+	var diffs []string
+	for i := 0; i < min(len(moves), len(g.Moves)); i++ {
+		if g.Moves[i].Color != moves[i].Color || g.Moves[i].Move != moves[i].Move {
+			diffs = append(diffs, fmt.Sprintf("move %d is %s %s in the game, %s %s in the log", i+1,
+				colorKey(g.Moves[i].Color), formatMove(g.Moves[i].Move, size), colorKey(moves[i].Color), formatMove(moves[i].Move, size)))
+			break
+		}
+	}
+	if len(g.Moves) != len(moves) {
+		diffs = append(diffs, fmt.Sprintf("the game has %d moves, the log %d", len(g.Moves), len(moves)))
+	}
+	if result != "" && g.Result != result {
+		diffs = append(diffs, fmt.Sprintf("the result is %q in the game, %q in the log", g.Result, result))
+	}
+	return diffs, nil
+}
+
+// loadAuditKey reads the signing key kept in path, making one the first
+// time.
+func loadAuditKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(seed)+"\n"), 0o600); err != nil {
+			return nil, err
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s: not an audit key", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func auditPublicKey(key ed25519.PrivateKey) string {
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+func runAudit(args []string) error {
+	usage := errors.New("usage: audit key [-key FILE] | audit verify [-pub KEY] game.log [game.sgf]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("audit "+args[0], flag.ExitOnError)
+	keyFile := fs.String("key", dataPath("audit.key"), "the server's signing key")
+	pubHex := fs.String("pub", "", "the public key the server printed (default that of -key)")
+	switch args[0] {
+	case "key":
+		fs.Parse(args[1:])
+		key, err := loadAuditKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		fmt.Println(auditPublicKey(key))
+		return nil
+	case "verify":
+		fs.Parse(args[1:])
+		if fs.NArg() == 0 || fs.NArg() > 2 {
+			return usage
+		}
+		if *pubHex == "" {
+			if _, err := os.Stat(*keyFile); err != nil {
+				return fmt.Errorf("give the server's public key with -pub: %v", err)
+			}
+			key, err := loadAuditKey(*keyFile)
+			if err != nil {
+				return err
+			}
+			*pubHex = auditPublicKey(key)
+		}
+		pub, err := hex.DecodeString(*pubHex)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return errors.New("-pub is not an ed25519 public key in hex")
+		}
+		path := fs.Arg(0)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		entries, err := VerifyAudit(f, pub)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("%s: empty", path)
+		}
+		last := entries[len(entries)-1]
+		fmt.Printf("%s: %d lines, all signed and in order\n", path, len(entries))
+		if last.Kind != "end" && last.Kind != "adjourn" {
+			fmt.Printf("%s: the log stops before the game ended; it may have been cut short\n", path)
+		}
+		if fs.NArg() == 1 {
+			return nil
+		}
+		sgf := fs.Arg(1)
+		games, err := ReadSGFFile(sgf)
+		if err != nil {
+			return err
+		}
+		g, err := RecordFromSGF(games[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", sgf, err)
+		}
+		diffs, err := compareAudit(entries, g)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, d := range diffs {
+			fmt.Printf("%s: %s\n", sgf, d)
+		}
+		if len(diffs) > 0 {
+			return fmt.Errorf("%s does not match its audit log", sgf)
+		}
+		fmt.Printf("%s: matches the log\n", sgf)
+		return nil
+	}
+	return usage
+}
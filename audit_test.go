@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	key, err := loadAuditKey(filepath.Join(dir, "audit.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAuditor(filepath.Join(dir, "logs"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.names = [3]string{Black: "alice", White: "bob"}
+	g.audit = a.open("alice vs bob")
+	black, white, result := startNetGame(t, g)
+	send := func(c *netTestClient, in netInput, event string) {
+		t.Helper()
+		json.NewEncoder(c.conn).Encode(in)
+		c.next(t, event)
+	}
+	black.next(t, "turn")
+	send(black, netInput{Move: "2 2", Seq: 1}, "move")
+	white.next(t, "turn")
+	send(white, netInput{Move: "1 1", Seq: 2}, "move")
+	black.next(t, "turn")
+	send(black, netInput{Move: "undo"}, "undo_request")
+	send(white, netInput{Move: "accept"}, "undo")
+	black.next(t, "turn")
+	send(black, netInput{Move: "1 2", Seq: 1}, "move")
+	white.next(t, "turn")
+	send(white, netInput{Move: "pass", Seq: 2}, "move")
+	black.next(t, "turn")
+	send(black, netInput{Move: "pass", Seq: 3}, "end")
+	<-result
+
+	logs, _ := filepath.Glob(filepath.Join(dir, "logs", "*.log"))
+	if len(logs) != 1 {
+		t.Fatalf("logs: %v", logs)
+	}
+	data, err := os.ReadFile(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	entries, err := VerifyAudit(bytes.NewReader(data), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	if got := strings.Join(kinds, " "); got != "start move move undo move pass pass end" {
+		t.Fatalf("kinds = %s", got)
+	}
+	if entries[0].Black != "alice" || entries[len(entries)-1].Result != "B+24.5" {
+		t.Errorf("start %+v, end %+v", entries[0], entries[len(entries)-1])
+	}
+
+	// The game as played matches; an SGF changed afterwards does not.
+	game := &GameRecord{Size: 5, Result: "B+24.5", Moves: []RecordedMove{{Color: Black, Move: 7}, {Color: White, Move: PassMove}, {Color: Black, Move: PassMove}}}
+	if diffs, err := compareAudit(entries, game); err != nil || len(diffs) != 0 {
+		t.Errorf("matching game: %v, %v", diffs, err)
+	}
+	game.Moves[0].Move, game.Result = 8, "W+0.5"
+	if diffs, _ := compareAudit(entries, game); len(diffs) != 2 {
+		t.Errorf("changed game: %q", diffs)
+	}
+
+	// A line changed, removed or signed with another key is found.
+	lines := strings.SplitAfter(string(data), "\n")
+	for name, tampered := range map[string]string{
+		"changed": strings.Replace(string(data), `"move":"1 2"`, `"move":"1 3"`, 1),
+		"removed": strings.Join(append(lines[:3:3], lines[4:]...), ""),
+	} {
+		if _, err := VerifyAudit(strings.NewReader(tampered), pub); err == nil {
+			t.Errorf("%s line not found", name)
+		}
+	}
+	other, _ := loadAuditKey(filepath.Join(dir, "other.key"))
+	if _, err := VerifyAudit(bytes.NewReader(data), other.Public().(ed25519.PublicKey)); err == nil {
+		t.Error("verified with another key")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The game in progress is written to a recovery file after every move,
+// so that a crash, a closed terminal or Ctrl-C loses nothing: the next
+// game started offers to resume it. The file goes when a game ends
+// normally or is quit.
+
+func autosavePath() string {
+	return dataPath("autosave.sgf")
+}
+
+// autosaver keeps the recovery file. A nil autosaver does nothing, for
+// games that are not saved.
+type autosaver struct {
+	path   string
+	failed bool // the last write failed and was reported
+}
+
+// save replaces the recovery file with rec, atomically, and reports the
+// first of a run of failures through view.
+func (a *autosaver) save(rec *GameRecord, view gameView) {
+	if a == nil {
+		return
+	}
+	var buf bytes.Buffer
+	err := WriteSGF(&buf, []*SGFNode{rec.SGF()})
+	if err == nil {
+		err = saveFile(a.path, buf.Bytes())
+	}
+	if err != nil && !a.failed {
+		view.Message(fmt.Sprintf("Autosave failed: %v", err))
+	}
+	a.failed = err != nil
+}
+
+// clear removes the recovery file.
+func (a *autosaver) clear() {
+	if a != nil {
+		os.Remove(a.path)
+	}
+}
+
+// loadAutosave reads the game left in the recovery file at path, nil if
+// there is none.
+func loadAutosave(path string) (*GameRecord, error) {
+	games, err := ReadSGFFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(games) != 1 {
+		return nil, fmt.Errorf("%s: expected one game", path)
+	}
+	return RecordFromSGF(games[0])
+}
+
+// resumedBot is the colour the computer played in a saved game.
+func resumedBot(rec *GameRecord) Stone {
+	switch {
+	case rec.Black == "gogame":
+		return Black
+	case rec.White == "gogame":
+		return White
+	}
+	return Empty
+}
+
+// offerResume asks on out whether to resume the game left at path and
+// returns it if so. A game declined, or one that cannot be read, is
+// removed so it is not offered again.
+func offerResume(in io.Reader, out io.Writer, path string) (*GameRecord, error) {
+	rec, err := loadAutosave(path)
+	if rec == nil {
+		if err != nil {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+	against := "two players"
+	if bot := resumedBot(rec); bot != Empty {
+		against = fmt.Sprintf("the computer playing %s", colorName(bot))
+	}
+	fmt.Fprintf(out, "An interrupted %dx%d game against %s, %d moves in, was saved on %s.\nResume it? [y/N] ",
+		rec.Size, rec.Size, against, len(rec.Moves), rec.Date)
+	answer := strings.ToLower(strings.TrimSpace(readLine(in)))
+	if answer == "y" || answer == "yes" {
+		return rec, nil
+	}
+	os.Remove(path)
+	return nil, nil
+}
+
+// readLine reads up to a newline a byte at a time, so nothing after it
+// is taken from in before the game's own reader starts.
+func readLine(in io.Reader) string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n == 1 && b[0] != '\n' {
+			line = append(line, b[0])
+			continue
+		}
+		if n == 1 || err != nil {
+			return string(line)
+		}
+	}
+}
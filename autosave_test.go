@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAutosaveResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autosave.sgf")
+	a := &autosaver{path: path}
+	rec := &GameRecord{Size: 9, Komi: 6.5, White: "gogame", Date: "2026-10-15",
+		Moves: []RecordedMove{{Color: Black, Move: 40}, {Color: White, Move: 30}}}
+	a.save(rec, nil)
+
+	in := strings.NewReader("yes\n3 3\n")
+	var out strings.Builder
+	got, err := offerResume(in, &out, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || len(got.Moves) != 2 || resumedBot(got) != White {
+		t.Fatalf("resumed %+v", got)
+	}
+	if !strings.Contains(out.String(), "9x9 game against the computer playing White, 2 moves in") {
+		t.Errorf("offer %q", out.String())
+	}
+	if rest, _ := io.ReadAll(in); string(rest) != "3 3\n" {
+		t.Errorf("the answer took %q from the input", rest)
+	}
+
+	// Declining forgets the game.
+	if got, err := offerResume(strings.NewReader("\n"), io.Discard, path); got != nil || err != nil {
+		t.Errorf("declined game resumed: %v, %v", got, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("declined game still saved")
+	}
+	if got, err := offerResume(strings.NewReader("y\n"), io.Discard, path); got != nil || err != nil {
+		t.Errorf("nothing to resume: %v, %v", got, err)
+	}
+}
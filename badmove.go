@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["review"] = runReview
+}
+
+// MoveWarning is a classic beginner error found in a move.
+type MoveWarning struct {
+	Kind string // first-line, self-atari, own-eye or ignored-atari
+	Text string
+}
+
+// group returns the points of the group at row, col and its number of
+// liberties.
+func (b *Board) group(row, col int) (stones []int, liberties int) {
+	color := b.grid[row][col]
+	seen := map[int]bool{row*b.size + col: true}
+	libs := make(map[int]bool)
+	stack := []int{row*b.size + col}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		stones = append(stones, p)
+		for _, dir := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			r, c := p/b.size+dir[0], p%b.size+dir[1]
+			if !b.isInBounds(r, c) {
+				continue
+			}
+			q := r*b.size + c
+			switch b.grid[r][c] {
+			case Empty:
+				libs[q] = true
+			case color:
+				if !seen[q] {
+					seen[q] = true
+					stack = append(stack, q)
+				}
+			}
+		}
+	}
+	return stones, len(libs)
+}
+
+// inAtari returns one stone of each group of colour s that has a single
+// liberty.
+func (b *Board) inAtari(s Stone) []int {
+	seen := make(map[int]bool)
+	var groups []int
+	for p := 0; p < b.size*b.size; p++ {
+		if b.grid[p/b.size][p%b.size] != s || seen[p] {
+			continue
+		}
+		stones, libs := b.group(p/b.size, p%b.size)
+		for _, q := range stones {
+			seen[q] = true
+		}
+		if libs == 1 {
+			groups = append(groups, p)
+		}
+	}
+	return groups
+}
+
+// CheckMove looks for beginner errors in move, played by the side to move
+// in b. b is not changed. Illegal moves and passes get no warnings.
+func CheckMove(b *Board, move int) []MoveWarning {
+	if move == PassMove {
+		return nil
+	}
+	row, col := move/b.size, move%b.size
+	color := b.turn
+	after := b.Copy()
+	if !after.PlaceStone(row, col) {
+		return nil
+	}
+	captured := b.countStones(color.Opponent()) - after.countStones(color.Opponent())
+	where := userCoords.Move(move, b.size)
+
+	var warnings []MoveWarning
+	edge := row == 0 || col == 0 || row == b.size-1 || col == b.size-1
+	if edge && captured == 0 && b.countStones(Black)+b.countStones(White) < b.size*b.size/8 && !b.hasNeighbour(row, col) {
+		warnings = append(warnings, MoveWarning{"first-line", fmt.Sprintf(
+			"%s is on the first line early in the game; stones there make little territory, play on the third or fourth line instead", where)})
+	}
+	if b.isEye(row, col, color) {
+		warnings = append(warnings, MoveWarning{"own-eye", fmt.Sprintf(
+			"%s fills your own eye; a group needs two eyes to live", where)})
+	}
+	if stones, libs := after.group(row, col); libs == 1 && captured == 0 {
+		warnings = append(warnings, MoveWarning{"self-atari", fmt.Sprintf(
+			"%s leaves %d of your stones with one liberty; %s can capture them next move", where, len(stones), color.Opponent())})
+	}
+	if captured == 0 {
+		for _, p := range b.inAtari(color) {
+			// Extending a group in atari is a response; if it is still in
+			// atari afterwards the self-atari warning covers it.
+			stones, libs := after.group(p/b.size, p%b.size)
+			if libs == 1 && !contains(stones, move) {
+				warnings = append(warnings, MoveWarning{"ignored-atari", fmt.Sprintf(
+					"your stones at %s were in atari and still are; save them or capture something first", userCoords.Move(p, b.size))})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// hasNeighbour reports whether any stone lies within two points of row,
+// col, a sign the move is part of a local fight.
+func (b *Board) hasNeighbour(row, col int) bool {
+	for r := row - 2; r <= row+2; r++ {
+		for c := col - 2; c <= col+2; c++ {
+			if b.isInBounds(r, c) && b.grid[r][c] != Empty {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(points []int, p int) bool {
+	for _, q := range points {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// ReviewGame checks every move of g and returns the warnings by move
+// number, counting from 1.
+func ReviewGame(g *GameRecord) (map[int][]MoveWarning, error) {
+	report := make(map[int][]MoveWarning)
+	b := g.Start()
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		if w := CheckMove(b, m.Move); len(w) > 0 {
+			report[i+1] = w
+		}
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d (%s) is illegal", i+1, formatMove(m.Move, g.Size))
+		}
+	}
+	return report, nil
+}
+
+func runReview(args []string) error {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	game := fs.Int("game", 1, "game number within the file")
+	color := fs.String("color", "", "only review black's or white's moves")
+	annotate := fs.String("annotate", "", "analyse the game and write it with the moves that stand out marked to this SGF file")
+	playouts := fs.Int("playouts", 2000, "playouts per position for -annotate, and to answer wrong moves in problems")
+	useCache := fs.Bool("cache", true, "look positions up in the analysis cache and keep new analyses there")
+	problems := fs.String("problems", "", "with no file: the problem library to study, SGF files or directories separated by commas (default ~/.gogame/problems)")
+	newPerDay := fs.Int("new", 10, "with no file: new items to start a day")
+	limit := fs.Int("n", 0, "with no file: most items to study, 0 for all those due")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		// Study the quiz decks and problems due today; see srs.go.
+		var paths []string
+		if *problems != "" {
+			paths = strings.Split(*problems, ",")
+		}
+		return runStudySession(paths, *newPerDay, *limit, engineRefuter(*playouts, 2*time.Second))
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	var only Stone
+	switch *color {
+	case "":
+	case "black", "b":
+		only = Black
+	case "white", "w":
+		only = White
+	default:
+		return fmt.Errorf("invalid -color %q", *color)
+	}
+	report, err := ReviewGame(g)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if *annotate != "" {
+		cache, err := openAnalysisCache(*useCache)
+		if err != nil {
+			return err
+		}
+		marked, err := AnnotateGame(context.Background(), NewEngine(*playouts, g.Komi), cache, g)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := saveAnalysisCache(cache); err != nil {
+			return err
+		}
+		if err := WriteSGFFile(*annotate, []*SGFNode{g.SGF()}); err != nil {
+			return err
+		}
+		fmt.Printf("%d moves marked in %s\n", marked, *annotate)
+	}
+
+	writeReview(os.Stdout, g, report, only)
+	return nil
+}
+
+// writeReview lists the warnings of report and the annotated moves of g,
+// only those of colour only unless it is Empty.
+func writeReview(w io.Writer, g *GameRecord, report map[int][]MoveWarning, only Stone) {
+	count := 0
+	for i, m := range g.Moves {
+		if only != Empty && m.Color != only {
+			continue
+		}
+		move := moveMark(m, g.Size)
+		for _, warning := range report[i+1] {
+			fmt.Fprintf(w, "%3d %s %-6s %-13s %s\n", i+1, m.Color, move, warning.Kind, warning.Text)
+			count++
+		}
+		// Annotated moves are listed even without a warning.
+		if q, emphasis := m.Quality(); q != NoQuality && len(report[i+1]) == 0 {
+			fmt.Fprintf(w, "%3d %s %-6s %-13s %s\n", i+1, m.Color, move, qualityName(q, emphasis), strings.SplitN(m.Comment, "\n", 2)[0])
+		}
+	}
+	fmt.Fprintf(w, "%d warnings in %d moves\n", count, len(g.Moves))
+}
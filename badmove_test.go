@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestCheckMove(t *testing.T) {
+	// Black to move on 9x9:
+	//   0 1 2 3
+	// 0 + ● + ○
+	// 1 ● ● ○ ○
+	// 2 ○ ○ + +
+	b := NewBoard(9)
+	for _, p := range []int{1, 9, 10} {
+		b.grid[p/9][p%9] = Black
+	}
+	for _, p := range []int{3, 11, 12, 18, 19} {
+		b.grid[p/9][p%9] = White
+	}
+	kinds := func(move int) map[string]bool {
+		found := map[string]bool{}
+		for _, w := range CheckMove(b, move) {
+			found[w.Kind] = true
+		}
+		return found
+	}
+
+	if k := kinds(0); !k["own-eye"] {
+		t.Errorf("0 0: want own-eye, got %v", k)
+	}
+	if k := kinds(2); !k["self-atari"] {
+		t.Errorf("0 2: want self-atari, got %v", k)
+	}
+	if k := kinds(8 * 9); !k["first-line"] {
+		t.Errorf("8 0: want first-line, got %v", k)
+	}
+	if k := kinds(40); len(k) != 0 {
+		t.Errorf("4 4: want no warnings, got %v", k)
+	}
+
+	// Fill the eye so Black's group is in atari: tenuki ignores it.
+	b.grid[0][0] = Black
+	if k := kinds(40); !k["ignored-atari"] {
+		t.Errorf("4 4 with a group in atari: want ignored-atari, got %v", k)
+	}
+}
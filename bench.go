@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["bench"] = runBench
+}
+
+// benchSuite is a fixed workload: setup builds its inputs from a fixed
+// seed, outside the measurement, and returns the operation to time, which
+// is run ops times. Suites of the engine's work do it with e's settings.
+type benchSuite struct {
+	name  string
+	about string
+	ops   int
+	setup func(rng *rand.Rand, e *Engine) (op func(i int), err error)
+}
+
+// benchSuites are the standard workloads. Their inputs come from fixed
+// seeds so that runs on different builds measure the same work.
+var benchSuites = []benchSuite{
+	{"legal", "legal moves of 64 mid-game 19x19 positions", 2000, func(rng *rand.Rand, e *Engine) (func(int), error) {
+		positions, err := benchPositions(rng, 64, PositionOptions{Size: 19, Stones: 150})
+		if err != nil {
+			return nil, err
+		}
+		return func(i int) { legalMoves(positions[i%len(positions)]) }, nil
+	}},
+	{"playouts", "random playouts from the empty 9x9 board, a 4-stone handicap and a middle game", 10000, func(rng *rand.Rand, e *Engine) (func(int), error) {
+		mid, err := RandomPosition(PositionOptions{Size: 9, Stones: 30}, rng)
+		if err != nil {
+			return nil, err
+		}
+		handicap := NewBoard(9)
+		handicap.PlaceHandicap(4)
+		positions := []*Board{NewBoard(9), handicap, mid}
+		playoutRNG := rand.New(rand.NewSource(1))
+		return func(i int) { e.Playout.Play(positions[i%len(positions)].Copy(), playoutRNG) }, nil
+	}},
+	{"search", "searches of 1000 playouts from a 9x9 middle game, with the engine's threads", 10, func(rng *rand.Rand, e *Engine) (func(int), error) {
+		pos, err := RandomPosition(PositionOptions{Size: 9, Stones: 30}, rng)
+		if err != nil {
+			return nil, err
+		}
+		s := *e
+		s.Playouts = 1000
+		return func(int) { s.Analyze(context.Background(), pos) }, nil
+	}},
+	{"score", "area scores of 16 finished 19x19 boards", 20000, func(rng *rand.Rand, e *Engine) (func(int), error) {
+		positions, err := benchPositions(rng, 16, PositionOptions{Size: 19, Alive: true})
+		if err != nil {
+			return nil, err
+		}
+		return func(i int) { positions[i%len(positions)].AreaScore() }, nil
+	}},
+}
+
+func benchPositions(rng *rand.Rand, n int, opts PositionOptions) ([]*Board, error) {
+	positions := make([]*Board, n)
+	for i := range positions {
+		var err error
+		if positions[i], err = RandomPosition(opts, rng); err != nil {
+			return nil, err
+		}
+	}
+	return positions, nil
+}
+
+// legalMoves lists the points where the side to move may play.
+func legalMoves(b *Board) []int {
+	var moves []int
+	for p := 0; p < b.size*b.size; p++ {
+		if b.IsLegal(p) {
+			moves = append(moves, p)
+		}
+	}
+	return moves
+}
+
+// BenchResult is one suite's measurement.
+type BenchResult struct {
+	Suite       string  `json:"suite"`
+	Ops         int     `json:"ops"`
+	OpsPerSec   float64 `json:"ops_per_sec"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+}
+
+// runBenchSuite measures s with its operation count scaled by scale.
+func runBenchSuite(s benchSuite, scale float64, e *Engine) (BenchResult, error) {
+	op, err := s.setup(rand.New(rand.NewSource(1)), e)
+	if err != nil {
+		return BenchResult{}, fmt.Errorf("bench %s: %v", s.name, err)
+	}
+	ops := max(int(float64(s.ops)*scale), 1)
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < ops; i++ {
+		op(i)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return BenchResult{
+		Suite:       s.name,
+		Ops:         ops,
+		OpsPerSec:   float64(ops) / elapsed.Seconds(),
+		NsPerOp:     float64(elapsed.Nanoseconds()) / float64(ops),
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / float64(ops),
+		BytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / float64(ops),
+	}, nil
+}
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	only := fs.String("suite", "", "comma-separated suites to run (default all)")
+	scale := fs.Float64("scale", 1, "multiply each suite's operation count")
+	asJSON := fs.Bool("json", false, "print one JSON object per suite, to keep results over time")
+	list := fs.Bool("list", false, "list the suites")
+	spec := fs.String("engine", "", "engine options for the suites of the engine's work, e.g. mercy=20,playout-length=150")
+	pin := fs.String("pin", "", "pin the search workers to CPUs: compact, spread or a list such as 0-7 (see affinity.go)")
+	fs.Parse(args)
+	e, err := newEngineFromSpec(*spec, 7.5)
+	if err != nil {
+		return err
+	}
+	if e.PinWorker, err = workerPinner(*pin); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, s := range benchSuites {
+			fmt.Printf("%-10s %6d ops  %s\n", s.name, s.ops, s.about)
+		}
+		return nil
+	}
+	wanted := map[string]bool{}
+	for _, name := range splitNames(*only) {
+		wanted[name] = true
+	}
+	if !*asJSON {
+		fmt.Printf("%s %s/%s, %d CPUs\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+		fmt.Printf("%-10s %8s %14s %14s %12s %12s\n", "Suite", "Ops", "Ops/sec", "ns/op", "allocs/op", "B/op")
+	}
+	ran := 0
+	for _, s := range benchSuites {
+		if len(wanted) > 0 && !wanted[s.name] {
+			continue
+		}
+		ran++
+		r, err := runBenchSuite(s, *scale, e)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			if err := json.NewEncoder(os.Stdout).Encode(r); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%-10s %8d %14.1f %14.0f %12.1f %12.0f\n", r.Suite, r.Ops, r.OpsPerSec, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp)
+	}
+	if ran == 0 {
+		return fmt.Errorf("no suite named %s; bench -list shows them", strings.Join(splitNames(*only), ", "))
+	}
+	return nil
+}
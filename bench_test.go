@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestLegalMoves(t *testing.T) {
+	// White may not fill the corner point, which would be suicide.
+	b := NewBoard(3)
+	b.grid[0][1], b.grid[1][0] = Black, Black
+	b.turn = White
+	if got := legalMoves(b); len(got) != 6 || got[0] != 2 {
+		t.Errorf("legal moves %v", got)
+	}
+}
+
+func TestBenchSuites(t *testing.T) {
+	for _, s := range benchSuites {
+		r, err := runBenchSuite(s, 0.001, NewEngine(1, 7.5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Ops < 1 || r.NsPerOp <= 0 {
+			t.Errorf("%s: %+v", s.name, r)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+// The board and rules. With legal.go, scoring.go, events.go, regions.go
+// and the engine files (engine.go, eval.go, arena.go, zobrist.go) this is
+// the core that wasm/build.sh compiles for the browser, so none of them
+// may use the terminal or files.
+
+import "fmt"
+
+type Stone int
+
+const (
+	Empty Stone = iota
+	Black
+	White
+)
+
+func (s Stone) String() string {
+	switch s {
+	case Black:
+		return "●"
+	case White:
+		return "○"
+	default:
+		return "+"
+	}
+}
+
+// MaxBoardSize is the largest board SGF coordinates can describe.
+const MaxBoardSize = 52
+
+type Board struct {
+	size  int
+	grid  [][]Stone
+	turn  Stone
+	passes int
+	legal *legalCache // see legal.go; nil until IsLegal is first asked
+}
+
+// BoardView is a position to look at but not change, as the game's board
+// is handed to engines, renderers and plugins. *Board implements it; a
+// consumer that needs to play moves works on a Copy.
+type BoardView interface {
+	Size() int
+	At(row, col int) Stone
+	Turn() Stone
+	IsLegal(move int) bool
+	IsGameOver() bool
+	Hash() uint64
+	Winner(komi float64) (Stone, float64)
+	Copy() *Board
+}
+
+var _ BoardView = (*Board)(nil)
+
+// inBounds reports whether row, col is a point of v.
+func inBounds(v BoardView, row, col int) bool {
+	return row >= 0 && row < v.Size() && col >= 0 && col < v.Size()
+}
+
+func NewBoard(size int) *Board {
+	grid := make([][]Stone, size)
+	for i := range grid {
+		grid[i] = make([]Stone, size)
+	}
+	return &Board{
+		size: size,
+		grid: grid,
+		turn: Black,
+	}
+}
+
+func (b *Board) Size() int {
+	return b.size
+}
+
+func (b *Board) At(row, col int) Stone {
+	return b.grid[row][col]
+}
+
+func (b *Board) Turn() Stone {
+	return b.turn
+}
+
+// Copy returns an independent copy of the board, for search and analysis.
+func (b *Board) Copy() *Board {
+	c := NewBoard(b.size)
+	for i := range b.grid {
+		copy(c.grid[i], b.grid[i])
+	}
+	c.turn = b.turn
+	c.passes = b.passes
+	c.legal = b.legal.copy()
+	return c
+}
+
+func (b *Board) Display() {
+	fmt.Println()
+	// Column headers
+	fmt.Print("  ")
+	for i := 0; i < b.size; i++ {
+		fmt.Printf("%2d", i)
+	}
+	fmt.Println()
+	
+	// Board with row headers
+	for i := 0; i < b.size; i++ {
+		fmt.Printf("%2d", i)
+		for j := 0; j < b.size; j++ {
+			fmt.Printf(" %s", b.grid[i][j])
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\nCurrent turn: %s\n", b.turn)
+}
+
+func (b *Board) IsValidMove(row, col int) bool {
+	if row < 0 || row >= b.size || col < 0 || col >= b.size {
+		return false
+	}
+	return b.grid[row][col] == Empty
+}
+
+func (b *Board) PlaceStone(row, col int) bool {
+	if !b.IsValidMove(row, col) {
+		return false
+	}
+	
+	b.grid[row][col] = b.turn
+	
+	// Remove captured opponent stones
+	opponent := White
+	if b.turn == White {
+		opponent = Black
+	}
+	
+	// Check all adjacent positions for captures
+	var captured []int
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for _, dir := range directions {
+		newRow, newCol := row+dir[0], col+dir[1]
+		if b.isInBounds(newRow, newCol) && b.grid[newRow][newCol] == opponent {
+			if !b.hasLiberty(newRow, newCol) {
+				if b.legal != nil {
+					captured = append(captured, b.legal.stones(b, newRow*b.size+newCol)...)
+				}
+				b.removeGroup(newRow, newCol)
+			}
+		}
+	}
+	
+	// Check if the placed stone group has liberties (suicide rule)
+	if !b.hasLiberty(row, col) {
+		b.grid[row][col] = Empty // Remove the stone
+		return false
+	}
+	
+	if b.legal != nil {
+		b.legal.moved(b, row*b.size+col, captured)
+	}
+	b.passes = 0
+	b.nextTurn()
+	return true
+}
+
+func (b *Board) isInBounds(row, col int) bool {
+	return row >= 0 && row < b.size && col >= 0 && col < b.size
+}
+
+func (b *Board) hasLiberties(row, col int, visited map[[2]int]bool) bool {
+	if visited[[2]int{row, col}] {
+		return false
+	}
+	visited[[2]int{row, col}] = true
+	
+	stone := b.grid[row][col]
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	
+	for _, dir := range directions {
+		newRow, newCol := row+dir[0], col+dir[1]
+		if !b.isInBounds(newRow, newCol) {
+			continue
+		}
+		
+		if b.grid[newRow][newCol] == Empty {
+			return true // Found a liberty
+		}
+		
+		if b.grid[newRow][newCol] == stone {
+			if b.hasLiberties(newRow, newCol, visited) {
+				return true
+			}
+		}
+	}
+	
+	return false
+}
+
+func (b *Board) removeGroup(row, col int) {
+	stone := b.grid[row][col]
+	if stone == Empty {
+		return
+	}
+	
+	b.grid[row][col] = Empty
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	
+	for _, dir := range directions {
+		newRow, newCol := row+dir[0], col+dir[1]
+		if b.isInBounds(newRow, newCol) && b.grid[newRow][newCol] == stone {
+			b.removeGroup(newRow, newCol)
+		}
+	}
+}
+
+func (b *Board) Pass() {
+	b.passes++
+	b.nextTurn()
+}
+
+func (b *Board) nextTurn() {
+	if b.turn == Black {
+		b.turn = White
+	} else {
+		b.turn = Black
+	}
+}
+
+func (b *Board) IsGameOver() bool {
+	return b.passes >= 2
+}
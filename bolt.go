@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// The bolt: store keeps the game database in a BoltDB file, the format of
+// go.etcd.io/bbolt, so that bolt tools and Go programs using bbolt can
+// open it. gogame takes no packages from outside the standard library, so
+// it reads and writes the format itself: it reads any Bolt file, and on
+// Close writes the whole file afresh, as the JSON store does, rather than
+// updating pages in place. The games are in the bucket "games", each under
+// its number in the order they were added (8 bytes, big endian) as a
+// DBGame in JSON, and the bucket "meta" holds the schema "version". Other
+// buckets in the file are kept as they are.
+//
+// A Bolt file is a run of pages of the size its meta pages give. Pages 0
+// and 1 are meta pages, of which the valid one with the higher transaction
+// id is current; it names the freelist page and the root page of the
+// top-level buckets. A bucket is a B+tree: leaf pages hold keys and values,
+// and branch pages the first key and the page of each child. A bucket's
+// value in its parent is its root page and sequence, followed, for a small
+// bucket, by its one leaf page inline. Integers are little endian, as
+// bbolt writes them on amd64 and arm64.
+
+const (
+	boltMagic    = 0xED0CDAED
+	boltVersion  = 2
+	boltPageSize = 4096
+
+	boltBranchPage   = 0x01
+	boltLeafPage     = 0x02
+	boltMetaPage     = 0x04
+	boltFreelistPage = 0x10
+	boltBucketLeaf   = 0x01 // the flag of a leaf element that is a bucket
+
+	boltPageHeader   = 16 // id, flags, count, overflow
+	boltElement      = 16 // a leaf or branch element
+	boltBucketHeader = 16 // root page, sequence
+	boltMetaSum      = 56 // the bytes of a meta its checksum covers
+	boltMaxDepth     = 64 // deeper trees are taken for loops in a bad file
+)
+
+var errBoltCorrupt = errors.New("bolt: corrupt file")
+
+// boltBucket is a bucket's keys and values and the buckets within it.
+type boltBucket struct {
+	sequence uint64
+	pairs    []boltPair // in key order
+	buckets  map[string]*boltBucket
+}
+
+type boltPair struct {
+	key, value []byte
+}
+
+func newBoltBucket() *boltBucket {
+	return &boltBucket{buckets: make(map[string]*boltBucket)}
+}
+
+// boltFile is a Bolt file being read.
+type boltFile struct {
+	data     []byte
+	pageSize int
+}
+
+// readBolt reads the top-level buckets of a Bolt file.
+func readBolt(data []byte) (*boltBucket, error) {
+	f := &boltFile{data: data}
+	var root uint64
+	var txid uint64
+	found := false
+	// The page size is in the meta pages, so the second is looked for
+	// after the first's page size, or after the usual ones if the first
+	// is damaged.
+	offsets := []int{0, boltPageSize, 512, 1024, 2048, 8192, 16384, 32768, 65536}
+	if size, _, _, ok := boltMeta(data, 0); ok {
+		offsets = []int{0, size}
+	}
+	for _, off := range offsets {
+		size, r, t, ok := boltMeta(data, off)
+		if ok && (!found || t > txid) && (off == 0 || off == size) {
+			f.pageSize, root, txid, found = size, r, t, true
+		}
+	}
+	if !found {
+		return nil, errors.New("bolt: not a Bolt file, or both meta pages are damaged")
+	}
+	b := newBoltBucket()
+	if err := f.readTree(b, f.page(root), 0); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// boltMeta reads the meta page at off, if it is one.
+func boltMeta(data []byte, off int) (pageSize int, root, txid uint64, ok bool) {
+	if off+boltPageHeader+boltMetaSum+8 > len(data) {
+		return 0, 0, 0, false
+	}
+	m := data[off+boltPageHeader:]
+	h := fnv.New64a()
+	h.Write(m[:boltMetaSum])
+	le := binary.LittleEndian
+	if le.Uint16(data[off+8:]) != boltMetaPage || le.Uint32(m) != boltMagic || le.Uint32(m[4:]) != boltVersion ||
+		le.Uint64(m[boltMetaSum:]) != h.Sum64() {
+		return 0, 0, 0, false
+	}
+	pageSize = int(le.Uint32(m[8:]))
+	if pageSize < 512 || pageSize > 1<<20 {
+		return 0, 0, 0, false
+	}
+	return pageSize, le.Uint64(m[16:]), le.Uint64(m[48:]), true
+}
+
+// page is page id with its overflow pages, or nil if it is not in the file.
+func (f *boltFile) page(id uint64) []byte {
+	if id >= uint64(len(f.data)/f.pageSize) {
+		return nil
+	}
+	p := f.data[id*uint64(f.pageSize):]
+	n := (uint64(binary.LittleEndian.Uint32(p[12:])) + 1) * uint64(f.pageSize)
+	if n > uint64(len(p)) {
+		return nil
+	}
+	return p[:n]
+}
+
+// readTree adds the keys, values and buckets of the tree at page p to b.
+func (f *boltFile) readTree(b *boltBucket, p []byte, depth int) error {
+	le := binary.LittleEndian
+	if len(p) < boltPageHeader || depth > boltMaxDepth {
+		return errBoltCorrupt
+	}
+	flags, count := le.Uint16(p[8:]), int(le.Uint16(p[10:]))
+	if boltPageHeader+count*boltElement > len(p) {
+		return errBoltCorrupt
+	}
+	for i := 0; i < count; i++ {
+		at := boltPageHeader + i*boltElement
+		e := p[at:]
+		switch flags {
+		case boltLeafPage:
+			start := at + int(le.Uint32(e[4:]))
+			ksize, vsize := int(le.Uint32(e[8:])), int(le.Uint32(e[12:]))
+			if start+ksize+vsize > len(p) || start+ksize+vsize < start {
+				return errBoltCorrupt
+			}
+			key, value := p[start:start+ksize], p[start+ksize:start+ksize+vsize]
+			if le.Uint32(e)&boltBucketLeaf == 0 {
+				b.pairs = append(b.pairs, boltPair{slices.Clone(key), slices.Clone(value)})
+				continue
+			}
+			if len(value) < boltBucketHeader {
+				return errBoltCorrupt
+			}
+			sub := newBoltBucket()
+			sub.sequence = le.Uint64(value[8:])
+			root := f.page(le.Uint64(value))
+			if le.Uint64(value) == 0 {
+				root = value[boltBucketHeader:] // an inline bucket
+			}
+			if err := f.readTree(sub, root, depth+1); err != nil {
+				return err
+			}
+			b.buckets[string(key)] = sub
+		case boltBranchPage:
+			start, ksize := at+int(le.Uint32(e)), int(le.Uint32(e[4:]))
+			if start+ksize > len(p) || start+ksize < start {
+				return errBoltCorrupt
+			}
+			if err := f.readTree(b, f.page(le.Uint64(e[8:])), depth+1); err != nil {
+				return err
+			}
+		default:
+			return errBoltCorrupt
+		}
+	}
+	return nil
+}
+
+// boltWriter lays out a Bolt file.
+type boltWriter struct {
+	buf []byte
+}
+
+// writeBolt makes a Bolt file of the top-level buckets of root.
+func writeBolt(root *boltBucket) []byte {
+	le := binary.LittleEndian
+	w := &boltWriter{buf: make([]byte, 3*boltPageSize)} // two metas and an empty freelist
+	rootPage := w.bucket(root)
+	le.PutUint64(w.buf[2*boltPageSize:], 2)
+	le.PutUint16(w.buf[2*boltPageSize+8:], boltFreelistPage)
+	for i := range 2 {
+		p := w.buf[i*boltPageSize:]
+		le.PutUint64(p, uint64(i))
+		le.PutUint16(p[8:], boltMetaPage)
+		m := p[boltPageHeader:]
+		le.PutUint32(m, boltMagic)
+		le.PutUint32(m[4:], boltVersion)
+		le.PutUint32(m[8:], boltPageSize)
+		le.PutUint64(m[16:], rootPage)
+		le.PutUint64(m[32:], 2) // the freelist
+		le.PutUint64(m[40:], uint64(len(w.buf)/boltPageSize))
+		le.PutUint64(m[48:], uint64(i)) // the transaction id; the higher meta is current
+		h := fnv.New64a()
+		h.Write(m[:boltMetaSum])
+		le.PutUint64(m[boltMetaSum:], h.Sum64())
+	}
+	return w.buf
+}
+
+// boltElementPair is a leaf element to write: a key and value, or a
+// bucket's name and header.
+type boltElementPair struct {
+	key, value []byte
+	flags      uint32
+}
+
+// bucket writes b's tree and returns its root page.
+func (w *boltWriter) bucket(b *boltBucket) uint64 {
+	level := w.pages(boltLeafPage, w.elements(b))
+	for len(level) > 1 {
+		level = w.pages(boltBranchPage, level)
+	}
+	return binary.LittleEndian.Uint64(level[0].value)
+}
+
+// elements is b's keys and values and buckets as leaf elements, in key
+// order. As bbolt does, a bucket with no buckets in it whose leaf would
+// fill at most a quarter of a page is kept inline.
+func (w *boltWriter) elements(b *boltBucket) []boltElementPair {
+	var elems []boltElementPair
+	for _, kv := range b.pairs {
+		elems = append(elems, boltElementPair{key: kv.key, value: kv.value})
+	}
+	for name, sub := range b.buckets {
+		header := make([]byte, boltBucketHeader)
+		binary.LittleEndian.PutUint64(header[8:], sub.sequence)
+		size := boltPageHeader
+		for _, kv := range sub.pairs {
+			size += boltElement + len(kv.key) + len(kv.value)
+		}
+		if len(sub.buckets) == 0 && size <= boltPageSize/4 {
+			inline := &boltWriter{}
+			inline.pages(boltLeafPage, inline.elements(sub))
+			header = append(header, inline.buf[:size]...)
+		} else {
+			binary.LittleEndian.PutUint64(header, w.bucket(sub))
+		}
+		elems = append(elems, boltElementPair{key: []byte(name), value: header, flags: boltBucketLeaf})
+	}
+	slices.SortFunc(elems, func(a, b boltElementPair) int { return bytes.Compare(a.key, b.key) })
+	return elems
+}
+
+// pages writes elems to as few pages of kind flags as hold them, an
+// element too big for one page having overflow pages, and returns the
+// branch elements for the pages: each one's first key and its page.
+func (w *boltWriter) pages(flags uint16, elems []boltElementPair) []boltElementPair {
+	le := binary.LittleEndian
+	var up []boltElementPair
+	for len(up) == 0 || len(elems) > 0 {
+		n, size := 0, boltPageHeader
+		for ; n < len(elems) && n < 0xFFFF; n++ {
+			s := boltElement + len(elems[n].key)
+			if flags == boltLeafPage {
+				s += len(elems[n].value)
+			}
+			if n > 0 && size+s > boltPageSize {
+				break
+			}
+			size += s
+		}
+		pages := max(1, (size+boltPageSize-1)/boltPageSize)
+		id := uint64(len(w.buf) / boltPageSize)
+		w.buf = append(w.buf, make([]byte, pages*boltPageSize)...)
+		p := w.buf[id*boltPageSize:]
+		le.PutUint64(p, id)
+		le.PutUint16(p[8:], flags)
+		le.PutUint16(p[10:], uint16(n))
+		le.PutUint32(p[12:], uint32(pages-1))
+		data := boltPageHeader + n*boltElement
+		for i, e := range elems[:n] {
+			at := boltPageHeader + i*boltElement
+			if flags == boltLeafPage {
+				le.PutUint32(p[at:], e.flags)
+				le.PutUint32(p[at+4:], uint32(data-at))
+				le.PutUint32(p[at+8:], uint32(len(e.key)))
+				le.PutUint32(p[at+12:], uint32(len(e.value)))
+				data += copy(p[data:], e.key)
+				data += copy(p[data:], e.value)
+			} else {
+				le.PutUint32(p[at:], uint32(data-at))
+				le.PutUint32(p[at+4:], uint32(len(e.key)))
+				le.PutUint64(p[at+8:], le.Uint64(e.value))
+				data += copy(p[data:], e.key)
+			}
+		}
+		var first []byte
+		if n > 0 {
+			first = elems[0].key
+		}
+		up = append(up, boltElementPair{key: first, value: le.AppendUint64(nil, id)})
+		elems = elems[n:]
+	}
+	return up
+}
+
+// boltStore is the game database in a Bolt file. Like a JSON file's, the
+// games are read when it is opened and written on Close.
+type boltStore struct {
+	*GameDB
+	other *boltBucket // the file's buckets other than games and meta
+}
+
+// openBoltStore opens the Bolt file at path; a missing file is an empty
+// database.
+func openBoltStore(path string) (*boltStore, error) {
+	s := &boltStore{GameDB: newMemoryDB(), other: newBoltBucket()}
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, err := readBolt(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s.Version = 0
+	if meta := root.buckets["meta"]; meta != nil {
+		for _, kv := range meta.pairs {
+			if string(kv.key) == "version" {
+				if s.Version, err = strconv.Atoi(string(kv.value)); err != nil {
+					return nil, fmt.Errorf("%s: bad schema version %q", path, kv.value)
+				}
+			}
+		}
+	}
+	if games := root.buckets["games"]; games != nil {
+		for _, kv := range games.pairs {
+			var g DBGame
+			if err := json.Unmarshal(kv.value, &g); err != nil {
+				return nil, fmt.Errorf("%s: game %x: %v", path, kv.key, err)
+			}
+			s.Entries = append(s.Entries, g)
+		}
+	}
+	delete(root.buckets, "meta")
+	delete(root.buckets, "games")
+	s.other = root
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	s.index()
+	return s, nil
+}
+
+// Save writes the file afresh.
+func (s *boltStore) Save() error {
+	s.Version = gameDBVersion
+	root := &boltBucket{buckets: maps.Clone(s.other.buckets), pairs: s.other.pairs, sequence: s.other.sequence}
+	meta := newBoltBucket()
+	meta.pairs = []boltPair{{[]byte("version"), []byte(strconv.Itoa(gameDBVersion))}}
+	games := newBoltBucket()
+	for i, g := range s.Entries {
+		value, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		games.pairs = append(games.pairs, boltPair{binary.BigEndian.AppendUint64(nil, uint64(i+1)), value})
+	}
+	games.sequence = uint64(len(s.Entries))
+	root.buckets["meta"], root.buckets["games"] = meta, games
+	if err := saveFile(s.path, writeBolt(root)); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close saves the games added since the file was read.
+func (s *boltStore) Close() error {
+	if !s.dirty {
+		return nil
+	}
+	return s.Save()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// boltDump is b's contents as text, buckets in name order.
+func boltDump(b *boltBucket) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "seq %d\n", b.sequence)
+	for _, kv := range b.pairs {
+		fmt.Fprintf(&s, "%x=%x\n", kv.key, kv.value)
+	}
+	for _, name := range slices.Sorted(maps.Keys(b.buckets)) {
+		fmt.Fprintf(&s, "%s {\n%s}\n", name, boltDump(b.buckets[name]))
+	}
+	return s.String()
+}
+
+func TestBoltFile(t *testing.T) {
+	// Enough keys for branch pages, a value bigger than a page, a bucket
+	// in a bucket, and buckets small enough to be inline.
+	root := newBoltBucket()
+	big := newBoltBucket()
+	for i := range 2000 {
+		big.pairs = append(big.pairs, boltPair{binary.BigEndian.AppendUint64(nil, uint64(i)), []byte(fmt.Sprint("value ", i))})
+	}
+	big.pairs = append(big.pairs, boltPair{[]byte("zz"), bytes.Repeat([]byte("x"), 3*boltPageSize)})
+	big.sequence = 2000
+	small := newBoltBucket()
+	small.pairs = []boltPair{{[]byte("a"), []byte("1")}, {[]byte("b"), nil}}
+	small.buckets["inner"] = newBoltBucket()
+	root.buckets["big"], root.buckets["small"] = big, small
+
+	data := writeBolt(root)
+	got, err := readBolt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boltDump(got) != boltDump(root) {
+		t.Fatal("read back a different file")
+	}
+	if got.buckets["big"].sequence != 2000 || got.buckets["small"].buckets["inner"] == nil {
+		t.Errorf("buckets %+v", got.buckets)
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":     nil,
+		"JSON":      []byte(`{"version": 2, "games": []}`),
+		"truncated": data[:5*boltPageSize],
+	} {
+		if _, err := readBolt(bad); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	// A damaged meta page leaves the other one.
+	damaged := bytes.Clone(data)
+	damaged[boltPageSize+boltPageHeader+20]++
+	if got, err := readBolt(damaged); err != nil || len(got.buckets) != 2 {
+		t.Errorf("with meta 1 damaged: %v", err)
+	}
+	binary.LittleEndian.PutUint64(damaged[boltPageHeader+16:], 1) // root: meta 1
+	if _, err := readBolt(damaged); err == nil {
+		t.Error("read both metas damaged")
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.db")
+	db, err := openBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 300 {
+		g := &GameRecord{Size: 19, Black: "alice", Moves: []RecordedMove{{Color: Black, Move: i}, {Color: White, Move: PassMove}}}
+		if ok, err := db.Add(fmt.Sprint("g", i), "test", g); !ok || err != nil {
+			t.Fatalf("add %d: %v, %v", i, ok, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another program's bucket is kept when gogame rewrites the file.
+	data, _ := os.ReadFile(path)
+	root, err := readBolt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.buckets["games"].sequence != 300 || len(root.buckets["meta"].pairs) != 1 {
+		t.Fatalf("buckets %+v", root.buckets)
+	}
+	notes := newBoltBucket()
+	notes.pairs = []boltPair{{[]byte("k"), []byte("v")}}
+	root.buckets["notes"] = notes
+	os.WriteFile(path, writeBolt(root), 0o644)
+
+	db, err = openBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	games, _ := db.Games()
+	if len(games) != 300 || games[299].ID != "g299" || db.String() != path {
+		t.Fatalf("%s: %d games", db, len(games))
+	}
+	if ok, _ := db.Add("again", "test", &GameRecord{Size: 19, Black: "alice", Moves: []RecordedMove{{Color: Black, Move: 0}, {Color: White, Move: PassMove}}}); ok {
+		t.Error("added a game already in the file")
+	}
+	db.Add("new", "test", &GameRecord{Size: 9})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if root, err = readBolt(data); err != nil || root.buckets["notes"] == nil || len(root.buckets["games"].pairs) != 301 {
+		t.Errorf("after adding: %v, %+v", err, root)
+	}
+
+	// A file from a newer gogame is refused, and one that is not a Bolt
+	// file is named in the error.
+	meta := newBoltBucket()
+	meta.pairs = []boltPair{{[]byte("version"), []byte("99")}}
+	root.buckets["meta"] = meta
+	os.WriteFile(path, writeBolt(root), 0o644)
+	if _, err := openBoltStore(path); err == nil || !strings.Contains(err.Error(), "newer gogame") {
+		t.Errorf("a newer file: %v", err)
+	}
+	os.WriteFile(path, []byte("{}"), 0o644)
+	if _, err := openBoltStore(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("a JSON file: %v", err)
+	}
+}
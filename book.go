@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["book"] = runBook
+}
+
+// BookMove is a move the opening book plays from a position, with the
+// games behind it and how many of them the side playing it won and lost.
+// Games also counts the games that were not decided.
+type BookMove struct {
+	Move   int
+	Games  int
+	Wins   int
+	Losses int
+}
+
+// WinRate is the share of decided games won by the side playing the move.
+func (m BookMove) WinRate() float64 {
+	if m.Wins+m.Losses == 0 {
+		return 0.5
+	}
+	return float64(m.Wins) / float64(m.Wins+m.Losses)
+}
+
+// OpeningBook maps positions, by Zobrist hash, to the moves to play
+// there. The computer plays from it while the game is still in the book.
+type OpeningBook struct {
+	Size      int
+	positions map[uint64][]BookMove
+	rng       *rand.Rand
+}
+
+// BookOptions prune the opening tree when building a book.
+type BookOptions struct {
+	Depth      int     // opening moves considered
+	MinGames   int     // games a move must have been played in
+	MinWinRate float64 // share of decided games the mover must have won
+}
+
+func newOpeningBook(size int) *OpeningBook {
+	return &OpeningBook{Size: size, positions: make(map[uint64][]BookMove), rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// BuildBook builds a book for size from games. Moves below the
+// thresholds are pruned, and so is every position that the remaining
+// moves no longer reach from the empty board.
+func BuildBook(games []DBGame, size int, opts BookOptions) (*OpeningBook, error) {
+	e, err := BuildExplorer(games, size, opts.Depth)
+	if err != nil {
+		return nil, err
+	}
+	book := newOpeningBook(size)
+	seen := map[uint64]bool{}
+	queue := []*Board{NewBoard(size)}
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		h := b.Hash()
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		var moves []BookMove
+		for _, s := range e.Next(b) {
+			if s.Move == PassMove || s.Games < opts.MinGames || s.WinRate(b.turn) < opts.MinWinRate {
+				continue
+			}
+			next := b.Copy()
+			if !next.Play(s.Move) {
+				continue
+			}
+			m := BookMove{Move: s.Move, Games: s.Games, Wins: s.BlackWins, Losses: s.WhiteWins}
+			if b.turn == White {
+				m.Wins, m.Losses = m.Losses, m.Wins
+			}
+			moves = append(moves, m)
+			queue = append(queue, next)
+		}
+		if len(moves) > 0 {
+			book.positions[h] = moves
+		}
+	}
+	return book, nil
+}
+
+// Len is the number of positions in the book.
+func (book *OpeningBook) Len() int { return len(book.positions) }
+
+// Lookup lists the book moves from b, most played first.
+func (book *OpeningBook) Lookup(b BoardView) []BookMove {
+	if book == nil || b.Size() != book.Size {
+		return nil
+	}
+	return book.positions[b.Hash()]
+}
+
+// Pick chooses a book move from b at random, weighted by the games it won
+// times its win rate, so that among moves as good the most played are
+// picked most and a move that keeps losing falls away, and by one more so
+// that it is still tried now and then; ok is false once the game has left
+// the book.
+func (book *OpeningBook) Pick(b BoardView) (move int, ok bool) {
+	moves := book.Lookup(b)
+	if len(moves) == 0 {
+		return PassMove, false
+	}
+	weight := func(m BookMove) float64 { return float64(m.Games)*m.WinRate()*m.WinRate() + 1 }
+	total := 0.0
+	for _, m := range moves {
+		total += weight(m)
+	}
+	n := book.rng.Float64() * total
+	for _, m := range moves {
+		if n -= weight(m); n < 0 {
+			return m.Move, true
+		}
+	}
+	return moves[len(moves)-1].Move, true
+}
+
+// Learn adds the result of the finished game g to the book moves played in
+// it, from wherever the game was in the book, so that the lines the
+// computer loses are picked less and less. It returns the number of moves
+// updated; a game without a winner, or on another board size, teaches
+// nothing.
+func (book *OpeningBook) Learn(g *GameRecord) int {
+	winner := resultWinner(g.Result)
+	if book == nil || g.Size != book.Size || winner == Empty {
+		return 0
+	}
+	updated := 0
+	b := g.Start()
+	for _, m := range g.Moves {
+		b.turn = m.Color
+		moves := book.positions[b.Hash()]
+		if i := slices.IndexFunc(moves, func(bm BookMove) bool { return bm.Move == m.Move }); i >= 0 {
+			bm := &moves[i]
+			if m.Color == winner {
+				bm.Wins++
+			} else {
+				bm.Losses++
+			}
+			bm.Games++
+			sort.SliceStable(moves, func(i, j int) bool { return moves[i].Games > moves[j].Games })
+			updated++
+		}
+		if !b.Play(m.Move) {
+			break
+		}
+	}
+	return updated
+}
+
+// EncodeBook serialises the book in the codec's book format (see
+// codec.go), positions in hash order so equal books encode the same.
+func EncodeBook(book *OpeningBook) []byte {
+	hashes := make([]uint64, 0, len(book.positions))
+	for h := range book.positions {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	out := append(codecHeader(codecBook), byte(book.Size))
+	out = binary.AppendUvarint(out, uint64(len(hashes)))
+	for _, h := range hashes {
+		out = binary.LittleEndian.AppendUint64(out, h)
+		moves := book.positions[h]
+		out = binary.AppendUvarint(out, uint64(len(moves)))
+		for _, m := range moves {
+			out = binary.AppendUvarint(out, uint64(m.Move))
+			out = binary.AppendUvarint(out, uint64(m.Games))
+			out = binary.AppendUvarint(out, uint64(m.Wins))
+			out = binary.AppendUvarint(out, uint64(m.Losses))
+		}
+	}
+	return out
+}
+
+// DecodeBook reads a book that EncodeBook wrote, or one in the older
+// format with win rates, whose decided games are taken to be all of them.
+func DecodeBook(data []byte) (*OpeningBook, error) {
+	kind := byte(codecBook)
+	if len(data) > 3 && data[3] == codecBookRates {
+		kind = codecBookRates
+	}
+	data, err := checkCodecHeader(data, kind)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 {
+		return nil, errCodecTruncated
+	}
+	if size := int(data[0]); size < 1 || size > MaxBoardSize {
+		return nil, fmt.Errorf("codec: invalid board size %d", size)
+	}
+	book := newOpeningBook(int(data[0]))
+	data = data[1:]
+	uvarint := func() (uint64, error) {
+		v, k := binary.Uvarint(data)
+		if k <= 0 {
+			return 0, errCodecTruncated
+		}
+		data = data[k:]
+		return v, nil
+	}
+	count, err := uvarint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 8 {
+			return nil, errCodecTruncated
+		}
+		h := binary.LittleEndian.Uint64(data)
+		data = data[8:]
+		n, err := uvarint()
+		if err != nil {
+			return nil, err
+		}
+		var moves []BookMove
+		for j := uint64(0); j < n; j++ {
+			v := make([]uint64, 4)
+			if kind == codecBookRates {
+				v = v[:3]
+			}
+			for k := range v {
+				if v[k], err = uvarint(); err != nil {
+					return nil, err
+				}
+			}
+			if v[0] >= uint64(book.Size*book.Size) {
+				return nil, fmt.Errorf("codec: book move %d off the board", v[0])
+			}
+			if v[1] > math.MaxInt32 {
+				return nil, fmt.Errorf("codec: book move with %d games", v[1])
+			}
+			if kind == codecBookRates {
+				if v[2] > 1000 {
+					return nil, fmt.Errorf("codec: book win rate %d/1000", v[2])
+				}
+				wins := (v[1]*v[2] + 500) / 1000
+				v = append(v[:2], wins, v[1]-wins)
+			}
+			if v[2] > v[1] || v[3] > v[1]-v[2] {
+				return nil, fmt.Errorf("codec: book move with %d games, %d won and %d lost", v[1], v[2], v[3])
+			}
+			moves = append(moves, BookMove{Move: int(v[0]), Games: int(v[1]), Wins: int(v[2]), Losses: int(v[3])})
+		}
+		book.positions[h] = moves
+	}
+	return book, nil
+}
+
+func bookPath() string {
+	return dataPath("book.bin")
+}
+
+// LoadBook reads the book at path. A missing file is no book: it returns
+// nil, which plays no moves.
+func LoadBook(path string) (*OpeningBook, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	book, err := DecodeBook(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return book, nil
+}
+
+func runBook(args []string) error {
+	if len(args) == 0 || args[0] != "build" {
+		return errors.New("usage: book build [-db games.json] [-size N] [-depth N] [-min-games N] [-min-win-rate R] [-o book.bin]")
+	}
+	fs := flag.NewFlagSet("book build", flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	size := fs.Int("size", 19, "board size")
+	depth := fs.Int("depth", 20, "opening moves to consider")
+	minGames := fs.Int("min-games", 5, "games a move must have been played in")
+	minWinRate := fs.Float64("min-win-rate", 0.45, "share of decided games the side playing a move must have won")
+	out := fs.String("o", bookPath(), "book file to write, loaded by the computer at startup")
+	fs.Parse(args[1:])
+
+	games, err := loadGames(*dbPath)
+	if err != nil {
+		return err
+	}
+	book, err := BuildBook(games, *size, BookOptions{Depth: *depth, MinGames: *minGames, MinWinRate: *minWinRate})
+	if err != nil {
+		return err
+	}
+	if err := saveFile(*out, EncodeBook(book)); err != nil {
+		return err
+	}
+	fmt.Printf("%d positions on %dx%d written to %s\n", book.Len(), *size, *size, *out)
+	return nil
+}
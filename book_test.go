@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBuildBook(t *testing.T) {
+	db := newMemoryDB()
+	// 3 3 is played in four games, three won by Black; 2 2 once.
+	for i, game := range []struct {
+		moves  []int
+		result string
+	}{
+		{[]int{24, 16, 32}, "B+5"},
+		{[]int{24, 16, 30}, "B+R"},
+		{[]int{24, 32, 16}, "B+2"},
+		{[]int{24, 16, 32, 40}, "W+1"},
+		{[]int{20, 24}, "B+3"},
+	} {
+		g := &GameRecord{Size: 7, Result: game.result}
+		color := Black
+		for _, m := range game.moves {
+			g.Moves = append(g.Moves, RecordedMove{Color: color, Move: m})
+			color = color.Opponent()
+		}
+		db.Add(string(rune('a'+i)), "test", g)
+	}
+
+	book, err := BuildBook(db.Entries, 7, BookOptions{Depth: 10, MinGames: 2, MinWinRate: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBoard(7)
+	if got := book.Lookup(b); len(got) != 1 || got[0] != (BookMove{Move: 24, Games: 4, Wins: 3, Losses: 1}) {
+		t.Errorf("book at the start: %+v", got)
+	}
+	b.Play(24)
+	if got := book.Lookup(b); len(got) != 1 || got[0].Move != 16 {
+		t.Errorf("book after 3 3: %+v", got)
+	}
+	// 2 2 is pruned for too few games, and so is every position after it.
+	b = NewBoard(7)
+	b.Play(20)
+	if got := book.Lookup(b); got != nil {
+		t.Errorf("book after the pruned 2 2: %+v", got)
+	}
+	if book.Len() != 3 {
+		t.Errorf("%d positions, want 3", book.Len())
+	}
+	if move, ok := book.Pick(NewBoard(7)); !ok || move != 24 {
+		t.Errorf("Pick = %d, %v", move, ok)
+	}
+
+	decoded, err := DecodeBook(EncodeBook(book))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Size != 7 || !reflect.DeepEqual(decoded.positions, book.positions) {
+		t.Errorf("round trip changed the book: %+v", decoded.positions)
+	}
+	if _, err := DecodeBook(EncodeBook(book)[:20]); err == nil {
+		t.Error("truncated book decoded")
+	}
+}
+
+func TestBookLearn(t *testing.T) {
+	book := newOpeningBook(7)
+	book.rng = rand.New(rand.NewSource(1))
+	book.positions[NewBoard(7).Hash()] = []BookMove{{Move: 24, Games: 4, Wins: 2, Losses: 2}, {Move: 16, Games: 2, Wins: 1, Losses: 1}}
+	game := func(result string, moves ...int) *GameRecord {
+		g := &GameRecord{Size: 7, Result: result}
+		for i, m := range moves {
+			g.Moves = append(g.Moves, RecordedMove{Color: []Stone{Black, White}[i%2], Move: m})
+		}
+		return g
+	}
+
+	// Black keeps losing after 3 3.
+	for i := 0; i < 6; i++ {
+		if n := book.Learn(game("W+R", 24, 32, PassMove)); n != 1 {
+			t.Fatalf("game %d: %d moves learnt", i+1, n)
+		}
+	}
+	if n := book.Learn(game("B+2", 16, 32)); n != 1 {
+		t.Fatalf("%d moves learnt from a win", n)
+	}
+	if n := book.Learn(game("Void", 16)) + book.Learn(&GameRecord{Size: 9, Result: "B+R"}); n != 0 {
+		t.Errorf("learnt %d moves from games with nothing to teach", n)
+	}
+	want := []BookMove{{Move: 24, Games: 10, Wins: 2, Losses: 8}, {Move: 16, Games: 3, Wins: 2, Losses: 1}}
+	if got := book.Lookup(NewBoard(7)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("book after learning: %+v", got)
+	}
+
+	picks := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		move, _ := book.Pick(NewBoard(7))
+		picks[move]++
+	}
+	if picks[16] <= picks[24] || picks[24] == 0 {
+		t.Errorf("picks after learning: %v", picks)
+	}
+}
+
+func TestBookLearnManyGames(t *testing.T) {
+	// One result moves the win rate of a move with thousands of games by
+	// less than its last place, but the counts keep every one of them.
+	book := newOpeningBook(7)
+	book.positions[NewBoard(7).Hash()] = []BookMove{{Move: 24, Games: 2000, Wins: 1000, Losses: 1000}}
+	loss := &GameRecord{Size: 7, Result: "W+R", Moves: []RecordedMove{{Color: Black, Move: 24}}}
+	for i := 0; i < 200; i++ {
+		book.Learn(loss)
+	}
+	got := book.Lookup(NewBoard(7))[0]
+	if got != (BookMove{Move: 24, Games: 2200, Wins: 1000, Losses: 1200}) || math.Abs(got.WinRate()-1000.0/2200) > 1e-9 {
+		t.Errorf("after 200 losses: %+v, win rate %.4f", got, got.WinRate())
+	}
+}
+
+func TestDecodeBookRates(t *testing.T) {
+	// A book from before the counts: 3 3 in 8 games at 0.625 from the
+	// empty board.
+	data := append(codecHeader(codecBookRates), 7)
+	data = binary.AppendUvarint(data, 1)
+	data = binary.LittleEndian.AppendUint64(data, NewBoard(7).Hash())
+	for _, v := range []uint64{1, 24, 8, 625} {
+		data = binary.AppendUvarint(data, v)
+	}
+	book, err := DecodeBook(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := book.Lookup(NewBoard(7)); len(got) != 1 || got[0] != (BookMove{Move: 24, Games: 8, Wins: 5, Losses: 3}) {
+		t.Errorf("old book: %+v", got)
+	}
+	if again, err := DecodeBook(EncodeBook(book)); err != nil || !reflect.DeepEqual(again.positions, book.positions) {
+		t.Errorf("written again: %v", err)
+	}
+}
@@ -0,0 +1,880 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	commands["broadcast"] = runBroadcast
+}
+
+// Broadcast mode is for online lectures and club teaching: a presenter
+// sets out positions and plays through them, and any number of viewers
+// follow live over the network protocol, from a browser with -ws. The
+// presenter types commands at the terminal broadcast runs in, with
+// points as GTP vertices:
+//
+//	D4, pass              play the next move
+//	b D4, w D4            play a move of either colour, in any order
+//	ab D4 Q16, aw, ae     add black or white stones, or empty points
+//	tr D4, sq, cr, ma     mark points with triangles, squares, circles or crosses, or unmark them
+//	lb D4 A               label a point; lb D4 takes the label off
+//	clear                 take the marks off the position
+//	hl D4, shade C3:E5    point at points, or shade a region, for the moment
+//	ar D4 Q16             draw an arrow for the moment
+//	erase                 take off what was pointed at
+//	pin                   keep it, in the game tree
+//	comment TEXT          comment on the position
+//	back [N], next [N], start, end
+//	var N                 follow the Nth move played from here
+//	save FILE             write the game tree, variations and all, as SGF
+//
+// With -auth a presenter can instead connect from elsewhere with the
+// token printed, as its hello (connect -token does this), and send the
+// same commands as {"command": "..."} or as moves.
+//
+// The board is a game tree, as in an SGF editor: a move already played
+// from the position is followed rather than added again, and any other
+// starts a variation. Viewers are sent a board event (see netgame.go)
+// whenever the board or its marks change, with the last move, the
+// comment, the marks and, where the game branches, the moves of the
+// branches:
+//
+//	{"event": "board", "size": 19, "number": 3, "to_move": "white", "color": "black", "move": "3 15",
+//	 "row": 3, "col": 15, "delta": {"b": [...], "w": [...], "cap": [0, 0]},
+//	 "marks": [{"kind": "TR", "point": 60}, {"kind": "LB", "point": 72, "label": "A"}],
+//	 "variations": ["3 3", "16 2"]}
+//
+// Pointing at the board (hl, shade, ar) is a laser pointer: it is shown
+// to viewers but goes with the position, and is kept in the game tree
+// only if pinned, as the SGF markup SL, DD and AR. Viewers are sent what
+// is pointed at in a markup event each time it changes; a board event
+// takes it all off, and a markup event with no marks clears it.
+//
+//	{"event": "markup", "marks": [{"kind": "AR", "point": 72, "to": 18}, {"kind": "DD", "point": 12}]}
+//
+// Viewers' moves are refused, and their {"board": true} is answered with
+// the board event again, and the markup event if anything is pointed at.
+
+// pipeMark is a mark on a point of a broadcast board, by its SGF
+// property: TR, SQ, CR, MA, SL (highlighted) or DD (shaded), LB with its
+// label, or AR, an arrow to To.
+type pipeMark struct {
+	Kind  string `json:"kind"`
+	Point int    `json:"point"`
+	To    *int   `json:"to,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+// markIDs are the SGF properties of the marks a presenter can put on
+// points, one to a point.
+var markIDs = []string{"TR", "SQ", "CR", "MA", "LB"}
+
+// pointerIDs are the SGF properties pointing at the board is pinned as.
+var pointerIDs = []string{"SL", "DD", "AR"}
+
+const broadcastHelp = `Commands, with points such as D4:
+  D4, pass            play the next move
+  b D4, w D4          play a move of either colour
+  ab D4 Q16, aw, ae   add black or white stones, or empty points
+  tr D4, sq, cr, ma   mark points with triangles, squares, circles or crosses
+  lb D4 A             label a point
+  clear               take the marks off
+  hl D4, shade C3:E5  point at points, or shade a region, for the moment
+  ar D4 Q16           draw an arrow for the moment
+  erase, pin          take off what was pointed at, or keep it as marks
+  comment TEXT        comment on the position
+  back [N], next [N], start, end, var N
+  save FILE           write the game tree as SGF
+  quit`
+
+// broadcast is the board a presenter controls and the viewers following
+// it.
+type broadcast struct {
+	mu        sync.Mutex // guards everything below
+	size      int
+	root      *SGFNode
+	path      []*SGFNode // from the root to the node shown
+	board     *Board     // the position at the node shown
+	event     pipeEvent  // the board event for it
+	pointer   []pipeMark // pointed at on it, and not pinned
+	viewers   map[*netPlayer]bool
+	presenter *netPlayer // connected with the token, if any
+}
+
+// newBroadcast shows the start of the game tree root.
+func newBroadcast(root *SGFNode) (*broadcast, error) {
+	bc := &broadcast{size: 19, root: root, viewers: map[*netPlayer]bool{}}
+	if sz := root.Get("SZ"); sz != "" {
+		n, err := strconv.Atoi(sz)
+		if err != nil || n < 2 || n > MaxBoardSize {
+			return nil, fmt.Errorf("bad board size %q", sz)
+		}
+		bc.size = n
+	}
+	return bc, bc.goTo([]*SGFNode{root})
+}
+
+// nodeMove is the move of n, if it has one.
+func nodeColorMove(n *SGFNode, size int) (color Stone, move int, ok bool, err error) {
+	for _, c := range []Stone{Black, White} {
+		id := "B"
+		if c == White {
+			id = "W"
+		}
+		if n.Values(id) != nil {
+			move, err = sgfPoint(n.Get(id), size)
+			return c, move, true, err
+		}
+	}
+	return Empty, 0, false, nil
+}
+
+// pointValues is the points of property id of n.
+func pointValues(n *SGFNode, id string, size int) []int {
+	var points []int
+	for _, v := range n.Values(id) {
+		ps, _ := sgfPointList(v, size)
+		points = append(points, ps...)
+	}
+	return points
+}
+
+// setPointValues sets property id of n to points, or removes it if there
+// are none.
+func setPointValues(n *SGFNode, id string, points []int, size int) {
+	var values []string
+	for _, p := range points {
+		values = append(values, sgfPointString(p, size))
+	}
+	n.Set(id, values...)
+}
+
+// nodeMarks is the marks of n.
+func nodeMarks(n *SGFNode, size int) []pipeMark {
+	var marks []pipeMark
+	for _, id := range markIDs {
+		if id == "LB" {
+			for _, v := range n.Values(id) {
+				point, label, _ := strings.Cut(v, ":")
+				if p, err := sgfPoint(point, size); err == nil && p != PassMove {
+					marks = append(marks, pipeMark{Kind: id, Point: p, Label: label})
+				}
+			}
+			continue
+		}
+		for _, p := range pointValues(n, id, size) {
+			marks = append(marks, pipeMark{Kind: id, Point: p})
+		}
+	}
+	for _, id := range pointerIDs {
+		if id != "AR" {
+			for _, p := range pointValues(n, id, size) {
+				marks = append(marks, pipeMark{Kind: id, Point: p})
+			}
+			continue
+		}
+		for _, v := range n.Values(id) {
+			from, to, _ := strings.Cut(v, ":")
+			p, err1 := sgfPoint(from, size)
+			q, err2 := sgfPoint(to, size)
+			if err1 == nil && err2 == nil && p != PassMove && q != PassMove {
+				marks = append(marks, pipeMark{Kind: id, Point: p, To: &q})
+			}
+		}
+	}
+	return marks
+}
+
+// sameMark reports whether a and b are the same mark.
+func sameMark(a, b pipeMark) bool {
+	return a.Kind == b.Kind && a.Point == b.Point && a.Label == b.Label && (a.To == nil) == (b.To == nil) && (a.To == nil || *a.To == *b.To)
+}
+
+// markPoint puts a mark of kind id on p in n, taking off any other mark
+// there, or takes it off if it is there already. An LB mark has label,
+// or is taken off if label is "".
+func markPoint(n *SGFNode, id string, p int, label string, size int) {
+	had := false
+	for _, other := range markIDs {
+		if other == "LB" {
+			var kept []string
+			for _, v := range n.Values(other) {
+				if point, _, _ := strings.Cut(v, ":"); point != sgfPointString(p, size) {
+					kept = append(kept, v)
+				}
+			}
+			n.Set(other, kept...)
+			continue
+		}
+		var kept []int
+		for _, q := range pointValues(n, other, size) {
+			if q == p {
+				had = had || other == id
+				continue
+			}
+			kept = append(kept, q)
+		}
+		setPointValues(n, other, kept, size)
+	}
+	switch {
+	case id == "LB" && label != "":
+		n.Set(id, append(n.Values(id), sgfPointString(p, size)+":"+label)...)
+	case id != "LB" && !had:
+		setPointValues(n, id, append(pointValues(n, id, size), p), size)
+	}
+}
+
+// position replays path, returning the position at its end and the board
+// event for it.
+func (bc *broadcast) position(path []*SGFNode) (*Board, pipeEvent, error) {
+	size := bc.size
+	b := NewBoard(size)
+	e := pipeEvent{Event: "board", Size: size}
+	count := func(s Stone) int {
+		n := 0
+		for p := 0; p < size*size; p++ {
+			if b.At(p/size, p%size) == s {
+				n++
+			}
+		}
+		return n
+	}
+	var captures [2]int
+	for _, n := range path {
+		for _, setup := range []struct {
+			id    string
+			color Stone
+		}{{"AB", Black}, {"AW", White}, {"AE", Empty}} {
+			for _, p := range pointValues(n, setup.id, size) {
+				b.setPoint(p, setup.color)
+			}
+		}
+		switch n.Get("PL") {
+		case "B":
+			b.turn = Black
+		case "W":
+			b.turn = White
+		}
+		color, move, ok, err := nodeColorMove(n, size)
+		if err != nil {
+			return nil, e, err
+		}
+		if !ok {
+			continue
+		}
+		b.turn = color
+		before := count(color.Opponent())
+		if !b.Play(move) {
+			return nil, e, fmt.Errorf("%s %s is not legal there", colorKey(color), gtpVertex(move, size))
+		}
+		captures[color-Black] += before - count(color.Opponent())
+		e.Number++
+		e.Color, e.Move, e.Row, e.Col = colorKey(color), formatMove(move, size), nil, nil
+		if move != PassMove {
+			row, col := move/size, move%size
+			e.Row, e.Col = &row, &col
+		}
+	}
+	e.ToMove, e.Hash = colorKey(b.turn), b.Hash()
+	e.Delta = boardDelta(NewBoard(size), b, captures)
+	n := path[len(path)-1]
+	e.Comment, e.Marks = n.Get("C"), nodeMarks(n, size)
+	if len(n.Children) > 1 {
+		for _, child := range n.Children {
+			v := "setup"
+			if _, move, ok, err := nodeColorMove(child, size); ok && err == nil {
+				v = formatMove(move, size)
+			}
+			e.Variations = append(e.Variations, v)
+		}
+	}
+	return b, e, nil
+}
+
+// goTo shows the node at the end of path, telling the viewers. What was
+// pointed at stays only if the node is the one shown already.
+func (bc *broadcast) goTo(path []*SGFNode) error {
+	b, e, err := bc.position(path)
+	if err != nil {
+		return err
+	}
+	if len(bc.path) == 0 || bc.path[len(bc.path)-1] != path[len(path)-1] {
+		bc.pointer = nil
+	}
+	bc.path, bc.board, bc.event = path, b, e
+	for v := range bc.viewers {
+		bc.sendBoard(v)
+	}
+	return nil
+}
+
+// sendBoard sends v the board event, and the markup event if anything is
+// pointed at.
+func (bc *broadcast) sendBoard(v *netPlayer) {
+	v.send(bc.event)
+	if len(bc.pointer) > 0 {
+		v.send(pipeEvent{Event: "markup", Marks: bc.pointer})
+	}
+}
+
+// point points at the board with marks, or stops pointing with those
+// there already.
+func (bc *broadcast) point(marks ...pipeMark) {
+	pointer := bc.pointer
+	for _, m := range marks {
+		kept := pointer[:0:0]
+		found := false
+		for _, old := range pointer {
+			if sameMark(old, m) {
+				found = true
+			} else {
+				kept = append(kept, old)
+			}
+		}
+		if !found {
+			kept = append(kept, m)
+		}
+		pointer = kept
+	}
+	bc.setPointer(pointer)
+}
+
+// setPointer replaces what is pointed at, telling the viewers.
+func (bc *broadcast) setPointer(marks []pipeMark) {
+	bc.pointer = marks
+	for v := range bc.viewers {
+		v.send(pipeEvent{Event: "markup", Marks: marks})
+	}
+}
+
+// pin keeps what is pointed at in the node shown, as SGF markup.
+func (bc *broadcast) pin() error {
+	if len(bc.pointer) == 0 {
+		return errors.New("nothing is pointed at")
+	}
+	n := bc.path[len(bc.path)-1]
+	for _, m := range bc.pointer {
+		if m.Kind == "AR" {
+			v := sgfPointString(m.Point, bc.size) + ":" + sgfPointString(*m.To, bc.size)
+			for _, old := range n.Values(m.Kind) {
+				if old == v {
+					v = ""
+				}
+			}
+			if v != "" {
+				n.Set(m.Kind, append(n.Values(m.Kind), v)...)
+			}
+			continue
+		}
+		if points := pointValues(n, m.Kind, bc.size); !contains(points, m.Point) {
+			setPointValues(n, m.Kind, append(points, m.Point), bc.size)
+		}
+	}
+	bc.pointer = nil
+	return bc.goTo(bc.path)
+}
+
+// play plays move for color from the node shown, following the variation
+// with that move if there is one, and starting one otherwise.
+func (bc *broadcast) play(color Stone, move int) error {
+	cur := bc.path[len(bc.path)-1]
+	for _, child := range cur.Children {
+		if c, m, ok, err := nodeColorMove(child, bc.size); ok && err == nil && c == color && m == move {
+			return bc.goTo(append(bc.path, child))
+		}
+	}
+	n := &SGFNode{}
+	id := "B"
+	if color == White {
+		id = "W"
+	}
+	n.Set(id, sgfPointString(move, bc.size))
+	cur.Children = append(cur.Children, n)
+	if err := bc.goTo(append(bc.path, n)); err != nil {
+		cur.Children = cur.Children[:len(cur.Children)-1]
+		return err
+	}
+	return nil
+}
+
+// setup puts stones of the colour of id, AB, AW or AE, on points: in the
+// node shown if it has no move, and in a new node after it otherwise.
+func (bc *broadcast) setup(id string, points []int) error {
+	cur, path := bc.path[len(bc.path)-1], bc.path
+	if _, _, ok, _ := nodeColorMove(cur, bc.size); ok {
+		n := &SGFNode{}
+		cur.Children = append(cur.Children, n)
+		cur, path = n, append(path, n)
+	}
+	for _, other := range []string{"AB", "AW", "AE"} {
+		var kept []int
+		for _, q := range pointValues(cur, other, bc.size) {
+			if !contains(points, q) {
+				kept = append(kept, q)
+			}
+		}
+		if other == id {
+			kept = append(kept, points...)
+		}
+		setPointValues(cur, other, kept, bc.size)
+	}
+	return bc.goTo(path)
+}
+
+// command carries out one of the presenter's commands, and returns a note
+// for them, if any.
+func (bc *broadcast) command(line string) (string, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return "", nil
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	cur := bc.path[len(bc.path)-1]
+	points := func(vertices []string) ([]int, error) {
+		if len(vertices) == 0 {
+			return nil, fmt.Errorf("usage: %s D4 ...", args[0])
+		}
+		var ps []int
+		for _, v := range vertices {
+			// A1:C3 is the rectangle with those corners.
+			from, to, _ := strings.Cut(v, ":")
+			if to == "" {
+				to = from
+			}
+			p, err1 := parseGTPVertex(from, bc.size)
+			q, err2 := parseGTPVertex(to, bc.size)
+			if err1 != nil || err2 != nil || p == PassMove || q == PassMove {
+				return nil, fmt.Errorf("bad point %q", v)
+			}
+			for row := min(p/bc.size, q/bc.size); row <= max(p/bc.size, q/bc.size); row++ {
+				for col := min(p%bc.size, q%bc.size); col <= max(p%bc.size, q%bc.size); col++ {
+					ps = append(ps, row*bc.size+col)
+				}
+			}
+		}
+		return ps, nil
+	}
+	count := func() (int, error) {
+		if len(args) < 2 {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("usage: %s [N]", args[0])
+		}
+		return n, nil
+	}
+	switch cmd := strings.ToLower(args[0]); cmd {
+	case "help":
+		return broadcastHelp, nil
+	case "b", "w":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: %s D4", cmd)
+		}
+		move, err := parseGTPVertex(args[1], bc.size)
+		if err != nil {
+			return "", err
+		}
+		color := Black
+		if cmd == "w" {
+			color = White
+		}
+		return "", bc.play(color, move)
+	case "ab", "aw", "ae":
+		ps, err := points(args[1:])
+		if err != nil {
+			return "", err
+		}
+		return "", bc.setup(strings.ToUpper(cmd), ps)
+	case "tr", "sq", "cr", "ma":
+		ps, err := points(args[1:])
+		if err != nil {
+			return "", err
+		}
+		for _, p := range ps {
+			markPoint(cur, strings.ToUpper(cmd), p, "", bc.size)
+		}
+		return "", bc.goTo(bc.path)
+	case "lb":
+		ps, err := points(args[1:min(2, len(args))])
+		if err != nil {
+			return "", err
+		}
+		markPoint(cur, "LB", ps[0], strings.Join(args[2:], " "), bc.size)
+		return "", bc.goTo(bc.path)
+	case "clear":
+		for _, id := range append(markIDs, pointerIDs...) {
+			cur.Set(id)
+		}
+		return "", bc.goTo(bc.path)
+	case "hl", "shade":
+		ps, err := points(args[1:])
+		if err != nil {
+			return "", err
+		}
+		var marks []pipeMark
+		for _, p := range ps {
+			marks = append(marks, pipeMark{Kind: map[string]string{"hl": "SL", "shade": "DD"}[cmd], Point: p})
+		}
+		bc.point(marks...)
+		return "", nil
+	case "ar":
+		ps, err := points(args[1:])
+		if err != nil || len(ps) != 2 {
+			return "", errors.New("usage: ar FROM TO")
+		}
+		bc.point(pipeMark{Kind: "AR", Point: ps[0], To: &ps[1]})
+		return "", nil
+	case "erase":
+		bc.setPointer(nil)
+		return "", nil
+	case "pin":
+		return "", bc.pin()
+	case "comment":
+		_, text, _ := strings.Cut(strings.TrimSpace(line), " ")
+		cur.Set("C", strings.TrimSpace(text))
+		return "", bc.goTo(bc.path)
+	case "back":
+		n, err := count()
+		if err != nil {
+			return "", err
+		}
+		return "", bc.goTo(bc.path[:max(1, len(bc.path)-n)])
+	case "start":
+		return "", bc.goTo(bc.path[:1])
+	case "next", "end":
+		n := math.MaxInt
+		if cmd == "next" {
+			var err error
+			if n, err = count(); err != nil {
+				return "", err
+			}
+		}
+		path := bc.path
+		for ; n > 0 && len(path[len(path)-1].Children) > 0; n-- {
+			path = append(path, path[len(path)-1].Children[0])
+		}
+		return "", bc.goTo(path)
+	case "var":
+		n, err := count()
+		if err != nil || len(args) != 2 {
+			return "", errors.New("usage: var N")
+		}
+		if n > len(cur.Children) {
+			return "", fmt.Errorf("there are %d moves from here", len(cur.Children))
+		}
+		return "", bc.goTo(append(bc.path, cur.Children[n-1]))
+	case "save":
+		if len(args) != 2 {
+			return "", errors.New("usage: save FILE")
+		}
+		if err := WriteSGFFile(args[1], []*SGFNode{bc.root}); err != nil {
+			return "", err
+		}
+		return "Saved to " + args[1], nil
+	}
+	if len(args) == 1 {
+		if move, err := parseGTPVertex(args[0], bc.size); err == nil {
+			return "", bc.play(bc.board.turn, move)
+		}
+	}
+	return "", fmt.Errorf("unknown command %q, see help", args[0])
+}
+
+// show prints the board as the presenter sees it.
+func (bc *broadcast) show() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	fmt.Println(chatBoard(bc.board))
+	e := bc.event
+	if e.Color != "" {
+		move := PassMove
+		if e.Row != nil {
+			move = *e.Row*bc.size + *e.Col
+		}
+		fmt.Printf("Move %d: %s %s\n", e.Number, e.Color, gtpVertex(move, bc.size))
+	}
+	for _, marks := range []struct {
+		name  string
+		marks []pipeMark
+	}{{"Marks", e.Marks}, {"Pointing at", bc.pointer}} {
+		if len(marks.marks) == 0 {
+			continue
+		}
+		var list []string
+		for _, m := range marks.marks {
+			text := m.Kind + " " + gtpVertex(m.Point, bc.size)
+			if m.To != nil {
+				text += "-" + gtpVertex(*m.To, bc.size)
+			}
+			list = append(list, strings.TrimSpace(text+" "+m.Label))
+		}
+		fmt.Printf("%s: %s\n", marks.name, strings.Join(list, ", "))
+	}
+	if e.Comment != "" {
+		fmt.Println(e.Comment)
+	}
+	if children := bc.path[len(bc.path)-1].Children; len(children) > 1 {
+		var moves []string
+		for i, child := range children {
+			v := "setup"
+			if _, move, ok, err := nodeColorMove(child, bc.size); ok && err == nil {
+				v = gtpVertex(move, bc.size)
+			}
+			moves = append(moves, fmt.Sprintf("%d %s", i+1, v))
+		}
+		fmt.Println("Variations:", strings.Join(moves, ", "))
+	}
+	fmt.Printf("%s to play, %d watching\n", colorName(bc.board.turn), len(bc.viewers))
+}
+
+// join adds a viewer, or the presenter, on conn and sends it the board.
+func (bc *broadcast) join(conn io.ReadWriter, gate *lineGate, presenter bool) error {
+	v := makeNetPlayer(Empty, conn, gate)
+	if presenter {
+		v.commands = make(chan string)
+	}
+	bc.mu.Lock()
+	if presenter {
+		if bc.presenter != nil {
+			bc.mu.Unlock()
+			return errors.New("the presenter is already connected")
+		}
+		bc.presenter = v
+	}
+	bc.viewers[v] = true
+	bc.sendBoard(v)
+	bc.mu.Unlock()
+	go v.read(conn)
+	go func() {
+		defer func() {
+			bc.mu.Lock()
+			delete(bc.viewers, v)
+			if bc.presenter == v {
+				bc.presenter = nil
+			}
+			bc.mu.Unlock()
+		}()
+		run := func(line string) {
+			note, err := bc.command(line)
+			switch {
+			case err != nil:
+				v.send(pipeEvent{Event: "error", Code: errBadCommand, Message: err.Error()})
+			case note != "":
+				v.send(pipeEvent{Event: "message", Message: note})
+			}
+		}
+		for {
+			select {
+			case m := <-v.moves:
+				if !presenter {
+					v.send(pipeEvent{Event: "error", Code: errNotAPlayer, Message: "you are watching the broadcast"})
+					continue
+				}
+				run(m.Move)
+			case line := <-v.commands:
+				run(line)
+			case <-v.syncs:
+				bc.mu.Lock()
+				bc.sendBoard(v)
+				bc.mu.Unlock()
+			case <-v.gone:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// admit lets a new connection in, checking the password if there is one.
+// With a token, a connection whose hello has it presents; every other
+// connection watches.
+func (bc *broadcast) admit(conn net.Conn, password, token string, limits *netLimits) {
+	limited, err := limits.accept(conn, time.Now())
+	if err != nil {
+		refuse(conn, errRateLimited, err)
+		return
+	}
+	conn = limited
+	presenter := false
+	if password != "" || token != "" {
+		hello := readHello(conn)
+		if err := (netAccess{password: password}).checkPassword(hello); err != nil {
+			refuse(conn, errUnauthorized, err)
+			return
+		}
+		if hello.Hello != "" {
+			if token == "" || subtle.ConstantTimeCompare([]byte(hello.Hello), []byte(token)) != 1 {
+				refuse(conn, errUnauthorized, errors.New("unknown token"))
+				return
+			}
+			presenter = true
+		}
+	}
+	if err := bc.join(conn, limits.gate(), presenter); err != nil {
+		refuse(conn, errUnauthorized, err)
+		return
+	}
+	if presenter {
+		fmt.Printf("\npresenter: %s\n> ", conn.RemoteAddr())
+	}
+}
+
+// end tells everyone the broadcast is over.
+func (bc *broadcast) end() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	for v := range bc.viewers {
+		v.send(pipeEvent{Event: "end", Message: "The broadcast is over."})
+	}
+}
+
+func runBroadcast(args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	addr := fs.String("addr", ":7100", "address to listen on")
+	size := fs.Int("size", 19, "board size, when not starting from a game")
+	ws := fs.Bool("ws", false, "speak WebSocket instead of plain TCP, for browsers (see websocket.go)")
+	auth := fs.Bool("auth", false, "print a token the presenter can connect with to send commands from elsewhere")
+	password := fs.String("password", os.Getenv("GOGAME_PASSWORD"), "password every viewer must connect with (default $GOGAME_PASSWORD)")
+	certFile := fs.String("tls-cert", "", "serve over TLS with this certificate file (PEM)")
+	keyFile := fs.String("tls-key", "", "private key file (PEM) for -tls-cert")
+	selfSigned := fs.Bool("tls-self-signed", false, "serve over TLS with a new self-signed certificate, printing its fingerprint for viewers to pin")
+	inputRate := fs.Float64("input-rate", 5, "lines a second from one connection, after a burst of 20; 0 for no limit")
+	out := fs.String("o", "", "write the game tree to this SGF file when the broadcast ends")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		return errors.New("usage: broadcast [flags] [game.sgf]")
+	}
+
+	root := &SGFNode{}
+	if fs.NArg() == 1 {
+		games, err := ReadSGFFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if len(games) == 0 {
+			return fmt.Errorf("%s: no game", fs.Arg(0))
+		}
+		root = games[0]
+	} else {
+		if *size < 2 || *size > MaxBoardSize {
+			return fmt.Errorf("board size must be between 2 and %d", MaxBoardSize)
+		}
+		root.Set("GM", "1")
+		root.Set("FF", "4")
+		root.Set("SZ", strconv.Itoa(*size))
+	}
+	bc, err := newBroadcast(root)
+	if err != nil {
+		return err
+	}
+	token := ""
+	if *auth {
+		key := make([]byte, 8)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(key)
+	}
+
+	ln, fingerprint, err := listenTLS(*addr, *certFile, *keyFile, *selfSigned)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if *ws {
+		ln = newWSListener(ln)
+	}
+	join := fmt.Sprintf("gogame connect -addr %s", ln.Addr())
+	if *ws {
+		join += " -ws"
+	}
+	switch {
+	case *selfSigned:
+		join += " -tls-fingerprint " + fingerprint
+	case fingerprint != "":
+		join += " -tls"
+	}
+	fmt.Printf("Broadcasting on %s; viewers join with: %s\n", ln.Addr(), join)
+	if token != "" {
+		fmt.Printf("The presenter joins with: %s -token %s\n", join, token)
+	}
+	limits := newNetLimits(0, 0, *inputRate, 0)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go bc.admit(conn, *password, token, limits)
+		}
+	}()
+
+	interrupts, stopInterrupts := notifyShutdown()
+	defer stopInterrupts()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		in := bufio.NewScanner(os.Stdin)
+		for in.Scan() {
+			lines <- in.Text()
+		}
+	}()
+	fmt.Println(broadcastHelp)
+	bc.show()
+	fmt.Print("> ")
+loop:
+	for {
+		select {
+		case <-interrupts:
+			fmt.Println()
+			break loop
+		case line, ok := <-lines:
+			switch {
+			case !ok && token == "":
+				break loop
+			case !ok:
+				// The presenter is elsewhere; run until stopped.
+				lines = nil
+				continue
+			case strings.TrimSpace(line) == "quit":
+				break loop
+			}
+			if note, err := bc.command(line); err != nil {
+				fmt.Println(err)
+			} else if note != "" {
+				fmt.Println(note)
+			} else if strings.TrimSpace(line) != "" {
+				bc.show()
+			}
+			fmt.Print("> ")
+		}
+	}
+	bc.end()
+	if *out != "" {
+		bc.mu.Lock()
+		err := WriteSGFFile(*out, []*SGFNode{bc.root})
+		bc.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Saved to", *out)
+	}
+	return nil
+}
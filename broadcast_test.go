@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBroadcast(t *testing.T) {
+	root := &SGFNode{}
+	root.Set("SZ", "5")
+	bc, err := newBroadcast(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := func(presenter bool) *netTestClient {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		c := newNetTestClient(client)
+		if err := bc.join(server, nil, presenter); err != nil {
+			t.Fatal(err)
+		}
+		c.next(t, "board")
+		return c
+	}
+	viewer, presenter := join(false), join(true)
+	if err := bc.join(nil, nil, true); err == nil {
+		t.Error("a second presenter joined")
+	}
+	run := func(line string) pipeEvent {
+		t.Helper()
+		if _, err := bc.command(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		presenter.next(t, "board")
+		return viewer.next(t, "board")
+	}
+
+	// White captures, out of turn, on a set-up position.
+	run("ab A5 A4")
+	run("w B5")
+	e := run("w b4")
+	if e.Number != 2 || e.Move != "1 1" || e.ToMove != "black" {
+		t.Fatalf("after w B4: %+v", e)
+	}
+	e = run("w a3")
+	if want := (&pipeDelta{White: []int{1, 6, 10}, Captures: [2]int{0, 2}}); !reflect.DeepEqual(e.Delta, want) {
+		t.Fatalf("capture: delta %+v", e.Delta)
+	}
+
+	run("tr C3 D3")
+	run("tr D3")
+	e = run("lb C2 A")
+	if want := []pipeMark{{Kind: "TR", Point: 12}, {Kind: "LB", Point: 17, Label: "A"}}; !reflect.DeepEqual(e.Marks, want) {
+		t.Fatalf("marks = %+v", e.Marks)
+	}
+	run("comment Black is dead")
+
+	// A different move from the same position starts a variation, and
+	// playing the first again follows it.
+	run("back")
+	e = run("b a3")
+	if e.Color != "black" || e.Marks != nil || e.Comment != "" {
+		t.Fatalf("variation: %+v", e)
+	}
+	run("back")
+	if _, err := bc.command("back 0"); err == nil {
+		t.Error("back 0 accepted")
+	}
+	e = run("w a3")
+	if e.Comment != "Black is dead" {
+		t.Fatalf("followed move: %+v", e)
+	}
+	e = run("back")
+	if !reflect.DeepEqual(e.Variations, []string{"2 0", "2 0"}) || e.ToMove != "black" {
+		t.Fatalf("variations = %v", e.Variations)
+	}
+	if _, err := bc.command("b A5"); err == nil {
+		t.Error("a move on a stone was played")
+	}
+
+	// The presenter's connection sends commands; a viewer's is refused.
+	json.NewEncoder(presenter.conn).Encode(netInput{Command: "var 2"})
+	if e := viewer.next(t, "board"); e.Color != "black" {
+		t.Fatalf("var 2 = %+v", e)
+	}
+	json.NewEncoder(viewer.conn).Encode(netInput{Move: "C3"})
+	if e := viewer.next(t, "error"); e.Code != errNotAPlayer {
+		t.Fatalf("viewer's move: %+v", e)
+	}
+	json.NewEncoder(viewer.conn).Encode(netInput{Board: true})
+	if e := viewer.next(t, "board"); e.Color != "black" {
+		t.Fatalf("resync = %+v", e)
+	}
+
+	path := filepath.Join(t.TempDir(), "lecture.sgf")
+	if _, err := bc.command("save " + path); err != nil {
+		t.Fatal(err)
+	}
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := games[0].Children[0].Children[0]; len(n.Children) != 2 || n.Children[0].Get("C") != "Black is dead" || n.Children[1].Get("B") != "ac" {
+		t.Errorf("saved tree: %+v", n)
+	}
+}
+
+func TestBroadcastPointer(t *testing.T) {
+	root := &SGFNode{}
+	root.Set("SZ", "5")
+	bc, err := newBroadcast(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+	viewer := newNetTestClient(client)
+	bc.join(server, nil, false)
+	viewer.next(t, "board")
+	command := func(line string) {
+		t.Helper()
+		if _, err := bc.command(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	command("hl C3")
+	command("shade A1:B2")
+	command("ar A5 E1")
+	command("hl C3")
+	to := 24
+	want := []pipeMark{{Kind: "DD", Point: 15}, {Kind: "DD", Point: 16}, {Kind: "DD", Point: 20}, {Kind: "DD", Point: 21}, {Kind: "AR", Point: 0, To: &to}}
+	var e pipeEvent
+	for i := 0; i < 4; i++ {
+		e = viewer.next(t, "markup")
+	}
+	if !reflect.DeepEqual(e.Marks, want) {
+		t.Fatalf("pointer = %+v", e.Marks)
+	}
+
+	// Pointing is not kept unless pinned, and goes with the position.
+	command("D4")
+	if e := viewer.next(t, "board"); e.Marks != nil {
+		t.Fatalf("marks after a move: %+v", e.Marks)
+	}
+	command("hl C3")
+	viewer.next(t, "markup")
+	command("back")
+	if e := viewer.next(t, "board"); root.Values("SL") != nil || len(e.Marks) != 0 {
+		t.Fatalf("pointing kept: %+v", root.Props)
+	}
+	command("ar A5 E1")
+	viewer.next(t, "markup")
+	command("pin")
+	if e := viewer.next(t, "board"); root.Get("AR") != "aa:ee" || len(e.Marks) != 1 || *e.Marks[0].To != 24 {
+		t.Fatalf("pinned: %+v, marks %+v", root.Props, e.Marks)
+	}
+	if _, err := bc.command("pin"); err == nil {
+		t.Error("pinned nothing")
+	}
+}
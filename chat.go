@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chatSession is a chat user's connection to a lobby, for frontends that
+// speak text such as Telegram and IRC. It turns the user's messages into
+// lobby commands and moves, and the lobby's events into text and boards,
+// which the frontend sends with say. The lobby's game is the authority:
+// moves are checked there, as for any other connection.
+type chatSession struct {
+	netClient // the game as the user sees it; mu also guards board
+	conn      net.Conn
+	sendMu    sync.Mutex
+	events    chan pipeEvent
+	last      string // the opponent's last move, told with the next turn
+	prefix    string // before commands, such as "/"; optional when typed
+	say       func(text, board string)
+}
+
+// startChatSession joins a user called name, if not "", to l. say sends
+// text to the user, followed by a board drawn by chatBoard if board is not
+// "". done is called when the lobby has let the session go.
+func startChatSession(l *lobby, name, prefix string, say func(text, board string), done func()) *chatSession {
+	server, client := net.Pipe()
+	c := &chatSession{conn: client, events: make(chan pipeEvent, 256), prefix: prefix, say: say}
+	go func() {
+		l.serve(server)
+		done()
+	}()
+	go c.read()
+	go c.handle()
+	if name != "" {
+		c.send(netInput{Command: "name " + name})
+	}
+	return c
+}
+
+// close leaves the lobby, forfeiting a game in progress.
+func (c *chatSession) close() { c.conn.Close() }
+
+// chatHelp is the commands as a user of a chat frontend types them.
+func chatHelp(prefix string) string {
+	return strings.Join([]string{
+		"Commands:",
+		prefix + "who - players and open challenges",
+		prefix + "games - games in progress",
+		prefix + "challenge size=9 komi=6.5 time=10m byoyomi=5x30s color=black",
+		prefix + "accept ID, " + prefix + "cancel ID",
+		prefix + "name NAME [RANK]",
+		"In a game, send a move such as D4, or " + prefix + "pass, " + prefix + "resign, " + prefix + "adjourn, " + prefix + "board.",
+	}, "\n")
+}
+
+func (c *chatSession) send(v netInput) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	json.NewEncoder(c.conn).Encode(v)
+}
+
+// sayBoard says text with the board.
+func (c *chatSession) sayBoard(text string) {
+	c.mu.Lock()
+	board := ""
+	if c.board != nil {
+		board = chatBoard(c.board)
+	}
+	c.mu.Unlock()
+	c.say(text, board)
+}
+
+// input handles a message from the user.
+func (c *chatSession) input(text string) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), c.prefix)
+	c.mu.Lock()
+	playing, size, seq := c.playing, 0, c.moves+1
+	if c.board != nil {
+		size = c.board.Size()
+	}
+	c.mu.Unlock()
+	switch first, _, _ := strings.Cut(text, " "); {
+	case first == "start" || first == "help":
+		c.say(chatHelp(c.prefix), "")
+	case first == "board" && playing:
+		c.sayBoard("")
+	case first == "board":
+		c.say("You are not in a game.", "")
+	case !playing:
+		c.send(netInput{Command: text})
+	default:
+		if move, err := parseGTPVertex(text, size); err == nil && move != PassMove {
+			text = fmt.Sprintf("%d %d", move/size, move%size)
+		}
+		c.send(netInput{Move: text, Seq: seq})
+	}
+}
+
+// read queues the lobby's events for handle, so that sending them to
+// the user does not hold up the lobby, and answers pings at once.
+func (c *chatSession) read() {
+	defer close(c.events)
+	in := bufio.NewScanner(c.conn)
+	for in.Scan() {
+		var e pipeEvent
+		if json.Unmarshal(in.Bytes(), &e) != nil {
+			continue
+		}
+		if e.Event == "ping" {
+			// Not waiting here: the lobby may be waiting for this
+			// reader to take an event before it reads the pong.
+			go c.send(netInput{Pong: e.Ping})
+			continue
+		}
+		c.events <- e
+	}
+}
+
+// handle sends the lobby's events to the user.
+func (c *chatSession) handle() {
+	for e := range c.events {
+		c.mu.Lock()
+		switch e.Event {
+		case "lobby":
+			c.lobby, c.playing = true, false
+		case "start":
+			c.playing = true
+			c.board, c.moves = NewBoard(e.Size), 0
+			c.you = map[string]Stone{"black": Black, "white": White}[e.You]
+			c.last = ""
+		case "clock":
+			c.black, c.white, c.received = e.Black, e.White, time.Now()
+			c.toMove = map[string]Stone{"black": Black, "white": White}[e.ToMove]
+		case "setup":
+			if e.Row != nil && e.Col != nil {
+				c.board.setPoint(*e.Row*c.board.size+*e.Col, map[string]Stone{"black": Black, "white": White}[e.Color])
+			}
+		case "move":
+			if e.Number <= c.moves {
+				break // sent again, for a repeated move or a sync
+			}
+			c.moves = e.Number
+			move := PassMove
+			if e.Row != nil && e.Col != nil {
+				move = *e.Row*c.board.size + *e.Col
+			}
+			c.board.Play(move)
+			if e.Color != colorKey(c.you) {
+				c.last = fmt.Sprintf("%s plays %s.", e.Color, gtpVertex(move, c.board.size))
+			}
+		}
+		you, last := c.you, c.last
+		c.mu.Unlock()
+
+		switch e.Event {
+		case "lobby":
+			c.say(lobbyText(e), "")
+		case "start":
+			c.sayBoard(fmt.Sprintf("You play %s.", colorKey(you)))
+		case "turn":
+			text := strings.TrimSpace(fmt.Sprintf("%s Your move %d.", last, e.Number))
+			if clocks := c.clockLine(time.Now()); clocks != "" {
+				text += "\n" + clocks
+			}
+			c.sayBoard(text)
+		case "error":
+			c.say(e.Message, "")
+		case "end":
+			c.sayBoard("Result: " + e.Result)
+		case "adjourn":
+			c.say(e.Message, "")
+		}
+	}
+}
+
+// chatBoard draws b with GTP coordinates, letters along the top and rows
+// numbered from the bottom, as moves are typed.
+func chatBoard(b *Board) string {
+	var sb strings.Builder
+	sb.WriteString("   ")
+	for c := 0; c < b.size; c++ {
+		fmt.Fprintf(&sb, "%-2s", gtpColumn(c))
+	}
+	for r := 0; r < b.size; r++ {
+		fmt.Fprintf(&sb, "\n%2d", b.size-r)
+		for c := 0; c < b.size; c++ {
+			sb.WriteString(" " + b.grid[r][c].String())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock is a game clock with main time followed by Japanese byo-yomi:
+// once a player's main time is gone each move must be made within Period,
+// or one of their periods is used up, and when the last period runs out
+// they lose on time. It is safe for concurrent use.
+type Clock struct {
+	Main    time.Duration
+	Periods int
+	Period  time.Duration
+
+	mu      sync.Mutex
+	left    [3]time.Duration // main time left, by colour
+	periods [3]int           // byo-yomi periods left, by colour
+	running Stone
+	since   time.Time
+}
+
+// ClockState is one player's clock at an instant.
+type ClockState struct {
+	Main       time.Duration // main time left
+	Periods    int           // byo-yomi periods left
+	PeriodLeft time.Duration // of the current period, in byo-yomi
+	Byoyomi    bool
+	Flagged    bool // out of time
+}
+
+func NewClock(main time.Duration, periods int, period time.Duration) *Clock {
+	c := &Clock{Main: main, Periods: periods, Period: period}
+	for _, s := range []Stone{Black, White} {
+		c.left[s], c.periods[s] = main, periods
+	}
+	return c
+}
+
+// Start runs color's clock from now.
+func (c *Clock) Start(color Stone, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running, c.since = color, now
+}
+
+// Stop charges the running player for the time since Start and reports
+// whether they ran out of time.
+func (c *Clock) Stop(now time.Time) (flagged bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	color := c.running
+	if color == Empty {
+		return false
+	}
+	s := c.state(color, now)
+	c.left[color], c.periods[color], c.running = s.Main, s.Periods, Empty
+	return s.Flagged
+}
+
+// State is color's clock at now, counting the time of a move in progress.
+func (c *Clock) State(color Stone, now time.Time) ClockState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state(color, now)
+}
+
+func (c *Clock) state(color Stone, now time.Time) ClockState {
+	s := ClockState{Main: c.left[color], Periods: c.periods[color]}
+	elapsed := time.Duration(0)
+	if c.running == color {
+		elapsed = now.Sub(c.since)
+	}
+	if elapsed < s.Main {
+		s.Main -= elapsed
+		return s
+	}
+	elapsed -= s.Main
+	s.Main = 0
+	s.Byoyomi = true
+	if c.Period <= 0 || s.Periods == 0 {
+		s.Periods, s.Flagged = 0, true
+		return s
+	}
+	used := int(elapsed / c.Period)
+	if used >= s.Periods {
+		s.Periods, s.Flagged = 0, true
+		return s
+	}
+	s.Periods -= used
+	s.PeriodLeft = c.Period - elapsed%c.Period
+	return s
+}
+
+// MoveTime is how long the player can think before losing a period, or
+// the game if they are in their last one.
+func (s ClockState) MoveTime(period time.Duration) time.Duration {
+	if s.Byoyomi {
+		return s.PeriodLeft
+	}
+	if s.Periods > 0 {
+		return s.Main + period
+	}
+	return s.Main
+}
+
+// String is the clock as shown to players: "4:59" in main time, "3×0:27"
+// in byo-yomi (periods left, then time left in the period).
+func (s ClockState) String() string {
+	switch {
+	case s.Flagged:
+		return "time up"
+	case s.Byoyomi:
+		return fmt.Sprintf("%d×%s", s.Periods, clockText(s.PeriodLeft.Round(time.Second)))
+	}
+	return clockText(s.Main.Round(time.Second))
+}
+
+// SGFTimeLeft is the clock after a move as SGF records it: the seconds
+// left in main time or, in byo-yomi, in the current period, and the
+// periods left once in byo-yomi (OB/OW), 0 before.
+func (s ClockState) SGFTimeLeft() (seconds float64, periods int) {
+	if s.Byoyomi {
+		return s.PeriodLeft.Round(time.Millisecond).Seconds(), s.Periods
+	}
+	return s.Main.Round(time.Millisecond).Seconds(), 0
+}
+
+// OT is the SGF overtime description, e.g. "5x30 byo-yomi".
+func (c *Clock) OT() string {
+	return fmt.Sprintf("%dx%g byo-yomi", c.Periods, c.Period.Seconds())
+}
+
+// parseByoyomi parses "5x30s": periods, then the length of each.
+func parseByoyomi(s string) (int, time.Duration, error) {
+	count, length, ok := strings.Cut(s, "x")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 0 {
+		return 0, 0, fmt.Errorf("byo-yomi %q: want periods x length, such as 5x30s", s)
+	}
+	d, err := time.ParseDuration(length)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("byo-yomi %q: want periods x length, such as 5x30s", s)
+	}
+	return n, d, nil
+}
+
+// parseDurations parses a comma-separated list such as "10s,5s".
+func parseDurations(s string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// clockWatch calls warn each time the running player's byo-yomi period
+// drops to one of the thresholds, until stop is closed.
+func clockWatch(c *Clock, color Stone, thresholds []time.Duration, warn func(ClockState), stop <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	type mark struct {
+		periods   int
+		threshold time.Duration
+	}
+	warned := make(map[mark]bool)
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s := c.State(color, now)
+			if !s.Byoyomi || s.Flagged {
+				continue
+			}
+			for _, t := range thresholds {
+				m := mark{s.Periods, t}
+				if s.PeriodLeft <= t && !warned[m] {
+					// Warn once per threshold per period, for the lowest
+					// threshold reached.
+					for _, u := range thresholds {
+						if s.PeriodLeft <= u {
+							warned[mark{s.Periods, u}] = true
+						}
+					}
+					warn(s)
+					break
+				}
+			}
+		}
+	}
+}
+
+// turnTimer runs playGame's clock, if it has one: the mover's clock starts
+// once per turn however often their input is rejected, and byo-yomi
+// warnings are watched for on the human's turns. A turnTimer without a
+// clock does nothing.
+type turnTimer struct {
+	clock    *Clock
+	view     gameView
+	warnings []time.Duration
+	running  bool
+	stop     chan struct{}
+}
+
+// start starts color's clock unless it is already running, watching for
+// warnings if warn is set.
+func (t *turnTimer) start(color Stone, warn bool) {
+	if t.clock == nil || t.running {
+		return
+	}
+	t.view.Clocks(t.clock)
+	t.clock.Start(color, time.Now())
+	t.running = true
+	if warn && len(t.warnings) > 0 {
+		t.stop = make(chan struct{})
+		go clockWatch(t.clock, color, t.warnings, func(s ClockState) { t.view.TimeWarning(color, s) }, t.stop)
+	}
+}
+
+// expired reports whether color has run out of time, without charging
+// them for the move in progress.
+func (t *turnTimer) expired(color Stone) bool {
+	return t.clock != nil && t.clock.State(color, time.Now()).Flagged
+}
+
+// plan is the TimeManager's plan for color's next move on a board of the
+// given size, after moves moves.
+func (t *turnTimer) plan(color Stone, moves, size int) TimePlan {
+	return TimeManager{Period: t.clock.Period, Size: size}.Plan(t.clock.State(color, time.Now()), moves)
+}
+
+// moved stops color's clock after their move and returns it as SGF
+// records it.
+func (t *turnTimer) moved(color Stone) (seconds float64, periods int) {
+	if t.clock == nil {
+		return 0, 0
+	}
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	now := time.Now()
+	s := t.clock.State(color, now)
+	t.clock.Stop(now)
+	t.running = false
+	return s.SGFTimeLeft()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock(10*time.Second, 3, 30*time.Second)
+	t0 := time.Now()
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+
+	// 4s of main time.
+	c.Start(Black, at(0))
+	if c.Stop(at(4 * time.Second)) {
+		t.Fatal("flagged in main time")
+	}
+	if s := c.State(Black, at(time.Hour)); s.Main != 6*time.Second || s.Byoyomi {
+		t.Errorf("after 4s: %+v", s)
+	}
+
+	// 6s of main time and 20s into the first period, which then resets.
+	c.Start(Black, at(0))
+	s := c.State(Black, at(26*time.Second))
+	if !s.Byoyomi || s.Periods != 3 || s.PeriodLeft != 10*time.Second {
+		t.Errorf("26s into the move: %+v", s)
+	}
+	if s.String() != "3×0:10" {
+		t.Errorf("shown as %q", s.String())
+	}
+	c.Stop(at(26 * time.Second))
+	if s := c.State(Black, at(0)); s.Periods != 3 || s.PeriodLeft != 30*time.Second {
+		t.Errorf("after a move in byo-yomi: %+v", s)
+	}
+
+	// A 70s move uses up two periods; the next overrun loses.
+	c.Start(Black, at(0))
+	c.Stop(at(70 * time.Second))
+	if s := c.State(Black, at(0)); s.Periods != 1 {
+		t.Errorf("after a 70s move: %+v", s)
+	}
+	c.Start(Black, at(0))
+	if !c.Stop(at(30 * time.Second)) {
+		t.Error("not flagged after the last period")
+	}
+
+	// White's clock was never touched.
+	if s := c.State(White, at(time.Hour)); s.Main != 10*time.Second || s.Periods != 3 {
+		t.Errorf("white: %+v", s)
+	}
+}
+
+func TestParseByoyomi(t *testing.T) {
+	if n, d, err := parseByoyomi("5x30s"); err != nil || n != 5 || d != 30*time.Second {
+		t.Errorf("5x30s: %d, %v, %v", n, d, err)
+	}
+	for _, bad := range []string{"5", "x30s", "5x", "5x-1s", "ax1s"} {
+		if _, _, err := parseByoyomi(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
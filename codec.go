@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Compact binary encoding of positions and move sequences, used wherever
+// games are stored or sent. Format version 1:
+//
+//	header   "GC" magic, version byte, kind byte ('P' position, 'M' moves,
+//	         'O' opening book)
+//	position size byte, side-to-move byte, then runs over the points in
+//	         row-major order, one byte each: colour in the top two bits and
+//	         run length minus one in the low six bits.
+//	moves    size byte, uvarint move count, then each move packed MSB-first
+//	         into ceil(log2(size*size+1)) bits: 0 is a pass and i+1 is the
+//	         point with move index i. Colours alternate from the position.
+//	book     size byte, uvarint position count, then per position its
+//	         Zobrist hash as 8 bytes little-endian, a uvarint move count
+//	         and per move: uvarint move index, uvarint games, and the
+//	         uvarint wins and losses of the side to move. Kind 'B', the
+//	         book before, had the win rate in thousandths in place of
+//	         wins and losses; DecodeBook still reads it.
+//
+// Decoders reject any other version, so the format can evolve by bumping
+// CodecVersion, and return an error for any data they cannot decode,
+// whatever its length or content, as it may come from the network.
+const CodecVersion = 1
+
+const (
+	codecPosition  = 'P'
+	codecMoves     = 'M'
+	codecBook      = 'O'
+	codecBookRates = 'B'
+	maxRunLength   = 64
+)
+
+var errCodecTruncated = errors.New("codec: truncated data")
+
+func codecHeader(kind byte) []byte {
+	return []byte{'G', 'C', CodecVersion, kind}
+}
+
+func checkCodecHeader(data []byte, kind byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 'G' || data[1] != 'C' {
+		return nil, errors.New("codec: bad magic")
+	}
+	if data[2] != CodecVersion {
+		return nil, fmt.Errorf("codec: unsupported format version %d", data[2])
+	}
+	if data[3] != kind {
+		return nil, fmt.Errorf("codec: expected kind %q, got %q", kind, data[3])
+	}
+	return data[4:], nil
+}
+
+// EncodePosition encodes the stones and side to move of b.
+func EncodePosition(b *Board) []byte {
+	out := append(codecHeader(codecPosition), byte(b.size), byte(b.turn))
+	run, length := Empty, 0
+	flush := func() {
+		if length > 0 {
+			out = append(out, byte(run)<<6|byte(length-1))
+		}
+	}
+	for row := 0; row < b.size; row++ {
+		for col := 0; col < b.size; col++ {
+			s := b.grid[row][col]
+			if s != run || length == maxRunLength {
+				flush()
+				run, length = s, 0
+			}
+			length++
+		}
+	}
+	flush()
+	return out
+}
+
+func DecodePosition(data []byte) (*Board, error) {
+	data, err := checkCodecHeader(data, codecPosition)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, errCodecTruncated
+	}
+	size, turn := int(data[0]), Stone(data[1])
+	if size < 1 || size > MaxBoardSize || (turn != Black && turn != White) {
+		return nil, errors.New("codec: invalid position header")
+	}
+	b := NewBoard(size)
+	b.turn = turn
+	point := 0
+	for _, run := range data[2:] {
+		s, length := Stone(run>>6), int(run&0x3f)+1
+		if s > White || point+length > size*size {
+			return nil, errors.New("codec: invalid run")
+		}
+		for ; length > 0; length-- {
+			b.setPoint(point, s)
+			point++
+		}
+	}
+	if point != size*size {
+		return nil, errCodecTruncated
+	}
+	return b, nil
+}
+
+// moveBits is the number of bits needed for one move on a size x size board.
+func moveBits(size int) int {
+	bits := 0
+	for n := size * size; n > 0; n >>= 1 {
+		bits++
+	}
+	return bits
+}
+
+// EncodeMoves packs a sequence of move indexes (or PassMove).
+func EncodeMoves(size int, moves []int) []byte {
+	out := append(codecHeader(codecMoves), byte(size))
+	out = binary.AppendUvarint(out, uint64(len(moves)))
+	bits := moveBits(size)
+	var acc uint64
+	n := 0
+	for _, m := range moves {
+		acc = acc<<bits | uint64(m+1)
+		n += bits
+		for n >= 8 {
+			n -= 8
+			out = append(out, byte(acc>>n))
+		}
+	}
+	if n > 0 {
+		out = append(out, byte(acc<<(8-n)))
+	}
+	return out
+}
+
+func DecodeMoves(data []byte) (size int, moves []int, err error) {
+	data, err = checkCodecHeader(data, codecMoves)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 1 {
+		return 0, nil, errCodecTruncated
+	}
+	size = int(data[0])
+	if size < 1 || size > MaxBoardSize {
+		return 0, nil, fmt.Errorf("codec: invalid board size %d", size)
+	}
+	count, k := binary.Uvarint(data[1:])
+	if k <= 0 {
+		return 0, nil, errCodecTruncated
+	}
+	data = data[1+k:]
+	// The count comes from the data, so it is checked against the bits
+	// there are before anything is allocated for it.
+	bits := moveBits(size)
+	if count > uint64(len(data))*8/uint64(bits) {
+		return 0, nil, errCodecTruncated
+	}
+	moves = make([]int, 0, count)
+	var acc uint64
+	n := 0
+	for len(moves) < int(count) {
+		for n < bits {
+			acc = acc<<8 | uint64(data[0])
+			data = data[1:]
+			n += 8
+		}
+		n -= bits
+		v := int(acc>>n) & (1<<bits - 1)
+		if v > size*size {
+			return 0, nil, fmt.Errorf("codec: move %d out of range", v-1)
+		}
+		moves = append(moves, v-1)
+	}
+	return size, moves, nil
+}
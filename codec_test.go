@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPositionRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{9, 13, 19} {
+		b := NewBoard(size)
+		for i := 0; i < size*size; i++ {
+			b.Play(rng.Intn(size * size))
+		}
+		got, err := DecodePosition(EncodePosition(b))
+		if err != nil {
+			t.Fatalf("%dx%d: %v", size, size, err)
+		}
+		if !reflect.DeepEqual(got.grid, b.grid) || got.turn != b.turn {
+			t.Errorf("%dx%d: position changed in round trip", size, size)
+		}
+	}
+}
+
+func TestEmptyPositionIsSmall(t *testing.T) {
+	data := EncodePosition(NewBoard(19))
+	// header, size, turn and ceil(361/64) runs
+	if len(data) != 4+2+6 {
+		t.Errorf("empty 19x19 encodes to %d bytes", len(data))
+	}
+}
+
+func TestMovesRoundTrip(t *testing.T) {
+	for _, size := range []int{5, 9, 19} {
+		moves := []int{PassMove, 0, size*size - 1, PassMove}
+		for i := 0; i < 100; i++ {
+			moves = append(moves, (i*37)%(size*size))
+		}
+		data := EncodeMoves(size, moves)
+		gotSize, got, err := DecodeMoves(data)
+		if err != nil {
+			t.Fatalf("%dx%d: %v", size, size, err)
+		}
+		if gotSize != size || !reflect.DeepEqual(got, moves) {
+			t.Errorf("%dx%d: moves changed in round trip", size, size)
+		}
+		if max := 4 + 1 + 2 + (len(moves)*moveBits(size)+7)/8; len(data) > max {
+			t.Errorf("%dx%d: %d bytes, want at most %d", size, size, len(data), max)
+		}
+	}
+}
+
+func TestDecodeRejectsOtherVersions(t *testing.T) {
+	data := EncodeMoves(9, []int{1, 2, 3})
+	data[2] = CodecVersion + 1
+	if _, _, err := DecodeMoves(data); err == nil {
+		t.Error("decoded data with an unknown format version")
+	}
+	data[2] = CodecVersion
+	if _, _, err := DecodeMoves(data[:6]); err == nil {
+		t.Error("decoded truncated data")
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	header := func(kind byte, rest ...byte) []byte { return append(codecHeader(kind), rest...) }
+	// bookMove is a 9x9 book of one position with one move.
+	bookMove := func(kind byte, v ...uint64) []byte {
+		data := binary.LittleEndian.AppendUint64(binary.AppendUvarint(header(kind, 9), 1), 1)
+		data = binary.AppendUvarint(data, 1)
+		for _, x := range v {
+			data = binary.AppendUvarint(data, x)
+		}
+		return data
+	}
+	for name, data := range map[string][]byte{
+		"moves, size 0":                 header(codecMoves, 0, 1, 0xff),
+		"moves, size too large":         header(codecMoves, MaxBoardSize+1, 1, 0xff, 0xff),
+		"moves, count of 1<<40":         binary.AppendUvarint(header(codecMoves, 0), 1<<40),
+		"moves, count of 1<<40 on 9":    binary.AppendUvarint(header(codecMoves, 9), 1<<40),
+		"moves, largest count":          binary.AppendUvarint(header(codecMoves, 19), 1<<64-1),
+		"moves, bad uvarint":            header(codecMoves, 9, 0xff, 0xff),
+		"position, size 0":              header(codecPosition, 0, byte(Black)),
+		"position, size too large":      header(codecPosition, 200, byte(Black), 0x3f),
+		"book, size 0":                  header(codecBook, 0, 1),
+		"book, count of 1<<40":          binary.AppendUvarint(header(codecBook, 9), 1<<40),
+		"book, more wins than games":    bookMove(codecBook, 40, 3, 4, 0),
+		"book, more results than games": bookMove(codecBook, 40, 3, 2, 2),
+		"book, wins wrapping":           bookMove(codecBook, 40, 3, 2, 1<<64-1),
+		"old book, win rate over 1":     bookMove(codecBookRates, 40, 3, 1500),
+	} {
+		var err error
+		switch data[3] {
+		case codecMoves:
+			_, _, err = DecodeMoves(data)
+		case codecPosition:
+			_, err = DecodePosition(data)
+		case codecBook, codecBookRates:
+			_, err = DecodeBook(data)
+		}
+		if err == nil {
+			t.Errorf("%s: decoded", name)
+		}
+	}
+
+	// Any prefix of good data, and good data with random bytes changed,
+	// either decodes or fails; none panics.
+	rng := rand.New(rand.NewSource(1))
+	moves := EncodeMoves(9, []int{40, 30, PassMove, 50, 20})
+	b := NewBoard(9)
+	b.Play(40)
+	position := EncodePosition(b)
+	book := newOpeningBook(9)
+	book.positions[1] = []BookMove{{Move: 40, Games: 3, Wins: 1, Losses: 1}}
+	for _, good := range [][]byte{moves, position, EncodeBook(book)} {
+		for i := 0; i < 1000; i++ {
+			data := append([]byte(nil), good[:rng.Intn(len(good)+1)]...)
+			if i%2 == 1 {
+				data = append([]byte(nil), good...)
+				for j := rng.Intn(3) + 1; j > 0; j-- {
+					data[4+rng.Intn(len(data)-4)] = byte(rng.Intn(256))
+				}
+			}
+			DecodeMoves(data)
+			DecodePosition(data)
+			DecodeBook(data)
+		}
+	}
+}
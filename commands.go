@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// commands maps subcommand names to their entry points. Feature files
+// register themselves from init so main only has to dispatch.
+var commands = map[string]func(args []string) error{}
+
+// dataPath returns the location of a file in the per-user data directory,
+// which is $GOGAME_HOME if set and ~/.gogame otherwise.
+func dataPath(name string) string {
+	dir := os.Getenv("GOGAME_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		dir = filepath.Join(home, ".gogame")
+	}
+	return filepath.Join(dir, name)
+}
+
+// loadJSON decodes path into v. A missing file leaves v untouched.
+func loadJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveJSON writes v to path via a temporary file so a crash never leaves a
+// half-written file behind.
+func saveJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return saveFile(path, data)
+}
+
+// saveFile writes data to path the same way, creating its directory.
+func saveFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
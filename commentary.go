@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"time"
+)
+
+func init() {
+	commands["commentary"] = runCommentary
+}
+
+// swingShown is the change in the mover's win rate, beyond the engine's
+// own estimate, that the commentary calls out even for unmarked moves.
+const swingShown = 0.1
+
+// Commentator follows a game move by move, analysing each position as it
+// arrives. The moves go into Record with the commentary as comments and
+// the moves that stand out marked, as AnnotateGame does for a whole game.
+type Commentator struct {
+	Record *GameRecord
+	engine *Engine
+	board  *Board
+	before MoveAnalysis // of the current position
+}
+
+// NewCommentator analyses the start of rec, whose moves are left to Play.
+func NewCommentator(ctx context.Context, e *Engine, rec *GameRecord) (*Commentator, error) {
+	e.Komi = rec.Komi
+	c := &Commentator{Record: rec, engine: e, board: rec.Start()}
+	var err error
+	if c.before, err = e.Analyze(ctx, c.board); err != nil {
+		return nil, err
+	}
+	rec.Moves, rec.Analysis = nil, []MoveAnalysis{c.before}
+	return c, nil
+}
+
+// Play adds m to the game, analyses the position after it and returns the
+// commentary line for it.
+func (c *Commentator) Play(ctx context.Context, m RecordedMove) (string, error) {
+	n := len(c.Record.Moves) + 1
+	c.board.turn = m.Color
+	if !c.board.Play(m.Move) {
+		return "", fmt.Errorf("move %d (%s) is illegal", n, formatMove(m.Move, c.Record.Size))
+	}
+	a, err := c.engine.Analyze(ctx, c.board)
+	if err != nil {
+		return "", err
+	}
+	a.MoveNumber = n
+	before, after := c.before, 1-a.WinRate
+	c.before = a
+
+	q, emphasis := m.Quality()
+	if q == NoQuality && m.Move != PassMove {
+		q, emphasis = judgeMove(before, m.Move, after)
+		m.SetQuality(q, emphasis)
+	}
+	m.Comment = appendComment(m.Comment, moveNote(before, a, q, c.Record.Size))
+	c.Record.Moves = append(c.Record.Moves, m)
+	c.Record.Analysis = append(c.Record.Analysis, a)
+
+	line := fmt.Sprintf("%3d %s %-7s %3.0f%% → %3.0f%%", n, m.Color, moveMark(m, c.Record.Size), 100*before.WinRate, 100*after)
+	switch {
+	case q == BadMove || q == DoubtfulMove:
+		line += "  " + qualityName(q, emphasis)
+		if len(before.Candidates) > 0 {
+			best := before.Candidates[0]
+			line += fmt.Sprintf("; %s was better (%.0f%%)", userCoords.Move(best.Move, c.Record.Size), 100*best.WinRate)
+		}
+	case q != NoQuality:
+		line += "  " + qualityName(q, emphasis)
+	case after-before.WinRate >= swingShown:
+		line += fmt.Sprintf("  swing of %+.0f%%", 100*(after-before.WinRate))
+	}
+	return line, nil
+}
+
+// commentFile comments on the moves of g as if they arrived one every
+// delay.
+func commentFile(ctx context.Context, e *Engine, g *GameRecord, delay time.Duration) (*GameRecord, error) {
+	rec := *g
+	c, err := NewCommentator(ctx, e, &rec)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("%s vs %s, komi %.1f\n", playerName(g.Black, "Black"), playerName(g.White, "White"), g.Komi)
+	for _, m := range g.Moves {
+		line, err := c.Play(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(line)
+		time.Sleep(delay)
+	}
+	return c.Record, nil
+}
+
+// commentLive watches the game served at addr (see netgame.go) and
+// comments on each move as it is played.
+func commentLive(ctx context.Context, e *Engine, addr string, komi float64) (*GameRecord, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var c *Commentator
+	events := bufio.NewScanner(conn)
+	for events.Scan() {
+		var ev pipeEvent
+		if err := json.Unmarshal(events.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("server: %v", err)
+		}
+		switch ev.Event {
+		case "start":
+			rec := &GameRecord{Size: ev.Size, Komi: komi, Date: time.Now().Format("2006-01-02")}
+			if c, err = NewCommentator(ctx, e, rec); err != nil {
+				return nil, err
+			}
+			fmt.Printf("Watching a %dx%d game on %s\n", ev.Size, ev.Size, addr)
+		case "move":
+			if c == nil {
+				return nil, errors.New("server: move before start")
+			}
+			move, err := parseMoveInput(ev.Move, c.Record.Size)
+			if err != nil {
+				return nil, fmt.Errorf("server: %v", err)
+			}
+			color := map[string]Stone{"black": Black, "white": White}[ev.Color]
+			line, err := c.Play(ctx, RecordedMove{Color: color, Move: move})
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println(line)
+		case "end":
+			if c == nil {
+				return nil, errors.New("server: game ended before it started")
+			}
+			c.Record.Result = ev.Result
+			fmt.Printf("Result: %s\n", ev.Result)
+			return c.Record, nil
+		}
+	}
+	return nil, errors.New("connection closed")
+}
+
+func runCommentary(args []string) error {
+	fs := flag.NewFlagSet("commentary", flag.ExitOnError)
+	watch := fs.String("watch", "", "watch the game served at this address instead of reading a file")
+	out := fs.String("o", "", "SGF file for the commented game (default: the SGF name with -commentary, or commentary.sgf)")
+	playouts := fs.Int("playouts", 2000, "playouts per position")
+	delay := fs.Duration("delay", 0, "pause between the moves of a file")
+	komi := fs.Float64("komi", 6.5, "komi of a watched game")
+	game := fs.Int("game", 1, "game number within the file")
+	fs.Parse(args)
+	var path string
+	if *watch == "" {
+		if fs.NArg() == 0 {
+			return errors.New("usage: commentary file.sgf [-o out.sgf] [-playouts N] [-delay 2s] [-game N], or commentary -watch host:port [-komi K]")
+		}
+		path = fs.Arg(0)
+		fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	}
+
+	ctx := context.Background()
+	e := NewEngine(*playouts, *komi)
+	var rec *GameRecord
+	if *watch != "" {
+		var err error
+		if rec, err = commentLive(ctx, e, *watch, *komi); err != nil {
+			return err
+		}
+		if *out == "" {
+			*out = "commentary.sgf"
+		}
+	} else {
+		games, err := ReadSGFFile(path)
+		if err != nil {
+			return err
+		}
+		if *game < 1 || *game > len(games) {
+			return fmt.Errorf("%s has %d games", path, len(games))
+		}
+		g, err := RecordFromSGF(games[*game-1])
+		if err != nil {
+			return err
+		}
+		if rec, err = commentFile(ctx, e, g, *delay); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if *out == "" {
+			*out = trimExt(path) + "-commentary.sgf"
+		}
+	}
+	if err := WriteSGFFile(*out, []*SGFNode{rec.SGF()}); err != nil {
+		return err
+	}
+	fmt.Printf("Commentary written to %s\n", *out)
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommentator(t *testing.T) {
+	ctx := context.Background()
+	rec := &GameRecord{Size: 5, Komi: 0.5}
+	c, err := NewCommentator(ctx, NewEngine(200, 0), rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves := []RecordedMove{{Color: Black, Move: 12}, {Color: White, Move: 0, Comment: "A first-line move"}, {Color: Black, Move: PassMove}}
+	for i, m := range moves {
+		line, err := c.Play(ctx, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(line, "%") || !strings.Contains(line, m.Color.String()) {
+			t.Errorf("move %d: line %q", i+1, line)
+		}
+	}
+	if len(rec.Moves) != 3 || len(rec.Analysis) != 4 || rec.Analysis[3].MoveNumber != 3 {
+		t.Fatalf("%d moves and %d analyses recorded", len(rec.Moves), len(rec.Analysis))
+	}
+	for i, m := range rec.Moves {
+		if !strings.Contains(m.Comment, "Win rate") {
+			t.Errorf("move %d comment %q", i+1, m.Comment)
+		}
+	}
+	if !strings.HasPrefix(rec.Moves[1].Comment, "A first-line move\n") {
+		t.Errorf("recorded comment lost: %q", rec.Moves[1].Comment)
+	}
+	if q, _ := rec.Moves[2].Quality(); q != NoQuality {
+		t.Errorf("pass marked %s", q)
+	}
+	if _, err := c.Play(ctx, RecordedMove{Color: White, Move: 12}); err == nil {
+		t.Error("move on an occupied point accepted")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coordinate systems for points of the board. Each user picks one with
+// -coords or "coordinates" in the config file (see engineprofile.go), and
+// "coords NAME" at the move prompt changes it during a game. It labels the
+// board in the terminal and in SVG exports, reads the moves typed in, and
+// writes the moves the program shows people: in messages, in the comments
+// it adds to SGF files and in the notes of diagrams. Protocols, GTP, -pipe
+// and network games, keep their own coordinates.
+//
+//	index     3 4     row then column from the top left, from 0 (the default)
+//	western   E16     columns A to T without I from the left, rows from the bottom, as GTP
+//	numeric   5-4     column then row from the top left, from 1
+//	japanese  １５四  columns in full-width digits from the right, rows in kanji from the top, as in kifu
+//
+// Japanese boards in the terminal are labelled with ASCII digits for the
+// columns, which keeps them to a point's width.
+
+// Coords is a coordinate system.
+type Coords int
+
+const (
+	IndexCoords Coords = iota
+	WesternCoords
+	NumericCoords
+	JapaneseCoords
+)
+
+var coordsNames = []string{"index", "western", "numeric", "japanese"}
+
+// userCoords are the coordinates this process shows and reads.
+var userCoords = IndexCoords
+
+func (c Coords) String() string {
+	if int(c) < len(coordsNames) {
+		return coordsNames[c]
+	}
+	return fmt.Sprintf("Coords(%d)", int(c))
+}
+
+// ParseCoords looks up a coordinate system by name.
+func ParseCoords(name string) (Coords, error) {
+	for i, n := range coordsNames {
+		if strings.EqualFold(name, n) {
+			return Coords(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown coordinates %q: use %s", name, strings.Join(coordsNames, ", "))
+}
+
+// loadUserCoords sets userCoords from the config file at path, if it has
+// them.
+func loadUserCoords(path string) error {
+	var config gogameConfig
+	if err := loadJSON(path, &config); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if config.Coordinates == "" {
+		return nil
+	}
+	c, err := ParseCoords(config.Coordinates)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	userCoords = c
+	return nil
+}
+
+// Column labels column col of a board of size in the board's margin.
+func (c Coords) Column(col, size int) string {
+	switch c {
+	case WesternCoords:
+		return gtpColumn(col)
+	case NumericCoords:
+		return strconv.Itoa(col + 1)
+	case JapaneseCoords:
+		return strconv.Itoa(size - col)
+	}
+	return strconv.Itoa(col)
+}
+
+// Row labels row row of a board of size in the board's margin.
+func (c Coords) Row(row, size int) string {
+	switch c {
+	case WesternCoords:
+		return strconv.Itoa(size - row)
+	case NumericCoords:
+		return strconv.Itoa(row + 1)
+	case JapaneseCoords:
+		return kanjiNumber(row + 1)
+	}
+	return strconv.Itoa(row)
+}
+
+// Move writes move for people.
+func (c Coords) Move(move, size int) string {
+	if move == PassMove {
+		return "pass"
+	}
+	row, col := move/size, move%size
+	switch c {
+	case WesternCoords:
+		return gtpVertex(move, size)
+	case NumericCoords:
+		return fmt.Sprintf("%d-%d", col+1, row+1)
+	case JapaneseCoords:
+		return fullWidthNumber(size-col) + kanjiNumber(row+1)
+	}
+	return formatMove(move, size)
+}
+
+// Parse reads a move typed in c's coordinates; "pass" is PassMove.
+// Japanese moves may have の between the column and the row, and either
+// may be in ASCII digits.
+func (c Coords) Parse(s string, size int) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "pass") {
+		return PassMove, nil
+	}
+	var row, col int
+	switch c {
+	case WesternCoords:
+		m, err := parseGTPVertex(s, size)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a point such as D4", s)
+		}
+		return m, nil
+	case NumericCoords:
+		x, y, ok := strings.Cut(s, "-")
+		if !ok {
+			return 0, fmt.Errorf("%q is not a point such as 4-16, column then row", s)
+		}
+		var err1, err2 error
+		col, err1 = strconv.Atoi(strings.TrimSpace(x))
+		row, err2 = strconv.Atoi(strings.TrimSpace(y))
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("%q is not a point such as 4-16, column then row", s)
+		}
+		row, col = row-1, col-1
+	case JapaneseCoords:
+		digits := strings.IndexFunc(s, func(r rune) bool { return !isDigit(r) })
+		if digits <= 0 {
+			return 0, fmt.Errorf("%q is not a point such as ４四", s)
+		}
+		x, err1 := strconv.Atoi(asciiDigits(s[:digits]))
+		y, err2 := parseKanjiNumber(strings.TrimSpace(strings.TrimPrefix(s[digits:], "の")))
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("%q is not a point such as ４四", s)
+		}
+		row, col = y-1, size-x
+	default:
+		fields := strings.Fields(s)
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("%q is not a point such as 3 4, row then column", s)
+		}
+		var err1, err2 error
+		row, err1 = strconv.Atoi(fields[0])
+		col, err2 = strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("%q is not a point such as 3 4, row then column", s)
+		}
+	}
+	if row < 0 || row >= size || col < 0 || col >= size {
+		return 0, fmt.Errorf("%s is off the board", s)
+	}
+	return row*size + col, nil
+}
+
+// coordLabels are the labels of a board's columns and rows, the rows
+// right-aligned to the width of the widest.
+func coordLabels(size int) (cols, rows []string, rowWidth int) {
+	rowWidth = 2
+	for i := 0; i < size; i++ {
+		cols = append(cols, userCoords.Column(i, size))
+		rows = append(rows, userCoords.Row(i, size))
+		rowWidth = max(rowWidth, displayWidth(rows[i]))
+	}
+	for i, r := range rows {
+		rows[i] = strings.Repeat(" ", rowWidth-displayWidth(r)) + r
+	}
+	return cols, rows, rowWidth
+}
+
+var kanjiDigits = []rune("〇一二三四五六七八九")
+
+// kanjiNumber writes n, from 1 to 99, in kanji numerals: 四, 十, 十九,
+// 二十五.
+func kanjiNumber(n int) string {
+	var s []rune
+	if n >= 20 {
+		s = append(s, kanjiDigits[n/10])
+	}
+	if n >= 10 {
+		s = append(s, '十')
+	}
+	if n%10 != 0 || n < 10 {
+		s = append(s, kanjiDigits[n%10])
+	}
+	return string(s)
+}
+
+// parseKanjiNumber reads a number written by kanjiNumber, or in digits.
+func parseKanjiNumber(s string) (int, error) {
+	if n, err := strconv.Atoi(asciiDigits(s)); err == nil {
+		return n, nil
+	}
+	n, digit, tens := 0, -1, false
+	for _, r := range s {
+		d := kanjiDigit(r)
+		switch {
+		case r == '十' && !tens:
+			n, digit, tens = 10*max(digit, 1), -1, true
+		case d > 0 && digit < 0:
+			digit = d
+		default:
+			return 0, errors.New("not a kanji number")
+		}
+	}
+	if digit > 0 {
+		n += digit
+	}
+	if n == 0 {
+		return 0, errors.New("not a kanji number")
+	}
+	return n, nil
+}
+
+func kanjiDigit(r rune) int {
+	for i, k := range kanjiDigits {
+		if k == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// fullWidthNumber writes n in full-width digits.
+func fullWidthNumber(n int) string {
+	var sb strings.Builder
+	for _, d := range strconv.Itoa(n) {
+		sb.WriteRune(d - '0' + '０')
+	}
+	return sb.String()
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9' || r >= '０' && r <= '９'
+}
+
+// asciiDigits turns full-width digits in s into ASCII ones.
+func asciiDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+}
+
+// displayWidth is how many terminal columns s takes, the CJK characters
+// of Japanese coordinates taking two.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w++
+		if r >= 0x1100 && (r <= 0x115f || r >= 0x2e80 && r <= 0xa4cf || r >= 0xac00 && r <= 0xd7a3 ||
+			r >= 0xf900 && r <= 0xfaff || r >= 0xff00 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6) {
+			w++
+		}
+	}
+	return w
+}
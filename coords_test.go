@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestCoords(t *testing.T) {
+	// The 4-4 point in the top right corner of 19x19.
+	move := 3*19 + 15
+	for _, tc := range []struct {
+		coords Coords
+		text   string
+		inputs []string
+	}{
+		{IndexCoords, "3 15", []string{"3 15"}},
+		{WesternCoords, "Q16", []string{"Q16", "q16"}},
+		{NumericCoords, "16-4", []string{"16-4", "16 - 4"}},
+		{JapaneseCoords, "４四", []string{"４四", "4の四", "４の4"}},
+	} {
+		if got := tc.coords.Move(move, 19); got != tc.text {
+			t.Errorf("%s: move written %q, want %q", tc.coords, got, tc.text)
+		}
+		for _, in := range tc.inputs {
+			if got, err := tc.coords.Parse(in, 19); err != nil || got != move {
+				t.Errorf("%s: %q read as %d, %v; want %d", tc.coords, in, got, err, move)
+			}
+		}
+		if _, err := tc.coords.Parse("20 20", 19); err == nil {
+			t.Errorf("%s: 20 20 accepted", tc.coords)
+		}
+	}
+
+	for n, kanji := range map[int]string{1: "一", 10: "十", 19: "十九", 20: "二十", 25: "二十五"} {
+		if got := kanjiNumber(n); got != kanji {
+			t.Errorf("kanji %d: %q, want %q", n, got, kanji)
+		}
+		if got, err := parseKanjiNumber(kanji); got != n || err != nil {
+			t.Errorf("read %q: %d, %v", kanji, got, err)
+		}
+	}
+
+	defer func(c Coords) { userCoords = c }(userCoords)
+	userCoords = JapaneseCoords
+	lines := markedBoardLines(NewBoard(9), 80, 20, PassMove, nil)
+	if lines[0] != "   9 8 7 6 5 4 3 2 1" || lines[1] != "一 + + + + + + + + +" {
+		t.Errorf("japanese board:\n%s\n%s", lines[0], lines[1])
+	}
+}
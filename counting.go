@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	commands["count"] = runCount
+	builtinPlugins = append(builtinPlugins, Plugin{
+		Name:        "japanese",
+		Description: "territory scoring: surrounded points plus prisoners, with the count shown in tens",
+		Score: func(game *GameLog, komi float64) (Stone, float64) {
+			return newJapaneseCount(game.Board().Copy(), gameCaptures(game), komi).Result()
+		},
+		Explain: func(game *GameLog, komi float64) []string {
+			return newJapaneseCount(game.Board().Copy(), gameCaptures(game), komi).Steps()
+		},
+	})
+}
+
+// japaneseCount is a final position counted by Japanese rules, the way
+// players do it over the board: each side's prisoners are put back into
+// the other side's territory, the remaining territory is rearranged into
+// blocks of ten, and the blocks are compared. Every stone on the board is
+// taken to be alive.
+type japaneseCount struct {
+	board     *Board
+	owner     []Stone // territory owner per point, Empty for dame and stones
+	territory [3]int
+	prisoners [3]int // stones captured by each colour
+	komi      float64
+}
+
+func gameCaptures(game *GameLog) [3]int {
+	return [3]int{0, game.Captures(Black), game.Captures(White)}
+}
+
+func newJapaneseCount(b *Board, prisoners [3]int, komi float64) *japaneseCount {
+	c := &japaneseCount{board: b, owner: make([]Stone, b.size*b.size), prisoners: prisoners, komi: komi}
+	for _, r := range b.EmptyRegions() {
+		if o := r.Owner(); o != Empty {
+			for _, p := range r.Points {
+				c.owner[p] = o
+			}
+			c.territory[o] += r.Size()
+		}
+	}
+	return c
+}
+
+// remaining is color's territory once the other side's prisoners have
+// been put into it.
+func (c *japaneseCount) remaining(color Stone) int {
+	return c.territory[color] - c.prisoners[color.Opponent()]
+}
+
+// Result is the winner and margin, komi included.
+func (c *japaneseCount) Result() (Stone, float64) {
+	diff := float64(c.remaining(Black)-c.remaining(White)) - c.komi
+	switch {
+	case diff > 0:
+		return Black, diff
+	case diff < 0:
+		return White, -diff
+	}
+	return Empty, 0
+}
+
+// Steps walks through the count for teaching: the territory, the
+// prisoners filling it, the territory in tens, and the result.
+func (c *japaneseCount) Steps() []string {
+	filled := c.fill()
+	steps := []string{
+		fmt.Sprintf("Step 1, territory: Black surrounds %d points (b), White %d (w); points marked · belong to neither.\n%s",
+			c.territory[Black], c.territory[White], c.diagram(nil)),
+		fmt.Sprintf("Step 2, prisoners: Black's %d prisoners are put into White's territory and White's %d into Black's (marked *).\n%s",
+			c.prisoners[Black], c.prisoners[White], c.diagram(filled)),
+	}
+	var tens strings.Builder
+	tens.WriteString("Step 3, counting in tens: the remaining territory is rearranged into blocks of ten.")
+	for _, color := range []Stone{Black, White} {
+		fmt.Fprintf(&tens, "\n%s: %s\n%s", colorName(color), countSum(c.remaining(color)), tenBlocks(c.remaining(color)))
+	}
+	steps = append(steps, tens.String())
+
+	winner, margin := c.Result()
+	result := fmt.Sprintf("Step 4, result: Black %d, White %d", c.remaining(Black), c.remaining(White))
+	if c.komi != 0 {
+		result += fmt.Sprintf(" + %g komi = %g", c.komi, float64(c.remaining(White))+c.komi)
+	}
+	if winner == Empty {
+		result += ": jigo."
+	} else {
+		result += fmt.Sprintf(": %s wins by %g (%s).", colorName(winner), margin, resultString(winner, margin))
+	}
+	return append(steps, result)
+}
+
+// fill picks the territory points the prisoners go into, from the first in
+// row-major order.
+func (c *japaneseCount) fill() map[int]bool {
+	filled := make(map[int]bool)
+	for _, color := range []Stone{Black, White} {
+		n := c.prisoners[color.Opponent()]
+		for p := 0; p < len(c.owner) && n > 0; p++ {
+			if c.owner[p] == color {
+				filled[p] = true
+				n--
+			}
+		}
+	}
+	return filled
+}
+
+func (c *japaneseCount) diagram(filled map[int]bool) string {
+	var sb strings.Builder
+	for row := 0; row < c.board.size; row++ {
+		for col := 0; col < c.board.size; col++ {
+			p := row*c.board.size + col
+			mark := "·"
+			switch {
+			case c.board.grid[row][col] != Empty:
+				mark = c.board.grid[row][col].String()
+			case filled[p]:
+				mark = "*"
+			case c.owner[p] == Black:
+				mark = "b"
+			case c.owner[p] == White:
+				mark = "w"
+			}
+			if col > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(mark)
+		}
+		if row < c.board.size-1 {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// countSum writes n as its tens, e.g. "10 + 10 + 4 = 24".
+func countSum(n int) string {
+	if n <= 10 {
+		return fmt.Sprintf("= %d", n)
+	}
+	var parts []string
+	for left := n; left > 0; left -= 10 {
+		parts = append(parts, fmt.Sprint(min(left, 10)))
+	}
+	return fmt.Sprintf("%s = %d", strings.Join(parts, " + "), n)
+}
+
+// tenBlocks draws n points as blocks of two rows of five, as territory is
+// shaped for counting.
+func tenBlocks(n int) string {
+	if n <= 0 {
+		return "  (none)"
+	}
+	var top, bottom []string
+	for left := n; left > 0; left -= 10 {
+		k := min(left, 10)
+		top = append(top, strings.Repeat("■", min(k, 5))+strings.Repeat(" ", 5-min(k, 5)))
+		bottom = append(bottom, strings.Repeat("■", max(k-5, 0))+strings.Repeat(" ", 5-max(k-5, 0)))
+	}
+	return "  " + strings.TrimRight(strings.Join(top, " "), " ") + "\n  " + strings.TrimRight(strings.Join(bottom, " "), " ")
+}
+
+// recordCaptures replays g and counts the stones each colour captured.
+func recordCaptures(g *GameRecord) (*Board, [3]int, error) {
+	var captured [3]int
+	b := g.Start()
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		before := b.stoneCount(m.Color.Opponent())
+		if !b.Play(m.Move) {
+			return nil, captured, fmt.Errorf("move %d is illegal", i+1)
+		}
+		captured[m.Color] += before - b.stoneCount(m.Color.Opponent())
+	}
+	return b, captured, nil
+}
+
+func runCount(args []string) error {
+	fs := flag.NewFlagSet("count", flag.ExitOnError)
+	game := fs.Int("game", 1, "game number within the file")
+	step := fs.Bool("step", false, "wait for Enter between the steps")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: count file.sgf [-step] [-game N]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	b, captured, err := recordCaptures(g)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	in := bufio.NewScanner(os.Stdin)
+	for i, s := range newJapaneseCount(b, captured, g.Komi).Steps() {
+		if i > 0 {
+			fmt.Println()
+			if *step {
+				fmt.Print("(Enter for the next step) ")
+				in.Scan()
+			}
+		}
+		fmt.Println(s)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJapaneseCount(t *testing.T) {
+	// Black walls off the left two columns, White the right two; the
+	// middle column is dame.
+	b := NewBoard(5)
+	for row := 0; row < 5; row++ {
+		b.grid[row][1] = Black
+		b.grid[row][3] = White
+	}
+	c := newJapaneseCount(b, [3]int{0, 2, 1}, 0.5)
+	if c.territory[Black] != 5 || c.territory[White] != 5 {
+		t.Fatalf("territory %v", c.territory)
+	}
+	// Black: 5 - 1 = 4, White: 5 - 2 = 3, plus komi.
+	if winner, margin := c.Result(); winner != Black || margin != 0.5 {
+		t.Errorf("result %s by %g", winner, margin)
+	}
+	steps := c.Steps()
+	if len(steps) != 4 {
+		t.Fatalf("%d steps", len(steps))
+	}
+	if !strings.Contains(steps[1], "* ● · ○ *") || strings.Count(steps[1][strings.Index(steps[1], "\n"):], "*") != 3 {
+		t.Errorf("prisoners not filled in:\n%s", steps[1])
+	}
+	if !strings.HasSuffix(steps[3], "(B+0.5).") {
+		t.Errorf("result step %q", steps[3])
+	}
+
+	if got := countSum(24); got != "10 + 10 + 4 = 24" {
+		t.Errorf("countSum(24) = %q", got)
+	}
+	if got := tenBlocks(17); got != "  ■■■■■ ■■■■■\n  ■■■■■ ■■" {
+		t.Errorf("tenBlocks(17) = %q", got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["db"] = runDB
+}
+
+// Game database maintenance. db export writes the whole database as a
+// bundle, a zip file with a manifest and one SGF file a game, which any
+// gogame reads back with db import whatever schema either database has,
+// and other programs can read too:
+//
+//	manifest.json  {"format": "gogame-db", "version": 1, "exported": "...",
+//	                "games": [{"id": "ogs:123", "source": "ogs", "file": "games/1.sgf"}, ...]}
+//	games/1.sgf
+//
+// db migrate rewrites a database file in the current schema, keeping the
+// old file next to it. Export and import work with any store (see
+// store.go), which makes them the way to move games from one to another.
+
+const (
+	dbBundleFormat  = "gogame-db"
+	dbBundleVersion = 1
+)
+
+type dbBundle struct {
+	Format   string         `json:"format"`
+	Version  int            `json:"version"`
+	Exported string         `json:"exported"`
+	Games    []dbBundleGame `json:"games"`
+}
+
+type dbBundleGame struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	File   string `json:"file"`
+}
+
+// exportDB writes games as a bundle to w.
+func exportDB(games []DBGame, w io.Writer, now time.Time) error {
+	z := zip.NewWriter(w)
+	manifest := dbBundle{Format: dbBundleFormat, Version: dbBundleVersion, Exported: now.UTC().Format(time.RFC3339)}
+	for i := range games {
+		g := &games[i]
+		rec, err := g.Record()
+		if err != nil {
+			return err
+		}
+		file := fmt.Sprintf("games/%d.sgf", i+1)
+		f, err := z.Create(file)
+		if err != nil {
+			return err
+		}
+		if err := WriteSGF(f, []*SGFNode{rec.SGF()}); err != nil {
+			return err
+		}
+		manifest.Games = append(manifest.Games, dbBundleGame{ID: g.ID, Source: g.Source, File: file})
+	}
+	f, err := z.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// importDB adds the games of the bundle data to db, skipping those it has.
+func importDB(db GameStore, data []byte) (added, skipped int, err error) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, 0, err
+	}
+	read := func(name string) ([]byte, error) {
+		f, err := z.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	raw, err := read("manifest.json")
+	if err != nil {
+		return 0, 0, err
+	}
+	var manifest dbBundle
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return 0, 0, fmt.Errorf("manifest.json: %v", err)
+	}
+	if manifest.Format != dbBundleFormat {
+		return 0, 0, errors.New("not a gogame database bundle")
+	}
+	if manifest.Version > dbBundleVersion {
+		return 0, 0, fmt.Errorf("bundle version %d is newer than this gogame reads (%d)", manifest.Version, dbBundleVersion)
+	}
+	for _, entry := range manifest.Games {
+		data, err := read(entry.File)
+		if err != nil {
+			return added, skipped, err
+		}
+		games, err := ParseSGF(bytes.NewReader(data))
+		if err != nil {
+			return added, skipped, fmt.Errorf("%s: %v", entry.File, err)
+		}
+		if len(games) != 1 {
+			return added, skipped, fmt.Errorf("%s: want one game, found %d", entry.File, len(games))
+		}
+		g, err := RecordFromSGF(games[0])
+		if err != nil {
+			return added, skipped, fmt.Errorf("%s: %v", entry.File, err)
+		}
+		if ok, err := db.Add(entry.ID, entry.Source, g); err != nil {
+			return added, skipped, err
+		} else if ok {
+			added++
+		} else {
+			skipped++
+		}
+	}
+	return added, skipped, nil
+}
+
+func runDB(args []string) error {
+	usage := errors.New("usage: db info | db migrate | db export -o bundle.zip | db import bundle.zip...")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("db "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	out := fs.String("o", "", "with export, the bundle to write")
+	fs.Parse(args[1:])
+	store, err := OpenStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	// db is the store's games when it is a file read whole, and save
+	// rewrites the file.
+	db, _ := store.(*GameDB)
+	save := func() error { return db.Save() }
+	if b, ok := store.(*boltStore); ok {
+		db, save = b.GameDB, b.Save
+	}
+	switch args[0] {
+	case "info":
+		games, err := store.Games()
+		if err != nil {
+			return err
+		}
+		sources := map[string]int{}
+		for _, g := range games {
+			sources[g.Source]++
+		}
+		names := make([]string, 0, len(sources))
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("%s: %d games, schema version %d", store, len(games), gameDBVersion)
+		if db != nil && db.read != 0 {
+			fmt.Printf(" (the file has version %d; db migrate rewrites it)", db.read)
+		}
+		fmt.Println()
+		for _, name := range names {
+			fmt.Printf("  %-8s %d\n", name, sources[name])
+		}
+		return nil
+	case "migrate":
+		if db == nil || db.path == "" {
+			return fmt.Errorf("%s: only database files are migrated by hand; the others migrate when opened", store)
+		}
+		if db.read == 0 {
+			fmt.Printf("%s: already at schema version %d\n", db.path, db.Version)
+			return nil
+		}
+		old, err := os.ReadFile(db.path)
+		if err != nil {
+			return err
+		}
+		backup := fmt.Sprintf("%s.v%d", db.path, db.read)
+		if err := os.WriteFile(backup, old, 0o644); err != nil {
+			return err
+		}
+		if err := save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: migrated from schema version %d to %d; the old file is %s\n", db.path, db.read, db.Version, backup)
+		return nil
+	case "export":
+		if *out == "" || fs.NArg() != 0 {
+			return usage
+		}
+		games, err := store.Games()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := exportDB(games, &buf, time.Now()); err != nil {
+			return err
+		}
+		if err := saveFile(*out, buf.Bytes()); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d games to %s\n", len(games), *out)
+		return nil
+	case "import":
+		if fs.NArg() == 0 {
+			return usage
+		}
+		for _, path := range fs.Args() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			added, skipped, err := importDB(store, data)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			fmt.Printf("%s: added %d games, skipped %d already present\n", path, added, skipped)
+		}
+		return store.Close()
+	}
+	return usage
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGameDBMigrateAndBundle(t *testing.T) {
+	// A version 1 file, from before versioning, with an OGS game whose
+	// two free handicap stones were stored as alternating moves.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "games.json")
+	v1 := &GameDB{Entries: []DBGame{{
+		ID: "ogs:1", Source: "ogs", Size: 9, Komi: 0.5, Handicap: 2,
+		Position: EncodePosition(NewBoard(9)), Moves: EncodeMoves(9, []int{20, 60, 40, 30}),
+	}}}
+	if err := saveJSON(path, v1); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenGameDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != gameDBVersion || db.read != 1 {
+		t.Fatalf("version %d, read %d", db.Version, db.read)
+	}
+	rec, err := db.Entries[0].Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var colors []Stone
+	for _, m := range rec.Moves {
+		colors = append(colors, m.Color)
+	}
+	if want := []Stone{Black, Black, White, Black}; !reflect.DeepEqual(colors, want) {
+		t.Fatalf("colours after migration = %v", colors)
+	}
+
+	os.WriteFile(path, []byte(`{"version": 99, "games": []}`), 0o644)
+	if _, err := OpenGameDB(path); err == nil {
+		t.Error("opened a database from a newer gogame")
+	}
+
+	// A bundle carries the games, colours and all, to another database.
+	sgf, err := ParseSGF(bytes.NewReader([]byte("(;SZ[9]PB[alice]RE[W+R];B[cc];W[gg])")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := RecordFromSGF(sgf[0])
+	db.Add("sgf:a.sgf", "sgf", g)
+	var bundle bytes.Buffer
+	if err := exportDB(db.Entries, &bundle, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	other := newMemoryDB()
+	if added, skipped, err := importDB(other, bundle.Bytes()); err != nil || added != 2 || skipped != 0 {
+		t.Fatalf("import: added %d, skipped %d, %v", added, skipped, err)
+	}
+	if added, skipped, _ := importDB(other, bundle.Bytes()); added != 0 || skipped != 2 {
+		t.Errorf("import again: added %d, skipped %d", added, skipped)
+	}
+	for i := range db.Entries {
+		want, got := db.Entries[i], other.Entries[i]
+		if want.ID != got.ID || !bytes.Equal(want.Moves, got.Moves) || want.Colors != got.Colors || want.Black != got.Black || want.Result != got.Result {
+			t.Errorf("game %d: %+v, want %+v", i, got, want)
+		}
+	}
+}
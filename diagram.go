@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["diagram"] = runDiagram
+}
+
+// Plain-text diagrams in the notation of Sensei's Library and GoWrite,
+// for forums and email:
+//
+//	$$Bcm11 Moves 11-20
+//	$$ +-------------------+
+//	$$ | . . . . . . . . . |
+//	$$ | . . X 1 . . O . . |
+//	...
+//
+// X and O are stones already on the board, digits 1 to 9 and 0 the next
+// ten moves, the first of them by the colour after $$, and , a star
+// point. Moves that cannot be shown where they were played, on a point
+// already numbered or occupied, or passes, are listed under the diagram.
+
+// movesPerDiagram is the most moves one diagram can number.
+const movesPerDiagram = 10
+
+// DiagramOptions controls a diagram. Region limits it to part of the
+// board, the zero Rect meaning the whole of it; only sides on the edge of
+// the board are drawn. Coordinates asks the renderer for coordinates.
+type DiagramOptions struct {
+	Region      Rect
+	Coordinates bool
+	Title       string
+}
+
+// WriteDiagram writes b with moves, up to ten alternating moves from b,
+// numbered from first.
+func WriteDiagram(w io.Writer, b BoardView, moves []RecordedMove, first int, opts DiagramOptions) error {
+	size := b.Size()
+	if len(moves) > movesPerDiagram {
+		return fmt.Errorf("a diagram numbers at most %d moves", movesPerDiagram)
+	}
+	toMove := b.Turn()
+	if len(moves) > 0 {
+		toMove = moves[0].Color
+	}
+	labels := map[int]int{} // point to 1 + offset of the move numbered there
+	var notes []string
+	board := b.Copy()
+	for i, m := range moves {
+		n := first + i
+		if i%2 == 0 && m.Color != toMove || i%2 == 1 && m.Color != toMove.Opponent() {
+			return fmt.Errorf("move %d is not in turn; the moves of a diagram must alternate", n)
+		}
+		switch {
+		case m.Move == PassMove:
+			notes = append(notes, fmt.Sprintf("%d pass", n))
+		case labels[m.Move] != 0:
+			notes = append(notes, fmt.Sprintf("%d at %d", n, first+labels[m.Move]-1))
+		case b.At(m.Move/size, m.Move%size) != Empty:
+			notes = append(notes, fmt.Sprintf("%d at %s", n, gtpVertex(m.Move, size)))
+		default:
+			labels[m.Move] = i + 1
+		}
+		board.turn = m.Color
+		if !board.Play(m.Move) {
+			return fmt.Errorf("move %d is illegal", n)
+		}
+	}
+
+	r := opts.Region
+	if r == (Rect{}) {
+		r = Rect{Bottom: size - 1, Right: size - 1}
+	}
+	r = Rect{Top: max(r.Top, 0), Left: max(r.Left, 0), Bottom: min(r.Bottom, size-1), Right: min(r.Right, size-1)}
+	if r.Top > r.Bottom || r.Left > r.Right {
+		return errors.New("empty diagram region")
+	}
+	stars := map[int]bool{}
+	for _, p := range starPoints(size) {
+		stars[p] = true
+	}
+
+	header := "$$" + map[Stone]string{Black: "B", White: "W"}[toMove]
+	if opts.Coordinates {
+		header += "c"
+	}
+	if first != 1 && len(moves) > 0 {
+		header += "m" + strconv.Itoa(first)
+	}
+	if opts.Title != "" {
+		header += " " + opts.Title
+	}
+	lines := []string{header}
+	border := func() string {
+		line := strings.Repeat("-", 2*(r.Right-r.Left)+1)
+		if r.Left == 0 {
+			line = "+-" + line
+		}
+		if r.Right == size-1 {
+			line += "-+"
+		}
+		return "$$ " + line
+	}
+	if r.Top == 0 {
+		lines = append(lines, border())
+	}
+	for row := r.Top; row <= r.Bottom; row++ {
+		cells := make([]string, 0, r.Right-r.Left+3)
+		if r.Left == 0 {
+			cells = append(cells, "|")
+		}
+		for col := r.Left; col <= r.Right; col++ {
+			p := row*size + col
+			switch {
+			case labels[p] != 0:
+				cells = append(cells, strconv.Itoa(labels[p]%10))
+			case b.At(row, col) == Black:
+				cells = append(cells, "X")
+			case b.At(row, col) == White:
+				cells = append(cells, "O")
+			case stars[p]:
+				cells = append(cells, ",")
+			default:
+				cells = append(cells, ".")
+			}
+		}
+		if r.Right == size-1 {
+			cells = append(cells, "|")
+		}
+		lines = append(lines, "$$ "+strings.Join(cells, " "))
+	}
+	if r.Bottom == size-1 {
+		lines = append(lines, border())
+	}
+	if len(notes) > 0 {
+		lines = append(lines, strings.Join(notes, ", "))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// WriteGameDiagrams writes the moves of g after from up to to as a series
+// of diagrams of per moves, at most ten, each starting from the position
+// the one before left. A diagram also ends early where the moves stop
+// alternating. With from equal to to it writes the position alone.
+func WriteGameDiagrams(w io.Writer, g *GameRecord, from, to, per int, opts DiagramOptions) error {
+	to = min(to, len(g.Moves))
+	per = min(max(per, 1), movesPerDiagram)
+	b, err := g.Replay(from)
+	if err != nil {
+		return err
+	}
+	if from >= to {
+		opts.Title = fmt.Sprintf("Position after %d moves", from)
+		return WriteDiagram(w, b, nil, from+1, opts)
+	}
+	for i := from; i < to; {
+		end := min(i+per, to)
+		for j := i + 1; j < end; j++ {
+			if g.Moves[j].Color == g.Moves[j-1].Color {
+				end = j
+				break
+			}
+		}
+		if i > from {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		opts.Title = fmt.Sprintf("Moves %d-%d", i+1, end)
+		if end == i+1 {
+			opts.Title = fmt.Sprintf("Move %d", end)
+		}
+		if err := WriteDiagram(w, b, g.Moves[i:end], i+1, opts); err != nil {
+			return err
+		}
+		for _, m := range g.Moves[i:end] {
+			b.turn = m.Color
+			b.Play(m.Move)
+		}
+		i = end
+	}
+	return nil
+}
+
+func runDiagram(args []string) error {
+	fs := flag.NewFlagSet("diagram", flag.ExitOnError)
+	out := fs.String("o", "", "write the diagrams to this file instead of standard output")
+	from := fs.Int("from", 0, "start from the position after this many moves")
+	to := fs.Int("to", -1, "stop after this many moves (default the end of the game)")
+	per := fs.Int("per", movesPerDiagram, "moves per diagram, at most 10")
+	region := fs.String("region", "", "top,left,bottom,right to show only part of the board")
+	coords := fs.Bool("coords", false, "ask for coordinates on the diagrams")
+	game := fs.Int("game", 1, "game number within the file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: diagram file.sgf [-from N] [-to N] [-per 10] [-region t,l,b,r] [-coords] [-o out.txt] [-game N]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	opts := DiagramOptions{Coordinates: *coords}
+	if *region != "" {
+		r, err := parseRect(*region)
+		if err != nil {
+			return err
+		}
+		opts.Region = r
+	}
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	end := len(g.Moves)
+	if *to >= 0 {
+		end = *to
+	}
+	var sb strings.Builder
+	if err := WriteGameDiagrams(&sb, g, max(*from, 0), end, *per, opts); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if *out == "" {
+		fmt.Print(sb.String())
+		return nil
+	}
+	if err := os.WriteFile(*out, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Diagrams written to %s\n", *out)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagram(t *testing.T) {
+	g := &GameRecord{Size: 5}
+	for i, p := range []int{1, 2, 5, 12, 11, 8, 7, 6, 24, PassMove, 7} {
+		color := Black
+		if i%2 == 1 {
+			color = White
+		}
+		g.Moves = append(g.Moves, RecordedMove{Color: color, Move: p})
+	}
+
+	// Move 8 captures 7 and 11 retakes the ko on the same point.
+	var sb strings.Builder
+	if err := WriteGameDiagrams(&sb, g, 1, 11, 10, DiagramOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `$$Wm2 Moves 2-11
+$$ +-----------+
+$$ | . X 1 . . |
+$$ | 2 7 6 5 . |
+$$ | . 4 3 . . |
+$$ | . . . . . |
+$$ | . . . . 8 |
+$$ +-----------+
+10 pass, 11 at 7
+`
+	if sb.String() != want {
+		t.Errorf("diagram:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	sb.Reset()
+	if err := WriteGameDiagrams(&sb, g, 11, 11, 10, DiagramOptions{Region: Rect{Bottom: 1, Right: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	want = `$$W Position after 11 moves
+$$ +------
+$$ | . X O
+$$ | X . X
+`
+	if sb.String() != want {
+		t.Errorf("corner diagram:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	sb.Reset()
+	if err := WriteGameDiagrams(&sb, g, 0, 11, 4, DiagramOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(sb.String(), "$$B"); n != 3 || !strings.Contains(sb.String(), "$$Bm5 Moves 5-8") {
+		t.Errorf("diagrams of four moves:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Opening diversity, so that engine-against-engine matches and self-play
+// do not repeat one game. Both are off by default and set like the other
+// engine options ("engine set temperature 1", temperature=1 in an engine
+// spec, or gtp -temperature 1):
+//
+//   - temperature T plays the first temperature-moves moves at random in
+//     proportion to visits^(1/T), instead of the most visited, so T = 1
+//     follows the visits and lower values keep closer to the best move.
+//     The engine only sees the position, so the first moves are those
+//     played while fewer stones than that are on the board.
+//   - dirichlet ε mixes Dirichlet noise into the exploration of the root's
+//     moves, as AlphaZero does with its priors: each move's exploration
+//     is scaled by (1-ε) + ε·n·η, η drawn from Dir(α) over the n moves for
+//     every search, so each search looks harder at some random moves.
+//     dirichlet-alpha is α, 0 for 10/n, which spreads the noise over
+//     about ten moves on any board.
+
+// temperatureMove picks a root child at random in proportion to
+// visits^(1/temperature). It passes only when no other move was searched:
+// a pass at random would throw the game away.
+func temperatureMove(root *node, temperature float64, rng *rand.Rand) int {
+	maxVisits := 0
+	for _, c := range root.children {
+		if c.move != PassMove {
+			maxVisits = max(maxVisits, c.visits)
+		}
+	}
+	if maxVisits == 0 {
+		return PassMove
+	}
+	weights := make([]float64, len(root.children))
+	total := 0.0
+	for i, c := range root.children {
+		if c.move == PassMove {
+			continue
+		}
+		// Scaled by the most visits first, so large counts do not overflow.
+		weights[i] = math.Pow(float64(c.visits)/float64(maxVisits), 1/temperature)
+		total += weights[i]
+	}
+	r := rng.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return root.children[i].move
+		}
+		r -= w
+	}
+	// Rounding left r past the end.
+	for i := len(weights) - 1; ; i-- {
+		if weights[i] > 0 {
+			return root.children[i].move
+		}
+	}
+}
+
+// rootExploration draws the Dirichlet noise for a search from the moves
+// at the root, as factors on each move's exploration, indexed by move+1.
+func rootExploration(moves []int, size int, weight, alpha float64, rng *rand.Rand) []float64 {
+	n := len(moves)
+	if n == 0 {
+		return nil
+	}
+	if alpha <= 0 {
+		alpha = 10 / float64(n)
+	}
+	eta := make([]float64, n)
+	total := 0.0
+	for i := range eta {
+		eta[i] = gammaSample(alpha, rng)
+		total += eta[i]
+	}
+	factors := make([]float64, size*size+1)
+	for i := range factors {
+		factors[i] = 1
+	}
+	for i, m := range moves {
+		if total > 0 {
+			factors[m+1] = (1 - weight) + weight*float64(n)*eta[i]/total
+		}
+	}
+	return factors
+}
+
+// gammaSample draws from the Gamma(alpha, 1) distribution by Marsaglia
+// and Tsang's method, boosted for alpha below 1.
+func gammaSample(alpha float64, rng *rand.Rand) float64 {
+	if alpha < 1 {
+		return gammaSample(alpha+1, rng) * math.Pow(rng.Float64(), 1/alpha)
+	}
+	d := alpha - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGammaSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, alpha := range []float64{0.03, 0.5, 2} {
+		sum := 0.0
+		const n = 20000
+		for i := 0; i < n; i++ {
+			sum += gammaSample(alpha, rng)
+		}
+		if mean := sum / n; math.Abs(mean-alpha) > 0.1*alpha+0.01 {
+			t.Errorf("Gamma(%g) mean %.3f", alpha, mean)
+		}
+	}
+}
+
+func TestOpeningDiversity(t *testing.T) {
+	// A root with visits 300, 100 and 0: at temperature 1 the first move is
+	// picked three times as often as the second, and the third never.
+	root := &node{children: []*node{{move: 0, visits: 300}, {move: 1, visits: 100}, {move: 2}}}
+	rng := rand.New(rand.NewSource(1))
+	var counts [3]int
+	for i := 0; i < 4000; i++ {
+		counts[temperatureMove(root, 1, rng)]++
+	}
+	if counts[2] != 0 || math.Abs(float64(counts[0])/float64(counts[1])-3) > 0.5 {
+		t.Errorf("picked %v", counts)
+	}
+
+	factors := rootExploration([]int{0, 1, PassMove}, 3, 0.25, 0, rng)
+	sum := factors[0] + factors[1] + factors[2]
+	if math.Abs(sum-3) > 1e-9 || factors[5] != 1 {
+		t.Errorf("factors %v: want the three moves' to average 1 and the rest 1", factors)
+	}
+
+	e := NewEngine(200, 6.5)
+	e.Temperature, e.TemperatureMoves, e.DirichletWeight = 1, 10, 0.25
+	seen := map[int]bool{}
+	for i := 0; i < 8; i++ {
+		row, col, pass, err := e.GenMove(context.Background(), NewBoard(9))
+		if err != nil || pass {
+			t.Fatalf("GenMove: %v, pass %v", err, pass)
+		}
+		seen[row*9+col] = true
+	}
+	if len(seen) < 3 {
+		t.Errorf("8 openings played only %d different moves", len(seen))
+	}
+}
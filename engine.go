@@ -0,0 +1,777 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PassMove is the move index used for a pass. Other moves are encoded as
+// row*size+col.
+const PassMove = -1
+
+// uctExploration is the exploration constant of the UCT formula.
+const uctExploration = 1.0
+
+// searchPlayouts counts the playouts of every search in the process, for
+// metrics.
+var searchPlayouts atomic.Int64
+
+// Engine is a Monte Carlo tree search player using uniformly random
+// playouts scored by area.
+type Engine struct {
+	Playouts int
+	Komi     float64
+	// Noise is the standard deviation of random noise, as a fraction of
+	// the most visited candidate's visits, added to every candidate's
+	// visit count before the final move is picked.
+	Noise float64
+	// Choice plays the k-th best candidate instead of the best (1).
+	Choice int
+	// Progress, if set, is called from the searching goroutine about every
+	// ProgressInterval (default 100ms) and once more when a search ends.
+	Progress         func(SearchProgress)
+	ProgressInterval time.Duration
+	// Threads is the number of parallel search workers. With more than
+	// one, or a BatchSize above one, leaves are evaluated in batches of up
+	// to BatchSize by Evaluator (random playouts when nil).
+	Threads   int
+	BatchSize int
+	Evaluator Evaluator
+	// SplitTree gives each of the Threads workers a tree of its own,
+	// merged at the root when the search ends, instead of one tree they
+	// all share under a lock; see splittree.go.
+	SplitTree bool
+	// PinWorker, if set, is called at the start of every search worker,
+	// numbered from 0, and of the batch evaluator, numbered Threads, on
+	// an OS thread of its own that is let go when the search ends, to
+	// pin it to a CPU; see affinity.go.
+	PinWorker func(worker int)
+	// MaxMemory caps the memory of the search tree in bytes; 0 means no
+	// limit. When it is reached the least visited subtrees are recycled.
+	MaxMemory int64
+	// Temperature, while fewer than TemperatureMoves stones are on the
+	// board, and DirichletWeight with DirichletAlpha vary the openings;
+	// see diversity.go.
+	Temperature      float64
+	TemperatureMoves int
+	DirichletWeight  float64
+	DirichletAlpha   float64
+	// Tablebase is the largest board size GenMove solves exactly instead
+	// of searching, when the engine is not weakened by Noise or Choice;
+	// see tablebase.go.
+	Tablebase int
+	// MoveTime, if positive, is the time GenMove has, however many
+	// Playouts are left. The search ends a tenth of it early (see
+	// moveBudget) and starts no playout it has no time left for, but a
+	// playout under way runs to its end, so a move may take up to about a
+	// playout longer than MoveTime, and more on a machine too busy to run
+	// the search when its time is up. It stops sooner once no other move
+	// can catch up with the best in the time or playouts remaining,
+	// unless the engine is weakened by Noise, Choice or Temperature.
+	// Blitz games set it.
+	MoveTime time.Duration
+	// Think, if set, plans the time of each GenMove in place of MoveTime,
+	// as a game's clock allows; see TimeManager.
+	Think func() TimePlan
+	// Reuse keeps the tree of GenMove's search for the next GenMove,
+	// which searches on from the node of its position if the tree has
+	// it, as it does after the engine's move and the opponent's reply.
+	Reuse bool
+	// Playout tunes the playouts that evaluate the search's leaves when
+	// there is no Evaluator; see playout.go. Score and Ownership always
+	// play to the end.
+	Playout PlayoutPolicy
+
+	scores      ScoreDistribution // of the last search
+	rootFactors []float64         // exploration of the root's moves, by move+1; nil for even
+	rng         *rand.Rand
+	arena       nodeArena
+	stats       SearchStats
+	stopEarly   bool        // the search may end once its best move is settled
+	moveEnd     time.Time   // when GenMove's MoveTime runs out, zero if none
+	plan        TimePlan    // of the GenMove under way
+	best        int         // the most visited move at the last check
+	bestSince   time.Time   // when it became so
+	split       *splitClock // the split search this engine is a shard of, if its time is planned
+	kept        *node       // root of the tree kept by Reuse, nil if none
+	keptBoard   *Board      // the position at kept
+	keptKomi    float64
+}
+
+// SearchStats describes the tree of the last search.
+type SearchStats struct {
+	Nodes    int     // live nodes at the end
+	Memory   int64   // estimated bytes held by the tree
+	Recycled int     // nodes freed to stay under MaxMemory
+	WinRate  float64 // of the root, for the side to move
+	Reused   int     // playouts the root had from the tree of the last move
+	Settled  bool    // the search stopped early, its best move out of reach
+}
+
+func NewEngine(playouts int, komi float64) *Engine {
+	return &Engine{
+		Playouts:  playouts,
+		Komi:      komi,
+		Choice:    1,
+		MaxMemory: 256 << 20,
+		Tablebase: 3,
+		Reuse:     true,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SearchProgress is a snapshot of a running search.
+type SearchProgress struct {
+	Playouts int     // completed so far
+	Total    int     // planned
+	Best     int     // most visited move so far, or PassMove
+	WinRate  float64 // of Best, for the side to move
+	Elapsed  time.Duration
+	Nodes    int   // in the search tree
+	Memory   int64 // estimated bytes held by the tree
+	Done     bool  // the last report of the search
+}
+
+type node struct {
+	move     int
+	player   Stone // colour that played move
+	parent   *node
+	children []*node
+	untried  []int
+	visits   int
+	wins     float64
+	// collapsed nodes had their subtree recycled and are expanded again
+	// when next reached.
+	collapsed bool
+	pending   int // evaluations in flight through this node
+}
+
+// Play applies a move index for the side to move.
+func (b *Board) Play(move int) bool {
+	if move == PassMove {
+		b.Pass()
+		return true
+	}
+	return b.PlaceStone(move/b.size, move%b.size)
+}
+
+// isEye reports whether the empty point is surrounded on all sides by
+// stones of colour s. Playouts never fill such points.
+func (b *Board) isEye(row, col int, s Stone) bool {
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for _, dir := range directions {
+		r, c := row+dir[0], col+dir[1]
+		if b.isInBounds(r, c) && b.grid[r][c] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates lists the legal moves of the side to move that do not fill
+// its own eyes.
+func (b *Board) candidates() []int {
+	var moves []int
+	for row := 0; row < b.size; row++ {
+		for col := 0; col < b.size; col++ {
+			if b.grid[row][col] == Empty && !b.isEye(row, col, b.turn) && b.IsLegal(row*b.size+col) {
+				moves = append(moves, row*b.size+col)
+			}
+		}
+	}
+	return moves
+}
+
+// MoveAnalysis is the engine's view of the position after MoveNumber moves.
+type MoveAnalysis struct {
+	MoveNumber int
+	WinRate    float64 // for the side to move
+	Playouts   int
+	Candidates []CandidateMove
+	// ScoreMean and ScoreStdev are the expected lead of the side to move
+	// and its spread, or 0 when the evaluator does not estimate scores.
+	ScoreMean  float64
+	ScoreStdev float64
+}
+
+type CandidateMove struct {
+	Move    int
+	Visits  int
+	WinRate float64
+}
+
+// GenMove searches the position and returns the chosen move for the side
+// to move, or pass. If ctx is done before all playouts have run, the
+// search stops and GenMove returns the best move found so far along with
+// ctx.Err(); running out of MoveTime is not an error. The engine searches
+// a copy of v.
+func (e *Engine) GenMove(ctx context.Context, v BoardView) (row, col int, pass bool, err error) {
+	plan := TimePlan{Target: e.MoveTime, Limit: e.MoveTime}
+	if e.Think != nil {
+		plan = e.Think()
+	}
+	if plan.Limit > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, moveBudget(plan.Limit))
+		defer cancel()
+		defer func() {
+			if parent.Err() == nil {
+				err = nil
+			}
+		}()
+		e.stopEarly = e.Noise == 0 && e.Choice <= 1 && e.Temperature == 0
+		e.moveEnd, _ = ctx.Deadline()
+		e.plan = plan
+		defer func() { e.stopEarly, e.moveEnd, e.plan = false, time.Time{}, TimePlan{} }()
+	}
+	b := v.Copy()
+	if b.size <= e.Tablebase && e.Noise == 0 && e.Choice <= 1 {
+		if _, move, err := tablebaseFor(b.size).Solve(ctx, b); err == nil {
+			if move == PassMove {
+				return 0, 0, true, nil
+			}
+			return move / b.size, move % b.size, false, nil
+		}
+	}
+	root, err := e.search(ctx, b, e.reuse(b))
+	if e.Reuse {
+		e.kept, e.keptBoard, e.keptKomi = root, b, e.Komi
+	}
+	move := e.pick(root)
+	if e.Temperature > 0 && b.stoneCount(Black)+b.stoneCount(White) < e.TemperatureMoves {
+		move = temperatureMove(root, e.Temperature, e.rng)
+	}
+	if move == PassMove {
+		return 0, 0, true, err
+	}
+	return move / b.size, move % b.size, false, err
+}
+
+// Analyze searches the position and reports the win rate for the side to
+// move and the candidate moves by visits, without picking one. Like
+// GenMove it returns what it has so far when ctx is done.
+func (e *Engine) Analyze(ctx context.Context, v BoardView) (MoveAnalysis, error) {
+	b := v.Copy()
+	root, err := e.search(ctx, b, nil)
+	a := MoveAnalysis{Playouts: root.visits}
+	if root.visits > 0 {
+		a.WinRate = 1 - root.wins/float64(root.visits)
+	}
+	if e.scores.Playouts > 0 {
+		a.ScoreMean, a.ScoreStdev = ownerSign(b.turn)*e.scores.Mean(), e.scores.Stdev()
+	}
+	for _, c := range root.children {
+		a.Candidates = append(a.Candidates, CandidateMove{Move: c.move, Visits: c.visits, WinRate: c.wins / float64(c.visits)})
+	}
+	sort.Slice(a.Candidates, func(i, j int) bool { return a.Candidates[i].Visits > a.Candidates[j].Visits })
+	return a, err
+}
+
+// Score estimates Black's final lead, komi included, as the mean area
+// score over Playouts random playouts from the position. A partial mean is
+// returned with ctx.Err() when ctx is done first.
+func (e *Engine) Score(ctx context.Context, b BoardView) (float64, error) {
+	total, n := 0.0, 0
+	var err error
+	for ; n < e.Playouts; n++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		pos := b.Copy()
+		randomPlayout(pos, e.rng)
+		black, white := pos.AreaScore()
+		total += float64(black-white) - e.Komi
+	}
+	if n == 0 {
+		return 0, err
+	}
+	return total / float64(n), err
+}
+
+// Ownership estimates who ends up with each point, by move index: the mean
+// over Playouts random playouts from b of +1 where Black owns the point by
+// area, -1 for White and 0 for neither, or the Evaluator's prediction when
+// it is an OwnershipEvaluator. A partial mean is returned with ctx.Err()
+// when ctx is done first.
+func (e *Engine) Ownership(ctx context.Context, b BoardView) ([]float64, error) {
+	if oe, ok := e.Evaluator.(OwnershipEvaluator); ok {
+		return oe.Ownership(b.Copy()), nil
+	}
+	size := b.Size()
+	own := make([]float64, size*size)
+	n := 0
+	var err error
+	for ; n < e.Playouts; n++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		pos := b.Copy()
+		randomPlayout(pos, e.rng)
+		for _, r := range pos.EmptyRegions() {
+			if o := r.Owner(); o != Empty {
+				for _, p := range r.Points {
+					own[p] += ownerSign(o)
+				}
+			}
+		}
+		for p := range own {
+			own[p] += ownerSign(pos.grid[p/size][p%size])
+		}
+	}
+	if n > 0 {
+		for p := range own {
+			own[p] /= float64(n)
+		}
+	}
+	return own, err
+}
+
+// ownerSign is +1 for Black, -1 for White and 0 for Empty.
+func ownerSign(s Stone) float64 {
+	switch s {
+	case Black:
+		return 1
+	case White:
+		return -1
+	}
+	return 0
+}
+
+// search runs up to Playouts iterations of MCTS from b, stopping early with
+// ctx.Err() when ctx is done. It searches on from root, a node of b kept
+// from the last search, or afresh when root is nil.
+func (e *Engine) search(ctx context.Context, b *Board, root *node) (*node, error) {
+	e.kept, e.keptBoard = nil, nil
+	if e.SplitTree && e.Threads > 1 {
+		return e.splitSearch(ctx, b)
+	}
+	if root == nil {
+		e.arena.reset()
+		root = e.arena.alloc()
+		root.move, root.player = PassMove, b.turn.Opponent()
+		e.arena.setUntried(root, append(b.candidates(), PassMove))
+	}
+	e.rootFactors = nil
+	if e.DirichletWeight > 0 {
+		moves := slices.Clone(root.untried)
+		for _, c := range root.children {
+			moves = append(moves, c.move)
+		}
+		e.rootFactors = rootExploration(moves, b.size, e.DirichletWeight, e.DirichletAlpha, e.rng)
+	}
+	reused := root.visits
+	e.stats = SearchStats{Reused: reused}
+	deadline, _ := ctx.Deadline()
+	defer func() {
+		searchPlayouts.Add(int64(root.visits - reused))
+		e.stats.Nodes, e.stats.Memory = e.arena.live, e.arena.bytes
+		if root.visits > 0 {
+			e.stats.WinRate = 1 - root.wins/float64(root.visits)
+		}
+	}()
+	start := time.Now()
+	e.best, e.bestSince = PassMove-1, start
+	interval := e.ProgressInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	next := start.Add(interval)
+	if e.Progress != nil {
+		defer func() { e.Progress(e.progress(root, start, true)) }()
+	}
+	report := func() {
+		if e.Progress != nil && time.Now().After(next) {
+			e.Progress(e.progress(root, start, false))
+			next = time.Now().Add(interval)
+		}
+	}
+
+	eval := e.Evaluator
+	if eval == nil {
+		eval = newPlayoutEvaluator(e.Komi, e.Playout)
+	}
+	e.scores = ScoreDistribution{}
+	if se, ok := eval.(ScoreEvaluator); ok {
+		eval = scoreRecorder{se, &e.scores}
+	}
+	if e.Threads <= 1 && e.BatchSize <= 1 {
+		for i := 0; i < e.Playouts; i++ {
+			if err := ctx.Err(); err != nil {
+				return root, err
+			}
+			if e.enough(root, i, start, deadline) {
+				return root, nil
+			}
+			report()
+			leaf, pos := e.descend(root, b)
+			e.backup(leaf, eval.Evaluate([]*Board{pos})[0])
+		}
+		return root, nil
+	}
+
+	// Parallel search: workers share the tree under mu and wait for their
+	// leaves to be evaluated in batches by the queue.
+	queue := newEvalQueue(eval, max(e.BatchSize, 1), e.pin(max(e.Threads, 1)))
+	defer queue.close()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	started, stop := 0, false
+	for w := 0; w < max(e.Threads, 1); w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if pin := e.pin(w); pin != nil {
+				pin()
+			}
+			for {
+				mu.Lock()
+				stop = stop || e.enough(root, started, start, deadline)
+				if stop || started == e.Playouts || ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				started++
+				report()
+				leaf, pos := e.descend(root, b)
+				mu.Unlock()
+
+				v := queue.evaluate(pos)
+
+				mu.Lock()
+				e.backup(leaf, v)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	return root, ctx.Err()
+}
+
+// reuse looks for b in the tree kept from the last GenMove, at its root or
+// up to two moves below, and returns its node with the rest of the tree
+// freed. It returns nil, for a search afresh, when the tree does not have
+// b or was searched with another komi.
+func (e *Engine) reuse(b *Board) *node {
+	root, pos := e.kept, e.keptBoard
+	e.kept, e.keptBoard = nil, nil
+	if root == nil || !e.Reuse || e.keptKomi != e.Komi || pos.size != b.size {
+		return nil
+	}
+	n := findPosition(root, pos, b, 2)
+	if n != nil {
+		e.arena.reroot(root, n)
+	}
+	return n
+}
+
+// findPosition returns the node of b among n, at pos, and its descendants
+// down to depth moves.
+func findPosition(n *node, pos, b *Board, depth int) *node {
+	if pos.passes == b.passes && pos.Hash() == b.Hash() {
+		return n
+	}
+	if depth == 0 {
+		return nil
+	}
+	for _, c := range n.children {
+		// A stone b lacks was not played on the way to it, unless it was
+		// captured again, which is too rare to replay every child for.
+		if c.move != PassMove && b.grid[c.move/b.size][c.move%b.size] != c.player {
+			continue
+		}
+		next := pos.Copy()
+		if !next.Play(c.move) {
+			continue
+		}
+		if found := findPosition(c, next, b, depth-1); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// moveBudget is the time a search may take to answer within d, keeping a
+// tenth, but at least 2ms and at most half, for the playouts in flight
+// and the answer.
+func moveBudget(d time.Duration) time.Duration {
+	return d - min(max(d/10, 2*time.Millisecond), d/2)
+}
+
+// TimePlan is the thinking time of one move: the search aims for Target,
+// and goes on up to Limit while its choice is unstable.
+type TimePlan struct {
+	Target time.Duration
+	Limit  time.Duration
+}
+
+// Continue reports whether a search that has run for elapsed goes on,
+// given how unstable its choice is, from 0 to 1: a settled one stops at
+// Target, the most unstable at three times that, and none after Limit.
+func (p TimePlan) Continue(elapsed time.Duration, instability float64) bool {
+	return elapsed < min(p.Limit, time.Duration(float64(p.Target)*(1+2*instability)))
+}
+
+// enough reports whether the search may stop after done playouts: out of
+// time, past its TimePlan or, with stopEarly, settled. All but the first
+// are looked at every 16 playouts, and in a shard of a split search for
+// the search as a whole (see splitClock).
+func (e *Engine) enough(root *node, done int, start, deadline time.Time) bool {
+	if e.outOfTime(done, start) {
+		return true
+	}
+	if done%16 != 0 {
+		return false
+	}
+	if e.split != nil {
+		return e.split.enough(e, root)
+	}
+	return e.decided(root, done, start, deadline)
+}
+
+// decided reports whether a search with root after done playouts is past
+// its TimePlan or, with stopEarly, settled.
+func (e *Engine) decided(root *node, done int, start, deadline time.Time) bool {
+	if e.plan.Target > 0 && !e.plan.Continue(time.Since(start), e.instability(root, start)) {
+		return true
+	}
+	return e.stopEarly && e.settled(root, done, start, deadline)
+}
+
+// instability is how unsettled the choice at root is: the visits of the
+// runner-up as a share of the best move's, or 1 if the best move changed
+// in the last quarter of the search since start.
+func (e *Engine) instability(root *node, start time.Time) float64 {
+	var first, second *node
+	for _, c := range root.children {
+		switch {
+		case first == nil || c.visits > first.visits:
+			first, second = c, first
+		case second == nil || c.visits > second.visits:
+			second = c
+		}
+	}
+	if first == nil {
+		return 1
+	}
+	now := time.Now()
+	if first.move != e.best {
+		e.best, e.bestSince = first.move, now
+	}
+	if e.bestSince.After(now.Add(-now.Sub(start) / 4)) {
+		return 1
+	}
+	if second == nil || first.visits == 0 {
+		return 0
+	}
+	return float64(second.visits) / float64(first.visits)
+}
+
+// outOfTime reports whether GenMove's MoveTime has too little left for
+// another playout, at the average time of the done playouts since start.
+// A playout runs to its end once started, so this is what keeps a search
+// of long playouts on a busy machine within its budget.
+func (e *Engine) outOfTime(done int, start time.Time) bool {
+	return !e.moveEnd.IsZero() && done > 0 && time.Until(e.moveEnd) < time.Since(start)/time.Duration(done)
+}
+
+// settled reports whether the most visited move at root has more visits
+// over the next than the done playouts of the search so far leave to
+// play, by Playouts or, going on at the rate so far, before deadline if
+// it is set. Stopping then changes nothing but the time taken.
+func (e *Engine) settled(root *node, done int, start, deadline time.Time) bool {
+	left := e.Playouts - done
+	if elapsed := time.Since(start); !deadline.IsZero() && elapsed > 0 && done > 0 {
+		left = min(left, int(float64(done)*float64(time.Until(deadline))/float64(elapsed))+1)
+	}
+	first, second := 0, 0
+	for _, c := range root.children {
+		switch {
+		case c.visits > first:
+			first, second = c.visits, first
+		case c.visits > second:
+			second = c.visits
+		}
+	}
+	if first-second > left {
+		e.stats.Settled = true
+		return true
+	}
+	return false
+}
+
+// pin returns the function that pins the worker numbered worker to its
+// CPU from the goroutine that runs it, or nil when there is no PinWorker.
+// The goroutine keeps its OS thread to the end, so that the thread, and
+// its affinity, go with it rather than back to the scheduler.
+func (e *Engine) pin(worker int) func() {
+	if e.PinWorker == nil {
+		return nil
+	}
+	return func() {
+		runtime.LockOSThread()
+		e.PinWorker(worker)
+	}
+}
+
+// descend selects a leaf by UCT from root, expands it and returns the new
+// node with the position reached. Every node on the path counts the visit
+// at once, as a virtual loss, so parallel workers spread out.
+func (e *Engine) descend(root *node, b *Board) (*node, *Board) {
+	pos := b.Copy()
+	n := root
+
+	// Selection
+	for len(n.untried) == 0 && len(n.children) > 0 {
+		n.visits++
+		n.pending++
+		if n == root {
+			n = n.bestChildWith(e.rootFactors)
+		} else {
+			n = n.bestChild()
+		}
+		pos.Play(n.move)
+	}
+
+	// Expansion, within the memory cap
+	if e.MaxMemory > 0 && e.arena.bytes > e.MaxMemory {
+		e.stats.Recycled += e.arena.prune(root, e.MaxMemory*3/4)
+	}
+	if n.collapsed && !pos.IsGameOver() {
+		n.collapsed = false
+		e.arena.setUntried(n, append(pos.candidates(), PassMove))
+	}
+	for len(n.untried) > 0 && !pos.IsGameOver() && (e.MaxMemory <= 0 || e.arena.bytes <= e.MaxMemory) {
+		j := e.rng.Intn(len(n.untried))
+		move := n.untried[j]
+		n.untried[j] = n.untried[len(n.untried)-1]
+		n.untried = n.untried[:len(n.untried)-1]
+		player := pos.turn
+		if !pos.Play(move) {
+			continue
+		}
+		child := e.arena.alloc()
+		child.move, child.player, child.parent = move, player, n
+		if !pos.IsGameOver() {
+			e.arena.setUntried(child, append(pos.candidates(), PassMove))
+		}
+		n.children = append(n.children, child)
+		n.visits++
+		n.pending++
+		n = child
+		break
+	}
+	n.visits++
+	n.pending++
+	return n, pos
+}
+
+// backup adds an evaluation, the probability that Black wins, to leaf and
+// its ancestors, whose visits descend already counted.
+func (e *Engine) backup(leaf *node, blackWins float64) {
+	for n := leaf; n != nil; n = n.parent {
+		n.pending--
+		if n.player == Black {
+			n.wins += blackWins
+		} else {
+			n.wins += 1 - blackWins
+		}
+	}
+}
+
+// ScoreDistribution returns the spread of the estimated scores of the last
+// search, empty when its evaluator does not estimate scores.
+func (e *Engine) ScoreDistribution() ScoreDistribution {
+	return e.scores
+}
+
+// Stats returns the tree statistics of the last search.
+func (e *Engine) Stats() SearchStats {
+	return e.stats
+}
+
+func (e *Engine) progress(root *node, start time.Time, done bool) SearchProgress {
+	p := SearchProgress{Playouts: root.visits - e.stats.Reused, Total: e.Playouts, Best: PassMove, Elapsed: time.Since(start),
+		Nodes: e.arena.live, Memory: e.arena.bytes, Done: done}
+	var best *node
+	for _, c := range root.children {
+		if best == nil || c.visits > best.visits {
+			best = c
+		}
+	}
+	if best != nil {
+		p.Best, p.WinRate = best.move, best.wins/float64(best.visits)
+	}
+	return p
+}
+
+func (n *node) bestChild() *node {
+	return n.bestChildWith(nil)
+}
+
+// bestChildWith is bestChild with each move's exploration scaled by
+// factors[move+1], if factors is not nil.
+func (n *node) bestChildWith(factors []float64) *node {
+	var best *node
+	bestValue := math.Inf(-1)
+	logVisits := math.Log(float64(n.visits))
+	for _, c := range n.children {
+		explore := uctExploration
+		if factors != nil {
+			explore *= factors[c.move+1]
+		}
+		value := c.wins/float64(c.visits) + explore*math.Sqrt(logVisits/float64(c.visits))
+		if value > bestValue {
+			best, bestValue = c, value
+		}
+	}
+	return best
+}
+
+// randomPlayout plays random moves until both sides pass or the game runs
+// too long.
+func randomPlayout(b *Board, rng *rand.Rand) {
+	PlayoutPolicy{}.Play(b, rng)
+}
+
+// pick ranks the root's children by visits, perturbed by Noise, and
+// returns the Choice-th best move.
+func (e *Engine) pick(root *node) int {
+	if len(root.children) == 0 {
+		return PassMove
+	}
+	type candidate struct {
+		move  int
+		value float64
+	}
+	maxVisits := 0
+	for _, c := range root.children {
+		if c.visits > maxVisits {
+			maxVisits = c.visits
+		}
+	}
+	ranked := make([]candidate, 0, len(root.children))
+	for _, c := range root.children {
+		value := float64(c.visits)
+		if e.Noise > 0 {
+			value += e.rng.NormFloat64() * e.Noise * float64(maxVisits)
+		}
+		ranked = append(ranked, candidate{c.move, value})
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].value > ranked[j].value })
+	k := e.Choice
+	if k < 1 {
+		k = 1
+	}
+	if k > len(ranked) {
+		k = len(ranked)
+	}
+	return ranked[k-1].move
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchStopsWhenCancelled(t *testing.T) {
+	e := NewEngine(1<<30, 6.5)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err := e.GenMove(ctx, NewBoard(9))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GenMove error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GenMove ran %v after its deadline", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	a, err := e.Analyze(ctx, NewBoard(9))
+	if !errors.Is(err, context.Canceled) || a.Playouts != 0 {
+		t.Errorf("Analyze on a cancelled context: %d playouts, %v", a.Playouts, err)
+	}
+	if _, err := e.Score(ctx, NewBoard(9)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Score error = %v", err)
+	}
+}
+
+func TestAnalyzeCandidates(t *testing.T) {
+	e := NewEngine(200, 6.5)
+	a, err := e.Analyze(context.Background(), NewBoard(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Playouts != 200 || len(a.Candidates) == 0 {
+		t.Fatalf("%d playouts, %d candidates", a.Playouts, len(a.Candidates))
+	}
+	for i := 1; i < len(a.Candidates); i++ {
+		if a.Candidates[i].Visits > a.Candidates[i-1].Visits {
+			t.Fatal("candidates not ordered by visits")
+		}
+	}
+}
+
+func TestSearchProgress(t *testing.T) {
+	e := NewEngine(300, 6.5)
+	e.ProgressInterval = time.Nanosecond
+	var reports []SearchProgress
+	e.Progress = func(p SearchProgress) { reports = append(reports, p) }
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("%d progress reports", len(reports))
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Playouts != 300 || last.Total != 300 {
+		t.Errorf("last report %+v", last)
+	}
+	for _, p := range reports[:len(reports)-1] {
+		if p.Done || p.Playouts > 300 {
+			t.Errorf("intermediate report %+v", p)
+		}
+	}
+}
+
+func TestSearchStaysUnderMemoryCap(t *testing.T) {
+	e := NewEngine(1000, 6.5)
+	e.MaxMemory = 64 << 10
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(7)); err != nil {
+		t.Fatal(err)
+	}
+	st := e.Stats()
+	if st.Recycled == 0 {
+		t.Errorf("no nodes recycled: %+v", st)
+	}
+	if st.Memory > e.MaxMemory+e.MaxMemory/4 {
+		t.Errorf("tree uses %d bytes, cap %d", st.Memory, e.MaxMemory)
+	}
+
+	e.MaxMemory = 0
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(7)); err != nil {
+		t.Fatal(err)
+	}
+	if st := e.Stats(); st.Recycled != 0 || st.Nodes < 1000 {
+		t.Errorf("uncapped search: %+v", st)
+	}
+}
+
+func TestGenMoveReusesTree(t *testing.T) {
+	ctx := context.Background()
+	e := NewEngine(2000, 6.5)
+	b := NewBoard(7)
+	row, col, _, _ := e.GenMove(ctx, b)
+	b.PlaceStone(row, col)
+	// Any reply the engine looked at is in its tree.
+	i := slices.IndexFunc(e.kept.children, func(c *node) bool { return c.move == row*7+col })
+	b.Play(e.kept.children[i].children[0].move)
+	if _, _, _, err := e.GenMove(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if st := e.Stats(); st.Reused == 0 || e.kept.visits != st.Reused+2000 {
+		t.Errorf("after the expected reply: %+v, root visits %d", st, e.kept.visits)
+	}
+
+	// Another position, or another komi, starts afresh.
+	e.GenMove(ctx, NewBoard(7))
+	if st := e.Stats(); st.Reused != 0 {
+		t.Errorf("on a new board: reused %d", st.Reused)
+	}
+	e.Komi = 0.5
+	e.GenMove(ctx, NewBoard(7))
+	if st := e.Stats(); st.Reused != 0 {
+		t.Errorf("with another komi: reused %d", st.Reused)
+	}
+}
+
+func TestBlitzMoveTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("times searches")
+	}
+	// A busy goroutine for each CPU stands for a loaded machine.
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}()
+	}
+	// A move may overrun its budget by about a playout, which takes what
+	// the machine gives it: the race detector, say, slows it manyfold.
+	// The busy goroutines may also hold the CPU for a while when the
+	// search's time is up, which half the budget again allows for.
+	e := NewEngine(50, 7.5)
+	start := time.Now()
+	e.GenMove(context.Background(), NewBoard(19))
+	playout := time.Since(start) / 50
+
+	e = NewEngine(1<<30, 7.5)
+	e.MoveTime = 100 * time.Millisecond
+	limit := e.MoveTime*3/2 + 2*playout
+	b := NewBoard(19)
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		row, col, pass, err := e.GenMove(context.Background(), b)
+		if elapsed := time.Since(start); err != nil || elapsed > limit {
+			t.Fatalf("move %d: %v, took %v, more than %v", i+1, err, elapsed, limit)
+		}
+		if !pass {
+			b.PlaceStone(row, col)
+		}
+	}
+
+	// With a budget to spare, the search stops once its move is settled.
+	e = NewEngine(5000, 6.5)
+	e.MoveTime, e.Evaluator = time.Minute, centreEvaluator{}
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(5)); err != nil {
+		t.Fatal(err)
+	}
+	if st := e.Stats(); !st.Settled || e.kept.visits >= 5000 {
+		t.Errorf("settled %v after %d playouts", st.Settled, e.kept.visits)
+	}
+}
+
+// centreEvaluator gives Black the game whenever Black holds the centre,
+// so that taking it is plainly the best move.
+type centreEvaluator struct{}
+
+func (centreEvaluator) Evaluate(positions []*Board) []float64 {
+	values := make([]float64, len(positions))
+	for i, b := range positions {
+		if b.At(b.size/2, b.size/2) == Black {
+			values[i] = 1
+		}
+	}
+	return values
+}
+
+func TestSplitTreeSearch(t *testing.T) {
+	e := NewEngine(301, 6.5)
+	e.Threads, e.SplitTree = 3, true
+	a, err := e.Analyze(context.Background(), NewBoard(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	visits := 0
+	for _, c := range a.Candidates {
+		visits += c.Visits
+	}
+	if a.Playouts != 301 || visits != 301 {
+		t.Errorf("%d playouts, %d visits of the moves", a.Playouts, visits)
+	}
+
+	// The shards stop together once the moves added up are settled.
+	e = NewEngine(5000, 6.5)
+	e.Threads, e.SplitTree, e.MoveTime, e.Evaluator = 3, true, time.Minute, centreEvaluator{}
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(5)); err != nil {
+		t.Fatal(err)
+	}
+	if st := e.Stats(); !st.Settled || e.kept.visits >= 5000 {
+		t.Errorf("settled %v after %d playouts", st.Settled, e.kept.visits)
+	}
+
+	// A shared evaluator's scores are kept for the whole search.
+	e = NewEngine(300, 6.5)
+	e.Threads, e.SplitTree = 3, true
+	e.Evaluator = newPlayoutEvaluator(6.5, PlayoutPolicy{})
+	if _, err := e.Analyze(context.Background(), NewBoard(5)); err != nil {
+		t.Fatal(err)
+	}
+	if d := e.ScoreDistribution(); d.Playouts != 300 {
+		t.Errorf("scores of %d playouts, want 300", d.Playouts)
+	}
+}
+
+// fixedOwner predicts that Black owns every point.
+type fixedOwner struct{ *playoutEvaluator }
+
+func (fixedOwner) Ownership(b *Board) []float64 {
+	own := make([]float64, b.size*b.size)
+	for i := range own {
+		own[i] = 1
+	}
+	return own
+}
+
+func TestQueuedOwnership(t *testing.T) {
+	eval := fixedOwner{newPlayoutEvaluator(6.5, PlayoutPolicy{})}
+	q := newEvalQueue(scoreRecorder{eval, &ScoreDistribution{}}, 4, nil)
+	defer q.close()
+	if q.owner == nil {
+		t.Fatal("the queue of an ownership evaluator has no owner")
+	}
+	var qe Evaluator = queuedOwnershipEvaluator{queuedEvaluator{q}}
+	oe, ok := qe.(OwnershipEvaluator)
+	if !ok {
+		t.Fatal("queuedOwnershipEvaluator is not an OwnershipEvaluator")
+	}
+	if own := oe.Ownership(NewBoard(3)); len(own) != 9 || own[4] != 1 {
+		t.Errorf("ownership through the queue %v", own)
+	}
+	if v := qe.Evaluate([]*Board{NewBoard(3)}); len(v) != 1 {
+		t.Errorf("values %v", v)
+	}
+}
+
+// batchRecorder is a playout evaluator that remembers its batch sizes.
+type batchRecorder struct {
+	*playoutEvaluator
+	batches []int
+}
+
+func (r *batchRecorder) Evaluate(positions []*Board) []float64 {
+	r.batches = append(r.batches, len(positions))
+	return r.playoutEvaluator.Evaluate(positions)
+}
+
+func (r *batchRecorder) EvaluateScores(positions []*Board) ([]float64, []float64) {
+	r.batches = append(r.batches, len(positions))
+	return r.playoutEvaluator.EvaluateScores(positions)
+}
+
+func TestParallelBatchedSearch(t *testing.T) {
+	e := NewEngine(400, 6.5)
+	e.Threads, e.BatchSize = 8, 4
+	rec := &batchRecorder{playoutEvaluator: newPlayoutEvaluator(6.5, PlayoutPolicy{})}
+	e.Evaluator = rec
+	a, err := e.Analyze(context.Background(), NewBoard(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Playouts != 400 {
+		t.Errorf("%d playouts, want 400", a.Playouts)
+	}
+	total, largest := 0, 0
+	for _, n := range rec.batches {
+		total += n
+		largest = max(largest, n)
+	}
+	if total != 400 || largest > 4 {
+		t.Errorf("evaluated %d positions, largest batch %d", total, largest)
+	}
+	sum := 0
+	for _, c := range a.Candidates {
+		sum += c.Visits
+	}
+	if sum != 400 {
+		t.Errorf("children have %d visits, want 400", sum)
+	}
+}
+
+func TestEngineOptions(t *testing.T) {
+	e := NewEngine(1000, 6.5)
+	if err := e.SetOption("playouts", "5000"); err != nil || e.Playouts != 5000 {
+		t.Errorf("set playouts: %v, playouts %d", err, e.Playouts)
+	}
+	if err := e.SetOption("tree-mb", "64"); err != nil || e.MaxMemory != 64<<20 {
+		t.Errorf("set tree-mb: %v, memory %d", err, e.MaxMemory)
+	}
+	for _, bad := range [][2]string{{"playouts", "1.5"}, {"playouts", "0"}, {"noise", "x"}, {"nets", "1"}} {
+		if err := e.SetOption(bad[0], bad[1]); err == nil {
+			t.Errorf("set %s %s accepted", bad[0], bad[1])
+		}
+	}
+	opts, _ := e.Options()
+	for _, o := range opts {
+		if o.Name == "playouts" && o.Value != "5000" {
+			t.Errorf("playouts listed as %s", o.Value)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["engine-diff"] = runEngineDiff
+}
+
+// newEngineFromSpec builds an engine from a configuration such as
+// "playouts=5000,threads=2": engine options as listed by "engine
+// options", applied in order. profile=NAME applies a profile's playouts,
+// threads and time per move at that point.
+func newEngineFromSpec(spec string, komi float64) (*Engine, error) {
+	e := NewEngine(1000, komi)
+	for _, setting := range strings.Split(spec, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid engine setting %q, use name=value", setting)
+		}
+		if name == "profile" {
+			p, err := FindEngineProfile(configPath(), value)
+			if err != nil {
+				return nil, err
+			}
+			e.Playouts, e.Threads = p.Playouts, p.Threads
+			e.MoveTime = time.Duration(p.MoveMS) * time.Millisecond
+			continue
+		}
+		if err := e.SetOption(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// PositionDiff compares two engines on the position before a move. Win
+// rates are for the side to move; CrossA is A's win rate for B's move
+// and CrossB B's for A's, NaN when the engine did not search the other's
+// move.
+type PositionDiff struct {
+	MoveNumber         int // of the move about to be played
+	MoveA, MoveB       int
+	WinRateA, WinRateB float64
+	CrossA, CrossB     float64
+}
+
+// Diverges reports whether the engines chose different moves or their
+// evaluations differ by at least gap.
+func (d PositionDiff) Diverges(gap float64) bool {
+	return d.MoveA != d.MoveB || math.Abs(d.WinRateA-d.WinRateB) >= gap
+}
+
+// DiffEngines analyses the positions before moves from+1 to to of g with
+// both engines.
+func DiffEngines(ctx context.Context, g *GameRecord, a, b *Engine, from, to int) ([]PositionDiff, error) {
+	a.Komi, b.Komi = g.Komi, g.Komi
+	to = min(to, len(g.Moves))
+	board, err := g.Replay(from)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []PositionDiff
+	for i := from; i < to; i++ {
+		board.turn = g.Moves[i].Color
+		analysisA, err := a.Analyze(ctx, board)
+		if err != nil {
+			return diffs, err
+		}
+		analysisB, err := b.Analyze(ctx, board)
+		if err != nil {
+			return diffs, err
+		}
+		d := PositionDiff{MoveNumber: i + 1, MoveA: topMove(analysisA), MoveB: topMove(analysisB),
+			WinRateA: analysisA.WinRate, WinRateB: analysisB.WinRate}
+		d.CrossA, d.CrossB = candidateWinRate(analysisA, d.MoveB), candidateWinRate(analysisB, d.MoveA)
+		diffs = append(diffs, d)
+		if !board.Play(g.Moves[i].Move) {
+			return diffs, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	return diffs, nil
+}
+
+func topMove(a MoveAnalysis) int {
+	if len(a.Candidates) == 0 {
+		return PassMove
+	}
+	return a.Candidates[0].Move
+}
+
+func candidateWinRate(a MoveAnalysis, move int) float64 {
+	for _, c := range a.Candidates {
+		if c.Move == move {
+			return c.WinRate
+		}
+	}
+	return math.NaN()
+}
+
+func percentOrDash(v float64) string {
+	if math.IsNaN(v) {
+		return "  -"
+	}
+	return fmt.Sprintf("%3.0f%%", 100*v)
+}
+
+func runEngineDiff(args []string) error {
+	fs := flag.NewFlagSet("engine-diff", flag.ExitOnError)
+	specA := fs.String("a", "playouts=1000", "first engine configuration, e.g. playouts=5000,threads=2 or profile=blitz")
+	specB := fs.String("b", "playouts=5000", "second engine configuration")
+	gap := fs.Float64("gap", 0.1, "report evaluations differing by at least this much")
+	from := fs.Int("from", 0, "start from the position after this many moves")
+	to := fs.Int("to", -1, "stop after this many moves (default the end of the game)")
+	all := fs.Bool("all", false, "list every position, not just where the engines diverge")
+	game := fs.Int("game", 1, "game number within the file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: engine-diff file.sgf [-a settings] [-b settings] [-gap 0.1] [-from N] [-to N] [-all] [-game N]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	a, err := newEngineFromSpec(*specA, g.Komi)
+	if err != nil {
+		return fmt.Errorf("-a: %v", err)
+	}
+	b, err := newEngineFromSpec(*specB, g.Komi)
+	if err != nil {
+		return fmt.Errorf("-b: %v", err)
+	}
+	end := len(g.Moves)
+	if *to >= 0 {
+		end = *to
+	}
+	diffs, err := DiffEngines(context.Background(), g, a, b, max(*from, 0), end)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	fmt.Printf("A: %s\nB: %s\n\n", *specA, *specB)
+	fmt.Printf("%4s    %-7s %4s  %-7s %4s  %4s  %s\n", "Move", "A plays", "", "B plays", "", "Δ", "each on the other's move")
+	moves, evals := 0, 0
+	total := 0.0
+	for _, d := range diffs {
+		delta := d.WinRateB - d.WinRateA
+		total += math.Abs(delta)
+		if d.MoveA != d.MoveB {
+			moves++
+		}
+		if math.Abs(delta) >= *gap {
+			evals++
+		}
+		if !*all && !d.Diverges(*gap) {
+			continue
+		}
+		cross := ""
+		if d.MoveA != d.MoveB {
+			cross = fmt.Sprintf("A %s, B %s", percentOrDash(d.CrossA), percentOrDash(d.CrossB))
+		}
+		fmt.Printf("%4d %s  %-7s %s  %-7s %s  %+4.0f%%  %s\n", d.MoveNumber, g.Moves[d.MoveNumber-1].Color,
+			userCoords.Move(d.MoveA, g.Size), percentOrDash(d.WinRateA), userCoords.Move(d.MoveB, g.Size), percentOrDash(d.WinRateB), 100*delta, cross)
+	}
+	if len(diffs) > 0 {
+		fmt.Printf("\nDifferent moves at %d of %d positions, evaluations %d%% or more apart at %d; mean difference %.1f%%\n",
+			moves, len(diffs), int(math.Round(100**gap)), evals, 100*total/float64(len(diffs)))
+	}
+	return nil
+}
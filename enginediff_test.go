@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEngineDiff(t *testing.T) {
+	a, err := newEngineFromSpec("playouts=50, noise=0", 0)
+	if err != nil || a.Playouts != 50 {
+		t.Fatalf("spec: %v, %d playouts", err, a.Playouts)
+	}
+	for _, spec := range []string{"playouts", "playouts=many", "depth=3", "profile=nosuch"} {
+		if _, err := newEngineFromSpec(spec, 0); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+
+	b, _ := newEngineFromSpec("playouts=100", 0)
+	g := &GameRecord{Size: 5, Komi: 0.5, Moves: []RecordedMove{{Color: Black, Move: 12}, {Color: White, Move: 7}, {Color: Black, Move: 13}}}
+	diffs, err := DiffEngines(context.Background(), g, a, b, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].MoveNumber != 2 || diffs[1].MoveNumber != 3 || a.Komi != 0.5 {
+		t.Fatalf("diffs %+v", diffs)
+	}
+	for _, d := range diffs {
+		if d.MoveA == 12 || d.MoveB == 12 || !d.Diverges(0) {
+			t.Errorf("diff %+v", d)
+		}
+	}
+	if same := (PositionDiff{MoveA: 3, MoveB: 3, WinRateA: 0.5, WinRateB: 0.55}); same.Diverges(0.1) {
+		t.Error("close evaluations of the same move diverge")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["engine"] = runEngine
+}
+
+// EngineOption is a setting an engine accepts at runtime, in the manner of
+// UCI's "option name ... type ...". Min and Max bound numeric options and
+// are empty when there is no bound; external engines may only report the
+// name and value.
+type EngineOption struct {
+	Name     string
+	Type     string // int, float, bool or string
+	Value    string
+	Min, Max string
+	Help     string
+}
+
+// Configurable is an engine that lists and changes its options while it
+// runs.
+type Configurable interface {
+	Options() ([]EngineOption, error)
+	SetOption(name, value string) error
+}
+
+// engineSetting binds an option of the built-in engine to its field.
+type engineSetting struct {
+	EngineOption
+	get func(e *Engine) string
+	set func(e *Engine, v float64)
+}
+
+var engineSettings = []engineSetting{
+	intSetting("playouts", "playouts per move", 1, 10_000_000,
+		func(e *Engine) *int { return &e.Playouts }),
+	intSetting("threads", "parallel search workers", 0, 256,
+		func(e *Engine) *int { return &e.Threads }),
+	intSetting("batch", "positions evaluated per batch", 0, 4096,
+		func(e *Engine) *int { return &e.BatchSize }),
+	{
+		EngineOption{Name: "split-tree", Type: "bool", Min: "0", Max: "1", Help: "give each thread a search tree of its own, merged at the end, 1 or 0"},
+		func(e *Engine) string { return map[bool]string{false: "0", true: "1"}[e.SplitTree] },
+		func(e *Engine, v float64) { e.SplitTree = v != 0 },
+	},
+	{
+		EngineOption{Name: "move-ms", Type: "int", Min: "0", Max: "3600000", Help: "most milliseconds to think about a move, 0 for no limit"},
+		func(e *Engine) string { return strconv.FormatInt(e.MoveTime.Milliseconds(), 10) },
+		func(e *Engine, v float64) { e.MoveTime = time.Duration(v) * time.Millisecond },
+	},
+	intSetting("choice", "play the k-th best candidate instead of the best", 1, 361,
+		func(e *Engine) *int { return &e.Choice }),
+	{
+		EngineOption{Name: "tree-mb", Type: "int", Min: "0", Max: "1048576", Help: "memory cap of the search tree in MB, 0 for none"},
+		func(e *Engine) string { return strconv.FormatInt(e.MaxMemory>>20, 10) },
+		func(e *Engine, v float64) { e.MaxMemory = int64(v) << 20 },
+	},
+	floatSetting("noise", "random noise on the final choice, as a fraction of the best visit count", 0, 10,
+		func(e *Engine) *float64 { return &e.Noise }),
+	floatSetting("komi", "komi the engine assumes", -1000, 1000,
+		func(e *Engine) *float64 { return &e.Komi }),
+	floatSetting("temperature", "play the opening moves at random in proportion to visits^(1/T), 0 for the best", 0, 10,
+		func(e *Engine) *float64 { return &e.Temperature }),
+	intSetting("temperature-moves", "opening moves, counted in stones on the board, played with the temperature", 0, 2704,
+		func(e *Engine) *int { return &e.TemperatureMoves }),
+	floatSetting("dirichlet", "weight of Dirichlet noise in the exploration of the root's moves", 0, 1,
+		func(e *Engine) *float64 { return &e.DirichletWeight }),
+	intSetting("tablebase", "largest board size solved exactly instead of searched, 0 for none", 0, tablebaseMaxSize,
+		func(e *Engine) *int { return &e.Tablebase }),
+	{
+		EngineOption{Name: "reuse", Type: "bool", Min: "0", Max: "1", Help: "keep the search tree from one move to the next, 1 or 0"},
+		func(e *Engine) string { return map[bool]string{false: "0", true: "1"}[e.Reuse] },
+		func(e *Engine, v float64) { e.Reuse = v != 0 },
+	},
+	intSetting("mercy", "end a playout when one side has captured this many stones more, 0 for never", 0, 1000,
+		func(e *Engine) *int { return &e.Playout.Mercy }),
+	intSetting("playout-length", "moves after which a playout is scored as it stands, 0 for three per point", 0, 100_000,
+		func(e *Engine) *int { return &e.Playout.MaxLength }),
+	{
+		EngineOption{Name: "eyes", Type: "int", Min: "0", Max: "2", Help: "eyes playouts do not fill: 0 simple, 1 true eyes only, 2 none"},
+		func(e *Engine) string { return strconv.Itoa(int(e.Playout.Eyes)) },
+		func(e *Engine, v float64) { e.Playout.Eyes = EyeRule(v) },
+	},
+	floatSetting("dirichlet-alpha", "concentration of the Dirichlet noise, 0 for 10/moves", 0, 100,
+		func(e *Engine) *float64 { return &e.DirichletAlpha }),
+}
+
+func intSetting(name, help string, min, max int, field func(*Engine) *int) engineSetting {
+	return engineSetting{
+		EngineOption{Name: name, Type: "int", Min: strconv.Itoa(min), Max: strconv.Itoa(max), Help: help},
+		func(e *Engine) string { return strconv.Itoa(*field(e)) },
+		func(e *Engine, v float64) { *field(e) = int(v) },
+	}
+}
+
+func floatSetting(name, help string, min, max float64, field func(*Engine) *float64) engineSetting {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	return engineSetting{
+		EngineOption{Name: name, Type: "float", Min: format(min), Max: format(max), Help: help},
+		func(e *Engine) string { return format(*field(e)) },
+		func(e *Engine, v float64) { *field(e) = v },
+	}
+}
+
+// Options lists the built-in engine's settings with their current values.
+func (e *Engine) Options() ([]EngineOption, error) {
+	opts := make([]EngineOption, len(engineSettings))
+	for i, s := range engineSettings {
+		opts[i] = s.EngineOption
+		opts[i].Value = s.get(e)
+	}
+	return opts, nil
+}
+
+// SetOption changes a setting, checking its type and range. It takes
+// effect from the next search.
+func (e *Engine) SetOption(name, value string) error {
+	for _, s := range engineSettings {
+		if s.Name != name {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || (s.Type == "int" && v != float64(int64(v))) {
+			kind := "a number"
+			if s.Type == "int" {
+				kind = "an integer"
+			}
+			return fmt.Errorf("%s takes %s, not %q", name, kind, value)
+		}
+		lo, _ := strconv.ParseFloat(s.Min, 64)
+		hi, _ := strconv.ParseFloat(s.Max, 64)
+		if v < lo || v > hi {
+			return fmt.Errorf("%s must be between %s and %s", name, s.Min, s.Max)
+		}
+		s.set(e, v)
+		return nil
+	}
+	return fmt.Errorf("unknown engine option %q", name)
+}
+
+// commands returns the commands the engine knows, from list_commands.
+func (c *gtpClient) commands() (map[string]bool, error) {
+	if c.known == nil {
+		reply, err := c.send("list_commands")
+		if err != nil {
+			return nil, err
+		}
+		c.known = make(map[string]bool)
+		for _, name := range strings.Fields(reply) {
+			c.known[name] = true
+		}
+	}
+	return c.known, nil
+}
+
+// Options lists an external engine's parameters. GTP has no standard for
+// this; KataGo's kata-list_params is used when the engine has it.
+// Engines that can only set options (Leela Zero's lz-setoption) list
+// none.
+func (c *gtpClient) Options() ([]EngineOption, error) {
+	known, err := c.commands()
+	if err != nil {
+		return nil, err
+	}
+	if !known["kata-list_params"] {
+		return nil, nil
+	}
+	reply, err := c.send("kata-list_params")
+	if err != nil {
+		return nil, err
+	}
+	var opts []EngineOption
+	for _, name := range strings.Fields(reply) {
+		value, err := c.send("kata-get_param %s", name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, EngineOption{Name: name, Type: "string", Value: value})
+	}
+	return opts, nil
+}
+
+// SetOption sets an external engine's parameter with kata-set_param or
+// lz-setoption, whichever it has.
+func (c *gtpClient) SetOption(name, value string) error {
+	known, err := c.commands()
+	if err != nil {
+		return err
+	}
+	switch {
+	case known["kata-set_param"]:
+		_, err = c.send("kata-set_param %s %s", name, value)
+	case known["lz-setoption"]:
+		_, err = c.send("lz-setoption name %s value %s", name, value)
+	default:
+		err = errors.New("the engine has no command to set options")
+	}
+	return err
+}
+
+// formatOptions lists options one per line, as the engine command and the
+// in-game "engine options" print them.
+func formatOptions(opts []EngineOption) string {
+	if len(opts) == 0 {
+		return "no options"
+	}
+	sort.Slice(opts, func(i, j int) bool { return opts[i].Name < opts[j].Name })
+	var sb strings.Builder
+	for i, o := range opts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%-10s %s", o.Name, o.Value)
+		if o.Type != "" && o.Type != "string" {
+			fmt.Fprintf(&sb, "  (%s", o.Type)
+			if o.Min != "" || o.Max != "" {
+				fmt.Fprintf(&sb, " %s..%s", o.Min, o.Max)
+			}
+			sb.WriteString(")")
+		}
+		if o.Help != "" {
+			sb.WriteString("  " + o.Help)
+		}
+	}
+	return sb.String()
+}
+
+// engineCommand runs "options" or "set NAME VALUE" against c and returns
+// what to show the user.
+func engineCommand(c Configurable, args []string) (string, error) {
+	switch {
+	case len(args) == 1 && args[0] == "options":
+		opts, err := c.Options()
+		if err != nil {
+			return "", err
+		}
+		return formatOptions(opts), nil
+	case len(args) == 3 && args[0] == "set":
+		if err := c.SetOption(args[1], args[2]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s set to %s", args[1], args[2]), nil
+	}
+	return "", errors.New("usage: engine options | engine set NAME VALUE")
+}
+
+func runEngine(args []string) error {
+	fs := flag.NewFlagSet("engine", flag.ExitOnError)
+	gtpCommand := fs.String("gtp", "", "command running an external engine in GTP mode")
+	fs.Parse(args)
+
+	var c Configurable = NewEngine(1000, 6.5)
+	if *gtpCommand != "" {
+		client, err := startGTP(*gtpCommand)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		c = client
+	}
+	out, err := engineCommand(c, fs.Args())
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
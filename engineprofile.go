@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["profiles"] = runProfiles
+}
+
+// EngineProfile bundles the computer's settings under a name, selected
+// with -profile instead of setting each flag.
+type EngineProfile struct {
+	Playouts int  `json:"playouts"`
+	Threads  int  `json:"threads"`
+	Ponder   bool `json:"ponder"` // think on the opponent's time, see ponder.go
+	// MoveMS is the most the computer thinks about a move, in
+	// milliseconds, whatever the playouts; 0 for no limit.
+	MoveMS int `json:"move_ms"`
+	// ResignBelow is the win rate under which the computer resigns, 0
+	// to play every game out.
+	ResignBelow float64 `json:"resign_below"`
+}
+
+// builtinProfiles are the profiles every installation has. The config
+// file may change their settings or add profiles of its own.
+var builtinProfiles = map[string]EngineProfile{
+	"blitz":    {Playouts: 300, Threads: 1, MoveMS: 300, ResignBelow: 0.15},
+	"normal":   {Playouts: 1000, Threads: 1, Ponder: true, ResignBelow: 0.1},
+	"analysis": {Playouts: 20000, Threads: runtime.NumCPU(), Ponder: true},
+}
+
+// gogameConfig is the optional config file, config.json in the data
+// directory:
+//
+//	{"profiles": {"normal": {"playouts": 2000}, "club": {"playouts": 3000, "threads": 4}},
+//	 "coordinates": "western"}
+//
+// Settings left out of a built-in profile keep their built-in values.
+// Coordinates are the user's coordinate system, see coords.go, and Theme
+// and Themes those of board images, see theme.go.
+type gogameConfig struct {
+	Profiles    map[string]json.RawMessage `json:"profiles"`
+	Coordinates string                     `json:"coordinates"`
+	Theme       string                     `json:"theme"`
+	Themes      map[string]json.RawMessage `json:"themes"`
+}
+
+func configPath() string {
+	return dataPath("config.json")
+}
+
+// EngineProfiles returns the built-in profiles as changed by the config
+// file at path, and those it adds.
+func EngineProfiles(path string) (map[string]EngineProfile, error) {
+	profiles := make(map[string]EngineProfile, len(builtinProfiles))
+	for name, p := range builtinProfiles {
+		profiles[name] = p
+	}
+	var config gogameConfig
+	if err := loadJSON(path, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, raw := range config.Profiles {
+		p := profiles[name]
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %v", path, name, err)
+		}
+		if p.Playouts < 1 || p.Threads < 0 || p.MoveMS < 0 || p.ResignBelow < 0 || p.ResignBelow >= 1 {
+			return nil, fmt.Errorf("%s: profile %q: invalid settings %+v", path, name, p)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// FindEngineProfile looks up a profile by name.
+func FindEngineProfile(path, name string) (EngineProfile, error) {
+	profiles, err := EngineProfiles(path)
+	if err != nil {
+		return EngineProfile{}, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return EngineProfile{}, fmt.Errorf("unknown profile %q, see the profiles command", name)
+	}
+	return p, nil
+}
+
+func runProfiles(args []string) error {
+	profiles, err := EngineProfiles(configPath())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("%-12s %9s %7s %8s %6s %7s\n", "Profile", "Playouts", "Threads", "Move", "Ponder", "Resign")
+	for _, name := range names {
+		p := profiles[name]
+		resign := "never"
+		if p.ResignBelow > 0 {
+			resign = fmt.Sprintf("<%.0f%%", 100*p.ResignBelow)
+		}
+		move := "-"
+		if p.MoveMS > 0 {
+			move = (time.Duration(p.MoveMS) * time.Millisecond).String()
+		}
+		fmt.Printf("%-12s %9d %7d %8s %6v %7s\n", name, p.Playouts, p.Threads, move, p.Ponder, resign)
+	}
+	fmt.Printf("Profiles can be changed or added in %s\n", configPath())
+	return nil
+}
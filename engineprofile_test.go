@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if p, err := FindEngineProfile(path, "blitz"); err != nil || p != builtinProfiles["blitz"] {
+		t.Errorf("blitz without a config file: %+v, %v", p, err)
+	}
+	os.WriteFile(path, []byte(`{"profiles": {"normal": {"playouts": 2000}, "club": {"playouts": 3000, "threads": 4}}}`), 0o644)
+	normal, err := FindEngineProfile(path, "normal")
+	if err != nil || normal.Playouts != 2000 || !normal.Ponder || normal.ResignBelow != 0.1 {
+		t.Errorf("normal changed by the config file: %+v, %v", normal, err)
+	}
+	if club, err := FindEngineProfile(path, "club"); err != nil || club.Threads != 4 || club.Ponder {
+		t.Errorf("club from the config file: %+v, %v", club, err)
+	}
+	if _, err := FindEngineProfile(path, "nope"); err == nil {
+		t.Error("unknown profile found")
+	}
+	os.WriteFile(path, []byte(`{"profiles": {"bad": {"playouts": 0}}}`), 0o644)
+	if _, err := EngineProfiles(path); err == nil {
+		t.Error("profile without playouts accepted")
+	}
+}
+
+func TestPonder(t *testing.T) {
+	e := NewEngine(200, 0.5)
+	b := NewBoard(5)
+	p := startPonder(e, b)
+	<-p.done
+	p.stop()
+	if !p.ready {
+		t.Fatal("pondering did not finish")
+	}
+	if _, ok := p.hit(b); ok {
+		t.Error("hit before the guessed move was played")
+	}
+	// Play the guess: the position after it is the one pondered.
+	for m := PassMove; m < 25; m++ {
+		next := b.Copy()
+		if next.Play(m) && next.Hash() == p.after {
+			if reply, ok := p.hit(next); !ok || reply == m && m != PassMove {
+				t.Errorf("hit = %d, %v", reply, ok)
+			}
+			return
+		}
+	}
+	t.Error("no move reaches the pondered position")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Evaluator scores leaf positions for the search. Evaluate is called with
+// batches of positions, from one goroutine at a time, and returns for each
+// the probability that Black wins. It may modify the positions.
+type Evaluator interface {
+	Evaluate(positions []*Board) []float64
+}
+
+// OwnershipEvaluator is an Evaluator, a network for instance, that also
+// predicts who ends up with each point, as Engine.Ownership reports it.
+// Engines whose Evaluator has it use it instead of random playouts.
+type OwnershipEvaluator interface {
+	Evaluator
+	Ownership(b *Board) []float64
+}
+
+// ScoreEvaluator is an Evaluator that also estimates the final score of
+// each position, Black's lead by area with komi; the engine's searches
+// report how the estimates spread, see scoredist.go.
+type ScoreEvaluator interface {
+	Evaluator
+	EvaluateScores(positions []*Board) (values, leads []float64)
+}
+
+// playoutEvaluator scores a position by one random playout. A playout the
+// mercy rule ended is won by the side it favours, whatever the score of
+// the unfinished board.
+type playoutEvaluator struct {
+	komi   float64
+	policy PlayoutPolicy
+	rng    *rand.Rand
+}
+
+func newPlayoutEvaluator(komi float64, policy PlayoutPolicy) *playoutEvaluator {
+	return &playoutEvaluator{komi: komi, policy: policy, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (p *playoutEvaluator) Evaluate(positions []*Board) []float64 {
+	values, _ := p.EvaluateScores(positions)
+	return values
+}
+
+func (p *playoutEvaluator) EvaluateScores(positions []*Board) (values, leads []float64) {
+	values, leads = make([]float64, len(positions)), make([]float64, len(positions))
+	for i, b := range positions {
+		mercy := p.policy.Play(b, p.rng)
+		black, white := b.AreaScore()
+		leads[i] = float64(black-white) - p.komi
+		switch {
+		case mercy == Black:
+			values[i] = 1
+		case mercy == White: // a loss for Black, 0
+		case leads[i] > 0:
+			values[i] = 1
+		case leads[i] == 0:
+			values[i] = 0.5
+		}
+	}
+	return values, leads
+}
+
+// evalQueue collects positions from the search workers and evaluates them
+// in batches of up to size, so a model's per-call overhead is shared.
+// Whatever is waiting is evaluated as soon as the evaluator is free; a
+// batch is not held back to fill up.
+type evalQueue struct {
+	eval     Evaluator
+	owner    OwnershipEvaluator // eval, if it predicts ownership
+	size     int
+	pin      func() // nil, or run first by the evaluating goroutine
+	requests chan evalRequest
+}
+
+type evalRequest struct {
+	pos    *Board
+	result chan float64
+	owned  chan []float64 // for the ownership of pos instead
+}
+
+func newEvalQueue(eval Evaluator, size int, pin func()) *evalQueue {
+	q := &evalQueue{eval: eval, size: size, pin: pin, requests: make(chan evalRequest, size)}
+	q.owner, _ = eval.(OwnershipEvaluator)
+	if r, ok := eval.(scoreRecorder); ok {
+		q.owner, _ = r.ScoreEvaluator.(OwnershipEvaluator)
+	}
+	go q.run()
+	return q
+}
+
+// evaluate queues pos and waits for its value.
+func (q *evalQueue) evaluate(pos *Board) float64 {
+	result := make(chan float64, 1)
+	q.requests <- evalRequest{pos: pos, result: result}
+	return <-result
+}
+
+// ownership asks the evaluator, between batches, for the ownership of pos
+// and waits for it. The queue's evaluator must be an OwnershipEvaluator.
+func (q *evalQueue) ownership(pos *Board) []float64 {
+	owned := make(chan []float64, 1)
+	q.requests <- evalRequest{pos: pos, owned: owned}
+	return <-owned
+}
+
+// close stops the queue once no more positions will be sent.
+func (q *evalQueue) close() {
+	close(q.requests)
+}
+
+func (q *evalQueue) run() {
+	if q.pin != nil {
+		q.pin()
+	}
+	batch := make([]evalRequest, 0, q.size)
+	positions := make([]*Board, 0, q.size)
+	for r := range q.requests {
+		if q.answerOwnership(r) {
+			continue
+		}
+		batch = append(batch[:0], r)
+	fill:
+		for len(batch) < q.size {
+			select {
+			case r, ok := <-q.requests:
+				if !ok {
+					break fill
+				}
+				if !q.answerOwnership(r) {
+					batch = append(batch, r)
+				}
+			default:
+				break fill
+			}
+		}
+		positions = positions[:0]
+		for _, r := range batch {
+			positions = append(positions, r.pos)
+		}
+		for i, v := range q.eval.Evaluate(positions) {
+			batch[i].result <- v
+		}
+	}
+}
+
+// answerOwnership answers r at once if it asks for ownership, and reports
+// whether it did.
+func (q *evalQueue) answerOwnership(r evalRequest) bool {
+	if r.owned == nil {
+		return false
+	}
+	r.owned <- q.owner.Ownership(r.pos)
+	return true
+}
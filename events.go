@@ -0,0 +1,243 @@
+package main
+
+// Game state as an append-only event log. Every change to a game is an
+// event, and the position is what Reduce makes of the events in order, so
+// undo, replay, audit trails and network sync all work from the same
+// record. This file is part of the core (see board.go).
+
+import (
+	"errors"
+	"fmt"
+)
+
+type EventKind string
+
+const (
+	MovePlayed     EventKind = "move"    // Color played at Move
+	StonesCaptured EventKind = "capture" // the move before took Stones
+	Passed         EventKind = "pass"
+	Resigned       EventKind = "resign"
+	ClockExpired   EventKind = "timeout" // Color ran out of time
+)
+
+// GameEvent is one entry of a GameLog. Seq numbers events from 1 in the
+// order they happened.
+type GameEvent struct {
+	Seq    int       `json:"seq"`
+	Kind   EventKind `json:"kind"`
+	Color  Stone     `json:"color"`
+	Move   int       `json:"move"`
+	Stones []int     `json:"stones,omitempty"`
+}
+
+// Reduce applies e to b. It checks that e fits the position but not the
+// rules: the log only holds moves that were legal when played, so a
+// replay needs no capture or suicide logic and gives back exactly the
+// positions the players saw.
+func Reduce(b *Board, e GameEvent) error {
+	switch e.Kind {
+	case MovePlayed:
+		if e.Color != b.turn {
+			return fmt.Errorf("event %d: %s moved out of turn", e.Seq, e.Color)
+		}
+		row, col := e.Move/b.size, e.Move%b.size
+		if !b.isInBounds(row, col) || b.grid[row][col] != Empty {
+			return fmt.Errorf("event %d: point %d %d is not empty", e.Seq, row, col)
+		}
+		b.setPoint(e.Move, e.Color)
+		b.passes = 0
+		b.nextTurn()
+	case StonesCaptured:
+		for _, p := range e.Stones {
+			if p < 0 || p >= b.size*b.size || b.grid[p/b.size][p%b.size] != e.Color {
+				return fmt.Errorf("event %d: no %s stone to capture at %d %d", e.Seq, e.Color, p/b.size, p%b.size)
+			}
+			b.setPoint(p, Empty)
+		}
+	case Passed:
+		if e.Color != b.turn {
+			return fmt.Errorf("event %d: %s passed out of turn", e.Seq, e.Color)
+		}
+		b.Pass()
+	case Resigned, ClockExpired:
+		// The game is over; the board does not change.
+	default:
+		return fmt.Errorf("event %d: unknown kind %q", e.Seq, e.Kind)
+	}
+	return nil
+}
+
+// GameLog is a game's events from a starting position, with the position
+// they lead to.
+type GameLog struct {
+	start  *Board
+	events []GameEvent
+	board  *Board
+}
+
+// NewGameLog starts a log at start, which may hold setup or handicap
+// stones.
+func NewGameLog(start *Board) *GameLog {
+	return &GameLog{start: start.Copy(), board: start.Copy()}
+}
+
+// Board is the current position. It belongs to the log, which is the only
+// one to change it, and it is replaced after an Undo.
+func (l *GameLog) Board() BoardView { return l.board }
+
+// Events returns the log so far.
+func (l *GameLog) Events() []GameEvent { return append([]GameEvent(nil), l.events...) }
+
+func (l *GameLog) append(e GameEvent) {
+	e.Seq = len(l.events) + 1
+	l.events = append(l.events, e)
+}
+
+// Play records a move or pass by the side to move, with the stones it
+// captures, or reports why the move is illegal.
+func (l *GameLog) Play(move int) error {
+	if over, _ := l.Over(); over {
+		return errors.New("the game is over")
+	}
+	color := l.board.turn
+	if move == PassMove {
+		l.board.Pass()
+		l.append(GameEvent{Kind: Passed, Color: color})
+		return nil
+	}
+	before := l.board.Copy()
+	if !l.board.Play(move) {
+		return errors.New("illegal move")
+	}
+	l.append(GameEvent{Kind: MovePlayed, Color: color, Move: move})
+	var captured []int
+	for p := 0; p < l.board.size*l.board.size; p++ {
+		if before.grid[p/l.board.size][p%l.board.size] == color.Opponent() && l.board.grid[p/l.board.size][p%l.board.size] == Empty {
+			captured = append(captured, p)
+		}
+	}
+	if len(captured) > 0 {
+		l.append(GameEvent{Kind: StonesCaptured, Color: color.Opponent(), Stones: captured})
+	}
+	return nil
+}
+
+// Resign records that color gave up.
+func (l *GameLog) Resign(color Stone) { l.append(GameEvent{Kind: Resigned, Color: color}) }
+
+// Expire records that color ran out of time.
+func (l *GameLog) Expire(color Stone) { l.append(GameEvent{Kind: ClockExpired, Color: color}) }
+
+// Over reports whether the game has ended, and the event that ended it
+// when that was a resignation or a timeout.
+func (l *GameLog) Over() (bool, *GameEvent) {
+	if n := len(l.events); n > 0 {
+		if e := l.events[n-1]; e.Kind == Resigned || e.Kind == ClockExpired {
+			return true, &e
+		}
+	}
+	return l.board.IsGameOver(), nil
+}
+
+// Moves is the number of moves and passes played.
+func (l *GameLog) Moves() int {
+	n := 0
+	for _, e := range l.events {
+		if e.Kind == MovePlayed || e.Kind == Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// Undo takes back the last n moves or passes, with their captures, and
+// rebuilds the position from the start. It returns how many were taken
+// back.
+func (l *GameLog) Undo(n int) int {
+	undone := 0
+	cut := len(l.events)
+	for cut > 0 && undone < n {
+		cut--
+		switch l.events[cut].Kind {
+		case MovePlayed, Passed:
+			undone++
+		}
+	}
+	if undone == 0 {
+		return 0
+	}
+	l.events = l.events[:cut]
+	b, err := ReplayEvents(l.start, l.events)
+	if err != nil {
+		// The prefix of a valid log is valid.
+		panic(err)
+	}
+	l.board = b
+	return undone
+}
+
+// ReplayEvents reduces events over a copy of start.
+func ReplayEvents(start *Board, events []GameEvent) (*Board, error) {
+	b := start.Copy()
+	for _, e := range events {
+		if err := Reduce(b, e); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Captures is the number of stones color has captured.
+func (l *GameLog) Captures(color Stone) int {
+	n := 0
+	for _, e := range l.events {
+		if e.Kind == StonesCaptured && e.Color == color.Opponent() {
+			n += len(e.Stones)
+		}
+	}
+	return n
+}
+
+// PositionRecord is one step of a game's history: a move or pass and the
+// position it led to, hashed with the side to move as Board.Hash does.
+type PositionRecord struct {
+	Number int    `json:"number"` // moves and passes played, 0 at the start
+	Color  Stone  `json:"color"`  // Empty at the start
+	Move   int    `json:"move"`   // PassMove for a pass or the start
+	Hash   uint64 `json:"hash,string"`
+}
+
+// PositionHistory is the starting position followed by every move since.
+// Two copies of a game agree up to the last position whose hashes match,
+// so a client can check it is in step by comparing one hash and, when it
+// is not, ask for the moves after the last position it shares.
+func (l *GameLog) PositionHistory() []PositionRecord {
+	b := l.start.Copy()
+	history := []PositionRecord{{Move: PassMove, Hash: b.Hash()}}
+	for _, e := range l.events {
+		Reduce(b, e)
+		switch e.Kind {
+		case MovePlayed:
+			history = append(history, PositionRecord{Number: len(history), Color: e.Color, Move: e.Move})
+		case Passed:
+			history = append(history, PositionRecord{Number: len(history), Color: e.Color, Move: PassMove})
+		}
+		// A move's captures are part of the move.
+		history[len(history)-1].Hash = b.Hash()
+	}
+	return history
+}
+
+// MovesSince returns the moves played after the last position hashed
+// hash, the suffix a client at that position is missing. ok is false if
+// the game never reached the position; the client has diverged and must
+// start again from the beginning of the history.
+func (l *GameLog) MovesSince(hash uint64) (moves []PositionRecord, ok bool) {
+	history := l.PositionHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Hash == hash {
+			return history[i+1:], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestGameLog(t *testing.T) {
+	l := NewGameLog(NewBoard(5))
+	// Black captures the white stone at 0 0 with its fourth move.
+	for _, m := range []int{5, 0, 12, 20, 1} {
+		if err := l.Play(m); err != nil {
+			t.Fatalf("move %d: %v", m, err)
+		}
+	}
+	events := l.Events()
+	last := events[len(events)-1]
+	if last.Kind != StonesCaptured || last.Color != White || len(last.Stones) != 1 || last.Stones[0] != 0 {
+		t.Fatalf("last event = %+v", last)
+	}
+	if err := l.Play(1); err == nil {
+		t.Error("played on an occupied point")
+	}
+
+	b, err := ReplayEvents(NewBoard(5), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Hash() != l.Board().Hash() {
+		t.Error("replay differs from the game")
+	}
+
+	if n := l.Undo(1); n != 1 || l.Board().At(0, 0) != White || l.Board().At(0, 1) != Empty || l.Board().Turn() != Black {
+		t.Errorf("undo did not restore the capture: %d undone", n)
+	}
+	if l.Moves() != 4 || len(l.Events()) != 4 {
+		t.Errorf("%d moves, %d events after undo", l.Moves(), len(l.Events()))
+	}
+
+	l.Resign(Black)
+	if over, e := l.Over(); !over || e == nil || e.Kind != Resigned {
+		t.Error("resignation did not end the game")
+	}
+	if err := l.Play(PassMove); err == nil {
+		t.Error("played after resigning")
+	}
+}
+
+func TestReduceRejects(t *testing.T) {
+	b := NewBoard(5)
+	for _, e := range []GameEvent{
+		{Seq: 1, Kind: MovePlayed, Color: White, Move: 3},
+		{Seq: 1, Kind: StonesCaptured, Color: White, Stones: []int{3}},
+		{Seq: 1, Kind: "teleport"},
+	} {
+		if err := Reduce(b, e); err == nil {
+			t.Errorf("Reduce accepted %+v", e)
+		}
+	}
+}
+
+func TestPositionHistory(t *testing.T) {
+	l := NewGameLog(NewBoard(5))
+	for _, m := range []int{5, 0, 12, PassMove, 1} {
+		if err := l.Play(m); err != nil {
+			t.Fatalf("move %d: %v", m, err)
+		}
+	}
+	history := l.PositionHistory()
+	if len(history) != 6 || history[0].Hash != NewBoard(5).Hash() || history[4].Move != PassMove || history[4].Color != White {
+		t.Fatalf("history = %+v", history)
+	}
+	if history[5].Hash != l.Board().Hash() {
+		t.Error("the last hash is not the position's, captures included")
+	}
+
+	moves, ok := l.MovesSince(history[2].Hash)
+	if !ok || len(moves) != 3 || moves[0].Number != 3 || moves[0].Move != 12 {
+		t.Errorf("MovesSince(move 2) = %+v, %v", moves, ok)
+	}
+	if moves, ok := l.MovesSince(l.Board().Hash()); !ok || len(moves) != 0 {
+		t.Errorf("MovesSince(now) = %+v, %v", moves, ok)
+	}
+	if _, ok := l.MovesSince(12345); ok {
+		t.Error("MovesSince found a position the game never reached")
+	}
+}
+
+func TestBoardViewConsumers(t *testing.T) {
+	l := NewGameLog(NewBoard(5))
+	for _, m := range []int{6, 12, 8} {
+		if err := l.Play(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	view := l.Board()
+	hash, turn := view.Hash(), view.Turn()
+	e := NewEngine(50, 0)
+	if _, _, _, err := e.GenMove(context.Background(), view); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (randomPlayer{rand.New(rand.NewSource(1))}).GenMove(context.Background(), view); err != nil {
+		t.Fatal(err)
+	}
+	WriteSVG(io.Discard, view, DefaultSVGOptions())
+	RenderThumbnail(view, 40)
+	if view.Hash() != hash || view.Turn() != turn || l.Moves() != 3 {
+		t.Error("a consumer changed the game's board")
+	}
+}
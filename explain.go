@@ -0,0 +1,169 @@
+package main
+
+// Move explanations: what a move would do and, when it is illegal, why,
+// for tooltips and teaching rather than for search. The rules themselves
+// stay in IsLegal and PlaceStone; an explanation plays the move on a copy
+// and reports what happened to the groups around it. This file is part of
+// the core (see board.go).
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MoveVerdict says whether a move may be played, or why not.
+type MoveVerdict string
+
+const (
+	MoveLegal     MoveVerdict = "legal"
+	MoveOffBoard  MoveVerdict = "off-board"
+	MoveOccupied  MoveVerdict = "occupied"
+	MoveSuicide   MoveVerdict = "suicide"   // the stone's group would have no liberty
+	MoveKo        MoveVerdict = "ko"        // the position would repeat
+	MoveForbidden MoveVerdict = "forbidden" // by a rules plugin, see Reason
+)
+
+// MoveExplanation describes a move. Captures and Ataris are the opponent
+// groups the move would take and leave with one liberty, each listed in
+// point order. Group is the stone's group after the move, with its
+// liberties: for a suicide, the stones that would be left without one.
+type MoveExplanation struct {
+	Move      int         `json:"move"` // PassMove off the board
+	Color     Stone       `json:"color"`
+	Verdict   MoveVerdict `json:"verdict"`
+	Captures  [][]int     `json:"captures,omitempty"`
+	Ataris    [][]int     `json:"ataris,omitempty"`
+	Group     []int       `json:"group,omitempty"`
+	Liberties int         `json:"liberties"`
+	KoMove    int         `json:"koMove,omitempty"` // for MoveKo, the move after which the position was on the board, 0 for the start
+	Reason    string      `json:"reason,omitempty"` // for MoveForbidden
+}
+
+// Legal reports whether the move may be played.
+func (x MoveExplanation) Legal() bool { return x.Verdict == MoveLegal }
+
+// Text explains the move in a sentence.
+func (x MoveExplanation) Text() string {
+	switch x.Verdict {
+	case MoveOffBoard:
+		return "the point is off the board"
+	case MoveOccupied:
+		return "the point is already taken"
+	case MoveSuicide:
+		if len(x.Group) == 1 {
+			return "suicide: the stone would have no liberty and capture nothing"
+		}
+		return fmt.Sprintf("suicide: the stone would leave its group of %d with no liberty and capture nothing", len(x.Group))
+	case MoveKo:
+		if x.KoMove == 0 {
+			return "ko: the move would bring back the starting position"
+		}
+		return fmt.Sprintf("ko: the move would bring back the position after move %d", x.KoMove)
+	case MoveForbidden:
+		return x.Reason
+	}
+	var parts []string
+	if n := stoneCount(x.Captures); n > 0 {
+		parts = append(parts, fmt.Sprintf("captures %s", plural(n, "stone")))
+	}
+	if n := stoneCount(x.Ataris); n > 0 {
+		parts = append(parts, fmt.Sprintf("puts %s in atari", plural(n, "stone")))
+	}
+	parts = append(parts, fmt.Sprintf("leaves its group of %d with %s", len(x.Group), plural(x.Liberties, "liberty")))
+	return strings.Join(parts, ", ")
+}
+
+func stoneCount(groups [][]int) int {
+	n := 0
+	for _, g := range groups {
+		n += len(g)
+	}
+	return n
+}
+
+func plural(n int, word string) string {
+	switch {
+	case n == 1:
+		return "1 " + word
+	case strings.HasSuffix(word, "y"):
+		return fmt.Sprintf("%d %sies", n, word[:len(word)-1])
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// ExplainMove explains a stone of color at row, col under the basic
+// rules, whoever is to move. The board knows no history, so it never
+// answers MoveKo; see Ruleset.ExplainMove.
+func (b *Board) ExplainMove(row, col int, color Stone) MoveExplanation {
+	x := MoveExplanation{Move: PassMove, Color: color, Verdict: MoveLegal}
+	if !b.isInBounds(row, col) {
+		x.Verdict = MoveOffBoard
+		return x
+	}
+	p := row*b.size + col
+	x.Move = p
+	if b.grid[row][col] != Empty {
+		x.Verdict = MoveOccupied
+		return x
+	}
+
+	after := b.Copy()
+	after.grid[row][col] = color
+	after.legal = newLegalCache(b.size)
+	c := after.legal
+	var seen []int
+	for _, n := range after.neighbours(p) {
+		if after.grid[n/b.size][n%b.size] != color.Opponent() || slices.Contains(seen, n) {
+			continue
+		}
+		stones := c.stones(after, n)
+		seen = append(seen, stones...)
+		slices.Sort(stones)
+		switch c.liberties(after, n, 2, nil) {
+		case 0:
+			x.Captures = append(x.Captures, stones)
+		case 1:
+			x.Ataris = append(x.Ataris, stones)
+		}
+	}
+	for _, g := range x.Captures {
+		for _, q := range g {
+			after.grid[q/b.size][q%b.size] = Empty
+		}
+	}
+	x.Group = c.stones(after, p)
+	slices.Sort(x.Group)
+	x.Liberties = c.liberties(after, p, b.size*b.size, nil)
+	if x.Liberties == 0 {
+		x.Verdict, x.Ataris = MoveSuicide, nil
+	}
+	return x
+}
+
+// Repeats reports whether move, by the side to move, would bring back an
+// earlier arrangement of stones, and if so, after how many moves and
+// passes it was last on the board.
+func (l *GameLog) Repeats(move int) (after int, ok bool) {
+	b := l.board.Copy()
+	if move == PassMove || !b.Play(move) {
+		return 0, false
+	}
+	h := b.stoneHash(0)
+	b = l.start.Copy()
+	ok = b.stoneHash(0) == h
+	n := 0
+	for i, e := range l.events {
+		Reduce(b, e)
+		switch e.Kind {
+		case MovePlayed, Passed:
+			n++
+		}
+		// A move's captures are part of the move.
+		capturing := i+1 < len(l.events) && l.events[i+1].Kind == StonesCaptured
+		if (e.Kind == StonesCaptured || e.Kind == MovePlayed && !capturing) && b.stoneHash(0) == h {
+			after, ok = n, true
+		}
+	}
+	return after, ok
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainMove(t *testing.T) {
+	b := NewBoard(5)
+	for p, s := range map[int]Stone{0: White, 1: Black, 12: White, 7: Black, 11: Black, 19: White, 23: White} {
+		b.setPoint(p, s)
+	}
+	x := b.ExplainMove(1, 0, Black)
+	if !x.Legal() || !reflect.DeepEqual(x.Captures, [][]int{{0}}) || x.Liberties != 3 {
+		t.Errorf("capture: %+v", x)
+	}
+	x = b.ExplainMove(2, 3, Black)
+	if !reflect.DeepEqual(x.Ataris, [][]int{{12}}) || x.Captures != nil {
+		t.Errorf("atari: %+v", x)
+	}
+	if got, want := x.Text(), "puts 1 stone in atari, leaves its group of 1 with 3 liberties"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	x = b.ExplainMove(4, 4, Black)
+	if x.Verdict != MoveSuicide || !reflect.DeepEqual(x.Group, []int{24}) || x.Liberties != 0 {
+		t.Errorf("suicide: %+v", x)
+	}
+	if x := b.ExplainMove(0, 1, White); x.Verdict != MoveOccupied {
+		t.Errorf("occupied: %+v", x)
+	}
+	if x := b.ExplainMove(5, 0, White); x.Verdict != MoveOffBoard || x.Move != PassMove {
+		t.Errorf("off the board: %+v", x)
+	}
+
+	// The ko of TestRulesPlugins: White may not take back at once.
+	rules, _ := NewRuleset([]string{"superko"})
+	game := NewGameLog(NewBoard(5))
+	for _, m := range []int{1, 2, 5, 12, 11, 8, 24, 6, 7} {
+		game.Play(m)
+	}
+	if x := rules.ExplainMove(game, 6); x.Verdict != MoveKo || x.KoMove != 8 || x.Text() != "ko: the move would bring back the position after move 8" {
+		t.Errorf("ko: %+v", x)
+	}
+	if x := (Ruleset{}).ExplainMove(game, 6); !x.Legal() || len(x.Captures) != 1 {
+		t.Errorf("ko with no superko rule: %+v", x)
+	}
+}
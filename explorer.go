@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["explore"] = runExplore
+}
+
+// MoveStats counts how often a move was played from a position and how
+// those games ended.
+type MoveStats struct {
+	Move      int
+	Games     int
+	BlackWins int
+	WhiteWins int
+}
+
+// WinRate is the share of decided games won by color.
+func (s *MoveStats) WinRate(color Stone) float64 {
+	decided := s.BlackWins + s.WhiteWins
+	if decided == 0 {
+		return 0.5
+	}
+	wins := s.BlackWins
+	if color == White {
+		wins = s.WhiteWins
+	}
+	return float64(wins) / float64(decided)
+}
+
+// OpeningExplorer indexes the opening moves of the database by position,
+// so transpositions share their statistics.
+type OpeningExplorer struct {
+	size  int
+	next  map[string]map[int]*MoveStats
+	games int
+}
+
+// BuildExplorer replays the first depth moves of every game of the given
+// board size among games.
+func BuildExplorer(games []DBGame, size, depth int) (*OpeningExplorer, error) {
+	e := &OpeningExplorer{size: size, next: make(map[string]map[int]*MoveStats)}
+	for i := range games {
+		dbg := &games[i]
+		if dbg.Size != size {
+			continue
+		}
+		g, err := dbg.Record()
+		if err != nil {
+			return nil, err
+		}
+		winner := resultWinner(g.Result)
+		b := g.Start()
+		for _, m := range g.Moves[:min(depth, len(g.Moves))] {
+			key := string(EncodePosition(b))
+			moves := e.next[key]
+			if moves == nil {
+				moves = make(map[int]*MoveStats)
+				e.next[key] = moves
+			}
+			s := moves[m.Move]
+			if s == nil {
+				s = &MoveStats{Move: m.Move}
+				moves[m.Move] = s
+			}
+			s.Games++
+			switch winner {
+			case Black:
+				s.BlackWins++
+			case White:
+				s.WhiteWins++
+			}
+			b.turn = m.Color
+			if !b.Play(m.Move) {
+				break
+			}
+		}
+		e.games++
+	}
+	return e, nil
+}
+
+// Next returns the moves played from b, most frequent first.
+func (e *OpeningExplorer) Next(b *Board) []*MoveStats {
+	var stats []*MoveStats
+	for _, s := range e.next[string(EncodePosition(b))] {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Games != stats[j].Games {
+			return stats[i].Games > stats[j].Games
+		}
+		return stats[i].Move < stats[j].Move
+	})
+	return stats
+}
+
+func runExplore(args []string) error {
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	size := fs.Int("size", 19, "board size")
+	depth := fs.Int("depth", 40, "number of opening moves to index")
+	top := fs.Int("top", 10, "number of moves to list")
+	fs.Parse(args)
+
+	games, err := loadGames(*dbPath)
+	if err != nil {
+		return err
+	}
+	e, err := BuildExplorer(games, *size, *depth)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d games on %dx%d\n", e.games, *size, *size)
+	fmt.Printf("Enter a list number or a move such as '%s' to play, 'back' to undo, 'quit' to exit\n", userCoords.Move(3**size+3, *size))
+
+	history := []*Board{NewBoard(*size)}
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		b := history[len(history)-1]
+		showBoard(b)
+		stats := e.Next(b)
+		if len(stats) == 0 {
+			fmt.Println("No games reach this position.")
+		} else {
+			total := 0
+			for _, s := range stats {
+				total += s.Games
+			}
+			fmt.Printf("%3s  %-7s %6s %7s %9s\n", "#", "Move", "Games", "Share", "Win rate")
+			for i, s := range stats[:min(*top, len(stats))] {
+				fmt.Printf("%3d  %-7s %6d %6.1f%% %8.1f%%\n", i+1, userCoords.Move(s.Move, *size), s.Games,
+					100*float64(s.Games)/float64(total), 100*s.WinRate(b.turn))
+			}
+		}
+
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			return nil
+		}
+		input := strings.TrimSpace(scanner.Text())
+		var move int
+		choice, err := strconv.Atoi(input)
+		switch {
+		case input == "quit":
+			return nil
+		case input == "back":
+			if len(history) > 1 {
+				history = history[:len(history)-1]
+			}
+			continue
+		case err == nil:
+			if choice < 1 || choice > min(*top, len(stats)) {
+				fmt.Println("Invalid choice.")
+				continue
+			}
+			move = stats[choice-1].Move
+		default:
+			// Moves are typed in the user's coordinates (see coords.go).
+			if move, err = userCoords.Parse(input, *size); err != nil {
+				fmt.Printf("Invalid move: %v\n", err)
+				continue
+			}
+		}
+		next := b.Copy()
+		if !next.Play(move) {
+			fmt.Println("Illegal move.")
+			continue
+		}
+		history = append(history, next)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestOpeningExplorer(t *testing.T) {
+	db, err := OpenStore("memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	play := func(id, result string, size int, moves ...int) {
+		g := &GameRecord{Size: size, Komi: 7, Result: result}
+		for i, m := range moves {
+			g.Moves = append(g.Moves, RecordedMove{Color: []Stone{Black, White}[i%2], Move: m})
+		}
+		if _, err := db.Add(id, "test", g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	play("a", "B+R", 9, 40, 20, 60, 10)
+	play("b", "W+R", 9, 40, 20)
+	play("c", "W+3", 9, 30)
+	play("d", "B+R", 9, 60, 20, 40, 10) // transposes into a
+	play("e", "B+R", 13, 40)
+	games, err := db.Games()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := BuildExplorer(games, 9, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.games != 4 {
+		t.Errorf("indexed %d games, want the four 9x9 ones", e.games)
+	}
+	b := NewBoard(9)
+	stats := e.Next(b)
+	if len(stats) != 3 || stats[0].Move != 40 || stats[0].Games != 2 || stats[1].Move != 30 || stats[2].Move != 60 {
+		t.Fatalf("first moves %+v", stats)
+	}
+	if r := stats[0].WinRate(Black); r != 0.5 {
+		t.Errorf("Black won %g of the games opening at 40, want 0.5", r)
+	}
+	if r := stats[1].WinRate(White); r != 1 {
+		t.Errorf("White won %g of the games opening at 30, want 1", r)
+	}
+
+	for _, m := range []int{40, 20, 60} {
+		b.Play(m)
+	}
+	if stats := e.Next(b); len(stats) != 1 || stats[0].Move != 10 || stats[0].Games != 2 || stats[0].BlackWins != 2 {
+		t.Errorf("after the transposition %+v, want both games' 10", stats)
+	}
+
+	shallow, err := BuildExplorer(games, 9, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = NewBoard(9)
+	b.Play(40)
+	if stats := shallow.Next(b); len(stats) != 0 {
+		t.Errorf("a depth of 1 indexed %+v after the first move", stats)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	commands["extract"] = runExtract
+}
+
+// Diagram is a position taken from a game, shown before Answer is played.
+type Diagram struct {
+	Board   *Board
+	Answer  int
+	GameID  string
+	MoveNum int
+	Caption string
+}
+
+// captureCount returns how many opposing stones playing move captures.
+func (b *Board) captureCount(move int) (int, bool) {
+	opponent := b.turn.Opponent()
+	before := b.countStones(opponent)
+	after := b.Copy()
+	if !after.Play(move) {
+		return 0, false
+	}
+	return before - after.countStones(opponent), true
+}
+
+func (b *Board) countStones(s Stone) int {
+	n := 0
+	for _, row := range b.grid {
+		for _, v := range row {
+			if v == s {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// ExtractCaptures collects the positions just before a move that captured
+// at least minStones stones, skipping positions already seen in any
+// orientation.
+func ExtractCaptures(games []DBGame, minStones, limit int) ([]Diagram, error) {
+	var diagrams []Diagram
+	seen := make(map[uint64]bool)
+	for i := range games {
+		dbg := &games[i]
+		g, err := dbg.Record()
+		if err != nil {
+			return nil, err
+		}
+		b := g.Start()
+		for n, m := range g.Moves {
+			b.turn = m.Color
+			captured, ok := b.captureCount(m.Move)
+			if !ok {
+				break
+			}
+			if captured >= minStones && !seen[b.CanonicalHash()] {
+				seen[b.CanonicalHash()] = true
+				diagrams = append(diagrams, Diagram{
+					Board: b.Copy(), Answer: m.Move, GameID: dbg.ID, MoveNum: n + 1,
+					Caption: fmt.Sprintf("%s to play and capture %d", colorName(m.Color), captured),
+				})
+				if len(diagrams) == limit {
+					return diagrams, nil
+				}
+			}
+			b.Play(m.Move)
+		}
+	}
+	return diagrams, nil
+}
+
+func colorName(s Stone) string {
+	switch s {
+	case Black:
+		return "Black"
+	case White:
+		return "White"
+	}
+	return "Nobody"
+}
+
+// WriteProblemSheet lays diagrams out on A4 pages (in millimetres) of
+// cols x rows, with the answers listed at the bottom of each page.
+func WriteProblemSheet(w io.Writer, diagrams []Diagram, cols, rows int, first int, theme Theme) {
+	const pageW, pageH, margin, footer = 210.0, 297.0, 12.0, 20.0
+	cellW := (pageW - 2*margin) / float64(cols)
+	cellH := (pageH - 2*margin - footer) / float64(rows)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%gmm" height="%gmm" viewBox="0 0 %g %g">`+"\n", pageW, pageH, pageW, pageH)
+	fmt.Fprintf(w, `<rect width="%g" height="%g" fill="white"/>`+"\n", pageW, pageH)
+	answers := ""
+	for i, d := range diagrams {
+		x := margin + float64(i%cols)*cellW
+		y := margin + float64(i/cols)*cellH
+		side := min(cellW, cellH-8) - 4
+		opts := SVGOptions{Cell: side / float64(d.Board.size+1), LastMove: PassMove, Theme: theme}
+		WriteBoardSVG(w, d.Board, x+2, y+2, opts)
+		fmt.Fprintf(w, `<text x="%g" y="%g" font-size="3.5" font-family="sans-serif">%d. %s</text>`+"\n",
+			x+2, y+side+6, first+i, html.EscapeString(d.Caption))
+		answers += fmt.Sprintf("%d: %s (%s, move %d)   ", first+i, userCoords.Move(d.Answer, d.Board.size), d.GameID, d.MoveNum)
+	}
+	fmt.Fprintf(w, `<text x="%g" y="%g" font-size="2.5" font-family="sans-serif">Answers: %s</text>`+"\n",
+		margin, pageH-margin, html.EscapeString(answers))
+	fmt.Fprintln(w, "</svg>")
+}
+
+func runExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	minCapture := fs.Int("min-capture", 4, "minimum stones captured by the move")
+	limit := fs.Int("max", 24, "maximum number of diagrams")
+	dir := fs.String("dir", "problems", "output directory for the SVG pages")
+	cols := fs.Int("cols", 2, "diagrams per row")
+	rows := fs.Int("rows", 3, "rows per page")
+	themeName := fs.String("theme", "", "board colours: wood, classic, high-contrast, print or one from the config file")
+	fs.Parse(args)
+	theme, err := FindTheme(configPath(), *themeName)
+	if err != nil {
+		return err
+	}
+
+	games, err := loadGames(*dbPath)
+	if err != nil {
+		return err
+	}
+	diagrams, err := ExtractCaptures(games, *minCapture, *limit)
+	if err != nil {
+		return err
+	}
+	if len(diagrams) == 0 {
+		fmt.Println("No positions match.")
+		return nil
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	perPage := *cols * *rows
+	pages := 0
+	for start := 0; start < len(diagrams); start += perPage {
+		pages++
+		f, err := os.Create(filepath.Join(*dir, fmt.Sprintf("sheet-%02d.svg", pages)))
+		if err != nil {
+			return err
+		}
+		WriteProblemSheet(f, diagrams[start:min(start+perPage, len(diagrams))], *cols, *rows, start+1, theme)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Wrote %d diagrams on %d pages to %s\n", len(diagrams), pages, *dir)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// addCaptureGames stores a game in which Black's ninth move takes four
+// white stones on the top edge, and its mirror image.
+func addCaptureGames(t *testing.T, db GameStore) {
+	t.Helper()
+	for id, moves := range map[string][]int{
+		"left":  {9, 0, 10, 1, 11, 2, 12, 3, 4},
+		"right": {17, 8, 16, 7, 15, 6, 14, 5, 4},
+	} {
+		g := &GameRecord{Size: 9, Komi: 7, Result: "B+R"}
+		for i, m := range moves {
+			g.Moves = append(g.Moves, RecordedMove{Color: []Stone{Black, White}[i%2], Move: m})
+		}
+		if _, err := db.Add(id, "test", g); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExtractCaptures(t *testing.T) {
+	db, err := OpenStore("memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	addCaptureGames(t, db)
+	games, err := db.Games()
+	if err != nil || len(games) != 2 {
+		t.Fatalf("%d games, %v", len(games), err)
+	}
+
+	diagrams, err := ExtractCaptures(games, 4, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagrams) != 1 {
+		t.Fatalf("got %d diagrams, want one: the mirrored game is the same position", len(diagrams))
+	}
+	d := diagrams[0]
+	if d.Answer != 4 || d.MoveNum != 9 || d.Caption != "Black to play and capture 4" {
+		t.Errorf("diagram %+v", d)
+	}
+	// Playing the answer on the diagram's board takes the four stones.
+	if n, ok := d.Board.captureCount(d.Answer); !ok || n != 4 {
+		t.Errorf("the answer captures %d, %v", n, ok)
+	}
+	if more, _ := ExtractCaptures(games, 5, 10); len(more) != 0 {
+		t.Errorf("-min-capture 5 found %d diagrams", len(more))
+	}
+}
+
+func TestRunExtract(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOGAME_HOME", dir)
+	dbPath := filepath.Join(dir, "games.db")
+	db, err := OpenStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addCaptureGames(t, db)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "sheets")
+	if err := runExtract([]string{"-db", dbPath, "-dir", out, "-cols", "1", "-rows", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "sheet-01.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text []string
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("sheet-01.svg is not well formed: %v", err)
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			text = append(text, string(cd))
+		}
+	}
+	all := strings.Join(text, "")
+	if !strings.Contains(all, "1. Black to play and capture 4") || !strings.Contains(all, "Answers: 1: ") {
+		t.Errorf("the sheet's text is %q", all)
+	}
+	if _, err := os.Stat(filepath.Join(out, "sheet-02.svg")); err == nil {
+		t.Error("one diagram filled two pages")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["fairplay"] = runFairPlay
+}
+
+// FairPlayStats are one player's numbers for the fair play report: how
+// often their moves were the engine's choice, and how long they took.
+type FairPlayStats struct {
+	Player string
+	Moves  int       // moves compared with the engine
+	Top1   int       // ... that were its first choice
+	Top3   int       // ... or among its first three
+	Times  []float64 // seconds per move, where the record has the clock
+}
+
+func (s *FairPlayStats) add(o FairPlayStats) {
+	s.Moves += o.Moves
+	s.Top1 += o.Top1
+	s.Top3 += o.Top3
+	s.Times = append(s.Times, o.Times...)
+}
+
+func (s FairPlayStats) Top1Rate() float64 { return ratio(s.Top1, s.Moves) }
+func (s FairPlayStats) Top3Rate() float64 { return ratio(s.Top3, s.Moves) }
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// TimeSpread is the mean time per move and its coefficient of variation,
+// the standard deviation over the mean. People spend very different times
+// on easy and hard moves; a low spread suggests moves relayed at a steady
+// pace.
+func (s FairPlayStats) TimeSpread() (mean, cv float64) {
+	if len(s.Times) == 0 {
+		return 0, 0
+	}
+	for _, t := range s.Times {
+		mean += t
+	}
+	mean /= float64(len(s.Times))
+	if mean == 0 {
+		return 0, 0
+	}
+	variance := 0.0
+	for _, t := range s.Times {
+		variance += (t - mean) * (t - mean)
+	}
+	return mean, math.Sqrt(variance/float64(len(s.Times))) / mean
+}
+
+// FairPlayThresholds decide what the report flags. Neither test applies to
+// fewer than MinMoves moves: short samples say nothing.
+type FairPlayThresholds struct {
+	Match    float64 // flag engine first-choice rates at or above this
+	CV       float64 // flag move time spreads below this
+	MinMoves int
+}
+
+// Flags lists why s looks suspicious, if it does. A flag is a reason to
+// look at the games, not proof of anything.
+func (s FairPlayStats) Flags(t FairPlayThresholds) []string {
+	var flags []string
+	if s.Moves >= t.MinMoves && s.Top1Rate() >= t.Match {
+		flags = append(flags, fmt.Sprintf("engine's first choice %.0f%% of %d moves", 100*s.Top1Rate(), s.Moves))
+	}
+	if mean, cv := s.TimeSpread(); len(s.Times) >= t.MinMoves && cv < t.CV {
+		flags = append(flags, fmt.Sprintf("uniform move times (%.1fs ± %.0f%% over %d moves)", mean, 100*cv, len(s.Times)))
+	}
+	return flags
+}
+
+// FairPlayOptions say which moves are compared with the engine.
+type FairPlayOptions struct {
+	Skip int // opening moves left out, where anyone plays book moves
+	// Obvious leaves out positions where the engine's first choice has
+	// at least this share of its playouts: forced replies match any
+	// engine and would inflate the rate.
+	Obvious float64
+	Cache   *AnalysisCache // of analyses, nil to search every position
+}
+
+// AnalyseFairPlay compares each player's moves in g with e's choices and
+// collects their move times, indexed by colour. Analysis already in the
+// record is used instead of searching again.
+func AnalyseFairPlay(ctx context.Context, e *Engine, g *GameRecord, opts FairPlayOptions) ([3]FairPlayStats, error) {
+	var stats [3]FairPlayStats
+	stats[Black].Player, stats[White].Player = playerName(g.Black, "Black"), playerName(g.White, "White")
+	recorded := make(map[int]MoveAnalysis)
+	for _, a := range g.Analysis {
+		recorded[a.MoveNumber] = a
+	}
+	times := moveTimes(g)
+	e.Komi = g.Komi
+
+	b := g.Start()
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		if times[i] >= 0 {
+			stats[m.Color].Times = append(stats[m.Color].Times, times[i])
+		}
+		if i >= opts.Skip && m.Move != PassMove {
+			a, ok := recorded[i]
+			if !ok || len(a.Candidates) == 0 {
+				var err error
+				if a, err = opts.Cache.Analyze(ctx, e, b); err != nil {
+					return stats, err
+				}
+			}
+			if rank, counted := engineRank(a, m.Move, opts.Obvious); counted {
+				s := &stats[m.Color]
+				s.Moves++
+				if rank == 0 {
+					s.Top1++
+				}
+				if rank >= 0 && rank < 3 {
+					s.Top3++
+				}
+			}
+		}
+		if !b.Play(m.Move) {
+			return stats, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	return stats, nil
+}
+
+// engineRank is move's place among a's candidates, or -1 if the engine did
+// not consider it; counted is false for obvious positions.
+func engineRank(a MoveAnalysis, move int, obvious float64) (rank int, counted bool) {
+	if len(a.Candidates) == 0 {
+		return -1, false
+	}
+	total := 0
+	for _, c := range a.Candidates {
+		total += c.Visits
+	}
+	if obvious > 0 && float64(a.Candidates[0].Visits) >= obvious*float64(total) {
+		return -1, false
+	}
+	for i, c := range a.Candidates {
+		if c.Move == move {
+			return i, true
+		}
+	}
+	return -1, true
+}
+
+func playerName(name, color string) string {
+	if name == "" {
+		return color
+	}
+	return name
+}
+
+// moveTimes is the time each move took in seconds, worked out from the
+// clock recorded after each move (BL/WL, OB/OW) with the game's TM and
+// OT, or -1 where the record does not say.
+func moveTimes(g *GameRecord) []float64 {
+	times := make([]float64, len(g.Moves))
+	mainTime, err := strconv.ParseFloat(g.Info["TM"], 64)
+	if err != nil {
+		mainTime = -1
+	}
+	periods, period, byoyomi := parseOT(g.Info["OT"])
+
+	type clock struct {
+		left    float64 // main time, or time in the period once in byo-yomi
+		periods int
+		known   bool
+	}
+	var last [3]clock
+	for _, c := range []Stone{Black, White} {
+		last[c] = clock{left: mainTime, known: mainTime >= 0}
+	}
+	for i, m := range g.Moves {
+		times[i] = -1
+		if m.TimeLeft == 0 && m.Periods == 0 {
+			last[m.Color].known = false
+			continue
+		}
+		prev := last[m.Color]
+		now := clock{left: m.TimeLeft, periods: m.Periods, known: true}
+		switch {
+		case !prev.known:
+		case m.Periods == 0 && prev.periods == 0:
+			times[i] = prev.left - m.TimeLeft
+		case byoyomi && prev.periods == 0:
+			// Main time ran out during the move.
+			times[i] = prev.left + float64(periods-m.Periods)*period + period - m.TimeLeft
+		case byoyomi:
+			// Each move in byo-yomi starts with a full period.
+			times[i] = float64(prev.periods-m.Periods)*period + period - m.TimeLeft
+		}
+		if times[i] < 0 {
+			times[i] = -1
+		}
+		last[m.Color] = now
+	}
+	return times
+}
+
+// parseOT reads byo-yomi from an SGF OT such as "5x30 byo-yomi".
+func parseOT(ot string) (periods int, period float64, ok bool) {
+	count, rest, found := strings.Cut(ot, "x")
+	if !found {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	fields := strings.Fields(rest)
+	if err != nil || len(fields) == 0 {
+		return 0, 0, false
+	}
+	d, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || d <= 0 {
+		return 0, 0, false
+	}
+	return n, d, true
+}
+
+func runFairPlay(args []string) error {
+	fs := flag.NewFlagSet("fairplay", flag.ExitOnError)
+	playouts := fs.Int("playouts", 2000, "engine playouts per position")
+	skip := fs.Int("skip", 20, "opening moves to leave out")
+	obvious := fs.Float64("obvious", 0.8, "leave out positions where the engine's first choice has this share of playouts")
+	match := fs.Float64("match", 0.7, "flag engine first-choice rates at or above this")
+	cv := fs.Float64("cv", 0.3, "flag move time spreads (stddev/mean) below this")
+	minMoves := fs.Int("min-moves", 30, "moves needed before either test applies")
+	useCache := fs.Bool("cache", true, "look positions up in the analysis cache and keep new analyses there")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: fairplay [flags] file.sgf...")
+	}
+	thresholds := FairPlayThresholds{Match: *match, CV: *cv, MinMoves: *minMoves}
+	cache, err := openAnalysisCache(*useCache)
+	if err != nil {
+		return err
+	}
+	opts := FairPlayOptions{Skip: *skip, Obvious: *obvious, Cache: cache}
+	e := NewEngine(*playouts, 6.5)
+
+	totals := make(map[string]*FairPlayStats)
+	var order []string
+	games := 0
+	for _, path := range fs.Args() {
+		collection, err := ReadSGFFile(path)
+		if err != nil {
+			return err
+		}
+		for n, tree := range collection {
+			g, err := RecordFromSGF(tree)
+			if err != nil {
+				return fmt.Errorf("%s game %d: %v", path, n+1, err)
+			}
+			stats, err := AnalyseFairPlay(context.Background(), e, g, opts)
+			if err != nil {
+				return fmt.Errorf("%s game %d: %v", path, n+1, err)
+			}
+			games++
+			fmt.Printf("%s game %d\n", path, n+1)
+			for _, c := range []Stone{Black, White} {
+				printFairPlay(stats[c], thresholds)
+				t, ok := totals[stats[c].Player]
+				if !ok {
+					t = &FairPlayStats{Player: stats[c].Player}
+					totals[t.Player] = t
+					order = append(order, t.Player)
+				}
+				t.add(stats[c])
+			}
+		}
+	}
+	if games > 1 {
+		fmt.Println("All games")
+		for _, name := range order {
+			printFairPlay(*totals[name], thresholds)
+		}
+	}
+	return saveAnalysisCache(cache)
+}
+
+func printFairPlay(s FairPlayStats, t FairPlayThresholds) {
+	line := fmt.Sprintf("  %-16s top-1 %3.0f%%  top-3 %3.0f%%  of %3d moves", s.Player, 100*s.Top1Rate(), 100*s.Top3Rate(), s.Moves)
+	if mean, cv := s.TimeSpread(); len(s.Times) > 0 {
+		line += fmt.Sprintf("   time %5.1fs ± %3.0f%%", mean, 100*cv)
+	}
+	fmt.Println(line)
+	for _, f := range s.Flags(t) {
+		fmt.Println("    FLAG:", f)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMoveTimes(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader(`(;GM[1]SZ[9]TM[60]OT[3x10 byo-yomi]
+		;B[ee]BL[55];W[ef]WL[50];B[fe]BL[5];W[df]WL[8]OW[2];B[dd];W[gg]WL[4]OW[2])`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := RecordFromSGF(games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// White's fourth move: 50s of main time, a whole period and 2s of the
+	// next. Black's third move has no clock.
+	want := []float64{5, 10, 50, 62, -1, 6}
+	got := moveTimes(g)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("move %d took %g, want %g", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestFairPlay(t *testing.T) {
+	g := &GameRecord{Size: 5, Komi: 0.5}
+	// Black always plays the engine's first choice, White its third;
+	// move 5 is obvious.
+	for i, move := range []int{12, 6, 7, 8, 13, 16} {
+		color := Black
+		if i%2 == 1 {
+			color = White
+		}
+		g.Moves = append(g.Moves, RecordedMove{Color: color, Move: move, TimeLeft: float64(100 - 20*(i/2))})
+		rank := 0
+		if color == White {
+			rank = 2
+		}
+		a := MoveAnalysis{MoveNumber: i}
+		for j := 0; j < 4; j++ {
+			c := CandidateMove{Move: 20 + j, Visits: 10 - j}
+			if j == rank {
+				c.Move = move
+			}
+			a.Candidates = append(a.Candidates, c)
+		}
+		if i == 4 {
+			a.Candidates[0].Visits = 1000
+		}
+		g.Analysis = append(g.Analysis, a)
+	}
+	g.Info = map[string]string{"TM": "120"}
+
+	stats, err := AnalyseFairPlay(context.Background(), NewEngine(10, 0.5), g, FairPlayOptions{Obvious: 0.8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, w := stats[Black], stats[White]
+	if b.Moves != 2 || b.Top1 != 2 || w.Moves != 3 || w.Top1 != 0 || w.Top3 != 3 {
+		t.Fatalf("black %+v, white %+v", b, w)
+	}
+	// Every move took exactly 20s.
+	thresholds := FairPlayThresholds{Match: 0.7, CV: 0.3, MinMoves: 2}
+	if flags := b.Flags(thresholds); len(flags) != 2 {
+		t.Errorf("black flags = %q", flags)
+	}
+	if flags := w.Flags(thresholds); len(flags) != 1 || !strings.Contains(flags[0], "uniform") {
+		t.Errorf("white flags = %q", flags)
+	}
+	thresholds.MinMoves = 10
+	if flags := b.Flags(thresholds); len(flags) != 0 {
+		t.Errorf("flags on a short sample: %q", flags)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// Game is a GameLog for servers, where several goroutines share a game:
+// players' connections submitting moves and watchers reading the
+// position. Every method holds the game's lock, so two moves submitted at
+// once are played one after the other, and a move by the side not to play
+// is refused rather than played for the other colour. Readers get
+// snapshots: the position Board returns is a copy that the next move does
+// not change under them.
+type Game struct {
+	mu  sync.Mutex
+	log *GameLog
+}
+
+var errOutOfTurn = errors.New("not your turn")
+
+// NewGame starts a game at start, which may hold setup or handicap
+// stones.
+func NewGame(start *Board) *Game {
+	return &Game{log: NewGameLog(start)}
+}
+
+// Play plays move for color, and returns the number of moves and passes
+// played with it.
+func (g *Game) Play(color Stone, move int) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if over, _ := g.log.Over(); over {
+		return 0, errors.New("the game is over")
+	}
+	if g.log.board.turn != color {
+		return 0, errOutOfTurn
+	}
+	if err := g.log.Play(move); err != nil {
+		return 0, err
+	}
+	return g.log.Moves(), nil
+}
+
+// Undo is GameLog.Undo.
+func (g *Game) Undo(n int) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.Undo(n)
+}
+
+// Resign records that color gave up.
+func (g *Game) Resign(color Stone) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.log.Resign(color)
+}
+
+// Expire records that color ran out of time.
+func (g *Game) Expire(color Stone) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.log.Expire(color)
+}
+
+// Board is a snapshot of the current position.
+func (g *Game) Board() BoardView {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.board.Copy()
+}
+
+// Over is GameLog.Over.
+func (g *Game) Over() (bool, *GameEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.Over()
+}
+
+// Moves is the number of moves and passes played.
+func (g *Game) Moves() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.Moves()
+}
+
+// Events returns the game's events so far.
+func (g *Game) Events() []GameEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.Events()
+}
+
+// Captures is the number of stones color has captured.
+func (g *Game) Captures(color Stone) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.Captures(color)
+}
+
+// PositionHistory is GameLog.PositionHistory.
+func (g *Game) PositionHistory() []PositionRecord {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.PositionHistory()
+}
+
+// MovesSince is GameLog.MovesSince.
+func (g *Game) MovesSince(hash uint64) ([]PositionRecord, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.log.MovesSince(hash)
+}
+
+// Do runs f with the game to itself, for a caller that has to look at
+// the game and change it in one step, such as checking a move against
+// plugins' rules before playing it. f must not keep the log.
+func (g *Game) Do(f func(*GameLog)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f(g.log)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// Run with -race: players submit moves at once, each also trying moves
+// out of turn, while watchers read the game.
+func TestGameConcurrentMoves(t *testing.T) {
+	g := NewGame(NewBoard(9))
+	const moves = 40
+	var players, watchers sync.WaitGroup
+	done := make(chan struct{})
+	for _, color := range []Stone{Black, White} {
+		for i := 0; i < 2; i++ {
+			players.Add(1)
+			go func(color Stone, seed int) {
+				defer players.Done()
+				for p := seed; g.Moves() < moves; p = (p + 7) % 81 {
+					if _, err := g.Play(color, p); err != nil && err != errOutOfTurn && err.Error() != "illegal move" {
+						t.Errorf("%s at %d: %v", colorKey(color), p, err)
+						return
+					}
+				}
+			}(color, i*40+int(color))
+		}
+	}
+	for i := 0; i < 4; i++ {
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				g.Board().Hash()
+				g.PositionHistory()
+			}
+		}()
+	}
+	players.Wait()
+	close(done)
+	watchers.Wait()
+
+	events := g.Events()
+	b, err := ReplayEvents(NewBoard(9), events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Hash() != g.Board().Hash() {
+		t.Error("the events do not lead to the game's position")
+	}
+	if n := g.Moves(); n < moves {
+		t.Errorf("%d moves played, want at least %d", n, moves)
+	}
+}
+
+func TestGameOutOfTurn(t *testing.T) {
+	g := NewGame(NewBoard(5))
+	if _, err := g.Play(White, 0); err != errOutOfTurn {
+		t.Errorf("White first: %v", err)
+	}
+	if n, err := g.Play(Black, 0); n != 1 || err != nil {
+		t.Errorf("Black first: %d, %v", n, err)
+	}
+	view := g.Board()
+	g.Play(White, 1)
+	if view.At(0, 1) != Empty {
+		t.Error("a snapshot changed with the next move")
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DBGame is one archived game. The starting position and moves are kept
+// in the compact binary codec; everything else is the game information
+// needed to list and filter games without decoding them. Colors, "b" or
+// "w" a move, is kept only for games whose colours do not alternate from
+// the side to move at the start, such as free handicap placed as moves.
+type DBGame struct {
+	ID        string  `json:"id"`
+	Source    string  `json:"source"`
+	Black     string  `json:"black"`
+	White     string  `json:"white"`
+	BlackRank string  `json:"black_rank,omitempty"`
+	WhiteRank string  `json:"white_rank,omitempty"`
+	Result    string  `json:"result,omitempty"`
+	Date      string  `json:"date,omitempty"`
+	Size      int     `json:"size"`
+	Komi      float64 `json:"komi"`
+	Handicap  int     `json:"handicap,omitempty"`
+	Position  []byte  `json:"position"`
+	Moves     []byte  `json:"moves"`
+	Colors    string  `json:"colors,omitempty"`
+}
+
+// GameDB is the local game database, stored as a single JSON file, or
+// kept in memory only if it has no path. Version is the file's schema version: files are brought up to
+// gameDBVersion as they are opened (see gameDBMigrations), and one written
+// by a newer gogame is refused rather than misread.
+type GameDB struct {
+	path    string
+	Version int      `json:"version"`
+	Entries []DBGame `json:"games"`
+	keys    map[string]bool
+	read    int  // the version the file had, if it was migrated
+	dirty   bool // games were added since the file was read
+}
+
+// gameDBVersion is the schema version this gogame writes. Files from
+// before versioning are version 1.
+const gameDBVersion = 2
+
+// gameDBMigrations[v] brings a database from version v to v+1.
+var gameDBMigrations = map[int]func(db *GameDB) error{
+	// Version 2 keeps move colours that do not alternate. The only
+	// games version 1 got wrong were OGS games with free handicap,
+	// whose stones OGS records as Black's first moves.
+	1: func(db *GameDB) error {
+		for i := range db.Entries {
+			g := &db.Entries[i]
+			if g.Source != "ogs" || g.Handicap < 2 {
+				continue
+			}
+			start, err := DecodePosition(g.Position)
+			if err != nil {
+				return fmt.Errorf("game %s: %w", g.ID, err)
+			}
+			_, moves, err := DecodeMoves(g.Moves)
+			if err != nil {
+				return fmt.Errorf("game %s: %w", g.ID, err)
+			}
+			if start.countStones(Black) > 0 || len(moves) < g.Handicap {
+				continue
+			}
+			colors := make([]Stone, len(moves))
+			for j := range colors {
+				colors[j] = Black
+				if j >= g.Handicap && (j-g.Handicap)%2 == 0 {
+					colors[j] = White
+				}
+			}
+			g.Colors = encodeColors(start.turn, colors)
+		}
+		return nil
+	},
+}
+
+// migrate brings db up to gameDBVersion.
+func (db *GameDB) migrate() error {
+	if db.Version == 0 {
+		db.Version = 1
+		if len(db.Entries) == 0 {
+			db.Version = gameDBVersion
+		}
+	}
+	if db.Version > gameDBVersion {
+		return fmt.Errorf("%s: written by a newer gogame (schema version %d; this one knows up to %d)", db.path, db.Version, gameDBVersion)
+	}
+	if db.Version < gameDBVersion {
+		db.read = db.Version
+	}
+	for ; db.Version < gameDBVersion; db.Version++ {
+		if err := gameDBMigrations[db.Version](db); err != nil {
+			return fmt.Errorf("%s: migrating from schema version %d: %w", db.path, db.Version, err)
+		}
+	}
+	return nil
+}
+
+// encodeColors is DBGame.Colors for moves of colors from a position with
+// turn to move: empty if they alternate.
+func encodeColors(turn Stone, colors []Stone) string {
+	var b strings.Builder
+	alternate := true
+	for _, c := range colors {
+		alternate = alternate && c == turn
+		turn = c.Opponent()
+		b.WriteString(colorKey(c)[:1])
+	}
+	if alternate {
+		return ""
+	}
+	return b.String()
+}
+
+func gameDBPath() string {
+	return dataPath("games.json")
+}
+
+// OpenGameDB loads the database at path; a missing file is an empty database.
+func OpenGameDB(path string) (*GameDB, error) {
+	db := &GameDB{path: path, keys: make(map[string]bool)}
+	if err := loadJSON(path, db); err != nil {
+		return nil, err
+	}
+	if err := db.migrate(); err != nil {
+		return nil, err
+	}
+	db.index()
+	return db, nil
+}
+
+// index notes the ids and games of the entries, for Add.
+func (db *GameDB) index() {
+	for _, g := range db.Entries {
+		db.keys[g.ID] = true
+		db.keys[string(g.Position)+string(g.Moves)] = true
+	}
+}
+
+// newMemoryDB is an empty database kept in memory.
+func newMemoryDB() *GameDB {
+	return &GameDB{Version: gameDBVersion, keys: make(map[string]bool)}
+}
+
+func (db *GameDB) Save() error {
+	db.Version = gameDBVersion
+	if db.path == "" {
+		return nil
+	}
+	if err := saveJSON(db.path, db); err != nil {
+		return err
+	}
+	db.dirty = false
+	return nil
+}
+
+// Close saves the games added since the file was read.
+func (db *GameDB) Close() error {
+	if !db.dirty {
+		return nil
+	}
+	return db.Save()
+}
+
+func (db *GameDB) String() string {
+	if db.path == "" {
+		return "memory database"
+	}
+	return db.path
+}
+
+// Games returns the games in the order they were added.
+func (db *GameDB) Games() ([]DBGame, error) {
+	return db.Entries, nil
+}
+
+// Add stores g under id unless a game with that id or with the same
+// starting position and moves is already present.
+func (db *GameDB) Add(id, source string, g *GameRecord) (bool, error) {
+	entry := newDBGame(id, source, g)
+	key := string(entry.Position) + string(entry.Moves)
+	if db.keys[id] || db.keys[key] {
+		return false, nil
+	}
+	db.keys[id], db.keys[key] = true, true
+	db.Entries = append(db.Entries, entry)
+	db.dirty = true
+	return true, nil
+}
+
+// newDBGame encodes g for storing under id.
+func newDBGame(id, source string, g *GameRecord) DBGame {
+	moves := make([]int, len(g.Moves))
+	colors := make([]Stone, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i], colors[i] = m.Move, m.Color
+	}
+	start := g.Start()
+	return DBGame{
+		ID: id, Source: source,
+		Black: g.Black, White: g.White, BlackRank: g.BlackRank, WhiteRank: g.WhiteRank,
+		Result: g.Result, Date: g.Date, Size: g.Size, Komi: g.Komi, Handicap: g.Handicap,
+		Position: EncodePosition(start),
+		Moves:    EncodeMoves(g.Size, moves),
+		Colors:   encodeColors(start.turn, colors),
+	}
+}
+
+// Record decodes a stored game. Move colours alternate from the side to
+// move in the starting position, unless the game has Colors.
+func (g *DBGame) Record() (*GameRecord, error) {
+	start, err := DecodePosition(g.Position)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", g.ID, err)
+	}
+	_, moves, err := DecodeMoves(g.Moves)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", g.ID, err)
+	}
+	r := &GameRecord{
+		Size: g.Size, Komi: g.Komi, Handicap: g.Handicap,
+		Black: g.Black, White: g.White, BlackRank: g.BlackRank, WhiteRank: g.WhiteRank,
+		Result: g.Result, Date: g.Date,
+	}
+	for row := 0; row < start.size; row++ {
+		for col := 0; col < start.size; col++ {
+			if s := start.grid[row][col]; s != Empty {
+				r.Setup = append(r.Setup, RecordedMove{Color: s, Move: row*start.size + col})
+			}
+		}
+	}
+	if g.Colors != "" && len(g.Colors) != len(moves) {
+		return nil, fmt.Errorf("game %s: %d colours for %d moves", g.ID, len(g.Colors), len(moves))
+	}
+	color := start.turn
+	for i, m := range moves {
+		if g.Colors != "" {
+			color = map[byte]Stone{'b': Black, 'w': White}[g.Colors[i]]
+		}
+		r.Moves = append(r.Moves, RecordedMove{Color: color, Move: m})
+		color = color.Opponent()
+	}
+	return r, nil
+}
+
+// Find returns the game with the given id, or nil.
+func (db *GameDB) Find(id string) *DBGame {
+	for i := range db.Entries {
+		if db.Entries[i].ID == id {
+			return &db.Entries[i]
+		}
+	}
+	return nil
+}
+
+// resultWinner returns the winner named by an SGF result, or Empty.
+func resultWinner(result string) Stone {
+	switch {
+	case strings.HasPrefix(result, "B+"):
+		return Black
+	case strings.HasPrefix(result, "W+"):
+		return White
+	}
+	return Empty
+}
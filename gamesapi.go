@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The games API keeps games on the server for clients with no board of
+// their own, such as a chat bot or a page with no Go rules in it.
+// analysis-api serves it beside /analyze:
+//
+//	POST   /games               {"size": 9, "komi": 7, "handicap": 0}
+//	GET    /games
+//	GET    /games/{id}
+//	DELETE /games/{id}
+//	POST   /games/{id}/moves    {"move": "E5"}, or "pass" or "resign"
+//	POST   /games/{id}/genmove  the engine plays for the side to move
+//
+// Every call but DELETE answers with the game as a GameState. Two passes in
+// a row end a game, which is then scored by area. Games live in memory and
+// end with the server.
+
+// gamesAPIMax is the most games a server keeps at once.
+const gamesAPIMax = 1000
+
+// NewGameRequest starts a game.
+type NewGameRequest struct {
+	Size     int     `json:"size"`
+	Komi     float64 `json:"komi"`
+	Handicap int     `json:"handicap,omitempty"`
+}
+
+// MoveRequest plays a move in a game.
+type MoveRequest struct {
+	Move string `json:"move"` // a GTP vertex, "pass" or "resign"
+}
+
+// GameState is a game as the games API shows it.
+type GameState struct {
+	ID       string   `json:"id"`
+	Size     int      `json:"size"`
+	Komi     float64  `json:"komi"`
+	Handicap int      `json:"handicap"`
+	Moves    []string `json:"moves"`
+	ToMove   string   `json:"to_move"`
+	Board    []string `json:"board"`            // a row per entry, from the top: X Black, O White, . empty
+	Result   string   `json:"result,omitempty"` // as in SGF RE, once the game is over
+}
+
+// apiGame is a game of the games API.
+type apiGame struct {
+	id       string
+	komi     float64
+	handicap int
+	b        *Board
+	moves    []string
+	result   string
+}
+
+func (g *apiGame) state() GameState {
+	s := GameState{
+		ID: g.id, Size: g.b.size, Komi: g.komi, Handicap: g.handicap,
+		Moves: append([]string{}, g.moves...), ToMove: colorKey(g.b.turn), Result: g.result,
+	}
+	for _, row := range g.b.grid {
+		var line strings.Builder
+		for _, p := range row {
+			line.WriteByte(".XO"[p])
+		}
+		s.Board = append(s.Board, line.String())
+	}
+	return s
+}
+
+// play plays move for the side to move and scores the game once it ends.
+func (g *apiGame) play(move int) bool {
+	if !g.b.Play(move) {
+		return false
+	}
+	g.moves = append(g.moves, gtpVertex(move, g.b.size))
+	if g.b.IsGameOver() {
+		g.result = resultString(g.b.Winner(g.komi))
+	}
+	return true
+}
+
+// gamesHandler serves the games API. The engine plays genmove with up to
+// playouts, within timeout, set up by configure if given; it thinks about
+// one game at a time.
+func gamesHandler(playouts int, timeout time.Duration, configure func(*Engine)) http.Handler {
+	var (
+		mu     sync.Mutex // guards games and next
+		games  = map[string]*apiGame{}
+		next   int
+		search sync.Mutex
+	)
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*") // for pages on other sites
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(w http.ResponseWriter, status int, err error) {
+		reply(w, status, map[string]string{"error": err.Error()})
+	}
+	// game finds the request's game, with mu held, or answers that there
+	// is none.
+	game := func(w http.ResponseWriter, r *http.Request) *apiGame {
+		mu.Lock()
+		g := games[r.PathValue("id")]
+		if g == nil {
+			mu.Unlock()
+			fail(w, http.StatusNotFound, errors.New("no such game"))
+		}
+		return g
+	}
+	errOver := errors.New("the game is over")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("OPTIONS /games/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	})
+	mux.HandleFunc("GET /games", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		list := []GameState{}
+		for _, g := range games {
+			list = append(list, g.state())
+		}
+		slices.SortFunc(list, func(a, b GameState) int {
+			ai, _ := strconv.Atoi(a.ID)
+			bi, _ := strconv.Atoi(b.ID)
+			return ai - bi
+		})
+		reply(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("POST /games", func(w http.ResponseWriter, r *http.Request) {
+		var req NewGameRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Size < 2 || req.Size > MaxBoardSize {
+			fail(w, http.StatusBadRequest, fmt.Errorf("size must be between 2 and %d", MaxBoardSize))
+			return
+		}
+		b := NewBoard(req.Size)
+		if err := b.PlaceHandicap(req.Handicap); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(games) >= gamesAPIMax {
+			fail(w, http.StatusServiceUnavailable, fmt.Errorf("the server already has %d games", gamesAPIMax))
+			return
+		}
+		next++
+		g := &apiGame{id: strconv.Itoa(next), komi: req.Komi, handicap: req.Handicap, b: b}
+		games[g.id] = g
+		reply(w, http.StatusCreated, g.state())
+	})
+	mux.HandleFunc("GET /games/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if g := game(w, r); g != nil {
+			defer mu.Unlock()
+			reply(w, http.StatusOK, g.state())
+		}
+	})
+	mux.HandleFunc("DELETE /games/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if g := game(w, r); g != nil {
+			delete(games, g.id)
+			mu.Unlock()
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("POST /games/{id}/moves", func(w http.ResponseWriter, r *http.Request) {
+		var req MoveRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		g := game(w, r)
+		if g == nil {
+			return
+		}
+		defer mu.Unlock()
+		if g.result != "" {
+			fail(w, http.StatusConflict, errOver)
+			return
+		}
+		if strings.EqualFold(req.Move, "resign") {
+			g.moves = append(g.moves, "resign")
+			g.result = resignResult(g.b.turn)
+			reply(w, http.StatusOK, g.state())
+			return
+		}
+		m, err := parseGTPVertex(req.Move, g.b.size)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		if !g.play(m) {
+			fail(w, http.StatusBadRequest, fmt.Errorf("%s is illegal", req.Move))
+			return
+		}
+		reply(w, http.StatusOK, g.state())
+	})
+	mux.HandleFunc("POST /games/{id}/genmove", func(w http.ResponseWriter, r *http.Request) {
+		g := game(w, r)
+		if g == nil {
+			return
+		}
+		if g.result != "" {
+			mu.Unlock()
+			fail(w, http.StatusConflict, errOver)
+			return
+		}
+		b, played := g.b.Copy(), len(g.moves)
+		mu.Unlock()
+
+		// The search runs without mu, so that other games go on meanwhile;
+		// a move played in this game in the meantime wins.
+		search.Lock()
+		e := NewEngine(playouts, g.komi)
+		e.MoveTime = timeout
+		if configure != nil {
+			configure(e)
+		}
+		row, col, pass, err := e.GenMove(r.Context(), b)
+		search.Unlock()
+		if err != nil {
+			fail(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		m := row*b.size + col
+		if pass {
+			m = PassMove
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if games[g.id] != g || len(g.moves) != played {
+			fail(w, http.StatusConflict, errors.New("the game changed while the engine was thinking"))
+			return
+		}
+		g.play(m)
+		reply(w, http.StatusOK, g.state())
+	})
+	return mux
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["gauntlet"] = runGauntlet
+}
+
+// gauntletResign is returned by a player that resigns.
+const gauntletResign = -2
+
+// gauntletPlayer is one side of a gauntlet game. Played is told every move
+// of the game, the player's own included.
+type gauntletPlayer interface {
+	Name() string
+	NewGame(size int, komi float64) error
+	GenMove(ctx context.Context, b BoardView) (int, error)
+	Played(color Stone, move int) error
+}
+
+// enginePlayer is the engine under test.
+type enginePlayer struct{ e *Engine }
+
+func (p enginePlayer) Name() string { return fmt.Sprintf("engine (%d playouts)", p.e.Playouts) }
+
+func (p enginePlayer) NewGame(size int, komi float64) error {
+	p.e.Komi = komi
+	return nil
+}
+
+func (p enginePlayer) GenMove(ctx context.Context, b BoardView) (int, error) {
+	row, col, pass, err := p.e.GenMove(ctx, b)
+	if pass {
+		return PassMove, err
+	}
+	return row*b.Size() + col, err
+}
+
+func (p enginePlayer) Played(Stone, int) error { return nil }
+
+// randomPlayer plays uniformly among the moves that do not fill its own
+// eyes, as in the engine's playouts.
+type randomPlayer struct{ rng *rand.Rand }
+
+func (p randomPlayer) Name() string               { return "random" }
+func (p randomPlayer) NewGame(int, float64) error { return nil }
+func (p randomPlayer) Played(Stone, int) error    { return nil }
+
+func (p randomPlayer) GenMove(ctx context.Context, v BoardView) (int, error) {
+	b := v.Copy()
+	candidates := b.candidates()
+	p.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	for _, m := range candidates {
+		if _, ok := b.captureCount(m); ok {
+			return m, nil
+		}
+	}
+	return PassMove, nil
+}
+
+// greedyPlayer captures as many stones as it can, and otherwise plays
+// like randomPlayer.
+type greedyPlayer struct{ rng *rand.Rand }
+
+func (p greedyPlayer) Name() string               { return "greedy" }
+func (p greedyPlayer) NewGame(int, float64) error { return nil }
+func (p greedyPlayer) Played(Stone, int) error    { return nil }
+
+func (p greedyPlayer) GenMove(ctx context.Context, v BoardView) (int, error) {
+	b := v.Copy()
+	candidates := b.candidates()
+	p.rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	best, bestCaptured := PassMove, -1
+	for _, m := range candidates {
+		if n, ok := b.captureCount(m); ok && n > bestCaptured {
+			best, bestCaptured = m, n
+		}
+	}
+	return best, nil
+}
+
+// gtpPlayer is an external engine, such as a previous build, over GTP.
+type gtpPlayer struct {
+	name      string
+	client    *gtpClient
+	size      int
+	generated bool // the last move came from our genmove
+}
+
+func newGTPPlayer(command string) (*gtpPlayer, error) {
+	client, err := startGTP(command)
+	if err != nil {
+		return nil, err
+	}
+	name := command
+	if n, err := client.send("name"); err == nil && n != "" {
+		name = n
+		if v, err := client.send("version"); err == nil && v != "" {
+			name += " " + v
+		}
+	}
+	return &gtpPlayer{name: name, client: client}, nil
+}
+
+func (p *gtpPlayer) Name() string { return p.name }
+
+func (p *gtpPlayer) NewGame(size int, komi float64) error {
+	p.size = size
+	if _, err := p.client.send("boardsize %d", size); err != nil {
+		return err
+	}
+	if _, err := p.client.send("komi %g", komi); err != nil {
+		return err
+	}
+	_, err := p.client.send("clear_board")
+	return err
+}
+
+func (p *gtpPlayer) GenMove(ctx context.Context, b BoardView) (int, error) {
+	reply, err := p.client.send("genmove %s", gtpColor(b.Turn()))
+	if err != nil {
+		return 0, err
+	}
+	if strings.EqualFold(reply, "resign") {
+		return gauntletResign, nil
+	}
+	p.generated = true
+	return parseGTPVertex(reply, p.size)
+}
+
+func (p *gtpPlayer) Played(color Stone, move int) error {
+	if p.generated {
+		p.generated = false
+		return nil
+	}
+	_, err := p.client.send("play %s %s", gtpColor(color), gtpVertex(move, p.size))
+	return err
+}
+
+// playGauntletGame plays one game to the end and returns the winner. An
+// illegal move or a resignation loses; games that run on are cut off
+// after 3*size*size moves and scored as they stand.
+func playGauntletGame(ctx context.Context, black, white gauntletPlayer, size int, komi float64) (Stone, error) {
+	for _, p := range []gauntletPlayer{black, white} {
+		if err := p.NewGame(size, komi); err != nil {
+			return Empty, err
+		}
+	}
+	b := NewBoard(size)
+	for moves := 0; !b.IsGameOver() && moves < 3*size*size; moves++ {
+		color := b.turn
+		p := black
+		if color == White {
+			p = white
+		}
+		move, err := p.GenMove(ctx, b.Copy())
+		if err != nil {
+			return Empty, fmt.Errorf("%s: %v", p.Name(), err)
+		}
+		if move == gauntletResign || !b.Play(move) {
+			return color.Opponent(), nil
+		}
+		for _, q := range []gauntletPlayer{black, white} {
+			if err := q.Played(color, move); err != nil {
+				return Empty, fmt.Errorf("%s: %v", q.Name(), err)
+			}
+		}
+	}
+	winner, _ := b.Winner(komi)
+	return winner, nil
+}
+
+func runGauntlet(args []string) error {
+	fs := flag.NewFlagSet("gauntlet", flag.ExitOnError)
+	size := fs.Int("size", 9, "board size")
+	komi := fs.Float64("komi", 7, "komi")
+	games := fs.Int("games", 10, "games against each opponent, alternating colours")
+	playouts := fs.Int("playouts", 1000, "playouts per move of the engine under test")
+	threads := fs.Int("threads", 1, "search workers of the engine under test")
+	spec := fs.String("engine", "", "more options of the engine under test, e.g. mercy=20,eyes=1 (see engine options)")
+	randomMin := fs.Float64("random", 0.9, "minimum win rate against the random player (0 to skip)")
+	greedyMin := fs.Float64("greedy", 0.8, "minimum win rate against the greedy capturer (0 to skip)")
+	gtpCommand := fs.String("gtp", "", "command running a reference engine in GTP mode, e.g. an older build")
+	gtpMin := fs.Float64("gtp-min", 0.4, "minimum win rate against the -gtp engine")
+	fs.Parse(args)
+
+	engine, err := newEngineFromSpec(*spec, *komi)
+	if err != nil {
+		return err
+	}
+	engine.Playouts, engine.Threads = *playouts, *threads
+	player := enginePlayer{engine}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	type opponent struct {
+		p   gauntletPlayer
+		min float64
+	}
+	var opponents []opponent
+	if *randomMin > 0 {
+		opponents = append(opponents, opponent{randomPlayer{rng}, *randomMin})
+	}
+	if *greedyMin > 0 {
+		opponents = append(opponents, opponent{greedyPlayer{rng}, *greedyMin})
+	}
+	if *gtpCommand != "" {
+		gp, err := newGTPPlayer(*gtpCommand)
+		if err != nil {
+			return err
+		}
+		defer gp.client.Close()
+		opponents = append(opponents, opponent{gp, *gtpMin})
+	}
+
+	ctx := context.Background()
+	var failed []string
+	for _, o := range opponents {
+		score := 0.0
+		for g := 0; g < *games; g++ {
+			black, white, ours := gauntletPlayer(player), o.p, Black
+			if g%2 == 1 {
+				black, white, ours = white, black, White
+			}
+			winner, err := playGauntletGame(ctx, black, white, *size, *komi)
+			if err != nil {
+				return err
+			}
+			switch winner {
+			case Empty:
+				score += 0.5
+			case ours:
+				score++
+			}
+		}
+		rate := score / float64(*games)
+		verdict := "pass"
+		if rate < o.min {
+			verdict = "FAIL"
+			failed = append(failed, o.p.Name())
+		}
+		fmt.Printf("%-24s %5.1f/%-3d %5.1f%%  (minimum %.0f%%)  %s\n", o.p.Name(), score, *games, 100*rate, 100*o.min, verdict)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("gauntlet failed against %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
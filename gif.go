@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	commands["gif"] = runGIF
+}
+
+// gifFinalHold is how long the last frame of an animation stays up before
+// it loops.
+const gifFinalHold = 3 * time.Second
+
+// GameFrames renders g one frame per move, the empty or setup position
+// first. numbers numbers the stones, as a -numbers setting (see
+// movenumbers.go), and theme colours them.
+func GameFrames(g *GameRecord, cell int, numbers int, theme Theme) ([]*image.Paletted, error) {
+	b := g.Start()
+	frames := []*image.Paletted{RenderBoard(b, RasterOptions{Cell: cell, LastMove: PassMove, Theme: theme})}
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d (%s) is illegal", i+1, formatMove(m.Move, g.Size))
+		}
+		labels := moveNumbers(b, g.Moves[:i+1], numbers)
+		frames = append(frames, RenderBoard(b, RasterOptions{Cell: cell, Numbers: labels, LastMove: m.Move, Theme: theme}))
+	}
+	return frames, nil
+}
+
+// WriteGIF encodes frames as a looping animation, delay per frame.
+func WriteGIF(path string, frames []*image.Paletted, delay time.Duration) error {
+	anim := &gif.GIF{}
+	for i, f := range frames {
+		d := delay
+		if i == len(frames)-1 {
+			d = max(delay, gifFinalHold)
+		}
+		anim.Image = append(anim.Image, f)
+		anim.Delay = append(anim.Delay, int(d/(10*time.Millisecond)))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WritePNGFrames writes frames to dir as move-000.png, move-001.png and so
+// on, the number being the moves played.
+func WritePNGFrames(dir string, frames []*image.Paletted) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, img := range frames {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("move-%03d.png", i)))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runGIF(args []string) error {
+	fs := flag.NewFlagSet("gif", flag.ExitOnError)
+	out := fs.String("o", "", "output GIF (default: the SGF name with .gif)")
+	pngDir := fs.String("png", "", "write numbered PNG frames to this directory instead of a GIF")
+	delay := fs.Duration("delay", 700*time.Millisecond, "time each move is shown")
+	numbers := fs.Bool("numbers", false, "number the stones by move, modulo 100")
+	numbersLast := fs.Int("numbers-last", 0, "number only the stones of the last N moves of each frame")
+	cell := fs.Int("cell", 24, "pixels between lines")
+	game := fs.Int("game", 1, "game number within the file")
+	themeName := fs.String("theme", "", "board colours: wood, classic, high-contrast, print or one from the config file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: gif file.sgf [-o game.gif | -png dir] [-delay 700ms] [-numbers] [-numbers-last N] [-cell 24] [-game N] [-theme NAME]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	if *delay < 10*time.Millisecond {
+		return errors.New("-delay must be at least 10ms")
+	}
+	if *cell < 8 {
+		return errors.New("-cell must be at least 8")
+	}
+
+	theme, err := FindTheme(configPath(), *themeName)
+	if err != nil {
+		return err
+	}
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	last := *numbersLast
+	if *numbers && last == 0 {
+		last = allMoveNumbers
+	}
+	frames, err := GameFrames(g, *cell, last, theme)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	if *pngDir != "" {
+		if err := WritePNGFrames(*pngDir, frames); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d frames to %s\n", len(frames), *pngDir)
+		return nil
+	}
+	if *out == "" {
+		*out = trimExt(path) + ".gif"
+	}
+	if err := WriteGIF(*out, frames, *delay); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%d frames)\n", *out, len(frames))
+	return nil
+}
+
+func trimExt(path string) string {
+	return path[:len(path)-len(filepath.Ext(path))]
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunGIF(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOGAME_HOME", dir)
+	sgf := filepath.Join(dir, "game.sgf")
+	if err := os.WriteFile(sgf, []byte("(;GM[1]SZ[9]KM[7];B[ee];W[cc];B[gg])"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runGIF([]string{sgf, "-delay", "200ms", "-numbers"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "game.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 4 {
+		t.Fatalf("%d frames, want the empty board and one per move", len(anim.Image))
+	}
+	for i, want := range []int{20, 20, 20, 300} {
+		if anim.Delay[i] != want {
+			t.Errorf("frame %d shows for %d0ms, want %d0ms", i, anim.Delay[i], want)
+		}
+	}
+	for i := 1; i < len(anim.Image); i++ {
+		if bytes.Equal(anim.Image[i].Pix, anim.Image[i-1].Pix) {
+			t.Errorf("frame %d is the same as the one before", i)
+		}
+	}
+
+	frames := filepath.Join(dir, "frames")
+	if err := runGIF([]string{sgf, "-png", frames}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 4 {
+		data, err := os.ReadFile(filepath.Join(frames, fmt.Sprintf("move-%03d.png", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil || img.Bounds() != anim.Image[i].Bounds() {
+			t.Errorf("frame %d: %v, %v", i, img.Bounds(), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(frames, "move-004.png")); err == nil {
+		t.Error("a fifth frame for three moves")
+	}
+
+	bad := filepath.Join(dir, "bad.sgf")
+	os.WriteFile(bad, []byte("(;SZ[9];B[ee];W[ee])"), 0o644)
+	if err := runGIF([]string{bad}); err == nil {
+		t.Error("rendered a game with a move on an occupied point")
+	}
+}
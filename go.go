@@ -1,216 +1,727 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-type Stone int
-
-const (
-	Empty Stone = iota
-	Black
-	White
-)
+func main() {
+	if err := loadUserCoords(configPath()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
-func (s Stone) String() string {
-	switch s {
-	case Black:
-		return "●"
-	case White:
-		return "○"
+	size := flag.Int("size", 9, "board size")
+	komi := flag.Float64("komi", 6.5, "komi")
+	handicap := flag.Int("handicap", 0, "handicap stones for Black")
+	botFlag := flag.String("bot", "", "colour played by the computer: black or white")
+	blackFlag := flag.String("black", "", "who plays Black: human, engine, gtp:COMMAND, net:ADDR or script (default human)")
+	whiteFlag := flag.String("white", "", "who plays White, as -black")
+	playouts := flag.Int("playouts", 1000, "computer playouts per move")
+	treeMB := flag.Int("tree-mb", 0, "memory cap of the computer's search tree in MB (default 256)")
+	threads := flag.Int("threads", 1, "parallel search workers")
+	batch := flag.Int("batch", 1, "positions evaluated per batch")
+	moveTime := flag.Duration("move-time", 0, "most time the computer thinks per move, e.g. 300ms (default no limit)")
+	adaptive := flag.Bool("adaptive", false, "adapt the computer's strength to the player")
+	player := flag.String("player", defaultPlayer(), "player name for -adaptive and -ladder")
+	ladder := flag.Bool("ladder", false, "play the next bot of the ladder, see the ladder command")
+	preset := flag.String("preset", "", "start from a named position, see the presets command")
+	tui := flag.Bool("tui", false, "full-screen terminal interface")
+	pipe := flag.Bool("pipe", false, "read and write one JSON object per line, for scripts")
+	teach := flag.Bool("teach", false, "point out beginner mistakes in your moves")
+	thumbs := flag.Int("thumbs", 0, "with -tui, show a thumbnail of the position every N moves in the move list")
+	numbers := flag.String("numbers", "", "number the stones of the last N moves, or all, modulo 100 (type 'numbers N|all|off' to change)")
+	coords := flag.String("coords", userCoords.String(), "coordinates to show and read moves in: index, western, numeric or japanese")
+	noAnim := flag.Bool("no-anim", false, "with -tui, do not flash captured stones and the new stone after each move")
+	scoreBar := flag.Bool("scorebar", false, "with -tui, show the estimated score and captures from the start (type 'score' to toggle)")
+	mainTime := flag.Duration("time", 0, "main time per player, e.g. 10m (default no clock)")
+	byoyomi := flag.String("byoyomi", "", "byo-yomi after main time, as periods x length, e.g. 5x30s")
+	warn := flag.String("warn", "10s,5s", "warn when a byo-yomi period is down to these times")
+	save := flag.String("save", "", "write the game, with the time left after each move, to this SGF file")
+	rules := flag.String("rules", "", "comma-separated rules plugins, see the plugins command")
+	script := flag.String("script", "", "game script reacting to moves, see scripthooks.go")
+	pluginFiles := flag.String("plugin", "", "comma-separated Go plugin files to load")
+	book := flag.String("book", bookPath(), "opening book the computer plays from, see the book command (empty for none)")
+	learn := flag.Bool("learn", false, "after each game, add its result to the opening book's win rates, so the computer avoids lines it loses")
+	profileName := flag.String("profile", "", "engine profile: blitz, normal, analysis or one from the config file, see the profiles command")
+	autosave := flag.Bool("autosave", true, "save the game after every move to resume it after a crash")
+	flag.Parse()
+	if c, err := ParseCoords(*coords); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	} else {
+		userCoords = c
+	}
+	
+	var bot Stone
+	switch strings.ToLower(*botFlag) {
+	case "":
+	case "black", "b":
+		bot = Black
+	case "white", "w":
+		bot = White
 	default:
-		return "+"
+		fmt.Fprintf(os.Stderr, "invalid -bot colour %q\n", *botFlag)
+		os.Exit(2)
+	}
+	// -black and -white name each colour's source, see sources.go; the
+	// built-in engine is still the bot.
+	var sources [3]string
+	for color, spec := range map[Stone]string{Black: *blackFlag, White: *whiteFlag} {
+		if err := checkSource(spec); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		switch spec {
+		case "", "human":
+		case "engine":
+			if bot != Empty && bot != color {
+				fmt.Fprintln(os.Stderr, "the built-in engine can play only one colour")
+				os.Exit(2)
+			}
+			bot = color
+		default:
+			sources[color] = spec
+		}
+	}
+	if bot != Empty && sources[bot] != "" {
+		fmt.Fprintf(os.Stderr, "-bot %s conflicts with -%s %s\n", colorKey(bot), colorKey(bot), sources[bot])
+		os.Exit(2)
+	}
+	var periods int
+	var period time.Duration
+	if *byoyomi != "" {
+		var err error
+		if periods, period, err = parseByoyomi(*byoyomi); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	warnings, err := parseDurations(*warn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -warn: %v\n", err)
+		os.Exit(2)
+	}
+	if err := loadPlugins(*pluginFiles); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// A profile sets the engine flags that are not given explicitly.
+	var profile EngineProfile
+	if *profileName != "" {
+		if profile, err = FindEngineProfile(configPath(), *profileName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		set := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["playouts"] {
+			*playouts = profile.Playouts
+		}
+		if !set["threads"] {
+			*threads = profile.Threads
+		}
+		if !set["move-time"] {
+			*moveTime = time.Duration(profile.MoveMS) * time.Millisecond
+		}
+	}
+	
+	// An interrupted game is offered before anything else starts.
+	var resume *GameRecord
+	if *autosave && !*pipe {
+		if resume, err = offerResume(os.Stdin, os.Stdout, autosavePath()); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot resume the interrupted game: %v\n", err)
+		}
+	}
+	
+	err = playGame(GameOptions{
+		Size:        *size,
+		Komi:        *komi,
+		Handicap:    *handicap,
+		Bot:         bot,
+		Sources:     sources,
+		Playouts:    *playouts,
+		TreeMB:      *treeMB,
+		Threads:     *threads,
+		Batch:       *batch,
+		MoveTime:    *moveTime,
+		Adaptive:    *adaptive,
+		Ladder:      *ladder,
+		Player:      *player,
+		Preset:      *preset,
+		TUI:         *tui,
+		Pipe:        *pipe,
+		Teach:       *teach,
+		Thumbs:      *thumbs,
+		ScoreBar:    *scoreBar,
+		NoAnim:      *noAnim,
+		Numbers:     *numbers,
+		MainTime:    *mainTime,
+		Periods:     periods,
+		Period:      period,
+		Warnings:    warnings,
+		Save:        *save,
+		Rules:       splitNames(*rules),
+		Script:      *script,
+		Book:        *book,
+		Learn:       *learn,
+		Ponder:      profile.Ponder,
+		ResignBelow: profile.ResignBelow,
+		Autosave:    *autosave && !*pipe,
+		Resume:      resume,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
-type Board struct {
-	size  int
-	grid  [][]Stone
-	turn  Stone
-	passes int
+// GameOptions configures an interactive game.
+type GameOptions struct {
+	Size     int
+	Komi     float64
+	Handicap int
+	Bot      Stone // colour played by the computer, or Empty
+	Sources  [3]string // other sources of each colour's moves, see sources.go; "" for the terminal
+	Playouts int
+	TreeMB   int // memory cap of the computer's search tree, 0 for the default
+	Threads  int // parallel search workers
+	Batch    int // leaf evaluation batch size
+	MoveTime time.Duration // most time the computer thinks per move, 0 for no limit
+	Adaptive bool
+	Ladder   bool
+	Player   string
+	Preset   string // starting position from the presets command
+	TUI      bool // full-screen terminal interface
+	Pipe     bool // JSON line protocol for scripts, see pipe.go
+	Teach    bool // warn about beginner mistakes, see badmove.go
+	Thumbs   int // -tui move list thumbnail interval, 0 for none
+	ScoreBar bool // -tui score estimate bar shown from the start
+	NoAnim   bool // no -tui capture and new stone effects
+	Numbers  string // move numbers on the stones, see movenumbers.go
+	// A clock runs if MainTime or Periods is set: main time, then Periods
+	// byo-yomi periods of Period each, with warnings when a period is down
+	// to each of Warnings.
+	MainTime time.Duration
+	Periods  int
+	Period   time.Duration
+	Warnings []time.Duration
+	Save     string   // SGF file to write the game to
+	Rules    []string // rules plugins, see plugins.go
+	Script   string   // game script file, see scripthooks.go
+	Book     string   // opening book file, see book.go
+	Learn    bool     // update the book with the game's result
+	// Ponder lets the computer think on the player's time; it resigns
+	// when its win rate falls below ResignBelow, if set.
+	Ponder      bool
+	ResignBelow float64
+	// Autosave keeps the game in the recovery file, see autosave.go;
+	// Resume continues a game from it, with its size, komi, handicap and
+	// the computer's colour.
+	Autosave bool
+	Resume   *GameRecord
 }
 
-func NewBoard(size int) *Board {
-	grid := make([][]Stone, size)
-	for i := range grid {
-		grid[i] = make([]Stone, size)
+// newPlayRecord starts the record of a game from its first position.
+func newPlayRecord(board *Board, opts GameOptions) *GameRecord {
+	rec := &GameRecord{
+		Size: board.size, Komi: opts.Komi, Handicap: opts.Handicap,
+		Black: opts.Player, White: opts.Player,
+		Date: time.Now().Format("2006-01-02"), Info: map[string]string{},
 	}
-	return &Board{
-		size: size,
-		grid: grid,
-		turn: Black,
+	switch opts.Bot {
+	case Black:
+		rec.Black = "gogame"
+	case White:
+		rec.White = "gogame"
 	}
-}
-
-func (b *Board) Display() {
-	fmt.Println()
-	// Column headers
-	fmt.Print("  ")
-	for i := 0; i < b.size; i++ {
-		fmt.Printf("%2d", i)
-	}
-	fmt.Println()
-	
-	// Board with row headers
-	for i := 0; i < b.size; i++ {
-		fmt.Printf("%2d", i)
-		for j := 0; j < b.size; j++ {
-			fmt.Printf(" %s", b.grid[i][j])
+	for p := 0; p < board.size*board.size; p++ {
+		if s := board.grid[p/board.size][p%board.size]; s != Empty {
+			rec.Setup = append(rec.Setup, RecordedMove{Color: s, Move: p})
 		}
-		fmt.Println()
 	}
-	fmt.Printf("\nCurrent turn: %s\n", b.turn)
+	return rec
 }
 
-func (b *Board) IsValidMove(row, col int) bool {
-	if row < 0 || row >= b.size || col < 0 || col >= b.size {
-		return false
+func playGame(opts GameOptions) error {
+	if r := opts.Resume; r != nil {
+		opts.Size, opts.Komi, opts.Handicap, opts.Bot, opts.Preset = r.Size, r.Komi, r.Handicap, resumedBot(r), ""
 	}
-	return b.grid[row][col] == Empty
-}
-
-func (b *Board) PlaceStone(row, col int) bool {
-	if !b.IsValidMove(row, col) {
-		return false
+	if opts.Size < 2 || opts.Size > MaxBoardSize {
+		return fmt.Errorf("board size must be between 2 and %d", MaxBoardSize)
 	}
-	
-	b.grid[row][col] = b.turn
-	
-	// Remove captured opponent stones
-	opponent := White
-	if b.turn == White {
-		opponent = Black
+	rules, err := NewRuleset(opts.Rules)
+	if err != nil {
+		return err
 	}
-	
-	// Check all adjacent positions for captures
-	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-	for _, dir := range directions {
-		newRow, newCol := row+dir[0], col+dir[1]
-		if b.isInBounds(newRow, newCol) && b.grid[newRow][newCol] == opponent {
-			if !b.hasLiberties(newRow, newCol, make(map[[2]int]bool)) {
-				b.removeGroup(newRow, newCol)
-			}
+	bot := opts.Bot
+	engine := NewEngine(opts.Playouts, opts.Komi)
+	var book *OpeningBook
+	if opts.Book != "" && bot != Empty {
+		if book, err = LoadBook(opts.Book); err != nil {
+			return err
+		}
+	}
+	var profile *AdaptiveProfile
+	if opts.Adaptive && bot != Empty {
+		var err error
+		if profile, err = LoadProfile(opts.Player); err != nil {
+			return err
 		}
+		engine = profile.Engine(opts.Komi)
 	}
+	var rung *LadderBot
+	if opts.Ladder && bot != Empty {
+		if opts.Adaptive {
+			return fmt.Errorf("-ladder cannot be combined with -adaptive")
+		}
+		standing, err := loadLadderStanding(opts.Player)
+		if err != nil {
+			return err
+		}
+		next, ok := standing.Bot()
+		if !ok {
+			return fmt.Errorf("you have climbed the whole ladder; play without -ladder")
+		}
+		rung, engine = &next, next.Engine(opts.Komi)
+	}
+	if opts.TreeMB > 0 {
+		engine.MaxMemory = int64(opts.TreeMB) << 20
+	}
+	engine.Threads, engine.BatchSize = opts.Threads, opts.Batch
+	engine.MoveTime = opts.MoveTime
 	
-	// Check if the placed stone group has liberties (suicide rule)
-	if !b.hasLiberties(row, col, make(map[[2]int]bool)) {
-		b.grid[row][col] = Empty // Remove the stone
-		return false
+	board := NewBoard(opts.Size)
+	if opts.Resume != nil {
+		board = opts.Resume.Start()
+	} else if opts.Preset != "" {
+		preset, err := FindPreset(opts.Preset)
+		if err != nil {
+			return err
+		}
+		if opts.Handicap > 1 {
+			return fmt.Errorf("-handicap cannot be combined with preset %s", preset.Name)
+		}
+		board = preset.Board.Copy()
+		opts.Size = board.size
+	}
+	if opts.Resume == nil {
+		if err := board.PlaceHandicap(opts.Handicap); err != nil {
+			return err
+		}
 	}
 	
-	b.passes = 0
-	b.nextTurn()
-	return true
-}
-
-func (b *Board) isInBounds(row, col int) bool {
-	return row >= 0 && row < b.size && col >= 0 && col < b.size
-}
-
-func (b *Board) hasLiberties(row, col int, visited map[[2]int]bool) bool {
-	if visited[[2]int{row, col}] {
-		return false
+	numbers, err := parseMoveNumbers(opts.Numbers)
+	if err != nil {
+		return err
+	}
+	console := newConsoleView(os.Stdin)
+	console.numbers = numbers
+	var view gameView = console
+	switch {
+	case opts.Pipe:
+		view = newPipeView(os.Stdin, os.Stdout)
+	case opts.TUI:
+		t := newTUIView(os.Stdin, os.Stdout)
+		t.thumbEvery = opts.Thumbs
+		t.scoreBar, t.komi = opts.ScoreBar, opts.Komi
+		t.animate = !opts.NoAnim
+		t.numbers = numbers
+		view = t
 	}
-	visited[[2]int{row, col}] = true
+	defer view.Close()
+	game := NewGameLog(board)
+	var script *scriptHost
+	if opts.Script != "" {
+		if script, err = newScriptHost(opts.Script, game, view); err != nil {
+			return err
+		}
+	}
+	engine.Progress = view.Thinking
 	
-	stone := b.grid[row][col]
-	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	view.Message("Welcome to Go!")
+	if userCoords == IndexCoords {
+		view.Message("Enter moves as 'row col' (e.g., '3 4')")
+	} else {
+		view.Message(fmt.Sprintf("Enter moves in %s coordinates (e.g., '%s')", userCoords, userCoords.Move(3*board.size+3, board.size)))
+	}
+	view.Message("Enter 'pass' to pass your turn")
+	view.Message("Enter 'undo' to take back a move, 'resign' to give up")
+	view.Message("Enter 'quit' to exit")
+	if bot != Empty {
+		view.Message("Enter 'engine options' or 'engine set NAME VALUE' to adjust the computer")
+	}
+	view.Message(fmt.Sprintf("Starting with %dx%d board...", opts.Size, opts.Size))
+	if opts.Handicap > 1 {
+		view.Message(fmt.Sprintf("Black receives %d handicap stones, komi %.1f", opts.Handicap, opts.Komi))
+	}
+	if profile != nil {
+		view.Message(fmt.Sprintf("Adaptive bot level %.0f%% (%d playouts)", profile.Level*100, engine.Playouts))
+	}
 	
-	for _, dir := range directions {
-		newRow, newCol := row+dir[0], col+dir[1]
-		if !b.isInBounds(newRow, newCol) {
+	rec := newPlayRecord(board, opts)
+	if len(opts.Rules) > 0 {
+		rec.Info["RU"] = strings.Join(opts.Rules, ",")
+	}
+	timer := &turnTimer{view: view, warnings: opts.Warnings}
+	if opts.MainTime > 0 || opts.Periods > 0 {
+		timer.clock = NewClock(opts.MainTime, opts.Periods, opts.Period)
+		rec.Info["TM"] = strconv.FormatFloat(opts.MainTime.Seconds(), 'f', -1, 64)
+		if opts.Periods > 0 {
+			rec.Info["OT"] = timer.clock.OT()
+		}
+		engine.Think = func() TimePlan { return timer.plan(bot, game.Moves(), board.Size()) }
+	}
+	var autosave *autosaver
+	if opts.Autosave {
+		autosave = &autosaver{path: autosavePath()}
+	}
+	write := func() error {
+		if opts.Save == "" {
+			return nil
+		}
+		return WriteSGFFile(opts.Save, []*SGFNode{rec.SGF()})
+	}
+	// save is for games that are over or quit; there is nothing left to
+	// resume.
+	save := func() error {
+		autosave.clear()
+		if opts.Learn {
+			if n := book.Learn(rec); n > 0 {
+				if err := saveFile(opts.Book, EncodeBook(book)); err != nil {
+					return err
+				}
+				view.Message(fmt.Sprintf("The opening book learnt from %s of this game", plural(n, "move")))
+			}
+		}
+		return write()
+	}
+	// Colours played from outside the terminal, see sources.go. They are
+	// told the result, or that the game stopped, when it ends.
+	var sources [3]moveSource
+	closeSources := func() {
+		for _, src := range sources {
+			if src != nil {
+				src.Close(rec.Result)
+			}
+		}
+	}
+	defer closeSources()
+	for _, color := range []Stone{Black, White} {
+		if opts.Sources[color] == "" {
 			continue
 		}
-		
-		if b.grid[newRow][newCol] == Empty {
-			return true // Found a liberty
+		src, err := openSource(opts.Sources[color], color, script, view)
+		if err != nil {
+			return err
 		}
-		
-		if b.grid[newRow][newCol] == stone {
-			if b.hasLiberties(newRow, newCol, visited) {
-				return true
+		sources[color] = src
+		err = src.NewGame(board.size, opts.Komi)
+		for _, m := range rec.Setup {
+			if err == nil {
+				err = src.Setup(m.Color, m.Move)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", src.Name(), err)
+		}
+		if color == Black {
+			rec.Black = src.Name()
+		} else {
+			rec.White = src.Name()
+		}
+		view.Message(fmt.Sprintf("%s is played by %s", color, src.Name()))
+	}
+	tell := func(color Stone, move int) {
+		for _, src := range sources {
+			if src == nil {
+				continue
+			}
+			if err := src.Played(color, move); err != nil {
+				view.Message(fmt.Sprintf("%s: %v", src.Name(), err))
 			}
 		}
 	}
 	
-	return false
-}
-
-func (b *Board) removeGroup(row, col int) {
-	stone := b.grid[row][col]
-	if stone == Empty {
-		return
+	var recMu sync.Mutex // rec is shared with the signal handler
+	played := func(color Stone, move int) {
+		view.Played(color, move)
+		m := RecordedMove{Color: color, Move: move}
+		m.TimeLeft, m.Periods = timer.moved(color)
+		recMu.Lock()
+		rec.Moves = append(rec.Moves, m)
+		autosave.save(rec, view)
+		recMu.Unlock()
+		tell(color, move)
+		script.moved(color, move)
 	}
-	
-	b.grid[row][col] = Empty
-	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-	
-	for _, dir := range directions {
-		newRow, newCol := row+dir[0], col+dir[1]
-		if b.isInBounds(newRow, newCol) && b.grid[newRow][newCol] == stone {
-			b.removeGroup(newRow, newCol)
+	if r := opts.Resume; r != nil {
+		// The clocks start again from the options given.
+		rec.Black, rec.White, rec.Date = r.Black, r.White, r.Date
+		for i, m := range r.Moves {
+			if game.Board().Turn() != m.Color || game.Play(m.Move) != nil {
+				view.Message(fmt.Sprintf("The saved game stops at move %d, which cannot be replayed", i+1))
+				break
+			}
+			view.Played(m.Color, m.Move)
+			rec.Moves = append(rec.Moves, m)
+			tell(m.Color, m.Move)
+			script.moved(m.Color, m.Move)
 		}
+		view.Message(fmt.Sprintf("Resumed the game after %d moves", len(rec.Moves)))
 	}
-}
-
-func (b *Board) Pass() {
-	b.passes++
-	b.nextTurn()
-}
-
-func (b *Board) nextTurn() {
-	if b.turn == Black {
-		b.turn = White
-	} else {
-		b.turn = Black
+	// Ctrl-C and SIGTERM adjourn the game, see signals.go.
+	interrupts, stopInterrupts := notifyShutdown()
+	defer stopInterrupts()
+	go func() {
+		<-interrupts
+		recMu.Lock()
+		adjournLocal(view, rec, autosave, timer.clock, opts.Save, write)
+		closeSources()
+		os.Exit(0)
+	}()
+	// ended does the bookkeeping of a game that is over, however it
+	// ended, once rec.Result is set: the game is saved and, against the
+	// computer, recorded on the ladder (see ladder.go) or in the adaptive
+	// profile and the results archive.
+	ended := func(winner Stone) error {
+		script.ended(rec.Result)
+		if err := save(); err != nil {
+			return err
+		}
+		score := 0.5
+		if winner == bot.Opponent() {
+			score = 1
+		} else if winner == bot {
+			score = 0
+		}
+		handicap := opts.Handicap
+		if bot == Black {
+			handicap = -handicap
+		}
+		if rung != nil {
+			msg, err := recordLadderGame(opts.Player, *rung, score, opts.Size, handicap)
+			if err != nil {
+				return err
+			}
+			view.Message(msg)
+		}
+		if profile != nil {
+			err := ArchiveResult(ArchivedResult{
+				Date: time.Now(), Player: opts.Player, Opponent: "adaptive bot", OpponentRank: profile.BotRank(),
+				Score: score, BoardSize: opts.Size, Handicap: handicap,
+			})
+			if winner != Empty {
+				profile.Record(winner != bot)
+			}
+			if err == nil {
+				err = SaveProfile(opts.Player, profile)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		view.Message("Thanks for playing!")
+		return nil
+	}
+	timeUp := func(loser Stone) error {
+		game.Expire(loser)
+		view.Show(game.Board())
+		view.TimeUp(loser)
+		rec.Result = timeUpResult(loser)
+		return ended(loser.Opponent())
+	}
+	resign := func(loser Stone) error {
+		game.Resign(loser)
+		view.Resigned(loser)
+		rec.Result = resignResult(loser)
+		return ended(loser.Opponent())
 	}
-}
-
-func (b *Board) IsGameOver() bool {
-	return b.passes >= 2
-}
-
-func main() {
-	fmt.Println("Welcome to Go!")
-	fmt.Println("Enter moves as 'row col' (e.g., '3 4')")
-	fmt.Println("Enter 'pass' to pass your turn")
-	fmt.Println("Enter 'quit' to exit")
-	fmt.Println("Starting with 9x9 board...")
-	
-	board := NewBoard(9)
-	scanner := bufio.NewScanner(os.Stdin)
 	
-	for !board.IsGameOver() {
-		board.Display()
+	var pondering *ponderer // thinking on the player's time, see ponder.go
+	script.started()
+	for !game.Board().IsGameOver() {
+		board := game.Board()
+		if over, winner, margin, reason := rules.GameOver(game); over {
+			view.Show(board)
+			view.Message(reason)
+			view.Result(winner, margin)
+			rec.Result = resultString(winner, margin)
+			return ended(winner)
+		}
+		view.Show(board)
+		color := board.Turn()
+		timer.start(color, color != bot && sources[color] == nil)
+		
+		// Like a player at the terminal, a source is not cut off when its
+		// time runs out but loses on time when its move comes in.
+		if src := sources[color]; src != nil {
+			move, err := src.GenMove(context.Background(), board)
+			if timer.expired(color) {
+				return timeUp(color)
+			}
+			if err != nil {
+				// The game stays in the recovery file.
+				write()
+				return fmt.Errorf("%s: %v", src.Name(), err)
+			}
+			if move == gauntletResign {
+				return resign(color)
+			}
+			if rules.Validate(game, move) != nil || game.Play(move) != nil {
+				view.Message(fmt.Sprintf("%s played an illegal move, %s", src.Name(), userCoords.Move(move, board.Size())))
+				return resign(color)
+			}
+			played(color, move)
+			if move == PassMove {
+				view.Message(fmt.Sprintf("%s passes", color))
+			} else {
+				view.Message(fmt.Sprintf("%s plays %s", color, userCoords.Move(move, board.Size())))
+			}
+			continue
+		}
+		
+		if color == bot {
+			move, chosen := PassMove, false
+			if script.plays() && opts.Sources[bot.Opponent()] != "script" {
+				if move, chosen = script.genMove(bot); !chosen {
+					view.Message("The script chose no legal move; the engine plays instead")
+				}
+			}
+			// The book plays while the game is still in it.
+			if !chosen {
+				move, chosen = book.Pick(board)
+				if chosen && rules.Validate(game, move) != nil {
+					move, chosen = PassMove, false
+				}
+			}
+			if !chosen {
+				move, chosen = pondering.hit(board)
+			}
+			pondering = nil
+			if !chosen {
+				row, col, pass, _ := engine.GenMove(context.Background(), board)
+				if move = PassMove; !pass {
+					move = row*board.Size() + col
+				}
+				// Early on the win rate is too noisy to give up on.
+				if opts.ResignBelow > 0 && game.Moves() >= board.Size() && engine.Stats().WinRate < opts.ResignBelow {
+					return resign(bot)
+				}
+			}
+			if timer.expired(bot) {
+				return timeUp(bot)
+			}
+			// The engine plays the basic rules; it passes rather than
+			// break a plugin's.
+			if move != PassMove && rules.Validate(game, move) != nil {
+				move = PassMove
+			}
+			game.Play(move)
+			played(bot, move)
+			if move == PassMove {
+				view.Message(fmt.Sprintf("%s passes", bot))
+			} else {
+				view.Message(fmt.Sprintf("%s plays %s", bot, userCoords.Move(move, board.Size())))
+			}
+			continue
+		}
 		
-		fmt.Printf("Enter move for %s: ", board.turn)
-		if !scanner.Scan() {
+		if opts.Ponder && bot != Empty {
+			pondering = startPonder(engine, board)
+		}
+		input, ok := view.ReadMove(color)
+		pondering.stop()
+		if !ok {
 			break
 		}
+		if timer.expired(color) {
+			return timeUp(color)
+		}
+		input = strings.TrimSpace(input)
+		
+		if name, ok := strings.CutPrefix(input, "coords "); ok {
+			if c, err := ParseCoords(strings.TrimSpace(name)); err != nil {
+				view.Invalid(err.Error())
+			} else {
+				userCoords = c
+			}
+			continue
+		}
 		
-		input := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(input, "engine ") {
+			if out, err := engineCommand(engine, strings.Fields(input)[1:]); err != nil {
+				view.Invalid(err.Error())
+			} else {
+				view.Message(out)
+			}
+			continue
+		}
 		
 		switch input {
 		case "quit":
-			fmt.Println("Thanks for playing!")
-			return
+			view.Message("Thanks for playing!")
+			return save()
 		case "pass":
-			board.Pass()
-			fmt.Printf("%s passes\n", func() Stone {
-				if board.turn == Black {
-					return White
-				}
-				return Black
-			}())
+			if msg := script.check(color, PassMove); msg != "" {
+				view.Invalid(msg)
+				continue
+			}
+			game.Play(PassMove)
+			played(color, PassMove)
+			view.Message(fmt.Sprintf("%s passes", color))
+		case "resign":
+			return resign(color)
+		case "undo":
+			// Against the computer, its reply is taken back too so that
+			// it is the human's turn again.
+			n := 1
+			if bot != Empty {
+				n = 2
+			}
+			switch {
+			case timer.clock != nil:
+				view.Invalid("Moves cannot be taken back in a timed game")
+			case sources[Black] != nil || sources[White] != nil:
+				view.Invalid("Moves cannot be taken back against a GTP, network or script player")
+			case game.Moves() < n:
+				view.Invalid("Nothing to undo")
+			default:
+				game.Undo(n)
+				recMu.Lock()
+				rec.Moves = rec.Moves[:len(rec.Moves)-n]
+				autosave.save(rec, view)
+				recMu.Unlock()
+				view.Undone(n)
+			}
 		default:
+			// Other coordinates are read into rows and columns.
+			if userCoords != IndexCoords {
+				move, err := userCoords.Parse(input, board.Size())
+				if err != nil {
+					view.Invalid(fmt.Sprintf("Invalid move: %v", err))
+					continue
+				}
+				input = formatMove(move, board.Size())
+			}
 			parts := strings.Fields(input)
 			if len(parts) != 2 {
-				fmt.Println("Invalid input. Use format: row col")
+				view.Invalid("Invalid input. Use format: row col")
 				continue
 			}
 			
@@ -218,17 +729,50 @@ func main() {
 			col, err2 := strconv.Atoi(parts[1])
 			
 			if err1 != nil || err2 != nil {
-				fmt.Println("Invalid numbers. Use format: row col")
+				view.Invalid("Invalid numbers. Use format: row col")
 				continue
 			}
 			
-			if !board.PlaceStone(row, col) {
-				fmt.Println("Invalid move! Try again.")
+			var warnings []MoveWarning
+			if opts.Teach && inBounds(board, row, col) {
+				warnings = CheckMove(board.Copy(), row*board.Size()+col)
+			}
+			if !inBounds(board, row, col) {
+				view.Invalid("Invalid move! Try again.")
+				continue
+			}
+			if msg := script.check(color, row*board.Size()+col); msg != "" {
+				view.Invalid(msg)
+				continue
+			}
+			if x := rules.ExplainMove(game, row*board.Size()+col); !x.Legal() {
+				view.Invalid("Illegal move: " + x.Text() + ". Try again.")
+				continue
+			}
+			if game.Play(row*board.Size()+col) != nil {
+				view.Invalid("Invalid move! Try again.")
+				continue
+			}
+			played(color, row*board.Size()+col)
+			for _, w := range warnings {
+				view.Message("Hint: " + w.Text)
 			}
 		}
 	}
 	
-	board.Display()
-	fmt.Println("Game over! Both players passed.")
-	fmt.Println("Thanks for playing!")
+	view.Show(game.Board())
+	if !game.Board().IsGameOver() {
+		// Input ended mid-game: there is no result to report, and the
+		// game stays in the recovery file.
+		return write()
+	}
+	view.Message("Game over! Both players passed.")
+	
+	for _, step := range rules.Explain(game, opts.Komi) {
+		view.Message(step)
+	}
+	winner, margin := rules.Score(game, opts.Komi)
+	view.Result(winner, margin)
+	rec.Result = resultString(winner, margin)
+	return ended(winner)
 }
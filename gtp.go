@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// gtpColumns are the GTP column letters; I is skipped.
+const gtpColumns = "ABCDEFGHJKLMNOPQRSTUVWXYZ"
+
+// gtpColumn is the letter of a column. GTP stops at 25 columns; beyond
+// that, as in other programs for large boards, they are lettered AA, AB
+// and so on.
+func gtpColumn(col int) string {
+	n := len(gtpColumns)
+	if col < n {
+		return gtpColumns[col : col+1]
+	}
+	return string([]byte{gtpColumns[col/n-1], gtpColumns[col%n]})
+}
+
+// gtpVertex formats a move index as a GTP vertex such as "D4". GTP counts
+// rows from the bottom.
+func gtpVertex(move, size int) string {
+	if move == PassMove {
+		return "pass"
+	}
+	return fmt.Sprintf("%s%d", gtpColumn(move%size), size-move/size)
+}
+
+// parseGTPVertex parses a GTP vertex into a move index.
+func parseGTPVertex(s string, size int) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "PASS" {
+		return PassMove, nil
+	}
+	letters := 1
+	if len(s) > 1 && s[1] >= 'A' && s[1] <= 'Z' {
+		letters = 2
+	}
+	if len(s) <= letters {
+		return 0, fmt.Errorf("gtp: bad vertex %q", s)
+	}
+	col := -1
+	for i := 0; i < letters; i++ {
+		k := strings.IndexByte(gtpColumns, s[i])
+		if k < 0 {
+			col = -1
+			break
+		}
+		col = (col+1)*len(gtpColumns) + k
+	}
+	n, err := strconv.Atoi(s[letters:])
+	if col < 0 || col >= size || err != nil || n < 1 || n > size {
+		return 0, fmt.Errorf("gtp: bad vertex %q", s)
+	}
+	return (size-n)*size + col, nil
+}
+
+// gtpColor is the GTP name of a colour.
+func gtpColor(s Stone) string {
+	if s == White {
+		return "white"
+	}
+	return "black"
+}
+
+// gtpClient drives an external engine over the Go Text Protocol.
+type gtpClient struct {
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	out   *bufio.Reader
+	known map[string]bool // from list_commands, once asked
+}
+
+// startGTP starts command, split on spaces, as a GTP engine.
+func startGTP(command string) (*gtpClient, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("gtp: empty engine command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &gtpClient{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// send issues one command and returns the response text without the
+// leading "=". A "?" response is returned as an error.
+func (c *gtpClient) send(format string, args ...any) (string, error) {
+	command := fmt.Sprintf(format, args...)
+	if _, err := fmt.Fprintln(c.in, command); err != nil {
+		return "", err
+	}
+	var lines []string
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("gtp %s: %v", command, err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(lines) == 0 {
+				continue
+			}
+			break
+		}
+		lines = append(lines, line)
+	}
+	response := strings.Join(lines, "\n")
+	switch response[0] {
+	case '=':
+		return strings.TrimSpace(response[1:]), nil
+	case '?':
+		return "", fmt.Errorf("gtp %s: %s", command, strings.TrimSpace(response[1:]))
+	}
+	return "", fmt.Errorf("gtp %s: malformed response %q", command, response)
+}
+
+// Close asks the engine to quit and waits for it.
+func (c *gtpClient) Close() error {
+	c.send("quit")
+	c.in.Close()
+	return c.cmd.Wait()
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestGTPVertex(t *testing.T) {
+	cases := []struct {
+		move, size int
+		vertex     string
+	}{
+		{0, 19, "A19"},
+		{18*19 + 18, 19, "T1"},
+		{8, 19, "J19"}, // no I column
+		{4*9 + 4, 9, "E5"},
+		{PassMove, 9, "pass"},
+		{25, 52, "AA52"},
+		{51*52 + 51, 52, "BB1"}, // double letters past Z
+	}
+	for _, c := range cases {
+		if got := gtpVertex(c.move, c.size); got != c.vertex {
+			t.Errorf("gtpVertex(%d, %d) = %q, want %q", c.move, c.size, got, c.vertex)
+		}
+		got, err := parseGTPVertex(c.vertex, c.size)
+		if err != nil || got != c.move {
+			t.Errorf("parseGTPVertex(%q) = %d, %v", c.vertex, got, err)
+		}
+	}
+	for _, bad := range []string{"I5", "A0", "A10", "Z1", "", "5"} {
+		if _, err := parseGTPVertex(bad, 9); err == nil {
+			t.Errorf("parseGTPVertex(%q) accepted", bad)
+		}
+	}
+}
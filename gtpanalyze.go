@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The analysis extensions of GTP that GUIs such as Lizzie, Sabaki and
+// KaTrain use to show the engine's thinking, as Leela Zero and KataGo
+// answer them:
+//
+//	lz-analyze [COLOR] [INTERVAL]
+//	kata-analyze [COLOR] [INTERVAL] [ownership true]
+//
+// Both answer at once and then send a line of info every INTERVAL
+// centiseconds (a second by default) until the next command comes in,
+// which ends the response with the usual blank line:
+//
+//	info move D4 visits 120 winrate 0.5310 prior 0 order 0 pv D4 info move ...
+//
+// lz-analyze gives win rates in ten thousandths. kata-analyze gives them
+// as fractions, and with ownership true ends each line with "ownership"
+// and one value a point, from the top left row by row, from +1 where the
+// side to move ends up with the point to -1 where the other side does.
+// Each line is a fresh search of the engine's playouts, so lines do not
+// add up visits.
+
+// gtpAnalysis is a running analysis.
+type gtpAnalysis struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	w      io.Writer
+}
+
+// stop ends the analysis and its response.
+func (a *gtpAnalysis) stop() {
+	a.cancel()
+	<-a.done
+	fmt.Fprint(a.w, "\n")
+}
+
+// analyze starts lz-analyze or kata-analyze on the current position,
+// after its response header, writing to w until stopped.
+func (g *gtpEngine) analyze(ctx context.Context, w io.Writer, id, name string, args []string) (*gtpAnalysis, error) {
+	b := g.board.Copy()
+	interval := time.Second
+	ownership := false
+	if len(args) > 0 {
+		if color, err := parseGTPColor(args[0]); err == nil {
+			b.turn = color
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 {
+		if cs, err := strconv.Atoi(args[0]); err == nil {
+			interval, args = time.Duration(cs)*10*time.Millisecond, args[1:]
+		}
+	}
+	for ; len(args) >= 2; args = args[2:] {
+		switch args[0] {
+		case "interval":
+			cs, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("bad interval %q", args[1])
+			}
+			interval = time.Duration(cs) * 10 * time.Millisecond
+		case "ownership":
+			if name != "kata-analyze" {
+				return nil, errors.New("ownership needs kata-analyze")
+			}
+			ownership = args[1] == "true"
+		}
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	a := &gtpAnalysis{cancel: cancel, done: make(chan struct{}), w: w}
+	fmt.Fprintf(w, "=%s\n", id)
+	// The engine is the gtpEngine's, which runs no other command until
+	// the analysis stops.
+	go func() {
+		defer close(a.done)
+		for ctx.Err() == nil {
+			round, cancelRound := context.WithTimeout(ctx, interval)
+			analysis, _ := g.engine.Analyze(round, b.Copy())
+			var own []float64
+			if ownership {
+				own, _ = g.engine.Ownership(round, b)
+			}
+			<-round.Done()
+			cancelRound()
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintln(w, gtpAnalysisLine(analysis, b, name == "kata-analyze", own))
+		}
+	}()
+	return a, nil
+}
+
+// gtpAnalysisLine writes one line of info for analysis of b, kata for
+// kata-analyze's format, with ownership if given.
+func gtpAnalysisLine(a MoveAnalysis, b *Board, kata bool, ownership []float64) string {
+	var sb strings.Builder
+	for i, c := range a.Candidates {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		winrate := strconv.Itoa(int(c.WinRate*10000 + 0.5))
+		if kata {
+			winrate = strconv.FormatFloat(c.WinRate, 'f', 6, 64)
+		}
+		v := gtpVertex(c.Move, b.size)
+		fmt.Fprintf(&sb, "info move %s visits %d winrate %s prior 0 order %d pv %s", v, c.Visits, winrate, i, v)
+	}
+	if ownership != nil {
+		sb.WriteString(" ownership")
+		sign := ownerSign(b.turn)
+		for _, o := range ownership {
+			if o = sign * o; o == 0 {
+				o = 0 // not -0
+			}
+			sb.WriteByte(' ')
+			sb.WriteString(strconv.FormatFloat(o, 'f', 4, 64))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["gtp"] = runGTP
+}
+
+// gtpEngine is the built-in engine speaking the Go Text Protocol on stdin
+// and stdout, for GUIs and servers such as KGS or CGOS. final_score and
+// final_status_list take dead stones off with FinalStatus, so servers
+// that ask the engine for them can score its games. After each genmove a
+// line of search statistics goes to the log, stderr, which GUIs show as
+// the engine's log.
+type gtpEngine struct {
+	engine  *Engine
+	board   *Board
+	history []*Board  // positions before each move, for undo
+	log     io.Writer // nil for none
+	quit    bool
+
+	searches, reuses int // genmoves, and those that searched on from the last one's tree
+}
+
+var gtpCommands = []string{
+	"boardsize", "clear_board", "final_score", "final_status_list", "genmove",
+	"kata-analyze", "known_command", "komi", "list_commands", "lz-analyze", "name",
+	"play", "protocol_version", "quit", "showboard", "undo", "version",
+}
+
+func (g *gtpEngine) play(color Stone, move int) error {
+	before := g.board.Copy()
+	g.board.turn = color
+	if move == PassMove {
+		g.board.Pass()
+	} else if !g.board.Play(move) {
+		g.board = before
+		return errors.New("illegal move")
+	}
+	g.history = append(g.history, before)
+	return nil
+}
+
+// logSearch logs the statistics of the last genmove's search.
+func (g *gtpEngine) logSearch() {
+	st := g.engine.Stats()
+	g.searches++
+	if st.Reused > 0 {
+		g.reuses++
+	}
+	if g.log != nil {
+		fmt.Fprintf(g.log, "search: win rate %.1f%%, %d nodes, %.1f MB, %s reused from the last move (tree reused on %d of %d moves)\n",
+			100*st.WinRate, st.Nodes, float64(st.Memory)/(1<<20), plural(st.Reused, "playout"), g.reuses, g.searches)
+	}
+}
+
+func parseGTPColor(s string) (Stone, error) {
+	switch strings.ToLower(s) {
+	case "b", "black":
+		return Black, nil
+	case "w", "white":
+		return White, nil
+	}
+	return Empty, fmt.Errorf("invalid color %q", s)
+}
+
+// handle runs one command, without its id, and returns the response.
+func (g *gtpEngine) handle(ctx context.Context, name string, args []string) (string, error) {
+	size := g.board.size
+	switch name {
+	case "protocol_version":
+		return "2", nil
+	case "name":
+		return "gogame", nil
+	case "version":
+		return "1", nil
+	case "known_command":
+		if len(args) == 0 {
+			return "", errors.New("known_command needs a command")
+		}
+		i := sort.SearchStrings(gtpCommands, args[0])
+		return strconv.FormatBool(i < len(gtpCommands) && gtpCommands[i] == args[0]), nil
+	case "list_commands":
+		return strings.Join(gtpCommands, "\n"), nil
+	case "quit":
+		g.quit = true
+		return "", nil
+	case "boardsize":
+		n, err := strconv.Atoi(strings.Join(args, ""))
+		if err != nil || n < 2 || n > 25 {
+			return "", errors.New("unacceptable size")
+		}
+		g.board, g.history = NewBoard(n), nil
+		return "", nil
+	case "clear_board":
+		g.board, g.history = NewBoard(size), nil
+		return "", nil
+	case "komi":
+		k, err := strconv.ParseFloat(strings.Join(args, ""), 64)
+		if err != nil {
+			return "", errors.New("syntax error")
+		}
+		g.engine.Komi = k
+		return "", nil
+	case "play":
+		if len(args) != 2 {
+			return "", errors.New("syntax error")
+		}
+		color, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		move, err := parseGTPVertex(args[1], size)
+		if err != nil {
+			return "", err
+		}
+		return "", g.play(color, move)
+	case "genmove":
+		if len(args) != 1 {
+			return "", errors.New("syntax error")
+		}
+		color, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		b := g.board.Copy()
+		b.turn = color
+		row, col, pass, err := g.engine.GenMove(ctx, b)
+		if err != nil {
+			return "", err
+		}
+		g.logSearch()
+		move := row*size + col
+		if pass {
+			move = PassMove
+		}
+		if err := g.play(color, move); err != nil {
+			return "", err
+		}
+		return gtpVertex(move, size), nil
+	case "undo":
+		if len(g.history) == 0 {
+			return "", errors.New("cannot undo")
+		}
+		g.board = g.history[len(g.history)-1]
+		g.history = g.history[:len(g.history)-1]
+		return "", nil
+	case "showboard":
+		var sb strings.Builder
+		for row := 0; row < size; row++ {
+			fmt.Fprintf(&sb, "\n%2d", size-row)
+			for col := 0; col < size; col++ {
+				sb.WriteString(" " + g.board.grid[row][col].String())
+			}
+		}
+		sb.WriteString("\n  ")
+		for col := 0; col < size; col++ {
+			sb.WriteString(" " + gtpColumn(col))
+		}
+		return sb.String(), nil
+	case "final_score":
+		status, err := FinalStatus(ctx, g.engine, g.board)
+		if err != nil {
+			return "", err
+		}
+		return FinalScore(g.board, status, g.engine.Komi), nil
+	case "final_status_list":
+		if len(args) != 1 {
+			return "", errors.New("syntax error")
+		}
+		want, err := parseStoneStatus(strings.ToLower(args[0]))
+		if err != nil {
+			return "", err
+		}
+		status, err := FinalStatus(ctx, g.engine, g.board)
+		if err != nil {
+			return "", err
+		}
+		// One group per line, as GNU Go does.
+		var lines []string
+		for _, stones := range StatusList(g.board, status, want) {
+			vertices := make([]string, len(stones))
+			for i, p := range stones {
+				vertices[i] = gtpVertex(p, size)
+			}
+			lines = append(lines, strings.Join(vertices, " "))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", errors.New("unknown command")
+}
+
+// serveGTP answers the commands read from r on w until quit or the end of
+// the input.
+func (g *gtpEngine) serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	in := bufio.NewScanner(r)
+	var analysis *gtpAnalysis // see gtpanalyze.go
+	defer func() {
+		if analysis != nil {
+			analysis.stop()
+		}
+	}()
+	for !g.quit && in.Scan() {
+		// Any input ends a running analysis.
+		if analysis != nil {
+			analysis.stop()
+			analysis = nil
+		}
+		line := in.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		id := ""
+		if _, err := strconv.Atoi(fields[0]); err == nil {
+			id, fields = fields[0], fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "lz-analyze" || fields[0] == "kata-analyze" {
+			var err error
+			if analysis, err = g.analyze(ctx, w, id, fields[0], fields[1:]); err != nil {
+				fmt.Fprintf(w, "?%s %s\n\n", id, err)
+			}
+			continue
+		}
+		reply, err := g.handle(ctx, fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(w, "?%s %s\n\n", id, err)
+		} else {
+			fmt.Fprintf(w, "=%s %s\n\n", id, reply)
+		}
+	}
+	return in.Err()
+}
+
+func runGTP(args []string) error {
+	fs := flag.NewFlagSet("gtp", flag.ExitOnError)
+	playouts := fs.Int("playouts", 2000, "playouts per move, also used to judge dead stones")
+	threads := fs.Int("threads", 1, "parallel search workers")
+	moveTime := fs.Duration("move-time", 0, "most time to think per move, e.g. 300ms (default no limit)")
+	temperature := fs.Float64("temperature", 0, "play the opening moves at random in proportion to visits^(1/T), for varied matches")
+	temperatureMoves := fs.Int("temperature-moves", 20, "opening moves played with -temperature")
+	dirichlet := fs.Float64("dirichlet", 0, "weight of Dirichlet noise at the root, for varied matches")
+	reuse := fs.Bool("reuse", true, "keep the search tree from one move to the next")
+	mercy := fs.Int("mercy", 0, "end a playout when one side has captured this many stones more (0 never)")
+	length := fs.Int("playout-length", 0, "moves after which a playout is scored as it stands (default three per point)")
+	eyes := fs.Int("eyes", 0, "eyes playouts do not fill: 0 simple, 1 true eyes only, 2 none")
+	quiet := fs.Bool("quiet", false, "write no search statistics to stderr")
+	split := fs.Bool("split-tree", false, "give each thread a search tree of its own, merged at the end")
+	pin := fs.String("pin", "", "pin the search workers to CPUs: compact, spread or a list such as 0-7 (see affinity.go)")
+	fs.Parse(args)
+	e := NewEngine(*playouts, 7.5)
+	e.Threads, e.Reuse, e.SplitTree = *threads, *reuse, *split
+	e.MoveTime = *moveTime
+	var err error
+	if e.PinWorker, err = workerPinner(*pin); err != nil {
+		return err
+	}
+	e.Playout = PlayoutPolicy{Mercy: *mercy, MaxLength: *length, Eyes: EyeRule(*eyes)}
+	e.Temperature, e.TemperatureMoves, e.DirichletWeight = *temperature, *temperatureMoves, *dirichlet
+	g := &gtpEngine{engine: e, board: NewBoard(19)}
+	if !*quiet {
+		g.log = os.Stderr
+	}
+	return g.serve(context.Background(), os.Stdin, os.Stdout)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+)
+
+func init() {
+	commands["handicap"] = runHandicap
+}
+
+// handicapPoints returns the standard fixed handicap placement for n
+// stones: opposite corners first, then the remaining corners, the centre
+// for odd counts, and the side star points.
+func (b *Board) handicapPoints(n int) ([][2]int, error) {
+	if n < 2 {
+		return nil, nil
+	}
+	if b.size < 7 || n > 9 || (n > 4 && b.size%2 == 0) {
+		return nil, fmt.Errorf("%d handicap stones are not supported on %dx%d", n, b.size, b.size)
+	}
+	edge := 3
+	if b.size < 13 {
+		edge = 2
+	}
+	lo, mid, hi := edge, b.size/2, b.size-1-edge
+	corners := [][2]int{{lo, hi}, {hi, lo}, {hi, hi}, {lo, lo}}
+	center := [2]int{mid, mid}
+	sides := [][2]int{{mid, lo}, {mid, hi}, {lo, mid}, {hi, mid}}
+
+	points := append([][2]int{}, corners[:min(n, 4)]...)
+	switch n {
+	case 5:
+		points = append(points, center)
+	case 6:
+		points = append(points, sides[:2]...)
+	case 7:
+		points = append(points, sides[:2]...)
+		points = append(points, center)
+	case 8:
+		points = append(points, sides...)
+	case 9:
+		points = append(points, sides...)
+		points = append(points, center)
+	}
+	return points, nil
+}
+
+// PlaceHandicap puts n black handicap stones on an empty board and gives
+// White the move. A handicap of 0 or 1 leaves the board unchanged.
+func (b *Board) PlaceHandicap(n int) error {
+	points, err := b.handicapPoints(n)
+	if err != nil || len(points) == 0 {
+		return err
+	}
+	for _, p := range points {
+		b.setPoint(p[0]*b.size+p[1], Black)
+	}
+	b.turn = White
+	return nil
+}
+
+// gradesPerStone is roughly how many rank grades one handicap stone is
+// worth on each board size.
+func gradesPerStone(size int) float64 {
+	switch {
+	case size >= 19:
+		return 1
+	case size >= 13:
+		return 2.5
+	default:
+		return 4
+	}
+}
+
+// RecommendHandicap turns a rank difference in grades (White stronger) into
+// handicap stones and komi. Differences under one stone are bridged by
+// lowering komi; from one stone on Black gets the stones and 0.5 komi.
+func RecommendHandicap(grades float64, size int) (stones int, komi float64) {
+	if grades <= 0 {
+		return 0, 6.5
+	}
+	eff := grades / gradesPerStone(size)
+	if eff < 1 {
+		return 0, 0.5 + math.Round((1-eff)*6)
+	}
+	stones = int(math.Round(eff))
+	if stones == 1 {
+		return 0, 0.5
+	}
+	return min(stones, 9), 0.5
+}
+
+// headToHeadGrades nudges a rank difference by the recent results between
+// the two players: a side that keeps winning is treated as stronger.
+func headToHeadGrades(results []ArchivedResult, black, white string) (float64, int) {
+	const recent = 6
+	var score float64
+	n := 0
+	for i := len(results) - 1; i >= 0 && n < recent; i-- {
+		r := results[i]
+		switch {
+		case r.Player == white && r.Opponent == black:
+			score += r.Score
+		case r.Player == black && r.Opponent == white:
+			score += 1 - r.Score
+		default:
+			continue
+		}
+		n++
+	}
+	if n < 3 {
+		return 0, n
+	}
+	return (score/float64(n) - 0.5) * 4, n
+}
+
+func runHandicap(args []string) error {
+	fs := flag.NewFlagSet("handicap", flag.ExitOnError)
+	black := fs.String("black", "", "name of the player taking Black")
+	white := fs.String("white", "", "name of the player taking White")
+	blackRank := fs.String("black-rank", "", "Black's rank, instead of the archived rating")
+	whiteRank := fs.String("white-rank", "", "White's rank, instead of the archived rating")
+	size := fs.Int("size", 19, "board size")
+	play := fs.Bool("play", false, "start a game with the recommended settings")
+	bot := fs.String("bot", "", "with -play, colour played by the computer")
+	tui := fs.Bool("tui", false, "with -play, full-screen terminal interface")
+	fs.Parse(args)
+
+	results, err := LoadResults()
+	if err != nil {
+		return err
+	}
+	rating := func(name, rank string) (float64, error) {
+		if rank != "" {
+			r, err := ParseRank(rank)
+			return RatingForRank(r), err
+		}
+		if name == "" {
+			return 0, errors.New("each side needs a name or a rank")
+		}
+		r, n := EstimateRating(name, results)
+		if n == 0 {
+			return 0, fmt.Errorf("no archived games for %s, give a rank instead", name)
+		}
+		return r.R, nil
+	}
+	rb, err := rating(*black, *blackRank)
+	if err != nil {
+		return err
+	}
+	rw, err := rating(*white, *whiteRank)
+	if err != nil {
+		return err
+	}
+	if rb > rw {
+		fmt.Println("Black is the stronger player; swap colours for a handicap game.")
+	}
+
+	grades := (rw - rb) / 100
+	adjust, games := headToHeadGrades(results, *black, *white)
+	grades += adjust
+	stones, komi := RecommendHandicap(grades, *size)
+
+	fmt.Printf("Rank difference: %.1f grades", grades)
+	if games > 0 {
+		fmt.Printf(" (including %d recent head-to-head games)", games)
+	}
+	fmt.Println()
+	fmt.Printf("Recommended: %d handicap stones, komi %.1f on %dx%d\n", stones, komi, *size, *size)
+
+	if !*play {
+		return nil
+	}
+	opts := GameOptions{Size: *size, Komi: komi, Handicap: stones, Playouts: 1000, Player: defaultPlayer(), TUI: *tui}
+	switch *bot {
+	case "black":
+		opts.Bot = Black
+	case "white":
+		opts.Bot = White
+	}
+	if _, err := NewBoard(*size).handicapPoints(stones); err != nil {
+		return err
+	}
+	return playGame(opts)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecommendHandicap(t *testing.T) {
+	for _, tc := range []struct {
+		grades float64
+		size   int
+		stones int
+		komi   float64
+	}{
+		{-2, 19, 0, 6.5}, // Black is stronger
+		{0, 19, 0, 6.5},
+		{0.5, 19, 0, 3.5}, // half a stone: komi lowered
+		{1, 19, 0, 0.5},   // one stone: no komi
+		{3, 19, 3, 0.5},
+		{3.4, 19, 3, 0.5},
+		{12, 19, 9, 0.5}, // at most nine stones
+		{2, 13, 0, 1.5},
+		{5, 13, 2, 0.5},
+		{2, 9, 0, 3.5},
+		{8, 9, 2, 0.5},
+		{40, 9, 9, 0.5},
+	} {
+		stones, komi := RecommendHandicap(tc.grades, tc.size)
+		if stones != tc.stones || komi != tc.komi {
+			t.Errorf("%g grades on %dx%d: %d stones, komi %g; want %d, %g", tc.grades, tc.size, tc.size, stones, komi, tc.stones, tc.komi)
+		}
+	}
+}
+
+func TestHandicapPoints(t *testing.T) {
+	for _, tc := range []struct {
+		size, n int
+		want    [][2]int
+		err     bool
+	}{
+		{19, 0, nil, false},
+		{19, 1, nil, false},
+		{19, 2, [][2]int{{3, 15}, {15, 3}}, false},
+		{19, 5, [][2]int{{3, 15}, {15, 3}, {15, 15}, {3, 3}, {9, 9}}, false},
+		{19, 6, [][2]int{{3, 15}, {15, 3}, {15, 15}, {3, 3}, {9, 3}, {9, 15}}, false},
+		{19, 9, [][2]int{{3, 15}, {15, 3}, {15, 15}, {3, 3}, {9, 3}, {9, 15}, {3, 9}, {15, 9}, {9, 9}}, false},
+		{13, 3, [][2]int{{3, 9}, {9, 3}, {9, 9}}, false},
+		{9, 4, [][2]int{{2, 6}, {6, 2}, {6, 6}, {2, 2}}, false}, // 3-3 points below 13x13
+		{9, 7, [][2]int{{2, 6}, {6, 2}, {6, 6}, {2, 2}, {4, 2}, {4, 6}, {4, 4}}, false},
+		{8, 4, [][2]int{{2, 5}, {5, 2}, {5, 5}, {2, 2}}, false},
+		{8, 5, nil, true}, // no centre on even boards
+		{5, 2, nil, true},
+		{19, 10, nil, true},
+	} {
+		got, err := NewBoard(tc.size).handicapPoints(tc.n)
+		if (err != nil) != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d stones on %dx%d: %v, %v", tc.n, tc.size, tc.size, got, err)
+		}
+	}
+
+	b := NewBoard(19)
+	if err := b.PlaceHandicap(4); err != nil || b.stoneCount(Black) != 4 || b.turn != White {
+		t.Errorf("PlaceHandicap(4): %v, %d stones, %v to move", err, b.stoneCount(Black), b.turn)
+	}
+}
+
+func TestHeadToHeadGrades(t *testing.T) {
+	games := func(scores ...float64) []ArchivedResult {
+		var results []ArchivedResult
+		for _, s := range scores {
+			results = append(results, ArchivedResult{Player: "w", Opponent: "b", Score: s})
+		}
+		return results
+	}
+	if g, n := headToHeadGrades(games(1, 1), "b", "w"); g != 0 || n != 2 {
+		t.Errorf("two games: %g grades from %d", g, n)
+	}
+	// White won the last six games; the losses before them are too old
+	// to count.
+	if g, n := headToHeadGrades(games(0, 0, 0, 0, 1, 1, 1, 1, 1, 1), "b", "w"); g != 2 || n != 6 {
+		t.Errorf("White ahead: %g grades from %d", g, n)
+	}
+	// Three wins for Black, archived from Black's side.
+	results := games(1, 1, 1)
+	for i := range results {
+		results[i].Player, results[i].Opponent = "b", "w"
+	}
+	if g, _ := headToHeadGrades(results, "b", "w"); g != -2 {
+		t.Errorf("Black ahead: %g grades", g)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"image/png"
+	"io"
+	"os"
+)
+
+func init() {
+	commands["export-html"] = runExportHTML
+}
+
+// viewerHTML is the page written by export-html: the game as JSON and a
+// small script that replays it, so the file needs nothing else to view.
+//
+//go:embed viewer.html
+var viewerHTML string
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(viewerHTML))
+
+// htmlStone and htmlGame are the game as the viewer script reads it.
+// Colours are 1 for Black and 2 for White; p is a move index or -1.
+type htmlStone struct {
+	C       Stone  `json:"c"`
+	P       int    `json:"p"`
+	Comment string `json:"comment,omitempty"`
+	Mark    string `json:"mark,omitempty"` // annotation symbol, e.g. "?!"
+}
+
+type htmlGame struct {
+	Size    int         `json:"size"`
+	Stars   []int       `json:"stars"`
+	Comment string      `json:"comment,omitempty"`
+	Setup   []htmlStone `json:"setup"`
+	Moves   []htmlStone `json:"moves"`
+}
+
+// WriteHTML writes g as a self-contained HTML viewer. With thumbEvery
+// positive, the move list has a thumbnail of the position every
+// thumbEvery moves.
+func WriteHTML(w io.Writer, g *GameRecord, thumbEvery int) error {
+	game := htmlGame{Size: g.Size, Stars: starPoints(g.Size), Comment: g.Info["GC"], Setup: []htmlStone{}, Moves: []htmlStone{}}
+	for _, s := range g.Setup {
+		if s.Move != PassMove {
+			game.Setup = append(game.Setup, htmlStone{C: s.Color, P: s.Move})
+		}
+	}
+	thumbs := map[int]string{}
+	b := g.Start()
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		if !b.Play(m.Move) {
+			return fmt.Errorf("move %d (%s) is illegal", i+1, formatMove(m.Move, g.Size))
+		}
+		q, emphasis := m.Quality()
+		game.Moves = append(game.Moves, htmlStone{C: m.Color, P: m.Move, Comment: m.Comment, Mark: qualitySymbol(q, emphasis)})
+		if thumbEvery > 0 && (i+1)%thumbEvery == 0 {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, RenderThumbnail(b, 96)); err != nil {
+				return err
+			}
+			thumbs[i+1] = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+	}
+
+	title := g.Black + " vs " + g.White
+	if g.Black == "" && g.White == "" {
+		title = "Go game"
+	}
+	if g.Result != "" {
+		title += ", " + g.Result
+	}
+	return viewerTemplate.Execute(w, struct {
+		Title  string
+		Game   htmlGame
+		Thumbs map[int]string
+	}{title, game, thumbs})
+}
+
+func runExportHTML(args []string) error {
+	fs := flag.NewFlagSet("export-html", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default: the SGF name with .html)")
+	thumbs := fs.Int("thumbs", 0, "thumbnail of the position every N moves in the move list")
+	game := fs.Int("game", 1, "game number within the file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: export-html file.sgf [-o game.html] [-thumbs N] [-game N]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = trimExt(path) + ".html"
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, g, *thumbs); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader(`(;GM[1]SZ[9]PB[Alice]PW[Bob];B[ee]C[see </script> here];W[ef];B[fe];W[df])`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := RecordFromSGF(games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, g, 2); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		"<title>Alice vs Bob</title>",
+		`{"c":1,"p":40,"comment":"see \u003c/script\u003e here"}`,
+		`"2":"data:image/png;base64,`,
+		`"4":"data:image/png;base64,`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %s", want)
+		}
+	}
+	if strings.Count(page, "</script>") != 1 {
+		t.Error("a comment closed the script element")
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["import"] = runImport
+}
+
+const (
+	ogsAPI      = "https://online-go.com/api/v1"
+	kgsArchives = "https://www.gokgs.com/servlet/archives/en_US"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func httpGet(rawURL string) ([]byte, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ogsPlayer is a player as it appears in OGS game data. Ranks count up
+// from 0 at 30k, so 30 is 1d.
+type ogsPlayer struct {
+	ID       int     `json:"id"`
+	Username string  `json:"username"`
+	Rank     float64 `json:"rank"`
+}
+
+// ogsGameData is the "gamedata" object of an OGS game. Moves are
+// [x, y, milliseconds] with x = -1 for a pass; the initial state lists
+// setup stones as concatenated SGF points.
+type ogsGameData struct {
+	GameID   int     `json:"game_id"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	Komi     float64 `json:"komi"`
+	Handicap int     `json:"handicap"`
+	Players  struct {
+		Black ogsPlayer `json:"black"`
+		White ogsPlayer `json:"white"`
+	} `json:"players"`
+	InitialState struct {
+		Black string `json:"black"`
+		White string `json:"white"`
+	} `json:"initial_state"`
+	InitialPlayer string      `json:"initial_player"`
+	Moves         [][]float64 `json:"moves"`
+	Winner        int         `json:"winner"`
+	Outcome       string      `json:"outcome"`
+	StartTime     int64       `json:"start_time"`
+}
+
+func ogsRank(r float64) string {
+	if r <= 0 {
+		return ""
+	}
+	return (Rank(math.Floor(r)) - 30).String()
+}
+
+// ogsResult converts an OGS outcome such as "12.5 points", "Resignation"
+// or "Timeout" into an SGF result.
+func ogsResult(d *ogsGameData) string {
+	winner := ""
+	switch d.Winner {
+	case 0:
+		return ""
+	case d.Players.Black.ID:
+		winner = "B+"
+	case d.Players.White.ID:
+		winner = "W+"
+	default:
+		return ""
+	}
+	outcome := strings.ToLower(d.Outcome)
+	switch {
+	case strings.HasSuffix(outcome, " points"):
+		return winner + strings.TrimSuffix(outcome, " points")
+	case strings.Contains(outcome, "resign"):
+		return winner + "R"
+	case strings.Contains(outcome, "timeout"):
+		return winner + "T"
+	case outcome != "":
+		return winner + "F"
+	}
+	return winner
+}
+
+// RecordFromOGS normalizes OGS game data into a GameRecord. It accepts
+// either a bare gamedata object or a game object wrapping one.
+func RecordFromOGS(data []byte) (*GameRecord, int, error) {
+	var wrapper struct {
+		ID       int             `json:"id"`
+		GameData json.RawMessage `json:"gamedata"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, 0, err
+	}
+	if len(wrapper.GameData) > 0 {
+		data = wrapper.GameData
+	}
+	var d ogsGameData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, 0, err
+	}
+	if d.GameID == 0 {
+		d.GameID = wrapper.ID
+	}
+	if d.Width != d.Height || d.Width < 2 || d.Width > 52 {
+		return nil, 0, fmt.Errorf("ogs game %d: unsupported %dx%d board", d.GameID, d.Width, d.Height)
+	}
+	g := &GameRecord{
+		Size: d.Width, Komi: d.Komi, Handicap: d.Handicap,
+		Black: d.Players.Black.Username, White: d.Players.White.Username,
+		BlackRank: ogsRank(d.Players.Black.Rank), WhiteRank: ogsRank(d.Players.White.Rank),
+		Result: ogsResult(&d),
+		Info:   map[string]string{"PC": "OGS", "GN": fmt.Sprintf("OGS game %d", d.GameID)},
+	}
+	if d.StartTime > 0 {
+		g.Date = time.Unix(d.StartTime, 0).UTC().Format("2006-01-02")
+	}
+	for _, setup := range []struct {
+		points string
+		color  Stone
+	}{{d.InitialState.Black, Black}, {d.InitialState.White, White}} {
+		for i := 0; i+1 < len(setup.points); i += 2 {
+			m, err := sgfPoint(setup.points[i:i+2], g.Size)
+			if err != nil {
+				return nil, 0, err
+			}
+			g.Setup = append(g.Setup, RecordedMove{Color: setup.color, Move: m})
+		}
+	}
+
+	// Free-placement handicap stones are Black's first moves on OGS, so
+	// replay the moves to find out whose turn each one was.
+	b := g.Start()
+	if d.InitialPlayer == "white" {
+		b.turn = White
+	}
+	freeHandicap := 0
+	if len(g.Setup) == 0 && d.Handicap > 1 {
+		freeHandicap = d.Handicap
+	}
+	for i, mv := range d.Moves {
+		if len(mv) < 2 {
+			return nil, 0, fmt.Errorf("ogs game %d: malformed move %d", d.GameID, i+1)
+		}
+		m := PassMove
+		if x, y := mv[0], mv[1]; x != -1 {
+			if x < 0 || x >= float64(g.Size) || y < 0 || y >= float64(g.Size) || x != math.Trunc(x) || y != math.Trunc(y) {
+				return nil, 0, fmt.Errorf("ogs game %d: move %d at (%g, %g) is off the board", d.GameID, i+1, x, y)
+			}
+			m = int(y)*g.Size + int(x)
+		}
+		color := b.turn
+		if i < freeHandicap {
+			color = Black
+		}
+		b.turn = color
+		if !b.Play(m) {
+			return nil, 0, fmt.Errorf("ogs game %d: illegal move %d", d.GameID, i+1)
+		}
+		g.Moves = append(g.Moves, RecordedMove{Color: color, Move: m})
+	}
+	return g, d.GameID, nil
+}
+
+// fetchOGS downloads up to max of a user's most recent finished games.
+func fetchOGS(user string, max int) ([][]byte, error) {
+	body, err := httpGet(ogsAPI + "/players?username=" + url.QueryEscape(user))
+	if err != nil {
+		return nil, err
+	}
+	var players struct {
+		Results []ogsPlayer `json:"results"`
+	}
+	if err := json.Unmarshal(body, &players); err != nil {
+		return nil, err
+	}
+	if len(players.Results) == 0 {
+		return nil, fmt.Errorf("ogs: no player named %q", user)
+	}
+
+	var games [][]byte
+	next := fmt.Sprintf("%s/players/%d/games/?ordering=-id&ended__isnull=false&page_size=50", ogsAPI, players.Results[0].ID)
+	for next != "" && len(games) < max {
+		body, err := httpGet(next)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				ID int `json:"id"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Results {
+			if len(games) == max {
+				break
+			}
+			game, err := httpGet(fmt.Sprintf("%s/games/%d", ogsAPI, r.ID))
+			if err != nil {
+				return nil, err
+			}
+			games = append(games, game)
+		}
+		next = page.Next
+	}
+	return games, nil
+}
+
+// sgfFromArchive extracts the SGF files from a KGS archive, which may be a
+// zip or tar.gz file, or a plain SGF file.
+func sgfFromArchive(name string, data []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if !strings.HasSuffix(strings.ToLower(f.Name), ".sgf") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			files[f.Name] = content
+		}
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasSuffix(strings.ToLower(h.Name), ".sgf") {
+				continue
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files[h.Name] = content
+		}
+	default:
+		files[name] = data
+	}
+	return files, nil
+}
+
+// importSGFData adds every game tree in an SGF file to the database. With
+// verify, games whose ZH position hashes do not match are rejected.
+func importSGFData(db GameStore, source, name string, data []byte, verify bool) (added, skipped int, err error) {
+	games, err := ParseSGF(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", name, err)
+	}
+	for i, root := range games {
+		if verify {
+			if _, err := VerifyPositionHashes(root); err != nil {
+				return added, skipped, fmt.Errorf("%s: game %d: %w", name, i+1, err)
+			}
+		}
+		g, err := RecordFromSGF(root)
+		if err != nil {
+			return added, skipped, fmt.Errorf("%s: %w", name, err)
+		}
+		id := fmt.Sprintf("%s:%s", source, filepath.Base(name))
+		if len(games) > 1 {
+			id = fmt.Sprintf("%s#%d", id, i+1)
+		}
+		if ok, err := db.Add(id, source, g); err != nil {
+			return added, skipped, err
+		} else if ok {
+			added++
+		} else {
+			skipped++
+		}
+	}
+	return added, skipped, nil
+}
+
+func runImport(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: import ogs|kgs|sgf|moves [flags] [files...]")
+	}
+	fs := flag.NewFlagSet("import "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	user := fs.String("user", "", "download the games of this online account")
+
+	var added, skipped int
+	var db GameStore
+	open := func() (err error) {
+		db, err = OpenStore(*dbPath)
+		return err
+	}
+
+	switch args[0] {
+	case "ogs":
+		max := fs.Int("max", 100, "maximum number of games to download")
+		fs.Parse(args[1:])
+		if err := open(); err != nil {
+			return err
+		}
+		var raw [][]byte
+		if *user != "" {
+			var err error
+			if raw, err = fetchOGS(*user, *max); err != nil {
+				return err
+			}
+		}
+		for _, path := range fs.Args() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			raw = append(raw, data)
+		}
+		for _, data := range raw {
+			g, id, err := RecordFromOGS(data)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if ok, err := db.Add(fmt.Sprintf("ogs:%d", id), "ogs", g); err != nil {
+				return err
+			} else if ok {
+				added++
+			} else {
+				skipped++
+			}
+		}
+	case "kgs", "sgf":
+		month := fs.String("month", "", "with -user, the archive month as YYYY-MM")
+		verify := fs.Bool("verify-hashes", false, "reject games whose ZH position hashes do not replay")
+		fs.Parse(args[1:])
+		if err := open(); err != nil {
+			return err
+		}
+		archives := map[string][]byte{}
+		if *user != "" {
+			if args[0] != "kgs" {
+				return errors.New("-user only works with import kgs")
+			}
+			t, err := time.Parse("2006-01", *month)
+			if err != nil {
+				return errors.New("-month must be YYYY-MM")
+			}
+			name := fmt.Sprintf("%s-%d-%d.zip", url.PathEscape(*user), t.Year(), int(t.Month()))
+			data, err := httpGet(kgsArchives + "/" + name)
+			if err != nil {
+				return err
+			}
+			archives[name] = data
+		}
+		for _, path := range fs.Args() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			archives[path] = data
+		}
+		for name, data := range archives {
+			files, err := sgfFromArchive(name, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			for fname, content := range files {
+				a, s, err := importSGFData(db, args[0], fname, content, *verify)
+				added, skipped = added+a, skipped+s
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}
+	case "moves":
+		size := fs.Int("size", 0, "board size (default from a boardsize command or the moves)")
+		komi := fs.Float64("komi", 6.5, "komi, unless the list sets it")
+		out := fs.String("o", "", "write the game to this SGF file instead of the database")
+		fs.Parse(args[1:])
+		if fs.NArg() == 0 {
+			return errors.New("usage: import moves [-size N] [-komi K] [-o game.sgf] files... (- for standard input)")
+		}
+		if *out != "" && fs.NArg() > 1 {
+			return errors.New("-o takes one move list")
+		}
+		if *out == "" {
+			if err := open(); err != nil {
+				return err
+			}
+		}
+		for _, path := range fs.Args() {
+			var data []byte
+			var err error
+			if path == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(path)
+			}
+			if err != nil {
+				return err
+			}
+			g, err := ParseMoveList(string(data), *size, *komi)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			if *out != "" {
+				if err := WriteSGFFile(*out, []*SGFNode{g.SGF()}); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote %s (%dx%d, %d moves)\n", *out, g.Size, g.Size, len(g.Moves))
+				return nil
+			}
+			if ok, err := db.Add("moves:"+filepath.Base(path), "moves", g); err != nil {
+				return err
+			} else if ok {
+				added++
+			} else {
+				skipped++
+			}
+		}
+	default:
+		return fmt.Errorf("unknown import source %q", args[0])
+	}
+
+	games, err := db.Games()
+	if err != nil {
+		db.Close()
+		return err
+	}
+	fmt.Printf("Imported %d games (%d already in the database), %d games total\n", added, skipped, len(games))
+	return db.Close()
+}
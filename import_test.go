@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+const ogsGame = `{"id": 42, "gamedata": {
+	"width": 9, "height": 9, "komi": 6.5,
+	"players": {"black": {"id": 1, "username": "alice", "rank": 30.4}, "white": {"id": 2, "username": "bob", "rank": 25}},
+	"initial_player": "black",
+	"moves": [[4, 4, 1000], [2, 2, 900], [-1, -1, 500], [6, 2, 800]],
+	"winner": 2, "outcome": "Resignation", "start_time": 1700000000
+}}`
+
+func TestRecordFromOGS(t *testing.T) {
+	g, id, err := RecordFromOGS([]byte(ogsGame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 || g.Size != 9 || g.Komi != 6.5 || g.Black != "alice" || g.BlackRank != "1d" || g.WhiteRank != "5k" {
+		t.Errorf("got id %d, %+v", id, g)
+	}
+	if g.Result != "W+R" || g.Date != "2023-11-14" {
+		t.Errorf("result %q, date %q", g.Result, g.Date)
+	}
+	want := []RecordedMove{{Color: Black, Move: 4*9 + 4}, {Color: White, Move: 2*9 + 2}, {Color: Black, Move: PassMove}, {Color: White, Move: 2*9 + 6}}
+	if len(g.Moves) != len(want) {
+		t.Fatalf("moves %+v", g.Moves)
+	}
+	for i, m := range want {
+		if g.Moves[i].Color != m.Color || g.Moves[i].Move != m.Move {
+			t.Errorf("move %d = %+v, want %+v", i+1, g.Moves[i], m)
+		}
+	}
+}
+
+func TestRecordFromOGSRejects(t *testing.T) {
+	for name, moves := range map[string]string{
+		"x off the board":  `[[9, 0]]`,
+		"y off the board":  `[[0, 9]]`,
+		"wrapping x":       `[[12, 1]]`,
+		"negative y":       `[[3, -2]]`,
+		"fractional point": `[[1.5, 2]]`,
+		"short move":       `[[3]]`,
+		"occupied point":   `[[3, 3], [3, 3]]`,
+	} {
+		data := `{"width": 9, "height": 9, "moves": ` + moves + `}`
+		if _, _, err := RecordFromOGS([]byte(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	for name, data := range map[string]string{
+		"not JSON":      `{"width": 9`,
+		"not square":    `{"width": 9, "height": 13}`,
+		"too big":       `{"width": 53, "height": 53}`,
+		"bad setup":     `{"width": 9, "height": 9, "initial_state": {"black": "zz"}}`,
+		"moves not num": `{"width": 9, "height": 9, "moves": [["a", "b"]]}`,
+	} {
+		if _, _, err := RecordFromOGS([]byte(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestOGSResultAndRank(t *testing.T) {
+	d := &ogsGameData{}
+	d.Players.Black.ID, d.Players.White.ID = 1, 2
+	for _, c := range []struct {
+		winner  int
+		outcome string
+		want    string
+	}{
+		{1, "12.5 points", "B+12.5"},
+		{2, "Resignation", "W+R"},
+		{1, "Timeout", "B+T"},
+		{2, "Disconnection", "W+F"},
+		{1, "", "B+"},
+		{0, "Resignation", ""},
+		{3, "Resignation", ""},
+	} {
+		d.Winner, d.Outcome = c.winner, c.outcome
+		if got := ogsResult(d); got != c.want {
+			t.Errorf("winner %d, %q: got %q, want %q", c.winner, c.outcome, got, c.want)
+		}
+	}
+	for r, want := range map[float64]string{0: "", -3: "", 30: "1d", 30.9: "1d", 29.5: "1k", 10: "20k", 36: "7d"} {
+		if got := ogsRank(r); got != want {
+			t.Errorf("ogsRank(%g) = %q, want %q", r, got, want)
+		}
+	}
+}
+
+func TestKGSArchives(t *testing.T) {
+	game := []byte("(;GM[1]SZ[9]PB[alice]PW[bob];B[ee];W[cc])")
+
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	for _, name := range []string{"alice/a.sgf", "alice/B.SGF", "readme.txt"} {
+		w, _ := zw.Create(name)
+		w.Write(game)
+	}
+	zw.Close()
+
+	var tbuf bytes.Buffer
+	gz := gzip.NewWriter(&tbuf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"c.sgf", "notes"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(game))})
+		tw.Write(game)
+	}
+	tw.Close()
+	gz.Close()
+
+	for name, c := range map[string]struct {
+		data  []byte
+		files int
+	}{
+		"alice-2024-1.zip": {zbuf.Bytes(), 2},
+		"games.tar.gz":     {tbuf.Bytes(), 1},
+		"one.sgf":          {game, 1},
+	} {
+		files, err := sgfFromArchive(name, c.data)
+		if err != nil || len(files) != c.files {
+			t.Errorf("%s: %d files, %v", name, len(files), err)
+		}
+	}
+	for _, name := range []string{"bad.zip", "bad.tar.gz"} {
+		if _, err := sgfFromArchive(name, []byte("not an archive")); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	db, err := OpenStore("memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	files, _ := sgfFromArchive("alice-2024-1.zip", zbuf.Bytes())
+	added, skipped := 0, 0
+	for fname, content := range files {
+		a, s, err := importSGFData(db, "kgs", fname, content, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added, skipped = added+a, skipped+s
+	}
+	if added != 1 || skipped != 1 {
+		t.Errorf("added %d, skipped %d: the same game twice should be added once", added, skipped)
+	}
+	if _, _, err := importSGFData(db, "kgs", "broken.sgf", []byte("(;SZ[9];B[ee]"), false); err == nil || !strings.Contains(err.Error(), "broken.sgf") {
+		t.Errorf("a truncated SGF file: %v", err)
+	}
+}
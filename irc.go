@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serve -lobby -irc HOST:PORT brings players in from IRC. The bridge joins
+// -irc-channel as -irc-nick, and people message it privately, or in the
+// channel with a ! in front: "!challenge size=9", "!accept 3", "!D4". It
+// answers each of them privately, with boards drawn in text. Every nick
+// is one connection to the lobby, which hosts the games and checks every
+// move; leaving (QUIT, or PART from the channel) leaves the lobby and
+// forfeits a game in progress. Matrix users can play through one of the
+// Matrix-IRC bridges.
+
+const (
+	ircLineRate  = 2  // lines a second the bridge sends, under servers' flood limits
+	ircLineBurst = 10 // lines it may send at once first
+)
+
+// ircBridge is a connection to an IRC server for a lobby.
+type ircBridge struct {
+	conn    net.Conn
+	nick    string
+	channel string
+	lobby   *lobby
+	pace    *rateLimiter  // of lines sent to users; nil for no limit
+	out     chan string   // paced lines waiting to be sent
+	done    chan struct{} // closed when the connection is
+	writeMu sync.Mutex
+
+	mu    sync.Mutex
+	users map[string]*ircUser // by nick
+}
+
+// ircUser is someone in the lobby through the bridge.
+type ircUser struct {
+	nick    string // guarded by the bridge's mu
+	session *chatSession
+}
+
+func newIRCBridge(conn net.Conn, nick, channel string, l *lobby) *ircBridge {
+	return &ircBridge{
+		conn: conn, nick: nick, channel: channel, lobby: l,
+		pace:  newRateLimiter(ircLineRate, ircLineBurst),
+		out:   make(chan string, 4096),
+		done:  make(chan struct{}),
+		users: map[string]*ircUser{},
+	}
+}
+
+// raw sends a line at once, for the protocol's own messages.
+func (b *ircBridge) raw(line string) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	fmt.Fprintf(b.conn, "%s\r\n", line)
+}
+
+// writePaced sends the lines queued for users no faster than the pace.
+func (b *ircBridge) writePaced() {
+	for {
+		select {
+		case line := <-b.out:
+			for !b.pace.allow(time.Now()) {
+				time.Sleep(100 * time.Millisecond)
+			}
+			b.raw(line)
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// say queues text and the board, line by line, as private messages to
+// nick.
+func (b *ircBridge) say(nick, text, board string) {
+	for _, line := range strings.Split(strings.TrimSpace(text+"\n"+board), "\n") {
+		if line = strings.TrimRight(line, " "); line == "" {
+			continue
+		}
+		select {
+		case b.out <- "PRIVMSG " + nick + " :" + line:
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// session returns nick's session, joining them to the lobby if they are
+// new.
+func (b *ircBridge) session(nick string) *chatSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if u := b.users[nick]; u != nil {
+		return u.session
+	}
+	u := &ircUser{nick: nick}
+	u.session = startChatSession(b.lobby, nick, "!", func(text, board string) {
+		b.mu.Lock()
+		to := u.nick
+		b.mu.Unlock()
+		b.say(to, text, board)
+	}, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.users[u.nick] == u {
+			delete(b.users, u.nick)
+		}
+	})
+	b.users[nick] = u
+	return u.session
+}
+
+// leave ends nick's session, if they have one.
+func (b *ircBridge) leave(nick string) {
+	b.mu.Lock()
+	u := b.users[nick]
+	delete(b.users, nick)
+	b.mu.Unlock()
+	if u != nil {
+		u.session.close()
+	}
+}
+
+// rename follows a nick change, in the lobby too unless they are
+// playing.
+func (b *ircBridge) rename(from, to string) {
+	b.mu.Lock()
+	u := b.users[from]
+	if u != nil {
+		delete(b.users, from)
+		u.nick = to
+		b.users[to] = u
+	}
+	b.mu.Unlock()
+	if u != nil {
+		u.session.send(netInput{Command: "name " + to})
+	}
+}
+
+// parseIRC splits a line into its source's nick, its command and its
+// parameters, the last of which may have spaces.
+func parseIRC(line string) (nick, command string, params []string) {
+	if strings.HasPrefix(line, ":") {
+		var source string
+		source, line, _ = strings.Cut(line[1:], " ")
+		nick, _, _ = strings.Cut(source, "!")
+	}
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nick, "", nil
+	}
+	params = fields[1:]
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return nick, strings.ToUpper(fields[0]), params
+}
+
+// run registers with the server and bridges the lobby until the
+// connection closes.
+func (b *ircBridge) run() error {
+	go b.writePaced()
+	defer close(b.done)
+	b.raw("NICK " + b.nick)
+	b.raw("USER " + b.nick + " 0 * :gogame lobby")
+	in := bufio.NewScanner(b.conn)
+	for in.Scan() {
+		nick, command, params := parseIRC(strings.TrimRight(in.Text(), "\r"))
+		last := ""
+		if len(params) > 0 {
+			last = params[len(params)-1]
+		}
+		switch command {
+		case "PING":
+			b.raw("PONG :" + last)
+		case "001": // registered
+			b.raw("JOIN " + b.channel)
+		case "433": // nick in use
+			b.nick += "_"
+			b.raw("NICK " + b.nick)
+		case "PRIVMSG":
+			if len(params) < 2 || strings.HasPrefix(last, "\x01") { // CTCP
+				continue
+			}
+			if strings.EqualFold(params[0], b.channel) && !strings.HasPrefix(last, "!") {
+				continue
+			}
+			if strings.EqualFold(params[0], b.nick) || strings.EqualFold(params[0], b.channel) {
+				b.session(nick).input(last)
+			}
+		case "NICK":
+			if nick != b.nick {
+				b.rename(nick, last)
+			}
+		case "QUIT":
+			b.leave(nick)
+		case "PART", "KICK":
+			if len(params) > 0 && strings.EqualFold(params[0], b.channel) {
+				if command == "KICK" && len(params) > 1 {
+					nick = params[1]
+				}
+				b.leave(nick)
+			}
+		}
+	}
+	b.mu.Lock()
+	users := b.users
+	b.users = map[string]*ircUser{}
+	b.mu.Unlock()
+	for _, u := range users {
+		u.session.close()
+	}
+	if err := in.Err(); err != nil {
+		return err
+	}
+	return errors.New("the IRC server closed the connection")
+}
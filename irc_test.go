@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIRC(t *testing.T) {
+	nick, command, params := parseIRC(":alice!a@example.net PRIVMSG #gogame :!challenge size=9")
+	if nick != "alice" || command != "PRIVMSG" || len(params) != 2 || params[0] != "#gogame" || params[1] != "!challenge size=9" {
+		t.Errorf("parseIRC = %q, %q, %q", nick, command, params)
+	}
+	if _, command, params := parseIRC("PING :irc.example.net"); command != "PING" || params[0] != "irc.example.net" {
+		t.Errorf("parseIRC(PING) = %q, %q", command, params)
+	}
+}
+
+func TestIRCBridge(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	b := newIRCBridge(client, "gogame", "#gogame", newLobby(time.Second, nil, nil))
+	b.pace = nil
+	go b.run()
+
+	lines := make(chan string, 100)
+	go func() {
+		in := bufio.NewScanner(server)
+		for in.Scan() {
+			lines <- strings.TrimRight(in.Text(), "\r")
+		}
+	}()
+	expect := func(want string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case line := <-lines:
+				if strings.Contains(line, want) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("the bridge did not send %q", want)
+			}
+		}
+	}
+	say := func(format string, args ...any) {
+		go fmt.Fprintf(server, format+"\r\n", args...)
+	}
+
+	expect("USER gogame")
+	say(":irc.example.net 001 gogame :Welcome")
+	expect("JOIN #gogame")
+	say("PING :irc.example.net")
+	expect("PONG :irc.example.net")
+
+	say(":alice!a@example.net PRIVMSG #gogame :!challenge size=5 color=black")
+	expect("PRIVMSG alice :New challenge #1 alice")
+	say(":bob!b@example.net PRIVMSG gogame :accept 1")
+	expect("PRIVMSG alice :You play black.")
+	say(":alice!a@example.net PRIVMSG gogame :C3")
+	expect("PRIVMSG bob :black plays C3. Your move 2.")
+	expect("PRIVMSG bob : 3 + + ● + +")
+
+	say(":bob!b@example.net QUIT :bye")
+	expect("PRIVMSG alice :Result: B+F")
+}
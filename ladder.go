@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["ladder"] = runLadder
+}
+
+// The ladder is a row of bots of rising strength, 20k to 5k, to climb one
+// at a time: gogame -ladder -bot white plays the player's current rung, and
+// ladderPromoteWins wins in a row against it move the player up to the
+// next. Games are archived in the stats DB like any other, against
+// "ladder 12k" and so on, and the player's place is worked out from them,
+// so the ladder needs no file of its own and its games count towards the
+// player's rating. The ranks are nominal: each rung doubles the playouts
+// of the one below and makes fewer deliberate mistakes.
+
+// LadderBot is one rung of the ladder.
+type LadderBot struct {
+	Rank     Rank
+	Playouts int
+	Noise    float64 // see Engine.Noise
+	Choice   int     // see Engine.Choice
+}
+
+var ladderBots = []LadderBot{
+	{Rank: -20, Playouts: 16, Noise: 0.5, Choice: 3},
+	{Rank: -18, Playouts: 32, Noise: 0.4, Choice: 3},
+	{Rank: -16, Playouts: 64, Noise: 0.3, Choice: 2},
+	{Rank: -14, Playouts: 128, Noise: 0.25, Choice: 2},
+	{Rank: -12, Playouts: 256, Noise: 0.2, Choice: 1},
+	{Rank: -10, Playouts: 512, Noise: 0.15, Choice: 1},
+	{Rank: -8, Playouts: 1024, Noise: 0.1, Choice: 1},
+	{Rank: -6, Playouts: 2048, Noise: 0.05, Choice: 1},
+	{Rank: -5, Playouts: 4096, Noise: 0, Choice: 1},
+}
+
+// ladderPromoteWins is how many wins in a row against a rung move the
+// player up.
+const ladderPromoteWins = 3
+
+// Name is how the bot's games are archived.
+func (l LadderBot) Name() string {
+	return "ladder " + l.Rank.String()
+}
+
+// Engine configures an engine to play as the bot.
+func (l LadderBot) Engine(komi float64) *Engine {
+	e := NewEngine(l.Playouts, komi)
+	e.Noise, e.Choice = l.Noise, l.Choice
+	return e
+}
+
+// LadderProgress is where a player stands on the ladder.
+type LadderProgress struct {
+	Rung   int // index in ladderBots; len(ladderBots) once the top is beaten
+	Streak int // wins in a row against the rung
+	Games  int // ladder games played
+}
+
+// Bot is the rung to play next, false once the ladder is climbed.
+func (p LadderProgress) Bot() (LadderBot, bool) {
+	if p.Rung >= len(ladderBots) {
+		return LadderBot{}, false
+	}
+	return ladderBots[p.Rung], true
+}
+
+// LadderStanding works out player's place from their archived results, in
+// the order they were played. Games against other rungs than the current
+// one, replayed by hand, do not count.
+func LadderStanding(player string, results []ArchivedResult) LadderProgress {
+	var p LadderProgress
+	for _, r := range results {
+		bot, ok := p.Bot()
+		if r.Player != player || !strings.HasPrefix(r.Opponent, "ladder ") {
+			continue
+		}
+		p.Games++
+		if !ok || r.Opponent != bot.Name() {
+			continue
+		}
+		if r.Score < 1 {
+			p.Streak = 0
+			continue
+		}
+		if p.Streak++; p.Streak >= ladderPromoteWins {
+			p.Rung, p.Streak = p.Rung+1, 0
+		}
+	}
+	return p
+}
+
+// loadLadderStanding is player's place from the stats DB.
+func loadLadderStanding(player string) (LadderProgress, error) {
+	results, err := LoadResults()
+	if err != nil {
+		return LadderProgress{}, err
+	}
+	return LadderStanding(player, results), nil
+}
+
+// recordLadderGame archives a game against bot and describes what it did
+// to the player's place.
+func recordLadderGame(player string, bot LadderBot, score float64, size, handicap int) (string, error) {
+	before, err := loadLadderStanding(player)
+	if err != nil {
+		return "", err
+	}
+	if err := ArchiveResult(ArchivedResult{
+		Date: time.Now(), Player: player, Opponent: bot.Name(), OpponentRank: bot.Rank,
+		Score: score, BoardSize: size, Handicap: handicap,
+	}); err != nil {
+		return "", err
+	}
+	after, err := loadLadderStanding(player)
+	if err != nil {
+		return "", err
+	}
+	next, ok := after.Bot()
+	switch {
+	case after.Rung > before.Rung && !ok:
+		return fmt.Sprintf("You beat the %s bot %d times in a row and have climbed the whole ladder!", bot.Rank, ladderPromoteWins), nil
+	case after.Rung > before.Rung:
+		return fmt.Sprintf("Promoted! Your next opponent is the %s bot.", next.Rank), nil
+	case !ok:
+		return "You have already climbed the whole ladder.", nil
+	}
+	return fmt.Sprintf("Ladder: %d of %d wins in a row against the %s bot.", after.Streak, ladderPromoteWins, next.Rank), nil
+}
+
+func runLadder(args []string) error {
+	fs := flag.NewFlagSet("ladder", flag.ExitOnError)
+	player := fs.String("player", defaultPlayer(), "player whose progress to show")
+	fs.Parse(args)
+	p, err := loadLadderStanding(*player)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Ladder of %s, %d games played:\n", *player, p.Games)
+	for i, bot := range ladderBots {
+		status := ""
+		switch {
+		case i < p.Rung:
+			status = "beaten"
+		case i == p.Rung:
+			status = fmt.Sprintf("current, %d of %d wins in a row", p.Streak, ladderPromoteWins)
+		}
+		fmt.Printf("  %-4s %5d playouts  %s\n", bot.Rank, bot.Playouts, status)
+	}
+	if _, ok := p.Bot(); ok {
+		fmt.Println("Play the current rung with: gogame -ladder -bot white")
+	}
+	return nil
+}
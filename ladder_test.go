@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestLadderStanding(t *testing.T) {
+	win := func(rung int) ArchivedResult {
+		return ArchivedResult{Player: "me", Opponent: ladderBots[rung].Name(), Score: 1}
+	}
+	loss := func(rung int) ArchivedResult {
+		return ArchivedResult{Player: "me", Opponent: ladderBots[rung].Name()}
+	}
+	results := []ArchivedResult{
+		win(0), win(0), loss(0), // the loss breaks the streak
+		win(0), win(0), win(0), // promoted to the second rung
+		{Player: "me", Opponent: "adaptive bot", Score: 1},
+		{Player: "you", Opponent: ladderBots[1].Name(), Score: 1},
+		win(0), // an old rung does not count
+		win(1), win(1),
+	}
+	got := LadderStanding("me", results)
+	if want := (LadderProgress{Rung: 1, Streak: 2, Games: 9}); got != want {
+		t.Errorf("LadderStanding = %+v, want %+v", got, want)
+	}
+	results = append(results, win(1))
+	if got := LadderStanding("me", results); got.Rung != 2 || got.Streak != 0 {
+		t.Errorf("after a third win LadderStanding = %+v, want rung 2", got)
+	}
+}
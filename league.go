@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["league"] = runLeague
+}
+
+// The league rates engine versions and settings against each other by
+// self-play, for engine development: entrants are engine configurations
+// ("playouts=2000,threads=2", as engine-diff takes them) or builds run
+// over GTP, league run plays them against each other for as long as it is
+// left running, and the leaderboard rates them from every game played.
+//
+// Ratings are Elo, fitted BayesElo-style to all the games at once rather
+// than updated game by game, so the order of the games does not matter:
+// the Bradley-Terry model by maximum likelihood, with a prior of
+// leaguePriorDraws draws for each entrant against a virtual one rated 0,
+// which keeps the ratings of entrants that always win or always lose
+// finite and anchors the scale. The ± is the 95% interval from the
+// curvature of the likelihood, and LOS the likelihood that an entrant is
+// stronger than the one below it.
+
+// leaguePriorDraws is the prior: virtual draws of each entrant against an
+// entrant rated 0.
+const leaguePriorDraws = 2
+
+// LeagueEntrant is an engine in the league.
+type LeagueEntrant struct {
+	Name   string    `json:"name"`
+	Engine string    `json:"engine,omitempty"` // settings of the built-in engine
+	GTP    string    `json:"gtp,omitempty"`    // or the command running a GTP engine
+	Added  time.Time `json:"added"`
+}
+
+// LeagueGame is a game of the league.
+type LeagueGame struct {
+	Black  string    `json:"black"`
+	White  string    `json:"white"`
+	Winner string    `json:"winner"` // black, white or empty for a draw
+	Size   int       `json:"size"`
+	Komi   float64   `json:"komi"`
+	Date   time.Time `json:"date"`
+}
+
+// League is the league's state file.
+type League struct {
+	Entrants []LeagueEntrant `json:"entrants"`
+	Games    []LeagueGame    `json:"games"`
+}
+
+func leaguePath() string {
+	return dataPath("league.json")
+}
+
+func (l *League) entrant(name string) (int, bool) {
+	for i, e := range l.Entrants {
+		if e.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// player starts e for a run of games.
+func (e LeagueEntrant) player(komi float64) (gauntletPlayer, func(), error) {
+	if e.GTP != "" {
+		p, err := newGTPPlayer(e.GTP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", e.Name, err)
+		}
+		return p, func() { p.client.Close() }, nil
+	}
+	engine, err := newEngineFromSpec(e.Engine, komi)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", e.Name, err)
+	}
+	return enginePlayer{engine}, func() {}, nil
+}
+
+// nextPairing picks the next game: of the pairs of entrants, one that has
+// played the fewest games together, at random among equals, with Black
+// for whichever of them has had it less often against the other.
+func (l *League) nextPairing(rng *rand.Rand) (black, white string) {
+	type pair struct{ a, b string }
+	games, blacks := map[pair]int{}, map[pair]int{}
+	for _, g := range l.Games {
+		a, b := g.Black, g.White
+		if a > b {
+			a, b = b, a
+		}
+		games[pair{a, b}]++
+		blacks[pair{g.Black, g.White}]++
+	}
+	var fewest []pair
+	least := -1
+	for i, x := range l.Entrants {
+		for _, y := range l.Entrants[i+1:] {
+			a, b := x.Name, y.Name
+			if a > b {
+				a, b = b, a
+			}
+			switch n := games[pair{a, b}]; {
+			case least < 0 || n < least:
+				least, fewest = n, []pair{{a, b}}
+			case n == least:
+				fewest = append(fewest, pair{a, b})
+			}
+		}
+	}
+	p := fewest[rng.Intn(len(fewest))]
+	if blacks[pair{p.a, p.b}] > blacks[pair{p.b, p.a}] {
+		return p.b, p.a
+	}
+	return p.a, p.b
+}
+
+// LeagueRating is an entrant's place on the leaderboard.
+type LeagueRating struct {
+	Name   string
+	Elo    float64
+	Margin float64 // of the 95% interval
+	Games  int
+	Score  float64 // wins, with draws as halves
+	LOS    float64 // likelihood of being stronger than the next entrant, NaN for the last
+}
+
+// RateLeague fits ratings to the games, best first.
+func RateLeague(entrants []LeagueEntrant, games []LeagueGame) []LeagueRating {
+	n := len(entrants)
+	index := map[string]int{}
+	for i, e := range entrants {
+		index[e.Name] = i
+	}
+	// played[i][j] games between i and j, and score[i] the points of i,
+	// counting the prior's draws against the virtual entrant n.
+	played := make([][]float64, n+1)
+	for i := range played {
+		played[i] = make([]float64, n+1)
+	}
+	score := make([]float64, n+1)
+	ratings := make([]LeagueRating, n)
+	for i, e := range entrants {
+		ratings[i].Name = e.Name
+		played[i][n], played[n][i] = leaguePriorDraws, leaguePriorDraws
+		score[i] += leaguePriorDraws / 2.0
+	}
+	for _, g := range games {
+		b, okB := index[g.Black]
+		w, okW := index[g.White]
+		if !okB || !okW || b == w {
+			continue // removed since
+		}
+		played[b][w]++
+		played[w][b]++
+		ratings[b].Games++
+		ratings[w].Games++
+		switch g.Winner {
+		case "black":
+			score[b]++
+		case "white":
+			score[w]++
+		default:
+			score[b] += 0.5
+			score[w] += 0.5
+		}
+	}
+	for i := range ratings {
+		ratings[i].Score = score[i] - leaguePriorDraws/2.0
+	}
+
+	// Hunter's MM iterations for the strengths gamma = 10^(Elo/400), with
+	// the virtual entrant's held at 1.
+	gamma := make([]float64, n+1)
+	for i := range gamma {
+		gamma[i] = 1
+	}
+	for iter := 0; iter < 10000; iter++ {
+		change := 0.0
+		for i := 0; i < n; i++ {
+			denominator := 0.0
+			for j := 0; j <= n; j++ {
+				if played[i][j] > 0 {
+					denominator += played[i][j] / (gamma[i] + gamma[j])
+				}
+			}
+			next := score[i] / denominator
+			change = max(change, math.Abs(math.Log(next/gamma[i])))
+			gamma[i] = next
+		}
+		if change < 1e-9 {
+			break
+		}
+	}
+	for i := range ratings {
+		ratings[i].Elo = 400 * math.Log10(gamma[i])
+		// The Fisher information of the rating, in Elo.
+		info := 0.0
+		for j := 0; j <= n; j++ {
+			p := gamma[i] / (gamma[i] + gamma[j])
+			info += played[i][j] * p * (1 - p)
+		}
+		info *= math.Pow(math.Ln10/400, 2)
+		ratings[i].Margin = 1.96 / math.Sqrt(info)
+	}
+	sort.SliceStable(ratings, func(i, j int) bool { return ratings[i].Elo > ratings[j].Elo })
+	for i := range ratings {
+		ratings[i].LOS = math.NaN()
+		if i+1 < len(ratings) {
+			// The two ratings taken as independent normal estimates.
+			a, b := ratings[i], ratings[i+1]
+			sd := math.Hypot(a.Margin, b.Margin) / 1.96
+			ratings[i].LOS = 0.5 * math.Erfc(-(a.Elo-b.Elo)/sd/math.Sqrt2)
+		}
+	}
+	return ratings
+}
+
+func printLeaderboard(l *League) {
+	fmt.Printf("%d entrants, %d games\n", len(l.Entrants), len(l.Games))
+	fmt.Printf("%3s  %-24s %6s %5s %6s %6s %5s\n", "", "entrant", "Elo", "±", "games", "score", "LOS")
+	for i, r := range RateLeague(l.Entrants, l.Games) {
+		pct, los := "", ""
+		if r.Games > 0 {
+			pct = fmt.Sprintf("%.0f%%", 100*r.Score/float64(r.Games))
+		}
+		if !math.IsNaN(r.LOS) {
+			los = fmt.Sprintf("%.0f%%", 100*r.LOS)
+		}
+		elo := math.Round(r.Elo)
+		if elo == 0 {
+			elo = 0 // not -0
+		}
+		fmt.Printf("%3d. %-24s %6.0f %5.0f %6d %6s %5s\n", i+1, r.Name, elo, r.Margin, r.Games, pct, los)
+	}
+}
+
+func runLeague(args []string) error {
+	path := leaguePath()
+	var l League
+	if err := loadJSON(path, &l); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if len(args) == 0 || args[0] == "standings" {
+		printLeaderboard(&l)
+		return nil
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("league add", flag.ExitOnError)
+		name := fs.String("name", "", "name of the entrant")
+		spec := fs.String("engine", "", "settings of the built-in engine, e.g. playouts=2000,threads=2")
+		gtp := fs.String("gtp", "", "command running a GTP engine, such as another build: \"./gogame-old gtp\"")
+		fs.Parse(args[1:])
+		if *name == "" || (*spec == "") == (*gtp == "") {
+			return errors.New("usage: league add -name NAME (-engine SETTINGS | -gtp COMMAND)")
+		}
+		if _, ok := l.entrant(*name); ok {
+			return fmt.Errorf("%s is in the league already", *name)
+		}
+		if *spec != "" {
+			if _, err := newEngineFromSpec(*spec, 7); err != nil {
+				return err
+			}
+		}
+		l.Entrants = append(l.Entrants, LeagueEntrant{Name: *name, Engine: *spec, GTP: *gtp, Added: time.Now()})
+		fmt.Printf("Added %s\n", *name)
+		return saveJSON(path, &l)
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: league remove NAME")
+		}
+		i, ok := l.entrant(args[1])
+		if !ok {
+			return fmt.Errorf("no entrant %s", args[1])
+		}
+		// Its games stay in the file but no longer count.
+		l.Entrants = append(l.Entrants[:i], l.Entrants[i+1:]...)
+		fmt.Printf("Removed %s\n", args[1])
+		return saveJSON(path, &l)
+	case "run":
+		fs := flag.NewFlagSet("league run", flag.ExitOnError)
+		size := fs.Int("size", 9, "board size")
+		komi := fs.Float64("komi", 7, "komi")
+		games := fs.Int("games", 0, "games to play, 0 to play until interrupted")
+		fs.Parse(args[1:])
+		return runLeagueGames(path, &l, *size, *komi, *games)
+	}
+	return fmt.Errorf("unknown league command %q: use add, remove, run or standings", args[0])
+}
+
+// runLeagueGames plays games of the league, saving it after each, until
+// games are played or a signal stops it after the game in progress.
+func runLeagueGames(path string, l *League, size int, komi float64, games int) error {
+	if len(l.Entrants) < 2 {
+		return errors.New("the league needs two entrants; add them with league add")
+	}
+	players := map[string]gauntletPlayer{}
+	for _, e := range l.Entrants {
+		p, stop, err := e.player(komi)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		players[e.Name] = p
+	}
+	signals, stopSignals := notifyShutdown()
+	defer stopSignals()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for played := 0; games == 0 || played < games; played++ {
+		select {
+		case <-signals:
+			fmt.Println("Stopped.")
+			printLeaderboard(l)
+			return nil
+		default:
+		}
+		black, white := l.nextPairing(rng)
+		winner, err := playGauntletGame(context.Background(), players[black], players[white], size, komi)
+		if err != nil {
+			return err
+		}
+		g := LeagueGame{Black: black, White: white, Size: size, Komi: komi, Date: time.Now()}
+		result := "draw"
+		if winner != Empty {
+			g.Winner = colorKey(winner)
+			result = colorKey(winner) + " wins"
+		}
+		l.Games = append(l.Games, g)
+		if err := saveJSON(path, l); err != nil {
+			return err
+		}
+		fmt.Printf("Game %d: %s (B) vs %s (W), %s\n", len(l.Games), black, white, result)
+	}
+	printLeaderboard(l)
+	return nil
+}
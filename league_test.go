@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRateLeague(t *testing.T) {
+	entrants := []LeagueEntrant{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	var games []LeagueGame
+	for i := 0; i < 20; i++ {
+		// a beats b three times in four, b and c are even.
+		winner := "black"
+		if i%4 == 3 {
+			winner = "white"
+		}
+		games = append(games, LeagueGame{Black: "a", White: "b", Winner: winner})
+		games = append(games, LeagueGame{Black: "b", White: "c", Winner: []string{"black", "white"}[i%2]})
+	}
+	ratings := RateLeague(entrants, games)
+	if ratings[0].Name != "a" || ratings[0].Games != 20 || ratings[0].Score != 15 {
+		t.Fatalf("leader %+v", ratings[0])
+	}
+	b, c := ratings[1], ratings[2]
+	if b.Name == "c" {
+		b, c = c, b
+	}
+	if math.Abs(b.Elo-c.Elo) > 60 || ratings[1].LOS < 0.5 || !math.IsNaN(ratings[2].LOS) {
+		t.Errorf("b %+v, c %+v: want them close", b, c)
+	}
+	// The prior pulls a 3:1 score, about 190 Elo, towards even.
+	if gap := ratings[0].Elo - b.Elo; gap < 120 || gap > 200 {
+		t.Errorf("a is %.0f above b, want about 190 less the prior", gap)
+	}
+	if c.Margin <= b.Margin {
+		t.Errorf("c played half as many games as b but has the narrower margin: %.0f, %.0f", c.Margin, b.Margin)
+	}
+
+	// An entrant that always wins stays finite.
+	ratings = RateLeague(entrants[:2], games[:1])
+	if math.IsInf(ratings[0].Elo, 0) || ratings[0].Elo <= 0 || ratings[1].Elo >= 0 {
+		t.Errorf("one game: %+v", ratings)
+	}
+}
+
+func TestLeaguePairing(t *testing.T) {
+	l := &League{Entrants: []LeagueEntrant{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 12; i++ {
+		black, white := l.nextPairing(rng)
+		l.Games = append(l.Games, LeagueGame{Black: black, White: white})
+	}
+	count := map[[2]string]int{}
+	for _, g := range l.Games {
+		count[[2]string{g.Black, g.White}]++
+	}
+	for _, pair := range [][2]string{{"a", "b"}, {"b", "a"}, {"a", "c"}, {"c", "a"}, {"b", "c"}, {"c", "b"}} {
+		if count[pair] != 2 {
+			t.Errorf("%s had Black against %s %d times, want 2", pair[0], pair[1], count[pair])
+		}
+	}
+}
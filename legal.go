@@ -0,0 +1,231 @@
+package main
+
+// Legal move caching. Whether a point is legal for a colour depends only
+// on its neighbours and on whether the groups next to it have liberties
+// besides it, so a move can only change the answer at the point played,
+// the stones it captures and the liberties of the groups around them.
+// The board remembers answers per colour and forgets just those points
+// after each move, which keeps IsLegal O(1) for most points through a
+// playout. This file is part of the core (see board.go).
+
+const (
+	legalUnknown uint8 = iota
+	legalYes
+	legalNo
+)
+
+// legalCache holds, by colour and point, legalUnknown, legalYes or
+// legalNo. mark and stamp are scratch space for walking groups without
+// allocating: a point is marked in the current walk when its mark equals
+// stamp.
+type legalCache struct {
+	state [3][]uint8
+	mark  []uint32
+	stamp uint32
+	stack []int
+}
+
+func newLegalCache(size int) *legalCache {
+	c := &legalCache{mark: make([]uint32, size*size)}
+	c.state[Black] = make([]uint8, size*size)
+	c.state[White] = make([]uint8, size*size)
+	return c
+}
+
+func (c *legalCache) copy() *legalCache {
+	if c == nil {
+		return nil
+	}
+	d := &legalCache{mark: make([]uint32, len(c.mark))}
+	d.state[Black] = append([]uint8(nil), c.state[Black]...)
+	d.state[White] = append([]uint8(nil), c.state[White]...)
+	return d
+}
+
+// walk starts a new group walk.
+func (c *legalCache) walk() {
+	c.stamp++
+	if c.stamp == 0 {
+		clear(c.mark)
+		c.stamp = 1
+	}
+	c.stack = c.stack[:0]
+}
+
+func (c *legalCache) forget(p int) {
+	c.state[Black][p] = legalUnknown
+	c.state[White][p] = legalUnknown
+}
+
+// IsLegal reports whether the side to move may play move: a pass, or an
+// empty point where the stone either has a liberty, joins a group that
+// keeps one or captures.
+func (b *Board) IsLegal(move int) bool {
+	if move == PassMove {
+		return true
+	}
+	if move < 0 || move >= b.size*b.size {
+		return false
+	}
+	if b.legal == nil {
+		b.legal = newLegalCache(b.size)
+	}
+	s := &b.legal.state[b.turn][move]
+	if *s == legalUnknown {
+		*s = legalNo
+		if b.computeLegal(move, b.turn) {
+			*s = legalYes
+		}
+	}
+	return *s == legalYes
+}
+
+func (b *Board) computeLegal(p int, color Stone) bool {
+	if b.grid[p/b.size][p%b.size] != Empty {
+		return false
+	}
+	for _, q := range b.neighbours(p) {
+		switch b.grid[q/b.size][q%b.size] {
+		case Empty:
+			return true
+		case color:
+			if b.legal.liberties(b, q, 2, nil) > 1 {
+				return true
+			}
+		default:
+			if b.legal.liberties(b, q, 2, nil) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// liberties counts the liberties of the group at p, stopping at limit,
+// and calls each, if not nil, with each liberty counted.
+func (c *legalCache) liberties(b *Board, p int, limit int, each func(q int)) int {
+	c.walk()
+	c.mark[p] = c.stamp
+	c.stack = append(c.stack, p)
+	libs := 0
+	c.flood(b, b.grid[p/b.size][p%b.size], func(q int) bool {
+		if each != nil {
+			each(q)
+		}
+		libs++
+		return libs < limit
+	}, nil)
+	return libs
+}
+
+// stones lists the stones of the group at p.
+func (c *legalCache) stones(b *Board, p int) []int {
+	c.walk()
+	c.mark[p] = c.stamp
+	c.stack = append(c.stack, p)
+	stones := []int{p}
+	c.flood(b, b.grid[p/b.size][p%b.size], func(int) bool { return true }, func(q int) {
+		stones = append(stones, q)
+	})
+	return stones
+}
+
+// flood walks the groups of color from the points on the stack, calling
+// liberty for each liberty found until it returns false, and stone, if
+// not nil, for each further stone.
+func (c *legalCache) flood(b *Board, color Stone, liberty func(q int) bool, stone func(q int)) {
+	for len(c.stack) > 0 {
+		q := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		row, col := q/b.size, q%b.size
+		for i, n := range [4]int{q - b.size, q + b.size, q - 1, q + 1} {
+			if i == 0 && row == 0 || i == 1 && row == b.size-1 || i == 2 && col == 0 || i == 3 && col == b.size-1 {
+				continue
+			}
+			if c.mark[n] == c.stamp {
+				continue
+			}
+			switch b.grid[n/b.size][n%b.size] {
+			case Empty:
+				c.mark[n] = c.stamp
+				if !liberty(n) {
+					c.stack = c.stack[:0]
+					return
+				}
+			case color:
+				c.mark[n] = c.stamp
+				c.stack = append(c.stack, n)
+				if stone != nil {
+					stone(n)
+				}
+			}
+		}
+	}
+}
+
+// moved forgets the answers a stone played at p, capturing captured,
+// may have changed. Next to a group only whether it has one liberty or
+// more matters. The groups p joined had either more than p as liberty,
+// and keep more than one unless the new group is down to one, or p
+// alone, and then had no other liberty to affect. So besides p, its
+// neighbours and the captured points, the answers to forget are at the
+// last liberty of the new group and of opponent groups p left in atari,
+// and at the liberties of the groups the captures freed.
+func (c *legalCache) moved(b *Board, p int, captured []int) {
+	c.forget(p)
+	atari := func(g int) {
+		last := -1
+		if c.liberties(b, g, 2, func(q int) { last = q }) == 1 {
+			c.forget(last)
+		}
+	}
+	atari(p)
+	color := b.grid[p/b.size][p%b.size]
+	for _, n := range b.neighbours(p) {
+		switch b.grid[n/b.size][n%b.size] {
+		case Empty:
+			c.forget(n)
+		case color:
+		default:
+			// Skip a group the last walk has just covered.
+			if c.mark[n] != c.stamp {
+				atari(n)
+			}
+		}
+	}
+
+	if len(captured) == 0 {
+		return
+	}
+	forget := func(q int) bool {
+		c.forget(q)
+		return true
+	}
+	c.walk()
+	for _, q := range captured {
+		c.forget(q)
+		for _, n := range b.neighbours(q) {
+			if b.grid[n/b.size][n%b.size] != Empty && c.mark[n] != c.stamp {
+				c.mark[n] = c.stamp
+				c.stack = append(c.stack, n)
+				c.flood(b, b.grid[n/b.size][n%b.size], forget, nil)
+			}
+		}
+	}
+}
+
+// hasLiberty reports whether the group at row, col has a liberty, with
+// the cache's scratch space when there is a cache.
+func (b *Board) hasLiberty(row, col int) bool {
+	if b.legal != nil {
+		return b.legal.liberties(b, row*b.size+col, 1, nil) > 0
+	}
+	return b.hasLiberties(row, col, make(map[[2]int]bool))
+}
+
+// setPoint puts s on a point directly, outside the rules, for setting up
+// positions. The cached answers are all forgotten.
+func (b *Board) setPoint(p int, s Stone) {
+	b.grid[p/b.size][p%b.size] = s
+	b.legal = nil
+}
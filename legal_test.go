@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestLegalCache plays random games and checks the cached answers for
+// both colours against playing each point on an uncached copy.
+func TestLegalCache(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for game := 0; game < 100; game++ {
+		b := NewBoard(7)
+		for move := 0; move < 150 && !b.IsGameOver(); move++ {
+			for _, turn := range []Stone{b.turn, b.turn.Opponent()} {
+				saved := b.turn
+				b.turn = turn
+				for p := 0; p < 49; p++ {
+					fresh := b.Copy()
+					fresh.legal = nil
+					want := fresh.grid[p/7][p%7] == Empty && fresh.PlaceStone(p/7, p%7)
+					if got := b.IsLegal(p); got != want {
+						t.Fatalf("game %d, move %d, %s at %s: cached %v, want %v", game, move, turn, formatMove(p, 7), got, want)
+					}
+				}
+				b.turn = saved
+			}
+			if moves := b.candidates(); len(moves) > 0 {
+				b.Play(moves[rng.Intn(len(moves))])
+			} else {
+				b.Pass()
+			}
+		}
+	}
+	if b := NewBoard(5); b.IsLegal(25) || b.IsLegal(-2) || !b.IsLegal(PassMove) {
+		t.Error("moves off the board are legal or a pass is not")
+	}
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lobby mode (serve -lobby) hosts any number of games. Connections start
+// in the lobby, where players give their name and rank, post challenges
+// with the settings they want and accept each other's. An accepted
+// challenge starts a game between the two on their connections, run as
+// serve runs its one game (see netgame.go), and they come back to the
+// lobby when it ends.
+//
+// Lobby commands are {"command": "..."} lines, as typed in connect:
+//
+//	name alice 3k          who you are; the rank is optional
+//	challenge size=9 komi=6.5 time=10m byoyomi=5x30s color=black
+//	accept 3               play challenge 3
+//	cancel 3               withdraw your challenge 3
+//	who                    list the players and open challenges
+//	games                  list the games in progress
+//
+// The lobby answers with lobby events, which carry a message and, when
+// they change or are asked for, the players and open challenges:
+//
+//	{"event": "lobby", "message": "alice challenges: ...", "players": [...], "challenges": [...]}
+
+// LobbyPlayer is a connection in the lobby as other players see it.
+type LobbyPlayer struct {
+	Name    string `json:"name"`
+	Rank    string `json:"rank,omitempty"`
+	Playing bool   `json:"playing,omitempty"`
+}
+
+// Challenge is an open offer of a game. Color is the challenger's, empty
+// for one drawn at random when the game starts.
+type Challenge struct {
+	ID      int     `json:"id"`
+	From    string  `json:"from"`
+	Rank    string  `json:"rank,omitempty"`
+	Size    int     `json:"size"`
+	Komi    float64 `json:"komi"`
+	Time    string  `json:"time,omitempty"`
+	Byoyomi string  `json:"byoyomi,omitempty"`
+	Color   string  `json:"color,omitempty"`
+}
+
+// parseChallenge reads the name=value settings of a challenge command.
+func parseChallenge(args []string) (Challenge, error) {
+	c := Challenge{Size: 19, Komi: 6.5}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return c, fmt.Errorf("%q: use name=value", arg)
+		}
+		var err error
+		switch name {
+		case "size":
+			c.Size, err = strconv.Atoi(value)
+			if err == nil && (c.Size < 2 || c.Size > MaxBoardSize) {
+				err = fmt.Errorf("must be between 2 and %d", MaxBoardSize)
+			}
+		case "komi":
+			c.Komi, err = strconv.ParseFloat(value, 64)
+		case "time":
+			_, err = time.ParseDuration(value)
+			c.Time = value
+		case "byoyomi":
+			_, _, err = parseByoyomi(value)
+			c.Byoyomi = value
+		case "color":
+			switch value {
+			case "black", "white":
+				c.Color = value
+			case "any":
+			default:
+				err = errors.New("use black, white or any")
+			}
+		default:
+			return c, fmt.Errorf("unknown setting %q", name)
+		}
+		if err != nil {
+			return c, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return c, nil
+}
+
+func (c Challenge) String() string {
+	from := c.From
+	if c.Rank != "" {
+		from += " (" + c.Rank + ")"
+	}
+	clock := "no clock"
+	switch {
+	case c.Time != "" && c.Byoyomi != "":
+		clock = c.Time + " + " + c.Byoyomi
+	case c.Time != "":
+		clock = c.Time
+	case c.Byoyomi != "":
+		clock = c.Byoyomi
+	}
+	color := "colours at random"
+	if c.Color != "" {
+		color = c.From + " takes " + c.Color
+	}
+	return fmt.Sprintf("#%d %s: %dx%d, komi %g, %s, %s", c.ID, from, c.Size, c.Size, c.Komi, clock, color)
+}
+
+// lobbyConn is a connection to the lobby. Lobby events and the events of
+// its games are written through it, a line at a time.
+type lobbyConn struct {
+	conn net.Conn
+	mu   sync.Mutex // guards writes to conn
+
+	// Guarded by the lobby's mu.
+	name, rank string
+	game       *io.PipeWriter // input to the game being played, or nil
+}
+
+func (c *lobbyConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.Write(p)
+}
+
+func (c *lobbyConn) send(e pipeEvent) { json.NewEncoder(c).Encode(e) }
+
+type lobbyChallenge struct {
+	Challenge
+	owner *lobbyConn
+}
+
+// lobby is the state of a lobby server.
+type lobby struct {
+	sync    time.Duration
+	limits  *netLimits
+	metrics *serverMetrics
+	hooks   *webhooks
+	audit   *auditor
+
+	mu         sync.Mutex
+	conns      map[*lobbyConn]bool
+	challenges []*lobbyChallenge // in the order posted
+	games      map[*netGame]bool
+	lastID     int
+	guests     int
+	running    sync.WaitGroup // games in progress
+}
+
+func newLobby(sync time.Duration, limits *netLimits, metrics *serverMetrics) *lobby {
+	return &lobby{sync: sync, limits: limits, metrics: metrics, conns: map[*lobbyConn]bool{}, games: map[*netGame]bool{}}
+}
+
+// event is a lobby event with message and, if lists is set, the players
+// and open challenges. l.mu must be held.
+func (l *lobby) event(message string, lists bool) pipeEvent {
+	e := pipeEvent{Event: "lobby", Message: message}
+	if !lists {
+		return e
+	}
+	e.Players = []LobbyPlayer{}
+	for c := range l.conns {
+		e.Players = append(e.Players, LobbyPlayer{Name: c.name, Rank: c.rank, Playing: c.game != nil})
+	}
+	sort.Slice(e.Players, func(i, j int) bool { return e.Players[i].Name < e.Players[j].Name })
+	e.Challenges = []Challenge{}
+	for _, ch := range l.challenges {
+		e.Challenges = append(e.Challenges, ch.Challenge)
+	}
+	return e
+}
+
+// announce tells everyone in the lobby, but not those playing, message
+// with the lists as they are now. l.mu must be held.
+func (l *lobby) announce(message string) {
+	e := l.event(message, true)
+	for c := range l.conns {
+		if c.game == nil {
+			c.send(e)
+		}
+	}
+}
+
+// withdraw removes c's challenges. l.mu must be held.
+func (l *lobby) withdraw(c *lobbyConn) {
+	open := l.challenges[:0]
+	for _, ch := range l.challenges {
+		if ch.owner != c {
+			open = append(open, ch)
+		}
+	}
+	l.challenges = open
+}
+
+// serve runs a connection in the lobby until it closes.
+func (l *lobby) serve(conn net.Conn) {
+	c := &lobbyConn{conn: conn}
+	l.mu.Lock()
+	l.guests++
+	c.name = fmt.Sprintf("guest%d", l.guests)
+	l.conns[c] = true
+	joined := l.event(c.name+" joined", true)
+	for other := range l.conns {
+		if other != c && other.game == nil {
+			other.send(joined)
+		}
+	}
+	c.send(l.event(fmt.Sprintf("Welcome, %s. Commands: name NAME [RANK], challenge [size=N] [komi=K] [time=T] [byoyomi=NxT] [color=C], accept ID, cancel ID, who, games", c.name), true))
+	l.mu.Unlock()
+
+	gate := l.limits.gate()
+	in := bufio.NewScanner(conn)
+	for in.Scan() {
+		if ok, warn := gate.pass(time.Now()); !ok {
+			if warn {
+				c.send(rateLimitedEvent)
+			}
+			continue
+		}
+		l.mu.Lock()
+		game := c.game
+		l.mu.Unlock()
+		if game != nil {
+			game.Write(append(in.Bytes(), '\n'))
+			continue
+		}
+		var msg netInput
+		if json.Unmarshal(in.Bytes(), &msg) != nil || msg.Command == "" {
+			c.send(pipeEvent{Event: "error", Code: errBadInput, Message: `send {"command": "..."} in the lobby`})
+			continue
+		}
+		start := time.Now()
+		args := strings.Fields(msg.Command)
+		l.mu.Lock()
+		if err := l.command(c, args); err != nil {
+			code := errBadCommand
+			if errors.Is(err, errLobbyFull) {
+				code = errServerFull
+			}
+			c.send(pipeEvent{Event: "error", Code: code, Message: err.Error()})
+		}
+		l.mu.Unlock()
+		l.metrics.handled(lobbyRequest(args), start)
+	}
+
+	l.mu.Lock()
+	if c.game != nil {
+		c.game.Close() // the game forfeits
+	}
+	delete(l.conns, c)
+	l.withdraw(c)
+	l.announce(c.name + " left")
+	l.mu.Unlock()
+	conn.Close()
+}
+
+// lobbyRequest names a command for metrics, with all unknown ones as
+// one, so that a client cannot make up any number of them.
+func lobbyRequest(args []string) string {
+	if len(args) > 0 {
+		switch args[0] {
+		case "name", "challenge", "cancel", "accept", "who", "games":
+			return args[0]
+		}
+	}
+	return "unknown"
+}
+
+// command runs a lobby command from c. l.mu must be held.
+func (l *lobby) command(c *lobbyConn, args []string) error {
+	if len(args) == 0 {
+		return errors.New("empty command")
+	}
+	id := 0
+	if args[0] == "accept" || args[0] == "cancel" {
+		var err error
+		if len(args) != 2 {
+			return fmt.Errorf("use: %s ID", args[0])
+		}
+		if id, err = strconv.Atoi(strings.TrimPrefix(args[1], "#")); err != nil {
+			return fmt.Errorf("bad challenge id %q", args[1])
+		}
+	}
+	switch args[0] {
+	case "name":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("use: name NAME [RANK]")
+		}
+		for other := range l.conns {
+			if other != c && other.name == args[1] {
+				return fmt.Errorf("%s is taken", args[1])
+			}
+		}
+		rank := ""
+		if len(args) == 3 {
+			r, err := ParseRank(args[2])
+			if err != nil {
+				return err
+			}
+			rank = r.String()
+		}
+		old := c.name
+		c.name, c.rank = args[1], rank
+		for _, ch := range l.challenges {
+			if ch.owner == c {
+				ch.From, ch.Rank = c.name, c.rank
+			}
+		}
+		l.announce(fmt.Sprintf("%s is now %s", old, c.name))
+	case "challenge":
+		ch, err := parseChallenge(args[1:])
+		if err != nil {
+			return err
+		}
+		l.lastID++
+		ch.ID, ch.From, ch.Rank = l.lastID, c.name, c.rank
+		l.challenges = append(l.challenges, &lobbyChallenge{ch, c})
+		l.announce("New challenge " + ch.String())
+	case "cancel":
+		for i, ch := range l.challenges {
+			if ch.ID == id && ch.owner == c {
+				l.challenges = append(l.challenges[:i], l.challenges[i+1:]...)
+				l.announce(fmt.Sprintf("%s withdrew challenge #%d", c.name, id))
+				return nil
+			}
+		}
+		return fmt.Errorf("you have no challenge #%d", id)
+	case "accept":
+		for _, ch := range l.challenges {
+			if ch.ID == id {
+				if ch.owner == c {
+					return errors.New("that is your own challenge")
+				}
+				return l.start(ch, c)
+			}
+		}
+		return fmt.Errorf("there is no challenge #%d", id)
+	case "who":
+		c.send(l.event("", true))
+	case "games":
+		var games []string
+		for g := range l.games {
+			games = append(games, fmt.Sprintf("%s (%dx%d)", g.title, g.start.Size, g.start.Size))
+		}
+		sort.Strings(games)
+		if len(games) == 0 {
+			c.send(l.event("No games in progress", false))
+		} else {
+			c.send(l.event("Games in progress: "+strings.Join(games, ", "), false))
+		}
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return nil
+}
+
+// start plays the game ch offers between its owner and taker. l.mu must
+// be held.
+func (l *lobby) start(ch *lobbyChallenge, taker *lobbyConn) error {
+	if err := l.limits.startGame(len(l.games)); err != nil {
+		return err
+	}
+	g := newNetGame(ch.Size, ch.Komi, l.sync)
+	g.metrics, g.hooks = l.metrics, l.hooks
+	if ch.Time != "" || ch.Byoyomi != "" {
+		mainTime, _ := time.ParseDuration(ch.Time)
+		var periods int
+		var period time.Duration
+		if ch.Byoyomi != "" {
+			periods, period, _ = parseByoyomi(ch.Byoyomi)
+		}
+		g.clock = NewClock(mainTime, periods, period)
+	}
+	var seats [3]*lobbyConn
+	switch {
+	case ch.Color == "black", ch.Color == "" && rand.Intn(2) == 0:
+		seats[Black], seats[White] = ch.owner, taker
+	default:
+		seats[Black], seats[White] = taker, ch.owner
+	}
+	l.withdraw(ch.owner)
+	l.withdraw(taker)
+
+	var inputs []*io.PipeReader
+	for _, color := range []Stone{Black, White} {
+		r, w := io.Pipe()
+		seats[color].game = w
+		inputs = append(inputs, r)
+		g.players[color] = newNetPlayer(color, struct {
+			io.Reader
+			io.Writer
+		}{r, seats[color]})
+	}
+	g.names[Black], g.names[White] = seats[Black].name, seats[White].name
+	g.title = fmt.Sprintf("%s vs %s", seats[Black].name, seats[White].name)
+	g.audit = l.audit.open(g.title)
+	l.games[g] = true
+	l.running.Add(1)
+	l.announce(fmt.Sprintf("%s (black) plays %s (white), challenge #%d", seats[Black].name, seats[White].name, ch.ID))
+	go func() {
+		defer l.running.Done()
+		result := g.run()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.games, g)
+		for i, color := range []Stone{Black, White} {
+			inputs[i].Close()
+			seats[color].game = nil
+		}
+		l.announce(fmt.Sprintf("%s (black) against %s (white): %s", seats[Black].name, seats[White].name, result))
+	}()
+	return nil
+}
+
+// shutdown adjourns every game in progress and waits for them to stop.
+func (l *lobby) shutdown() {
+	l.mu.Lock()
+	for g := range l.games {
+		close(g.adjourn)
+	}
+	l.announce("The server is shutting down")
+	l.mu.Unlock()
+	l.running.Wait()
+}
+
+// serveLobby runs l on ln until Ctrl-C or SIGTERM, which adjourn the
+// games in progress.
+func serveLobby(ln net.Listener, l *lobby, access netAccess) error {
+	fmt.Printf("Lobby open on %s\n", ln.Addr())
+	interrupts, stopInterrupts := notifyShutdown()
+	defer stopInterrupts()
+	stopped := make(chan struct{})
+	go func() {
+		<-interrupts
+		close(stopped)
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stopped:
+				l.shutdown()
+				l.hooks.wait()
+				fmt.Println("Limits:", l.limits.Stats())
+				return nil
+			default:
+				return err
+			}
+		}
+		go func() {
+			limited, err := l.limits.accept(conn, time.Now())
+			if err != nil {
+				refuse(conn, errRateLimited, err)
+				return
+			}
+			conn := limited
+			if access.password != "" {
+				if err := access.checkPassword(readHello(conn)); err != nil {
+					refuse(conn, errUnauthorized, err)
+					return
+				}
+			}
+			fmt.Printf("lobby: %s\n", conn.RemoteAddr())
+			l.serve(conn)
+		}()
+	}
+}
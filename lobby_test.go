@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseChallenge(t *testing.T) {
+	c, err := parseChallenge([]string{"size=9", "komi=0.5", "time=5m", "byoyomi=3x20s", "color=white"})
+	if err != nil || c.Size != 9 || c.Komi != 0.5 || c.Time != "5m" || c.Byoyomi != "3x20s" || c.Color != "white" {
+		t.Errorf("parseChallenge = %+v, %v", c, err)
+	}
+	for _, bad := range []string{"size=1", "time=soon", "color=red", "handicap=2", "9"} {
+		if _, err := parseChallenge([]string{bad}); err == nil {
+			t.Errorf("parseChallenge accepted %q", bad)
+		}
+	}
+}
+
+func TestLobby(t *testing.T) {
+	l := newLobby(time.Second, nil, nil)
+	join := func() *netTestClient {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		go l.serve(server)
+		c := newNetTestClient(client)
+		c.next(t, "lobby")
+		return c
+	}
+	command := func(c *netTestClient, line string) {
+		go json.NewEncoder(c.conn).Encode(netInput{Command: line})
+	}
+
+	alice := join()
+	bob := join()
+	if e := alice.next(t, "lobby"); e.Message != "guest2 joined" || len(e.Players) != 2 {
+		t.Fatalf("join = %+v", e)
+	}
+	command(alice, "name alice 3k")
+	if e := bob.next(t, "lobby"); e.Message != "guest1 is now alice" {
+		t.Fatalf("rename = %+v", e)
+	}
+	command(alice, "challenge size=5 komi=0.5 color=white")
+	if e := bob.next(t, "lobby"); len(e.Challenges) != 1 || e.Challenges[0].From != "alice" || e.Challenges[0].Rank != "3k" {
+		t.Fatalf("challenge = %+v", e)
+	}
+	command(alice, "accept 1")
+	if e := alice.next(t, "error"); e.Code != errBadCommand {
+		t.Errorf("accepting one's own challenge: %+v", e)
+	}
+
+	command(bob, "accept 1")
+	if e := alice.next(t, "start"); e.You != "white" || e.Size != 5 {
+		t.Fatalf("alice's start = %+v", e)
+	}
+	bob.next(t, "start")
+	bob.next(t, "turn")
+	go json.NewEncoder(bob.conn).Encode(netInput{Move: "2 2", Seq: 1})
+	if e := alice.next(t, "move"); e.Move != "2 2" {
+		t.Fatalf("move = %+v", e)
+	}
+
+	// Leaving forfeits, and the other player is back in the lobby.
+	bob.conn.Close()
+	if e := alice.next(t, "end"); e.Result != "W+F" {
+		t.Errorf("end = %+v", e)
+	}
+	if e := alice.next(t, "lobby"); len(e.Players) != 1 {
+		t.Errorf("back in the lobby: %+v", e)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["local"] = runLocal
+}
+
+// LocalRegion is a rectangle of a board cut out as a board of its own, for
+// searching one fight without the rest of the game. Sides of the
+// rectangle on the edge of the original board stay edges; the others are
+// framed by Margin lines of filler outside the region. Moves there are not
+// part of the problem: Moves leaves them out and GenMove treats them as
+// playing elsewhere.
+type LocalRegion struct {
+	Board  *Board
+	Rect   Rect // on the original board
+	global int  // size of the original board
+	top    int  // local row of Rect.Top
+	left   int  // local column of Rect.Left
+}
+
+// LocalOptions are the boundary conditions of a LocalRegion.
+type LocalOptions struct {
+	// Wall is the colour of the filler. The wall is solid next to the
+	// region, with single-point eyes further out so it cannot be
+	// captured: the region is surrounded by Wall's strong position.
+	// Empty leaves the filler open, giving the groups at the border
+	// liberties outside the region.
+	Wall Stone
+	// Margin is the number of filler lines beyond each side that is not
+	// an edge of the board, at least 2 with a wall.
+	Margin int
+}
+
+// Extract cuts r, clipped to the board, out of b with the side to move of
+// b. The local board is square; when r is not, the extra lines are filler
+// on a side that is not an edge.
+func Extract(b *Board, r Rect, opts LocalOptions) (*LocalRegion, error) {
+	r = Rect{Top: max(r.Top, 0), Left: max(r.Left, 0), Bottom: min(r.Bottom, b.size-1), Right: min(r.Right, b.size-1)}
+	if r.Top > r.Bottom || r.Left > r.Right {
+		return nil, errors.New("local: empty region")
+	}
+	margin := opts.Margin
+	if opts.Wall != Empty {
+		margin = max(margin, 2)
+	}
+	// before and after are the filler lines above and below (or left and
+	// right of) the region, zero on the edges of the board.
+	lay := func(from, to int) (before, after int) {
+		if from > 0 {
+			before = margin
+		}
+		if to < b.size-1 {
+			after = margin
+		}
+		return before, after
+	}
+	up, down := lay(r.Top, r.Bottom)
+	leftM, rightM := lay(r.Left, r.Right)
+	height, width := r.Bottom-r.Top+1+up+down, r.Right-r.Left+1+leftM+rightM
+	n := max(height, width)
+	grow := func(before, after *int, length int) error {
+		extra := n - length
+		switch {
+		case extra == 0:
+		case *after > 0:
+			*after += extra
+		case *before > 0:
+			*before += extra
+		default:
+			return errors.New("local: the region spans the board one way and not the other; extract a square or the whole board")
+		}
+		return nil
+	}
+	if err := grow(&up, &down, height); err != nil {
+		return nil, err
+	}
+	if err := grow(&leftM, &rightM, width); err != nil {
+		return nil, err
+	}
+
+	l := &LocalRegion{Board: NewBoard(n), Rect: r, global: b.size, top: up, left: leftM}
+	l.Board.turn = b.turn
+	eyes := make([]bool, n*n)
+	for p := 0; p < n*n; p++ {
+		if g, ok := l.ToGlobal(p); ok {
+			l.Board.setPoint(p, b.grid[g/b.size][g%b.size])
+		} else if opts.Wall != Empty {
+			if l.eyeRoom(p, eyes) {
+				eyes[p] = true
+			} else {
+				l.Board.setPoint(p, opts.Wall)
+			}
+		}
+	}
+	// A group whose liberties were all outside keeps them.
+	if opts.Wall != Empty {
+		for _, stones := range l.Board.chains(opts.Wall.Opponent()) {
+			if _, libs := l.Board.group(stones[0]/n, stones[0]%n); libs > 0 {
+				continue
+			}
+			for _, q := range stones {
+				for _, lib := range l.Board.neighbours(q) {
+					if !l.Contains(lib) {
+						l.Board.setPoint(lib, Empty)
+					}
+				}
+			}
+		}
+	}
+	return l, nil
+}
+
+// eyeRoom reports whether the filler point p can be left empty as an eye
+// of the wall: it does not touch the region, and no other eye is next to
+// it or diagonally so, which keeps the wall connected.
+func (l *LocalRegion) eyeRoom(p int, eyes []bool) bool {
+	n := l.Board.size
+	for _, q := range l.Board.neighbours(p) {
+		if l.Contains(q) {
+			return false
+		}
+	}
+	for row := max(p/n-1, 0); row <= min(p/n+1, n-1); row++ {
+		for col := max(p%n-1, 0); col <= min(p%n+1, n-1); col++ {
+			if eyes[row*n+col] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Contains reports whether the local point p is part of the region.
+func (l *LocalRegion) Contains(p int) bool {
+	_, ok := l.ToGlobal(p)
+	return ok && p != PassMove
+}
+
+// ToGlobal maps a local point to the original board; ok is false for
+// filler. A pass stays a pass.
+func (l *LocalRegion) ToGlobal(p int) (global int, ok bool) {
+	if p == PassMove {
+		return PassMove, true
+	}
+	n := l.Board.size
+	row, col := p/n-l.top+l.Rect.Top, p%n-l.left+l.Rect.Left
+	if p < 0 || p >= n*n || row < l.Rect.Top || row > l.Rect.Bottom || col < l.Rect.Left || col > l.Rect.Right {
+		return 0, false
+	}
+	return row*l.global + col, true
+}
+
+// ToLocal maps a point of the original board into the local board; ok is
+// false outside the region.
+func (l *LocalRegion) ToLocal(global int) (p int, ok bool) {
+	if global == PassMove {
+		return PassMove, true
+	}
+	row, col := global/l.global, global%l.global
+	if global < 0 || row < l.Rect.Top || row > l.Rect.Bottom || col < l.Rect.Left || col > l.Rect.Right {
+		return 0, false
+	}
+	return (row-l.Rect.Top+l.top)*l.Board.size + col - l.Rect.Left + l.left, true
+}
+
+// Moves lists the legal moves of the side to move inside the region, as
+// local points.
+func (l *LocalRegion) Moves() []int {
+	var moves []int
+	for p := 0; p < l.Board.size*l.Board.size; p++ {
+		if l.Contains(p) && l.Board.IsLegal(p) {
+			moves = append(moves, p)
+		}
+	}
+	return moves
+}
+
+// GenMove searches the local board with e and returns the chosen move on
+// the original board. A pass, or a move in the filler, is returned as
+// PassMove: nothing in the region needs playing.
+func (l *LocalRegion) GenMove(ctx context.Context, e *Engine) (global int, err error) {
+	row, col, pass, err := e.GenMove(ctx, l.Board)
+	if pass {
+		return PassMove, err
+	}
+	global, ok := l.ToGlobal(row*l.Board.size + col)
+	if !ok {
+		return PassMove, err
+	}
+	return global, err
+}
+
+// parseRect parses "top,left,bottom,right".
+func parseRect(s string) (Rect, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Rect{}, fmt.Errorf("invalid region %q, use top,left,bottom,right", s)
+	}
+	var v [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return Rect{}, fmt.Errorf("invalid region %q, use top,left,bottom,right", s)
+		}
+		v[i] = n
+	}
+	return Rect{Top: v[0], Left: v[1], Bottom: v[2], Right: v[3]}, nil
+}
+
+func runLocal(args []string) error {
+	fs := flag.NewFlagSet("local", flag.ExitOnError)
+	region := fs.String("region", "", "top,left,bottom,right of the region, in rows and columns")
+	move := fs.Int("move", -1, "position after this many moves (default the end of the game)")
+	wall := fs.String("wall", "", "black or white to frame the region with that colour's wall (default open)")
+	margin := fs.Int("margin", 2, "filler lines beyond each side that is not an edge")
+	playouts := fs.Int("playouts", 2000, "playouts for the local search")
+	game := fs.Int("game", 1, "game number within the file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: local file.sgf -region top,left,bottom,right [-wall black|white] [-move N] [-playouts N]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	r, err := parseRect(*region)
+	if err != nil {
+		return err
+	}
+	opts := LocalOptions{Margin: *margin}
+	switch *wall {
+	case "":
+	case "black", "b":
+		opts.Wall = Black
+	case "white", "w":
+		opts.Wall = White
+	default:
+		return fmt.Errorf("invalid -wall %q", *wall)
+	}
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	n := len(g.Moves)
+	if *move >= 0 {
+		n = min(*move, n)
+	}
+	b, err := g.Replay(n)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	l, err := Extract(b, r, opts)
+	if err != nil {
+		return err
+	}
+	l.Board.Display()
+	best, err := l.GenMove(context.Background(), NewEngine(*playouts, g.Komi))
+	if err != nil {
+		return err
+	}
+	if best == PassMove {
+		fmt.Printf("%s has nothing to play in the region\n", colorName(b.turn))
+	} else {
+		fmt.Printf("%s's local move: %s\n", colorName(b.turn), userCoords.Move(best, b.size))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	// A black group in the top left corner of a 9x9 board, White around.
+	b := NewBoard(9)
+	for _, p := range []int{2, 11, 19, 18} {
+		b.grid[p/9][p%9] = Black
+	}
+	for _, p := range []int{3, 12, 21, 20, 28, 27} {
+		b.grid[p/9][p%9] = White
+	}
+	b.turn = White
+	l, err := Extract(b, Rect{Top: 0, Left: 0, Bottom: 3, Right: 4}, LocalOptions{Wall: White})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Five columns and four rows, with two filler lines below and right.
+	if n := l.Board.size; n != 7 || l.Board.turn != White {
+		t.Fatalf("local board %dx%d, %s to move", n, n, l.Board.turn)
+	}
+	for g := 0; g < 81; g++ {
+		p, ok := l.ToLocal(g)
+		if want := g/9 <= 3 && g%9 <= 4; ok != want {
+			t.Fatalf("point %s in region: %v", formatMove(g, 9), ok)
+		}
+		if !ok {
+			continue
+		}
+		if back, _ := l.ToGlobal(p); back != g || l.Board.grid[p/7][p%7] != b.grid[g/9][g%9] {
+			t.Fatalf("point %s maps to %d and back to %d", formatMove(g, 9), p, back)
+		}
+	}
+	if _, ok := l.ToGlobal(6); ok {
+		t.Error("filler maps to the board")
+	}
+	alive, _ := l.Board.passAlive(White)
+	if !alive[5*7+5] {
+		t.Errorf("wall is not pass-alive")
+		l.Board.Display()
+	}
+	for _, p := range l.Moves() {
+		if !l.Contains(p) {
+			t.Errorf("move %d outside the region", p)
+		}
+	}
+
+	move, err := l.GenMove(context.Background(), NewEngine(200, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move != PassMove && (move/9 > 3 || move%9 > 4) {
+		t.Errorf("local move %s outside the region", formatMove(move, 9))
+	}
+
+	if _, err := Extract(b, Rect{Top: 0, Left: 2, Bottom: 8, Right: 4}, LocalOptions{}); err == nil {
+		t.Error("region from edge to edge one way only accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serve -metrics ADDR serves the server's metrics at http://ADDR/metrics
+// in the Prometheus text format, for a hosted server to be monitored:
+//
+//	gogame_games_active                 games in progress
+//	gogame_games_total                  games started
+//	gogame_moves_total                  moves played, rate() for moves a second
+//	gogame_request_seconds{request=...} time to handle moves and lobby commands
+//	gogame_connections_*, gogame_lines_dropped_total, gogame_games_refused_total
+//	                                    the work of the limits (see netlimits.go)
+//	gogame_engine_playouts_total        playouts searched by engines in the process
+//	go_goroutines, go_memstats_*, go_gc_*
+//
+// It is a separate address so that it can be kept off the public one.
+
+// serverMetrics are a server's counters. A nil *serverMetrics counts
+// nothing.
+type serverMetrics struct {
+	gamesActive atomic.Int64
+	gamesTotal  atomic.Int64
+	moves       atomic.Int64
+
+	mu       sync.Mutex
+	requests map[string]*requestTimes
+}
+
+type requestTimes struct {
+	count int64
+	sum   time.Duration
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{requests: map[string]*requestTimes{}}
+}
+
+func (m *serverMetrics) gameStarted() {
+	if m != nil {
+		m.gamesActive.Add(1)
+		m.gamesTotal.Add(1)
+	}
+}
+
+func (m *serverMetrics) gameEnded() {
+	if m != nil {
+		m.gamesActive.Add(-1)
+	}
+}
+
+// handled records a request that took from start until now; request is
+// "move" or a lobby command.
+func (m *serverMetrics) handled(request string, start time.Time) {
+	if m == nil {
+		return
+	}
+	d := time.Since(start)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.requests[request]
+	if t == nil {
+		t = &requestTimes{}
+		m.requests[request] = t
+	}
+	t.count++
+	t.sum += d
+}
+
+// played records a move and the time it took to handle.
+func (m *serverMetrics) played(start time.Time) {
+	if m != nil {
+		m.moves.Add(1)
+		m.handled("move", start)
+	}
+}
+
+// write writes the metrics, with the counts of limits, in the Prometheus
+// text format.
+func (m *serverMetrics) write(w io.Writer, limits *netLimits) {
+	metric := func(name, kind, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+	metric("gogame_games_active", "gauge", "Games in progress.", float64(m.gamesActive.Load()))
+	metric("gogame_games_total", "counter", "Games started.", float64(m.gamesTotal.Load()))
+	metric("gogame_moves_total", "counter", "Moves played.", float64(m.moves.Load()))
+
+	m.mu.Lock()
+	names := make([]string, 0, len(m.requests))
+	for name := range m.requests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "# HELP gogame_request_seconds Time to handle moves and lobby commands.\n# TYPE gogame_request_seconds summary\n")
+	for _, name := range names {
+		t := m.requests[name]
+		fmt.Fprintf(w, "gogame_request_seconds_sum{request=%q} %g\n", name, t.sum.Seconds())
+		fmt.Fprintf(w, "gogame_request_seconds_count{request=%q} %d\n", name, t.count)
+	}
+	m.mu.Unlock()
+
+	s := limits.Stats()
+	metric("gogame_connections_open", "gauge", "Connections open.", float64(s.Open))
+	metric("gogame_connections_accepted_total", "counter", "Connections let in.", float64(s.Accepted))
+	metric("gogame_connections_refused_total", "counter", "Connections turned away by the limits.", float64(s.Refused))
+	metric("gogame_lines_dropped_total", "counter", "Lines dropped by -input-rate.", float64(s.Throttled))
+	metric("gogame_games_refused_total", "counter", "Games not started because of -max-games.", float64(s.GamesRefused))
+	metric("gogame_engine_playouts_total", "counter", "Playouts searched by engines.", float64(searchPlayouts.Load()))
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	metric("go_goroutines", "gauge", "Goroutines.", float64(runtime.NumGoroutine()))
+	metric("go_memstats_heap_alloc_bytes", "gauge", "Heap bytes in use.", float64(mem.HeapAlloc))
+	metric("go_memstats_sys_bytes", "gauge", "Bytes obtained from the system.", float64(mem.Sys))
+	metric("go_gc_cycles_total", "counter", "Garbage collections.", float64(mem.NumGC))
+	metric("go_gc_pause_seconds_total", "counter", "Time the garbage collector stopped the program.", float64(mem.PauseTotalNs)/1e9)
+}
+
+// serveMetrics serves m at http://addr/metrics until the returned
+// listener is closed.
+func serveMetrics(addr string, m *serverMetrics, limits *netLimits) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.write(w, limits)
+	})
+	go http.Serve(ln, mux)
+	return ln, nil
+}
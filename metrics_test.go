@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerMetrics(t *testing.T) {
+	m := newServerMetrics()
+	m.gameStarted()
+	m.played(time.Now())
+	m.handled("who", time.Now())
+	limits := newNetLimits(0, 0, 0, 0)
+	limits.startGame(0)
+
+	var out strings.Builder
+	m.write(&out, limits)
+	for _, want := range []string{
+		"# TYPE gogame_games_active gauge\ngogame_games_active 1\n",
+		"gogame_moves_total 1\n",
+		`gogame_request_seconds_count{request="move"} 1`,
+		`gogame_request_seconds_count{request="who"} 1`,
+		"gogame_connections_open 0\n",
+		"# TYPE go_gc_cycles_total counter\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, out.String())
+		}
+	}
+	var none *serverMetrics
+	none.played(time.Now()) // counts nothing, without panicking
+}
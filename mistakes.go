@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["training-report"] = runTrainingReport
+}
+
+// The mistake taxonomy sorts the moves the engine marks bad or doubtful by
+// what went wrong, from the analysis of the position and who the engine
+// expects to own each point:
+//
+//   - life-and-death: stones that would have lived, or died, with the
+//     engine's move change hands after the one played;
+//   - endgame-slack: most of the board was settled already;
+//   - overplay: the move went into the opponent's area;
+//   - direction-of-play: the board was still open and the move was far
+//     from where the engine wanted to play;
+//   - other: none of these.
+//
+// The heuristics are rough, the ownership coming from random playouts, but
+// over many games the counts show what a player gets wrong most, and the
+// training report turns them into the problem categories to practise.
+
+// MistakeType is what went wrong in a mistake.
+type MistakeType string
+
+const (
+	LifeAndDeathMistake MistakeType = "life-and-death"
+	EndgameMistake      MistakeType = "endgame-slack"
+	OverplayMistake     MistakeType = "overplay"
+	DirectionMistake    MistakeType = "direction-of-play"
+	OtherMistake        MistakeType = "other"
+)
+
+// Thresholds of the taxonomy.
+const (
+	// A life-and-death mistake changes the owner of this many stones,
+	// each by more than statusSwing.
+	statusStones = 3
+	statusSwing  = 1.0
+	// A point is settled when its owner is this sure, and the endgame
+	// is when this share of the points are.
+	settledOwnership = 0.8
+	endgameSettled   = 0.7
+	// An overplay is played where the opponent owned at least this much.
+	overplayOwnership = 0.5
+	// Direction matters while fewer than this share of the points have
+	// stones, and the move was at least a third of the board away from
+	// the engine's.
+	openingStones = 0.25
+)
+
+// practiceCategories are the problem categories that train against each
+// type of mistake.
+var practiceCategories = map[MistakeType]string{
+	LifeAndDeathMistake: "life-and-death",
+	EndgameMistake:      "endgame",
+	OverplayMistake:     "tesuji",
+	DirectionMistake:    "opening",
+}
+
+// ClassifyMistake works out what went wrong with move, played by the side
+// to move in b, from the analysis of b and the ownership, by move index
+// with Black positive, of b and of the positions after the engine's move
+// and after move.
+func ClassifyMistake(b *Board, move int, before MoveAnalysis, own, ownBest, ownPlayed []float64) MistakeType {
+	sign := ownerSign(b.turn)
+	points := b.size * b.size
+	swung, settled, stones := 0, 0, 0
+	for p := 0; p < points; p++ {
+		if b.grid[p/b.size][p%b.size] != Empty {
+			stones++
+			if ownBest[p]-ownPlayed[p] > statusSwing || ownPlayed[p]-ownBest[p] > statusSwing {
+				swung++
+			}
+		}
+		if own[p] >= settledOwnership || own[p] <= -settledOwnership {
+			settled++
+		}
+	}
+	switch {
+	case swung >= statusStones:
+		return LifeAndDeathMistake
+	case float64(settled) >= endgameSettled*float64(points):
+		return EndgameMistake
+	case move != PassMove && sign*own[move] <= -overplayOwnership:
+		return OverplayMistake
+	}
+	if move != PassMove && len(before.Candidates) > 0 && before.Candidates[0].Move != PassMove &&
+		float64(stones) < openingStones*float64(points) {
+		best := before.Candidates[0].Move
+		if max(abs(move/b.size-best/b.size), abs(move%b.size-best%b.size)) >= b.size/3 {
+			return DirectionMistake
+		}
+	}
+	return OtherMistake
+}
+
+// ClassifyMistakes sorts the bad and doubtful moves of g, annotated by
+// AnnotateGame with its analysis, by move number from 1, working out the
+// ownership they need with e.
+func ClassifyMistakes(ctx context.Context, e *Engine, g *GameRecord) (map[int]MistakeType, error) {
+	if len(g.Analysis) <= len(g.Moves) {
+		return nil, errors.New("the game has not been analysed")
+	}
+	types := map[int]MistakeType{}
+	b := g.Start()
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		if q, _ := m.Quality(); (q == BadMove || q == DoubtfulMove) && m.Move != PassMove {
+			before := g.Analysis[i]
+			own, err := e.Ownership(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			ownBest := own
+			if len(before.Candidates) > 0 {
+				best := b.Copy()
+				if best.Play(before.Candidates[0].Move) {
+					if ownBest, err = e.Ownership(ctx, best); err != nil {
+						return nil, err
+					}
+				}
+			}
+			played := b.Copy()
+			played.Play(m.Move)
+			ownPlayed, err := e.Ownership(ctx, played)
+			if err != nil {
+				return nil, err
+			}
+			types[i+1] = ClassifyMistake(b, m.Move, before, own, ownBest, ownPlayed)
+		}
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	return types, nil
+}
+
+// writeTrainingReport recommends what player should practise from the
+// types of their mistakes in reports, with the problems of puzzles in
+// each category when there is a library.
+func writeTrainingReport(w io.Writer, player string, reports []DirGameReport, puzzles []*Puzzle) {
+	byType := map[MistakeType]int{}
+	total := 0
+	for _, r := range reports {
+		for _, side := range []DirSideReport{r.Black, r.White} {
+			if player != "" && !strings.EqualFold(side.Player, player) {
+				continue
+			}
+			for t, n := range side.Types {
+				byType[t] += n
+				total += n
+			}
+		}
+	}
+	fmt.Fprintln(w, "Types of mistakes:")
+	if total == 0 {
+		fmt.Fprintln(w, "  none classified")
+		return
+	}
+	var types []MistakeType
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if byType[types[i]] != byType[types[j]] {
+			return byType[types[i]] > byType[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	for _, t := range types {
+		fmt.Fprintf(w, "  %-18s %5d  %3.0f%%\n", t, byType[t], 100*float64(byType[t])/float64(total))
+	}
+
+	byCategory := map[string][]*Puzzle{}
+	for _, p := range puzzles {
+		byCategory[p.Category] = append(byCategory[p.Category], p)
+	}
+	fmt.Fprintln(w, "Practise:")
+	for _, t := range types {
+		category, ok := practiceCategories[t]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "  %-15s for %d %s mistakes", category, byType[t], t)
+		switch problems := byCategory[category]; {
+		case len(puzzles) == 0:
+			fmt.Fprintln(w)
+		case len(problems) == 0:
+			fmt.Fprintln(w, ": the library has no such problems")
+		default:
+			var ids []string
+			for _, p := range problems[:min(len(problems), 5)] {
+				ids = append(ids, p.ID)
+			}
+			more := ""
+			if len(problems) > len(ids) {
+				more = ", ..."
+			}
+			fmt.Fprintf(w, ": %d problems, %s%s\n", len(problems), strings.Join(ids, ", "), more)
+		}
+	}
+}
+
+func runTrainingReport(args []string) error {
+	fs := flag.NewFlagSet("training-report", flag.ExitOnError)
+	player := fs.String("player", defaultPlayer(), "player to report on, as named in the games; empty for both sides")
+	problems := fs.String("problems", "", "the problem library, SGF files or directories separated by commas")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: training-report [-player NAME] [-problems DIR] ANALYSIS_DIR")
+	}
+	path := filepath.Join(fs.Arg(0), "progress.json")
+	var progress dirProgress
+	if err := loadJSON(path, &progress); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if len(progress.Files) == 0 {
+		return fmt.Errorf("%s: no games; run analyze-dir first", path)
+	}
+	var puzzles []*Puzzle
+	if *problems != "" {
+		var err error
+		if puzzles, err = LoadPuzzles(strings.Split(*problems, ",")); err != nil {
+			return err
+		}
+	}
+	writeMistakeSummary(os.Stdout, *player, &progress, puzzles)
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestClassifyMistake(t *testing.T) {
+	b := NewBoard(9)
+	for _, m := range []int{40, 20, 60} {
+		b.Play(m)
+	}
+	// White to move; the engine wants E3 (58).
+	before := MoveAnalysis{Candidates: []CandidateMove{{Move: 58, Visits: 100}}}
+	even := make([]float64, 81)
+	flat := func(v float64) []float64 {
+		own := make([]float64, 81)
+		for p := range own {
+			own[p] = v
+		}
+		return own
+	}
+	swung := append([]float64(nil), even...)
+	swung[40], swung[20], swung[60] = 1, 1, 1 // White's stone and Black's change hands
+	blackArea := append([]float64(nil), even...)
+	blackArea[30] = 0.9
+
+	tests := []struct {
+		name                  string
+		move                  int
+		own, ownBest, ownPlay []float64
+		want                  MistakeType
+	}{
+		{"stones change hands", 10, even, flat(-1), swung, LifeAndDeathMistake},
+		{"board settled", 10, flat(0.9), even, even, EndgameMistake},
+		{"into Black's area", 30, blackArea, even, even, OverplayMistake},
+		{"far from the engine's move", 2, even, even, even, DirectionMistake},
+		{"next to the engine's move", 57, even, even, even, OtherMistake},
+	}
+	for _, tt := range tests {
+		if got := ClassifyMistake(b, tt.move, before, tt.own, tt.ownBest, tt.ownPlay); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
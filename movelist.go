@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// listedMove is a move read from a move list before the board size is
+// known. GTP vertices count rows from the bottom, SGF points from the
+// top, so the row is only fixed once the size is.
+type listedMove struct {
+	color      Stone
+	pass       bool
+	col, row   int
+	fromBottom bool // row is a GTP row number, 1 at the bottom
+	sgfPass    bool // "tt", a pass on boards up to 19x19
+	setup      bool // a handicap stone
+}
+
+// ParseMoveList reads a game from a plain-text move list as people paste
+// it: "B D4, W Q16, ...", "1. dd 2. pd", "B[dd];W[pd]" or a GTP session
+// log with play and genmove commands and their replies. Colours are
+// optional and alternate from Black when left out, move numbers and
+// words it does not know are skipped, a result such as "W+R" is kept and
+// "resign" ends the game. GTP boardsize, komi and handicap commands are
+// taken into account; otherwise size, if 0, is the smallest of 9, 13 and
+// 19 that fits the moves.
+func ParseMoveList(text string, size int, komi float64) (*GameRecord, error) {
+	var tokens []string
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		tokens = append(tokens, strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+			return strings.ContainsRune(" \t\r,;:()[]{}", r)
+		})...)
+	}
+
+	g := &GameRecord{Komi: komi}
+	var moves []listedMove
+	color := Empty    // given for the next move
+	handicap := false // reading the stones of a GTP handicap command
+	extent := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		m := listedMove{}
+		switch {
+		case t == "b" || t == "black":
+			color = Black
+			continue
+		case t == "w" || t == "white":
+			color = White
+			continue
+		case t == "boardsize" || t == "komi":
+			v, err := strconv.ParseFloat(next, 64)
+			if err != nil {
+				continue
+			}
+			if t == "komi" {
+				g.Komi = v
+			} else if size == 0 {
+				size = int(v)
+			}
+			i++
+			continue
+		case t == "fixed_handicap" || t == "place_free_handicap" || t == "set_free_handicap":
+			handicap = true
+			continue
+		case t == "resign":
+			loser := color
+			if loser == Empty {
+				loser = Black
+				if len(moves) > 0 {
+					loser = moves[len(moves)-1].color.Opponent()
+				}
+			}
+			g.Result = resignResult(loser)
+			i = len(tokens)
+			continue
+		case len(t) > 2 && (t[0] == 'b' || t[0] == 'w') && t[1] == '+':
+			g.Result = strings.ToUpper(t) // as in SGF RE
+			continue
+		case t == "pass":
+			m.pass = true
+		case len(t) == 2 && t[0] >= 'a' && t[0] <= 'z' && t[1] >= 'a' && t[1] <= 'z':
+			m.col, m.row, m.sgfPass = int(t[0]-'a'), int(t[1]-'a'), t == "tt"
+			if !m.sgfPass {
+				extent = max(extent, m.col+1, m.row+1)
+			}
+		default:
+			col, row, ok := splitGTPVertex(t)
+			if !ok {
+				// A move number, a GTP reply marker, a command or chatter;
+				// words end the stones of a handicap command.
+				if _, err := strconv.Atoi(strings.TrimRight(t, ".")); err != nil && t[0] != '=' {
+					handicap = false
+				}
+				continue
+			}
+			m.col, m.row, m.fromBottom = col, row, true
+			extent = max(extent, col+1, row)
+		}
+		m.color, m.setup = color, handicap
+		if m.setup {
+			m.color = Black
+		} else if m.color == Empty {
+			m.color = Black
+			if len(moves) > 0 {
+				m.color = moves[len(moves)-1].color.Opponent()
+			}
+		}
+		moves = append(moves, m)
+		color = Empty
+	}
+	if len(moves) == 0 {
+		return nil, errors.New("no moves found")
+	}
+
+	if size == 0 {
+		size = extent
+		for _, standard := range []int{9, 13, 19} {
+			if extent <= standard {
+				size = standard
+				break
+			}
+		}
+	}
+	if size < 2 || size > MaxBoardSize || extent > size {
+		return nil, fmt.Errorf("the moves do not fit a %dx%d board", size, size)
+	}
+	g.Size = size
+	for _, m := range moves {
+		move := PassMove
+		switch {
+		case m.pass || m.sgfPass && size <= 19:
+		case m.fromBottom:
+			move = (size-m.row)*size + m.col
+		default:
+			move = m.row*size + m.col
+		}
+		if m.setup {
+			g.Setup = append(g.Setup, RecordedMove{Color: Black, Move: move})
+			g.Handicap++
+		} else {
+			g.Moves = append(g.Moves, RecordedMove{Color: m.color, Move: move})
+		}
+	}
+	if _, err := g.Replay(len(g.Moves)); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// splitGTPVertex splits a lowercase GTP vertex such as "q16" into its
+// column and its row number from the bottom.
+func splitGTPVertex(t string) (col, row int, ok bool) {
+	letters := 1
+	if len(t) > 2 && t[1] >= 'a' && t[1] <= 'z' {
+		letters = 2
+	}
+	col = -1
+	for i := 0; i < letters && i < len(t); i++ {
+		k := strings.IndexByte(gtpColumns, t[i]-'a'+'A')
+		if k < 0 {
+			return 0, 0, false
+		}
+		col = (col+1)*len(gtpColumns) + k
+	}
+	if len(t) <= letters || t[letters] < '0' || t[letters] > '9' {
+		return 0, 0, false
+	}
+	row, err := strconv.Atoi(t[letters:])
+	if err != nil || row < 1 {
+		return 0, 0, false
+	}
+	return col, row, true
+}
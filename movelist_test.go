@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestParseMoveList(t *testing.T) {
+	for _, c := range []struct {
+		text  string
+		size  int
+		moves []RecordedMove
+	}{
+		{"1. B D4, 2. W Q16\n3. B R4 4. W pass", 19, []RecordedMove{{Color: Black, Move: 15*19 + 3}, {Color: White, Move: 3*19 + 15}, {Color: Black, Move: 15*19 + 16}, {Color: White, Move: PassMove}}},
+		{"dd pd dp", 19, []RecordedMove{{Color: Black, Move: 3*19 + 3}, {Color: White, Move: 3*19 + 15}, {Color: Black, Move: 15*19 + 3}}},
+		{"B[cc];W[gg];B[tt]", 9, []RecordedMove{{Color: Black, Move: 2*9 + 2}, {Color: White, Move: 6*9 + 6}, {Color: Black, Move: PassMove}}},
+		{"W e5 W c3", 9, []RecordedMove{{Color: White, Move: 4*9 + 4}, {Color: White, Move: 6*9 + 2}}},
+	} {
+		g, err := ParseMoveList(c.text, 0, 6.5)
+		if err != nil {
+			t.Errorf("%q: %v", c.text, err)
+			continue
+		}
+		if g.Size != c.size || len(g.Moves) != len(c.moves) {
+			t.Errorf("%q: %dx%d with %d moves", c.text, g.Size, g.Size, len(g.Moves))
+			continue
+		}
+		for i, m := range c.moves {
+			if g.Moves[i].Color != m.Color || g.Moves[i].Move != m.Move {
+				t.Errorf("%q: move %d is %s %d, want %s %d", c.text, i+1, g.Moves[i].Color, g.Moves[i].Move, m.Color, m.Move)
+			}
+		}
+	}
+
+	log := "boardsize 9\nkomi 7\nfixed_handicap 2\n= C3 G7\n\ngenmove w\n= E5\n\nplay b C7\n=\n\ngenmove w\n= resign\n"
+	g, err := ParseMoveList(log, 0, 6.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Size != 9 || g.Komi != 7 || g.Handicap != 2 || len(g.Setup) != 2 || len(g.Moves) != 2 || g.Moves[0].Color != White || g.Result != "B+R" {
+		t.Errorf("GTP log: %+v", g)
+	}
+
+	for _, text := range []string{"hello there", "B D4 W D4", "A99"} {
+		if _, err := ParseMoveList(text, 0, 6.5); err == nil {
+			t.Errorf("%q accepted", text)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Move numbers on the stones, as in printed game records, to review a
+// game without stepping through it. -numbers N numbers the stones of the
+// last N moves that are still on the board, and -numbers all every one of
+// them. Numbers go modulo 100 as in kifu, so move 101 is 1 again, and they
+// fit on a stone; the terminal shows 100 as 00, and only the last digit
+// when the board is condensed to one column per point. During a game,
+// "numbers N", "numbers all" and "numbers off" at the move prompt change
+// them; replay toggles them with m, and gif -numbers draws them on the
+// frames.
+
+// allMoveNumbers numbers every move.
+const allMoveNumbers = -1
+
+// parseMoveNumbers reads a -numbers setting: a count of moves, "all", or
+// "off" or "" for none.
+func parseMoveNumbers(s string) (int, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "", "off", "0":
+		return 0, nil
+	case "all":
+		return allMoveNumbers, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("move numbers %q: want a number of moves, all or off", s)
+	}
+	return n, nil
+}
+
+// moveNumbers labels the stones on b that moves placed with their kifu
+// numbers, 1 to 100, for the last moves of them (all for allMoveNumbers,
+// none for 0). moves are the moves that led to b, from the first.
+func moveNumbers(b *Board, moves []RecordedMove, last int) map[int]int {
+	if last == 0 {
+		return nil
+	}
+	from := 0
+	if last > 0 {
+		from = max(len(moves)-last, 0)
+	}
+	labels := make(map[int]int)
+	for i := from; i < len(moves); i++ {
+		if m := moves[i].Move; m != PassMove {
+			labels[m] = i%100 + 1
+		}
+	}
+	// The last move on a point placed whatever stone is there now.
+	for p := range labels {
+		if b.grid[p/b.size][p%b.size] == Empty {
+			delete(labels, p)
+		}
+	}
+	return labels
+}
+
+// numberMarks are move numbers as marks for markedBoardLines.
+func numberMarks(labels map[int]int) map[int]string {
+	if labels == nil {
+		return nil
+	}
+	marks := make(map[int]string, len(labels))
+	for p, n := range labels {
+		marks[p] = strconv.Itoa(n)
+		if n == 100 {
+			marks[p] = "00"
+		}
+	}
+	return marks
+}
+
+// numbersCommand reads "numbers ..." typed at the move prompt into a
+// setting; ok is false for other input.
+func numbersCommand(input string) (last int, ok bool, err error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || fields[0] != "numbers" {
+		return 0, false, nil
+	}
+	if len(fields) == 1 {
+		return allMoveNumbers, true, nil
+	}
+	last, err = parseMoveNumbers(fields[1])
+	return last, true, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMoveNumbers(t *testing.T) {
+	b := NewBoard(9)
+	var moves []RecordedMove
+	play := func(m int) {
+		moves = append(moves, RecordedMove{Color: b.turn, Move: m})
+		if !b.Play(m) {
+			t.Fatalf("move %d is illegal", m)
+		}
+	}
+	// White's stone in the corner is taken and Black plays there later.
+	play(10)
+	play(0)
+	play(1)
+	play(PassMove)
+	play(9) // captures 0
+	play(80)
+	play(0)
+
+	labels := moveNumbers(b, moves, allMoveNumbers)
+	want := map[int]int{10: 1, 1: 3, 9: 5, 80: 6, 0: 7}
+	if len(labels) != len(want) {
+		t.Errorf("all: %v, want %v", labels, want)
+	}
+	for p, n := range want {
+		if labels[p] != n {
+			t.Errorf("all: point %d numbered %d, want %d", p, labels[p], n)
+		}
+	}
+	if labels := moveNumbers(b, moves, 2); len(labels) != 2 || labels[80] != 6 || labels[0] != 7 {
+		t.Errorf("last 2: %v", labels)
+	}
+	if labels := moveNumbers(b, moves, 0); labels != nil {
+		t.Errorf("off: %v", labels)
+	}
+
+	// Numbers go round after 100, and take both columns of a point.
+	long := make([]RecordedMove, 101)
+	for i := range long {
+		long[i] = RecordedMove{Move: PassMove}
+	}
+	long[99].Move, long[100].Move = 40, 41
+	b.grid[4][4], b.grid[4][5] = Black, White
+	marks := numberMarks(moveNumbers(b, long, 2))
+	if marks[40] != "00" || marks[41] != "1" {
+		t.Errorf("marks %v, want 00 and 1", marks)
+	}
+	lines := markedBoardLines(b, 80, 20, PassMove, marks)
+	if !strings.HasPrefix(lines[5], " 4 + + + +00 1") {
+		t.Errorf("row 4: %q", lines[5])
+	}
+
+	for _, s := range []string{"x", "-3"} {
+		if _, err := parseMoveNumbers(s); err == nil {
+			t.Errorf("-numbers %s accepted", s)
+		}
+	}
+}
@@ -0,0 +1,1311 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	commands["serve"] = runServe
+	commands["connect"] = runConnect
+}
+
+// Network mode: serve runs a game between two remote players over TCP,
+// speaking the pipe protocol (see pipe.go) with a few additions, and
+// connect is a terminal client for it.
+//
+// The server is the only authority on time. It runs the clock, sends
+// every player a clock event at each turn and every sync interval while a
+// move is pending, and decides timeouts itself without waiting for the
+// late player. Clock events carry server_ms, the server's clock in
+// milliseconds, for clients that want to estimate their offset.
+//
+//	{"event": "start", "size": 9, "to_move": "black", "you": "white"}
+//	{"event": "clock", "to_move": "black", "server_ms": 1718000000000, "black": {...}, "white": {...}}
+//	{"event": "ping", "ping": 7, "server_ms": 1718000000000}
+//
+// Clients answer pings at once with {"pong": 7}. Moves are as in pipe
+// mode, with the number of the move, as the turn event gave it:
+// {"move": "3 4", "seq": 12}. The round trip times measured this way compensate for
+// latency: a move is charged the time from the turn event to its arrival
+// less the mover's round trip time, capped at netMaxCompensation.
+//
+// Start and move events carry the hash of the position (see
+// GameLog.PositionHistory) as a decimal string; the empty board's is 0
+// and left out. A client whose own hash differs, or that missed events,
+// sends {"sync": "HASH"} with the last hash it agrees with and is sent
+// the move events after that position again; for a hash the game never
+// had, it is sent the start event and every move.
+//
+// Move events also carry what the move changed, for clients that draw
+// the board without knowing the rules, such as an app on a phone: the
+// points, as row*size+col, that turned black, white or empty, and the
+// stones each side has captured in the game. In a timed game they carry
+// both clocks as well, as they stood when the move arrived.
+//
+//	{"event": "move", "color": "white", "number": 12, "move": "2 3", "row": 2, "col": 3,
+//	 "hash": "...", "delta": {"w": [21], "e": [20, 30], "cap": [0, 2]}}
+//
+// A client that would rather not replay the moves it missed sends
+// {"board": true} and is sent the position instead: a board event, whose
+// delta is from the empty board, with the number of moves played, the
+// player to move and the clocks. It carries the position once more in
+// the compact binary format of codec.go, base64 encoded, which is a few
+// dozen bytes on 19x19 where the delta can be a few kilobytes; connect
+// reads that, and clients without a decoder read the delta.
+//
+//	{"event": "board", "size": 9, "number": 12, "to_move": "black", "delta": {"b": [...], "w": [...], "cap": [0, 2]},
+//	 "position": "R0MBUAkB..."}
+//
+// The number makes moves safe to send again on a flaky connection. The
+// server plays a move only when it is the next one; a client that does
+// not know whether its move arrived sends it again with the same number,
+// and if the first copy was played the server acknowledges the repeat by
+// sending it that move's event again. A move with no number, a different
+// move under the number of one already played, or a number past the next
+// move, is refused with a "bad_sequence" error. "adjourn" has no number.
+//
+// A player may ask to take back their last move with {"move": "undo"}:
+// the opponent's reply too, if they have answered it. Everyone is told,
+// with the number of moves that would be left, and the opponent answers
+// {"move": "accept"} or {"move": "decline"}; playing a move instead
+// declines. On acceptance the server takes the moves back and sends an
+// undo event, like a board event but with the delta from the position
+// before, and the clocks, which keep the time already used. The player
+// whose move it is then gets a turn event as usual.
+//
+//	{"event": "undo_request", "color": "black", "number": 10}
+//	{"event": "undo", "number": 10, "to_move": "black", "hash": "...", "delta": {"e": [21, 22]}}
+//	{"event": "undo_declined", "color": "white"}
+//
+// Errors carry a code as well as a message, so that clients can act on
+// them without parsing text:
+//
+//	{"event": "error", "code": "not_your_turn", "message": "not your turn"}
+//
+// A server started with -password refuses every connection, watchers'
+// too, whose first line is not {"password": "..."} with it; the password
+// can go in the same line as the hello. -tls-cert and -tls-key, or
+// -tls-self-signed, put the whole protocol inside TLS (see nettls.go).
+//
+// With -auth the server makes up a token for each colour, to be handed
+// to the players. A connection then starts with {"hello": "TOKEN"} and
+// plays the colour its token is for, whatever the order of arrival; one
+// that says nothing within netHelloTimeout watches, and one with a wrong
+// token, or a token whose player is already connected, is refused with an
+// "unauthorized" error.
+//
+// Connections after the two players watch the game: they get a start
+// event without "you", the moves played so far and then every event the
+// players get. Moves from watchers are refused.
+//
+// Either player sending {"move": "adjourn"}, or the server being stopped
+// with Ctrl-C or SIGTERM, adjourns the game: the clocks stop and everyone
+// gets an adjourn event with the time left instead of an end event.
+//
+//	{"event": "adjourn", "message": "Game adjourned after 12 moves. ..."}
+//
+// A game started with -black net:ADDR or -white net:ADDR (see sources.go)
+// speaks the same protocol to its remote player, without clocks or
+// sync, and sends the stones of a handicap or preset position after
+// start:
+//
+//	{"event": "setup", "color": "black", "move": "2 6", "row": 2, "col": 6}
+
+const (
+	netSyncInterval    = time.Second
+	netMaxCompensation = 2 * time.Second
+	netHelloTimeout    = 2 * time.Second
+)
+
+// Codes of network error events.
+const (
+	errBadInput     = "bad_input"     // a line that is not a message
+	errBadMove      = "bad_move"      // a move that cannot be read, or is illegal
+	errBadSequence  = "bad_sequence"  // a move whose number is not the next one's
+	errBadUndo      = "bad_undo"      // an undo request or answer out of place
+	errNotYourTurn  = "not_your_turn" // a player moved on the opponent's turn
+	errNotAPlayer   = "not_a_player"  // a watcher tried to move
+	errUnauthorized = "unauthorized"  // a wrong token, or a seat already taken
+	errBadCommand   = "bad_command"   // a lobby command that cannot be done
+	errRateLimited  = "rate_limited"  // a connection or line over the server's limits
+	errServerFull   = "server_full"   // a lobby game past -max-games
+)
+
+// netInput is one line from a network client.
+type netInput struct {
+	Move     string  `json:"move,omitempty"`
+	Seq      int     `json:"seq,omitempty"` // the move's number, from the turn event
+	Pong     int64   `json:"pong,omitempty"`
+	Sync     *uint64 `json:"sync,string,omitempty"`
+	Board    bool    `json:"board,omitempty"`    // asks for the position
+	Hello    string  `json:"hello,omitempty"`    // the player's token, with -auth
+	Password string  `json:"password,omitempty"` // the server's, with -password
+	Command  string  `json:"command,omitempty"`  // in the lobby, see lobby.go
+}
+
+// netMove is a move as a client sent it.
+type netMove struct {
+	Move string
+	Seq  int
+}
+
+// netSync is a client's request to catch up: the moves after the
+// position hashed Hash or, with Board, the position itself.
+type netSync struct {
+	Hash  uint64
+	Board bool
+}
+
+// netPlayer is one connected client.
+type netPlayer struct {
+	color Stone
+	enc   *json.Encoder
+	mu    sync.Mutex // guards enc and the ping state
+	moves chan netMove
+	syncs chan netSync
+	// commands are a broadcast presenter's, see broadcast.go; nil for
+	// everyone else, whose commands are dropped.
+	commands chan string
+	gone     chan struct{}
+	gate     *lineGate // nil for no limit on lines
+
+	pings map[int64]time.Time
+	rtt   time.Duration // smoothed round trip time
+}
+
+func newNetPlayer(color Stone, conn io.ReadWriter) *netPlayer {
+	return newLimitedNetPlayer(color, conn, nil)
+}
+
+// newLimitedNetPlayer is newNetPlayer with the lines it reads passed
+// through gate.
+func newLimitedNetPlayer(color Stone, conn io.ReadWriter, gate *lineGate) *netPlayer {
+	p := makeNetPlayer(color, conn, gate)
+	go p.read(conn)
+	return p
+}
+
+// makeNetPlayer is newLimitedNetPlayer without starting to read conn.
+func makeNetPlayer(color Stone, conn io.ReadWriter, gate *lineGate) *netPlayer {
+	return &netPlayer{
+		color: color,
+		enc:   json.NewEncoder(conn),
+		moves: make(chan netMove),
+		syncs: make(chan netSync),
+		gone:  make(chan struct{}),
+		gate:  gate,
+		pings: make(map[int64]time.Time),
+	}
+}
+
+func (p *netPlayer) send(e pipeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enc.Encode(e)
+}
+
+// read forwards moves to p.moves and handles pongs until the connection
+// closes.
+func (p *netPlayer) read(r io.Reader) {
+	defer close(p.gone)
+	in := bufio.NewScanner(r)
+	for in.Scan() {
+		if ok, warn := p.gate.pass(time.Now()); !ok {
+			if warn {
+				p.send(rateLimitedEvent)
+			}
+			continue
+		}
+		var msg netInput
+		dec := json.NewDecoder(strings.NewReader(in.Text()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&msg); err != nil {
+			p.send(pipeEvent{Event: "error", Code: errBadInput, Message: "bad input: " + err.Error()})
+			continue
+		}
+		if msg.Pong != 0 {
+			p.pong(msg.Pong, time.Now())
+			continue
+		}
+		if msg.Sync != nil || msg.Board {
+			s := netSync{Board: msg.Board}
+			if msg.Sync != nil {
+				s.Hash = *msg.Sync
+			}
+			select {
+			case p.syncs <- s:
+			case <-p.gone:
+				return
+			}
+			continue
+		}
+		if msg.Command != "" && p.commands != nil {
+			select {
+			case p.commands <- msg.Command:
+			case <-p.gone:
+				return
+			}
+			continue
+		}
+		if msg.Move != "" {
+			select {
+			case p.moves <- netMove{msg.Move, msg.Seq}:
+			case <-p.gone:
+				return
+			}
+		}
+	}
+}
+
+func (p *netPlayer) ping(id int64, now time.Time) {
+	p.mu.Lock()
+	p.pings[id] = now
+	p.mu.Unlock()
+	p.send(pipeEvent{Event: "ping", Ping: id, ServerMS: now.UnixMilli()})
+}
+
+func (p *netPlayer) pong(id int64, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sent, ok := p.pings[id]
+	if !ok {
+		return
+	}
+	delete(p.pings, id)
+	sample := now.Sub(sent)
+	if p.rtt == 0 {
+		p.rtt = sample
+	} else {
+		p.rtt = (7*p.rtt + sample) / 8
+	}
+}
+
+func (p *netPlayer) roundTrip() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rtt
+}
+
+// chargedUntil is the instant a move arriving at arrival is charged up
+// to: the turn event took about half the round trip to reach the player
+// and the move the other half, so rtt is given back, up to
+// netMaxCompensation and never before the turn started.
+func chargedUntil(start, arrival time.Time, rtt time.Duration) time.Time {
+	t := arrival.Add(-min(rtt, netMaxCompensation))
+	if t.Before(start) {
+		return start
+	}
+	return t
+}
+
+// netGame is a game on the server.
+type netGame struct {
+	game    *Game
+	komi    float64
+	clock   *Clock // nil for untimed games
+	players [3]*netPlayer
+	pingID  int64
+	sync    time.Duration
+	start   pipeEvent
+	adjourn chan struct{} // closed to adjourn the game
+	limits  *netLimits    // for the lines of its connections; nil in a lobby, which limits them itself
+	metrics *serverMetrics
+	hooks   *webhooks
+	title   string    // the game, in webhook events
+	names   [3]string // the players, in webhook events; colours if empty
+	audit   *auditLog // nil for none, see audit.go
+
+	mu       sync.Mutex // guards watchers and played
+	watchers []*netPlayer
+	played   []pipeEvent // move events so far, for late watchers
+}
+
+func newNetGame(size int, komi float64, sync time.Duration) *netGame {
+	g := &netGame{game: NewGame(NewBoard(size)), komi: komi, sync: sync, adjourn: make(chan struct{})}
+	g.start = pipeEvent{Event: "start", Size: size, ToMove: colorKey(g.game.Board().Turn()), Hash: g.game.Board().Hash()}
+	return g
+}
+
+func (g *netGame) broadcast(e pipeEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e.Event == "move" {
+		g.played = append(g.played, e)
+	}
+	for _, p := range g.players[Black:] {
+		p.send(e)
+	}
+	for _, w := range g.watchers {
+		w.send(e)
+	}
+}
+
+// watch adds a watcher on conn and catches it up with the game so far.
+func (g *netGame) watch(conn io.ReadWriter) {
+	w := newLimitedNetPlayer(Empty, conn, g.limits.gate())
+	go func() {
+		for {
+			select {
+			case <-w.moves:
+				w.send(pipeEvent{Event: "error", Code: errNotAPlayer, Message: "you are watching this game"})
+			case s := <-w.syncs:
+				g.resync(w, s)
+			case <-w.gone:
+				return
+			}
+		}
+	}()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	w.send(g.start)
+	for _, e := range g.played {
+		w.send(e)
+	}
+	g.watchers = append(g.watchers, w)
+}
+
+// netAccess is who may connect to a server: the players holding each
+// colour's token, if there are tokens, and only those with the password,
+// if there is one.
+type netAccess struct {
+	tokens   [3]string
+	password string
+}
+
+// readHello reads the first line of a connection, which has
+// netHelloTimeout to send it; the zero netInput if it sends nothing.
+func readHello(conn net.Conn) netInput {
+	conn.SetReadDeadline(time.Now().Add(netHelloTimeout))
+	line := readLine(conn)
+	conn.SetReadDeadline(time.Time{})
+	var msg netInput
+	json.Unmarshal([]byte(line), &msg)
+	return msg
+}
+
+// checkPassword reports whether hello has the server's password, if it
+// has one.
+func (a netAccess) checkPassword(hello netInput) error {
+	if a.password != "" && subtle.ConstantTimeCompare([]byte(hello.Password), []byte(a.password)) != 1 {
+		return errors.New("wrong password")
+	}
+	return nil
+}
+
+// refuse turns conn away with an error event.
+func refuse(conn net.Conn, code string, err error) {
+	fmt.Printf("refused: %s: %v\n", conn.RemoteAddr(), err)
+	json.NewEncoder(conn).Encode(pipeEvent{Event: "error", Code: code, Message: err.Error()})
+	conn.Close()
+}
+
+// admit decides who a new connection is. With tokens, its hello line
+// names its colour and without one it watches; otherwise the first two
+// connections play Black and White. It returns Empty for a watcher.
+func (g *netGame) admit(conn net.Conn, access netAccess) (Stone, error) {
+	inOrder := func() Stone {
+		for _, color := range []Stone{Black, White} {
+			if g.players[color] == nil {
+				return color
+			}
+		}
+		return Empty
+	}
+	if access.tokens[Black] == "" && access.password == "" {
+		return inOrder(), nil
+	}
+	msg := readHello(conn)
+	if err := access.checkPassword(msg); err != nil {
+		return Empty, err
+	}
+	switch {
+	case access.tokens[Black] == "":
+		return inOrder(), nil
+	case msg.Hello == "":
+		return Empty, nil
+	}
+	for _, color := range []Stone{Black, White} {
+		if subtle.ConstantTimeCompare([]byte(msg.Hello), []byte(access.tokens[color])) == 1 {
+			if g.players[color] != nil {
+				return Empty, fmt.Errorf("%s is already connected", colorKey(color))
+			}
+			return color, nil
+		}
+	}
+	return Empty, errors.New("unknown token")
+}
+
+// movesPlayed is the move events so far.
+func (g *netGame) movesPlayed() []pipeEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.played[:len(g.played):len(g.played)]
+}
+
+// sequenceMove checks the number of a move from p against the move events
+// played so far, on a board of size. It reports whether the move is the
+// next one, to be played; a repeat of p's move already played is
+// acknowledged with its event again, and any other number refused.
+func sequenceMove(p *netPlayer, m netMove, played []pipeEvent, size int) bool {
+	next := len(played) + 1
+	refuse := func(format string, args ...any) bool {
+		p.send(pipeEvent{Event: "error", Code: errBadSequence, Message: fmt.Sprintf(format, args...)})
+		return false
+	}
+	switch {
+	case m.Seq == next:
+		return true
+	case m.Seq <= 0:
+		return refuse("a move needs its number, seq: the next move is %d", next)
+	case m.Seq > next:
+		return refuse("move %d is not the next move, %d", m.Seq, next)
+	}
+	e := played[m.Seq-1]
+	if move, err := parseMoveInput(m.Move, size); err != nil || e.Color != colorKey(p.color) || e.Move != formatMove(move, size) {
+		return refuse("move %d has been played: %s %s", m.Seq, e.Color, e.Move)
+	}
+	p.send(e)
+	return false
+}
+
+// undoRequest is a player's request to take back moves, not yet
+// answered.
+type undoRequest struct {
+	by    Stone
+	moves int // moves and passes to take back
+}
+
+// negotiateUndo handles line from p if it is part of an undo negotiation,
+// with req the request pending, and reports whether it was and whether
+// moves were taken back.
+func (g *netGame) negotiateUndo(p *netPlayer, line string, req **undoRequest) (handled, undone bool) {
+	refuse := func(message string) (bool, bool) {
+		p.send(pipeEvent{Event: "error", Code: errBadUndo, Message: message})
+		return true, false
+	}
+	switch line = strings.TrimSpace(line); line {
+	case "undo":
+		if *req != nil {
+			return refuse("an undo has already been asked for")
+		}
+		// Take back p's last move, and the reply to it if there is one.
+		n, played := 1, g.game.Moves()
+		if g.game.Board().Turn() == p.color {
+			n = 2
+		}
+		if played < n {
+			return refuse("you have no move to take back")
+		}
+		*req = &undoRequest{by: p.color, moves: n}
+		g.broadcast(pipeEvent{Event: "undo_request", Color: colorKey(p.color), Number: played - n})
+		return true, false
+	case "accept", "decline":
+		if *req == nil || (*req).by == p.color {
+			return refuse("there is no undo to answer")
+		}
+		r := *req
+		*req = nil
+		if line == "decline" {
+			g.broadcast(pipeEvent{Event: "undo_declined", Color: colorKey(p.color)})
+			return true, false
+		}
+		g.takeBack(r.moves, time.Now())
+		return true, true
+	}
+	return false, false
+}
+
+// takeBack takes back n moves, charging the player to move for the time
+// they had used, and sends everyone the position.
+func (g *netGame) takeBack(n int, now time.Time) {
+	if g.clock != nil {
+		g.clock.Stop(now)
+	}
+	before := g.game.Board()
+	g.game.Undo(n)
+	g.mu.Lock()
+	g.played = g.played[:len(g.played)-n]
+	g.mu.Unlock()
+	after := g.game.Board()
+	e := pipeEvent{
+		Event: "undo", Number: g.game.Moves(), ToMove: colorKey(after.Turn()), Hash: after.Hash(),
+		Delta: boardDelta(before, after, [2]int{g.game.Captures(Black), g.game.Captures(White)}),
+	}
+	if g.clock != nil {
+		e.ServerMS = now.UnixMilli()
+		e.Black, e.White = newPipeClock(g.clock.State(Black, now)), newPipeClock(g.clock.State(White, now))
+	}
+	g.broadcast(e)
+	g.audit.record(AuditEntry{Kind: "undo", Number: e.Number, Hash: e.Hash})
+}
+
+// resync sends p the position, if it asked for that, or else the move
+// events after the position it gave the hash of, or the whole game if
+// there was no such position.
+func (g *netGame) resync(p *netPlayer, s netSync) {
+	if s.Board {
+		p.send(g.boardEvent(time.Now()))
+		return
+	}
+	hash := s.Hash
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	from := -1
+	for i := len(g.played) - 1; i >= 0 && from < 0; i-- {
+		if g.played[i].Hash == hash {
+			from = i + 1
+		}
+	}
+	if from < 0 && hash == g.start.Hash {
+		from = 0
+	}
+	if from < 0 {
+		e := g.start
+		e.You = colorKey(p.color)
+		p.send(e)
+		from = 0
+	}
+	for _, e := range g.played[from:] {
+		p.send(e)
+	}
+}
+
+// pipeDelta is how the board changed: the points, as row*size+col, that
+// turned black, white or empty, and the stones captured so far.
+type pipeDelta struct {
+	Black    []int  `json:"b,omitempty"`
+	White    []int  `json:"w,omitempty"`
+	Empty    []int  `json:"e,omitempty"`
+	Captures [2]int `json:"cap"` // by Black, by White
+}
+
+// boardDelta is the change from before to after, which are the same size;
+// the captures are after's.
+func boardDelta(before, after BoardView, captures [2]int) *pipeDelta {
+	d := &pipeDelta{Captures: captures}
+	size := after.Size()
+	for p := 0; p < size*size; p++ {
+		s := after.At(p/size, p%size)
+		if s == before.At(p/size, p%size) {
+			continue
+		}
+		switch s {
+		case Black:
+			d.Black = append(d.Black, p)
+		case White:
+			d.White = append(d.White, p)
+		default:
+			d.Empty = append(d.Empty, p)
+		}
+	}
+	return d
+}
+
+// boardEvent is the position at now, for a client to draw afresh.
+func (g *netGame) boardEvent(now time.Time) pipeEvent {
+	var e pipeEvent
+	g.game.Do(func(l *GameLog) {
+		b := l.board
+		e = pipeEvent{
+			Event: "board", Size: b.Size(), Number: l.Moves(), ToMove: colorKey(b.Turn()), Hash: b.Hash(),
+			Delta:    boardDelta(NewBoard(b.Size()), b, [2]int{l.Captures(Black), l.Captures(White)}),
+			Position: EncodePosition(b),
+		}
+	})
+	if g.clock != nil {
+		e.ServerMS = now.UnixMilli()
+		e.Black, e.White = newPipeClock(g.clock.State(Black, now)), newPipeClock(g.clock.State(White, now))
+	}
+	return e
+}
+
+func (g *netGame) clockEvent(now time.Time) pipeEvent {
+	return pipeEvent{
+		Event: "clock", ToMove: colorKey(g.game.Board().Turn()), ServerMS: now.UnixMilli(),
+		Black: newPipeClock(g.clock.State(Black, now)), White: newPipeClock(g.clock.State(White, now)),
+	}
+}
+
+// run plays the game to the end and returns the result.
+func (g *netGame) run() string {
+	g.metrics.gameStarted()
+	defer g.metrics.gameEnded()
+	defer g.audit.close()
+	size := g.game.Board().Size()
+	g.audit.record(AuditEntry{Kind: "start", Size: size, Komi: g.komi, Black: g.player(Black), White: g.player(White)})
+	for _, p := range g.players[Black:] {
+		e := g.start
+		e.You = colorKey(p.color)
+		p.send(e)
+	}
+	ticker := time.NewTicker(g.sync)
+	defer ticker.Stop()
+	var undo *undoRequest
+	for !g.game.Board().IsGameOver() {
+		color := g.game.Board().Turn()
+		mover := g.players[color]
+		start := time.Now()
+		var flag <-chan time.Time
+		if g.clock != nil {
+			g.clock.Start(color, start)
+			g.broadcast(g.clockEvent(start))
+			flag = time.After(g.clock.State(color, start).MoveTime(g.clock.Period))
+		}
+		mover.send(pipeEvent{Event: "turn", Color: colorKey(color), Number: g.game.Moves() + 1})
+		g.hook("turn", color, "")
+
+	wait:
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, p := range g.players[Black:] {
+					g.pingID++
+					p.ping(g.pingID, now)
+				}
+				if g.clock != nil {
+					g.broadcast(g.clockEvent(now))
+				}
+			case <-flag:
+				// Give a move already on its way the benefit of the
+				// compensation before adjudicating.
+				flag = nil
+				if g.clock.State(color, time.Now().Add(-min(mover.roundTrip(), netMaxCompensation))).Flagged {
+					g.game.Expire(color)
+					return g.end(timeUpResult(color), color.Opponent(), nil)
+				}
+				flag = time.After(10 * time.Millisecond)
+			case <-g.adjourn:
+				return g.adjourned()
+			case s := <-g.players[Black].syncs:
+				g.resync(g.players[Black], s)
+			case s := <-g.players[White].syncs:
+				g.resync(g.players[White], s)
+			case <-g.players[color.Opponent()].gone:
+				return g.end(forfeitResult(color.Opponent()), color, nil)
+			case <-mover.gone:
+				return g.end(forfeitResult(color), color.Opponent(), nil)
+			case m := <-g.players[color.Opponent()].moves:
+				if strings.TrimSpace(m.Move) == "adjourn" {
+					return g.adjourned()
+				}
+				if handled, undone := g.negotiateUndo(g.players[color.Opponent()], m.Move, &undo); undone {
+					break wait
+				} else if handled {
+					continue
+				}
+				if opponent := g.players[color.Opponent()]; sequenceMove(opponent, m, g.movesPlayed(), size) {
+					opponent.send(pipeEvent{Event: "error", Code: errNotYourTurn, Message: "not your turn"})
+				}
+			case m := <-mover.moves:
+				if strings.TrimSpace(m.Move) == "adjourn" {
+					return g.adjourned()
+				}
+				if handled, undone := g.negotiateUndo(mover, m.Move, &undo); undone {
+					break wait
+				} else if handled {
+					continue
+				}
+				arrival := time.Now()
+				if g.clock != nil && g.clock.State(color, chargedUntil(start, arrival, mover.roundTrip())).Flagged {
+					g.game.Expire(color)
+					return g.end(timeUpResult(color), color.Opponent(), nil)
+				}
+				if !sequenceMove(mover, m, g.movesPlayed(), size) {
+					continue
+				}
+				before := g.game.Board()
+				move, err := parseMoveInput(m.Move, size)
+				number := 0
+				if err == nil {
+					number, err = g.game.Play(color, move)
+				}
+				if err != nil {
+					mover.send(pipeEvent{Event: "error", Code: errBadMove, Message: err.Error()})
+					continue
+				}
+				after := g.game.Board()
+				e := pipeEvent{
+					Event: "move", Color: colorKey(color), Number: number, Move: formatMove(move, size), Hash: after.Hash(),
+					Delta: boardDelta(before, after, [2]int{g.game.Captures(Black), g.game.Captures(White)}),
+				}
+				if g.clock != nil {
+					stopped := chargedUntil(start, arrival, mover.roundTrip())
+					g.clock.Stop(stopped)
+					e.ServerMS = stopped.UnixMilli()
+					e.Black, e.White = newPipeClock(g.clock.State(Black, stopped)), newPipeClock(g.clock.State(White, stopped))
+				}
+				if move != PassMove {
+					row, col := move/size, move%size
+					e.Row, e.Col = &row, &col
+				}
+				g.broadcast(e)
+				g.metrics.played(arrival)
+				kind := "move"
+				if move == PassMove {
+					kind = "pass"
+				}
+				g.audit.record(AuditEntry{Kind: kind, Color: e.Color, Number: number, Move: e.Move, Hash: e.Hash})
+				if undo != nil {
+					// Playing on declines, or withdraws, an undo.
+					undo = nil
+					g.broadcast(pipeEvent{Event: "undo_declined", Color: colorKey(color)})
+				}
+				break wait
+			}
+		}
+	}
+	winner, margin := g.game.Board().Winner(g.komi)
+	return g.end(resultString(winner, margin), winner, &margin)
+}
+
+// end tells both players the result; margin is nil unless the game was
+// scored.
+func (g *netGame) end(result string, winner Stone, margin *float64) string {
+	g.broadcast(pipeEvent{Event: "end", Result: result, Winner: colorKey(winner), Margin: margin})
+	g.audit.record(AuditEntry{Kind: "end", Result: result})
+	if strings.HasSuffix(result, "+T") {
+		g.hook("timeout", winner.Opponent(), result)
+	} else {
+		g.hook("end", Empty, result)
+	}
+	return result
+}
+
+// adjourned stops the clocks and tells everyone the game is adjourned.
+func (g *netGame) adjourned() string {
+	g.broadcast(pipeEvent{Event: "adjourn", Message: adjournText(g.game.Moves(), g.clock, time.Now())})
+	g.audit.record(AuditEntry{Kind: "adjourn", Number: g.game.Moves()})
+	return "adjourned"
+}
+
+// forfeitResult is the result when loser leaves the game.
+func forfeitResult(loser Stone) string {
+	if loser == Black {
+		return "W+F"
+	}
+	return "B+F"
+}
+
+// parseMoveInput parses "row col" or "pass".
+func parseMoveInput(s string, size int) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "pass" {
+		return PassMove, nil
+	}
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0, errors.New("use format: row col")
+	}
+	row, err1 := strconv.Atoi(parts[0])
+	col, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || row < 0 || col < 0 || row >= size || col >= size {
+		return 0, errors.New("use format: row col, on the board")
+	}
+	return row*size + col, nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":7000", "address to listen on")
+	size := fs.Int("size", 9, "board size")
+	komi := fs.Float64("komi", 6.5, "komi")
+	mainTime := fs.Duration("time", 0, "main time per player (default no clock)")
+	byoyomi := fs.String("byoyomi", "", "byo-yomi after main time, e.g. 5x30s")
+	syncEvery := fs.Duration("sync", netSyncInterval, "clock sync and ping interval")
+	auth := fs.Bool("auth", false, "give each colour a token its player must connect with")
+	password := fs.String("password", os.Getenv("GOGAME_PASSWORD"), "password everyone must connect with, players and watchers (default $GOGAME_PASSWORD)")
+	certFile := fs.String("tls-cert", "", "serve over TLS with this certificate file (PEM)")
+	keyFile := fs.String("tls-key", "", "private key file (PEM) for -tls-cert")
+	selfSigned := fs.Bool("tls-self-signed", false, "serve over TLS with a new self-signed certificate, printing its fingerprint for clients to pin")
+	ws := fs.Bool("ws", false, "speak WebSocket instead of plain TCP, for browsers and apps (see websocket.go)")
+	lobbyMode := fs.Bool("lobby", false, "host any number of games, set up by challenges in a lobby (see lobby.go)")
+	perAddr := fs.Int("max-conns-per-ip", 8, "connections open at once from one IP address, 0 for no limit")
+	connRate := fs.Float64("conn-rate", 1, "new connections a second from one IP address, after a burst of 10; 0 for no limit")
+	inputRate := fs.Float64("input-rate", 5, "lines a second from one connection, moves and commands alike, after a burst of 20; 0 for no limit")
+	maxGames := fs.Int("max-games", 0, "games at once in the lobby, 0 for no limit")
+	metricsAddr := fs.String("metrics", "", "serve Prometheus metrics at http://ADDR/metrics (see metrics.go)")
+	webhookURLs := fs.String("webhook", "", "comma-separated URLs to post game events to, such as Slack or Discord incoming webhooks (see webhook.go)")
+	telegramToken := fs.String("telegram-token", os.Getenv("TELEGRAM_BOT_TOKEN"), "with -lobby, let people play from Telegram through this bot (default $TELEGRAM_BOT_TOKEN; see telegram.go)")
+	ircAddr := fs.String("irc", "", "with -lobby, let people play from IRC through a bridge to this server, HOST:PORT (see irc.go)")
+	ircTLS := fs.Bool("irc-tls", false, "connect to the -irc server over TLS")
+	ircNick := fs.String("irc-nick", "gogame", "the -irc bridge's nick")
+	ircChannel := fs.String("irc-channel", "#gogame", "the channel the -irc bridge joins")
+	webhookEvents := fs.String("webhook-events", strings.Join(webhookEventNames, ","), "events to post to -webhook")
+	auditDir := fs.String("audit", "", "write a signed audit log of every game to this directory (see audit.go)")
+	auditKey := fs.String("audit-key", dataPath("audit.key"), "the key -audit signs with, made if missing")
+	fs.Parse(args)
+	if *lobbyMode && *auth {
+		return errors.New("-auth is for a single game; lobby players are known by name")
+	}
+	if !*lobbyMode && *maxGames != 0 {
+		return errors.New("-max-games is for -lobby")
+	}
+	hooks, err := newWebhooks(*webhookURLs, *webhookEvents)
+	if err != nil {
+		return err
+	}
+	var audit *auditor
+	if *auditDir != "" {
+		key, err := loadAuditKey(*auditKey)
+		if err != nil {
+			return err
+		}
+		if audit, err = newAuditor(*auditDir, key); err != nil {
+			return err
+		}
+		fmt.Printf("Audit logs in %s, signed with key %s\n", *auditDir, auditPublicKey(key))
+	}
+	limits := newNetLimits(*perAddr, *connRate, *inputRate, *maxGames)
+	metrics := newServerMetrics()
+	if *metricsAddr != "" {
+		mln, err := serveMetrics(*metricsAddr, metrics, limits)
+		if err != nil {
+			return err
+		}
+		defer mln.Close()
+		fmt.Printf("Metrics at http://%s/metrics\n", mln.Addr())
+	}
+	if *size < 2 || *size > MaxBoardSize {
+		return fmt.Errorf("board size must be between 2 and %d", MaxBoardSize)
+	}
+
+	g := newNetGame(*size, *komi, *syncEvery)
+	if *mainTime > 0 || *byoyomi != "" {
+		var periods int
+		var period time.Duration
+		if *byoyomi != "" {
+			var err error
+			if periods, period, err = parseByoyomi(*byoyomi); err != nil {
+				return err
+			}
+		}
+		g.clock = NewClock(*mainTime, periods, period)
+	}
+
+	access := netAccess{password: *password}
+	if *auth {
+		for _, color := range []Stone{Black, White} {
+			key := make([]byte, 8)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			access.tokens[color] = hex.EncodeToString(key)
+		}
+	}
+
+	ln, fingerprint, err := listenTLS(*addr, *certFile, *keyFile, *selfSigned)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if *ws {
+		ln = newWSListener(ln)
+	}
+	if !*lobbyMode {
+		fmt.Printf("Waiting for two players on %s\n", ln.Addr())
+	}
+	join := fmt.Sprintf("gogame connect -addr %s", ln.Addr())
+	if *ws {
+		join += " -ws"
+	}
+	switch {
+	case *selfSigned:
+		join += " -tls-fingerprint " + fingerprint
+	case fingerprint != "":
+		join += " -tls"
+	}
+	if fingerprint != "" {
+		fmt.Printf("TLS certificate fingerprint: %s\n", fingerprint)
+	}
+	if *lobbyMode {
+		l := newLobby(*syncEvery, limits, metrics)
+		l.hooks, l.audit = hooks, audit
+		if *telegramToken != "" {
+			bot, name, err := newTelegramBot(newTelegramAPI(telegramAPIBase, *telegramToken), l)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Telegram players message @%s\n", name)
+			go bot.run()
+		}
+		if *ircAddr != "" {
+			conn, err := dialTLS(*ircAddr, *ircTLS, "")
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			fmt.Printf("IRC players message %s on %s, or say !help in %s\n", *ircNick, *ircAddr, *ircChannel)
+			go func() {
+				fmt.Println("irc:", newIRCBridge(conn, *ircNick, *ircChannel, l).run())
+			}()
+		}
+		return serveLobby(ln, l, access)
+	}
+	for _, color := range []Stone{Black, White} {
+		if access.tokens[color] != "" {
+			fmt.Printf("%s joins with: %s -token %s\n", colorKey(color), join, access.tokens[color])
+		}
+	}
+	g.limits, g.metrics, g.hooks = limits, metrics, hooks
+	g.title = "game on " + ln.Addr().String()
+	g.audit = audit.open(g.title)
+	admit := func(conn net.Conn) {
+		limited, err := limits.accept(conn, time.Now())
+		if err != nil {
+			refuse(conn, errRateLimited, err)
+			return
+		}
+		conn = limited
+		color, err := g.admit(conn, access)
+		switch {
+		case err != nil:
+			refuse(conn, errUnauthorized, err)
+		case color == Empty:
+			fmt.Printf("watcher: %s\n", conn.RemoteAddr())
+			g.watch(conn)
+		default:
+			g.players[color] = newLimitedNetPlayer(color, conn, limits.gate())
+			fmt.Printf("%s: %s\n", colorKey(color), conn.RemoteAddr())
+		}
+	}
+	for g.players[Black] == nil || g.players[White] == nil {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		admit(conn)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go admit(conn)
+		}
+	}()
+	interrupts, stopInterrupts := notifyShutdown()
+	defer stopInterrupts()
+	go func() {
+		<-interrupts
+		close(g.adjourn)
+	}()
+	fmt.Println("Result:", g.run())
+	hooks.wait()
+	fmt.Println("Limits:", limits.Stats())
+	return nil
+}
+
+// netClient is the terminal side of connect. Clocks are shown from the
+// server's last clock event, counting down locally between events.
+type netClient struct {
+	mu       sync.Mutex
+	board    *Board
+	you      Stone
+	black    *pipeClock
+	white    *pipeClock
+	toMove   Stone
+	received time.Time
+	lobby    bool // connected to a lobby, see lobby.go
+	playing  bool
+	moves    int // moves shown, to skip events sent again
+	size     int // of the game's board, for reading moves
+}
+
+// netWords are what connect sends as typed during a game, rather than as
+// a move.
+var netWords = map[string]bool{"undo": true, "accept": true, "decline": true, "adjourn": true}
+
+// moveInput turns a line typed during a game into what the server reads: a
+// move in the user's coordinates becomes "row col", and the protocol's
+// words go as they are.
+func (c *netClient) moveInput(line string) (string, error) {
+	if netWords[line] {
+		return line, nil
+	}
+	c.mu.Lock()
+	size := c.size
+	c.mu.Unlock()
+	m, err := userCoords.Parse(line, size)
+	if err != nil {
+		return "", err
+	}
+	return formatMove(m, size), nil
+}
+
+// seq is the number to send a move with: the next move's.
+func (c *netClient) seq() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.moves + 1
+}
+
+// inLobby reports whether input goes to the lobby rather than a game.
+func (c *netClient) inLobby() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lobby && !c.playing
+}
+
+// showLobby prints a lobby event.
+func showLobby(e pipeEvent) {
+	fmt.Printf("\n%s\nlobby> ", lobbyText(e))
+}
+
+// lobbyText is a lobby event as text: its message, or the lists when it
+// has none.
+func lobbyText(e pipeEvent) string {
+	if e.Message != "" {
+		return e.Message
+	}
+	var lines []string
+	for _, p := range e.Players {
+		line := p.Name
+		if p.Rank != "" {
+			line += " " + p.Rank
+		}
+		if p.Playing {
+			line += " (playing)"
+		}
+		lines = append(lines, "  "+line)
+	}
+	for _, ch := range e.Challenges {
+		lines = append(lines, "  "+ch.String())
+	}
+	if len(e.Challenges) == 0 {
+		lines = append(lines, "  No open challenges")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clockLine is both clocks as of now.
+func (c *netClient) clockLine(now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.black == nil {
+		return ""
+	}
+	show := func(color Stone, pc *pipeClock) string {
+		s := ClockState{
+			Main: time.Duration(pc.MainMS) * time.Millisecond, Periods: pc.Periods,
+			PeriodLeft: time.Duration(pc.PeriodMS) * time.Millisecond, Byoyomi: pc.Byoyomi,
+		}
+		if color == c.toMove {
+			elapsed := now.Sub(c.received)
+			if s.Byoyomi {
+				s.PeriodLeft = max(s.PeriodLeft-elapsed, 0)
+			} else {
+				s.Main = max(s.Main-elapsed, 0)
+			}
+		}
+		return fmt.Sprintf("%s %s", color, s)
+	}
+	return show(Black, c.black) + "   " + show(White, c.white)
+}
+
+func runConnect(args []string) error {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:7000", "server address")
+	token := fs.String("token", "", "your token, for a server started with -auth")
+	password := fs.String("password", os.Getenv("GOGAME_PASSWORD"), "the server's password, if it has one (default $GOGAME_PASSWORD)")
+	useTLS := fs.Bool("tls", false, "connect over TLS, checking the server's certificate")
+	fingerprint := fs.String("tls-fingerprint", "", "connect over TLS to a server with a self-signed certificate with this SHA-256 fingerprint")
+	ws := fs.Bool("ws", false, "speak WebSocket, to a server started with -ws")
+	fs.Parse(args)
+
+	conn, err := dialTLS(*addr, *useTLS, *fingerprint)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if *ws {
+		if conn, err = dialWS(conn, *addr); err != nil {
+			return err
+		}
+	}
+	c := &netClient{}
+	var sendMu sync.Mutex
+	send := func(v netInput) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		json.NewEncoder(conn).Encode(v)
+	}
+	if *token != "" || *password != "" {
+		send(netInput{Hello: *token, Password: *password})
+	}
+
+	// Ctrl-C asks the server to adjourn the game; a second one, or one in
+	// a lobby, quits.
+	interrupts, stopInterrupts := notifyShutdown()
+	defer stopInterrupts()
+	go func() {
+		<-interrupts
+		stopInterrupts()
+		if c.inLobby() {
+			fmt.Println()
+			os.Exit(0)
+		}
+		fmt.Println("\nAdjourning, press Ctrl-C again to quit.")
+		send(netInput{Move: "adjourn"})
+	}()
+
+	go func() {
+		in := bufio.NewScanner(os.Stdin)
+		for in.Scan() {
+			line := strings.TrimSpace(in.Text())
+			switch {
+			case line == "":
+			case c.inLobby():
+				send(netInput{Command: line})
+			default:
+				move, err := c.moveInput(line)
+				if err != nil {
+					fmt.Printf("Invalid move: %v\n", err)
+					continue
+				}
+				send(netInput{Move: move, Seq: c.seq()})
+			}
+		}
+	}()
+
+	events := bufio.NewScanner(conn)
+	for events.Scan() {
+		var e pipeEvent
+		if err := json.Unmarshal(events.Bytes(), &e); err != nil {
+			return fmt.Errorf("server: %v", err)
+		}
+		switch e.Event {
+		case "ping":
+			send(netInput{Pong: e.Ping})
+		case "lobby":
+			c.mu.Lock()
+			c.lobby, c.playing = true, false
+			c.mu.Unlock()
+			showLobby(e)
+		case "start":
+			c.mu.Lock()
+			c.playing, c.moves, c.size = true, 0, e.Size
+			c.mu.Unlock()
+			c.board = NewBoard(e.Size)
+			c.you = map[string]Stone{"black": Black, "white": White}[e.You]
+			fmt.Printf("You play %s\n", c.you)
+			if userCoords != IndexCoords {
+				fmt.Printf("Enter moves in %s coordinates (e.g., '%s')\n", userCoords, userCoords.Move(3*e.Size+3, e.Size))
+			}
+			showBoard(c.board)
+		case "clock":
+			c.mu.Lock()
+			c.black, c.white, c.received = e.Black, e.White, time.Now()
+			c.toMove = map[string]Stone{"black": Black, "white": White}[e.ToMove]
+			c.mu.Unlock()
+		case "turn":
+			if line := c.clockLine(time.Now()); line != "" {
+				fmt.Println("Clock:", line)
+			}
+			fmt.Printf("Enter move for %s: ", c.you)
+		case "setup":
+			if e.Row != nil && e.Col != nil {
+				c.board.setPoint(*e.Row*c.board.size+*e.Col, map[string]Stone{"black": Black, "white": White}[e.Color])
+			}
+		case "board":
+			c.mu.Lock()
+			c.moves, c.size = e.Number, e.Size
+			c.mu.Unlock()
+			if b, err := DecodePosition(e.Position); err == nil {
+				c.board = b
+			} else {
+				c.board = NewBoard(e.Size)
+				for _, p := range e.Delta.Black {
+					c.board.setPoint(p, Black)
+				}
+				for _, p := range e.Delta.White {
+					c.board.setPoint(p, White)
+				}
+				c.board.turn = map[string]Stone{"black": Black, "white": White}[e.ToMove]
+			}
+			showBoard(c.board)
+			if e.Comment != "" {
+				fmt.Println(e.Comment)
+			}
+		case "undo_request":
+			if e.Color == colorKey(c.you) {
+				fmt.Println("Undo asked for; waiting for your opponent to answer.")
+			} else {
+				fmt.Printf("\n%s asks to take back moves, leaving %d. Type accept or decline.\n", e.Color, e.Number)
+			}
+		case "undo_declined":
+			fmt.Printf("\nThe undo was declined by %s.\n", e.Color)
+		case "undo":
+			c.mu.Lock()
+			c.moves = e.Number
+			c.mu.Unlock()
+			for color, points := range [][]int{Empty: e.Delta.Empty, Black: e.Delta.Black, White: e.Delta.White} {
+				for _, p := range points {
+					c.board.setPoint(p, Stone(color))
+				}
+			}
+			c.board.turn = map[string]Stone{"black": Black, "white": White}[e.ToMove]
+			fmt.Printf("\nMoves taken back; %d left.\n", e.Number)
+			showBoard(c.board)
+		case "move":
+			c.mu.Lock()
+			repeat, missed := e.Number <= c.moves, e.Number > c.moves+1
+			if !missed {
+				c.moves = max(c.moves, e.Number)
+			}
+			c.mu.Unlock()
+			if missed {
+				send(netInput{Board: true})
+				break
+			}
+			if repeat {
+				break // sent again, for a repeated move or a sync
+			}
+			move := PassMove
+			if e.Row != nil && e.Col != nil {
+				move = *e.Row*c.board.size + *e.Col
+			}
+			c.board.Play(move)
+			fmt.Printf("\n%s plays %s\n", e.Color, userCoords.Move(move, c.board.size))
+			showBoard(c.board)
+		case "error":
+			if c.inLobby() {
+				fmt.Printf("%s\nlobby> ", e.Message)
+			} else {
+				fmt.Printf("%s\nEnter move for %s: ", e.Message, c.you)
+			}
+		case "message":
+			fmt.Println(e.Message)
+		case "end":
+			if e.Result != "" {
+				fmt.Printf("\nResult: %s\n", e.Result)
+			} else {
+				fmt.Printf("\n%s\n", e.Message)
+			}
+			if !c.lobby {
+				return nil
+			}
+		case "adjourn":
+			fmt.Printf("\n%s\n", e.Message)
+			if !c.lobby {
+				return nil
+			}
+		}
+	}
+	return errors.New("connection closed")
+}
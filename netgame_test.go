@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChargedUntil(t *testing.T) {
+	start := time.Unix(1000, 0)
+	for _, tc := range []struct {
+		arrival, rtt, want time.Duration
+	}{
+		{5 * time.Second, 200 * time.Millisecond, 4800 * time.Millisecond},
+		{5 * time.Second, 10 * time.Second, 3 * time.Second}, // capped
+		{100 * time.Millisecond, time.Second, 0},             // not before the turn
+	} {
+		got := chargedUntil(start, start.Add(tc.arrival), tc.rtt).Sub(start)
+		if got != tc.want {
+			t.Errorf("chargedUntil(%v, rtt %v) = %v, want %v", tc.arrival, tc.rtt, got, tc.want)
+		}
+	}
+}
+
+// netTestClient plays one side of a netGame over a pipe, answering pings.
+type netTestClient struct {
+	conn   net.Conn
+	events chan pipeEvent
+}
+
+func newNetTestClient(conn net.Conn) *netTestClient {
+	c := &netTestClient{conn: conn, events: make(chan pipeEvent, 100)}
+	go func() {
+		defer close(c.events)
+		in := bufio.NewScanner(conn)
+		for in.Scan() {
+			var e pipeEvent
+			json.Unmarshal(in.Bytes(), &e)
+			if e.Event == "ping" {
+				go json.NewEncoder(conn).Encode(netInput{Pong: e.Ping})
+				continue
+			}
+			c.events <- e
+		}
+	}()
+	return c
+}
+
+// next returns the next event named name, skipping others.
+func (c *netTestClient) next(t *testing.T, name string) pipeEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-c.events:
+			if !ok {
+				t.Fatalf("connection closed waiting for %s", name)
+			}
+			if e.Event == name {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", name)
+		}
+	}
+}
+
+func startNetGame(t *testing.T, g *netGame) (black, white *netTestClient, result chan string) {
+	t.Helper()
+	clients := [3]*netTestClient{}
+	for _, color := range []Stone{Black, White} {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		g.players[color] = newNetPlayer(color, server)
+		clients[color] = newNetTestClient(client)
+	}
+	result = make(chan string, 1)
+	go func() { result <- g.run() }()
+	return clients[Black], clients[White], result
+}
+
+func TestNetGame(t *testing.T) {
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.clock = NewClock(time.Minute, 0, 0)
+	black, white, result := startNetGame(t, g)
+
+	if e := white.next(t, "start"); e.You != "white" || e.Size != 5 {
+		t.Fatalf("start = %+v", e)
+	}
+	if e := black.next(t, "clock"); e.Black == nil || e.ServerMS == 0 {
+		t.Fatalf("clock = %+v", e)
+	}
+	black.next(t, "turn")
+	json.NewEncoder(white.conn).Encode(netInput{Move: "1 1", Seq: 1})
+	if e := white.next(t, "error"); e.Message != "not your turn" {
+		t.Fatalf("error = %+v", e)
+	}
+	json.NewEncoder(black.conn).Encode(netInput{Move: "2 2", Seq: 1})
+	if e := white.next(t, "move"); e.Move != "2 2" {
+		t.Fatalf("move = %+v", e)
+	}
+	black.next(t, "move")
+	white.next(t, "turn")
+	// Sent again, the move is acknowledged but not played twice; other
+	// numbers are refused.
+	json.NewEncoder(black.conn).Encode(netInput{Move: "2 2", Seq: 1})
+	if e := black.next(t, "move"); e.Move != "2 2" || e.Number != 1 {
+		t.Fatalf("repeated move = %+v", e)
+	}
+	for _, in := range []netInput{{Move: "3 3", Seq: 1}, {Move: "3 3", Seq: 3}, {Move: "3 3"}} {
+		json.NewEncoder(white.conn).Encode(in)
+		if e := white.next(t, "error"); e.Code != errBadSequence {
+			t.Fatalf("%+v: error = %+v", in, e)
+		}
+	}
+	if g.game.Moves() != 1 {
+		t.Fatalf("%d moves played", g.game.Moves())
+	}
+	// A client resyncing from the start is sent the move again.
+	start := NewBoard(5).Hash()
+	json.NewEncoder(white.conn).Encode(netInput{Sync: &start})
+	if e := white.next(t, "move"); e.Move != "2 2" || e.Hash == 0 {
+		t.Fatalf("move after sync = %+v", e)
+	}
+	// Or the position, which the codec form gives whole.
+	json.NewEncoder(white.conn).Encode(netInput{Board: true})
+	e := white.next(t, "board")
+	if b, err := DecodePosition(e.Position); err != nil || b.At(2, 2) != Black || b.Turn() != White || e.Number != 1 {
+		t.Fatalf("board = %+v, %v", e, err)
+	}
+
+	// A watcher joining now is caught up, then follows the game.
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+	watcher := newNetTestClient(client)
+	g.watch(server)
+	if e := watcher.next(t, "start"); e.You != "" || e.Size != 5 {
+		t.Fatalf("watcher start = %+v", e)
+	}
+	if e := watcher.next(t, "move"); e.Move != "2 2" {
+		t.Fatalf("watcher move = %+v", e)
+	}
+	json.NewEncoder(watcher.conn).Encode(netInput{Move: "1 1"})
+	if e := watcher.next(t, "error"); e.Message != "you are watching this game" {
+		t.Fatalf("watcher error = %+v", e)
+	}
+
+	json.NewEncoder(white.conn).Encode(netInput{Move: "pass", Seq: 2})
+	black.next(t, "turn")
+	json.NewEncoder(black.conn).Encode(netInput{Move: "pass", Seq: 3})
+	if e := black.next(t, "end"); e.Result != "B+24.5" {
+		t.Errorf("end = %+v", e)
+	}
+	if e := watcher.next(t, "end"); e.Result != "B+24.5" {
+		t.Errorf("watcher end = %+v", e)
+	}
+	if r := <-result; r != "B+24.5" {
+		t.Errorf("run = %s", r)
+	}
+}
+
+func TestNetGameTimeUp(t *testing.T) {
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.clock = NewClock(100*time.Millisecond, 0, 0)
+	black, white, result := startNetGame(t, g)
+	black.next(t, "turn")
+	if e := white.next(t, "end"); e.Result != "W+T" || e.Winner != "white" {
+		t.Errorf("end = %+v", e)
+	}
+	if r := <-result; r != "W+T" {
+		t.Errorf("run = %s", r)
+	}
+}
+
+func TestNetGameAdjourn(t *testing.T) {
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.clock = NewClock(time.Minute, 0, 0)
+	black, white, result := startNetGame(t, g)
+	black.next(t, "turn")
+	json.NewEncoder(white.conn).Encode(netInput{Move: "adjourn"})
+	e := black.next(t, "adjourn")
+	if !strings.HasPrefix(e.Message, "Game adjourned after 0 moves. Time left: ") {
+		t.Errorf("adjourn = %+v", e)
+	}
+	if r := <-result; r != "adjourned" {
+		t.Errorf("run = %s", r)
+	}
+
+	// Stopping the server adjourns the game too.
+	g = newNetGame(5, 0.5, 20*time.Millisecond)
+	black, _, result = startNetGame(t, g)
+	black.next(t, "turn")
+	close(g.adjourn)
+	black.next(t, "adjourn")
+	if r := <-result; r != "adjourned" {
+		t.Errorf("run = %s", r)
+	}
+}
+
+func TestNetGameUndo(t *testing.T) {
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.clock = NewClock(time.Minute, 0, 0)
+	black, white, _ := startNetGame(t, g)
+	play := func(c *netTestClient, in netInput) {
+		json.NewEncoder(c.conn).Encode(in)
+		c.next(t, "move")
+	}
+	black.next(t, "turn")
+	play(black, netInput{Move: "2 2", Seq: 1})
+	white.next(t, "turn")
+	play(white, netInput{Move: "1 1", Seq: 2})
+	black.next(t, "turn")
+
+	json.NewEncoder(black.conn).Encode(netInput{Move: "accept"})
+	if e := black.next(t, "error"); e.Code != errBadUndo {
+		t.Fatalf("accepting nothing: %+v", e)
+	}
+	json.NewEncoder(white.conn).Encode(netInput{Move: "undo"})
+	if e := black.next(t, "undo_request"); e.Color != "white" || e.Number != 1 {
+		t.Fatalf("undo request = %+v", e)
+	}
+	json.NewEncoder(black.conn).Encode(netInput{Move: "decline"})
+	white.next(t, "undo_declined")
+	json.NewEncoder(white.conn).Encode(netInput{Move: "undo"})
+	black.next(t, "undo_request")
+	json.NewEncoder(black.conn).Encode(netInput{Move: "accept"})
+	e := white.next(t, "undo")
+	if e.Number != 1 || e.ToMove != "white" || !reflect.DeepEqual(e.Delta.Empty, []int{6}) || e.White == nil {
+		t.Fatalf("undo = %+v", e)
+	}
+	if e := white.next(t, "turn"); e.Number != 2 {
+		t.Fatalf("turn after undo = %+v", e)
+	}
+
+	// Playing on instead of answering declines.
+	json.NewEncoder(black.conn).Encode(netInput{Move: "undo"})
+	white.next(t, "undo_request")
+	play(white, netInput{Move: "3 3", Seq: 2})
+	black.next(t, "undo_declined")
+	if n := len(g.movesPlayed()); n != 2 {
+		t.Errorf("%d moves played", n)
+	}
+}
+
+func TestNetGameAdmit(t *testing.T) {
+	g := newNetGame(5, 0.5, time.Second)
+	access := netAccess{tokens: [3]string{Black: "b-token", White: "w-token"}}
+	password := ""
+	admit := func(hello string) (Stone, error) {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		go json.NewEncoder(client).Encode(netInput{Hello: hello, Password: password, Move: "pass"})
+		return g.admit(server, access)
+	}
+
+	if color, err := admit("w-token"); color != White || err != nil {
+		t.Errorf("white's token: %v, %v", color, err)
+	}
+	g.players[White] = &netPlayer{color: White}
+	if _, err := admit("w-token"); err == nil {
+		t.Error("white admitted twice")
+	}
+	if _, err := admit("guess"); err == nil {
+		t.Error("unknown token admitted")
+	}
+	if color, err := admit(""); color != Empty || err != nil {
+		t.Errorf("no token: %v, %v", color, err)
+	}
+
+	// Without tokens, players are seated in order of arrival.
+	if color, _ := g.admit(nil, netAccess{}); color != Black {
+		t.Errorf("first seat = %v", color)
+	}
+
+	// A password keeps out everyone without it.
+	access = netAccess{password: "sesame"}
+	if _, err := admit(""); err == nil {
+		t.Error("admitted without the password")
+	}
+	password = "sesame"
+	if color, err := admit(""); color != Black || err != nil {
+		t.Errorf("with the password: %v, %v", color, err)
+	}
+}
+
+func TestNetClientMoveInput(t *testing.T) {
+	defer func(c Coords) { userCoords = c }(userCoords)
+	userCoords = WesternCoords
+	c := &netClient{size: 9}
+	for line, want := range map[string]string{"C7": "2 2", "pass": "pass", "undo": "undo", "accept": "accept"} {
+		if got, err := c.moveInput(line); err != nil || got != want {
+			t.Errorf("moveInput(%q) = %q, %v, want %q", line, got, err, want)
+		}
+	}
+	for _, line := range []string{"2 2", "Z9", "C10"} {
+		if got, err := c.moveInput(line); err == nil {
+			t.Errorf("moveInput(%q) = %q, want an error", line, got)
+		}
+	}
+	userCoords = IndexCoords
+	if got, err := c.moveInput("2 2"); err != nil || got != "2 2" {
+		t.Errorf("index coordinates: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Limits keep a public server, above all a lobby, usable when someone
+// floods it. Each IP address may have only so many connections open
+// (-max-conns-per-ip) and open new ones only so fast (-conn-rate); each
+// connection may send only so many lines a second (-input-rate), which
+// caps how fast moves and commands come in; and a lobby runs only so
+// many games at once (-max-games). Connections over a limit are refused
+// and lines over it dropped, with a rate_limited error event; a game
+// over the limit is not started, with a server_full one.
+
+const (
+	netConnBurst  = 10   // connections an address may open at once before -conn-rate applies
+	netInputBurst = 20   // lines a connection may send at once before -input-rate applies
+	netMaxTracked = 4096 // addresses whose connection rate is tracked before idle ones are forgotten
+)
+
+// rateLimiter is a token bucket: rate events a second on average, in
+// bursts of up to burst.
+type rateLimiter struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+// newRateLimiter returns a limiter, or nil, which allows everything, if
+// rate is not positive.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow reports whether an event at now is within the rate, using up its
+// share if so.
+func (r *rateLimiter) allow(now time.Time) bool {
+	if r == nil {
+		return true
+	}
+	r.refill(now)
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+func (r *rateLimiter) refill(now time.Time) {
+	if !r.last.IsZero() && now.After(r.last) {
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+}
+
+// netStats counts what a server's limits have done.
+type netStats struct {
+	Open         int64 // connections open now
+	Accepted     int64 // connections let in
+	Refused      int64 // connections turned away by -max-conns-per-ip or -conn-rate
+	Throttled    int64 // lines dropped by -input-rate
+	GamesRefused int64 // challenges accepted past -max-games
+}
+
+func (s netStats) String() string {
+	return fmt.Sprintf("%d connections accepted, %d refused, %d lines dropped, %d games refused",
+		s.Accepted, s.Refused, s.Throttled, s.GamesRefused)
+}
+
+// netLimits are a server's limits. Zero values are no limit, and so is a
+// nil *netLimits.
+type netLimits struct {
+	perAddr   int
+	connRate  float64
+	inputRate float64
+	games     int
+
+	mu    sync.Mutex
+	open  map[string]int          // connections open by address
+	dials map[string]*rateLimiter // connection rate by address
+	stats netStats
+}
+
+func newNetLimits(perAddr int, connRate, inputRate float64, games int) *netLimits {
+	return &netLimits{
+		perAddr:   perAddr,
+		connRate:  connRate,
+		inputRate: inputRate,
+		games:     games,
+		open:      map[string]int{},
+		dials:     map[string]*rateLimiter{},
+	}
+}
+
+// addrHost is the IP address of a, without the port.
+func addrHost(a net.Addr) string {
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return a.String()
+	}
+	return host
+}
+
+// accept lets conn in if its address is within the limits. The returned
+// connection gives its place back when it is closed or its reads fail.
+func (l *netLimits) accept(conn net.Conn, now time.Time) (net.Conn, error) {
+	if l == nil {
+		return conn, nil
+	}
+	host := addrHost(conn.RemoteAddr())
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perAddr > 0 && l.open[host] >= l.perAddr {
+		l.stats.Refused++
+		return nil, fmt.Errorf("at most %d connections from one address", l.perAddr)
+	}
+	if l.connRate > 0 {
+		d := l.dials[host]
+		if d == nil {
+			if len(l.dials) >= netMaxTracked {
+				l.forget(now)
+			}
+			d = newRateLimiter(l.connRate, netConnBurst)
+			l.dials[host] = d
+		}
+		if !d.allow(now) {
+			l.stats.Refused++
+			return nil, errors.New("too many connections; try again later")
+		}
+	}
+	l.open[host]++
+	l.stats.Accepted++
+	return &limitedConn{Conn: conn, release: func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.open[host]--; l.open[host] <= 0 {
+			delete(l.open, host)
+		}
+	}}, nil
+}
+
+// forget drops the connection rates of addresses that have been quiet
+// long enough to be back to a full burst. l.mu must be held.
+func (l *netLimits) forget(now time.Time) {
+	for host, d := range l.dials {
+		if d.refill(now); d.tokens >= d.burst {
+			delete(l.dials, host)
+		}
+	}
+}
+
+var errLobbyFull = errors.New("the server is full")
+
+// startGame reports whether a lobby running games games may start one
+// more.
+func (l *netLimits) startGame(games int) error {
+	if l == nil || l.games <= 0 || games < l.games {
+		return nil
+	}
+	l.mu.Lock()
+	l.stats.GamesRefused++
+	l.mu.Unlock()
+	return fmt.Errorf("%w with %d games; try again when one ends", errLobbyFull, games)
+}
+
+// Stats returns the counts so far.
+func (l *netLimits) Stats() netStats {
+	if l == nil {
+		return netStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stats
+	for _, n := range l.open {
+		s.Open += int64(n)
+	}
+	return s
+}
+
+// gate returns a lineGate for a new connection, or nil if lines are not
+// limited.
+func (l *netLimits) gate() *lineGate {
+	if l == nil || l.inputRate <= 0 {
+		return nil
+	}
+	return &lineGate{limits: l, rate: newRateLimiter(l.inputRate, netInputBurst)}
+}
+
+// limitedConn is a connection let in by netLimits.accept.
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	// A read deadline, as for a hello line, leaves the connection open.
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.once.Do(c.release)
+	}
+	return n, err
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// lineGate limits the lines read from one connection. A nil *lineGate
+// passes everything.
+type lineGate struct {
+	limits   *netLimits
+	rate     *rateLimiter
+	dropping bool
+}
+
+// pass reports whether a line read at now may be handled, and whether
+// the sender should be told that lines are being dropped, which is once
+// for each run of them.
+func (g *lineGate) pass(now time.Time) (ok, warn bool) {
+	if g == nil || g.rate.allow(now) {
+		if g != nil {
+			g.dropping = false
+		}
+		return true, false
+	}
+	g.limits.mu.Lock()
+	g.limits.stats.Throttled++
+	g.limits.mu.Unlock()
+	warn = !g.dropping
+	g.dropping = true
+	return false, warn
+}
+
+// rateLimitedEvent is the error event for dropped lines.
+var rateLimitedEvent = pipeEvent{Event: "error", Code: errRateLimited, Message: "too many messages; some were dropped"}
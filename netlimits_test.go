@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimiter(2, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if !r.allow(now) {
+			t.Fatalf("event %d of the burst refused", i)
+		}
+	}
+	if r.allow(now) {
+		t.Fatal("event past the burst allowed")
+	}
+	if !r.allow(now.Add(500*time.Millisecond)) || r.allow(now.Add(500*time.Millisecond)) {
+		t.Error("half a second at 2 a second should allow one event")
+	}
+	if newRateLimiter(0, 3) != nil {
+		t.Error("a zero rate should be no limit")
+	}
+}
+
+func TestNetLimits(t *testing.T) {
+	l := newNetLimits(2, 0, 1, 1)
+	now := time.Now()
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		server, client := net.Pipe()
+		t.Cleanup(func() { server.Close(); client.Close() })
+		conn, err := l.accept(server, now)
+		if (err == nil) != (i < 2) {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		conns = append(conns, conn)
+	}
+	conns[0].Close()
+	server, _ := net.Pipe()
+	defer server.Close()
+	if _, err := l.accept(server, now); err != nil {
+		t.Errorf("closing a connection should make room: %v", err)
+	}
+
+	gate := l.gate()
+	var warnings int
+	for i := 0; i < netInputBurst+5; i++ {
+		ok, warn := gate.pass(now)
+		if ok != (i < netInputBurst) {
+			t.Fatalf("line %d passed = %v", i, ok)
+		}
+		if warn {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("warned %d times for one run of dropped lines", warnings)
+	}
+
+	if l.startGame(0) != nil || l.startGame(1) == nil {
+		t.Error("-max-games 1 should allow one game")
+	}
+	if s := l.Stats(); s.Accepted != 3 || s.Refused != 1 || s.Throttled != 5 || s.GamesRefused != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
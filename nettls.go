@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// TLS for serve and connect. A server uses a certificate from a CA, such
+// as one certbot keeps up to date (-tls-cert, -tls-key), or makes up a
+// self-signed one when it starts (-tls-self-signed). Clients check the
+// former as browsers do (connect -tls) and pin the latter by the SHA-256
+// fingerprint the server prints (connect -tls-fingerprint).
+
+// listenTLS listens on addr, over TLS if a certificate is given or
+// selfSigned is set, and returns the certificate's fingerprint.
+func listenTLS(addr, certFile, keyFile string, selfSigned bool) (ln net.Listener, fingerprint string, err error) {
+	var cert tls.Certificate
+	switch {
+	case (certFile != "" || keyFile != "") && selfSigned:
+		return nil, "", errors.New("-tls-self-signed cannot be combined with -tls-cert and -tls-key")
+	case certFile != "" || keyFile != "":
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	case selfSigned:
+		cert, err = selfSignedCert()
+	default:
+		ln, err = net.Listen("tcp", addr)
+		return ln, "", err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	ln, err = tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return nil, "", err
+	}
+	return ln, certFingerprint(cert.Certificate[0]), nil
+}
+
+// selfSignedCert makes a certificate for this run of the server.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "gogame"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// certFingerprint is the SHA-256 of a DER certificate in hex.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// dialTLS connects to addr: over TLS, checked against the system's CAs,
+// if useTLS is set; over TLS to the certificate with fingerprint, which
+// may be written with colons, if that is given; and in the clear
+// otherwise.
+func dialTLS(addr string, useTLS bool, fingerprint string) (net.Conn, error) {
+	if fingerprint != "" {
+		want := strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+		return tls.Dial("tcp", addr, &tls.Config{
+			// The certificate is checked by its fingerprint instead.
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 || certFingerprint(cs.PeerCertificates[0].Raw) != want {
+					return errors.New("the server's certificate does not match -tls-fingerprint")
+				}
+				return nil
+			},
+		})
+	}
+	if useTLS {
+		return tls.Dial("tcp", addr, &tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return net.Dial("tcp", addr)
+}
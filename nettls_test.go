@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"testing"
+)
+
+func TestTLSFingerprint(t *testing.T) {
+	ln, fingerprint, err := listenTLS("127.0.0.1:0", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("hello\n"))
+			conn.Close()
+		}
+	}()
+
+	conn, err := dialTLS(ln.Addr().String(), false, fingerprint)
+	if err != nil {
+		t.Fatalf("dial with the fingerprint: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	conn.Close()
+	if err != nil || line != "hello\n" {
+		t.Errorf("read %q, %v", line, err)
+	}
+	other := "0" + fingerprint[1:]
+	if fingerprint[0] == '0' {
+		other = "1" + fingerprint[1:]
+	}
+	if conn, err := dialTLS(ln.Addr().String(), false, other); err == nil {
+		conn.Close()
+		t.Error("dialled a server with another fingerprint")
+	}
+	if conn, err := dialTLS(ln.Addr().String(), true, ""); err == nil {
+		conn.Close()
+		t.Error("a self-signed certificate passed CA checks")
+	}
+}
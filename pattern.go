@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// patternAny marks a pattern cell that matches anything.
+const patternAny Stone = -1
+
+// Pattern is a rectangular local pattern. Cells are Empty, Black, White or
+// patternAny.
+type Pattern struct {
+	rows, cols int
+	cells      [][]Stone
+	black      int
+	white      int
+}
+
+// ParsePattern reads a text diagram, one row per line: X or # for Black,
+// O for White, . or + for empty and ? for anything. Spaces are ignored.
+func ParsePattern(text string) (*Pattern, error) {
+	p := &Pattern{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+		if line == "" {
+			continue
+		}
+		var row []Stone
+		for _, c := range line {
+			switch c {
+			case 'X', 'x', '#':
+				row = append(row, Black)
+				p.black++
+			case 'O', 'o':
+				row = append(row, White)
+				p.white++
+			case '.', '+':
+				row = append(row, Empty)
+			case '?':
+				row = append(row, patternAny)
+			default:
+				return nil, fmt.Errorf("pattern: unexpected %q", c)
+			}
+		}
+		if p.cols != 0 && len(row) != p.cols {
+			return nil, errors.New("pattern: rows have different lengths")
+		}
+		p.cols = len(row)
+		p.cells = append(p.cells, row)
+	}
+	p.rows = len(p.cells)
+	if p.rows == 0 {
+		return nil, errors.New("pattern: empty")
+	}
+	return p, nil
+}
+
+// transform returns the pattern seen through symmetry sym, as in
+// transformPoint.
+func (p *Pattern) transform(sym int) *Pattern {
+	t := &Pattern{rows: p.rows, cols: p.cols, black: p.black, white: p.white}
+	if sym&4 != 0 {
+		t.rows, t.cols = p.cols, p.rows
+	}
+	t.cells = make([][]Stone, t.rows)
+	for i := range t.cells {
+		t.cells[i] = make([]Stone, t.cols)
+	}
+	for r := 0; r < p.rows; r++ {
+		for c := 0; c < p.cols; c++ {
+			rr, cc := r, c
+			if sym&1 != 0 {
+				cc = p.cols - 1 - cc
+			}
+			if sym&2 != 0 {
+				rr = p.rows - 1 - rr
+			}
+			if sym&4 != 0 {
+				rr, cc = cc, rr
+			}
+			t.cells[rr][cc] = p.cells[r][c]
+		}
+	}
+	return t
+}
+
+func (p *Pattern) matchesAt(b *Board, row, col int) bool {
+	for r := 0; r < p.rows; r++ {
+		for c := 0; c < p.cols; c++ {
+			want := p.cells[r][c]
+			if want != patternAny && b.grid[row+r][col+c] != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Match reports whether the pattern, in any orientation, appears anywhere
+// on the board.
+func (p *Pattern) Match(b *Board) bool {
+	black, white := 0, 0
+	for _, row := range b.grid {
+		for _, s := range row {
+			switch s {
+			case Black:
+				black++
+			case White:
+				white++
+			}
+		}
+	}
+	if black < p.black || white < p.white {
+		return false
+	}
+	for sym := 0; sym < 8; sym++ {
+		t := p.transform(sym)
+		for row := 0; row+t.rows <= b.size; row++ {
+			for col := 0; col+t.cols <= b.size; col++ {
+				if t.matchesAt(b, row, col) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
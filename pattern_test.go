@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestCanonicalHashIgnoresSymmetry(t *testing.T) {
+	b := NewBoard(9)
+	b.PlaceStone(2, 3)
+	b.PlaceStone(6, 6)
+	for sym := 1; sym < 8; sym++ {
+		m := NewBoard(9)
+		for _, p := range [][3]int{{2, 3, int(Black)}, {6, 6, int(White)}} {
+			r, c := transformPoint(sym, p[0], p[1], 9)
+			m.grid[r][c] = Stone(p[2])
+		}
+		if m.CanonicalHash() != b.CanonicalHash() {
+			t.Errorf("symmetry %d changes the canonical hash", sym)
+		}
+	}
+	b.grid[0][0] = Black
+	if b.CanonicalHash() == NewBoard(9).CanonicalHash() {
+		t.Error("different positions share a canonical hash")
+	}
+}
+
+func TestPatternMatchesInAnyOrientation(t *testing.T) {
+	p, err := ParsePattern("X O\n. ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBoard(9)
+	b.grid[5][5] = Black
+	b.grid[4][5] = White // White above Black: a rotation of the pattern
+	if !p.Match(b) {
+		t.Error("rotated pattern not found")
+	}
+	b.grid[4][5] = Empty
+	b.grid[2][2] = White
+	if p.Match(b) {
+		t.Error("pattern matched stones that are not adjacent")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pipe mode (-pipe) drives a game over a strict line protocol for test
+// harnesses and scripts. Nothing is prompted or decorated: every line in
+// either direction is one JSON object.
+//
+// Input, one per line:
+//
+//	{"move": "3 4"}     row and column, as typed by a human
+//	{"move": "pass"}
+//	{"move": "undo"}    take back the last move (and the computer's reply)
+//	{"move": "resign"}
+//	{"move": "quit"}
+//
+// Output events, one per line:
+//
+//	{"event": "start", "size": 9, "to_move": "black"}
+//	{"event": "clock", "black": {...}, "white": {...}}  before each turn, with a clock
+//	{"event": "turn", "color": "black", "number": 1}   waiting for input
+//	{"event": "time_warning", "color": "black", "clock": {...}}
+//	{"event": "move", "color": "black", "number": 1, "move": "3 4", "row": 3, "col": 4}
+//	{"event": "move", "color": "white", "number": 2, "move": "pass"}
+//	{"event": "error", "message": "..."}               input rejected, send another
+//	{"event": "undo", "number": 3}                     moves taken back; 3 moves remain
+//	{"event": "end", "result": "B+6.5", "winner": "black", "margin": 6.5}
+//	{"event": "end", "result": "W+T", "winner": "white"}   on time
+//	{"event": "end", "result": "B+R", "winner": "black"}   by resignation
+//
+// A clock is {"main_ms": 0, "periods": 3, "period_ms": 27000, "byoyomi":
+// true}: main time left, byo-yomi periods left and, in byo-yomi, the time
+// left in the current period. Both colours' clocks in an event are read at
+// the same instant, in whole milliseconds, so a client can run its own
+// display from them without drift between the two.
+//
+// Every input line is answered by exactly one move, undo, error or end
+// event.
+
+// pipeEvent is one line of pipe mode output.
+type pipeEvent struct {
+	Event   string     `json:"event"`
+	Size    int        `json:"size,omitempty"`
+	ToMove  string     `json:"to_move,omitempty"`
+	Color   string     `json:"color,omitempty"`
+	Number  int        `json:"number,omitempty"`
+	Move    string     `json:"move,omitempty"`
+	Row     *int       `json:"row,omitempty"`
+	Col     *int       `json:"col,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Code    string     `json:"code,omitempty"` // of a network error, see netgame.go
+	Result  string     `json:"result,omitempty"`
+	Winner  string     `json:"winner,omitempty"`
+	Margin  *float64   `json:"margin,omitempty"`
+	Clock   *pipeClock `json:"clock,omitempty"`
+	// Network mode only, see netgame.go.
+	You      string     `json:"you,omitempty"`
+	ServerMS int64      `json:"server_ms,omitempty"`
+	Ping     int64      `json:"ping,omitempty"`
+	Black    *pipeClock `json:"black,omitempty"`
+	White    *pipeClock `json:"white,omitempty"`
+	Hash     uint64     `json:"hash,string,omitempty"`
+	Delta    *pipeDelta `json:"delta,omitempty"`
+	Position []byte     `json:"position,omitempty"` // in the codec's position format, base64
+	// Broadcast mode only, see broadcast.go.
+	Comment    string     `json:"comment,omitempty"`
+	Marks      []pipeMark `json:"marks,omitempty"`
+	Variations []string   `json:"variations,omitempty"`
+	// Lobby mode only, see lobby.go.
+	Players    []LobbyPlayer `json:"players,omitempty"`
+	Challenges []Challenge   `json:"challenges,omitempty"`
+}
+
+type pipeClock struct {
+	MainMS   int64 `json:"main_ms"`
+	Periods  int   `json:"periods"`
+	PeriodMS int64 `json:"period_ms"`
+	Byoyomi  bool  `json:"byoyomi"`
+}
+
+func newPipeClock(s ClockState) *pipeClock {
+	return &pipeClock{MainMS: s.Main.Milliseconds(), Periods: s.Periods, PeriodMS: s.PeriodLeft.Milliseconds(), Byoyomi: s.Byoyomi}
+}
+
+// pipeInput is one line of pipe mode input.
+type pipeInput struct {
+	Move string `json:"move"`
+}
+
+type pipeView struct {
+	in      *bufio.Scanner
+	mu      sync.Mutex // time warnings come from another goroutine
+	out     *json.Encoder
+	size    int
+	moves   int
+	started bool
+}
+
+func newPipeView(in io.Reader, out io.Writer) *pipeView {
+	return &pipeView{in: bufio.NewScanner(in), out: json.NewEncoder(out)}
+}
+
+func (p *pipeView) emit(e pipeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out.Encode(e)
+}
+
+func (p *pipeView) Show(b BoardView) {
+	p.size = b.Size()
+	if !p.started {
+		p.started = true
+		p.emit(pipeEvent{Event: "start", Size: b.Size(), ToMove: colorKey(b.Turn())})
+	}
+}
+
+func (p *pipeView) Played(color Stone, move int) {
+	p.moves++
+	e := pipeEvent{Event: "move", Color: colorKey(color), Number: p.moves, Move: formatMove(move, p.size)}
+	if move != PassMove {
+		row, col := move/p.size, move%p.size
+		e.Row, e.Col = &row, &col
+	}
+	p.emit(e)
+}
+
+// Message drops the human-oriented chatter; moves and results have their
+// own events.
+func (p *pipeView) Message(text string) {}
+
+func (p *pipeView) Invalid(text string) {
+	p.emit(pipeEvent{Event: "error", Message: text})
+}
+
+func (p *pipeView) Result(winner Stone, margin float64) {
+	p.emit(pipeEvent{Event: "end", Result: resultString(winner, margin), Winner: colorKey(winner), Margin: &margin})
+}
+
+// Thinking is not reported: the protocol only has events a script must act
+// on.
+func (p *pipeView) Thinking(SearchProgress) {}
+
+func (p *pipeView) ReadMove(color Stone) (string, bool) {
+	p.emit(pipeEvent{Event: "turn", Color: colorKey(color), Number: p.moves + 1})
+	for p.in.Scan() {
+		line := strings.TrimSpace(p.in.Text())
+		if line == "" {
+			continue
+		}
+		var in pipeInput
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&in); err != nil {
+			p.Invalid("bad input: " + err.Error())
+			continue
+		}
+		if in.Move == "" {
+			p.Invalid(`bad input: missing "move"`)
+			continue
+		}
+		return in.Move, true
+	}
+	return "", false
+}
+
+func (p *pipeView) Clocks(c *Clock) {
+	now := time.Now()
+	p.emit(pipeEvent{Event: "clock", Black: newPipeClock(c.State(Black, now)), White: newPipeClock(c.State(White, now))})
+}
+
+func (p *pipeView) TimeWarning(color Stone, s ClockState) {
+	p.emit(pipeEvent{Event: "time_warning", Color: colorKey(color), Clock: newPipeClock(s)})
+}
+
+func (p *pipeView) TimeUp(loser Stone) {
+	p.emit(pipeEvent{Event: "end", Result: timeUpResult(loser), Winner: colorKey(loser.Opponent())})
+}
+
+func (p *pipeView) Resigned(loser Stone) {
+	p.emit(pipeEvent{Event: "end", Result: resignResult(loser), Winner: colorKey(loser.Opponent())})
+}
+
+func (p *pipeView) Undone(moves int) {
+	p.moves = max(p.moves-moves, 0)
+	p.emit(pipeEvent{Event: "undo", Number: p.moves})
+}
+
+func (p *pipeView) Close() {}
+
+// colorKey is the lower-case colour name used in machine-readable output,
+// or "" for Empty.
+func colorKey(s Stone) string {
+	switch s {
+	case Black:
+		return "black"
+	case White:
+		return "white"
+	}
+	return ""
+}
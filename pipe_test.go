@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPipeProtocol(t *testing.T) {
+	in := strings.NewReader("{\"move\":\"2 2\"}\n\nnot json\n{\"mvoe\":\"1 1\"}\n{\"move\":\"pass\"}\n")
+	var out bytes.Buffer
+	p := newPipeView(in, &out)
+
+	b := NewBoard(5)
+	p.Show(b)
+	if move, ok := p.ReadMove(Black); !ok || move != "2 2" {
+		t.Fatalf("first move = %q, %v", move, ok)
+	}
+	p.Played(Black, 2*5+2)
+	if move, ok := p.ReadMove(White); !ok || move != "pass" {
+		t.Fatalf("second move = %q, %v", move, ok)
+	}
+	p.Played(White, PassMove)
+	if _, ok := p.ReadMove(Black); ok {
+		t.Fatal("read past end of input")
+	}
+	p.Result(White, 6.5)
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e pipeEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("output line %q: %v", line, err)
+		}
+		events = append(events, e.Event)
+	}
+	want := "start turn move turn error error move turn end"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("events %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), `"row":2,"col":2`) || !strings.Contains(out.String(), `"result":"W+6.5"`) {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+// Playout policy: how the random playouts that evaluate the search's
+// leaves choose their moves and when they stop. Ending a lost cause early
+// and cutting off long playouts buy playouts per second; which eyes are
+// kept decides how well playouts see life and death. This file is part
+// of the core (see board.go).
+
+import "math/rand"
+
+// EyeRule says which empty points playouts keep the side to move from
+// filling.
+type EyeRule int
+
+const (
+	EyesSimple EyeRule = iota // points whose four neighbours are the player's stones
+	EyesTrue                  // those, unless the diagonals make them false eyes
+	EyesNone                  // none: playouts fill eyes too
+)
+
+// PlayoutPolicy tunes the engine's random playouts. The zero value plays
+// every playout to the end, keeping simple eyes.
+type PlayoutPolicy struct {
+	// Mercy ends a playout once one side has captured this many stones
+	// more than the other, and gives it the game; 0 never does.
+	Mercy int
+	// MaxLength is the number of moves after which a playout is cut off
+	// and scored as it stands; 0 means three per point.
+	MaxLength int
+	Eyes      EyeRule
+}
+
+// Play plays random moves on b until both sides pass, the mercy rule ends
+// the game or MaxLength moves have been played. It returns the side the
+// mercy rule gave the game to, or Empty when b is to be scored.
+func (p PlayoutPolicy) Play(b *Board, rng *rand.Rand) Stone {
+	limit := p.MaxLength
+	if limit <= 0 {
+		limit = 3 * b.size * b.size
+	}
+	// Stones on the board stand for captures, relative to the start so
+	// that handicap stones do not count; counting them every size moves
+	// keeps the check cheap.
+	lead := b.stoneCount(Black) - b.stoneCount(White)
+	for moves := 0; !b.IsGameOver() && moves < limit; moves++ {
+		if p.Mercy > 0 && moves > 0 && moves%b.size == 0 {
+			switch d := b.stoneCount(Black) - b.stoneCount(White) - lead; {
+			case d >= p.Mercy:
+				return Black
+			case -d >= p.Mercy:
+				return White
+			}
+		}
+		candidates := p.candidates(b)
+		played := false
+		for len(candidates) > 0 {
+			j := rng.Intn(len(candidates))
+			if b.Play(candidates[j]) {
+				played = true
+				break
+			}
+			candidates[j] = candidates[len(candidates)-1]
+			candidates = candidates[:len(candidates)-1]
+		}
+		if !played {
+			b.Pass()
+		}
+	}
+	return Empty
+}
+
+// candidates lists the moves a playout chooses among.
+func (p PlayoutPolicy) candidates(b *Board) []int {
+	if p.Eyes == EyesSimple {
+		return b.candidates()
+	}
+	var moves []int
+	for row := 0; row < b.size; row++ {
+		for col := 0; col < b.size; col++ {
+			if b.grid[row][col] == Empty && (p.Eyes == EyesNone || !b.isTrueEye(row, col, b.turn)) && b.IsLegal(row*b.size+col) {
+				moves = append(moves, row*b.size+col)
+			}
+		}
+	}
+	return moves
+}
+
+// isTrueEye reports whether the empty point is an eye of s that the
+// opponent cannot make false: surrounded by s, with at most one of its
+// diagonal points held by the opponent, or none on the edge.
+func (b *Board) isTrueEye(row, col int, s Stone) bool {
+	if !b.isEye(row, col, s) {
+		return false
+	}
+	bad, edge := 0, false
+	for _, d := range [][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}} {
+		r, c := row+d[0], col+d[1]
+		if !b.isInBounds(r, c) {
+			edge = true
+		} else if b.grid[r][c] == s.Opponent() {
+			bad++
+		}
+	}
+	if edge {
+		return bad == 0
+	}
+	return bad < 2
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTrueEyes(t *testing.T) {
+	// Black's eye at the corner is false while White holds its diagonal.
+	b := NewBoard(5)
+	b.grid[0][1], b.grid[1][0], b.grid[1][1] = Black, Black, White
+	if !b.isEye(0, 0, Black) || b.isTrueEye(0, 0, Black) {
+		t.Error("corner eye with an opponent on the diagonal is true")
+	}
+	b.grid[1][1] = Black
+	if !b.isTrueEye(0, 0, Black) {
+		t.Error("corner eye is false")
+	}
+}
+
+func TestPlayoutPolicy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := NewBoard(9)
+	if w := (PlayoutPolicy{MaxLength: 10}).Play(b, rng); w != Empty || b.stoneCount(Black)+b.stoneCount(White) > 10 {
+		t.Errorf("after 10 moves: winner %v, %d stones", w, b.stoneCount(Black)+b.stoneCount(White))
+	}
+	mercy := 0
+	for i := 0; i < 20; i++ {
+		if (PlayoutPolicy{Mercy: 3}).Play(NewBoard(9), rng) != Empty {
+			mercy++
+		}
+	}
+	if mercy == 0 {
+		t.Error("the mercy rule ended no playout")
+	}
+}
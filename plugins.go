@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"plugin"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["plugins"] = runPlugins
+	for _, p := range builtinPlugins {
+		if err := RegisterPlugin(p); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Plugin adds rules to games without changing the program: a move
+// validator to forbid moves the basic rules allow, a scorer to count the
+// final position, and a win condition to end the game early. Any of the
+// three may be nil. Plugins are chosen by name with -rules.
+//
+// Third parties register plugins from Go with RegisterPlugin, or ship them
+// as Go plugins (.so files) loaded with -plugin. A plugin cannot import
+// this program's types, so a .so file exports
+//
+//	func GogamePlugin() map[string]any
+//
+// returning "name" and "description" strings and any of these functions,
+// over boards given as the size and the stones row by row (0 empty, 1
+// black, 2 white):
+//
+//	"validate":  func(size int, grid []int, toMove, move int) error
+//	"score":     func(size int, grid []int, komi float64) (winner int, margin float64)
+//	"game_over": func(size int, grid []int, captures [3]int) (over bool, winner int, margin float64, reason string)
+//
+// Moves are indexes row*size+col, or -1 for a pass, and captures counts
+// the stones each colour has taken.
+type Plugin struct {
+	Name        string
+	Description string
+	Source      string // "built-in" or the .so file
+
+	Validate func(game *GameLog, move int) error
+	Score    func(game *GameLog, komi float64) (winner Stone, margin float64)
+	GameOver func(game *GameLog) (over bool, winner Stone, margin float64, reason string)
+	// Explain optionally walks through Score's count, one step per
+	// string, shown at the end of the game.
+	Explain func(game *GameLog, komi float64) []string
+}
+
+var plugins = map[string]Plugin{}
+
+// RegisterPlugin makes p available to -rules. Names must be unique.
+func RegisterPlugin(p Plugin) error {
+	if p.Name == "" {
+		return errors.New("plugin has no name")
+	}
+	if _, ok := plugins[p.Name]; ok {
+		return fmt.Errorf("plugin %q is already registered", p.Name)
+	}
+	if p.Source == "" {
+		p.Source = "built-in"
+	}
+	plugins[p.Name] = p
+	return nil
+}
+
+var builtinPlugins = []Plugin{
+	{
+		Name:        "area",
+		Description: "area scoring: stones plus surrounded territory, less komi (the default)",
+		Score:       func(game *GameLog, komi float64) (Stone, float64) { return game.Board().Winner(komi) },
+	},
+	{
+		Name:        "stones",
+		Description: "stone scoring: only stones on the board count, as in ancient Chinese rules",
+		Score:       stoneScore,
+	},
+	{
+		Name:        "superko",
+		Description: "positional superko: no move may repeat an earlier position",
+		Validate:    validateSuperko,
+	},
+	{
+		Name:        "capture-go",
+		Description: "capture go for beginners: the first capture wins",
+		GameOver: func(game *GameLog) (bool, Stone, float64, string) {
+			for _, c := range []Stone{Black, White} {
+				if n := game.Captures(c); n > 0 {
+					return true, c, float64(n), fmt.Sprintf("%s captured first", c)
+				}
+			}
+			return false, Empty, 0, ""
+		},
+	},
+}
+
+func stoneScore(game *GameLog, komi float64) (Stone, float64) {
+	diff := -komi
+	b := game.Board()
+	for row := 0; row < b.Size(); row++ {
+		for col := 0; col < b.Size(); col++ {
+			switch b.At(row, col) {
+			case Black:
+				diff++
+			case White:
+				diff--
+			}
+		}
+	}
+	switch {
+	case diff > 0:
+		return Black, diff
+	case diff < 0:
+		return White, -diff
+	}
+	return Empty, 0
+}
+
+// RepetitionError is the superko validator's answer to a move that
+// brings back the position after move After.
+type RepetitionError struct{ After int }
+
+func (e *RepetitionError) Error() string {
+	return fmt.Sprintf("superko: the move repeats the position after move %d", e.After)
+}
+
+func validateSuperko(game *GameLog, move int) error {
+	// Illegal moves repeat nothing; the basic rules reject them anyway.
+	if after, ok := game.Repeats(move); ok {
+		return &RepetitionError{after}
+	}
+	return nil
+}
+
+// Plugins lists the registered plugins by name.
+func Plugins() []Plugin {
+	list := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// LoadPlugin opens a Go plugin file and registers the plugin it exports.
+func LoadPlugin(path string) error {
+	so, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := so.Lookup("GogamePlugin")
+	if err != nil {
+		return err
+	}
+	export, ok := sym.(func() map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: GogamePlugin is %T, want func() map[string]any", path, sym)
+	}
+	p, err := adaptPlugin(export())
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	p.Source = path
+	return RegisterPlugin(p)
+}
+
+// adaptPlugin turns the map a .so plugin exports into a Plugin.
+func adaptPlugin(m map[string]any) (Plugin, error) {
+	var p Plugin
+	var ok bool
+	if p.Name, ok = m["name"].(string); !ok {
+		return p, errors.New(`"name" is missing or not a string`)
+	}
+	p.Description, _ = m["description"].(string)
+	for key, f := range m {
+		switch key {
+		case "name", "description":
+		case "validate":
+			validate, ok := f.(func(int, []int, int, int) error)
+			if !ok {
+				return p, fmt.Errorf(`"validate" is %T`, f)
+			}
+			p.Validate = func(game *GameLog, move int) error {
+				b := game.Board()
+				return validate(b.Size(), flatGrid(b), int(b.Turn()), move)
+			}
+		case "score":
+			score, ok := f.(func(int, []int, float64) (int, float64))
+			if !ok {
+				return p, fmt.Errorf(`"score" is %T`, f)
+			}
+			p.Score = func(game *GameLog, komi float64) (Stone, float64) {
+				b := game.Board()
+				winner, margin := score(b.Size(), flatGrid(b), komi)
+				return Stone(winner), margin
+			}
+		case "game_over":
+			gameOver, ok := f.(func(int, []int, [3]int) (bool, int, float64, string))
+			if !ok {
+				return p, fmt.Errorf(`"game_over" is %T`, f)
+			}
+			p.GameOver = func(game *GameLog) (bool, Stone, float64, string) {
+				b := game.Board()
+				captures := [3]int{0, game.Captures(Black), game.Captures(White)}
+				over, winner, margin, reason := gameOver(b.Size(), flatGrid(b), captures)
+				return over, Stone(winner), margin, reason
+			}
+		default:
+			return p, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return p, nil
+}
+
+func flatGrid(b BoardView) []int {
+	grid := make([]int, 0, b.Size()*b.Size())
+	for row := 0; row < b.Size(); row++ {
+		for col := 0; col < b.Size(); col++ {
+			grid = append(grid, int(b.At(row, col)))
+		}
+	}
+	return grid
+}
+
+// Ruleset is the plugins chosen for a game. With none it plays the basic
+// rules with area scoring.
+type Ruleset []Plugin
+
+// NewRuleset looks up the named plugins.
+func NewRuleset(names []string) (Ruleset, error) {
+	var rules Ruleset
+	for _, name := range names {
+		p, ok := plugins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rules plugin %q (see the plugins command)", name)
+		}
+		rules = append(rules, p)
+	}
+	return rules, nil
+}
+
+// Validate checks move against every validator.
+func (r Ruleset) Validate(game *GameLog, move int) error {
+	for _, p := range r {
+		if p.Validate != nil {
+			if err := p.Validate(game, move); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExplainMove explains move, a point, played by the side to move under
+// the basic rules and then the validators.
+func (r Ruleset) ExplainMove(game *GameLog, move int) MoveExplanation {
+	b := game.board
+	row, col := move/b.size, move%b.size
+	if move < 0 {
+		row = -1
+	}
+	x := b.ExplainMove(row, col, b.turn)
+	if !x.Legal() {
+		return x
+	}
+	var repeated *RepetitionError
+	switch err := r.Validate(game, move); {
+	case errors.As(err, &repeated):
+		x.Verdict, x.KoMove = MoveKo, repeated.After
+	case err != nil:
+		x.Verdict, x.Reason = MoveForbidden, err.Error()
+	}
+	return x
+}
+
+// scorer is the last plugin given with a scorer, or nil for area scoring.
+func (r Ruleset) scorer() *Plugin {
+	for i := len(r) - 1; i >= 0; i-- {
+		if r[i].Score != nil {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// Score counts the final position with the last scorer given, or area
+// scoring.
+func (r Ruleset) Score(game *GameLog, komi float64) (Stone, float64) {
+	if p := r.scorer(); p != nil {
+		return p.Score(game, komi)
+	}
+	return game.Board().Winner(komi)
+}
+
+// Explain walks through the count, if the scorer can.
+func (r Ruleset) Explain(game *GameLog, komi float64) []string {
+	if p := r.scorer(); p != nil && p.Explain != nil {
+		return p.Explain(game, komi)
+	}
+	return nil
+}
+
+// GameOver reports the first win condition that ends the game.
+func (r Ruleset) GameOver(game *GameLog) (over bool, winner Stone, margin float64, reason string) {
+	for _, p := range r {
+		if p.GameOver != nil {
+			if over, winner, margin, reason = p.GameOver(game); over {
+				return
+			}
+		}
+	}
+	return false, Empty, 0, ""
+}
+
+// loadPlugins loads a comma-separated list of plugin files.
+func loadPlugins(paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			if err := LoadPlugin(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitNames splits a comma-separated -rules list.
+func splitNames(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func runPlugins(args []string) error {
+	fs := flag.NewFlagSet("plugins", flag.ExitOnError)
+	load := fs.String("plugin", "", "comma-separated Go plugin files to load first")
+	fs.Parse(args)
+	if err := loadPlugins(*load); err != nil {
+		return err
+	}
+	for _, p := range Plugins() {
+		var kinds []string
+		if p.Validate != nil {
+			kinds = append(kinds, "validator")
+		}
+		if p.Score != nil {
+			kinds = append(kinds, "scorer")
+		}
+		if p.GameOver != nil {
+			kinds = append(kinds, "win condition")
+		}
+		fmt.Printf("%-12s %-24s %s\n", p.Name, strings.Join(kinds, ", "), p.Description)
+		if p.Source != "built-in" {
+			fmt.Printf("%12s from %s\n", "", p.Source)
+		}
+	}
+	return nil
+}
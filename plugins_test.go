@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRulesPlugins(t *testing.T) {
+	rules, err := NewRuleset([]string{"superko", "capture-go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGameLog(NewBoard(5))
+	// A ko: Black takes at 1 2, and White may not take straight back.
+	for _, m := range []int{1, 2, 5, 12, 11, 8, 24, 6} {
+		if err := game.Play(m); err != nil {
+			t.Fatalf("move %d: %v", m, err)
+		}
+	}
+	if over, _, _, _ := rules.GameOver(game); over {
+		t.Fatal("game over before a capture")
+	}
+	if err := rules.Validate(game, 7); err != nil {
+		t.Fatal(err)
+	}
+	game.Play(7)
+	if err := rules.Validate(game, 6); err == nil {
+		t.Error("superko allowed retaking the ko")
+	}
+	if err := rules.Validate(game, 20); err != nil {
+		t.Error(err)
+	}
+	over, winner, margin, _ := rules.GameOver(game)
+	if !over || winner != Black || margin != 1 {
+		t.Errorf("capture-go: over %v, winner %s, margin %g", over, winner, margin)
+	}
+
+	if _, err := NewRuleset([]string{"no-such-rules"}); err == nil {
+		t.Error("unknown plugin accepted")
+	}
+	stones, _ := NewRuleset([]string{"stones"})
+	b := NewBoard(5)
+	b.grid[2][2] = White
+	if winner, margin := stones.Score(NewGameLog(b), 0.5); winner != White || margin != 1.5 {
+		t.Errorf("stone scoring: %s by %g", winner, margin)
+	}
+}
+
+func TestAdaptPlugin(t *testing.T) {
+	p, err := adaptPlugin(map[string]any{
+		"name": "no-center",
+		"validate": func(size int, grid []int, toMove, move int) error {
+			if move == size*size/2 {
+				return errors.New("no center")
+			}
+			return nil
+		},
+		"score": func(size int, grid []int, komi float64) (int, float64) { return 2, float64(len(grid)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewGameLog(NewBoard(5))
+	if p.Validate(game, 12) == nil || p.Validate(game, 0) != nil {
+		t.Error("validator not adapted")
+	}
+	if winner, margin := p.Score(NewGameLog(NewBoard(5)), 0); winner != White || margin != 25 {
+		t.Errorf("scorer gave %s by %g", winner, margin)
+	}
+	if _, err := adaptPlugin(map[string]any{"name": "x", "score": func() {}}); err == nil {
+		t.Error("wrong function type accepted")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "context"
+
+// ponderer thinks on the opponent's time. It guesses the opponent's move
+// with a short search and then searches its own reply to the guess; if
+// the opponent does play the guess, the reply is ready at once.
+type ponderer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	after  uint64 // hash of the position after the guessed move
+	reply  int
+	ready  bool // the reply search ran to completion
+}
+
+// ponderEngine copies e's settings into an engine of its own, so pondering
+// never touches the tree of the engine that plays.
+func ponderEngine(e *Engine) *Engine {
+	p := NewEngine(e.Playouts, e.Komi)
+	p.Threads, p.BatchSize, p.MaxMemory, p.Evaluator = e.Threads, e.BatchSize, e.MaxMemory, e.Evaluator
+	p.Noise, p.Choice, p.Playout = e.Noise, e.Choice, e.Playout
+	return p
+}
+
+// startPonder begins pondering on b, where the opponent is to move.
+func startPonder(e *Engine, view BoardView) *ponderer {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ponderer{cancel: cancel, done: make(chan struct{})}
+	b := view.Copy()
+	go func() {
+		defer close(p.done)
+		guesser := ponderEngine(e)
+		guesser.Playouts = max(e.Playouts/4, 1)
+		row, col, pass, err := guesser.GenMove(ctx, b)
+		if err != nil {
+			return
+		}
+		guess := PassMove
+		if !pass {
+			guess = row*b.size + col
+		}
+		if !b.Play(guess) {
+			return
+		}
+		p.after = b.Hash()
+		row, col, pass, err = ponderEngine(e).GenMove(ctx, b)
+		if err != nil {
+			return
+		}
+		if p.reply = PassMove; !pass {
+			p.reply = row*b.size + col
+		}
+		p.ready = true
+	}()
+	return p
+}
+
+// stop ends pondering and waits for it. It is safe on a nil ponderer.
+func (p *ponderer) stop() {
+	if p != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+// hit returns the pondered reply if the opponent played the guessed move
+// and the reply search finished, leaving b.
+func (p *ponderer) hit(b BoardView) (move int, ok bool) {
+	if p == nil || !p.ready || b.Hash() != p.after {
+		return PassMove, false
+	}
+	return p.reply, true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["presets"] = runPresets
+}
+
+// Starting positions for -preset, one SGF file each. GN is the preset's
+// name, C its description and PL the side to move.
+//
+//go:embed presets/*.sgf
+var presetFiles embed.FS
+
+// Preset is a named starting position.
+type Preset struct {
+	Name        string
+	Description string
+	Board       *Board
+}
+
+// LoadPresets parses every embedded preset, sorted by name.
+func LoadPresets() ([]Preset, error) {
+	entries, err := presetFiles.ReadDir("presets")
+	if err != nil {
+		return nil, err
+	}
+	var presets []Preset
+	for _, e := range entries {
+		data, err := presetFiles.ReadFile(path.Join("presets", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		games, err := ParseSGF(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("preset %s: %v", e.Name(), err)
+		}
+		root := games[0]
+		g, err := RecordFromSGF(root)
+		if err != nil {
+			return nil, fmt.Errorf("preset %s: %v", e.Name(), err)
+		}
+		b, err := g.Replay(len(g.Moves))
+		if err != nil {
+			return nil, fmt.Errorf("preset %s: %v", e.Name(), err)
+		}
+		switch strings.ToUpper(root.Get("PL")) {
+		case "B":
+			b.turn = Black
+		case "W":
+			b.turn = White
+		}
+		name := root.Get("GN")
+		if name == "" {
+			name = strings.TrimSuffix(e.Name(), ".sgf")
+		}
+		presets = append(presets, Preset{Name: name, Description: root.Get("C"), Board: b})
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets, nil
+}
+
+// FindPreset returns the preset called name.
+func FindPreset(name string) (*Preset, error) {
+	presets, err := LoadPresets()
+	if err != nil {
+		return nil, err
+	}
+	for i := range presets {
+		if presets[i].Name == name {
+			return &presets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown preset %q (see: gogame presets)", name)
+}
+
+func runPresets(args []string) error {
+	if len(args) == 1 {
+		p, err := FindPreset(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.Description)
+		p.Board.Display()
+		return nil
+	}
+	presets, err := LoadPresets()
+	if err != nil {
+		return err
+	}
+	for _, p := range presets {
+		fmt.Printf("%-16s %2dx%-2d  %s\n", p.Name, p.Board.size, p.Board.size, p.Description)
+	}
+	return nil
+}
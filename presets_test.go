@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestPresets(t *testing.T) {
+	presets, err := LoadPresets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, p := range presets {
+		if seen[p.Name] {
+			t.Errorf("duplicate preset %s", p.Name)
+		}
+		seen[p.Name] = true
+		if p.Description == "" || p.Board.countStones(Black)+p.Board.countStones(White) == 0 {
+			t.Errorf("preset %s has no description or no stones", p.Name)
+		}
+	}
+	p, err := FindPreset("nine-stones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Board.size != 19 || p.Board.countStones(Black) != 9 || p.Board.turn != White {
+		t.Errorf("nine-stones: %dx%d, %d black stones, %v to move", p.Board.size, p.Board.size, p.Board.countStones(Black), p.Board.turn)
+	}
+	if _, err := FindPreset("no-such-preset"); err == nil {
+		t.Error("found a preset that does not exist")
+	}
+}
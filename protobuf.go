@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol buffers encoding of GameRecord following gamerecord.proto. The
+// wire format is written by hand so the game has no generated code or
+// third-party dependencies; unknown fields are skipped when decoding, so
+// newer schema revisions stay readable.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wire))
+}
+
+func (w *protoWriter) uint(field int, v uint64) {
+	if v != 0 {
+		w.tag(field, wireVarint)
+		w.buf = binary.AppendUvarint(w.buf, v)
+	}
+}
+
+func (w *protoWriter) bool(field int, v bool) {
+	if v {
+		w.uint(field, 1)
+	}
+}
+
+func (w *protoWriter) double(field int, v float64) {
+	if v != 0 {
+		w.tag(field, wireFixed64)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+	}
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	if s != "" {
+		w.bytes(field, []byte(s))
+	}
+}
+
+// protoField is one decoded field: num and either the varint/fixed value
+// or the length-delimited payload.
+type protoField struct {
+	num   int
+	wire  int
+	value uint64
+	data  []byte
+}
+
+func (f protoField) double() float64 {
+	return math.Float64frombits(f.value)
+}
+
+func readProto(data []byte, fn func(f protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("protobuf: bad field key")
+		}
+		data = data[n:]
+		f := protoField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			f.value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("protobuf: bad varint")
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errors.New("protobuf: truncated fixed64")
+			}
+			f.value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errors.New("protobuf: truncated fixed32")
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errors.New("protobuf: truncated field")
+			}
+			f.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", f.wire)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalProtoMove(m RecordedMove) []byte {
+	var w protoWriter
+	w.uint(1, uint64(m.Color))
+	if m.Move == PassMove {
+		w.bool(3, true)
+	} else {
+		w.uint(2, uint64(m.Move))
+	}
+	w.string(4, m.Comment)
+	return w.buf
+}
+
+func unmarshalProtoMove(data []byte) (RecordedMove, error) {
+	var m RecordedMove
+	pass := false
+	err := readProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			m.Color = Stone(f.value)
+		case 2:
+			m.Move = int(f.value)
+		case 3:
+			pass = f.value != 0
+		case 4:
+			m.Comment = string(f.data)
+		}
+		return nil
+	})
+	if pass {
+		m.Move = PassMove
+	}
+	return m, err
+}
+
+func marshalProtoAnalysis(a MoveAnalysis) []byte {
+	var w protoWriter
+	w.uint(1, uint64(a.MoveNumber))
+	w.double(2, a.WinRate)
+	w.uint(3, uint64(a.Playouts))
+	for _, c := range a.Candidates {
+		var cw protoWriter
+		if c.Move == PassMove {
+			cw.bool(2, true)
+		} else {
+			cw.uint(1, uint64(c.Move))
+		}
+		cw.uint(3, uint64(c.Visits))
+		cw.double(4, c.WinRate)
+		w.bytes(4, cw.buf)
+	}
+	w.double(5, a.ScoreMean)
+	w.double(6, a.ScoreStdev)
+	return w.buf
+}
+
+func unmarshalProtoAnalysis(data []byte) (MoveAnalysis, error) {
+	var a MoveAnalysis
+	err := readProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			a.MoveNumber = int(f.value)
+		case 2:
+			a.WinRate = f.double()
+		case 3:
+			a.Playouts = int(f.value)
+		case 4:
+			var c CandidateMove
+			pass := false
+			err := readProto(f.data, func(f protoField) error {
+				switch f.num {
+				case 1:
+					c.Move = int(f.value)
+				case 2:
+					pass = f.value != 0
+				case 3:
+					c.Visits = int(f.value)
+				case 4:
+					c.WinRate = f.double()
+				}
+				return nil
+			})
+			if pass {
+				c.Move = PassMove
+			}
+			a.Candidates = append(a.Candidates, c)
+			return err
+		case 5:
+			a.ScoreMean = f.double()
+		case 6:
+			a.ScoreStdev = f.double()
+		}
+		return nil
+	})
+	return a, err
+}
+
+// MarshalProto encodes g as a gogame.v1.Game message.
+func (g *GameRecord) MarshalProto() []byte {
+	var w protoWriter
+	w.uint(1, uint64(g.Size))
+	w.double(2, g.Komi)
+	w.uint(3, uint64(g.Handicap))
+	var black, white protoWriter
+	black.string(1, g.Black)
+	black.string(2, g.BlackRank)
+	white.string(1, g.White)
+	white.string(2, g.WhiteRank)
+	w.bytes(4, black.buf)
+	w.bytes(5, white.buf)
+	w.string(6, g.Result)
+	w.string(7, g.Date)
+	for _, m := range g.Setup {
+		w.bytes(8, marshalProtoMove(m))
+	}
+	for _, m := range g.Moves {
+		w.bytes(9, marshalProtoMove(m))
+	}
+	for _, a := range g.Analysis {
+		w.bytes(10, marshalProtoAnalysis(a))
+	}
+	return w.buf
+}
+
+// UnmarshalProtoGame decodes a gogame.v1.Game message.
+func UnmarshalProtoGame(data []byte) (*GameRecord, error) {
+	g := &GameRecord{}
+	player := func(data []byte, name, rank *string) error {
+		return readProto(data, func(f protoField) error {
+			switch f.num {
+			case 1:
+				*name = string(f.data)
+			case 2:
+				*rank = string(f.data)
+			}
+			return nil
+		})
+	}
+	err := readProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			g.Size = int(f.value)
+		case 2:
+			g.Komi = f.double()
+		case 3:
+			g.Handicap = int(f.value)
+		case 4:
+			return player(f.data, &g.Black, &g.BlackRank)
+		case 5:
+			return player(f.data, &g.White, &g.WhiteRank)
+		case 6:
+			g.Result = string(f.data)
+		case 7:
+			g.Date = string(f.data)
+		case 8, 9:
+			m, err := unmarshalProtoMove(f.data)
+			if f.num == 8 {
+				g.Setup = append(g.Setup, m)
+			} else {
+				g.Moves = append(g.Moves, m)
+			}
+			return err
+		case 10:
+			a, err := unmarshalProtoAnalysis(f.data)
+			g.Analysis = append(g.Analysis, a)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := g.checkProto(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// checkProto rejects a decoded game that no board could hold, as the codec
+// does, so that a bad message fails here rather than when it is replayed.
+func (g *GameRecord) checkProto() error {
+	if g.Size < 1 || g.Size > MaxBoardSize {
+		return fmt.Errorf("protobuf: invalid board size %d", g.Size)
+	}
+	if g.Handicap < 0 || g.Handicap > g.Size*g.Size {
+		return fmt.Errorf("protobuf: invalid handicap %d", g.Handicap)
+	}
+	onBoard := func(m int) bool { return m == PassMove || m >= 0 && m < g.Size*g.Size }
+	for i, m := range g.Setup {
+		if m.Color != Black && m.Color != White || m.Move == PassMove || !onBoard(m.Move) {
+			return fmt.Errorf("protobuf: invalid setup stone %d", i+1)
+		}
+	}
+	for i, m := range g.Moves {
+		if m.Color != Black && m.Color != White {
+			return fmt.Errorf("protobuf: move %d has invalid color %d", i+1, m.Color)
+		}
+		if !onBoard(m.Move) {
+			return fmt.Errorf("protobuf: move %d is off the %dx%d board", i+1, g.Size, g.Size)
+		}
+	}
+	for _, a := range g.Analysis {
+		for _, c := range a.Candidates {
+			if !onBoard(c.Move) {
+				return fmt.Errorf("protobuf: analysis of move %d has a candidate off the board", a.MoveNumber)
+			}
+		}
+	}
+	return nil
+}
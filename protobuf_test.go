@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGameProtoRoundTrip(t *testing.T) {
+	g := &GameRecord{
+		Size: 19, Komi: 6.5, Handicap: 2,
+		Black: "Honinbo Shusaku", BlackRank: "4d", White: "Gennan Inseki", WhiteRank: "8d",
+		Result: "B+2", Date: "1846-09-11",
+		Setup: []RecordedMove{{Color: Black, Move: 3*19 + 15}, {Color: Black, Move: 15*19 + 3}},
+		Moves: []RecordedMove{{Color: White, Move: 0, Comment: "ear-reddening"}, {Color: Black, Move: PassMove}},
+		Analysis: []MoveAnalysis{{
+			MoveNumber: 1, WinRate: 0.42, Playouts: 1000,
+			Candidates: []CandidateMove{{Move: 60, Visits: 700, WinRate: 0.43}, {Move: PassMove, Visits: 1}},
+		}},
+	}
+	got, err := UnmarshalProtoGame(g.MarshalProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, g) {
+		t.Errorf("round trip changed the game:\n got %+v\nwant %+v", got, g)
+	}
+}
+
+func TestProtoSkipsUnknownFields(t *testing.T) {
+	var w protoWriter
+	w.uint(1, 9)
+	w.string(99, "from a newer schema")
+	w.double(2, 7)
+	g, err := UnmarshalProtoGame(w.buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Size != 9 || g.Komi != 7 {
+		t.Errorf("got size %d komi %g", g.Size, g.Komi)
+	}
+}
+
+func TestProtoRejectsBadGames(t *testing.T) {
+	for name, g := range map[string]*GameRecord{
+		"size 0":           {Size: 0},
+		"size 60":          {Size: 60},
+		"move off board":   {Size: 9, Moves: []RecordedMove{{Color: Black, Move: 81}}},
+		"setup pass":       {Size: 9, Setup: []RecordedMove{{Color: Black, Move: PassMove}}},
+		"empty color":      {Size: 9, Moves: []RecordedMove{{Color: Empty, Move: 40}}},
+		"bad candidate":    {Size: 9, Analysis: []MoveAnalysis{{Candidates: []CandidateMove{{Move: 100}}}}},
+		"handicap too big": {Size: 2, Handicap: 5},
+	} {
+		if _, err := UnmarshalProtoGame(g.MarshalProto()); err == nil {
+			t.Errorf("%s: decoded without an error", name)
+		}
+	}
+}
+
+func TestProtoFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.pb")
+	g := &GameRecord{Size: 9, Komi: 7, Black: "B", White: "W", Result: "W+R",
+		Moves: []RecordedMove{{Color: Black, Move: 40}, {Color: White, Move: PassMove}}}
+	if err := WriteSGFFile(path, []*SGFNode{g.SGF()}); err != nil {
+		t.Fatal(err)
+	}
+	games, err := ReadSGFFile(path)
+	if err != nil || len(games) != 1 {
+		t.Fatalf("ReadSGFFile = %d games, %v", len(games), err)
+	}
+	got, err := RecordFromSGF(games[0])
+	if err != nil || got.Size != 9 || got.Komi != 7 || got.Result != "W+R" || !reflect.DeepEqual(got.Moves, g.Moves) {
+		t.Errorf("read back %+v, %v", got, err)
+	}
+	if err := WriteSGFFile(path, []*SGFNode{g.SGF(), g.SGF()}); err == nil {
+		t.Error("wrote two games to one .pb file")
+	}
+	if err := os.WriteFile(path, (&GameRecord{Size: 9, Moves: []RecordedMove{{Color: Black, Move: 99}}}).MarshalProto(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSGFFile(path); err == nil {
+		t.Error("read a move off the board")
+	}
+}
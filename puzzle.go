@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Puzzles are life-and-death problems in the usual SGF form: the position
+// in setup stones, PL for the side to move, and the tree of moves, where
+// a line is a solution if it ends in a node whose comment says RIGHT (or
+// starts with "Correct"), as on goproblems.com. Every other line is a
+// wrong answer with its refutation.
+//
+// The puzzles command serves them as JSON over HTTP, so that a website
+// can show a problem and leave the checking to the server, which keeps
+// the answers:
+//
+//	GET  /puzzles            [{"id": "ld1", "title": "...", "size": 9, "to_move": "black"}, ...]
+//	GET  /puzzles/ID         the problem: its size, side to move, black and white stones
+//	POST /puzzles/ID/check   {"moves": ["C3", "B2", "D2"]}  ->  PuzzleAnswer
+//
+// Moves are GTP vertices. A check sends the whole line so far, the
+// player's moves and the replies the server gave, and gets the next reply.
+// openapi.json describes the API, and APIClient calls it.
+//
+// A problem's category, such as life-and-death, tesuji, endgame or opening,
+// is its genre (GE) or else the name of the directory its file is in, so a
+// library sorted into directories needs no editing.
+
+func init() {
+	commands["puzzles"] = runPuzzles
+}
+
+// Puzzle is one problem.
+type Puzzle struct {
+	ID       string
+	Title    string
+	Comment  string
+	Category string // see puzzleCategory
+	start    *Board
+	tree     *SGFNode // the root; its children are the first moves
+}
+
+// PuzzleFromSGF reads a problem from a game tree.
+func PuzzleFromSGF(id string, root *SGFNode) (*Puzzle, error) {
+	rec, err := RecordFromSGF(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.Children) == 0 {
+		return nil, fmt.Errorf("puzzle %s has no moves", id)
+	}
+	p := &Puzzle{ID: id, Title: root.Get("GN"), Comment: root.Get("C"), start: rec.Start(), tree: root}
+	switch strings.ToUpper(root.Get("PL")) {
+	case "B":
+		p.start.turn = Black
+	case "W":
+		p.start.turn = White
+	}
+	if p.Title == "" {
+		p.Title = id
+	}
+	return p, nil
+}
+
+// PuzzleAnswer is the verdict on a line.
+type PuzzleAnswer struct {
+	Correct    bool     `json:"correct"`              // the player's moves are all on a solution
+	Solved     bool     `json:"solved"`               // and reach its end
+	Reply      string   `json:"reply,omitempty"`      // the opponent's answer, when the line goes on
+	Refutation []string `json:"refutation,omitempty"` // how the opponent punishes a wrong move
+	Comment    string   `json:"comment,omitempty"`    // the problem's comment on the last move
+}
+
+// isRight reports whether n ends a solution.
+func isRight(n *SGFNode) bool {
+	c := n.Get("C")
+	return strings.Contains(c, "RIGHT") || strings.HasPrefix(strings.ToLower(strings.TrimSpace(c)), "correct")
+}
+
+// leadsRight reports whether a solution goes through n.
+func leadsRight(n *SGFNode) bool {
+	if isRight(n) {
+		return true
+	}
+	for _, c := range n.Children {
+		if leadsRight(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// childPlaying is the child of n where color plays move, or nil.
+func childPlaying(n *SGFNode, color Stone, move, size int) *SGFNode {
+	id := map[Stone]string{Black: "B", White: "W"}[color]
+	for _, c := range n.Children {
+		if v := c.Values(id); len(v) > 0 {
+			if m, err := sgfPoint(v[0], size); err == nil && m == move {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// nodeMove is the move played in n, if any.
+func nodeMove(n *SGFNode, size int) (int, bool) {
+	for _, id := range []string{"B", "W"} {
+		if v := n.Values(id); len(v) > 0 {
+			m, err := sgfPoint(v[0], size)
+			return m, err == nil
+		}
+	}
+	return 0, false
+}
+
+// Check judges the line moves, which alternate between the player and
+// the replies Check gave, from the start. A player's move off the tree is
+// wrong; refute, if not nil, finds the opponent's answer to it.
+func (p *Puzzle) Check(moves []int, refute func(*Board) int) (PuzzleAnswer, error) {
+	b := p.start.Copy()
+	size := b.size
+	node := p.tree
+	for i, m := range moves {
+		color := b.turn
+		if !b.Play(m) {
+			return PuzzleAnswer{}, fmt.Errorf("move %d, %s, is illegal", i+1, gtpVertex(m, size))
+		}
+		child := childPlaying(node, color, m, size)
+		player := i%2 == 0
+		switch {
+		case child == nil && !player:
+			return PuzzleAnswer{}, fmt.Errorf("move %d, %s, is not a reply this puzzle gives", i+1, gtpVertex(m, size))
+		case child == nil:
+			ans := PuzzleAnswer{Comment: "Not a solution."}
+			if refute != nil {
+				if r := refute(b); r != PassMove {
+					ans.Refutation = []string{gtpVertex(r, size)}
+				}
+			}
+			return ans, nil
+		case player && !leadsRight(child):
+			ans := PuzzleAnswer{Comment: child.Get("C")}
+			for _, n := range child.MainLine()[1:] {
+				if r, ok := nodeMove(n, size); ok {
+					ans.Refutation = append(ans.Refutation, gtpVertex(r, size))
+				}
+			}
+			return ans, nil
+		}
+		node = child
+	}
+
+	ans := PuzzleAnswer{Correct: true, Comment: node.Get("C")}
+	if node == p.tree {
+		ans.Comment = ""
+	}
+	if isRight(node) || len(node.Children) == 0 {
+		ans.Solved = isRight(node)
+		return ans, nil
+	}
+	if len(moves)%2 == 1 {
+		reply := node.Children[0]
+		for _, c := range node.Children {
+			if leadsRight(c) {
+				reply = c
+				break
+			}
+		}
+		if r, ok := nodeMove(reply, size); ok {
+			ans.Reply = gtpVertex(r, size)
+		}
+	}
+	return ans, nil
+}
+
+// PuzzleInfo is a puzzle as listed, and with its stones when shown.
+type PuzzleInfo struct {
+	ID       string   `json:"id"`
+	Category string   `json:"category,omitempty"`
+	Title    string   `json:"title"`
+	Size     int      `json:"size"`
+	ToMove   string   `json:"to_move"`
+	Black    []string `json:"black,omitempty"`
+	White    []string `json:"white,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+func (p *Puzzle) info(stones bool) PuzzleInfo {
+	info := PuzzleInfo{ID: p.ID, Title: p.Title, Category: p.Category, Size: p.start.size, ToMove: colorKey(p.start.turn)}
+	if !stones {
+		return info
+	}
+	info.Comment = p.Comment
+	for m := 0; m < p.start.size*p.start.size; m++ {
+		switch p.start.grid[m/p.start.size][m%p.start.size] {
+		case Black:
+			info.Black = append(info.Black, gtpVertex(m, p.start.size))
+		case White:
+			info.White = append(info.White, gtpVertex(m, p.start.size))
+		}
+	}
+	return info
+}
+
+// puzzleHandler serves puzzles; refute answers moves off their trees.
+func puzzleHandler(puzzles []*Puzzle, refute func(*Board) int) http.Handler {
+	byID := map[string]*Puzzle{}
+	for _, p := range puzzles {
+		byID[p.ID] = p
+	}
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*") // for pages on other sites
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(w http.ResponseWriter, status int, err error) {
+		reply(w, status, map[string]string{"error": err.Error()})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /puzzles", func(w http.ResponseWriter, r *http.Request) {
+		list := []PuzzleInfo{}
+		for _, p := range puzzles {
+			list = append(list, p.info(false))
+		}
+		reply(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /puzzles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p := byID[r.PathValue("id")]
+		if p == nil {
+			fail(w, http.StatusNotFound, errors.New("no such puzzle"))
+			return
+		}
+		reply(w, http.StatusOK, p.info(true))
+	})
+	mux.HandleFunc("OPTIONS /puzzles/{id}/check", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	})
+	mux.HandleFunc("POST /puzzles/{id}/check", func(w http.ResponseWriter, r *http.Request) {
+		p := byID[r.PathValue("id")]
+		if p == nil {
+			fail(w, http.StatusNotFound, errors.New("no such puzzle"))
+			return
+		}
+		var req struct {
+			Moves []string `json:"moves"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		moves := make([]int, len(req.Moves))
+		for i, v := range req.Moves {
+			m, err := parseGTPVertex(v, p.start.size)
+			if err != nil {
+				fail(w, http.StatusBadRequest, err)
+				return
+			}
+			moves[i] = m
+		}
+		ans, err := p.Check(moves, refute)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		reply(w, http.StatusOK, ans)
+	})
+	return mux
+}
+
+// engineRefuter answers moves with the engine, one search at a time.
+func engineRefuter(playouts int, timeout time.Duration) func(*Board) int {
+	var mu sync.Mutex
+	e := NewEngine(playouts, 0)
+	return func(b *Board) int {
+		mu.Lock()
+		defer mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		row, col, pass, err := e.GenMove(ctx, b)
+		if pass || (err != nil && !errors.Is(err, context.DeadlineExceeded)) {
+			return PassMove
+		}
+		return row*b.size + col
+	}
+}
+
+// puzzleCategory is the category of a problem in the file at path: its
+// genre, in lower case with dashes for spaces, or its directory's name.
+func puzzleCategory(root *SGFNode, path string) string {
+	if ge := strings.TrimSpace(root.Get("GE")); ge != "" {
+		return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(ge, "_", " "))), "-")
+	}
+	dir := filepath.Base(filepath.Dir(path))
+	if dir == "." || dir == string(filepath.Separator) {
+		return ""
+	}
+	return strings.ToLower(dir)
+}
+
+// LoadPuzzles reads the problems in the SGF files at paths, or under them
+// for directories. A problem's ID is its file's name, with its number in
+// the file when there are several.
+func LoadPuzzles(paths []string) ([]*Puzzle, error) {
+	var puzzles []*Puzzle
+	for _, arg := range paths {
+		files := []string{arg}
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			rels, err := sgfFiles(arg, "")
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, rel := range rels {
+				files = append(files, filepath.Join(arg, rel))
+			}
+		}
+		for _, path := range files {
+			games, err := ReadSGFFile(path)
+			if err != nil {
+				return nil, err
+			}
+			base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			for i, root := range games {
+				id := base
+				if len(games) > 1 {
+					id = fmt.Sprintf("%s-%d", base, i+1)
+				}
+				p, err := PuzzleFromSGF(id, root)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", path, err)
+				}
+				p.Category = puzzleCategory(root, path)
+				puzzles = append(puzzles, p)
+			}
+		}
+	}
+	return puzzles, nil
+}
+
+func runPuzzles(args []string) error {
+	fs := flag.NewFlagSet("puzzles", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to serve the puzzle API on")
+	playouts := fs.Int("playouts", 2000, "engine playouts to refute a move the puzzle does not have")
+	timeout := fs.Duration("timeout", 2*time.Second, "longest time to spend refuting a move")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: puzzles [-addr :8080] problems.sgf|DIR ...")
+	}
+	puzzles, err := LoadPuzzles(fs.Args())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving %d puzzles on http://%s/puzzles\n", len(puzzles), *addr)
+	return http.ListenAndServe(*addr, puzzleHandler(puzzles, engineRefuter(*playouts, *timeout)))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPuzzleSGF = `(;GM[1]FF[4]SZ[9]GN[Corner]PL[B]AB[gg]AW[hh]
+  (;B[cc];W[bc];B[cb]C[RIGHT])
+  (;B[bb]C[Too slow.];W[cc];B[dc]))`
+
+func testPuzzle(t *testing.T) *Puzzle {
+	games, err := ParseSGF(strings.NewReader(testPuzzleSGF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := PuzzleFromSGF("corner", games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPuzzleCheck(t *testing.T) {
+	p := testPuzzle(t)
+	moves := func(vertices ...string) []int {
+		var ms []int
+		for _, v := range vertices {
+			m, err := parseGTPVertex(v, 9)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	refute := func(*Board) int { return 0 }
+	for _, c := range []struct {
+		moves []string
+		want  PuzzleAnswer
+	}{
+		{nil, PuzzleAnswer{Correct: true}},
+		{[]string{"C7"}, PuzzleAnswer{Correct: true, Reply: "B7"}},
+		{[]string{"C7", "B7", "C8"}, PuzzleAnswer{Correct: true, Solved: true, Comment: "RIGHT"}},
+		{[]string{"B8"}, PuzzleAnswer{Refutation: []string{"C7", "D7"}, Comment: "Too slow."}},
+		{[]string{"E5"}, PuzzleAnswer{Refutation: []string{"A9"}, Comment: "Not a solution."}},
+	} {
+		got, err := p.Check(moves(c.moves...), refute)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Check(%v) = %+v, %v, want %+v", c.moves, got, err, c.want)
+		}
+	}
+	for _, bad := range [][]string{{"C7", "E5"}, {"G3"}} {
+		if _, err := p.Check(moves(bad...), refute); err == nil {
+			t.Errorf("Check(%v) accepted", bad)
+		}
+	}
+}
+
+func TestPuzzleHandler(t *testing.T) {
+	srv := httptest.NewServer(puzzleHandler([]*Puzzle{testPuzzle(t)}, nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/puzzles/corner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info PuzzleInfo
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if info.Title != "Corner" || info.ToMove != "black" || !reflect.DeepEqual(info.Black, []string{"G3"}) || !reflect.DeepEqual(info.White, []string{"H2"}) {
+		t.Errorf("puzzle = %+v", info)
+	}
+
+	resp, err = http.Post(srv.URL+"/puzzles/corner/check", "application/json", strings.NewReader(`{"moves": ["c7"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ans PuzzleAnswer
+	json.NewDecoder(resp.Body).Decode(&ans)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !ans.Correct || ans.Reply != "B7" {
+		t.Errorf("check = %s %+v", resp.Status, ans)
+	}
+
+	resp, err = http.Post(srv.URL+"/puzzles/nope/check", "application/json", strings.NewReader(`{"moves": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("an unknown puzzle: %s", resp.Status)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["quiz"] = runQuiz
+}
+
+// Quiz decks turn reviewed games into questions. A key position, a move
+// marked as a hotspot (HO) or a good move (TE), asks for that move, and a
+// comment with a question on a line starting "Q:" and its answer on one
+// starting "A:" asks the question on the position it comments on:
+//
+//	C[Q: Which white group is weak?
+//	  A: The one on the left]
+//
+// An answer that is a point on the board is answered with a move, others
+// in words, which the player marks right or wrong on seeing the answer
+// when theirs is not the same. quiz make writes a deck to ~/.gogame/decks
+// and quiz asks its questions, recording every answer in the study log
+// (study.json), which the review scheduler works from.
+
+// QuizItem is one question on a position.
+type QuizItem struct {
+	ID       string   `json:"id"` // the source, game and move number
+	Size     int      `json:"size"`
+	Black    []string `json:"black,omitempty"` // the stones, as GTP vertices
+	White    []string `json:"white,omitempty"`
+	ToMove   string   `json:"to_move"`
+	LastMove string   `json:"last_move,omitempty"`
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Move     bool     `json:"move,omitempty"`    // answered with a move, a GTP vertex
+	Comment  string   `json:"comment,omitempty"` // shown after answering
+}
+
+// QuizDeck is a named list of questions.
+type QuizDeck struct {
+	Name  string     `json:"name"`
+	Items []QuizItem `json:"items"`
+}
+
+// StudyResult is one answer to a quiz question or problem.
+type StudyResult struct {
+	Deck    string    `json:"deck"`
+	Item    string    `json:"item"`
+	Date    time.Time `json:"date"`
+	Correct bool      `json:"correct"`
+}
+
+func quizDeckPath(name string) string {
+	return dataPath(filepath.Join("decks", name+".json"))
+}
+
+func studyLogPath() string {
+	return dataPath("study.json")
+}
+
+// LoadStudyLog returns every answer recorded, oldest first.
+func LoadStudyLog() ([]StudyResult, error) {
+	var results []StudyResult
+	if err := loadJSON(studyLogPath(), &results); err != nil {
+		return nil, fmt.Errorf("%s: %v", studyLogPath(), err)
+	}
+	return results, nil
+}
+
+// RecordStudyResult adds r to the study log.
+func RecordStudyResult(r StudyResult) error {
+	results, err := LoadStudyLog()
+	if err != nil {
+		return err
+	}
+	return saveJSON(studyLogPath(), append(results, r))
+}
+
+// LoadQuizDeck reads a deck by its name in the decks directory, or from a
+// file when name is a path.
+func LoadQuizDeck(name string) (*QuizDeck, error) {
+	path := name
+	if !strings.ContainsAny(name, `/\`) && !strings.HasSuffix(name, ".json") {
+		path = quizDeckPath(name)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no deck %s", name)
+	}
+	deck := &QuizDeck{}
+	if err := loadJSON(path, deck); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return deck, nil
+}
+
+// quizPosition describes b for a question.
+func quizPosition(id string, b *Board, last int) QuizItem {
+	item := QuizItem{ID: id, Size: b.size, ToMove: colorKey(b.turn)}
+	for p := 0; p < b.size*b.size; p++ {
+		switch b.grid[p/b.size][p%b.size] {
+		case Black:
+			item.Black = append(item.Black, gtpVertex(p, b.size))
+		case White:
+			item.White = append(item.White, gtpVertex(p, b.size))
+		}
+	}
+	if last != PassMove {
+		item.LastMove = gtpVertex(last, b.size)
+	}
+	return item
+}
+
+// board sets up the item's position.
+func (q QuizItem) board() (*Board, error) {
+	b := NewBoard(q.Size)
+	for _, stones := range []struct {
+		color    Stone
+		vertices []string
+	}{{Black, q.Black}, {White, q.White}} {
+		for _, v := range stones.vertices {
+			p, err := parseGTPVertex(v, q.Size)
+			if err != nil || p == PassMove {
+				return nil, fmt.Errorf("item %s: bad stone %q", q.ID, v)
+			}
+			b.setPoint(p, stones.color)
+		}
+	}
+	if q.ToMove == "white" {
+		b.turn = White
+	}
+	return b, nil
+}
+
+// commentQuestions finds the Q: and A: pairs of a comment, and returns
+// the rest of it.
+func commentQuestions(comment string) (questions, answers []string, rest string) {
+	var kept []string
+	for _, line := range strings.Split(comment, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch prefix := strings.ToUpper(trimmed[:min(2, len(trimmed))]); {
+		case prefix == "Q:":
+			questions = append(questions, strings.TrimSpace(trimmed[2:]))
+		case prefix == "A:" && len(answers) < len(questions):
+			answers = append(answers, strings.TrimSpace(trimmed[2:]))
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return questions[:len(answers)], answers, strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// QuizItemsFromGame makes the questions of a reviewed game; source names
+// the game in their IDs.
+func QuizItemsFromGame(g *GameRecord, source string) ([]QuizItem, error) {
+	var items []QuizItem
+	b := g.Start()
+	last := PassMove
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		questions, answers, rest := commentQuestions(m.Comment)
+		key := false
+		for _, p := range m.Markup {
+			key = key || p.ID == "HO"
+		}
+		if q, _ := m.Quality(); (key || q == GoodMove) && m.Move != PassMove {
+			item := quizPosition(fmt.Sprintf("%s:%d", source, i+1), b, last)
+			item.Question = colorName(m.Color) + " to play. What is the move?"
+			item.Answer, item.Move, item.Comment = gtpVertex(m.Move, g.Size), true, rest
+			items = append(items, item)
+		}
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d is illegal", i+1)
+		}
+		last = m.Move
+		// Questions are on the position the comment is on, after the move.
+		for n, question := range questions {
+			item := quizPosition(fmt.Sprintf("%s:%d:q%d", source, i+1, n+1), b, last)
+			item.Question, item.Answer, item.Comment = question, answers[n], rest
+			if p, err := parseGTPVertex(answers[n], g.Size); err == nil && p != PassMove {
+				item.Answer, item.Move = gtpVertex(p, g.Size), true
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// sameAnswer reports whether two answers in words are the same, but for
+// case, spaces and a full stop.
+func sameAnswer(a, b string) bool {
+	norm := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))), " ")
+	}
+	return norm(a) == norm(b)
+}
+
+// askQuizItem shows the item's position and question, reads the answer
+// and reports whether it was right.
+func askQuizItem(view *consoleView, item QuizItem) (correct, ok bool) {
+	b, err := item.board()
+	if err != nil {
+		fmt.Println(err)
+		return false, false
+	}
+	view.Show(b)
+	if item.LastMove != "" {
+		if p, err := parseGTPVertex(item.LastMove, item.Size); err == nil {
+			fmt.Printf("Last move: %s\n", userCoords.Move(p, item.Size))
+		}
+	}
+	fmt.Println(item.Question)
+	for {
+		fmt.Print("> ")
+		if !view.in.Scan() {
+			return false, false
+		}
+		answer := strings.TrimSpace(view.in.Text())
+		if answer == "" {
+			continue
+		}
+		if item.Move {
+			move, err := userCoords.Parse(answer, item.Size)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			want, _ := parseGTPVertex(item.Answer, item.Size)
+			correct = move == want
+			if !correct {
+				fmt.Printf("No: the answer is %s.\n", userCoords.Move(want, item.Size))
+			}
+		} else if correct = sameAnswer(answer, item.Answer); !correct {
+			fmt.Printf("The answer: %s\nCount yours as right? [y/N] ", item.Answer)
+			if !view.in.Scan() {
+				return false, false
+			}
+			correct = strings.HasPrefix(strings.ToLower(strings.TrimSpace(view.in.Text())), "y")
+		}
+		if correct {
+			fmt.Println("Right!")
+		}
+		if item.Comment != "" {
+			fmt.Println(item.Comment)
+		}
+		return correct, true
+	}
+}
+
+// runQuizItems asks items of deck in order, recording each answer, until
+// they are done or the input ends, and returns how many were answered and
+// how many of those were right.
+func runQuizItems(deck string, items []QuizItem) (asked, right int, err error) {
+	view := newConsoleView(os.Stdin)
+	for i, item := range items {
+		fmt.Printf("\nQuestion %d of %d\n", i+1, len(items))
+		correct, ok := askQuizItem(view, item)
+		if !ok {
+			break
+		}
+		asked++
+		if correct {
+			right++
+		}
+		if err := RecordStudyResult(StudyResult{Deck: deck, Item: item.ID, Date: time.Now(), Correct: correct}); err != nil {
+			return asked, right, err
+		}
+	}
+	return asked, right, nil
+}
+
+func runQuiz(args []string) error {
+	if len(args) > 0 && args[0] == "make" {
+		return runQuizMake(args[1:])
+	}
+	if len(args) > 0 && args[0] == "list" {
+		paths, err := filepath.Glob(quizDeckPath("*"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			var deck QuizDeck
+			if err := loadJSON(path, &deck); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			fmt.Printf("%-24s %4d questions\n", deck.Name, len(deck.Items))
+		}
+		return nil
+	}
+	fs := flag.NewFlagSet("quiz", flag.ExitOnError)
+	shuffle := fs.Bool("shuffle", false, "ask the questions in random order")
+	count := fs.Int("n", 0, "questions to ask, 0 for all")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: quiz [-shuffle] [-n N] DECK | quiz make [-name NAME] games.sgf... | quiz list")
+	}
+	deck, err := LoadQuizDeck(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	items := deck.Items
+	if *shuffle {
+		items = append([]QuizItem(nil), items...)
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	}
+	if *count > 0 {
+		items = items[:min(*count, len(items))]
+	}
+	asked, right, err := runQuizItems(deck.Name, items)
+	fmt.Printf("\n%d of %d right\n", right, asked)
+	return err
+}
+
+func runQuizMake(args []string) error {
+	fs := flag.NewFlagSet("quiz make", flag.ExitOnError)
+	name := fs.String("name", "", "name of the deck (default the first file's name)")
+	out := fs.String("o", "", "file to write the deck to (default the decks directory)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: quiz make [-name NAME] [-o deck.json] games.sgf...")
+	}
+	deck := &QuizDeck{Name: *name}
+	if deck.Name == "" {
+		deck.Name = strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+	}
+	for _, path := range fs.Args() {
+		games, err := ReadSGFFile(path)
+		if err != nil {
+			return err
+		}
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		for n, tree := range games {
+			g, err := RecordFromSGF(tree)
+			if err != nil {
+				return fmt.Errorf("%s game %d: %v", path, n+1, err)
+			}
+			source := base
+			if len(games) > 1 {
+				source = fmt.Sprintf("%s-%d", base, n+1)
+			}
+			items, err := QuizItemsFromGame(g, source)
+			if err != nil {
+				return fmt.Errorf("%s game %d: %v", path, n+1, err)
+			}
+			deck.Items = append(deck.Items, items...)
+		}
+	}
+	if len(deck.Items) == 0 {
+		return errors.New("no key positions (HO or TE) or Q:/A: comments found")
+	}
+	path := *out
+	if path == "" {
+		path = quizDeckPath(deck.Name)
+	}
+	if err := saveJSON(path, deck); err != nil {
+		return err
+	}
+	fmt.Printf("%d questions in %s\n", len(deck.Items), path)
+	return nil
+}
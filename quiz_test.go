@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuizItemsFromGame(t *testing.T) {
+	trees, err := ParseSGF(strings.NewReader(`(;GM[1]SZ[9];B[ee];W[cc]HO[1];B[gc]C[Q: Which side is ahead?
+a: Black
+Both have two corners.];W[cg]BM[1]C[Q: Where is the vital point?
+A: c4])`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := RecordFromSGF(trees[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := QuizItemsFromGame(g, "game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("%d items, want 3: %+v", len(items), items)
+	}
+	key := items[0]
+	if key.ID != "game:2" || !key.Move || key.Answer != "C7" || key.ToMove != "white" || len(key.Black) != 1 || len(key.White) != 0 {
+		t.Errorf("key position %+v", key)
+	}
+	words := items[1]
+	if words.ID != "game:3:q1" || words.Move || words.Answer != "Black" || words.Comment != "Both have two corners." || words.LastMove != "G7" {
+		t.Errorf("question %+v", words)
+	}
+	if !sameAnswer("black.", words.Answer) || sameAnswer("white", words.Answer) {
+		t.Error("answers compared wrongly")
+	}
+	if point := items[2]; !point.Move || point.Answer != "C4" || len(point.White) != 2 {
+		t.Errorf("question answered with a point %+v", point)
+	}
+	b, err := items[2].board()
+	if err != nil || b.At(6, 2) != White || b.turn != Black || b.IsLegal(6*b.size+2) {
+		t.Errorf("board of %+v: %v", items[2], err)
+	}
+}
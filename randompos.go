@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func init() {
+	commands["randompos"] = runRandomPos
+}
+
+// positionAttempts bounds the tries RandomPosition makes before giving up.
+const positionAttempts = 1000
+
+// PositionOptions describes the random positions to generate.
+type PositionOptions struct {
+	Size    int
+	Stones  int // stones on the board
+	Balance int // black stones minus white stones
+	// Alive asks for finished positions where every group has two eyes.
+	// They come from random games played to the end, so Stones and
+	// Balance are then only matched to within Size stones, and zero
+	// Stones takes whatever the game left.
+	Alive bool
+}
+
+// RandomPosition generates a legal position as described by opts. Stones
+// are placed at random points; placements that would capture or have no
+// liberties are skipped, so no group is ever left without a liberty.
+func RandomPosition(opts PositionOptions, rng *rand.Rand) (*Board, error) {
+	if opts.Size < 2 {
+		return nil, fmt.Errorf("board size %d is too small", opts.Size)
+	}
+	if opts.Alive {
+		return randomAlivePosition(opts, rng)
+	}
+	black := (opts.Stones + opts.Balance) / 2
+	white := opts.Stones - black
+	if opts.Stones < 0 || black < 0 || white < 0 || (opts.Stones+opts.Balance)%2 != 0 {
+		return nil, fmt.Errorf("cannot have %d stones with black %+d", opts.Stones, opts.Balance)
+	}
+	if opts.Stones > opts.Size*opts.Size*3/4 {
+		return nil, fmt.Errorf("%d stones do not fit a %dx%d board with room to breathe", opts.Stones, opts.Size, opts.Size)
+	}
+	for attempt := 0; attempt < positionAttempts; attempt++ {
+		if b, ok := placeRandomStones(opts.Size, black, white, rng); ok {
+			return b, nil
+		}
+	}
+	return nil, errors.New("no position found; try fewer stones")
+}
+
+// placeRandomStones tries once to fill an empty board; it fails if it runs
+// out of points where a stone can go without capturing.
+func placeRandomStones(size, black, white int, rng *rand.Rand) (*Board, bool) {
+	b := NewBoard(size)
+	for _, p := range rng.Perm(size * size) {
+		if black == 0 && white == 0 {
+			break
+		}
+		color := Black
+		if black == 0 || (white > 0 && rng.Intn(black+white) >= black) {
+			color = White
+		}
+		before, saved := b.stoneCount(color.Opponent()), b.Copy()
+		b.turn = color
+		if !b.Play(p) {
+			continue
+		}
+		if b.stoneCount(color.Opponent()) != before {
+			b = saved
+			continue
+		}
+		if color == Black {
+			black--
+		} else {
+			white--
+		}
+	}
+	b.turn = Black
+	return b, black == 0 && white == 0
+}
+
+func randomAlivePosition(opts PositionOptions, rng *rand.Rand) (*Board, error) {
+	for attempt := 0; attempt < positionAttempts; attempt++ {
+		b := NewBoard(opts.Size)
+		randomPlayout(b, rng)
+		if !b.IsGameOver() || !b.groupsAlive() {
+			continue
+		}
+		black, white := b.stoneCount(Black), b.stoneCount(White)
+		if opts.Stones > 0 && (abs(black+white-opts.Stones) > opts.Size || abs(black-white-opts.Balance) > opts.Size) {
+			continue
+		}
+		b.passes, b.turn = 0, Black
+		return b, nil
+	}
+	return nil, fmt.Errorf("no finished position with about %d stones found on %dx%d", opts.Stones, opts.Size, opts.Size)
+}
+
+// groupsAlive reports whether every group borders at least two eyes, empty
+// points surrounded by its own colour. It is a quick test that holds in
+// finished games, not a proof of life.
+func (b *Board) groupsAlive() bool {
+	seen := make([]bool, b.size*b.size)
+	for p := range seen {
+		color := b.grid[p/b.size][p%b.size]
+		if seen[p] || color == Empty {
+			continue
+		}
+		eyes := map[int]bool{}
+		seen[p] = true
+		stack := []int{p}
+		for len(stack) > 0 {
+			q := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, n := range b.neighbours(q) {
+				switch s := b.grid[n/b.size][n%b.size]; {
+				case s == color && !seen[n]:
+					seen[n] = true
+					stack = append(stack, n)
+				case s == Empty && b.isEye(n/b.size, n%b.size, color):
+					eyes[n] = true
+				}
+			}
+		}
+		if len(eyes) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// countingExercise records b as a problem: count the position by area.
+func countingExercise(b *Board, komi float64) *GameRecord {
+	black, white := b.AreaScore()
+	winner, margin := b.Winner(komi)
+	return positionRecord(b, komi, fmt.Sprintf("Count the position by area, with %g komi.\nAnswer: Black %d, White %d, %s.",
+		komi, black, white, resultString(winner, margin)))
+}
+
+func runRandomPos(args []string) error {
+	fs := flag.NewFlagSet("randompos", flag.ExitOnError)
+	size := fs.Int("size", 9, "board size")
+	stones := fs.Int("stones", 0, "stones on the board (0 for a third of the board, or as the game left it with -alive)")
+	balance := fs.Int("balance", 0, "black stones minus white stones")
+	alive := fs.Bool("alive", false, "finished positions with every group alive; -stones and -balance are approximate")
+	n := fs.Int("n", 1, "positions to generate")
+	exercise := fs.Bool("exercise", false, "add the area count as a counting exercise")
+	komi := fs.Float64("komi", 7, "komi for -exercise")
+	seed := fs.Int64("seed", 0, "random seed (0 for the time)")
+	out := fs.String("o", "", "SGF file to write (default standard output)")
+	fs.Parse(args)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	opts := PositionOptions{Size: *size, Stones: *stones, Balance: *balance, Alive: *alive}
+	if opts.Stones == 0 && !opts.Alive {
+		opts.Stones = *size * *size / 3 &^ 1
+	}
+	var games []*SGFNode
+	for i := 0; i < *n; i++ {
+		b, err := RandomPosition(opts, rng)
+		if err != nil {
+			return err
+		}
+		g := positionRecord(b, *komi, "")
+		if *exercise {
+			g = countingExercise(b, *komi)
+		}
+		games = append(games, g.SGF())
+	}
+	if *out == "" {
+		return WriteSGF(os.Stdout, games)
+	}
+	return WriteSGFFile(*out, games)
+}
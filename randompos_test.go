@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRandomPosition(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		b, err := RandomPosition(PositionOptions{Size: 9, Stones: 40, Balance: 2}, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if black, white := b.stoneCount(Black), b.stoneCount(White); black != 21 || white != 19 {
+			t.Fatalf("%d black and %d white stones", black, white)
+		}
+		for row := 0; row < 9; row++ {
+			for col := 0; col < 9; col++ {
+				if b.grid[row][col] != Empty && !b.hasLiberties(row, col, map[[2]int]bool{}) {
+					t.Fatalf("stone at %d %d has no liberties", row, col)
+				}
+			}
+		}
+		// Every point is a stone, territory or dame.
+		black, white := b.AreaScore()
+		if black+white > 81 || black < 21 || white < 19 {
+			t.Errorf("area score %d-%d", black, white)
+		}
+	}
+	if _, err := RandomPosition(PositionOptions{Size: 9, Stones: 41, Balance: 0}, rng); err == nil {
+		t.Error("odd stones with even balance accepted")
+	}
+
+	b, err := RandomPosition(PositionOptions{Size: 7, Alive: true}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.groupsAlive() {
+		t.Error("alive position has a group without two eyes")
+	}
+	dead := NewBoard(5)
+	dead.grid[2][2] = White
+	if dead.groupsAlive() {
+		t.Error("a lone stone counted as alive")
+	}
+}
+
+func BenchmarkAreaScore(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	positions := make([]*Board, 64)
+	for i := range positions {
+		positions[i], _ = RandomPosition(PositionOptions{Size: 19, Stones: 200}, rng)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		positions[i%len(positions)].AreaScore()
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rank is a kyu/dan grade on a single integer scale: 1d is 0, 1k is -1,
+// 30k is -30 and professional ranks continue above 7d (1p is 7).
+type Rank int
+
+func ParseRank(s string) (Rank, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid rank %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid rank %q", s)
+	}
+	switch s[len(s)-1] {
+	case 'k':
+		if n > 30 {
+			return 0, fmt.Errorf("rank %q is below 30k", s)
+		}
+		return Rank(-n), nil
+	case 'd':
+		if n > 7 {
+			return 0, fmt.Errorf("rank %q is above 7d", s)
+		}
+		return Rank(n - 1), nil
+	case 'p':
+		if n > 9 {
+			return 0, fmt.Errorf("rank %q is above 9p", s)
+		}
+		return Rank(n + 6), nil
+	}
+	return 0, fmt.Errorf("invalid rank %q", s)
+}
+
+func (r Rank) String() string {
+	switch {
+	case r < 0:
+		return fmt.Sprintf("%dk", -int(r))
+	case r > 6:
+		return fmt.Sprintf("%dp", int(r)-6)
+	default:
+		return fmt.Sprintf("%dd", int(r)+1)
+	}
+}
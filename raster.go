@@ -0,0 +1,161 @@
+package main
+
+import (
+	"image"
+	"math"
+	"strconv"
+)
+
+// Bitmap board renderer for the image exports. Images are paletted so
+// they encode directly as GIF frames.
+
+// The colour indexes of the palette, whose colours come from the theme
+// (see Theme.palette).
+const (
+	rasterBoard uint8 = iota
+	rasterBlack
+	rasterWhite
+	rasterMarker
+	rasterBlackShade // first of 3, darkest last
+	_
+	_
+	rasterWhiteShade // first of 3, lightest last
+	_
+	_
+	rasterLines
+)
+
+// RasterOptions controls bitmap rendering. Cell is the distance between
+// lines in pixels. Numbers, if set, labels the stones on those points with
+// move numbers. LastMove is marked unless it is PassMove. Ownership shades
+// points and marks dead stones as in SVGOptions. Theme gives the colours,
+// the zero Theme being wood.
+type RasterOptions struct {
+	Cell      int
+	Numbers   map[int]int
+	LastMove  int
+	Ownership []float64
+	Theme     Theme
+}
+
+// digitFont is a 3x5 bitmap of each digit, one row per 3 bits.
+var digitFont = [10][5]uint8{
+	{7, 5, 5, 5, 7}, {2, 6, 2, 2, 7}, {7, 1, 7, 4, 7}, {7, 1, 7, 1, 7}, {5, 5, 7, 1, 1},
+	{7, 4, 7, 1, 7}, {7, 4, 7, 5, 7}, {7, 1, 1, 1, 1}, {7, 5, 7, 5, 7}, {7, 5, 7, 1, 7},
+}
+
+// RenderBoard draws b as a paletted image.
+func RenderBoard(b BoardView, opts RasterOptions) *image.Paletted {
+	size := b.Size()
+	cell := max(opts.Cell, 2)
+	side := (size + 1) * cell
+	theme := opts.Theme.or()
+	img := image.NewPaletted(image.Rect(0, 0, side, side), theme.palette())
+	pos := func(i int) int { return cell + i*cell }
+	ownership := len(opts.Ownership) == size*size
+
+	if ownership {
+		for p, o := range opts.Ownership {
+			if math.Abs(o) < ownershipShown || b.At(p/size, p%size) != Empty {
+				continue
+			}
+			level := min(int(math.Abs(o)*3), 2)
+			shade := rasterBlackShade + uint8(level)
+			if o < 0 {
+				shade = rasterWhiteShade + uint8(level)
+			}
+			x, y := pos(p%size), pos(p/size)
+			for dy := -cell / 2; dy < cell-cell/2; dy++ {
+				for dx := -cell / 2; dx < cell-cell/2; dx++ {
+					img.SetColorIndex(x+dx, y+dy, shade)
+				}
+			}
+		}
+	}
+	for i := 0; i < size; i++ {
+		for j := pos(0); j <= pos(size-1); j++ {
+			img.SetColorIndex(j, pos(i), rasterLines)
+			img.SetColorIndex(pos(i), j, rasterLines)
+		}
+	}
+	for _, p := range starPoints(size) {
+		fillCircle(img, pos(p%size), pos(p/size), max(cell/8, 1), rasterLines)
+	}
+
+	radius := cell/2 - 1
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			x, y := pos(col), pos(row)
+			ink := rasterWhite
+			switch b.At(row, col) {
+			case Black:
+				fillCircle(img, x, y, radius, rasterBlack)
+			case White:
+				fillCircle(img, x, y, radius, rasterLines)
+				fillCircle(img, x, y, radius-1, rasterWhite)
+				ink = rasterBlack
+			default:
+				continue
+			}
+			p := row*size + col
+			// Dead stones carry a square of the other colour.
+			if ownership && opts.Ownership[p]*ownerSign(b.At(row, col)) <= -ownershipDead {
+				fillSquare(img, x, y, max(cell/6, 1), ink)
+			}
+			marker := rasterMarker
+			if theme.Marker == "" {
+				marker = ink
+			}
+			if n, ok := opts.Numbers[p]; ok {
+				if p == opts.LastMove {
+					ink = marker
+				}
+				drawNumber(img, x, y, n, max(cell/14, 1), ink)
+			} else if p == opts.LastMove {
+				fillCircle(img, x, y, max(cell/6, 1), marker)
+			}
+		}
+	}
+	return img
+}
+
+func fillSquare(img *image.Paletted, cx, cy, r int, c uint8) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			img.SetColorIndex(cx+x, cy+y, c)
+		}
+	}
+}
+
+func fillCircle(img *image.Paletted, cx, cy, r int, c uint8) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				img.SetColorIndex(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// drawNumber writes n centred on x, y with the digit font at the given
+// scale.
+func drawNumber(img *image.Paletted, x, y, n, scale int, c uint8) {
+	s := strconv.Itoa(n)
+	width := (4*len(s) - 1) * scale
+	left, top := x-width/2, y-5*scale/2
+	for i, d := range s {
+		glyph := digitFont[d-'0']
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if glyph[row]&(4>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.SetColorIndex(left+(4*i+col)*scale+dx, top+row*scale+dy, c)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import "math"
+
+// Glicko-2 rating system, following Glickman's "Example of the Glicko-2
+// system". Ratings are kept on the Glicko scale (1500 default) and mapped
+// to ranks with 1d at 2100 and 100 points per grade, as the EGD does.
+
+const (
+	glickoScale      = 173.7178
+	glickoTau        = 0.5
+	glickoDefaultR   = 1500
+	glickoDefaultRD  = 350
+	glickoDefaultVol = 0.06
+)
+
+type Rating struct {
+	R     float64 `json:"r"`
+	RD    float64 `json:"rd"`
+	Sigma float64 `json:"sigma"`
+}
+
+func NewRating() Rating {
+	return Rating{R: glickoDefaultR, RD: glickoDefaultRD, Sigma: glickoDefaultVol}
+}
+
+// RatingForRank returns the established rating of a player of rank r.
+func RatingForRank(r Rank) float64 {
+	return 2100 + 100*float64(r)
+}
+
+// RankForRating returns the nearest rank to a rating.
+func RankForRating(r float64) Rank {
+	rank := Rank(math.Round((r - 2100) / 100))
+	if rank < -30 {
+		rank = -30
+	}
+	return rank
+}
+
+// Interval returns the 95% confidence interval of the rating.
+func (r Rating) Interval() (low, high float64) {
+	return r.R - 1.96*r.RD, r.R + 1.96*r.RD
+}
+
+// GlickoResult is one game within a rating period. Score is 1 for a win,
+// 0.5 for a jigo and 0 for a loss.
+type GlickoResult struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update applies one rating period. With no games only the deviation grows.
+func (r Rating) Update(results []GlickoResult) Rating {
+	mu := (r.R - glickoDefaultR) / glickoScale
+	phi := r.RD / glickoScale
+	if len(results) == 0 {
+		phi = math.Sqrt(phi*phi + r.Sigma*r.Sigma)
+		return Rating{R: r.R, RD: math.Min(phi*glickoScale, glickoDefaultRD), Sigma: r.Sigma}
+	}
+
+	var vInv, delta float64
+	for _, res := range results {
+		muJ := (res.Opponent.R - glickoDefaultR) / glickoScale
+		phiJ := res.Opponent.RD / glickoScale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		delta += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta *= v
+
+	sigma := newVolatility(phi, v, delta, r.Sigma)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*delta/v
+
+	return Rating{
+		R:     muNew*glickoScale + glickoDefaultR,
+		RD:    phiNew * glickoScale,
+		Sigma: sigma,
+	}
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm.
+func newVolatility(phi, v, delta, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > 1e-6 {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGlickoUpdate(t *testing.T) {
+	// Glickman's "Example of the Glicko-2 system".
+	r := Rating{R: 1500, RD: 200, Sigma: 0.06}.Update([]GlickoResult{
+		{Opponent: Rating{R: 1400, RD: 30}, Score: 1},
+		{Opponent: Rating{R: 1550, RD: 100}, Score: 0},
+		{Opponent: Rating{R: 1700, RD: 300}, Score: 0},
+	})
+	if math.Abs(r.R-1464.06) > 0.01 || math.Abs(r.RD-151.52) > 0.01 || math.Abs(r.Sigma-0.05999) > 0.00001 {
+		t.Errorf("after the example period: %+v, want 1464.06, 151.52, 0.05999", r)
+	}
+
+	// A period without games only widens the deviation, up to the default.
+	idle := r.Update(nil)
+	if idle.R != r.R || idle.RD <= r.RD || NewRating().Update(nil).RD != glickoDefaultRD {
+		t.Errorf("after an idle period: %+v", idle)
+	}
+}
+
+func TestEstimateRatingHandicap(t *testing.T) {
+	fiveKyu, _ := ParseRank("5k")
+	rate := func(handicap int) float64 {
+		var results []ArchivedResult
+		for i := 0; i < 5; i++ {
+			results = append(results, ArchivedResult{
+				Date: time.Date(2026, 1, 5+i, 12, 0, 0, 0, time.UTC), Player: "p",
+				OpponentRank: fiveKyu, Score: 1, Handicap: handicap,
+			})
+		}
+		r, n := EstimateRating("p", results)
+		if n != 5 {
+			t.Fatalf("%d games rated", n)
+		}
+		return r.R
+	}
+	// Wins with stones received count for less than even wins, and wins
+	// giving stones for more.
+	took, even, gave := rate(3), rate(0), rate(-3)
+	if !(took < even && even < gave) {
+		t.Errorf("rating after wins taking 3 stones %.0f, even %.0f, giving 3 %.0f", took, even, gave)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// GameRecord is a game independent of any file format: the metadata, the
+// setup stones and the moves, optionally with per-move analysis.
+type GameRecord struct {
+	Size      int
+	Komi      float64
+	Handicap  int
+	Black     string
+	White     string
+	BlackRank string
+	WhiteRank string
+	Result    string // as in SGF RE, e.g. "B+3.5", "W+R", "0"
+	Date      string
+	// Info holds the other game-info properties by SGF id (EV, PC, RU,
+	// TM, OT, ...).
+	Info     map[string]string
+	Setup    []RecordedMove // stones placed before the first move
+	Moves    []RecordedMove
+	Analysis []MoveAnalysis
+}
+
+// RecordedMove is a stone of Color at Move (a move index or PassMove).
+// TimeLeft and Periods are the clock after the move (SGF BL/WL and OB/OW),
+// zero when not recorded. Markup keeps the node's markup and annotation
+// properties verbatim.
+type RecordedMove struct {
+	Color    Stone
+	Move     int
+	Comment  string
+	TimeLeft float64
+	Periods  int
+	Markup   []SGFProperty
+}
+
+// Start returns the position before the first move.
+func (g *GameRecord) Start() *Board {
+	b := NewBoard(g.Size)
+	for _, s := range g.Setup {
+		if s.Move != PassMove {
+			b.setPoint(s.Move, s.Color)
+		}
+	}
+	if len(g.Moves) > 0 {
+		b.turn = g.Moves[0].Color
+	} else if g.Handicap > 1 {
+		b.turn = White
+	}
+	return b
+}
+
+// Replay returns the position after the first n moves.
+func (g *GameRecord) Replay(n int) (*Board, error) {
+	b := g.Start()
+	for i, m := range g.Moves[:min(n, len(g.Moves))] {
+		b.turn = m.Color
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d is illegal", i+1)
+		}
+	}
+	return b, nil
+}
+
+// formatMove renders a move index the way moves are typed in the game,
+// "row col", or "pass".
+func formatMove(move, size int) string {
+	if move == PassMove {
+		return "pass"
+	}
+	return strconv.Itoa(move/size) + " " + strconv.Itoa(move%size)
+}
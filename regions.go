@@ -0,0 +1,172 @@
+package main
+
+// Spatial queries on the board, for territory estimates, hit-testing in
+// user interfaces and pattern tools. Part of the core (see board.go).
+
+// Region is a connected group of empty points.
+type Region struct {
+	Points []int // move indexes, in the order found from the first
+	// Black and White report whether the region borders stones of that
+	// colour.
+	Black, White bool
+}
+
+// Size is the number of points in the region.
+func (r Region) Size() int { return len(r.Points) }
+
+// Owner is the only colour bordering the region, or Empty if it borders
+// both or neither.
+func (r Region) Owner() Stone {
+	switch {
+	case r.Black && !r.White:
+		return Black
+	case r.White && !r.Black:
+		return White
+	}
+	return Empty
+}
+
+// EmptyRegions lists the empty regions of the board, ordered by their
+// first point in row-major order.
+func (b *Board) EmptyRegions() []Region {
+	seen := make([]bool, b.size*b.size)
+	var regions []Region
+	for p := range seen {
+		if seen[p] || b.grid[p/b.size][p%b.size] != Empty {
+			continue
+		}
+		var r Region
+		seen[p] = true
+		stack := []int{p}
+		for len(stack) > 0 {
+			q := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			r.Points = append(r.Points, q)
+			for _, n := range b.neighbours(q) {
+				switch b.grid[n/b.size][n%b.size] {
+				case Black:
+					r.Black = true
+				case White:
+					r.White = true
+				default:
+					if !seen[n] {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// RegionAt returns the empty region containing row, col; ok is false if
+// the point is occupied or off the board.
+func (b *Board) RegionAt(row, col int) (r Region, ok bool) {
+	if !b.isInBounds(row, col) || b.grid[row][col] != Empty {
+		return Region{}, false
+	}
+	p := row*b.size + col
+	for _, r := range b.EmptyRegions() {
+		for _, q := range r.Points {
+			if q == p {
+				return r, true
+			}
+		}
+	}
+	return Region{}, false
+}
+
+// neighbours lists the points next to p on the board.
+func (b *Board) neighbours(p int) []int {
+	row, col := p/b.size, p%b.size
+	ns := make([]int, 0, 4)
+	if row > 0 {
+		ns = append(ns, p-b.size)
+	}
+	if row < b.size-1 {
+		ns = append(ns, p+b.size)
+	}
+	if col > 0 {
+		ns = append(ns, p-1)
+	}
+	if col < b.size-1 {
+		ns = append(ns, p+1)
+	}
+	return ns
+}
+
+// Rect is a rectangle of points, from Top, Left to Bottom, Right inclusive.
+type Rect struct {
+	Top, Left, Bottom, Right int
+}
+
+// StonesInRect lists the stones inside r, clipped to the board, in
+// row-major order.
+func (b *Board) StonesInRect(r Rect) []int {
+	var stones []int
+	for row := max(r.Top, 0); row <= min(r.Bottom, b.size-1); row++ {
+		for col := max(r.Left, 0); col <= min(r.Right, b.size-1); col++ {
+			if b.grid[row][col] != Empty {
+				stones = append(stones, row*b.size+col)
+			}
+		}
+	}
+	return stones
+}
+
+// NearestStone finds the stone of color (any colour for Empty) closest to
+// row, col by Manhattan distance, the number of steps along the lines.
+// Ties go to the first in row-major order. ok is false on an empty board.
+func (b *Board) NearestStone(row, col int, color Stone) (point, distance int, ok bool) {
+	// Search rings of growing distance so a stone nearby is found without
+	// scanning the board.
+	for d := 0; d <= 2*(b.size-1); d++ {
+		for _, p := range b.ring(row, col, d) {
+			if s := b.grid[p/b.size][p%b.size]; s != Empty && (color == Empty || s == color) {
+				return p, d, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// StonesWithin lists the stones of color (any colour for Empty) at most
+// distance steps from row, col, nearest first.
+func (b *Board) StonesWithin(row, col, distance int, color Stone) []int {
+	var stones []int
+	for d := 0; d <= distance; d++ {
+		for _, p := range b.ring(row, col, d) {
+			if s := b.grid[p/b.size][p%b.size]; s != Empty && (color == Empty || s == color) {
+				stones = append(stones, p)
+			}
+		}
+	}
+	return stones
+}
+
+// ring lists the points on the board exactly d steps from row, col, in
+// row-major order.
+func (b *Board) ring(row, col, d int) []int {
+	var points []int
+	for r := row - d; r <= row+d; r++ {
+		dc := d - abs(r-row)
+		for _, c := range []int{col - dc, col + dc} {
+			if b.isInBounds(r, c) {
+				points = append(points, r*b.size+c)
+			}
+			if dc == 0 {
+				break
+			}
+		}
+	}
+	return points
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
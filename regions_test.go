@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegions(t *testing.T) {
+	// . ● . ○ .
+	// ● ● . ○ .
+	// . . . ○ .
+	// . . . ○ ○
+	// . . . . .
+	b := NewBoard(5)
+	for _, p := range []int{1, 5, 6} {
+		b.grid[p/5][p%5] = Black
+	}
+	for _, p := range []int{3, 8, 13, 18, 19} {
+		b.grid[p/5][p%5] = White
+	}
+
+	regions := b.EmptyRegions()
+	if len(regions) != 3 {
+		t.Fatalf("%d regions, want 3", len(regions))
+	}
+	for i, want := range []struct {
+		size  int
+		owner Stone
+	}{{1, Black}, {13, Empty}, {3, White}} {
+		if r := regions[i]; r.Size() != want.size || r.Owner() != want.owner {
+			t.Errorf("region %d: size %d owner %s, want %d %s", i, r.Size(), r.Owner(), want.size, want.owner)
+		}
+	}
+	if r, ok := b.RegionAt(1, 4); !ok || r.Size() != 3 || !r.White || r.Black {
+		t.Errorf("RegionAt(1, 4) = %+v, %v", r, ok)
+	}
+	if _, ok := b.RegionAt(1, 1); ok {
+		t.Error("RegionAt found a region on a stone")
+	}
+
+	if got := b.StonesInRect(Rect{Top: -1, Left: 1, Bottom: 1, Right: 3}); !reflect.DeepEqual(got, []int{1, 3, 6, 8}) {
+		t.Errorf("StonesInRect = %v", got)
+	}
+
+	if p, d, ok := b.NearestStone(4, 0, Empty); !ok || p != 5 || d != 3 {
+		t.Errorf("NearestStone(4, 0) = %d at %d, %v", p, d, ok)
+	}
+	if p, d, ok := b.NearestStone(0, 0, White); !ok || p != 3 || d != 3 {
+		t.Errorf("NearestStone(0, 0, white) = %d at %d, %v", p, d, ok)
+	}
+	if _, _, ok := NewBoard(5).NearestStone(2, 2, Empty); ok {
+		t.Error("NearestStone found a stone on an empty board")
+	}
+	if got := b.StonesWithin(2, 3, 1, Empty); !reflect.DeepEqual(got, []int{13, 8, 18}) {
+		t.Errorf("StonesWithin = %v", got)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["replay"] = runReplay
+}
+
+// replayFlash is how long captured stones stay marked.
+const replayFlash = 300 * time.Millisecond
+
+// replayer animates a recorded game in the terminal.
+type replayer struct {
+	g         *GameRecord
+	positions []*Board // positions[i] is the board after i moves
+	captures  [][]int  // captures[i] are the points captured by move i
+	out       *os.File
+	speed     time.Duration
+	paused    bool
+	// numbers is the move numbers setting shown, see movenumbers.go; m
+	// switches between it and none.
+	numbers, numbersOff int
+}
+
+func newReplayer(g *GameRecord, out *os.File, speed time.Duration) (*replayer, error) {
+	r := &replayer{g: g, out: out, speed: speed}
+	b := g.Start()
+	r.positions = append(r.positions, b.Copy())
+	r.captures = append(r.captures, nil)
+	for i, m := range g.Moves {
+		b.turn = m.Color
+		before := b.Copy()
+		if !b.Play(m.Move) {
+			return nil, fmt.Errorf("move %d (%s) is illegal", i+1, formatMove(m.Move, g.Size))
+		}
+		var captured []int
+		for p := 0; p < g.Size*g.Size; p++ {
+			if s := before.grid[p/g.Size][p%g.Size]; s != Empty && b.grid[p/g.Size][p%g.Size] == Empty {
+				captured = append(captured, p)
+			}
+		}
+		r.positions = append(r.positions, b.Copy())
+		r.captures = append(r.captures, captured)
+	}
+	return r, nil
+}
+
+// frame draws the position after n moves, with the stones captured by
+// move n marked if flash is set.
+func (r *replayer) frame(n int, flash bool) {
+	width, height := screenSize(r.out)
+	var lines []string
+	label := func(name, rank string) string {
+		if rank != "" {
+			return name + " (" + rank + ")"
+		}
+		return name
+	}
+	title := label(r.g.Black, r.g.BlackRank) + " vs " + label(r.g.White, r.g.WhiteRank)
+	if r.g.Result != "" {
+		title += "  " + r.g.Result
+	}
+	lines = append(lines, title)
+
+	marks := numberMarks(moveNumbers(r.positions[n], r.g.Moves[:n], r.numbers))
+	if flash && len(r.captures[n]) > 0 {
+		if marks == nil {
+			marks = make(map[int]string)
+		}
+		for _, p := range r.captures[n] {
+			marks[p] = "×"
+		}
+	}
+	focus := PassMove
+	status := fmt.Sprintf("Move %d/%d", n, len(r.g.Moves))
+	comment := ""
+	if n > 0 {
+		m := r.g.Moves[n-1]
+		focus = m.Move
+		status += fmt.Sprintf("  %s %s", m.Color, moveMark(m, r.g.Size))
+		if k := len(r.captures[n]); k > 0 {
+			status += fmt.Sprintf("  captures %d", k)
+		}
+		comment = strings.SplitN(m.Comment, "\n", 2)[0]
+	}
+	lines = append(lines, markedBoardLines(r.positions[n], width, height-5, focus, marks)...)
+	lines = append(lines, status, comment)
+	help := fmt.Sprintf("space pause  n/p step  +/- speed  m numbers  q quit   %v/move", r.speed)
+	if r.paused {
+		help = "[paused]  " + help
+	}
+	lines = append(lines, help)
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	for _, l := range lines {
+		sb.WriteString(truncateRunes(l, width))
+		sb.WriteString("\n")
+	}
+	io.WriteString(r.out, sb.String())
+}
+
+// show draws move n, flashing its captures first.
+func (r *replayer) show(n int) {
+	if len(r.captures[n]) > 0 {
+		r.frame(n, true)
+		time.Sleep(min(replayFlash, r.speed/2))
+	}
+	r.frame(n, false)
+}
+
+// run animates the game until it ends or the user quits. keys delivers
+// single key presses and may be nil.
+func (r *replayer) run(keys <-chan byte) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	n := 0
+	r.frame(n, false)
+	timer := time.NewTimer(r.speed)
+	defer timer.Stop()
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-timer.C:
+			if !r.paused && n < len(r.g.Moves) {
+				n++
+				r.show(n)
+			}
+			if n == len(r.g.Moves) && keys == nil {
+				return
+			}
+			timer.Reset(r.speed)
+		case k, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			switch k {
+			case 'q', 'Q':
+				return
+			case ' ':
+				r.paused = !r.paused
+				r.frame(n, false)
+			case 'n', 'N':
+				r.paused = true
+				if n < len(r.g.Moves) {
+					n++
+				}
+				r.show(n)
+			case 'p', 'P':
+				r.paused = true
+				if n > 0 {
+					n--
+				}
+				r.frame(n, false)
+			case '+':
+				r.speed = max(r.speed/2, 50*time.Millisecond)
+				r.frame(n, false)
+			case '-':
+				r.speed *= 2
+				r.frame(n, false)
+			case 'm', 'M':
+				r.numbers, r.numbersOff = r.numbersOff, r.numbers
+				r.frame(n, false)
+			}
+		}
+	}
+}
+
+// readKeys sends key presses from f, translating the right and left arrow
+// keys to n and p.
+func readKeys(f *os.File) <-chan byte {
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 8)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			switch s := string(buf[:n]); s {
+			case "\x1b[C":
+				keys <- 'n'
+			case "\x1b[D":
+				keys <- 'p'
+			default:
+				for _, c := range buf[:n] {
+					keys <- c
+				}
+			}
+		}
+	}()
+	return keys
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Duration("speed", time.Second, "time per move")
+	game := fs.Int("game", 1, "game number within the file")
+	numbers := fs.String("numbers", "", "number the stones of the last N moves, or all, modulo 100 (m switches them on and off)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: replay file.sgf [-speed 1s] [-game N] [-numbers N|all]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	if *speed <= 0 {
+		return errors.New("-speed must be positive")
+	}
+	last, err := parseMoveNumbers(*numbers)
+	if err != nil {
+		return err
+	}
+
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	r, err := newReplayer(g, os.Stdout, *speed)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	r.numbers, r.numbersOff = last, 0
+	if last == 0 {
+		r.numbersOff = allMoveNumbers
+	}
+
+	var keys <-chan byte
+	if isTerminal(os.Stdin) {
+		if restore, err := cbreak(os.Stdin); err == nil {
+			defer restore()
+			keys = readKeys(os.Stdin)
+		}
+	}
+	r.run(keys)
+	fmt.Println()
+	return nil
+}
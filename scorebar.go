@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// estimateReach is how many steps through empty points a stone's
+// influence claims; points further from every stone are left neutral.
+const estimateReach = 3
+
+// EstimateArea is a quick area score for live display: the stones of each
+// colour, plus each empty point that is closer, walking along empty
+// points, to that colour's stones than to the other's, within
+// estimateReach steps. It takes a pass over the board, with no search,
+// and does not judge dead stones.
+func EstimateArea(b *Board) (black, white int) {
+	n := b.size * b.size
+	var dist [3][]int
+	for _, color := range []Stone{Black, White} {
+		d := make([]int, n)
+		var queue []int
+		for p := range d {
+			d[p] = -1
+			if b.grid[p/b.size][p%b.size] == color {
+				d[p] = 0
+				queue = append(queue, p)
+			}
+		}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			if d[p] == estimateReach {
+				continue
+			}
+			for _, q := range b.neighbours(p) {
+				if d[q] < 0 && b.grid[q/b.size][q%b.size] == Empty {
+					d[q] = d[p] + 1
+					queue = append(queue, q)
+				}
+			}
+		}
+		dist[color] = d
+	}
+	for p := 0; p < n; p++ {
+		switch s := b.grid[p/b.size][p%b.size]; {
+		case s == Black:
+			black++
+		case s == White:
+			white++
+		case dist[White][p] < 0 && dist[Black][p] >= 0, dist[Black][p] >= 0 && dist[Black][p] < dist[White][p]:
+			black++
+		case dist[Black][p] < 0 && dist[White][p] >= 0, dist[White][p] >= 0 && dist[White][p] < dist[Black][p]:
+			white++
+		}
+	}
+	return black, white
+}
+
+// scoreBarText is the TUI's score line: a bar split in proportion to the
+// estimated areas, the estimated result with komi and the stones each
+// side has captured, fitted to width.
+func scoreBarText(b *Board, komi float64, captures [3]int, width int) string {
+	black, white := EstimateArea(b)
+	lead := float64(black-white) - komi
+	result := "even"
+	switch {
+	case lead > 0:
+		result = fmt.Sprintf("B+%.1f", lead)
+	case lead < 0:
+		result = fmt.Sprintf("W+%.1f", -lead)
+	}
+	tail := fmt.Sprintf(" %s  %s%d %s%d", result, Black, captures[Black], White, captures[White])
+	bar := width - len([]rune(tail)) - 2
+	if bar < 4 {
+		return strings.TrimSpace(tail)
+	}
+	filled := bar / 2
+	if black+white > 0 {
+		filled = (bar*black + (black+white)/2) / (black + white)
+	}
+	return Black.String() + strings.Repeat("█", filled) + strings.Repeat("░", bar-filled) + White.String() + tail
+}
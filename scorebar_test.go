@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateArea(t *testing.T) {
+	b := NewBoard(19)
+	b.grid[9][9] = Black
+	if black, white := EstimateArea(b); black != 25 || white != 0 {
+		t.Errorf("one stone: %d, %d; want its 25 points within reach", black, white)
+	}
+
+	// Walls on columns 2 and 6 split the board, column 4 being neutral.
+	b = NewBoard(9)
+	for row := 0; row < 9; row++ {
+		b.grid[row][2], b.grid[row][6] = Black, White
+	}
+	if black, white := EstimateArea(b); black != 36 || white != 36 {
+		t.Errorf("walls: %d, %d; want 36 each", black, white)
+	}
+	line := scoreBarText(b, 6.5, [3]int{0, 2, 1}, 30)
+	if len([]rune(line)) != 30 || !strings.HasSuffix(line, "W+6.5  ●2 ○1") {
+		t.Errorf("score bar %q", line)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["scorecheck"] = runScoreCheck
+}
+
+// scoreReference is an independent scorer to check ours against.
+type scoreReference interface {
+	// Score returns the result of the position as in SGF RE.
+	Score(b *Board, komi float64) (string, error)
+	// Dead lists the stones it considers dead, as move indexes.
+	Dead(b *Board) ([]int, error)
+}
+
+// gtpReference scores with an external engine, such as GNU Go, through
+// final_score and final_status_list.
+type gtpReference struct {
+	client *gtpClient
+}
+
+// setUp clears the engine's board and places b's stones. Stones are played
+// in row-major order; a final position has no captures along the way, as
+// every group keeps at least the liberties it has at the end.
+func (r gtpReference) setUp(b *Board, komi float64) error {
+	for _, cmd := range []string{fmt.Sprintf("boardsize %d", b.size), fmt.Sprintf("komi %g", komi), "clear_board"} {
+		if _, err := r.client.send("%s", cmd); err != nil {
+			return err
+		}
+	}
+	for p := 0; p < b.size*b.size; p++ {
+		if s := b.grid[p/b.size][p%b.size]; s != Empty {
+			if _, err := r.client.send("play %s %s", gtpColor(s), gtpVertex(p, b.size)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r gtpReference) Score(b *Board, komi float64) (string, error) {
+	if err := r.setUp(b, komi); err != nil {
+		return "", err
+	}
+	return r.client.send("final_score")
+}
+
+// Dead relies on the board set up by Score.
+func (r gtpReference) Dead(b *Board) ([]int, error) {
+	reply, err := r.client.send("final_status_list dead")
+	if err != nil {
+		return nil, err
+	}
+	var dead []int
+	for _, v := range strings.Fields(reply) {
+		p, err := parseGTPVertex(v, b.size)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, p)
+	}
+	return dead, nil
+}
+
+// scoreDiscrepancy is a position where the reference disagrees with us.
+type scoreDiscrepancy struct {
+	Board          *Board
+	Ours, Theirs   string // results as in SGF RE
+	Dead           []int  // stones the reference considers dead; we count all alive
+	ScoreDisagrees bool
+}
+
+// checkScore compares our score of b under rules with ref's. Results are
+// compared as numbers so that "B+3" and "B+3.0" agree.
+func checkScore(b *Board, komi float64, rules Ruleset, ref scoreReference) (*scoreDiscrepancy, error) {
+	theirs, err := ref.Score(b, komi)
+	if err != nil {
+		return nil, err
+	}
+	dead, err := ref.Dead(b)
+	if err != nil {
+		return nil, err
+	}
+	winner, margin := rules.Score(NewGameLog(b), komi)
+	d := &scoreDiscrepancy{Board: b, Ours: resultString(winner, margin), Theirs: theirs, Dead: dead}
+	d.ScoreDisagrees = resultValue(d.Ours) != resultValue(theirs)
+	if !d.ScoreDisagrees && len(dead) == 0 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// resultValue is a result's margin from Black's side, or NaN for results
+// that are not a score.
+func resultValue(result string) float64 {
+	result = strings.ToUpper(strings.TrimSpace(result))
+	if result == "0" || result == "DRAW" {
+		return 0
+	}
+	if len(result) < 3 || result[1] != '+' {
+		return math.NaN()
+	}
+	v, err := strconv.ParseFloat(result[2:], 64)
+	if err != nil {
+		return math.NaN()
+	}
+	if result[0] == 'W' {
+		return -v
+	}
+	return v
+}
+
+// positionRecord makes a record of b as setup stones, for reporting.
+func positionRecord(b *Board, komi float64, comment string) *GameRecord {
+	g := &GameRecord{Size: b.size, Komi: komi, Info: map[string]string{}}
+	if comment != "" {
+		g.Info["C"] = comment
+	}
+	for p := 0; p < b.size*b.size; p++ {
+		if s := b.grid[p/b.size][p%b.size]; s != Empty {
+			g.Setup = append(g.Setup, RecordedMove{Color: s, Move: p})
+		}
+	}
+	return g
+}
+
+func runScoreCheck(args []string) error {
+	fs := flag.NewFlagSet("scorecheck", flag.ExitOnError)
+	gtpCommand := fs.String("gtp", "gnugo --mode gtp --chinese-rules", "command running the reference engine in GTP mode")
+	positions := fs.Int("positions", 20, "random final positions to compare")
+	size := fs.Int("size", 9, "board size")
+	komi := fs.Float64("komi", 7, "komi")
+	rulesFlag := fs.String("rules", "area", "our scoring rules, to match the reference engine's")
+	seed := fs.Int64("seed", 0, "random seed (0 for the time)")
+	out := fs.String("out", "", "write the positions that disagree to this SGF file")
+	fs.Parse(args)
+
+	rules, err := NewRuleset(splitNames(*rulesFlag))
+	if err != nil {
+		return err
+	}
+	client, err := startGTP(*gtpCommand)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	ref := gtpReference{client}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(*seed))
+	var found []*SGFNode
+	for checked := 1; checked <= *positions; checked++ {
+		b, err := RandomPosition(PositionOptions{Size: *size, Alive: true}, rng)
+		if err != nil {
+			return err
+		}
+		d, err := checkScore(b, *komi, rules, ref)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+		var problems []string
+		if d.ScoreDisagrees {
+			problems = append(problems, fmt.Sprintf("we score %s, the reference %s", d.Ours, d.Theirs))
+		}
+		if len(d.Dead) > 0 {
+			var vertices []string
+			for _, p := range d.Dead {
+				vertices = append(vertices, gtpVertex(p, b.size))
+			}
+			problems = append(problems, "the reference calls dead "+strings.Join(vertices, " "))
+		}
+		report := strings.Join(problems, "; ")
+		fmt.Printf("position %d: %s\n", checked, report)
+		found = append(found, positionRecord(b, *komi, report).SGF())
+	}
+	fmt.Printf("%d positions (seed %d), %d discrepancies\n", *positions, *seed, len(found))
+	if *out != "" && len(found) > 0 {
+		if err := WriteSGFFile(*out, found); err != nil {
+			return err
+		}
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("scoring disagrees with %s on %d of %d positions", *gtpCommand, len(found), *positions)
+	}
+	return nil
+}
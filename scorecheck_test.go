@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/rand"
+	"os/exec"
+	"testing"
+)
+
+// fakeReference scores by area with all stones alive, plus a fixed error.
+type fakeReference struct {
+	offset float64
+	dead   []int
+}
+
+func (f fakeReference) Score(b *Board, komi float64) (string, error) {
+	winner, margin := b.Winner(komi - f.offset)
+	return resultString(winner, margin), nil
+}
+
+func (f fakeReference) Dead(b *Board) ([]int, error) { return f.dead, nil }
+
+func TestCheckScore(t *testing.T) {
+	rules, _ := NewRuleset(nil)
+	b, err := RandomPosition(PositionOptions{Size: 7, Alive: true}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := checkScore(b, 0.5, rules, fakeReference{}); err != nil || d != nil {
+		t.Errorf("agreeing reference reported %+v, %v", d, err)
+	}
+	if d, _ := checkScore(b, 0.5, rules, fakeReference{offset: 2}); d == nil || !d.ScoreDisagrees {
+		t.Error("score difference not reported")
+	}
+	if d, _ := checkScore(b, 0.5, rules, fakeReference{dead: []int{0}}); d == nil || d.ScoreDisagrees || len(d.Dead) != 1 {
+		t.Errorf("dead stones not reported: %+v", d)
+	}
+	for result, want := range map[string]float64{"B+3.5": 3.5, "W+2": -2, "0": 0} {
+		if got := resultValue(result); got != want {
+			t.Errorf("resultValue(%q) = %g", result, got)
+		}
+	}
+}
+
+// TestScoreCheckGNUGo compares against GNU Go when it is installed.
+func TestScoreCheckGNUGo(t *testing.T) {
+	if _, err := exec.LookPath("gnugo"); err != nil {
+		t.Skip("gnugo not installed")
+	}
+	if err := runScoreCheck([]string{"-positions", "5", "-size", "7", "-seed", "1"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Score estimates alongside win rates. A win rate says little once a game
+// is decided either way, as in handicap games, where 95% for White may mean
+// a lead of 2 points or of 40; the expected score and its spread say how
+// far ahead a side is and how sure the engine is of it.
+//
+// The search's evaluator, when it is a ScoreEvaluator such as the random
+// playouts, estimates the final score of each position it evaluates; the
+// estimates of one search make its ScoreDistribution. They come from the
+// whole tree, so they lean towards the lines the search spent the most on.
+
+// ScoreDistribution is how the estimated final scores of a search spread,
+// as Black's lead by area, komi included.
+type ScoreDistribution struct {
+	Playouts int
+	sum      float64
+	sumSq    float64
+	counts   map[int]int // by the lead rounded down
+}
+
+// ScoreBin counts the playouts with a lead from From up to To.
+type ScoreBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+func (d *ScoreDistribution) add(lead float64) {
+	if d.counts == nil {
+		d.counts = map[int]int{}
+	}
+	d.Playouts++
+	d.sum += lead
+	d.sumSq += lead * lead
+	d.counts[int(math.Floor(lead))]++
+}
+
+// merge adds the leads of o.
+func (d *ScoreDistribution) merge(o ScoreDistribution) {
+	if o.Playouts == 0 {
+		return
+	}
+	if d.counts == nil {
+		d.counts = map[int]int{}
+	}
+	d.Playouts += o.Playouts
+	d.sum += o.sum
+	d.sumSq += o.sumSq
+	for lead, n := range o.counts {
+		d.counts[lead] += n
+	}
+}
+
+// Mean is the expected lead.
+func (d ScoreDistribution) Mean() float64 {
+	if d.Playouts == 0 {
+		return 0
+	}
+	return d.sum / float64(d.Playouts)
+}
+
+// Stdev is the standard deviation of the lead.
+func (d ScoreDistribution) Stdev() float64 {
+	if d.Playouts == 0 {
+		return 0
+	}
+	mean := d.Mean()
+	return math.Sqrt(max(d.sumSq/float64(d.Playouts)-mean*mean, 0))
+}
+
+// Histogram counts the leads in bins width points wide, from the lowest
+// lead to the highest, empty bins included.
+func (d ScoreDistribution) Histogram(width int) []ScoreBin {
+	if len(d.counts) == 0 || width < 1 {
+		return nil
+	}
+	leads := make([]int, 0, len(d.counts))
+	for lead := range d.counts {
+		leads = append(leads, lead)
+	}
+	sort.Ints(leads)
+	floor := func(n int) int { return int(math.Floor(float64(n)/float64(width))) * width }
+	from := floor(leads[0])
+	bins := make([]ScoreBin, (floor(leads[len(leads)-1])-from)/width+1)
+	for i := range bins {
+		bins[i].From = float64(from + i*width)
+		bins[i].To = bins[i].From + float64(width)
+	}
+	for lead, n := range d.counts {
+		bins[(floor(lead)-from)/width].Count += n
+	}
+	return bins
+}
+
+// scoreRecorder is the evaluator of a search whose evaluator estimates
+// scores: it passes the win rates on and keeps the scores in dist.
+type scoreRecorder struct {
+	ScoreEvaluator
+	dist *ScoreDistribution
+}
+
+func (r scoreRecorder) Evaluate(positions []*Board) []float64 {
+	values, leads := r.EvaluateScores(positions)
+	for _, lead := range leads {
+		r.dist.add(lead)
+	}
+	return values
+}
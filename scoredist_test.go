@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestScoreDistribution(t *testing.T) {
+	var d ScoreDistribution
+	for _, lead := range []float64{-2.5, 0.5, 1.5, 4.5} {
+		d.add(lead)
+	}
+	if d.Mean() != 1 || d.Stdev() != 2.5 {
+		t.Errorf("mean %v stdev %v, want 1 and 2.5", d.Mean(), d.Stdev())
+	}
+	want := []ScoreBin{{-3, 0, 1}, {0, 3, 2}, {3, 6, 1}}
+	if got := d.Histogram(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Histogram(3) = %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzeScores(t *testing.T) {
+	// White to move against a living Black group with two eyes on 3x3:
+	// Black leads by 8.5 with komi.
+	b := NewBoard(3)
+	for p := 1; p < 8; p++ {
+		b.grid[p/3][p%3] = Black
+	}
+	b.turn = White
+	e := NewEngine(50, 0.5)
+	a, err := e.Analyze(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := e.ScoreDistribution(); d.Playouts != 50 || d.Mean() < 4 {
+		t.Errorf("Black's lead %v over %d playouts, want about 8.5 over 50", d.Mean(), d.Playouts)
+	}
+	if a.ScoreMean != -e.ScoreDistribution().Mean() || a.ScoreStdev != e.ScoreDistribution().Stdev() {
+		t.Errorf("score %v ± %v, want White's side of %v", a.ScoreMean, a.ScoreStdev, e.ScoreDistribution().Mean())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import "strconv"
+
+// Opponent returns the other colour.
+func (s Stone) Opponent() Stone {
+	switch s {
+	case Black:
+		return White
+	case White:
+		return Black
+	}
+	return Empty
+}
+
+// stoneCount is the number of stones of color on the board.
+func (b *Board) stoneCount(color Stone) int {
+	n := 0
+	for _, row := range b.grid {
+		for _, s := range row {
+			if s == color {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// AreaScore counts stones plus the empty regions that touch only one
+// colour, as in Chinese rules. Every stone on the board is assumed alive.
+func (b *Board) AreaScore() (black, white int) {
+	seen := make([][]bool, b.size)
+	for i := range seen {
+		seen[i] = make([]bool, b.size)
+	}
+	for row := 0; row < b.size; row++ {
+		for col := 0; col < b.size; col++ {
+			switch b.grid[row][col] {
+			case Black:
+				black++
+			case White:
+				white++
+			default:
+				if seen[row][col] {
+					continue
+				}
+				size, owner := b.floodEmpty(row, col, seen)
+				if owner == Black {
+					black += size
+				} else if owner == White {
+					white += size
+				}
+			}
+		}
+	}
+	return black, white
+}
+
+// floodEmpty marks the empty region containing (row, col) as seen and
+// returns its size and the only colour bordering it, or Empty if it borders
+// both or neither.
+func (b *Board) floodEmpty(row, col int, seen [][]bool) (int, Stone) {
+	size := 0
+	touchesBlack, touchesWhite := false, false
+	stack := [][2]int{{row, col}}
+	seen[row][col] = true
+	directions := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		size++
+		for _, dir := range directions {
+			r, c := p[0]+dir[0], p[1]+dir[1]
+			if !b.isInBounds(r, c) {
+				continue
+			}
+			switch b.grid[r][c] {
+			case Black:
+				touchesBlack = true
+			case White:
+				touchesWhite = true
+			default:
+				if !seen[r][c] {
+					seen[r][c] = true
+					stack = append(stack, [2]int{r, c})
+				}
+			}
+		}
+	}
+	switch {
+	case touchesBlack && !touchesWhite:
+		return size, Black
+	case touchesWhite && !touchesBlack:
+		return size, White
+	}
+	return size, Empty
+}
+
+// Winner returns the winner by area score with komi added for White, and
+// the margin of victory.
+func (b *Board) Winner(komi float64) (Stone, float64) {
+	black, white := b.AreaScore()
+	diff := float64(black) - float64(white) - komi
+	switch {
+	case diff > 0:
+		return Black, diff
+	case diff < 0:
+		return White, -diff
+	}
+	return Empty, 0
+}
+
+// resultString formats a result as in SGF RE: "B+3.5", "W+0.5" or "0".
+func resultString(winner Stone, margin float64) string {
+	switch winner {
+	case Black:
+		return "B+" + strconv.FormatFloat(margin, 'f', -1, 64)
+	case White:
+		return "W+" + strconv.FormatFloat(margin, 'f', -1, 64)
+	}
+	return "0"
+}
@@ -0,0 +1,937 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A small interpreter for game scripts (see scripthooks.go), in a subset
+// of Lua: local and global variables, functions and closures, if, while,
+// numeric for, break and return, with nil, booleans, numbers and strings.
+// There are no tables, modules or I/O; scripts see only the functions they
+// are given, and every call is limited to scriptMaxSteps steps so a
+// runaway loop cannot hang a game.
+//
+//	-- comments run to the end of the line
+//	local n = 0
+//	function on_move(color, row, col)
+//	  n = n + 1
+//	  if row >= 0 and (row == 0 or col == 0) then
+//	    say(color .. " played on the edge at move " .. n)
+//	  end
+//	end
+
+const (
+	scriptMaxSteps = 1_000_000
+	scriptMaxDepth = 200
+)
+
+// scriptFunc is a function defined in a script.
+type scriptFunc struct {
+	name   string
+	params []string
+	body   []scriptStmt
+	env    *scriptEnv
+}
+
+// scriptBuiltin is a function the host gives scripts.
+type scriptBuiltin func(args []any) (any, error)
+
+type scriptEnv struct {
+	vars   map[string]any
+	parent *scriptEnv
+}
+
+func (e *scriptEnv) lookup(name string) (*scriptEnv, bool) {
+	for ; e != nil; e = e.parent {
+		if _, ok := e.vars[name]; ok {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Script is a loaded script and its global variables.
+type Script struct {
+	name    string
+	globals *scriptEnv
+	steps   int
+	depth   int
+}
+
+// LoadScript parses src and runs its top level, which usually just
+// defines functions. name is used in error messages.
+func LoadScript(name, src string, builtins map[string]scriptBuiltin) (*Script, error) {
+	toks, err := scriptLex(name, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &scriptParser{name: name, toks: toks}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	s := &Script{name: name, globals: &scriptEnv{vars: map[string]any{}}}
+	for k, f := range builtins {
+		s.globals.vars[k] = f
+	}
+	if _, _, err := s.exec(body, s.globals); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Has reports whether the script defines function name.
+func (s *Script) Has(name string) bool {
+	_, ok := s.globals.vars[name].(*scriptFunc)
+	return ok
+}
+
+// Call calls the script's function name, returning nil if there is none.
+func (s *Script) Call(name string, args ...any) (any, error) {
+	f, ok := s.globals.vars[name].(*scriptFunc)
+	if !ok {
+		return nil, nil
+	}
+	s.steps, s.depth = 0, 0
+	return s.call(f, args, 0)
+}
+
+// ---- lexer ----
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokName
+	tokNumber
+	tokString
+	tokOp
+	tokKeyword
+)
+
+type scriptTok struct {
+	kind tokKind
+	text string
+	line int
+}
+
+var scriptKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "local": true, "nil": true,
+	"not": true, "or": true, "return": true, "then": true, "true": true, "while": true,
+}
+
+func scriptLex(name, src string) ([]scriptTok, error) {
+	var toks []scriptTok
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\n' {
+					return nil, fmt.Errorf("%s:%d: unfinished string", name, line)
+				}
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[j])
+					}
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%s:%d: unfinished string", name, line)
+			}
+			toks = append(toks, scriptTok{tokString, sb.String(), line})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, scriptTok{tokNumber, src[i:j], line})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			kind := tokName
+			if scriptKeywords[src[i:j]] {
+				kind = tokKeyword
+			}
+			toks = append(toks, scriptTok{kind, src[i:j], line})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"==", "~=", "<=", ">=", "..", "+", "-", "*", "/", "%", "<", ">", "=", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%s:%d: unexpected character %q", name, line, c)
+			}
+			toks = append(toks, scriptTok{tokOp, op, line})
+			i += len(op)
+		}
+	}
+	return append(toks, scriptTok{tokEOF, "end of file", line}), nil
+}
+
+// ---- syntax tree ----
+
+type scriptStmt interface{}
+type scriptExpr interface{}
+
+type (
+	stmtLocal struct {
+		name  string
+		value scriptExpr // nil for none
+	}
+	stmtAssign struct {
+		line  int
+		name  string
+		value scriptExpr
+	}
+	stmtCall   struct{ call exprCall }
+	stmtReturn struct{ value scriptExpr }
+	stmtBreak  struct{}
+	stmtIf     struct {
+		conds  []scriptExpr
+		blocks [][]scriptStmt // one more than conds with an else
+	}
+	stmtWhile struct {
+		cond scriptExpr
+		body []scriptStmt
+	}
+	stmtFor struct {
+		line           int
+		name           string
+		from, to, step scriptExpr
+		body           []scriptStmt
+	}
+	stmtFunction struct {
+		local bool
+		fn    *scriptFunc
+	}
+)
+
+type (
+	exprConst struct{ value any }
+	exprName  struct {
+		line int
+		name string
+	}
+	exprCall struct {
+		line int
+		fn   scriptExpr
+		args []scriptExpr
+	}
+	exprBinary struct {
+		line        int
+		op          string
+		left, right scriptExpr
+	}
+	exprUnary struct {
+		line    int
+		op      string
+		operand scriptExpr
+	}
+	exprFunction struct{ fn *scriptFunc }
+)
+
+// ---- parser ----
+
+type scriptParser struct {
+	name string
+	toks []scriptTok
+	pos  int
+}
+
+func (p *scriptParser) peek() scriptTok { return p.toks[p.pos] }
+
+func (p *scriptParser) next() scriptTok {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *scriptParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokOp || t.kind == tokKeyword) && t.text == text
+}
+
+func (p *scriptParser) expect(text string) error {
+	if t := p.next(); (t.kind != tokOp && t.kind != tokKeyword) || t.text != text {
+		return p.errorf(t, "expected %q, found %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *scriptParser) errorf(t scriptTok, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", p.name, t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *scriptParser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokName {
+		return "", p.errorf(t, "expected a name, found %q", t.text)
+	}
+	return t.text, nil
+}
+
+// block parses statements up to end, else, elseif or the end of input.
+func (p *scriptParser) block() ([]scriptStmt, error) {
+	var stmts []scriptStmt
+	for {
+		if t := p.peek(); t.kind == tokEOF || p.is("end") || p.is("else") || p.is("elseif") {
+			return stmts, nil
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+}
+
+func (p *scriptParser) statement() (scriptStmt, error) {
+	t := p.peek()
+	switch {
+	case p.is("local"):
+		p.next()
+		if p.is("function") {
+			p.next()
+			fn, err := p.function()
+			return stmtFunction{local: true, fn: fn}, err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		s := stmtLocal{name: name}
+		if p.is("=") {
+			p.next()
+			s.value, err = p.expr(0)
+		}
+		return s, err
+	case p.is("function"):
+		p.next()
+		fn, err := p.function()
+		return stmtFunction{fn: fn}, err
+	case p.is("return"):
+		p.next()
+		s := stmtReturn{}
+		if !p.is("end") && !p.is("else") && !p.is("elseif") && p.peek().kind != tokEOF {
+			var err error
+			if s.value, err = p.expr(0); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case p.is("break"):
+		p.next()
+		return stmtBreak{}, nil
+	case p.is("if"):
+		p.next()
+		var s stmtIf
+		for {
+			cond, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("then"); err != nil {
+				return nil, err
+			}
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			s.conds, s.blocks = append(s.conds, cond), append(s.blocks, body)
+			if !p.is("elseif") {
+				break
+			}
+			p.next()
+		}
+		if p.is("else") {
+			p.next()
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			s.blocks = append(s.blocks, body)
+		}
+		return s, p.expect("end")
+	case p.is("while"):
+		p.next()
+		cond, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return stmtWhile{cond, body}, p.expect("end")
+	case p.is("for"):
+		p.next()
+		s := stmtFor{line: t.line}
+		var err error
+		if s.name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		if s.from, err = p.expr(0); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if s.to, err = p.expr(0); err != nil {
+			return nil, err
+		}
+		if p.is(",") {
+			p.next()
+			if s.step, err = p.expr(0); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		if s.body, err = p.block(); err != nil {
+			return nil, err
+		}
+		return s, p.expect("end")
+	case t.kind == tokName:
+		if p.toks[p.pos+1].kind == tokOp && p.toks[p.pos+1].text == "=" {
+			p.pos += 2
+			value, err := p.expr(0)
+			return stmtAssign{line: t.line, name: t.text, value: value}, err
+		}
+		e, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		call, ok := e.(exprCall)
+		if !ok {
+			return nil, p.errorf(t, "expected a statement")
+		}
+		return stmtCall{call}, nil
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+// function parses a function's name, parameters and body.
+func (p *scriptParser) function() (*scriptFunc, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	fn, err := p.functionBody()
+	if fn != nil {
+		fn.name = name
+	}
+	return fn, err
+}
+
+func (p *scriptParser) functionBody() (*scriptFunc, error) {
+	fn := &scriptFunc{name: "function"}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.is(")") {
+		param, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		fn.params = append(fn.params, param)
+		if !p.is(")") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p.next()
+	var err error
+	if fn.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	return fn, p.expect("end")
+}
+
+// Binary operator precedence, lowest first; ".." is right associative.
+var scriptPrecedence = map[string]int{
+	"or": 1, "and": 2,
+	"==": 3, "~=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"..": 4, "+": 5, "-": 5, "*": 6, "/": 6, "%": 6,
+}
+
+const scriptUnaryPrecedence = 7
+
+func (p *scriptParser) expr(min int) (scriptExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := scriptPrecedence[t.text]
+		if !ok || (t.kind != tokOp && t.kind != tokKeyword) || prec <= min {
+			return left, nil
+		}
+		p.next()
+		next := prec
+		if t.text == ".." {
+			next--
+		}
+		right, err := p.expr(next)
+		if err != nil {
+			return nil, err
+		}
+		left = exprBinary{line: t.line, op: t.text, left: left, right: right}
+	}
+}
+
+func (p *scriptParser) unary() (scriptExpr, error) {
+	if t := p.peek(); p.is("not") || p.is("-") {
+		p.next()
+		operand, err := p.expr(scriptUnaryPrecedence)
+		return exprUnary{line: t.line, op: t.text, operand: operand}, err
+	}
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("(") {
+		t := p.next()
+		call := exprCall{line: t.line, fn: e}
+		for !p.is(")") {
+			arg, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.is(")") {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+		e = call
+	}
+	return e, nil
+}
+
+func (p *scriptParser) primary() (scriptExpr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "bad number %q", t.text)
+		}
+		return exprConst{f}, nil
+	case t.kind == tokString:
+		return exprConst{t.text}, nil
+	case t.kind == tokName:
+		return exprName{line: t.line, name: t.text}, nil
+	case t.kind == tokKeyword && t.text == "nil":
+		return exprConst{nil}, nil
+	case t.kind == tokKeyword && t.text == "true":
+		return exprConst{true}, nil
+	case t.kind == tokKeyword && t.text == "false":
+		return exprConst{false}, nil
+	case t.kind == tokKeyword && t.text == "function":
+		fn, err := p.functionBody()
+		return exprFunction{fn}, err
+	case t.kind == tokOp && t.text == "(":
+		e, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+// ---- interpreter ----
+
+type scriptSignal int
+
+const (
+	sigNone scriptSignal = iota
+	sigReturn
+	sigBreak
+)
+
+var errScriptSteps = errors.New("script ran too long")
+
+func (s *Script) errorf(line int, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", s.name, line, fmt.Sprintf(format, args...))
+}
+
+// step counts one step of work.
+func (s *Script) step() error {
+	if s.steps++; s.steps > scriptMaxSteps {
+		return fmt.Errorf("%s: %w", s.name, errScriptSteps)
+	}
+	return nil
+}
+
+func (s *Script) exec(stmts []scriptStmt, env *scriptEnv) (scriptSignal, any, error) {
+	for _, st := range stmts {
+		if err := s.step(); err != nil {
+			return sigNone, nil, err
+		}
+		switch st := st.(type) {
+		case stmtLocal:
+			var v any
+			if st.value != nil {
+				var err error
+				if v, err = s.eval(st.value, env); err != nil {
+					return sigNone, nil, err
+				}
+			}
+			env.vars[st.name] = v
+		case stmtAssign:
+			v, err := s.eval(st.value, env)
+			if err != nil {
+				return sigNone, nil, err
+			}
+			if scope, ok := env.lookup(st.name); ok {
+				if _, builtin := scope.vars[st.name].(scriptBuiltin); builtin {
+					return sigNone, nil, s.errorf(st.line, "cannot assign to built-in %s", st.name)
+				}
+				scope.vars[st.name] = v
+			} else {
+				s.globals.vars[st.name] = v
+			}
+		case stmtCall:
+			if _, err := s.eval(st.call, env); err != nil {
+				return sigNone, nil, err
+			}
+		case stmtReturn:
+			var v any
+			if st.value != nil {
+				var err error
+				if v, err = s.eval(st.value, env); err != nil {
+					return sigNone, nil, err
+				}
+			}
+			return sigReturn, v, nil
+		case stmtBreak:
+			return sigBreak, nil, nil
+		case stmtIf:
+			for i, body := range st.blocks {
+				if i < len(st.conds) {
+					c, err := s.eval(st.conds[i], env)
+					if err != nil {
+						return sigNone, nil, err
+					}
+					if !scriptTruthy(c) {
+						continue
+					}
+				}
+				sig, v, err := s.exec(body, &scriptEnv{vars: map[string]any{}, parent: env})
+				if err != nil || sig != sigNone {
+					return sig, v, err
+				}
+				break
+			}
+		case stmtWhile:
+			for {
+				if err := s.step(); err != nil {
+					return sigNone, nil, err
+				}
+				c, err := s.eval(st.cond, env)
+				if err != nil {
+					return sigNone, nil, err
+				}
+				if !scriptTruthy(c) {
+					break
+				}
+				sig, v, err := s.exec(st.body, &scriptEnv{vars: map[string]any{}, parent: env})
+				if err != nil || sig == sigReturn {
+					return sig, v, err
+				}
+				if sig == sigBreak {
+					break
+				}
+			}
+		case stmtFor:
+			var bounds [3]float64
+			bounds[2] = 1
+			for i, e := range []scriptExpr{st.from, st.to, st.step} {
+				if e == nil {
+					continue
+				}
+				v, err := s.eval(e, env)
+				if err != nil {
+					return sigNone, nil, err
+				}
+				f, ok := v.(float64)
+				if !ok {
+					return sigNone, nil, s.errorf(st.line, "for needs numbers, not %s", scriptType(v))
+				}
+				bounds[i] = f
+			}
+			if bounds[2] == 0 {
+				return sigNone, nil, s.errorf(st.line, "for step is zero")
+			}
+			for i := bounds[0]; bounds[2] > 0 && i <= bounds[1] || bounds[2] < 0 && i >= bounds[1]; i += bounds[2] {
+				if err := s.step(); err != nil {
+					return sigNone, nil, err
+				}
+				sig, v, err := s.exec(st.body, &scriptEnv{vars: map[string]any{st.name: i}, parent: env})
+				if err != nil || sig == sigReturn {
+					return sig, v, err
+				}
+				if sig == sigBreak {
+					break
+				}
+			}
+		case stmtFunction:
+			fn := *st.fn
+			fn.env = env
+			if st.local {
+				env.vars[fn.name] = &fn
+			} else {
+				s.globals.vars[fn.name] = &fn
+			}
+		}
+	}
+	return sigNone, nil, nil
+}
+
+func (s *Script) eval(e scriptExpr, env *scriptEnv) (any, error) {
+	switch e := e.(type) {
+	case exprConst:
+		return e.value, nil
+	case exprName:
+		scope, ok := env.lookup(e.name)
+		if !ok {
+			return nil, nil
+		}
+		return scope.vars[e.name], nil
+	case exprFunction:
+		fn := *e.fn
+		fn.env = env
+		return &fn, nil
+	case exprUnary:
+		v, err := s.eval(e.operand, env)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "not" {
+			return !scriptTruthy(v), nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return nil, s.errorf(e.line, "cannot negate %s", scriptType(v))
+		}
+		return -f, nil
+	case exprBinary:
+		left, err := s.eval(e.left, env)
+		if err != nil {
+			return nil, err
+		}
+		// and and or short-circuit and yield an operand, as in Lua.
+		switch e.op {
+		case "and":
+			if !scriptTruthy(left) {
+				return left, nil
+			}
+			return s.eval(e.right, env)
+		case "or":
+			if scriptTruthy(left) {
+				return left, nil
+			}
+			return s.eval(e.right, env)
+		}
+		right, err := s.eval(e.right, env)
+		if err != nil {
+			return nil, err
+		}
+		return s.binary(e, left, right)
+	case exprCall:
+		fv, err := s.eval(e.fn, env)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]any, len(e.args))
+		for i, a := range e.args {
+			if args[i], err = s.eval(a, env); err != nil {
+				return nil, err
+			}
+		}
+		switch f := fv.(type) {
+		case scriptBuiltin:
+			v, err := f(args)
+			if err != nil {
+				return nil, s.errorf(e.line, "%v", err)
+			}
+			return v, nil
+		case *scriptFunc:
+			return s.call(f, args, e.line)
+		}
+		what := "value"
+		if n, ok := e.fn.(exprName); ok {
+			what = n.name
+		}
+		return nil, s.errorf(e.line, "cannot call %s (a %s)", what, scriptType(fv))
+	}
+	return nil, fmt.Errorf("%s: bad expression %T", s.name, e)
+}
+
+func (s *Script) call(f *scriptFunc, args []any, line int) (any, error) {
+	if s.depth++; s.depth > scriptMaxDepth {
+		return nil, s.errorf(line, "calls nested too deeply in %s", f.name)
+	}
+	defer func() { s.depth-- }()
+	env := &scriptEnv{vars: map[string]any{}, parent: f.env}
+	for i, p := range f.params {
+		if i < len(args) {
+			env.vars[p] = args[i]
+		} else {
+			env.vars[p] = nil
+		}
+	}
+	_, v, err := s.exec(f.body, env)
+	return v, err
+}
+
+func (s *Script) binary(e exprBinary, left, right any) (any, error) {
+	switch e.op {
+	case "==":
+		return scriptEqual(left, right), nil
+	case "~=":
+		return !scriptEqual(left, right), nil
+	case "..":
+		return scriptString(left) + scriptString(right), nil
+	}
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok {
+			switch e.op {
+			case "<":
+				return ls < rs, nil
+			case "<=":
+				return ls <= rs, nil
+			case ">":
+				return ls > rs, nil
+			case ">=":
+				return ls >= rs, nil
+			}
+		}
+	}
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, s.errorf(e.line, "cannot apply %s to %s and %s", e.op, scriptType(left), scriptType(right))
+	}
+	switch e.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	case "%":
+		return l - math.Floor(l/r)*r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, s.errorf(e.line, "unknown operator %s", e.op)
+}
+
+// scriptEqual compares values; built-ins, being Go funcs, equal nothing.
+func scriptEqual(a, b any) bool {
+	if _, ok := a.(scriptBuiltin); ok {
+		return false
+	}
+	if _, ok := b.(scriptBuiltin); ok {
+		return false
+	}
+	return a == b
+}
+
+func scriptTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+func scriptType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	}
+	return "function"
+}
+
+func scriptString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return "function"
+}
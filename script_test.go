@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	var said []string
+	s, err := LoadScript("test.lua", `
+-- recursion, loops and closures
+function fib(n)
+  if n < 2 then return n end
+  return fib(n - 1) + fib(n - 2)
+end
+
+function sum(n)
+  local total = 0
+  for i = 1, n do
+    if i > 100 then break end
+    total = total + i
+  end
+  return total
+end
+
+function counter()
+  local n = 0
+  return function() n = n + 1 return n end
+end
+next_id = counter()
+
+function describe(x)
+  if x == nil then return "nothing"
+  elseif x % 2 == 0 then return "even " .. x
+  else return 'odd ' .. x end
+end
+
+function loop() while true do end end
+
+say("loaded")
+`, map[string]scriptBuiltin{
+		"say": func(args []any) (any, error) {
+			said = append(said, scriptString(args[0]))
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(said) != 1 || said[0] != "loaded" {
+		t.Errorf("top level said %q", said)
+	}
+	for _, tc := range []struct {
+		fn   string
+		args []any
+		want any
+	}{
+		{"fib", []any{10.0}, 55.0},
+		{"sum", []any{10.0}, 55.0},
+		{"sum", []any{1000.0}, 5050.0},
+		{"next_id", nil, 1.0},
+		{"next_id", nil, 2.0},
+		{"describe", []any{4.0}, "even 4"},
+		{"describe", []any{7.0}, "odd 7"},
+		{"describe", nil, "nothing"},
+		{"missing", nil, nil},
+	} {
+		got, err := s.Call(tc.fn, tc.args...)
+		if err != nil || got != tc.want {
+			t.Errorf("%s%v = %v, %v; want %v", tc.fn, tc.args, got, err, tc.want)
+		}
+	}
+	if _, err := s.Call("loop"); !errors.Is(err, errScriptSteps) {
+		t.Errorf("endless loop: %v", err)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	for _, src := range []string{
+		"function f( end",
+		"x = = 1",
+		"if true then",
+		`x = "unfinished`,
+		"x = 1 + nil",
+		"nothing()",
+		"function f() return f() end f()",
+	} {
+		if _, err := LoadScript("bad.lua", src, nil); err == nil {
+			t.Errorf("%q: no error", src)
+		} else if !strings.HasPrefix(err.Error(), "bad.lua:") {
+			t.Errorf("%q: error %q has no position", src, err)
+		}
+	}
+}
+
+func TestScriptHost(t *testing.T) {
+	game := NewGameLog(NewBoard(5))
+	var out bytes.Buffer
+	h := &scriptHost{game: game, view: newPipeView(strings.NewReader(""), &out)}
+	var err error
+	h.script, err = LoadScript("drill.lua", `
+function check(color, row, col)
+  if row == 0 or col == 0 or row == size() - 1 or col == size() - 1 then
+    return "not on the edge"
+  end
+end
+
+function genmove(color)
+  for row = 0, size() - 1 do
+    for col = 0, size() - 1 do
+      if at(row, col) == "empty" and legal(row, col) then
+        play(row, col)
+        return
+      end
+    end
+  end
+  pass()
+end
+`, h.builtins())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.check(Black, 0) == "" || h.check(Black, 12) != "" {
+		t.Error("check hook not applied")
+	}
+	game.Play(0)
+	if move, ok := h.genMove(White); !ok || move != 1 {
+		t.Errorf("genmove = %d, %v", move, ok)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// Game scripts (-script file.lua) react to a game as it is played, for
+// teaching drills and simple bots. They are written in the Lua subset of
+// script.go and may define any of these functions:
+//
+//	on_start()                  before the first move
+//	check(color, row, col)      before a human move; return a message to refuse it
+//	on_move(color, row, col)    after every move
+//	genmove(color)              choose the -bot colour's move with play or pass
+//	on_end(result)              at the end, with the SGF result
+//
+// Colours are "black" and "white", and a pass has row and col -1. Scripts
+// can read the game but not change it, beyond genmove's choice:
+//
+//	size()                      board size
+//	turn()                      colour to move
+//	at(row, col)                "black", "white", "empty", or nil off the board
+//	legal(row, col)             whether the side to move may play there
+//	liberties(row, col)         liberties of the group there, 0 if empty
+//	captures(color)             stones color has captured
+//	moves()                     moves played so far
+//	random(n)                   a whole number from 0 to n-1
+//	say(...)                    show a message
+//	play(row, col), pass()      genmove's choice
+
+// scriptHost runs a script against a game. A nil scriptHost does nothing,
+// so playGame can call it unconditionally.
+type scriptHost struct {
+	script *Script
+	game   *GameLog
+	view   gameView
+	rng    *rand.Rand
+
+	choosing bool // in genmove
+	choice   int
+	chosen   bool
+}
+
+func newScriptHost(path string, game *GameLog, view gameView) (*scriptHost, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	h := &scriptHost{game: game, view: view, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	if h.script, err = LoadScript(path, string(src), h.builtins()); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *scriptHost) builtins() map[string]scriptBuiltin {
+	point := func(args []any) (int, int, error) {
+		if len(args) != 2 {
+			return 0, 0, errors.New("want row and col")
+		}
+		row, ok1 := args[0].(float64)
+		col, ok2 := args[1].(float64)
+		if !ok1 || !ok2 || row != float64(int(row)) || col != float64(int(col)) {
+			return 0, 0, errors.New("row and col must be whole numbers")
+		}
+		return int(row), int(col), nil
+	}
+	return map[string]scriptBuiltin{
+		"size": func([]any) (any, error) { return float64(h.game.Board().Size()), nil },
+		"turn": func([]any) (any, error) { return colorKey(h.game.Board().Turn()), nil },
+		"moves": func([]any) (any, error) {
+			return float64(h.game.Moves()), nil
+		},
+		"at": func(args []any) (any, error) {
+			row, col, err := point(args)
+			b := h.game.Board()
+			if err != nil || !inBounds(b, row, col) {
+				return nil, err
+			}
+			if s := b.At(row, col); s != Empty {
+				return colorKey(s), nil
+			}
+			return "empty", nil
+		},
+		"legal": func(args []any) (any, error) {
+			row, col, err := point(args)
+			if err != nil {
+				return nil, err
+			}
+			b := h.game.Board()
+			return inBounds(b, row, col) && b.Copy().PlaceStone(row, col), nil
+		},
+		"liberties": func(args []any) (any, error) {
+			row, col, err := point(args)
+			b := h.game.Board()
+			if err != nil || !inBounds(b, row, col) || b.At(row, col) == Empty {
+				return 0.0, err
+			}
+			_, libs := b.Copy().group(row, col)
+			return float64(libs), nil
+		},
+		"captures": func(args []any) (any, error) {
+			color, ok := scriptColor(args)
+			if !ok {
+				return nil, errors.New(`want "black" or "white"`)
+			}
+			return float64(h.game.Captures(color)), nil
+		},
+		"random": func(args []any) (any, error) {
+			var n float64
+			if len(args) == 1 {
+				n, _ = args[0].(float64)
+			}
+			if n < 1 {
+				return nil, errors.New("random needs a positive number")
+			}
+			return float64(h.rng.Intn(int(n))), nil
+		},
+		"say": func(args []any) (any, error) {
+			parts := make([]string, len(args))
+			for i, a := range args {
+				parts[i] = scriptString(a)
+			}
+			h.view.Message(strings.Join(parts, " "))
+			return nil, nil
+		},
+		"play": func(args []any) (any, error) {
+			row, col, err := point(args)
+			if err != nil {
+				return nil, err
+			}
+			if !h.choosing {
+				return nil, errors.New("play may only be called from genmove")
+			}
+			b := h.game.Board()
+			if !inBounds(b, row, col) {
+				return nil, fmt.Errorf("%d %d is off the board", row, col)
+			}
+			h.choice, h.chosen = row*b.Size()+col, true
+			return nil, nil
+		},
+		"pass": func([]any) (any, error) {
+			if !h.choosing {
+				return nil, errors.New("pass may only be called from genmove")
+			}
+			h.choice, h.chosen = PassMove, true
+			return nil, nil
+		},
+	}
+}
+
+func scriptColor(args []any) (Stone, bool) {
+	var s string
+	if len(args) == 1 {
+		s, _ = args[0].(string)
+	}
+	switch s {
+	case "black":
+		return Black, true
+	case "white":
+		return White, true
+	}
+	return Empty, false
+}
+
+// moveArgs are the colour, row and column a hook gets for a move.
+func moveArgs(color Stone, move, size int) []any {
+	row, col := -1.0, -1.0
+	if move != PassMove {
+		row, col = float64(move/size), float64(move%size)
+	}
+	return []any{colorKey(color), row, col}
+}
+
+// hook calls a script function, showing any error instead of stopping the
+// game.
+func (h *scriptHost) hook(name string, args ...any) any {
+	if h == nil {
+		return nil
+	}
+	v, err := h.script.Call(name, args...)
+	if err != nil {
+		h.view.Message("script error: " + err.Error())
+	}
+	return v
+}
+
+func (h *scriptHost) started() { h.hook("on_start") }
+
+// check is the script's objection to a human move, or "".
+func (h *scriptHost) check(color Stone, move int) string {
+	if h == nil {
+		return ""
+	}
+	if v := h.hook("check", moveArgs(color, move, h.game.Board().Size())...); v != nil && v != false {
+		return scriptString(v)
+	}
+	return ""
+}
+
+func (h *scriptHost) moved(color Stone, move int) {
+	if h != nil {
+		h.hook("on_move", moveArgs(color, move, h.game.Board().Size())...)
+	}
+}
+
+func (h *scriptHost) ended(result string) { h.hook("on_end", result) }
+
+// plays reports whether the script chooses the computer's moves.
+func (h *scriptHost) plays() bool { return h != nil && h.script.Has("genmove") }
+
+// genMove asks the script for color's move; ok is false if it chose none
+// or an illegal one.
+func (h *scriptHost) genMove(color Stone) (move int, ok bool) {
+	h.choosing, h.chosen = true, false
+	h.hook("genmove", colorKey(color))
+	h.choosing = false
+	if !h.chosen {
+		return 0, false
+	}
+	if h.choice == PassMove {
+		return PassMove, true
+	}
+	return h.choice, h.game.Board().Copy().Play(h.choice)
+}
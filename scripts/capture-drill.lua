@@ -0,0 +1,63 @@
+-- A capturing drill: the computer plays next to your last stone, and
+-- you are told whenever one of its groups is in atari and you miss the
+-- capture.
+--
+--   ./gogame -bot white -script scripts/capture-drill.lua
+
+function on_start()
+  say("Capture drill: take any white group in atari.")
+end
+
+-- atari_point is a liberty of a white group in atari, or nil.
+function atari_point()
+  local n = size()
+  for row = 0, n - 1 do
+    for col = 0, n - 1 do
+      if at(row, col) == "white" and liberties(row, col) == 1 then
+        local found = liberty_of(row, col)
+        if found then return found end
+      end
+    end
+  end
+end
+
+-- liberty_of is an empty neighbour of the stone at row, col, as "row col".
+function liberty_of(row, col)
+  if at(row - 1, col) == "empty" then return (row - 1) .. " " .. col end
+  if at(row + 1, col) == "empty" then return (row + 1) .. " " .. col end
+  if at(row, col - 1) == "empty" then return row .. " " .. (col - 1) end
+  if at(row, col + 1) == "empty" then return row .. " " .. (col + 1) end
+end
+
+local missed = nil
+local taken = 0
+
+function check(color, row, col)
+  missed = atari_point()
+  taken = captures("black")
+end
+
+function on_move(color, row, col)
+  if color == "black" and missed and captures("black") == taken then
+    say("You could have captured at " .. missed)
+  end
+  last_row = row
+  last_col = col
+end
+
+function genmove(color)
+  if last_row == nil or last_row < 0 then
+    return
+  end
+  -- Play next to the human's last stone when possible.
+  local tries = 0
+  while tries < 20 do
+    local row = last_row + random(3) - 1
+    local col = last_col + random(3) - 1
+    if legal(row, col) then
+      play(row, col)
+      return
+    end
+    tries = tries + 1
+  end
+end
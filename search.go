@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func init() {
+	commands["search"] = runSearch
+}
+
+// SearchHit is a database game reaching the searched position after Move
+// moves.
+type SearchHit struct {
+	Game *DBGame
+	Move int
+}
+
+// SearchGames replays every game of the given size and reports the first
+// position of each that satisfies match.
+func SearchGames(games []DBGame, size int, match func(b *Board) bool) ([]SearchHit, error) {
+	var hits []SearchHit
+	for i := range games {
+		dbg := &games[i]
+		if dbg.Size != size {
+			continue
+		}
+		g, err := dbg.Record()
+		if err != nil {
+			return nil, err
+		}
+		b := g.Start()
+		if match(b) {
+			hits = append(hits, SearchHit{dbg, 0})
+			continue
+		}
+		for n, m := range g.Moves {
+			b.turn = m.Color
+			if !b.Play(m.Move) {
+				break
+			}
+			if match(b) {
+				hits = append(hits, SearchHit{dbg, n + 1})
+				break
+			}
+		}
+	}
+	return hits, nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dbPath := fs.String("db", defaultStore(), "game database: a file, memory:, postgres://... or sqlite:PATH (see store.go)")
+	patternFile := fs.String("pattern", "", "text diagram of a local pattern to find anywhere on the board")
+	sgfFile := fs.String("sgf", "", "SGF file whose position to find")
+	moveNum := fs.Int("move", -1, "with -sgf, the position after this many moves (default: the end)")
+	size := fs.Int("size", 19, "board size, for -pattern")
+	export := fs.String("export", "", "write the matching games to this SGF file for review")
+	fs.Parse(args)
+
+	var match func(b *Board) bool
+	switch {
+	case *patternFile != "":
+		text, err := os.ReadFile(*patternFile)
+		if err != nil {
+			return err
+		}
+		p, err := ParsePattern(string(text))
+		if err != nil {
+			return err
+		}
+		match = p.Match
+	case *sgfFile != "":
+		games, err := ReadSGFFile(*sgfFile)
+		if err != nil {
+			return err
+		}
+		g, err := RecordFromSGF(games[0])
+		if err != nil {
+			return err
+		}
+		n := len(g.Moves)
+		if *moveNum >= 0 {
+			n = *moveNum
+		}
+		target, err := g.Replay(n)
+		if err != nil {
+			return err
+		}
+		*size = g.Size
+		want := target.CanonicalHash()
+		match = func(b *Board) bool { return b.CanonicalHash() == want }
+	default:
+		return errors.New("give a -pattern or an -sgf position to search for")
+	}
+
+	games, err := loadGames(*dbPath)
+	if err != nil {
+		return err
+	}
+	hits, err := SearchGames(games, *size, match)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d games match\n", len(hits))
+	for i, h := range hits {
+		fmt.Printf("%3d  %-16s %s (%s) vs %s (%s) %s, move %d\n", i+1, h.Game.ID,
+			h.Game.Black, h.Game.BlackRank, h.Game.White, h.Game.WhiteRank, h.Game.Result, h.Move)
+	}
+
+	if *export == "" || len(hits) == 0 {
+		return nil
+	}
+	var collection []*SGFNode
+	for _, h := range hits {
+		g, err := h.Game.Record()
+		if err != nil {
+			return err
+		}
+		root := g.SGF()
+		root.Set("GN", h.Game.ID)
+		node := root
+		for i := 0; i < h.Move && len(node.Children) > 0; i++ {
+			node = node.Children[0]
+		}
+		node.Set("C", "Position search match after move "+strconv.Itoa(h.Move))
+		if err := AddPositionHashes(root); err != nil {
+			return err
+		}
+		collection = append(collection, root)
+	}
+	return WriteSGFFile(*export, collection)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+func init() {
+	commands["debug"] = runDebug
+}
+
+// SearchNode is a node of the engine's search tree as DebugSearch reports
+// it. Q is the mean result for Color, the colour that played Move, so a
+// child is good for the side choosing it when its Q is high. The engine
+// has no policy, so Prior is uniform over the moves the node's parent
+// could try.
+type SearchNode struct {
+	Move     int
+	Color    Stone
+	Visits   int
+	Q        float64
+	Prior    float64
+	Children []*SearchNode // most visited first
+}
+
+// DebugSearch searches b like GenMove and returns the top of the tree: up
+// to width children of each node, most visited first, down to depth
+// moves, leaving out nodes with fewer than minVisits visits.
+func (e *Engine) DebugSearch(ctx context.Context, b BoardView, depth, width, minVisits int) (*SearchNode, error) {
+	root, err := e.search(ctx, b.Copy(), nil)
+	return snapshotNode(root, 1, depth, width, minVisits), err
+}
+
+func snapshotNode(n *node, prior float64, depth, width, minVisits int) *SearchNode {
+	s := &SearchNode{Move: n.move, Color: n.player, Visits: n.visits, Prior: prior}
+	if n.visits > 0 {
+		s.Q = n.wins / float64(n.visits)
+	}
+	if depth == 0 {
+		return s
+	}
+	children := append([]*node(nil), n.children...)
+	sort.SliceStable(children, func(i, j int) bool { return children[i].visits > children[j].visits })
+	for _, c := range children[:min(width, len(children))] {
+		if c.visits < max(minVisits, 1) {
+			break
+		}
+		moves := len(n.children) + len(n.untried)
+		s.Children = append(s.Children, snapshotNode(c, 1/float64(moves), depth-1, width, minVisits))
+	}
+	return s
+}
+
+// WriteSearchTree prints the tree under root, one node per line with its
+// visits, Q, prior and the UCT value its parent selects it by.
+func WriteSearchTree(w io.Writer, root *SearchNode, size int) {
+	fmt.Fprintf(w, "%s to move  visits %d  win rate %.3f\n", root.Color.Opponent(), root.Visits, 1-root.Q)
+	var walk func(n *SearchNode, indent string)
+	walk = func(n *SearchNode, indent string) {
+		for i, c := range n.Children {
+			branch, next := "├─ ", "│  "
+			if i == len(n.Children)-1 {
+				branch, next = "└─ ", "   "
+			}
+			uct := c.Q + uctExploration*math.Sqrt(math.Log(float64(n.Visits))/float64(c.Visits))
+			move := fmt.Sprintf("%s %s", c.Color, userCoords.Move(c.Move, size))
+			fmt.Fprintf(w, "%s%s%-*s visits %6d  Q %.3f  prior %.3f  uct %.3f\n", indent, branch,
+				max(12-len([]rune(indent)), 0), move, c.Visits, c.Q, c.Prior, uct)
+			walk(c, indent+next)
+		}
+	}
+	walk(root, "")
+}
+
+func runDebug(args []string) error {
+	if len(args) == 0 || args[0] != "search" {
+		return errors.New("usage: debug search [file.sgf] [flags]")
+	}
+	fs := flag.NewFlagSet("debug search", flag.ExitOnError)
+	move := fs.Int("move", -1, "position after this many moves (default the end of the game)")
+	game := fs.Int("game", 1, "game number within the file")
+	size := fs.Int("size", 9, "board size when no file is given (an empty board)")
+	playouts := fs.Int("playouts", 2000, "playouts for the search")
+	threads := fs.Int("threads", 1, "parallel search workers")
+	komi := fs.Float64("komi", 6.5, "komi when no file is given")
+	depth := fs.Int("depth", 3, "moves deep to print")
+	width := fs.Int("width", 5, "children printed per node, most visited first")
+	minVisits := fs.Int("min-visits", 1, "leave out nodes with fewer visits")
+	cpuProfile := fs.String("cpuprofile", "", "write a CPU profile of the search to this file, for go tool pprof")
+	memProfile := fs.String("memprofile", "", "write a heap profile after the search to this file")
+	fs.Parse(args[1:])
+	path := ""
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+		fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	}
+
+	b := NewBoard(*size)
+	if path != "" {
+		games, err := ReadSGFFile(path)
+		if err != nil {
+			return err
+		}
+		if *game < 1 || *game > len(games) {
+			return fmt.Errorf("%s has %d games", path, len(games))
+		}
+		g, err := RecordFromSGF(games[*game-1])
+		if err != nil {
+			return err
+		}
+		n := len(g.Moves)
+		if *move >= 0 {
+			n = min(*move, n)
+		}
+		if b, err = g.Replay(n); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		*komi = g.Komi
+	}
+	e := NewEngine(*playouts, *komi)
+	e.Threads = *threads
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	root, err := e.DebugSearch(context.Background(), b, *depth, *width, *minVisits)
+	elapsed := time.Since(start)
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if err != nil {
+		return err
+	}
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			return err
+		}
+		runtime.GC()
+		err = pprof.WriteHeapProfile(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	stats := e.Stats()
+	WriteSearchTree(os.Stdout, root, b.size)
+	fmt.Printf("%d playouts in %s (%.0f/s), %d nodes, %.1f MB, %d recycled\n", root.Visits, elapsed.Round(time.Millisecond),
+		float64(root.Visits)/elapsed.Seconds(), stats.Nodes, float64(stats.Memory)/(1<<20), stats.Recycled)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDebugSearch(t *testing.T) {
+	e := NewEngine(300, 6.5)
+	root, err := e.DebugSearch(context.Background(), NewBoard(5), 2, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Visits != 300 || root.Color != White || len(root.Children) != 3 {
+		t.Fatalf("root: %d visits, %s, %d children", root.Visits, root.Color, len(root.Children))
+	}
+	for i, c := range root.Children {
+		if i > 0 && c.Visits > root.Children[i-1].Visits {
+			t.Errorf("children not by visits: %d after %d", c.Visits, root.Children[i-1].Visits)
+		}
+		if c.Color != Black || c.Prior != 1.0/26 {
+			t.Errorf("child %d: %s, prior %v", i, c.Color, c.Prior)
+		}
+		for _, g := range c.Children {
+			if g.Visits < 2 || len(g.Children) != 0 {
+				t.Errorf("grandchild with %d visits and %d children", g.Visits, len(g.Children))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	WriteSearchTree(&sb, root, 5)
+	if lines := strings.Split(sb.String(), "\n"); !strings.HasPrefix(lines[0], "● to move  visits 300") || !strings.HasPrefix(lines[1], "├─ ● ") {
+		t.Errorf("tree:\n%s", sb.String())
+	}
+}
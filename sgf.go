@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SGFProperty is one property of a node with its raw (unescaped) values.
+type SGFProperty struct {
+	ID     string
+	Values []string
+}
+
+// SGFNode is a node of an SGF game tree. The first child continues the main
+// line; further children are variations.
+type SGFNode struct {
+	Props    []SGFProperty
+	Children []*SGFNode
+}
+
+// Get returns the first value of property id, or "".
+func (n *SGFNode) Get(id string) string {
+	for _, p := range n.Props {
+		if p.ID == id && len(p.Values) > 0 {
+			return p.Values[0]
+		}
+	}
+	return ""
+}
+
+// Values returns all values of property id.
+func (n *SGFNode) Values(id string) []string {
+	for _, p := range n.Props {
+		if p.ID == id {
+			return p.Values
+		}
+	}
+	return nil
+}
+
+// Set replaces property id, adding it if needed. No values removes it.
+func (n *SGFNode) Set(id string, values ...string) {
+	for i, p := range n.Props {
+		if p.ID == id {
+			if len(values) == 0 {
+				n.Props = append(n.Props[:i], n.Props[i+1:]...)
+			} else {
+				n.Props[i].Values = values
+			}
+			return
+		}
+	}
+	if len(values) > 0 {
+		n.Props = append(n.Props, SGFProperty{ID: id, Values: values})
+	}
+}
+
+// MainLine returns the nodes from n following first children.
+func (n *SGFNode) MainLine() []*SGFNode {
+	var line []*SGFNode
+	for ; n != nil; n = firstChild(n) {
+		line = append(line, n)
+	}
+	return line
+}
+
+func firstChild(n *SGFNode) *SGFNode {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+type sgfParser struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (p *sgfParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("sgf: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *sgfParser) next() (rune, error) {
+	c, _, err := p.r.ReadRune()
+	if c == '\n' {
+		p.line++
+	}
+	return c, err
+}
+
+// skipSpace returns the next non-space rune.
+func (p *sgfParser) skipSpace() (rune, error) {
+	for {
+		c, err := p.next()
+		if err != nil || !unicode.IsSpace(c) {
+			return c, err
+		}
+	}
+}
+
+// ParseSGF reads a collection: every game tree in the input.
+func ParseSGF(r io.Reader) ([]*SGFNode, error) {
+	p := &sgfParser{r: bufio.NewReader(r), line: 1}
+	var games []*SGFNode
+	for {
+		c, err := p.skipSpace()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c != '(' {
+			// Text before and between game trees is ignored, as the spec allows.
+			continue
+		}
+		root, err := p.parseTree()
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, root)
+	}
+	if len(games) == 0 {
+		return nil, errors.New("sgf: no game trees found")
+	}
+	return games, nil
+}
+
+// parseTree parses a game tree after its opening parenthesis and returns
+// the first node of its sequence.
+func (p *sgfParser) parseTree() (*SGFNode, error) {
+	var first, last *SGFNode
+	for {
+		c, err := p.skipSpace()
+		if err != nil {
+			return nil, p.errorf("unterminated game tree")
+		}
+		switch c {
+		case ';':
+			n, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			if first == nil {
+				first = n
+			} else {
+				last.Children = append(last.Children, n)
+			}
+			last = n
+		case '(':
+			if last == nil {
+				return nil, p.errorf("variation before the first node")
+			}
+			child, err := p.parseTree()
+			if err != nil {
+				return nil, err
+			}
+			last.Children = append(last.Children, child)
+		case ')':
+			if first == nil {
+				return nil, p.errorf("empty game tree")
+			}
+			return first, nil
+		default:
+			return nil, p.errorf("unexpected %q", c)
+		}
+	}
+}
+
+func (p *sgfParser) parseNode() (*SGFNode, error) {
+	n := &SGFNode{}
+	for {
+		c, err := p.skipSpace()
+		if err != nil {
+			return nil, p.errorf("unterminated node")
+		}
+		if !unicode.IsLetter(c) {
+			p.r.UnreadRune()
+			return n, nil
+		}
+		var id strings.Builder
+		for unicode.IsLetter(c) {
+			// FF[3] long names like "AddBlack" reduce to their capitals.
+			if unicode.IsUpper(c) {
+				id.WriteRune(c)
+			}
+			if c, err = p.next(); err != nil {
+				return nil, p.errorf("unterminated property")
+			}
+		}
+		var values []string
+		for {
+			for unicode.IsSpace(c) {
+				if c, err = p.next(); err != nil {
+					return nil, p.errorf("unterminated property")
+				}
+			}
+			if c != '[' {
+				p.r.UnreadRune()
+				break
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if c, err = p.next(); err != nil {
+				break
+			}
+		}
+		if len(values) == 0 {
+			return nil, p.errorf("property %s has no value", id.String())
+		}
+		n.Props = append(n.Props, SGFProperty{ID: id.String(), Values: values})
+	}
+}
+
+func (p *sgfParser) parseValue() (string, error) {
+	var v strings.Builder
+	for {
+		c, err := p.next()
+		if err != nil {
+			return "", p.errorf("unterminated property value")
+		}
+		switch c {
+		case ']':
+			return v.String(), nil
+		case '\\':
+			c, err = p.next()
+			if err != nil {
+				return "", p.errorf("unterminated property value")
+			}
+			if c == '\n' || c == '\r' {
+				continue // soft line break
+			}
+		}
+		v.WriteRune(c)
+	}
+}
+
+// WriteSGF writes a collection, one game tree after another.
+func WriteSGF(w io.Writer, games []*SGFNode) error {
+	bw := bufio.NewWriter(w)
+	for _, g := range games {
+		writeSGFTree(bw, g)
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+func writeSGFTree(w *bufio.Writer, n *SGFNode) {
+	w.WriteByte('(')
+	for {
+		w.WriteByte(';')
+		for _, p := range n.Props {
+			w.WriteString(p.ID)
+			for _, v := range p.Values {
+				w.WriteByte('[')
+				w.WriteString(escapeSGF(v))
+				w.WriteByte(']')
+			}
+		}
+		if len(n.Children) != 1 {
+			break
+		}
+		n = n.Children[0]
+		w.WriteByte('\n')
+	}
+	for _, c := range n.Children {
+		writeSGFTree(w, c)
+	}
+	w.WriteByte(')')
+}
+
+func escapeSGF(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `]`, `\]`).Replace(v)
+}
+
+// sgfPoint converts an SGF point such as "dd" to a move index. An empty
+// value, or "tt" on boards up to 19x19, is a pass.
+func sgfPoint(v string, size int) (int, error) {
+	if v == "" || (v == "tt" && size <= 19) {
+		return PassMove, nil
+	}
+	if len(v) != 2 {
+		return 0, fmt.Errorf("sgf: invalid point %q", v)
+	}
+	col, row := sgfCoord(v[0]), sgfCoord(v[1])
+	if col < 0 || row < 0 || col >= size || row >= size {
+		return 0, fmt.Errorf("sgf: point %q is off the %dx%d board", v, size, size)
+	}
+	return row*size + col, nil
+}
+
+func sgfCoord(c byte) int {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 26
+	}
+	return -1
+}
+
+func sgfLetter(i int) byte {
+	if i < 26 {
+		return byte('a' + i)
+	}
+	return byte('A' + i - 26)
+}
+
+// sgfPointString converts a move index to an SGF point; passes are "".
+func sgfPointString(move, size int) string {
+	if move == PassMove {
+		return ""
+	}
+	return string([]byte{sgfLetter(move % size), sgfLetter(move / size)})
+}
+
+// RecordFromSGF converts the main line of a game tree to a GameRecord.
+func RecordFromSGF(root *SGFNode) (*GameRecord, error) {
+	g := &GameRecord{
+		Size:      19,
+		Black:     root.Get("PB"),
+		White:     root.Get("PW"),
+		BlackRank: root.Get("BR"),
+		WhiteRank: root.Get("WR"),
+		Result:    root.Get("RE"),
+		Date:      root.Get("DT"),
+	}
+	if sz := root.Get("SZ"); sz != "" {
+		size, err := strconv.Atoi(strings.SplitN(sz, ":", 2)[0])
+		if err != nil || size < 1 || size > 52 {
+			return nil, fmt.Errorf("sgf: invalid board size %q", sz)
+		}
+		g.Size = size
+	}
+	if km := root.Get("KM"); km != "" {
+		g.Komi, _ = strconv.ParseFloat(km, 64)
+	}
+	if ha := root.Get("HA"); ha != "" {
+		g.Handicap, _ = strconv.Atoi(ha)
+	}
+	for _, p := range root.Props {
+		switch p.ID {
+		case "PB", "PW", "BR", "WR", "RE", "DT", "KM", "HA":
+		default:
+			if IsGameInfoProperty(p.ID) {
+				if g.Info == nil {
+					g.Info = make(map[string]string)
+				}
+				g.Info[p.ID] = p.Values[0]
+			}
+		}
+	}
+	for _, n := range root.MainLine() {
+		for _, setup := range []struct {
+			id    string
+			color Stone
+		}{{"AB", Black}, {"AW", White}, {"AE", Empty}} {
+			if len(g.Moves) > 0 {
+				break // setup after the first move only lives in the tree
+			}
+			for _, v := range n.Values(setup.id) {
+				points, err := sgfPointList(v, g.Size)
+				if err != nil {
+					return nil, err
+				}
+				for _, m := range points {
+					g.Setup = append(g.Setup, RecordedMove{Color: setup.color, Move: m})
+				}
+			}
+		}
+		for _, id := range []string{"B", "W"} {
+			if len(n.Values(id)) == 0 {
+				continue
+			}
+			m, err := sgfPoint(n.Get(id), g.Size)
+			if err != nil {
+				return nil, err
+			}
+			rm := RecordedMove{Color: Black, Move: m, Comment: n.Get("C")}
+			timeID, periodsID := "BL", "OB"
+			if id == "W" {
+				rm.Color = White
+				timeID, periodsID = "WL", "OW"
+			}
+			rm.TimeLeft, _ = strconv.ParseFloat(n.Get(timeID), 64)
+			rm.Periods, _ = strconv.Atoi(n.Get(periodsID))
+			for _, p := range n.Props {
+				if sgfMarkupIDs[p.ID] {
+					rm.Markup = append(rm.Markup, p)
+				}
+			}
+			g.Moves = append(g.Moves, rm)
+		}
+	}
+	return g, nil
+}
+
+// sgfMarkupIDs are the markup and annotation properties carried by
+// RecordedMove.Markup.
+var sgfMarkupIDs = map[string]bool{
+	"AR": true, "CR": true, "DD": true, "LB": true, "LN": true, "MA": true,
+	"SL": true, "SQ": true, "TR": true, "TB": true, "TW": true,
+	"BM": true, "DO": true, "IT": true, "TE": true,
+	"DM": true, "GB": true, "GW": true, "HO": true, "UC": true, "V": true, "N": true,
+}
+
+// sgfPointList expands a point or a compressed "aa:cc" rectangle.
+func sgfPointList(v string, size int) ([]int, error) {
+	corners := strings.SplitN(v, ":", 2)
+	if len(corners) == 1 {
+		m, err := sgfPoint(v, size)
+		if err != nil || m == PassMove {
+			return nil, err
+		}
+		return []int{m}, nil
+	}
+	a, err := sgfPoint(corners[0], size)
+	if err != nil {
+		return nil, err
+	}
+	b, err := sgfPoint(corners[1], size)
+	if err != nil {
+		return nil, err
+	}
+	if a == PassMove || b == PassMove {
+		return nil, fmt.Errorf("sgf: invalid rectangle %q", v)
+	}
+	var points []int
+	for row := min(a/size, b/size); row <= max(a/size, b/size); row++ {
+		for col := min(a%size, b%size); col <= max(a%size, b%size); col++ {
+			points = append(points, row*size+col)
+		}
+	}
+	return points, nil
+}
+
+// SGF converts the record to a game tree with a single main line.
+func (g *GameRecord) SGF() *SGFNode {
+	root := &SGFNode{}
+	root.Set("GM", "1")
+	root.Set("FF", "4")
+	root.Set("SZ", strconv.Itoa(g.Size))
+	root.Set("KM", strconv.FormatFloat(g.Komi, 'f', -1, 64))
+	if g.Handicap > 1 {
+		root.Set("HA", strconv.Itoa(g.Handicap))
+	}
+	for _, p := range [][2]string{{"PB", g.Black}, {"BR", g.BlackRank}, {"PW", g.White}, {"WR", g.WhiteRank}, {"DT", g.Date}, {"RE", g.Result}} {
+		if p[1] != "" {
+			root.Set(p[0], p[1])
+		}
+	}
+	ids := make([]string, 0, len(g.Info))
+	for id := range g.Info {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		root.Set(id, g.Info[id])
+	}
+	setup := map[Stone][]string{}
+	for _, s := range g.Setup {
+		setup[s.Color] = append(setup[s.Color], sgfPointString(s.Move, g.Size))
+	}
+	root.Set("AB", setup[Black]...)
+	root.Set("AW", setup[White]...)
+	root.Set("AE", setup[Empty]...)
+
+	last := root
+	for _, m := range g.Moves {
+		n := &SGFNode{}
+		id := "B"
+		if m.Color == White {
+			id = "W"
+		}
+		n.Props = append(n.Props, SGFProperty{ID: id, Values: []string{sgfPointString(m.Move, g.Size)}})
+		if m.TimeLeft != 0 {
+			n.Set(id+"L", strconv.FormatFloat(m.TimeLeft, 'f', -1, 64))
+		}
+		if m.Periods != 0 {
+			n.Set("O"+id, strconv.Itoa(m.Periods))
+		}
+		if m.Comment != "" {
+			n.Set("C", m.Comment)
+		}
+		n.Props = append(n.Props, m.Markup...)
+		last.Children = append(last.Children, n)
+		last = n
+	}
+	return root
+}
+
+// ReadSGFFile parses every game tree in the file at path. A file ending in
+// .pb holds one game as a gogame.v1.Game protocol buffer instead.
+func ReadSGFFile(path string) ([]*SGFNode, error) {
+	if strings.HasSuffix(path, ".pb") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		g, err := UnmarshalProtoGame(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []*SGFNode{g.SGF()}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	games, err := ParseSGF(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return games, nil
+}
+
+// WriteSGFFile writes a collection to path, or its one game as a protocol
+// buffer when path ends in .pb.
+func WriteSGFFile(path string, games []*SGFNode) error {
+	if strings.HasSuffix(path, ".pb") {
+		if len(games) != 1 {
+			return fmt.Errorf("%s: a .pb file holds one game, not %d", path, len(games))
+		}
+		g, err := RecordFromSGF(games[0])
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return saveFile(path, g.MarshalProto())
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteSGF(f, games); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
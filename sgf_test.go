@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSGF = `(;GM[1]FF[4]SZ[9]KM[6.5]PB[Alice]PW[Bob]RE[B+3.5]AB[aa:ab]
+;B[ee];W[cc]C[a \] bracket and a \\ backslash]
+(;B[gc];W[tt])
+(;B[cg]))
+(;GM[1]SZ[9];B[ee];W[])`
+
+func TestSGFRoundTrip(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader(testSGF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("parsed %d game trees, want 2", len(games))
+	}
+	var buf bytes.Buffer
+	if err := WriteSGF(&buf, games); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseSGF(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(games, again) {
+		t.Errorf("round trip changed the collection:\n%s", buf.String())
+	}
+}
+
+func TestRecordFromSGF(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader(testSGF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := RecordFromSGF(games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Size != 9 || g.Komi != 6.5 || g.Black != "Alice" || g.Result != "B+3.5" {
+		t.Errorf("bad game info: %+v", g)
+	}
+	if len(g.Setup) != 2 || g.Setup[1].Move != 9 {
+		t.Errorf("setup = %+v, want a1 and a2", g.Setup)
+	}
+	wantMoves := []int{4*9 + 4, 2*9 + 2, 2*9 + 6, PassMove}
+	for i, m := range g.Moves {
+		if m.Move != wantMoves[i] {
+			t.Errorf("move %d = %d, want %d", i+1, m.Move, wantMoves[i])
+		}
+	}
+	if g.Moves[1].Comment != `a ] bracket and a \ backslash` {
+		t.Errorf("comment = %q", g.Moves[1].Comment)
+	}
+}
+
+func TestValidateSGF(t *testing.T) {
+	src := `(;GM[1]FF[4]SZ[9]KM[six]RE[B+3.5]XX[1]
+;B[ee]AB[aa];W[zz]SZ[9]
+;B[cc]PB[late]TE[3]LB[dd:A][ee:B])`
+	games, err := ParseSGF(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"game 1, node 0, KM: not a real number: \"six\"",
+		"game 1, node 0, XX: not an FF[4] property",
+		"game 1, node 1: move and setup properties in the same node",
+		"game 1, node 2, W: not a move: \"zz\"",
+		"game 1, node 2, SZ: root property outside the root node",
+		"game 1, node 3, TE: must be 1 or 2: \"3\"",
+		"game 1, node 3: game info appears twice on the same line of play",
+	}
+	issues := ValidateSGF(games)
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %v", len(issues), len(want), issues)
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %s, want %s", i, issue, want[i])
+		}
+	}
+}
+
+func TestValidateAcceptsFullGame(t *testing.T) {
+	src := `(;GM[1]FF[4]CA[UTF-8]AP[gogame:1]ST[2]SZ[19]KM[6.5]HA[2]RU[Japanese]
+TM[3600]OT[5x30 byo-yomi]PB[A]PW[B]DT[2024-05-01,02]EV[Cup]RE[W+R]AB[dd][pp]
+;W[dp]WL[3590.5]OW[5]C[Hello];B[pd]BL[3500]TE[1]CR[dp]LB[dd:a]AR[aa:bb]
+;W[]DD[]VW[aa:ss])`
+	games, err := ParseSGF(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := ValidateSGF(games); len(issues) != 0 {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+	g, err := RecordFromSGF(games[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Info["RU"] != "Japanese" || g.Moves[0].TimeLeft != 3590.5 || g.Moves[0].Periods != 5 || len(g.Moves[1].Markup) != 4 {
+		t.Errorf("lost information converting to a record: %+v", g)
+	}
+	again, err := RecordFromSGF(g.SGF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, g) {
+		t.Errorf("record changed through SGF:\n got %+v\nwant %+v", again, g)
+	}
+}
+
+func TestPositionHashes(t *testing.T) {
+	games, err := ParseSGF(strings.NewReader("(;SZ[9]AB[cc];W[ee];B[dd](;W[ff])(;W[gg]))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := games[0]
+	if err := AddPositionHashes(root); err != nil {
+		t.Fatal(err)
+	}
+	if issues := ValidateSGF(games); len(issues) > 0 {
+		t.Errorf("hashed tree does not validate: %v", issues)
+	}
+	if n, err := VerifyPositionHashes(root); err != nil || n != 5 {
+		t.Fatalf("verified %d hashes: %v", n, err)
+	}
+	v1, v2 := root.Children[0].Children[0].Children[0], root.Children[0].Children[0].Children[1]
+	if v1.Get(sgfHashID) == v2.Get(sgfHashID) {
+		t.Error("different positions share a hash")
+	}
+	v2.Set("W", "hh")
+	if _, err := VerifyPositionHashes(root); err == nil {
+		t.Error("edited move still verifies")
+	}
+}
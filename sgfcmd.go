@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["sgf"] = runSGF
+}
+
+// readCollections parses every game tree in the given files.
+func readCollections(paths []string) ([]*SGFNode, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no SGF files given")
+	}
+	var games []*SGFNode
+	for _, path := range paths {
+		g, err := ReadSGFFile(path)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g...)
+	}
+	return games, nil
+}
+
+func writeCollection(path string, games []*SGFNode) error {
+	if path == "" {
+		return WriteSGF(os.Stdout, games)
+	}
+	return WriteSGFFile(path, games)
+}
+
+// SGFFilter selects games by their root properties. Zero fields match
+// everything.
+type SGFFilter struct {
+	Player string // substring of either player's name, case-insensitive
+	Result string // prefix of RE, e.g. "B+" or "W+R"
+	Size   int
+	After  string // DT on or after this YYYY-MM-DD date
+	Before string // DT before this YYYY-MM-DD date
+}
+
+func (f SGFFilter) Match(root *SGFNode) bool {
+	if f.Player != "" {
+		p := strings.ToLower(f.Player)
+		if !strings.Contains(strings.ToLower(root.Get("PB")), p) && !strings.Contains(strings.ToLower(root.Get("PW")), p) {
+			return false
+		}
+	}
+	if f.Result != "" && !strings.HasPrefix(strings.ToUpper(root.Get("RE")), strings.ToUpper(f.Result)) {
+		return false
+	}
+	if f.Size != 0 {
+		size := 19
+		if sz := root.Get("SZ"); sz != "" {
+			size, _ = strconv.Atoi(strings.SplitN(sz, ":", 2)[0])
+		}
+		if size != f.Size {
+			return false
+		}
+	}
+	// DT may list several dates; the first one is the start of the game.
+	date := strings.SplitN(root.Get("DT"), ",", 2)[0]
+	if f.After != "" && (date == "" || date < f.After) {
+		return false
+	}
+	if f.Before != "" && (date == "" || date >= f.Before) {
+		return false
+	}
+	return true
+}
+
+// gameKey identifies a game by its starting position and main line, so
+// the same game with different metadata or comments still matches.
+func gameKey(root *SGFNode) (string, error) {
+	g, err := RecordFromSGF(root)
+	if err != nil {
+		return "", err
+	}
+	moves := make([]int, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i] = m.Move
+	}
+	return string(EncodePosition(g.Start())) + string(EncodeMoves(g.Size, moves)), nil
+}
+
+// Dedupe drops games whose main line repeats an earlier game.
+func Dedupe(games []*SGFNode) ([]*SGFNode, error) {
+	seen := make(map[string]bool)
+	var unique []*SGFNode
+	for _, g := range games {
+		key, err := gameKey(g)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, g)
+		}
+	}
+	return unique, nil
+}
+
+func runSGF(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: sgf merge|split|filter|dedupe|hash|validate [flags] files...")
+	}
+	fs := flag.NewFlagSet("sgf "+args[0], flag.ExitOnError)
+	out := fs.String("o", "", "output file (default stdout)")
+
+	switch args[0] {
+	case "merge":
+		fs.Parse(args[1:])
+		games, err := readCollections(fs.Args())
+		if err != nil {
+			return err
+		}
+		return writeCollection(*out, games)
+	case "split":
+		dir := fs.String("dir", ".", "output directory")
+		prefix := fs.String("prefix", "game", "output file name prefix")
+		fs.Parse(args[1:])
+		games, err := readCollections(fs.Args())
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+		for i, g := range games {
+			path := filepath.Join(*dir, fmt.Sprintf("%s-%03d.sgf", *prefix, i+1))
+			if err := WriteSGFFile(path, []*SGFNode{g}); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Wrote %d games to %s\n", len(games), *dir)
+		return nil
+	case "filter":
+		var f SGFFilter
+		fs.StringVar(&f.Player, "player", "", "player name (either colour)")
+		fs.StringVar(&f.Result, "result", "", "result prefix, e.g. B+ or W+R")
+		fs.IntVar(&f.Size, "size", 0, "board size")
+		fs.StringVar(&f.After, "after", "", "games on or after YYYY-MM-DD")
+		fs.StringVar(&f.Before, "before", "", "games before YYYY-MM-DD")
+		fs.Parse(args[1:])
+		games, err := readCollections(fs.Args())
+		if err != nil {
+			return err
+		}
+		var matched []*SGFNode
+		for _, g := range games {
+			if f.Match(g) {
+				matched = append(matched, g)
+			}
+		}
+		if len(matched) == 0 {
+			return errors.New("no games match")
+		}
+		return writeCollection(*out, matched)
+	case "dedupe":
+		fs.Parse(args[1:])
+		games, err := readCollections(fs.Args())
+		if err != nil {
+			return err
+		}
+		unique, err := Dedupe(games)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d games, %d duplicates removed\n", len(games), len(games)-len(unique))
+		return writeCollection(*out, unique)
+	case "hash":
+		verify := fs.Bool("verify", false, "check the existing hashes instead of adding them")
+		fs.Parse(args[1:])
+		games, err := readCollections(fs.Args())
+		if err != nil {
+			return err
+		}
+		for i, g := range games {
+			if !*verify {
+				if err := AddPositionHashes(g); err != nil {
+					return fmt.Errorf("game %d: %v", i+1, err)
+				}
+				continue
+			}
+			n, err := VerifyPositionHashes(g)
+			if err != nil {
+				return fmt.Errorf("game %d: %v", i+1, err)
+			}
+			fmt.Printf("game %d: %d position hashes match\n", i+1, n)
+		}
+		if *verify {
+			return nil
+		}
+		return writeCollection(*out, games)
+	case "validate":
+		fs.Parse(args[1:])
+		if fs.NArg() == 0 {
+			return errors.New("no SGF files given")
+		}
+		bad := 0
+		for _, path := range fs.Args() {
+			games, err := ReadSGFFile(path)
+			if err != nil {
+				fmt.Println(err)
+				bad++
+				continue
+			}
+			issues := ValidateSGF(games)
+			for _, issue := range issues {
+				fmt.Printf("%s: %s\n", path, issue)
+			}
+			if len(issues) > 0 {
+				bad++
+			}
+		}
+		if bad > 0 {
+			return fmt.Errorf("%d of %d files have problems", bad, fs.NArg())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown sgf command %q", args[0])
+}
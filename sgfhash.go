@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Position hashes in SGF. Each node can carry the private property ZH, the
+// Zobrist hash (Board.Hash) of the position after the node, side to move
+// included, as 16 hex digits. Indexers can then find positions without
+// replaying the game, and importers can check that a file replays to the
+// positions its writer saw.
+
+const sgfHashID = "ZH"
+
+func formatPositionHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+// walkPositions calls fn for every node of the game tree, depth first,
+// with its depth-first number and the position after its setup and move.
+func walkPositions(root *SGFNode, fn func(n *SGFNode, num int, b *Board) error) error {
+	size := 19
+	if sz := root.Get("SZ"); sz != "" {
+		n, err := strconv.Atoi(strings.SplitN(sz, ":", 2)[0])
+		if err != nil || n < 1 || n > 52 {
+			return fmt.Errorf("sgf: invalid board size %q", sz)
+		}
+		size = n
+	}
+	num := 0
+	var walk func(n *SGFNode, b *Board) error
+	walk = func(n *SGFNode, b *Board) error {
+		if err := applySGFNode(n, b); err != nil {
+			return fmt.Errorf("node %d: %v", num, err)
+		}
+		if err := fn(n, num, b); err != nil {
+			return err
+		}
+		num++
+		for _, c := range n.Children {
+			if err := walk(c, b.Copy()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, NewBoard(size))
+}
+
+// applySGFNode plays a node's setup, side to move and move on b.
+func applySGFNode(n *SGFNode, b *Board) error {
+	for _, setup := range []struct {
+		id    string
+		color Stone
+	}{{"AB", Black}, {"AW", White}, {"AE", Empty}} {
+		for _, v := range n.Values(setup.id) {
+			points, err := sgfPointList(v, b.size)
+			if err != nil {
+				return err
+			}
+			for _, m := range points {
+				b.setPoint(m, setup.color)
+			}
+		}
+	}
+	switch n.Get("PL") {
+	case "B":
+		b.turn = Black
+	case "W":
+		b.turn = White
+	}
+	for _, id := range []string{"B", "W"} {
+		if len(n.Values(id)) == 0 {
+			continue
+		}
+		m, err := sgfPoint(n.Get(id), b.size)
+		if err != nil {
+			return err
+		}
+		b.turn = Black
+		if id == "W" {
+			b.turn = White
+		}
+		if !b.Play(m) {
+			return fmt.Errorf("illegal move %s[%s]", id, n.Get(id))
+		}
+	}
+	return nil
+}
+
+// AddPositionHashes sets ZH on every node of the game tree.
+func AddPositionHashes(root *SGFNode) error {
+	return walkPositions(root, func(n *SGFNode, num int, b *Board) error {
+		n.Set(sgfHashID, formatPositionHash(b.Hash()))
+		return nil
+	})
+}
+
+// VerifyPositionHashes replays the game tree and checks every ZH against
+// the position reached. It returns how many hashes were checked.
+func VerifyPositionHashes(root *SGFNode) (int, error) {
+	checked := 0
+	err := walkPositions(root, func(n *SGFNode, num int, b *Board) error {
+		want := n.Get(sgfHashID)
+		if want == "" {
+			return nil
+		}
+		checked++
+		if got := formatPositionHash(b.Hash()); got != want {
+			return fmt.Errorf("node %d: position hash %s, file says %s", num, got, want)
+		}
+		return nil
+	})
+	return checked, err
+}
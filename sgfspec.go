@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Property table and validator for SGF FF[4] with GM[1] (Go).
+
+type sgfValueType int
+
+const (
+	sgfNone sgfValueType = iota
+	sgfNumber
+	sgfReal
+	sgfDouble // 1 or 2
+	sgfColor  // B or W
+	sgfSimpleText
+	sgfText
+	sgfMoveValue
+	sgfPointValue  // a point, or a compressed rectangle "aa:cc"
+	sgfSize        // number or number:number
+	sgfPointPair   // point:point, for AR and LN
+	sgfLabel       // point:simpletext
+	sgfAppName     // simpletext:simpletext
+	sgfFigure      // none or number:simpletext
+	sgfResultValue // RE
+	sgfDateValue   // DT
+	sgfFileFormat  // FF, 1 to 4
+	sgfHashValue   // ZH, 16 hex digits
+)
+
+type sgfScope int
+
+const (
+	scopeAny sgfScope = iota
+	scopeRoot
+	scopeGameInfo
+	scopeSetup
+	scopeMove
+)
+
+type sgfPropSpec struct {
+	typ   sgfValueType
+	scope sgfScope
+	list  bool // more than one value allowed
+	elist bool // the empty list [] allowed
+}
+
+var sgfSpec = map[string]sgfPropSpec{
+	// Move
+	"B":  {typ: sgfMoveValue, scope: scopeMove},
+	"W":  {typ: sgfMoveValue, scope: scopeMove},
+	"KO": {typ: sgfNone, scope: scopeMove},
+	"MN": {typ: sgfNumber, scope: scopeMove},
+	// Setup
+	"AB": {typ: sgfPointValue, scope: scopeSetup, list: true},
+	"AW": {typ: sgfPointValue, scope: scopeSetup, list: true},
+	"AE": {typ: sgfPointValue, scope: scopeSetup, list: true},
+	"PL": {typ: sgfColor, scope: scopeSetup},
+	// Node annotation
+	"C":  {typ: sgfText},
+	"DM": {typ: sgfDouble},
+	"GB": {typ: sgfDouble},
+	"GW": {typ: sgfDouble},
+	"HO": {typ: sgfDouble},
+	"N":  {typ: sgfSimpleText},
+	"UC": {typ: sgfDouble},
+	"V":  {typ: sgfReal},
+	// Move annotation
+	"BM": {typ: sgfDouble, scope: scopeMove},
+	"DO": {typ: sgfNone, scope: scopeMove},
+	"IT": {typ: sgfNone, scope: scopeMove},
+	"TE": {typ: sgfDouble, scope: scopeMove},
+	// Markup
+	"AR": {typ: sgfPointPair, list: true},
+	"CR": {typ: sgfPointValue, list: true},
+	"DD": {typ: sgfPointValue, list: true, elist: true},
+	"LB": {typ: sgfLabel, list: true},
+	"LN": {typ: sgfPointPair, list: true},
+	"MA": {typ: sgfPointValue, list: true},
+	"SL": {typ: sgfPointValue, list: true},
+	"SQ": {typ: sgfPointValue, list: true},
+	"TR": {typ: sgfPointValue, list: true},
+	// Root
+	"AP": {typ: sgfAppName, scope: scopeRoot},
+	"CA": {typ: sgfSimpleText, scope: scopeRoot},
+	"FF": {typ: sgfFileFormat, scope: scopeRoot},
+	"GM": {typ: sgfNumber, scope: scopeRoot},
+	"ST": {typ: sgfNumber, scope: scopeRoot},
+	"SZ": {typ: sgfSize, scope: scopeRoot},
+	// Game info
+	"AN": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"BR": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"BT": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"CP": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"DT": {typ: sgfDateValue, scope: scopeGameInfo},
+	"EV": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"GN": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"GC": {typ: sgfText, scope: scopeGameInfo},
+	"ON": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"OT": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"PB": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"PC": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"PW": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"RE": {typ: sgfResultValue, scope: scopeGameInfo},
+	"RO": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"RU": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"SO": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"TM": {typ: sgfReal, scope: scopeGameInfo},
+	"US": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"WR": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"WT": {typ: sgfSimpleText, scope: scopeGameInfo},
+	"HA": {typ: sgfNumber, scope: scopeGameInfo},
+	"KM": {typ: sgfReal, scope: scopeGameInfo},
+	// Timing
+	"BL": {typ: sgfReal, scope: scopeMove},
+	"OB": {typ: sgfNumber, scope: scopeMove},
+	"OW": {typ: sgfNumber, scope: scopeMove},
+	"WL": {typ: sgfReal, scope: scopeMove},
+	// Miscellaneous
+	"FG": {typ: sgfFigure},
+	"PM": {typ: sgfNumber},
+	"VW": {typ: sgfPointValue, list: true, elist: true},
+	// Go
+	"TB": {typ: sgfPointValue, list: true, elist: true},
+	"TW": {typ: sgfPointValue, list: true, elist: true},
+	// Private: Zobrist hash of the position after the node, see sgfhash.go
+	"ZH": {typ: sgfHashValue},
+}
+
+// IsGameInfoProperty reports whether id belongs to the game-info scope.
+func IsGameInfoProperty(id string) bool {
+	return sgfSpec[id].scope == scopeGameInfo
+}
+
+var (
+	sgfResultRE = regexp.MustCompile(`^(0|Draw|Void|\?|[BW]\+([0-9]+(\.[0-9]+)?|R|Resign|T|Time|F|Forfeit)?)$`)
+	sgfDateRE   = regexp.MustCompile(`^[0-9]{4}(-[0-9]{2}(-[0-9]{2})?)?$`)
+	sgfShortRE  = regexp.MustCompile(`^[0-9]{2}(-[0-9]{2})?$`)
+	sgfHashRE   = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// SGFIssue is one problem found by ValidateSGF. Node counts the nodes of
+// the game tree depth-first from 0 at the root.
+type SGFIssue struct {
+	Game    int
+	Node    int
+	Prop    string
+	Message string
+}
+
+func (i SGFIssue) String() string {
+	if i.Prop == "" {
+		return fmt.Sprintf("game %d, node %d: %s", i.Game+1, i.Node, i.Message)
+	}
+	return fmt.Sprintf("game %d, node %d, %s: %s", i.Game+1, i.Node, i.Prop, i.Message)
+}
+
+type sgfValidator struct {
+	game   int
+	node   int
+	size   int
+	issues []SGFIssue
+}
+
+func (v *sgfValidator) report(prop, format string, args ...interface{}) {
+	v.issues = append(v.issues, SGFIssue{Game: v.game, Node: v.node, Prop: prop, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateSGF checks a collection against the FF[4] Go specification: it
+// reports unknown properties, malformed values, properties in the wrong
+// kind of node, and duplicate game information along a line of play.
+func ValidateSGF(games []*SGFNode) []SGFIssue {
+	v := &sgfValidator{}
+	for i, root := range games {
+		v.game, v.node, v.size = i, 0, 19
+		if gm := root.Get("GM"); gm != "" && gm != "1" {
+			v.report("GM", "game type %s is not Go", gm)
+			continue
+		}
+		if sz := root.Get("SZ"); sz != "" {
+			parts := strings.SplitN(sz, ":", 2)
+			if n, err := strconv.Atoi(parts[0]); err == nil && n >= 1 && n <= 52 {
+				v.size = n
+			}
+			if len(parts) == 2 && parts[0] != parts[1] {
+				v.report("SZ", "rectangular boards are not supported by Go")
+			}
+		}
+		v.walk(root, true, false)
+	}
+	return v.issues
+}
+
+func (v *sgfValidator) walk(n *SGFNode, root, seenGameInfo bool) {
+	seen := make(map[string]bool)
+	hasMove, hasSetup, hasGameInfo := false, false, false
+	for _, p := range n.Props {
+		if seen[p.ID] {
+			v.report(p.ID, "property appears twice in one node")
+		}
+		seen[p.ID] = true
+		spec, ok := sgfSpec[p.ID]
+		if !ok {
+			v.report(p.ID, "not an FF[4] property")
+			continue
+		}
+		switch spec.scope {
+		case scopeRoot:
+			if !root {
+				v.report(p.ID, "root property outside the root node")
+			}
+		case scopeMove:
+			hasMove = true
+		case scopeSetup:
+			hasSetup = true
+		case scopeGameInfo:
+			hasGameInfo = true
+		}
+		if len(p.Values) > 1 && !spec.list {
+			v.report(p.ID, "takes a single value, got %d", len(p.Values))
+		}
+		for _, value := range p.Values {
+			if value == "" && spec.elist {
+				continue
+			}
+			if err := v.checkValue(spec.typ, value); err != "" {
+				v.report(p.ID, "%s: %q", err, value)
+			}
+		}
+	}
+	if hasMove && hasSetup {
+		v.report("", "move and setup properties in the same node")
+	}
+	if hasGameInfo && seenGameInfo {
+		v.report("", "game info appears twice on the same line of play")
+	}
+	for _, c := range n.Children {
+		v.node++
+		v.walk(c, false, seenGameInfo || hasGameInfo)
+	}
+}
+
+func (v *sgfValidator) checkPoint(s string) bool {
+	m, err := sgfPoint(s, v.size)
+	return err == nil && m != PassMove
+}
+
+// checkValue returns a description of what is wrong with value, or "".
+func (v *sgfValidator) checkValue(typ sgfValueType, value string) string {
+	switch typ {
+	case sgfNone:
+		if value != "" {
+			return "takes no value"
+		}
+	case sgfNumber:
+		if _, err := strconv.Atoi(value); err != nil {
+			return "not a number"
+		}
+	case sgfFileFormat:
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 4 {
+			return "not a file format between 1 and 4"
+		}
+	case sgfReal:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "not a real number"
+		}
+	case sgfDouble:
+		if value != "1" && value != "2" {
+			return "must be 1 or 2"
+		}
+	case sgfColor:
+		if value != "B" && value != "W" {
+			return "must be B or W"
+		}
+	case sgfMoveValue:
+		if _, err := sgfPoint(value, v.size); err != nil {
+			return "not a move"
+		}
+	case sgfPointValue:
+		if _, err := sgfPointList(value, v.size); err != nil || value == "" {
+			return "not a point or rectangle"
+		}
+	case sgfSize:
+		for _, part := range strings.SplitN(value, ":", 2) {
+			if n, err := strconv.Atoi(part); err != nil || n < 1 || n > 52 {
+				return "not a board size between 1 and 52"
+			}
+		}
+	case sgfPointPair:
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || !v.checkPoint(parts[0]) || !v.checkPoint(parts[1]) {
+			return "not a pair of points"
+		}
+		if parts[0] == parts[1] {
+			return "connects a point to itself"
+		}
+	case sgfLabel:
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || !v.checkPoint(parts[0]) {
+			return "not a point:label pair"
+		}
+	case sgfAppName:
+		if !strings.Contains(value, ":") {
+			return "not a name:version pair"
+		}
+	case sgfFigure:
+		if value != "" {
+			parts := strings.SplitN(value, ":", 2)
+			if _, err := strconv.Atoi(parts[0]); err != nil || len(parts) != 2 {
+				return "not a flags:name pair"
+			}
+		}
+	case sgfResultValue:
+		if !sgfResultRE.MatchString(value) {
+			return "not a result"
+		}
+	case sgfDateValue:
+		for i, d := range strings.Split(value, ",") {
+			// Later dates may shorten to MM-DD or DD once the year is known.
+			if !sgfDateRE.MatchString(d) && (i == 0 || !sgfShortRE.MatchString(d)) {
+				return "not an ISO date list"
+			}
+		}
+	case sgfHashValue:
+		if !sgfHashRE.MatchString(value) {
+			return "not a 16 digit hex hash"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Ctrl-C (SIGINT) and SIGTERM adjourn a game rather than kill it in the
+// middle of a turn: the clocks stop, the game is saved where it can be
+// resumed, the other side of a network game is told, and the process
+// says how to carry on before it exits.
+
+// notifyShutdown delivers SIGINT and SIGTERM on the returned channel
+// until stop is called.
+func notifyShutdown() (signals <-chan os.Signal, stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	return ch, func() { signal.Stop(ch) }
+}
+
+// adjournText is what a player is told when their game is adjourned,
+// with the time each side has left.
+func adjournText(moves int, clock *Clock, now time.Time) string {
+	text := fmt.Sprintf("Game adjourned after %d moves.", moves)
+	if clock != nil {
+		clock.Stop(now)
+		text += fmt.Sprintf(" Time left: %s %s, %s %s.", Black, clock.State(Black, now), White, clock.State(White, now))
+	}
+	return text
+}
+
+// adjournLocal adjourns an interactive game on a signal: it saves rec to
+// the recovery file and through write, tells the player how to resume
+// and closes view, leaving the caller to exit.
+func adjournLocal(view gameView, rec *GameRecord, autosave *autosaver, clock *Clock, save string, write func() error) {
+	view.Message(adjournText(len(rec.Moves), clock, time.Now()))
+	autosave.save(rec, view)
+	if err := write(); err != nil {
+		view.Message(fmt.Sprintf("Saving %s failed: %v", save, err))
+	}
+	switch {
+	case autosave != nil:
+		view.Message("Start gogame again to resume it.")
+	case save != "":
+		view.Message(fmt.Sprintf("It is saved in %s.", save))
+	}
+	view.Close()
+}
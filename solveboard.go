@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+func init() {
+	commands["solve-board"] = runSolveBoard
+}
+
+// solve-board solves an empty board with a tablebase, see tablebase.go.
+
+func runSolveBoard(args []string) error {
+	fs := flag.NewFlagSet("solve-board", flag.ExitOnError)
+	size := fs.Int("size", 3, "board size, 1 to 5")
+	maxNodes := fs.Int("max-nodes", 0, "stop after searching this many positions, 0 for no limit")
+	prune := fs.Bool("prune", false, "never fill one's own one-point eyes, which is not proven safe")
+	timeout := fs.Duration("timeout", 0, "stop after this long, 0 for no limit")
+	fs.Parse(args)
+	if *size < 1 || *size > tablebaseMaxSize {
+		return fmt.Errorf("solve-board handles boards from 1x1 to %dx%d", tablebaseMaxSize, tablebaseMaxSize)
+	}
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	t := NewTablebase(*size)
+	t.MaxNodes, t.Prune = *maxNodes, *prune
+	start := time.Now()
+	value, move, err := t.Solve(ctx, NewBoard(*size))
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("%dx%d not solved after %d positions in %s: %v", *size, *size, t.Nodes, elapsed, err)
+	}
+	fmt.Printf("%dx%d solved: %d positions searched, %d stored, in %s\n", *size, *size, t.Nodes, t.Entries(), elapsed)
+	switch {
+	case value > 0:
+		fmt.Printf("Black wins by %d points without komi, starting at %s\n", value, userCoords.Move(move, *size))
+	case value < 0:
+		fmt.Printf("White wins by %d points without komi\n", -value)
+	default:
+		fmt.Println("The game is a draw without komi")
+	}
+	fmt.Printf("Optimal komi: %d (a draw); Black wins below it and White above\n", value)
+	return nil
+}
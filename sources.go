@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Each colour of an interactive game gets its moves from a source, set
+// with -black and -white:
+//
+//	human        the player at the terminal (the default)
+//	engine       the built-in engine, as -bot
+//	gtp:COMMAND  an external engine run in GTP mode, e.g. gtp:katago gtp
+//	net:ADDR     a remote player, who joins with "gogame connect -addr ADDR"
+//	script       the genmove hook of the -script file
+//
+// so that any two can meet: a human against KataGo, the built-in engine
+// against a GNU Go, a remote player against the engine.
+
+// moveSource plays one colour from outside the terminal. Setup is told
+// the stones of the starting position after NewGame; Close ends the source
+// with the game's result, "" if the game stopped unfinished.
+type moveSource interface {
+	gauntletPlayer
+	Setup(color Stone, move int) error
+	Close(result string) error
+}
+
+// checkSource reports whether spec is a valid -black or -white value.
+func checkSource(spec string) error {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case spec == "" || spec == "human" || spec == "engine" || spec == "script":
+		return nil
+	case (kind == "gtp" || kind == "net") && arg != "":
+		return nil
+	}
+	return fmt.Errorf("invalid move source %q: use human, engine, gtp:COMMAND, net:ADDR or script", spec)
+}
+
+// openSource starts the source spec for color, which must be one played
+// from outside the terminal: gtp, net or script. A net source waits for
+// its player to connect, saying so through view.
+func openSource(spec string, color Stone, script *scriptHost, view gameView) (moveSource, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "gtp":
+		p, err := newGTPPlayer(arg)
+		if err != nil {
+			return nil, err
+		}
+		return gtpSource{p}, nil
+	case "net":
+		ln, err := net.Listen("tcp", arg)
+		if err != nil {
+			return nil, err
+		}
+		defer ln.Close()
+		view.Message(fmt.Sprintf("Waiting for %s to connect on %s", color, ln.Addr()))
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, err
+		}
+		return newNetSource(color, conn), nil
+	case "script":
+		if !script.plays() {
+			return nil, errors.New("a script source needs a -script file with a genmove hook")
+		}
+		return scriptSource{script}, nil
+	}
+	return nil, fmt.Errorf("%s is not played from outside the terminal", spec)
+}
+
+// gtpSource is an external engine; it quits when the game is over.
+type gtpSource struct{ *gtpPlayer }
+
+func (s gtpSource) Setup(color Stone, move int) error { return s.Played(color, move) }
+func (s gtpSource) Close(string) error                { return s.client.Close() }
+
+// scriptSource lets the game script choose a colour's moves. It sees the
+// moves through its own hooks.
+type scriptSource struct{ h *scriptHost }
+
+func (s scriptSource) Name() string               { return "script" }
+func (s scriptSource) NewGame(int, float64) error { return nil }
+func (s scriptSource) Played(Stone, int) error    { return nil }
+func (s scriptSource) Setup(Stone, int) error     { return nil }
+func (s scriptSource) Close(string) error         { return nil }
+
+func (s scriptSource) GenMove(ctx context.Context, b BoardView) (int, error) {
+	move, ok := s.h.genMove(b.Turn())
+	if !ok {
+		return 0, errors.New("the script chose no legal move")
+	}
+	return move, nil
+}
+
+// netSource is a remote player speaking the serve protocol (see
+// netgame.go), so that "gogame connect" can play it.
+type netSource struct {
+	p      *netPlayer
+	conn   net.Conn
+	size   int
+	played []pipeEvent // the move events sent, to acknowledge repeats
+}
+
+func newNetSource(color Stone, conn net.Conn) *netSource {
+	return &netSource{p: newNetPlayer(color, conn), conn: conn}
+}
+
+func (s *netSource) Name() string { return s.conn.RemoteAddr().String() }
+
+func (s *netSource) NewGame(size int, komi float64) error {
+	s.size = size
+	s.p.send(pipeEvent{Event: "start", Size: size, ToMove: "black", You: colorKey(s.p.color)})
+	return nil
+}
+
+// GenMove asks the player for a move until they send a legal one, or
+// "resign".
+func (s *netSource) GenMove(ctx context.Context, b BoardView) (int, error) {
+	s.p.send(pipeEvent{Event: "turn", Color: colorKey(b.Turn()), Number: len(s.played) + 1})
+	for {
+		select {
+		case m := <-s.p.moves:
+			if strings.TrimSpace(m.Move) == "resign" {
+				return gauntletResign, nil
+			}
+			if !sequenceMove(s.p, m, s.played, b.Size()) {
+				continue
+			}
+			move, err := parseMoveInput(m.Move, b.Size())
+			if err == nil && !b.Copy().Play(move) {
+				err = errors.New("illegal move")
+			}
+			if err != nil {
+				s.p.send(pipeEvent{Event: "error", Code: errBadMove, Message: err.Error()})
+				continue
+			}
+			return move, nil
+		case <-s.p.gone:
+			return 0, errors.New("disconnected")
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (s *netSource) Setup(color Stone, move int) error {
+	s.p.send(s.moveEvent("setup", color, move))
+	return nil
+}
+
+func (s *netSource) Played(color Stone, move int) error {
+	e := s.moveEvent("move", color, move)
+	s.played = append(s.played, e)
+	s.p.send(e)
+	return nil
+}
+
+func (s *netSource) moveEvent(event string, color Stone, move int) pipeEvent {
+	e := pipeEvent{Event: event, Color: colorKey(color), Move: formatMove(move, s.size)}
+	if event == "move" {
+		e.Number = len(s.played) + 1
+	}
+	if move != PassMove {
+		row, col := move/s.size, move%s.size
+		e.Row, e.Col = &row, &col
+	}
+	return e
+}
+
+func (s *netSource) Close(result string) error {
+	if result == "" {
+		s.p.send(pipeEvent{Event: "adjourn", Message: fmt.Sprintf("Game stopped after %d moves.", len(s.played))})
+	} else {
+		s.p.send(pipeEvent{Event: "end", Result: result})
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestCheckSource(t *testing.T) {
+	for _, spec := range []string{"", "human", "engine", "script", "gtp:gnugo --mode gtp", "net::7000"} {
+		if err := checkSource(spec); err != nil {
+			t.Errorf("checkSource(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"robot", "gtp:", "net"} {
+		if checkSource(spec) == nil {
+			t.Errorf("checkSource(%q) accepted", spec)
+		}
+	}
+}
+
+func TestNetSource(t *testing.T) {
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+	remote := newNetTestClient(client)
+	src := newNetSource(White, server)
+
+	b := NewBoard(5)
+	b.PlaceStone(2, 2)
+	go func() {
+		src.NewGame(5, 0.5)
+		src.Setup(Black, 2*5+2)
+	}()
+	if e := remote.next(t, "start"); e.You != "white" || e.Size != 5 {
+		t.Fatalf("start = %+v", e)
+	}
+	if e := remote.next(t, "setup"); e.Color != "black" || e.Move != "2 2" {
+		t.Fatalf("setup = %+v", e)
+	}
+
+	moves := make(chan int)
+	go func() {
+		move, _ := src.GenMove(context.Background(), b)
+		moves <- move
+	}()
+	remote.next(t, "turn")
+	json.NewEncoder(client).Encode(netInput{Move: "2 2", Seq: 1})
+	if e := remote.next(t, "error"); e.Message != "illegal move" {
+		t.Fatalf("error = %+v", e)
+	}
+	json.NewEncoder(client).Encode(netInput{Move: "1 3", Seq: 1})
+	if move := <-moves; move != 1*5+3 {
+		t.Fatalf("GenMove = %d", move)
+	}
+
+	go src.Played(White, 1*5+3)
+	if e := remote.next(t, "move"); e.Number != 1 || e.Move != "1 3" {
+		t.Errorf("move = %+v", e)
+	}
+	go src.Close("W+R")
+	if e := remote.next(t, "end"); e.Result != "W+R" {
+		t.Errorf("end = %+v", e)
+	}
+}
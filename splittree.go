@@ -0,0 +1,192 @@
+package main
+
+// Split-tree search. Workers sharing one tree take turns with it under a
+// lock, which on many cores is where they spend their time. With
+// SplitTree each worker grows a tree of its own from the same position,
+// with its own share of the playouts, its own memory and random numbers,
+// and no lock at all; when they are done their root moves are added up
+// into one tree one move deep, which is all GenMove and Analyze look at.
+// The trees do not share what they learn below the root, so a split
+// search of N playouts plays a little weaker than a shared one; it pays
+// where lock contention costs more than that. This file is part of the
+// core (see board.go).
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// queuedEvaluator hands positions to a queue that an evaluator shared by
+// several trees takes them from, one at a time as Evaluator requires. The
+// queue's evaluator keeps the scores of a ScoreEvaluator (see splitSearch).
+type queuedEvaluator struct{ q *evalQueue }
+
+func (q queuedEvaluator) Evaluate(positions []*Board) []float64 {
+	values := make([]float64, len(positions))
+	for i, b := range positions {
+		values[i] = q.q.evaluate(b)
+	}
+	return values
+}
+
+// queuedOwnershipEvaluator is a queuedEvaluator for an OwnershipEvaluator,
+// whose ownership is asked through the queue as well.
+type queuedOwnershipEvaluator struct{ queuedEvaluator }
+
+func (q queuedOwnershipEvaluator) Ownership(b *Board) []float64 {
+	return q.q.ownership(b)
+}
+
+// splitClock decides for the shards of a split search when the search as
+// a whole is past its TimePlan or settled, which no shard can tell from
+// its own tree: each shard adds what its root moves gained to the totals
+// when it checks, and the split engine looks at those as at a tree of its
+// own. Each shard still stops by itself when too little of the MoveTime
+// is left for another of its playouts.
+type splitClock struct {
+	e               *Engine // the split engine
+	start, deadline time.Time
+	mu              sync.Mutex
+	root            node          // the shards' root moves, added up
+	moves           map[int]*node // root's children by move
+	seen            map[shardMove]int
+	stop            bool
+}
+
+// shardMove is a root move of a shard, whose visits the clock last saw.
+type shardMove struct {
+	shard *Engine
+	move  int
+}
+
+func newSplitClock(e *Engine, start, deadline time.Time) *splitClock {
+	c := &splitClock{e: e, start: start, deadline: deadline, moves: map[int]*node{}, seen: map[shardMove]int{}}
+	c.root.move = PassMove
+	return c
+}
+
+// enough adds what the root moves of shard s gained since it last checked
+// to the totals and reports whether the split search has had enough.
+func (c *splitClock) enough(s *Engine, root *node) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop {
+		return true
+	}
+	for _, child := range root.children {
+		m := c.moves[child.move]
+		if m == nil {
+			m = &node{move: child.move, parent: &c.root}
+			c.moves[child.move] = m
+			c.root.children = append(c.root.children, m)
+		}
+		key := shardMove{s, child.move}
+		m.visits += child.visits - c.seen[key]
+		c.root.visits += child.visits - c.seen[key]
+		c.seen[key] = child.visits
+	}
+	c.stop = c.e.decided(&c.root, c.root.visits, c.start, c.deadline)
+	return c.stop
+}
+
+// splitSearch searches b with a tree for each of e.Threads workers and
+// merges their roots.
+func (e *Engine) splitSearch(ctx context.Context, b *Board) (*node, error) {
+	start := time.Now()
+	e.best, e.bestSince = PassMove-1, start
+	e.stats, e.scores, e.rootFactors = SearchStats{}, ScoreDistribution{}, nil
+	shards := make([]*Engine, e.Threads)
+	var queue *evalQueue
+	if e.Evaluator != nil {
+		eval := e.Evaluator
+		if se, ok := eval.(ScoreEvaluator); ok {
+			// The queue's goroutine alone evaluates, so it can keep the
+			// scores for every shard.
+			eval = scoreRecorder{se, &e.scores}
+		}
+		queue = newEvalQueue(eval, max(e.BatchSize, 1), e.pin(e.Threads))
+		defer queue.close()
+	}
+	var clock *splitClock
+	if e.plan.Target > 0 || e.stopEarly {
+		deadline, _ := ctx.Deadline()
+		clock = newSplitClock(e, start, deadline)
+	}
+	for i := range shards {
+		s := &Engine{
+			Playouts:        e.Playouts / e.Threads,
+			Komi:            e.Komi,
+			MaxMemory:       e.MaxMemory / int64(e.Threads),
+			DirichletWeight: e.DirichletWeight,
+			DirichletAlpha:  e.DirichletAlpha,
+			Playout:         e.Playout,
+			rng:             rand.New(rand.NewSource(e.rng.Int63())),
+			moveEnd:         e.moveEnd,
+			split:           clock,
+		}
+		if i < e.Playouts%e.Threads {
+			s.Playouts++
+		}
+		if queue != nil {
+			s.Evaluator = queuedEvaluator{queue}
+			if queue.owner != nil {
+				s.Evaluator = queuedOwnershipEvaluator{queuedEvaluator{queue}}
+			}
+		}
+		shards[i] = s
+	}
+
+	roots := make([]*node, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, s := range shards {
+		wg.Add(1)
+		go func(i int, s *Engine) {
+			defer wg.Done()
+			if pin := e.pin(i); pin != nil {
+				pin()
+			}
+			roots[i], errs[i] = s.search(ctx, b.Copy(), nil)
+		}(i, s)
+	}
+	wg.Wait()
+
+	e.arena.reset()
+	root := e.arena.alloc()
+	root.move, root.player = PassMove, b.turn.Opponent()
+	children := map[int]*node{}
+	for i, r := range roots {
+		root.visits += r.visits
+		root.wins += r.wins
+		for _, c := range r.children {
+			m := children[c.move]
+			if m == nil {
+				m = e.arena.alloc()
+				m.move, m.player, m.parent = c.move, c.player, root
+				children[c.move] = m
+				root.children = append(root.children, m)
+			}
+			m.visits += c.visits
+			m.wins += c.wins
+		}
+		st := shards[i].Stats()
+		e.stats.Nodes += st.Nodes
+		e.stats.Memory += st.Memory
+		e.stats.Recycled += st.Recycled
+		e.scores.merge(shards[i].scores)
+	}
+	if root.visits > 0 {
+		e.stats.WinRate = 1 - root.wins/float64(root.visits)
+	}
+	if e.Progress != nil {
+		e.Progress(e.progress(root, start, true))
+	}
+	for _, err := range errs {
+		if err != nil {
+			return root, err
+		}
+	}
+	return root, nil
+}
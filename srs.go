@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spaced repetition schedules the quiz decks and the problem library for
+// study, after SM-2: an item answered right comes back a day later, then
+// six days later, then after intervals that grow by the item's ease each
+// time; one answered wrong starts over from a day, and its ease drops so
+// that it comes back more often from then on. The schedule is not kept
+// but worked out from the study log, the way ladder standings are from
+// its results, so answers from quiz count towards it too. Only a wrong
+// answer counts before an item is due: answering it again early and
+// right does not push it further off.
+//
+// review with no game to review serves the items due today, and a few
+// new ones a day until the decks and problems have all been seen.
+
+const (
+	studyStartEase = 2.5
+	studyMinEase   = 1.3
+	// The SM-2 grades, from 0 to 5, given to right and wrong answers.
+	studyRightGrade = 4
+	studyWrongGrade = 1
+	// problemDeck is the deck the problem library is recorded under.
+	problemDeck = "problems"
+)
+
+// StudyCard is the schedule of one item.
+type StudyCard struct {
+	Deck, Item string
+	Ease       float64
+	Interval   int // days
+	Reps       int // right answers in a row
+	First      time.Time
+	Due        time.Time
+}
+
+type studyKey struct{ Deck, Item string }
+
+// studyDay is the local day t is on.
+func studyDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// answer updates c for an answer on day.
+func (c *StudyCard) answer(correct bool, day time.Time) {
+	grade := studyWrongGrade
+	if correct {
+		if c.Reps > 0 && day.Before(c.Due) {
+			return
+		}
+		grade = studyRightGrade
+		c.Reps++
+		switch c.Reps {
+		case 1:
+			c.Interval = 1
+		case 2:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+	} else {
+		c.Reps, c.Interval = 0, 1
+	}
+	q := float64(5 - grade)
+	c.Ease = max(studyMinEase, c.Ease+0.1-q*(0.08+q*0.02))
+	c.Due = day.AddDate(0, 0, c.Interval)
+}
+
+// ScheduleStudy replays the study log into the schedule of every item
+// answered.
+func ScheduleStudy(results []StudyResult) map[studyKey]*StudyCard {
+	cards := map[studyKey]*StudyCard{}
+	for _, r := range results {
+		day := studyDay(r.Date)
+		key := studyKey{r.Deck, r.Item}
+		c := cards[key]
+		if c == nil {
+			c = &StudyCard{Deck: r.Deck, Item: r.Item, Ease: studyStartEase, First: day}
+			cards[key] = c
+		}
+		c.answer(r.Correct, day)
+	}
+	return cards
+}
+
+// StudyItem is a quiz question or a problem to study.
+type StudyItem struct {
+	Deck   string
+	Quiz   *QuizItem
+	Puzzle *Puzzle
+}
+
+func (s StudyItem) key() studyKey {
+	if s.Puzzle != nil {
+		return studyKey{s.Deck, s.Puzzle.ID}
+	}
+	return studyKey{s.Deck, s.Quiz.ID}
+}
+
+// DueStudyItems picks the items of items to study on day: those due, the
+// most overdue first, then new ones in order, up to newPerDay a day
+// counting those started on day already.
+func DueStudyItems(items []StudyItem, cards map[studyKey]*StudyCard, day time.Time, newPerDay int) []StudyItem {
+	started := 0
+	for _, c := range cards {
+		if c.First.Equal(day) {
+			started++
+		}
+	}
+	var due, fresh []StudyItem
+	for _, item := range items {
+		switch c := cards[item.key()]; {
+		case c == nil && len(fresh) < newPerDay-started:
+			fresh = append(fresh, item)
+		case c != nil && !c.Due.After(day):
+			due = append(due, item)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return cards[due[i].key()].Due.Before(cards[due[j].key()].Due) })
+	return append(due, fresh...)
+}
+
+// loadStudyItems gathers the questions of every deck in the decks
+// directory and the problems at problems.
+func loadStudyItems(problems []string) ([]StudyItem, error) {
+	var items []StudyItem
+	paths, err := filepath.Glob(quizDeckPath("*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		var deck QuizDeck
+		if err := loadJSON(path, &deck); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for i := range deck.Items {
+			items = append(items, StudyItem{Deck: deck.Name, Quiz: &deck.Items[i]})
+		}
+	}
+	puzzles, err := LoadPuzzles(problems)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range puzzles {
+		items = append(items, StudyItem{Deck: problemDeck, Puzzle: p})
+	}
+	return items, nil
+}
+
+// askPuzzle sets out p and plays it through with the player, giving the
+// problem's replies, and reports whether they solved it.
+func askPuzzle(view *consoleView, p *Puzzle, refute func(*Board) int) (correct, ok bool) {
+	size := p.start.size
+	fmt.Println(p.Title)
+	if p.Comment != "" {
+		fmt.Println(p.Comment)
+	}
+	fmt.Printf("%s to play.\n", colorName(p.start.turn))
+	var line []int
+	for {
+		b := p.start.Copy()
+		for _, m := range line {
+			b.Play(m)
+		}
+		view.Show(b)
+		fmt.Print("> ")
+		if !view.in.Scan() {
+			return false, false
+		}
+		text := strings.TrimSpace(view.in.Text())
+		if text == "" {
+			continue
+		}
+		move, err := userCoords.Parse(text, size)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		ans, err := p.Check(append(line, move), refute)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		line = append(line, move)
+		if ans.Comment != "" {
+			fmt.Println(ans.Comment)
+		}
+		switch {
+		case !ans.Correct:
+			fmt.Print("Wrong.")
+			if len(ans.Refutation) > 0 {
+				var moves []string
+				for _, v := range ans.Refutation {
+					if r, err := parseGTPVertex(v, size); err == nil {
+						moves = append(moves, userCoords.Move(r, size))
+					}
+				}
+				fmt.Printf(" The answer to it: %s", strings.Join(moves, ", "))
+			}
+			fmt.Println()
+			return false, true
+		case ans.Solved:
+			fmt.Println("Right!")
+			return true, true
+		case ans.Reply == "":
+			// The line ends without being marked right.
+			return false, true
+		}
+		reply, err := parseGTPVertex(ans.Reply, size)
+		if err != nil {
+			return false, true
+		}
+		fmt.Printf("%s plays %s\n", colorName(p.start.turn.Opponent()), userCoords.Move(reply, size))
+		line = append(line, reply)
+		if ans, err := p.Check(line, nil); err == nil && ans.Solved {
+			if ans.Comment != "" {
+				fmt.Println(ans.Comment)
+			}
+			fmt.Println("Right!")
+			return true, true
+		}
+	}
+}
+
+// runStudySession serves the items due today, recording each answer,
+// until they are done, limit have been asked or the input ends.
+func runStudySession(problems []string, newPerDay, limit int, refute func(*Board) int) error {
+	if len(problems) == 0 {
+		if info, err := os.Stat(dataPath(problemDeck)); err == nil && info.IsDir() {
+			problems = []string{dataPath(problemDeck)}
+		}
+	}
+	items, err := loadStudyItems(problems)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("nothing to study: make a deck with quiz make, or put problems in %s", dataPath(problemDeck))
+	}
+	results, err := LoadStudyLog()
+	if err != nil {
+		return err
+	}
+	today := studyDay(time.Now())
+	due := DueStudyItems(items, ScheduleStudy(results), today, newPerDay)
+	if limit > 0 {
+		due = due[:min(limit, len(due))]
+	}
+	if len(due) == 0 {
+		fmt.Println("Nothing is due today.")
+	}
+	view := newConsoleView(os.Stdin)
+	asked, right := 0, 0
+	for i, item := range due {
+		fmt.Printf("\n%d of %d (%s)\n", i+1, len(due), item.Deck)
+		var correct, ok bool
+		if item.Puzzle != nil {
+			correct, ok = askPuzzle(view, item.Puzzle, refute)
+		} else {
+			correct, ok = askQuizItem(view, *item.Quiz)
+		}
+		if !ok {
+			break
+		}
+		asked++
+		if correct {
+			right++
+		}
+		key := item.key()
+		if err := RecordStudyResult(StudyResult{Deck: key.Deck, Item: key.Item, Date: time.Now(), Correct: correct}); err != nil {
+			return err
+		}
+	}
+	if asked > 0 {
+		fmt.Printf("\n%d of %d right\n", right, asked)
+	}
+	if results, err = LoadStudyLog(); err != nil {
+		return err
+	}
+	tomorrow := 0
+	for _, c := range ScheduleStudy(results) {
+		if !c.Due.After(today.AddDate(0, 0, 1)) {
+			tomorrow++
+		}
+	}
+	fmt.Printf("Due tomorrow: %d\n", tomorrow)
+	return nil
+}
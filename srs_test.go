@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleStudy(t *testing.T) {
+	day := studyDay(time.Date(2026, 3, 1, 20, 0, 0, 0, time.Local))
+	on := func(days int) time.Time { return day.AddDate(0, 0, days).Add(9 * time.Hour) }
+	results := []StudyResult{
+		{Deck: "d", Item: "a", Date: on(0), Correct: true},
+		{Deck: "d", Item: "a", Date: on(0), Correct: true}, // early: does not count
+		{Deck: "d", Item: "a", Date: on(1), Correct: true},
+		{Deck: "d", Item: "a", Date: on(7), Correct: true},
+		{Deck: "d", Item: "b", Date: on(0), Correct: true},
+		{Deck: "d", Item: "b", Date: on(1), Correct: false},
+	}
+	cards := ScheduleStudy(results)
+	a, b := cards[studyKey{"d", "a"}], cards[studyKey{"d", "b"}]
+	if a.Reps != 3 || a.Interval != 15 || !a.Due.Equal(day.AddDate(0, 0, 22)) {
+		t.Errorf("a: %+v", a)
+	}
+	if b.Reps != 0 || b.Interval != 1 || !b.Due.Equal(day.AddDate(0, 0, 2)) || b.Ease >= studyStartEase {
+		t.Errorf("b: %+v", b)
+	}
+
+	items := []StudyItem{
+		{Deck: "d", Quiz: &QuizItem{ID: "a"}},
+		{Deck: "d", Quiz: &QuizItem{ID: "b"}},
+		{Deck: "d", Quiz: &QuizItem{ID: "c"}},
+		{Deck: "d", Quiz: &QuizItem{ID: "d"}},
+	}
+	due := DueStudyItems(items, cards, day.AddDate(0, 0, 2), 1)
+	if len(due) != 2 || due[0].Quiz.ID != "b" || due[1].Quiz.ID != "c" {
+		t.Errorf("due on day 2: %v", due)
+	}
+	if due := DueStudyItems(items, cards, day.AddDate(0, 0, 22), 0); len(due) != 2 || due[0].Quiz.ID != "b" || due[1].Quiz.ID != "a" {
+		t.Errorf("due on day 22: %v", due)
+	}
+}
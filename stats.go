@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["stats"] = runStats
+}
+
+// opponentRD is the deviation assumed for archived opponents, whose ranks
+// are taken as established (rated bots or ranked players).
+const opponentRD = 60
+
+// ArchivedResult is one finished game from a player's point of view.
+// Score is 1 for a win, 0.5 for a jigo and 0 for a loss.
+type ArchivedResult struct {
+	Date         time.Time `json:"date"`
+	Player       string    `json:"player"`
+	Opponent     string    `json:"opponent"`
+	OpponentRank Rank      `json:"opponent_rank"`
+	Score        float64   `json:"score"`
+	BoardSize    int       `json:"board_size,omitempty"`
+	Handicap     int       `json:"handicap,omitempty"`
+}
+
+func resultsPath() string {
+	return dataPath("results.json")
+}
+
+func LoadResults() ([]ArchivedResult, error) {
+	var results []ArchivedResult
+	err := loadJSON(resultsPath(), &results)
+	return results, err
+}
+
+func ArchiveResult(r ArchivedResult) error {
+	results, err := LoadResults()
+	if err != nil {
+		return err
+	}
+	return saveJSON(resultsPath(), append(results, r))
+}
+
+func defaultPlayer() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return "player"
+}
+
+// EstimateRating rates player from their archived results, using calendar
+// weeks as Glicko-2 rating periods. A handicap game is rated as an even game
+// against an opponent that many grades weaker, for the stones the player
+// received, or stronger, for those they gave.
+func EstimateRating(player string, results []ArchivedResult) (Rating, int) {
+	var games []ArchivedResult
+	for _, r := range results {
+		if r.Player == player {
+			games = append(games, r)
+		}
+	}
+	sort.SliceStable(games, func(i, j int) bool { return games[i].Date.Before(games[j].Date) })
+
+	rating := NewRating()
+	var period []GlickoResult
+	var periodStart time.Time
+	for _, g := range games {
+		week := g.Date.Truncate(7 * 24 * time.Hour)
+		if len(period) > 0 && !week.Equal(periodStart) {
+			rating = rating.Update(period)
+			period = nil
+			for t := periodStart.Add(7 * 24 * time.Hour); t.Before(week); t = t.Add(7 * 24 * time.Hour) {
+				rating = rating.Update(nil)
+			}
+		}
+		periodStart = week
+		opp := Rating{R: RatingForRank(g.OpponentRank - Rank(g.Handicap)), RD: opponentRD, Sigma: glickoDefaultVol}
+		period = append(period, GlickoResult{Opponent: opp, Score: g.Score})
+	}
+	if len(period) > 0 {
+		rating = rating.Update(period)
+	}
+	return rating, len(games)
+}
+
+func runStats(args []string) error {
+	if len(args) > 0 && args[0] == "record" {
+		fs := flag.NewFlagSet("stats record", flag.ExitOnError)
+		player := fs.String("player", defaultPlayer(), "player name")
+		opponent := fs.String("opponent", "", "opponent name")
+		rank := fs.String("opponent-rank", "", "opponent rank, e.g. 5k")
+		result := fs.String("result", "", "win, loss or jigo")
+		size := fs.Int("size", 19, "board size")
+		handicap := fs.Int("handicap", 0, "handicap stones given to the player")
+		fs.Parse(args[1:])
+
+		r, err := ParseRank(*rank)
+		if err != nil {
+			return err
+		}
+		var score float64
+		switch strings.ToLower(*result) {
+		case "win":
+			score = 1
+		case "jigo", "draw":
+			score = 0.5
+		case "loss":
+			score = 0
+		default:
+			return errors.New("result must be win, loss or jigo")
+		}
+		return ArchiveResult(ArchivedResult{
+			Date: time.Now(), Player: *player, Opponent: *opponent, OpponentRank: r,
+			Score: score, BoardSize: *size, Handicap: *handicap,
+		})
+	}
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	player := fs.String("player", defaultPlayer(), "player name")
+	fs.Parse(args)
+
+	results, err := LoadResults()
+	if err != nil {
+		return err
+	}
+	rating, n := EstimateRating(*player, results)
+	if n == 0 {
+		fmt.Printf("No archived games for %s.\n", *player)
+		return nil
+	}
+	var wins, losses, jigo int
+	for _, r := range results {
+		if r.Player != *player {
+			continue
+		}
+		switch r.Score {
+		case 1:
+			wins++
+		case 0:
+			losses++
+		default:
+			jigo++
+		}
+	}
+	low, high := rating.Interval()
+	fmt.Printf("Player:  %s\n", *player)
+	fmt.Printf("Games:   %d (%d wins, %d losses, %d jigo)\n", n, wins, losses, jigo)
+	fmt.Printf("Rating:  %.0f ± %.0f\n", rating.R, 1.96*rating.RD)
+	fmt.Printf("Rank:    %s (95%% interval %s – %s)\n", RankForRating(rating.R), RankForRating(low), RankForRating(high))
+	return nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// StoneStatus is the fate of a stone at the end of the game, as in GTP
+// final_status_list.
+type StoneStatus int
+
+const (
+	StatusAlive StoneStatus = iota
+	StatusDead
+	StatusSeki
+)
+
+func (s StoneStatus) String() string {
+	switch s {
+	case StatusDead:
+		return "dead"
+	case StatusSeki:
+		return "seki"
+	}
+	return "alive"
+}
+
+// chains splits the stones of color into groups, each a list of points.
+func (b *Board) chains(color Stone) [][]int {
+	seen := make([]bool, b.size*b.size)
+	var chains [][]int
+	for p := range seen {
+		if seen[p] || b.grid[p/b.size][p%b.size] != color {
+			continue
+		}
+		stones, _ := b.group(p/b.size, p%b.size)
+		for _, q := range stones {
+			seen[q] = true
+		}
+		chains = append(chains, stones)
+	}
+	return chains
+}
+
+// passAlive finds the stones of color that are alive even if the
+// opponent plays every move, by Benson's algorithm: chains that keep two
+// vital regions, regions all of whose empty points are their liberties,
+// once the regions next to chains that fail are discarded in turn. It
+// also returns the points of the surviving vital regions; opponent stones
+// there can never live.
+func (b *Board) passAlive(color Stone) (alive, safe []bool) {
+	n := b.size * b.size
+	chains := b.chains(color)
+	chainOf := make([]int, n)
+	for p := range chainOf {
+		chainOf[p] = -1
+	}
+	for i, stones := range chains {
+		for _, p := range stones {
+			chainOf[p] = i
+		}
+	}
+
+	// Regions are the connected areas of points not of color.
+	type region struct {
+		points  []int
+		borders map[int]bool // chains next to the region
+		vital   map[int]bool // chains the region is vital to
+	}
+	var regions []*region
+	regionOf := make([]int, n)
+	for p := range regionOf {
+		regionOf[p] = -1
+	}
+	for p := 0; p < n; p++ {
+		if regionOf[p] >= 0 || chainOf[p] >= 0 {
+			continue
+		}
+		r := &region{borders: map[int]bool{}, vital: map[int]bool{}}
+		regionOf[p] = len(regions)
+		stack := []int{p}
+		for len(stack) > 0 {
+			q := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			r.points = append(r.points, q)
+			for _, nb := range b.neighbours(q) {
+				if c := chainOf[nb]; c >= 0 {
+					r.borders[c] = true
+				} else if regionOf[nb] < 0 {
+					regionOf[nb] = len(regions)
+					stack = append(stack, nb)
+				}
+			}
+		}
+		for c := range r.borders {
+			vital := true
+			for _, q := range r.points {
+				if b.grid[q/b.size][q%b.size] != Empty {
+					continue
+				}
+				next := false
+				for _, nb := range b.neighbours(q) {
+					next = next || chainOf[nb] == c
+				}
+				if !next {
+					vital = false
+					break
+				}
+			}
+			if vital {
+				r.vital[c] = true
+			}
+		}
+		regions = append(regions, r)
+	}
+
+	chainAlive := make([]bool, len(chains))
+	for i := range chainAlive {
+		chainAlive[i] = true
+	}
+	regionAlive := make([]bool, len(regions))
+	for i := range regionAlive {
+		regionAlive[i] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for c := range chains {
+			if !chainAlive[c] {
+				continue
+			}
+			vital := 0
+			for i, r := range regions {
+				if regionAlive[i] && r.vital[c] {
+					vital++
+				}
+			}
+			if vital < 2 {
+				chainAlive[c], changed = false, true
+			}
+		}
+		for i, r := range regions {
+			if !regionAlive[i] {
+				continue
+			}
+			for c := range r.borders {
+				if !chainAlive[c] {
+					regionAlive[i], changed = false, true
+					break
+				}
+			}
+		}
+	}
+
+	alive, safe = make([]bool, n), make([]bool, n)
+	for c, stones := range chains {
+		for _, p := range stones {
+			alive[p] = chainAlive[c]
+		}
+	}
+	for i, r := range regions {
+		// A larger region leaves the opponent room to live.
+		if regionAlive[i] && len(r.vital) > 0 {
+			for _, p := range r.points {
+				safe[p] = true
+			}
+		}
+	}
+	return alive, safe
+}
+
+// eyeSpace is the empty area next to stones that no other colour
+// touches, as the number of separate areas and their total size.
+func (b *Board) eyeSpace(stones []int) (areas, points int) {
+	color := b.grid[stones[0]/b.size][stones[0]%b.size]
+	seen := map[int]bool{}
+	for _, p := range stones {
+		for _, q := range b.neighbours(p) {
+			if seen[q] || b.grid[q/b.size][q%b.size] != Empty {
+				continue
+			}
+			r, _ := b.RegionAt(q/b.size, q%b.size)
+			for _, x := range r.Points {
+				seen[x] = true
+			}
+			if r.Owner() == color {
+				areas++
+				points += r.Size()
+			}
+		}
+	}
+	return areas, points
+}
+
+// outsideLiberties are the liberties of stones that are not in an eye
+// of their own.
+func (b *Board) outsideLiberties(stones []int) map[int]bool {
+	color := b.grid[stones[0]/b.size][stones[0]%b.size]
+	libs := map[int]bool{}
+	for _, p := range stones {
+		for _, q := range b.neighbours(p) {
+			if b.grid[q/b.size][q%b.size] != Empty || libs[q] {
+				continue
+			}
+			if r, _ := b.RegionAt(q/b.size, q%b.size); r.Owner() != color {
+				libs[q] = true
+			}
+		}
+	}
+	return libs
+}
+
+// sekiPair reports whether chains x and y, of opposite colours, live in
+// seki: neither has room for two eyes, each has no liberties outside its
+// eye but those it shares with the other, and filling one is fatal, as
+// they share two or more or each has an eye.
+func (b *Board) sekiPair(x, y []int) bool {
+	xEyes, xPoints := b.eyeSpace(x)
+	yEyes, yPoints := b.eyeSpace(y)
+	if xEyes > 1 || yEyes > 1 || xPoints > 3 || yPoints > 3 || xEyes != yEyes {
+		return false
+	}
+	xLibs, yLibs := b.outsideLiberties(x), b.outsideLiberties(y)
+	if len(xLibs) != len(yLibs) || len(xLibs) == 0 {
+		return false
+	}
+	for p := range xLibs {
+		if !yLibs[p] {
+			return false
+		}
+	}
+	return len(xLibs) >= 2 || xEyes == 1
+}
+
+// FinalStatus decides which stones of a finished game are alive, dead or
+// in seki, indexed by point (the entries for empty points mean nothing).
+// Benson's algorithm settles the groups that are alive whatever happens
+// and the stones inside their territory, and pairs of groups that share
+// their last liberties are checked for seki; the rest go by the
+// ownership e's playouts give them.
+func FinalStatus(ctx context.Context, e *Engine, b *Board) ([]StoneStatus, error) {
+	n := b.size * b.size
+	status := make([]StoneStatus, n)
+	decided := make([]bool, n)
+	for _, color := range []Stone{Black, White} {
+		alive, safe := b.passAlive(color)
+		for p := 0; p < n; p++ {
+			switch {
+			case alive[p]:
+				decided[p] = true
+			case safe[p] && b.grid[p/b.size][p%b.size] == color.Opponent():
+				status[p], decided[p] = StatusDead, true
+			}
+		}
+	}
+
+	// Random playouts fill shared liberties, so seki is found from the
+	// shape instead.
+	for _, x := range b.chains(Black) {
+		if decided[x[0]] {
+			continue
+		}
+		for _, y := range b.chains(White) {
+			if !decided[y[0]] && b.sekiPair(x, y) {
+				for _, p := range append(x, y...) {
+					status[p], decided[p] = StatusSeki, true
+				}
+			}
+		}
+	}
+
+	var ownership []float64
+	for _, color := range []Stone{Black, White} {
+		for _, stones := range b.chains(color) {
+			if decided[stones[0]] {
+				continue
+			}
+			if ownership == nil {
+				var err error
+				if ownership, err = e.Ownership(ctx, b); err != nil {
+					return nil, err
+				}
+			}
+			own := 0.0
+			for _, p := range stones {
+				own += ownership[p] * ownerSign(color)
+			}
+			if own < 0 {
+				for _, p := range stones {
+					status[p] = StatusDead
+				}
+			}
+		}
+	}
+	return status, nil
+}
+
+// StatusList lists the groups with status, one stone list per group, for
+// GTP final_status_list.
+func StatusList(b *Board, status []StoneStatus, want StoneStatus) [][]int {
+	var groups [][]int
+	for _, color := range []Stone{Black, White} {
+		for _, stones := range b.chains(color) {
+			if status[stones[0]] == want {
+				groups = append(groups, stones)
+			}
+		}
+	}
+	return groups
+}
+
+// FinalScore is the result of b as in SGF RE, by area with the dead
+// stones taken off.
+func FinalScore(b *Board, status []StoneStatus, komi float64) string {
+	scored := b.Copy()
+	for p, s := range status {
+		if s == StatusDead {
+			scored.setPoint(p, Empty)
+		}
+	}
+	winner, margin := scored.Winner(komi)
+	return resultString(winner, margin)
+}
+
+func parseStoneStatus(s string) (StoneStatus, error) {
+	for _, status := range []StoneStatus{StatusAlive, StatusDead, StatusSeki} {
+		if s == status.String() {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status %q", s)
+}
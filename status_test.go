@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPassAlive(t *testing.T) {
+	// Black's chain has three one-point eyes; the white stone is alone
+	// in the open.
+	b := NewBoard(5)
+	for col := 0; col < 5; col++ {
+		b.grid[1][col] = Black
+	}
+	b.grid[0][1], b.grid[0][3] = Black, Black
+	b.grid[3][2] = White
+	alive, safe := b.passAlive(Black)
+	if !alive[5] || !alive[1] || !safe[0] || safe[3*5+2] {
+		t.Errorf("black: alive %v, safe %v", alive, safe)
+	}
+	if alive, _ := b.passAlive(White); alive[3*5+2] {
+		t.Error("lone white stone is pass-alive")
+	}
+
+	// With one eye nothing is certain.
+	b.grid[0][1], b.grid[0][3] = Empty, Empty
+	if alive, _ := b.passAlive(Black); alive[5] {
+		t.Error("chain with one eye is pass-alive")
+	}
+}
+
+func TestSeki(t *testing.T) {
+	// Black's 3 1 group and White's two stones on the edge share their
+	// last two liberties, inside White's living wall.
+	b := NewBoard(9)
+	for _, p := range [][2]int{{0, 2}, {1, 2}, {1, 3}, {1, 4}, {1, 5}, {1, 6}} {
+		b.grid[p[0]][p[1]] = Black
+	}
+	for _, p := range [][2]int{{0, 4}, {0, 5}, {0, 1}, {1, 1}, {1, 7}, {0, 7}} {
+		b.grid[p[0]][p[1]] = White
+	}
+	for col := 0; col < 9; col++ {
+		b.grid[2][col] = White
+	}
+	status, err := FinalStatus(context.Background(), NewEngine(100, 0.5), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status[2] != StatusSeki || status[4] != StatusSeki || status[2*9] != StatusAlive {
+		t.Errorf("black %s, white %s, wall %s", status[2], status[4], status[2*9])
+	}
+	// Filling a shared liberty ends the seki.
+	b.grid[0][3] = Black
+	if status, _ := FinalStatus(context.Background(), NewEngine(100, 0.5), b); status[4] == StatusSeki {
+		t.Error("seki after a shared liberty was filled")
+	}
+}
+
+func TestFinalStatusGTP(t *testing.T) {
+	g := &gtpEngine{engine: NewEngine(300, 0.5), board: NewBoard(7)}
+	var script strings.Builder
+	for col := 0; col < 7; col++ {
+		script.WriteString("play b " + gtpVertex(3*7+col, 7) + "\nplay w " + gtpVertex(4*7+col, 7) + "\n")
+	}
+	script.WriteString("play w D6\n1 final_status_list dead\n2 final_status_list alive\n3 final_score\nquit\nname\n") // nothing after quit
+	var out strings.Builder
+	g.board = NewBoard(7)
+	if err := g.serve(context.Background(), strings.NewReader("boardsize 7\n"+script.String()), &out); err != nil {
+		t.Fatal(err)
+	}
+	replies := strings.Split(strings.TrimSpace(out.String()), "\n\n")
+	if len(replies) != 20 {
+		t.Fatalf("%d replies:\n%s", len(replies), out.String())
+	}
+	if dead := replies[16]; dead != "=1 D6" {
+		t.Errorf("dead: %q", dead)
+	}
+	if alive := replies[17]; strings.Count(alive, "\n") != 1 || strings.Contains(alive, "D6") {
+		t.Errorf("alive: %q", alive)
+	}
+	// Black has 28 points, White 21 and komi.
+	if score := replies[18]; score != "=3 B+6.5" {
+		t.Errorf("score: %q", score)
+	}
+}
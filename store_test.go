@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOpenStore(t *testing.T) {
+	g := &GameRecord{Size: 9, Moves: []RecordedMove{{Color: Black, Move: 40}}}
+	path := filepath.Join(t.TempDir(), "games.json")
+	bolt := filepath.Join(t.TempDir(), "games.db")
+	for _, dsn := range []string{"memory:", "file:" + path, "bolt:" + bolt} {
+		db, err := OpenStore(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := db.Add("a", "test", g); !ok || err != nil {
+			t.Fatalf("%s: add: %v, %v", dsn, ok, err)
+		}
+		if ok, _ := db.Add("b", "test", g); ok {
+			t.Errorf("%s: added the same game twice", dsn)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dsn := range []string{path, "bolt:" + bolt} {
+		if games, err := loadGames(dsn); err != nil || len(games) != 1 || games[0].ID != "a" {
+			t.Errorf("%s after close: %v, %v", dsn, games, err)
+		}
+	}
+
+	if _, err := OpenStore("postgres://localhost/gogame"); err == nil || !strings.Contains(err.Error(), "driver") {
+		t.Errorf("postgres without a driver: %v", err)
+	}
+}
+
+// fakeSQL is a database/sql driver, registered as "sqlite", that keeps one
+// database per data source name in memory and understands just the
+// statements sqlStore makes. Any other statement is only logged.
+type fakeSQL struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	version *int64
+	games   [][]driver.Value // the columns of the INSERT, id and key first
+	log     []string         // the statements not understood
+}
+
+var fakeSQLDriver = &fakeSQL{dbs: map[string]*fakeDB{}}
+
+func init() {
+	sql.Register("sqlite", fakeSQLDriver)
+}
+
+func (f *fakeSQL) Open(name string) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dbs[name] == nil {
+		f.dbs[name] = &fakeDB{}
+	}
+	return fakeConn{f, f.dbs[name]}, nil
+}
+
+type fakeConn struct {
+	f  *fakeSQL
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	c     fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.f.mu.Lock()
+	defer s.c.f.mu.Unlock()
+	db := s.c.db
+	q := strings.Join(strings.Fields(s.query), " ")
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS gogame_"):
+	case strings.HasPrefix(q, "INSERT INTO gogame_schema"), strings.HasPrefix(q, "UPDATE gogame_schema SET version"):
+		v := args[0].(int64)
+		db.version = &v
+	case strings.HasPrefix(q, "INSERT INTO gogame_games"):
+		for _, g := range db.games {
+			if g[0] == args[0] || string(g[1].([]byte)) == string(args[1].([]byte)) {
+				return driver.RowsAffected(0), nil
+			}
+		}
+		db.games = append(db.games, args)
+	default:
+		db.log = append(db.log, q)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.f.mu.Lock()
+	defer s.c.f.mu.Unlock()
+	db := s.c.db
+	switch q := strings.Join(strings.Fields(s.query), " "); {
+	case q == "SELECT version FROM gogame_schema":
+		rows := &fakeRows{cols: []string{"version"}}
+		if db.version != nil {
+			rows.rows = [][]driver.Value{{*db.version}}
+		}
+		return rows, nil
+	case strings.HasPrefix(q, "SELECT id, source,"):
+		rows := &fakeRows{cols: strings.Split("id source black white black_rank white_rank result date size komi handicap position moves colors", " ")}
+		for _, g := range db.games {
+			rows.rows = append(rows.rows, append([]driver.Value{g[0]}, g[2:]...))
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("fake sql: cannot query %q", q)
+	}
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	dsn := "sqlite:" + t.Name()
+	g := &GameRecord{Size: 9, Black: "Ann", White: "Bo", Komi: 7, Result: "B+R", Moves: []RecordedMove{{Color: Black, Move: 40}, {Color: White, Move: 30}}}
+	db, err := OpenStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.String() != "sqlite database" {
+		t.Errorf("store named %q", db)
+	}
+	if ok, err := db.Add("a", "test", g); !ok || err != nil {
+		t.Fatalf("add: %v, %v", ok, err)
+	}
+	if ok, _ := db.Add("b", "test", g); ok {
+		t.Error("added the same game twice")
+	}
+	if ok, _ := db.Add("a", "test", &GameRecord{Size: 9}); ok {
+		t.Error("added a game under an id taken")
+	}
+	if ok, err := db.Add("c", "test", &GameRecord{Size: 9, Moves: []RecordedMove{{Color: Black, Move: 0}}}); !ok || err != nil {
+		t.Errorf("add another: %v, %v", ok, err)
+	}
+	db.Close()
+
+	games, err := loadGames(dsn)
+	if err != nil || len(games) != 2 {
+		t.Fatalf("games: %v, %v", games, err)
+	}
+	if want := newDBGame("a", "test", g); fmt.Sprint(games[0]) != fmt.Sprint(want) || games[1].ID != "c" {
+		t.Errorf("read back %+v, want %+v", games[0], want)
+	}
+	if v := fakeSQLDriver.dbs[t.Name()].version; v == nil || *v != gameDBVersion {
+		t.Errorf("schema version %v", v)
+	}
+}
+
+func TestSQLStoreMigrations(t *testing.T) {
+	for v := sqlFirstVersion; v < gameDBVersion; v++ {
+		if sqlMigrations[v] == nil {
+			t.Errorf("no SQL migration from schema version %d", v)
+		}
+	}
+
+	db, err := openSQLStore("sqlite", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	fake := fakeSQLDriver.dbs[t.Name()]
+	sqlMigrations[gameDBVersion] = func(tx *sql.Tx, driver string) error {
+		_, err := tx.Exec(`CREATE INDEX gogame_games_black ON gogame_games (black)`)
+		return err
+	}
+	defer delete(sqlMigrations, gameDBVersion)
+	if err := db.migrate(gameDBVersion, gameDBVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(fake.log, []string{"CREATE INDEX gogame_games_black ON gogame_games (black)"}) || *fake.version != gameDBVersion+1 {
+		t.Errorf("migrated with %q to version %d", fake.log, *fake.version)
+	}
+	if err := db.migrate(gameDBVersion+1, gameDBVersion+2); err == nil {
+		t.Error("migrated with no migration")
+	}
+
+	// This gogame does not know the tables now.
+	if _, err := openSQLStore("sqlite", t.Name()); err == nil || !strings.Contains(err.Error(), "newer gogame") {
+		t.Errorf("opened a newer schema: %v", err)
+	}
+	sqlMigrations[gameDBVersion] = func(*sql.Tx, string) error { return errors.New("boom") }
+	if err := db.migrate(gameDBVersion, gameDBVersion+1); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("a failing migration: %v", err)
+	}
+
+	*fake.version = sqlFirstVersion - 1
+	if _, err := openSQLStore("sqlite", t.Name()); err == nil || !strings.Contains(err.Error(), "older than any SQL store") {
+		t.Errorf("opened a schema older than the SQL stores: %v", err)
+	}
+}
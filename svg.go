@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// SVG board renderer shared by the image exports.
+
+// SVGOptions controls how a board is drawn. Cell is the distance between
+// lines in user units; LastMove is marked with a circle unless it is
+// PassMove. Ownership, if set, shades each point by its expected owner,
+// from +1 for Black to -1 for White (see Engine.Ownership), and marks
+// stones expected to be captured. Theme gives the colours, the zero Theme
+// being wood.
+type SVGOptions struct {
+	Cell        float64
+	Coordinates bool
+	LastMove    int
+	Ownership   []float64
+	Theme       Theme
+}
+
+// ownershipShown is the least ownership shaded, and ownershipDead the
+// ownership against a stone from which it is marked as dead.
+const (
+	ownershipShown = 0.1
+	ownershipDead  = 0.5
+)
+
+func DefaultSVGOptions() SVGOptions {
+	return SVGOptions{Cell: 24, Coordinates: true, LastMove: PassMove}
+}
+
+// starPoints returns the move indexes of the hoshi for a board size. Up to
+// 25x25 they follow the usual 9x9, 13x13 and 19x19 layouts; larger boards
+// get a grid of them on the fourth line and at intervals of about six
+// lines between, as on 19x19.
+func starPoints(size int) []int {
+	if size < 7 {
+		return nil
+	}
+	edge := 3
+	if size < 13 {
+		edge = 2
+	}
+	lo, mid, hi := edge, size/2, size-1-edge
+	if size > 25 {
+		// Lay out half the lines and mirror them, so uneven spacing
+		// stays symmetric.
+		span := hi - lo
+		parts := max((span+3)/6, 1)
+		var lines []int
+		for k := 0; k <= parts; k++ {
+			if k <= parts/2 {
+				lines = append(lines, lo+(2*k*span+parts)/(2*parts))
+			} else {
+				lines = append(lines, hi-(2*(parts-k)*span+parts)/(2*parts))
+			}
+		}
+		var points []int
+		for _, r := range lines {
+			for _, c := range lines {
+				points = append(points, r*size+c)
+			}
+		}
+		return points
+	}
+	points := []int{lo*size + lo, lo*size + hi, hi*size + lo, hi*size + hi}
+	if size%2 == 1 {
+		points = append(points, mid*size+mid)
+		if size >= 13 {
+			points = append(points, lo*size+mid, mid*size+lo, mid*size+hi, hi*size+mid)
+		}
+	}
+	return points
+}
+
+// svgBoardSize is the width and height of a board drawing.
+func svgBoardSize(size int, opts SVGOptions) float64 {
+	margin := opts.Cell
+	if opts.Coordinates {
+		margin = 1.5 * opts.Cell
+	}
+	return 2*margin + float64(size-1)*opts.Cell
+}
+
+// WriteBoardSVG draws b as an SVG group with its top-left corner at x, y.
+func WriteBoardSVG(w io.Writer, b BoardView, x, y float64, opts SVGOptions) {
+	size := b.Size()
+	cell := opts.Cell
+	theme := opts.Theme.or()
+	margin := cell
+	if opts.Coordinates {
+		margin = 1.5 * cell
+	}
+	full := svgBoardSize(size, opts)
+	pos := func(i int) float64 { return margin + float64(i)*cell }
+	fmt.Fprintf(w, `<g transform="translate(%g %g)">`+"\n", x, y)
+	fmt.Fprintf(w, `<rect width="%g" height="%g" fill="%s"/>`+"\n", full, full, theme.Board)
+	for i := 0; i < size; i++ {
+		fmt.Fprintf(w, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="1"/>`+"\n", pos(0), pos(i), pos(size-1), pos(i), theme.Lines)
+		fmt.Fprintf(w, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="1"/>`+"\n", pos(i), pos(0), pos(i), pos(size-1), theme.Lines)
+		if opts.Coordinates {
+			fmt.Fprintf(w, `<text x="%g" y="%g" font-size="%g" text-anchor="middle" font-family="sans-serif" fill="%s">%s</text>`+"\n", pos(i), margin/2, cell/2, theme.Lines, userCoords.Column(i, size))
+			fmt.Fprintf(w, `<text x="%g" y="%g" font-size="%g" text-anchor="middle" dominant-baseline="middle" font-family="sans-serif" fill="%s">%s</text>`+"\n", margin/2, pos(i), cell/2, theme.Lines, userCoords.Row(i, size))
+		}
+	}
+	for _, p := range starPoints(size) {
+		fmt.Fprintf(w, `<circle cx="%g" cy="%g" r="%g" fill="%s"/>`+"\n", pos(p%size), pos(p/size), cell/10, theme.Lines)
+	}
+	if len(opts.Ownership) == size*size {
+		for p, o := range opts.Ownership {
+			if math.Abs(o) >= ownershipShown && b.At(p/size, p%size) == Empty {
+				fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s" fill-opacity="%.2f"/>`+"\n",
+					pos(p%size)-cell/2, pos(p/size)-cell/2, cell, cell, ownerFill(o), 0.6*math.Abs(o))
+			}
+		}
+	}
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			switch b.At(row, col) {
+			case Black:
+				fmt.Fprintf(w, `<circle cx="%g" cy="%g" r="%g" fill="%s"/>`+"\n", pos(col), pos(row), cell*0.47, theme.Black)
+			case White:
+				fmt.Fprintf(w, `<circle cx="%g" cy="%g" r="%g" fill="%s" stroke="%s" stroke-width="1"/>`+"\n", pos(col), pos(row), cell*0.47, theme.White, theme.Lines)
+			default:
+				continue
+			}
+			// Dead stones carry a square of the colour that will own the point.
+			if len(opts.Ownership) == size*size {
+				if o := opts.Ownership[row*size+col]; o*ownerSign(b.At(row, col)) <= -ownershipDead {
+					fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s" stroke="%s" stroke-width="1"/>`+"\n",
+						pos(col)-cell/6, pos(row)-cell/6, cell/3, cell/3, ownerFill(o), ownerFill(-o))
+				}
+			}
+		}
+	}
+	if m := opts.LastMove; m != PassMove && m >= 0 && m < size*size {
+		color := theme.marker(b.At(m/size, m%size))
+		fmt.Fprintf(w, `<circle cx="%g" cy="%g" r="%g" fill="none" stroke="%s" stroke-width="2"/>`+"\n", pos(m%size), pos(m/size), cell/4, color)
+	}
+	fmt.Fprintln(w, "</g>")
+}
+
+func ownerFill(ownership float64) string {
+	if ownership > 0 {
+		return "black"
+	}
+	return "white"
+}
+
+// WriteSVG writes a standalone SVG document of one board.
+func WriteSVG(w io.Writer, b BoardView, opts SVGOptions) {
+	full := svgBoardSize(b.Size(), opts)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n", full, full, full, full)
+	WriteBoardSVG(w, b, 0, 0, opts)
+	fmt.Fprintln(w, "</svg>")
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// A tablebase solves tiny boards exactly, by alpha-beta search over every
+// line of play, and keeps the value of each position it has solved, so
+// that the engine plays them perfectly and solve-board can tell who wins.
+//
+// Values are area scores, Tromp-Taylor style: the game ends after two
+// passes in a row and every stone counts, so the value of the empty board
+// is the fair komi. Moves may not repeat an earlier position (positional
+// superko, as the superko rule plugin), without which tiny boards cycle
+// forever. Positions are stored up to the board's symmetries and swapping
+// the colours, and only when their search did not run into a repetition of
+// a position played before them. That is the usual answer to the graph
+// history interaction, and not a complete one: a stored value can still
+// count on a ko recapture that another way to the position forbids.
+//
+// 2x2 and 3x3 are solved in under a second, 3x3 a Black win by 9, and the
+// engine solves them rather than search. Larger boards grow too fast to
+// search every line; -prune, never filling one's own one-point eye, solves
+// 4x4 in about a second, though as the pruning is not proven safe its
+// result is not proven either. 5x5 does not finish even so, and is only
+// tried within -max-nodes or -timeout.
+
+// tablebaseMaxSize is the largest board a tablebase handles, whose points
+// fit the bitboards below.
+const tablebaseMaxSize = 5
+
+// tablebaseEngineNodes bounds the engine's searches of boards larger than
+// 3x3, which it solves with pruning and gives up on past this, searching
+// as usual.
+const tablebaseEngineNodes = 20_000_000
+
+// errTablebaseLimit stops a search that went past its node limit.
+var errTablebaseLimit = errors.New("tablebase: node limit reached")
+
+// Tablebase solves positions of one board size.
+type Tablebase struct {
+	MaxNodes int  // nodes a search may visit, 0 for no limit
+	Prune    bool // never fill a one-point eye of one's own
+	Nodes    int  // nodes visited by the last Solve
+
+	size        int
+	full        uint32     // every point
+	left, right uint32     // points off the first and off the last column
+	syms        [8][]int   // syms[s][p] is point p through symmetry s
+	unsyms      [8][]int   // and unsyms[s] takes it back
+	order       []int      // the points from the centre out
+	mu          sync.Mutex // searches are one at a time
+	table       map[uint64]tbEntry
+	played      map[uint64]int // the positions of the line searched, by index
+}
+
+// tbEntry bounds the value of a position for the side to move, and has
+// the move that did best there, through the key's symmetry, to try first.
+type tbEntry struct {
+	lower, upper int8
+	best         int8
+}
+
+// tablebases are shared by every engine, so what one search solves the
+// next finds.
+var (
+	tablebasesMu sync.Mutex
+	tablebases   = map[int]*Tablebase{}
+)
+
+// tablebaseFor returns the shared tablebase of a board size.
+func tablebaseFor(size int) *Tablebase {
+	tablebasesMu.Lock()
+	defer tablebasesMu.Unlock()
+	if t, ok := tablebases[size]; ok {
+		return t
+	}
+	t := NewTablebase(size)
+	if size > 3 {
+		t.MaxNodes, t.Prune = tablebaseEngineNodes, true
+	}
+	tablebases[size] = t
+	return t
+}
+
+// NewTablebase returns an empty tablebase for boards of size, from 1 to
+// tablebaseMaxSize.
+func NewTablebase(size int) *Tablebase {
+	t := &Tablebase{size: size, full: 1<<(size*size) - 1, table: map[uint64]tbEntry{}, played: map[uint64]int{}}
+	for p := 0; p < size*size; p++ {
+		if p%size != 0 {
+			t.left |= 1 << p
+		}
+		if p%size != size-1 {
+			t.right |= 1 << p
+		}
+	}
+	for s := range t.syms {
+		t.syms[s] = make([]int, size*size)
+		for p := range t.syms[s] {
+			r, c := transformPoint(s, p/size, p%size, size)
+			t.syms[s][p] = r*size + c
+		}
+		t.unsyms[s] = make([]int, size*size)
+		for p, q := range t.syms[s] {
+			t.unsyms[s][q] = p
+		}
+	}
+	centre := func(p int) int {
+		r, c := 2*(p/size)-(size-1), 2*(p%size)-(size-1)
+		return r*r + c*c
+	}
+	for p := 0; p < size*size; p++ {
+		t.order = append(t.order, p)
+	}
+	sort.SliceStable(t.order, func(i, j int) bool { return centre(t.order[i]) < centre(t.order[j]) })
+	return t
+}
+
+// expand is g with the points next to it.
+func (t *Tablebase) expand(g uint32) uint32 {
+	n := t.size
+	return (g | g<<1&t.left | g>>1&t.right | g<<n | g>>n) & t.full
+}
+
+// group is the chain of stones through point p.
+func (t *Tablebase) group(p int, stones uint32) uint32 {
+	g := uint32(1) << p
+	for {
+		next := t.expand(g) & stones
+		if next == g {
+			return g
+		}
+		g = next
+	}
+}
+
+// play puts a stone of the side to move, me, on p and takes what it
+// captures; ok is false for an occupied point or suicide.
+func (t *Tablebase) play(me, opp uint32, p int) (uint32, uint32, bool) {
+	stone := uint32(1) << p
+	if (me|opp)&stone != 0 {
+		return me, opp, false
+	}
+	me |= stone
+	empty := t.full &^ (me | opp)
+	for nb := t.expand(stone) & opp; nb != 0; nb &= nb - 1 {
+		q := bits.TrailingZeros32(nb)
+		if opp&(1<<q) == 0 {
+			continue // taken with a neighbour already
+		}
+		if g := t.group(q, opp); t.expand(g)&empty == 0 {
+			opp &^= g
+			empty |= g
+		}
+	}
+	if t.expand(t.group(p, me))&empty == 0 {
+		return 0, 0, false
+	}
+	return me, opp, true
+}
+
+// score is the area of me less the area of opp.
+func (t *Tablebase) score(me, opp uint32) int {
+	area := bits.OnesCount32(me) - bits.OnesCount32(opp)
+	empty := t.full &^ (me | opp)
+	for empty != 0 {
+		region := t.group(bits.TrailingZeros32(empty), empty)
+		empty &^= region
+		border := t.expand(region) &^ region
+		switch {
+		case border&opp == 0 && border&me != 0:
+			area += bits.OnesCount32(region)
+		case border&me == 0 && border&opp != 0:
+			area -= bits.OnesCount32(region)
+		}
+	}
+	return area
+}
+
+// transform moves the stones of g through symmetry s.
+func (t *Tablebase) transform(g uint32, s int) uint32 {
+	var out uint32
+	for ; g != 0; g &= g - 1 {
+		out |= 1 << t.syms[s][bits.TrailingZeros32(g)]
+	}
+	return out
+}
+
+// key identifies a position up to symmetry: the stones of the side to
+// move and of the other side, and whether the last move was a pass. sym is
+// the symmetry that takes the position to its key.
+func (t *Tablebase) key(me, opp uint32, passed bool) (key uint64, sym int) {
+	key = 1<<64 - 1
+	for s := range t.syms {
+		if k := uint64(t.transform(me, s)) | uint64(t.transform(opp, s))<<25; k < key {
+			key, sym = k, s
+		}
+	}
+	if passed {
+		key |= 1 << 50
+	}
+	return key, sym
+}
+
+// position is the arrangement of stones, for the superko history.
+func position(me, opp uint32, black bool) uint64 {
+	if !black {
+		me, opp = opp, me
+	}
+	return uint64(me) | uint64(opp)<<25
+}
+
+// search finds the value of a position for the side to move, me, black
+// when Black is to move, within alpha and beta; root is the position
+// Solve was asked about. t.played holds the positions of the line to it,
+// this one last at index last. It returns the best move and the earliest
+// index in t.played a cut repetition went back to, last+1 for none.
+func (t *Tablebase) search(ctx context.Context, me, opp uint32, black, passed, root bool, alpha, beta, last int) (value, best, rep int, err error) {
+	t.Nodes++
+	if t.MaxNodes > 0 && t.Nodes > t.MaxNodes {
+		return 0, PassMove, 0, errTablebaseLimit
+	}
+	if t.Nodes%4096 == 0 && ctx.Err() != nil {
+		return 0, PassMove, 0, ctx.Err()
+	}
+	key, sym := t.key(me, opp, passed)
+	entry, ok := t.table[key]
+	if !ok {
+		entry = tbEntry{lower: -127, upper: 127, best: -1}
+	}
+	// The root is searched through for its best move, which is not kept.
+	if !root {
+		switch {
+		case entry.lower == entry.upper, int(entry.lower) >= beta:
+			return int(entry.lower), PassMove, last + 1, nil
+		case int(entry.upper) <= alpha:
+			return int(entry.upper), PassMove, last + 1, nil
+		}
+		alpha, beta = max(alpha, int(entry.lower)), min(beta, int(entry.upper))
+	}
+
+	rep = last + 1
+	value, best = -127, PassMove
+	// Passing after a pass ends the game.
+	if passed {
+		value = t.score(me, opp)
+	}
+	moves := t.order
+	if entry.best >= 0 {
+		first := t.unsyms[sym][entry.best]
+		moves = append([]int{first}, t.order...)
+	}
+	for i, p := range moves {
+		if value >= beta {
+			break
+		}
+		if i > 0 && p == moves[0] && len(moves) > len(t.order) {
+			continue // tried first
+		}
+		if t.Prune && t.expand(1<<p)&^(1<<p)&^me == 0 {
+			continue
+		}
+		nme, nopp, ok := t.play(me, opp, p)
+		if !ok {
+			continue
+		}
+		pos := position(nme, nopp, black)
+		if at, repeated := t.played[pos]; repeated {
+			rep = min(rep, at)
+			continue
+		}
+		t.played[pos] = last + 1
+		v, _, r, err := t.search(ctx, nopp, nme, !black, false, false, -beta, -max(alpha, value), last+1)
+		delete(t.played, pos)
+		if err != nil {
+			return 0, PassMove, 0, err
+		}
+		rep = min(rep, r)
+		if -v > value {
+			value, best = -v, p
+		}
+	}
+	if !passed && value < beta {
+		v, _, r, err := t.search(ctx, opp, me, !black, true, false, -beta, -max(alpha, value), last)
+		if err != nil {
+			return 0, PassMove, 0, err
+		}
+		rep = min(rep, r)
+		if -v > value {
+			value, best = -v, PassMove
+		}
+	}
+
+	if best != PassMove {
+		entry.best = int8(t.syms[sym][best])
+	}
+	if rep >= last {
+		switch {
+		case value <= alpha:
+			entry.upper = int8(min(int(entry.upper), value))
+		case value >= beta:
+			entry.lower = int8(max(int(entry.lower), value))
+		default:
+			entry.lower, entry.upper = int8(value), int8(value)
+		}
+	}
+	t.table[key] = entry
+	return value, best, rep, nil
+}
+
+// Solve finds the value of the position on b for the side to move, its
+// area lead under perfect play, and the move that keeps it. The position
+// is taken as the first of the game: earlier ones are not known to the
+// superko rule. It fails when ctx is done or the node limit is reached.
+func (t *Tablebase) Solve(ctx context.Context, b *Board) (value, move int, err error) {
+	if b.size != t.size {
+		return 0, PassMove, fmt.Errorf("tablebase for %dx%d asked to solve %dx%d", t.size, t.size, b.size, b.size)
+	}
+	var me, opp uint32
+	for p := 0; p < b.size*b.size; p++ {
+		switch b.grid[p/b.size][p%b.size] {
+		case b.turn:
+			me |= 1 << p
+		case b.turn.Opponent():
+			opp |= 1 << p
+		}
+	}
+	if b.IsGameOver() {
+		return t.score(me, opp), PassMove, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Nodes = 0
+	n := t.size * t.size
+	black := b.turn == Black
+	// Null-window tests, each whether the value reaches a bound, narrow
+	// it down faster than one search with the full window.
+	lo, hi := -n, n
+	move = PassMove
+	for lo < hi {
+		bound := lo + (hi-lo+1)/2
+		t.played = map[uint64]int{position(me, opp, black): 0}
+		v, m, _, err := t.search(ctx, me, opp, black, b.passes > 0, true, bound-1, bound, 0)
+		if err != nil {
+			return 0, PassMove, err
+		}
+		if v >= bound {
+			lo, move = v, m
+		} else {
+			hi = v
+		}
+	}
+	return lo, move, nil
+}
+
+// Entries is the number of positions the tablebase holds.
+func (t *Tablebase) Entries() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.table)
+}
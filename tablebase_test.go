@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTablebaseSolve(t *testing.T) {
+	for _, tc := range []struct {
+		size, value, move int
+	}{
+		{1, 0, PassMove},
+		{3, 9, 4}, // the centre, and Black owns the board
+	} {
+		tb := NewTablebase(tc.size)
+		value, move, err := tb.Solve(context.Background(), NewBoard(tc.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != tc.value || move != tc.move {
+			t.Errorf("%dx%d: value %d, move %d, want %d, %d", tc.size, tc.size, value, move, tc.value, tc.move)
+		}
+	}
+}
+
+func TestTablebaseCaptures(t *testing.T) {
+	tb := NewTablebase(3)
+	// White at 0 1 and 1 0 take the black stone in the corner.
+	me, opp, ok := tb.play(1<<1, 1<<0, 3)
+	if !ok || opp != 0 || me != 1<<1|1<<3 {
+		t.Errorf("play = %b, %b, %v, want the corner taken", me, opp, ok)
+	}
+	if got := tb.score(me, opp); got != 9 {
+		t.Errorf("score = %d, want 9 for the whole board", got)
+	}
+	if _, _, ok := tb.play(0, 1<<1|1<<3, 0); ok {
+		t.Error("suicide in the corner was allowed")
+	}
+}
+
+func TestEngineUsesTablebase(t *testing.T) {
+	e := NewEngine(1, 0)
+	row, col, pass, err := e.GenMove(context.Background(), NewBoard(3))
+	if err != nil || pass || row != 1 || col != 1 {
+		t.Errorf("GenMove = %d %d pass %v, %v, want the centre", row, col, pass, err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serve -lobby -telegram-token TOKEN lets people play from Telegram. Each
+// chat with the bot is a connection to the lobby like any other, so
+// Telegram players see, challenge and play those on connect and each
+// other. Messages are lobby commands, with or without a slash
+// (/challenge size=9, /accept 3, /who, /games), and in a game, moves as
+// GTP vertices such as D4 or as "row col", /pass, /resign and /adjourn.
+// The bot sends the board with the opponent's move when it is the
+// player's turn, and /board sends it at any time. See chat.go.
+
+const telegramAPIBase = "https://api.telegram.org"
+
+// telegramAPI calls the Telegram Bot API.
+type telegramAPI struct {
+	base string // the API URL with the bot's token
+}
+
+func newTelegramAPI(base, token string) telegramAPI {
+	return telegramAPI{base: base + "/bot" + token}
+}
+
+// call calls method with params and decodes its result into result, if
+// not nil.
+func (t telegramAPI) call(method string, params, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(t.base+"/"+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL has the token in it; keep it out of the logs.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return fmt.Errorf("telegram %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	var r struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s: %s", method, r.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+type telegramUpdate struct {
+	UpdateID int              `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	From *struct {
+		Username string `json:"username"`
+	} `json:"from"`
+	Text string `json:"text"`
+}
+
+// telegramBot connects Telegram chats to a lobby.
+type telegramBot struct {
+	api   telegramAPI
+	lobby *lobby
+
+	mu    sync.Mutex
+	chats map[int64]*chatSession
+}
+
+// newTelegramBot checks the bot's token and returns the bot and its
+// username.
+func newTelegramBot(api telegramAPI, l *lobby) (*telegramBot, string, error) {
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := api.call("getMe", struct{}{}, &me); err != nil {
+		return nil, "", err
+	}
+	return &telegramBot{api: api, lobby: l, chats: map[int64]*chatSession{}}, me.Username, nil
+}
+
+// run long-polls Telegram for messages and hands them to their chats,
+// forever.
+func (b *telegramBot) run() {
+	offset := 0
+	for {
+		var updates []telegramUpdate
+		params := map[string]any{"offset": offset, "timeout": 25, "allowed_updates": []string{"message"}}
+		if err := b.api.call("getUpdates", params, &updates); err != nil {
+			fmt.Println(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if m := u.Message; m != nil && m.Text != "" {
+				b.message(m)
+			}
+		}
+	}
+}
+
+// message hands m to its chat.
+func (b *telegramBot) message(m *telegramMessage) {
+	text := m.Text
+	first, _, _ := strings.Cut(text, " ")
+	if cmd, _, ok := strings.Cut(first, "@"); ok && strings.HasPrefix(cmd, "/") {
+		text = cmd + strings.TrimPrefix(text, first) // /who@gogamebot in a group
+	}
+	b.chat(m).input(text)
+}
+
+// chat returns the chat m is in, joining it to the lobby if it is new.
+func (b *telegramBot) chat(m *telegramMessage) *chatSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := m.Chat.ID
+	if c := b.chats[id]; c != nil {
+		return c
+	}
+	name := ""
+	if m.From != nil {
+		name = m.From.Username
+	}
+	say := func(text, board string) {
+		msg := html.EscapeString(text)
+		if board != "" {
+			msg += "\n<pre>" + html.EscapeString(board) + "</pre>"
+		}
+		params := map[string]any{"chat_id": id, "text": msg, "parse_mode": "HTML"}
+		if err := b.api.call("sendMessage", params, nil); err != nil {
+			fmt.Println(err)
+		}
+	}
+	c := startChatSession(b.lobby, name, "/", say, func() {
+		b.mu.Lock()
+		delete(b.chats, id)
+		b.mu.Unlock()
+	})
+	b.chats[id] = c
+	return c
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramBot(t *testing.T) {
+	type sent struct {
+		ChatID int64  `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	messages := make(chan sent, 100)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/botTOKEN/getMe"):
+			w.Write([]byte(`{"ok": true, "result": {"username": "gogamebot"}}`))
+		case strings.HasSuffix(r.URL.Path, "/botTOKEN/sendMessage"):
+			var m sent
+			json.NewDecoder(r.Body).Decode(&m)
+			messages <- m
+			w.Write([]byte(`{"ok": true, "result": {}}`))
+		default:
+			w.Write([]byte(`{"ok": false, "description": "Unauthorized"}`))
+		}
+	}))
+	defer api.Close()
+	expect := func(chat int64, want string) string {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case m := <-messages:
+				if m.ChatID == chat && strings.Contains(m.Text, want) {
+					return m.Text
+				}
+			case <-timeout:
+				t.Fatalf("chat %d was not sent %q", chat, want)
+			}
+		}
+	}
+
+	if _, _, err := newTelegramBot(newTelegramAPI(api.URL, "WRONG"), nil); err == nil || strings.Contains(err.Error(), "WRONG") {
+		t.Errorf("a wrong token: %v", err)
+	}
+	bot, name, err := newTelegramBot(newTelegramAPI(api.URL, "TOKEN"), newLobby(time.Second, nil, nil))
+	if err != nil || name != "gogamebot" {
+		t.Fatalf("newTelegramBot = %q, %v", name, err)
+	}
+	message := func(chat int64, user, text string) {
+		m := &telegramMessage{Text: text}
+		m.Chat.ID = chat
+		m.From = &struct {
+			Username string `json:"username"`
+		}{user}
+		bot.message(m)
+	}
+
+	message(1, "alice", "/challenge@gogamebot size=5 color=black")
+	expect(1, "New challenge #1 alice")
+	message(2, "bob", "/accept 1")
+	expect(1, "You play black.")
+	if text := expect(1, "Your move 1."); !strings.Contains(text, "<pre>") {
+		t.Errorf("no board with the turn: %q", text)
+	}
+	message(1, "alice", "C3")
+	if text := expect(2, "black plays C3. Your move 2."); !strings.Contains(text, " 3 + + ●") {
+		t.Errorf("board = %q", text)
+	}
+	message(3, "carol", "/games")
+	expect(3, "alice vs bob (5x5)")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sigwinch is SIGWINCH on Linux, macOS and the BSDs. syscall does not
+// define it everywhere; where it means nothing, Notify never fires.
+const sigwinch = syscall.Signal(28)
+
+// stty runs stty with args on the terminal f and returns its output.
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize returns the size of the terminal on f in character cells,
+// as reported by stty.
+func terminalSize(f *os.File) (width, height int, ok bool) {
+	out, err := stty(f, "size")
+	if err != nil {
+		return 0, 0, false
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	rows, err1 := strconv.Atoi(fields[0])
+	cols, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || rows <= 0 || cols <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+// screenSize returns the terminal size, falling back to $COLUMNS and
+// $LINES and then to 80x24.
+func screenSize(f *os.File) (int, int) {
+	if w, h, ok := terminalSize(f); ok {
+		return w, h
+	}
+	w, err1 := strconv.Atoi(os.Getenv("COLUMNS"))
+	h, err2 := strconv.Atoi(os.Getenv("LINES"))
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// cbreak turns off line buffering and echo on the terminal f so single
+// key presses can be read, and returns a function restoring the settings.
+func cbreak(f *os.File) (restore func(), err error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, strings.TrimSpace(saved)) }, nil
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// notifyResize delivers a signal on ch whenever the terminal is resized.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, sigwinch)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	commands["territory"] = runTerritory
+}
+
+// WriteTerritoryDiagram draws b shaded by ownership to path, as SVG or,
+// for a .png name, as a bitmap with cell pixels between lines, in theme's
+// colours.
+func WriteTerritoryDiagram(path string, b BoardView, ownership []float64, cell int, theme Theme) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		err = png.Encode(f, RenderBoard(b, RasterOptions{Cell: cell, LastMove: PassMove, Ownership: ownership, Theme: theme}))
+	} else {
+		opts := DefaultSVGOptions()
+		opts.Cell, opts.Ownership, opts.Theme = float64(cell), ownership, theme
+		WriteSVG(f, b, opts)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func runTerritory(args []string) error {
+	fs := flag.NewFlagSet("territory", flag.ExitOnError)
+	out := fs.String("o", "", "output .svg or .png (default: the SGF name with .svg)")
+	move := fs.Int("move", -1, "position after this many moves (default the end of the game)")
+	playouts := fs.Int("playouts", 2000, "playouts for the ownership estimate")
+	cell := fs.Int("cell", 24, "distance between lines, in pixels for PNG")
+	game := fs.Int("game", 1, "game number within the file")
+	themeName := fs.String("theme", "", "board colours: wood, classic, high-contrast, print or one from the config file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: territory file.sgf [-o diagram.svg|png] [-move N] [-playouts N] [-cell 24] [-game N] [-theme NAME]")
+	}
+	path := fs.Arg(0)
+	fs.Parse(fs.Args()[1:]) // flags may follow the file name
+	if *cell < 8 {
+		return errors.New("-cell must be at least 8")
+	}
+
+	theme, err := FindTheme(configPath(), *themeName)
+	if err != nil {
+		return err
+	}
+	games, err := ReadSGFFile(path)
+	if err != nil {
+		return err
+	}
+	if *game < 1 || *game > len(games) {
+		return fmt.Errorf("%s has %d games", path, len(games))
+	}
+	g, err := RecordFromSGF(games[*game-1])
+	if err != nil {
+		return err
+	}
+	n := len(g.Moves)
+	if *move >= 0 {
+		n = min(*move, n)
+	}
+	b, err := g.Replay(n)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	ownership, err := NewEngine(*playouts, g.Komi).Ownership(context.Background(), b)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = trimExt(path) + ".svg"
+	}
+	if err := WriteTerritoryDiagram(*out, b, ownership, *cell, theme); err != nil {
+		return err
+	}
+	black, white := 0.0, 0.0
+	for _, o := range ownership {
+		if o > 0 {
+			black += o
+		} else {
+			white -= o
+		}
+	}
+	fmt.Printf("Wrote %s: Black about %.0f points, White about %.0f, before komi\n", *out, black, white)
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOwnershipDiagram(t *testing.T) {
+	b := NewBoard(9)
+	for row := 0; row < 9; row++ {
+		b.grid[row][1] = Black
+		b.grid[row][7] = White
+	}
+	own, err := NewEngine(200, 7.5).Ownership(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if own[4*9] < 0.5 || own[4*9+1] < 0.5 {
+		t.Errorf("Black's side owned %.2f, wall %.2f", own[4*9], own[4*9+1])
+	}
+	if own[4*9+8] > -0.5 || own[4*9+7] > -0.5 {
+		t.Errorf("White's side owned %.2f, wall %.2f", own[4*9+8], own[4*9+7])
+	}
+
+	var plain, shaded bytes.Buffer
+	opts := DefaultSVGOptions()
+	WriteSVG(&plain, b, opts)
+	opts.Ownership = own
+	WriteSVG(&shaded, b, opts)
+	if strings.Contains(plain.String(), "fill-opacity") || !strings.Contains(shaded.String(), "fill-opacity") {
+		t.Error("ownership shading missing from the SVG or drawn without it")
+	}
+	img := RenderBoard(b, RasterOptions{Cell: 16, LastMove: PassMove, Ownership: own})
+	if i := img.ColorIndexAt(16+16*8-5, 16+16*4-5); i < rasterWhiteShade || i > rasterWhiteShade+2 {
+		t.Errorf("White's territory drawn in colour %d", i)
+	}
+}
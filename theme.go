@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	commands["themes"] = runThemes
+}
+
+// Theme is the palette of the board images, SVG and PNG, chosen with
+// -theme. Colours are written #rrggbb. Marker is the last move's mark;
+// empty, it takes the colour of the other stones, as in the print theme.
+type Theme struct {
+	Board  string `json:"board"`
+	Lines  string `json:"lines"` // lines, star points, coordinates and the edge of white stones
+	Black  string `json:"black"`
+	White  string `json:"white"`
+	Marker string `json:"marker"`
+}
+
+// builtinThemes are the themes every installation has. "themes" in the
+// config file may change their colours or add themes of its own, and
+// "theme" there picks the one used when -theme is not given:
+//
+//	{"theme": "club", "themes": {"club": {"board": "#c8a060", "marker": "#2060d0"}}}
+//
+// Colours left out of a built-in theme keep their values, and those left
+// out of a new one are wood's.
+var builtinThemes = map[string]Theme{
+	"wood":          {Board: "#dcb35c", Lines: "#000000", Black: "#000000", White: "#ffffff", Marker: "#d02020"},
+	"classic":       {Board: "#f2d8a0", Lines: "#3a2a10", Black: "#1a1a1a", White: "#f8f8f0", Marker: "#c03030"},
+	"high-contrast": {Board: "#ffe000", Lines: "#000000", Black: "#000000", White: "#ffffff", Marker: "#0040ff"},
+	"print":         {Board: "#ffffff", Lines: "#000000", Black: "#000000", White: "#ffffff"},
+}
+
+// Themes returns the built-in themes as changed by the config file at
+// path, and those it adds, with the name of its default theme.
+func Themes(path string) (map[string]Theme, string, error) {
+	themes := make(map[string]Theme, len(builtinThemes))
+	for name, t := range builtinThemes {
+		themes[name] = t
+	}
+	var config gogameConfig
+	if err := loadJSON(path, &config); err != nil {
+		return nil, "", fmt.Errorf("%s: %v", path, err)
+	}
+	for name, raw := range config.Themes {
+		t, ok := themes[name]
+		if !ok {
+			t = builtinThemes["wood"]
+		}
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, "", fmt.Errorf("%s: theme %q: %v", path, name, err)
+		}
+		for _, c := range []string{t.Board, t.Lines, t.Black, t.White, t.Marker} {
+			if _, err := parseHexColor(c); err != nil && c != "" {
+				return nil, "", fmt.Errorf("%s: theme %q: %v", path, name, err)
+			}
+		}
+		themes[name] = t
+	}
+	def := config.Theme
+	if def == "" {
+		def = "wood"
+	}
+	return themes, def, nil
+}
+
+// FindTheme looks up a theme by name, the config file's default for "".
+func FindTheme(path, name string) (Theme, error) {
+	themes, def, err := Themes(path)
+	if err != nil {
+		return Theme{}, err
+	}
+	if name == "" {
+		name = def
+	}
+	t, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q, see the themes command", name)
+	}
+	return t, nil
+}
+
+// parseHexColor reads a colour written #rrggbb.
+func parseHexColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("colour %q is not #rrggbb", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// or is t with the colours it leaves out taken from wood; the zero Theme
+// is wood.
+func (t Theme) or() Theme {
+	wood := builtinThemes["wood"]
+	if t == (Theme{}) {
+		return wood
+	}
+	for _, f := range []struct {
+		c   *string
+		def string
+	}{{&t.Board, wood.Board}, {&t.Lines, wood.Lines}, {&t.Black, wood.Black}, {&t.White, wood.White}} {
+		if _, err := parseHexColor(*f.c); err != nil {
+			*f.c = f.def
+		}
+	}
+	return t
+}
+
+// marker is the colour of the last move's mark on a stone of color s.
+func (t Theme) marker(s Stone) string {
+	if t.Marker != "" {
+		return t.Marker
+	}
+	if s == White {
+		return t.Black
+	}
+	return t.White
+}
+
+// palette is the raster palette of t, in the order of the raster colour
+// indexes.
+func (t Theme) palette() color.Palette {
+	rgb := func(s string) color.RGBA {
+		c, _ := parseHexColor(s)
+		return c
+	}
+	board := rgb(t.Board)
+	mix := func(to uint8, k int) color.Color {
+		m := func(c uint8) uint8 { return uint8((int(c)*(4-k) + int(to)*k + 2) / 4) }
+		return color.RGBA{m(board.R), m(board.G), m(board.B), 0xff}
+	}
+	marker := rgb(t.Lines)
+	if t.Marker != "" {
+		marker = rgb(t.Marker)
+	}
+	return color.Palette{
+		board, rgb(t.Black), rgb(t.White), marker,
+		// Ownership shades: the board colour a quarter, half and three
+		// quarters of the way to black, then the same towards white.
+		mix(0, 1), mix(0, 2), mix(0, 3), mix(0xff, 1), mix(0xff, 2), mix(0xff, 3),
+		rgb(t.Lines),
+	}
+}
+
+func runThemes(args []string) error {
+	themes, def, err := Themes(configPath())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("  %-14s %-8s %-8s %-8s %-8s %-8s\n", "Theme", "Board", "Lines", "Black", "White", "Marker")
+	for _, name := range names {
+		t := themes[name]
+		mark := " "
+		if name == def {
+			mark = "*"
+		}
+		marker := t.Marker
+		if marker == "" {
+			marker = "contrast"
+		}
+		fmt.Printf("%s %-14s %-8s %-8s %-8s %-8s %-8s\n", mark, name, t.Board, t.Lines, t.Black, t.White, marker)
+	}
+	fmt.Printf("Themes can be changed or added in %s\n", configPath())
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestThemes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"theme": "club", "themes": {"print": {"lines": "#333333"}, "club": {"board": "#c8a060"}}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	club, err := FindTheme(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if club.Board != "#c8a060" || club.Marker != builtinThemes["wood"].Marker {
+		t.Errorf("club theme %+v, want wood with its own board", club)
+	}
+	mono, err := FindTheme(path, "print")
+	if err != nil || mono.Lines != "#333333" || mono.Board != "#ffffff" {
+		t.Errorf("print theme %+v, %v", mono, err)
+	}
+	if _, err := FindTheme(path, "neon"); err == nil {
+		t.Error("unknown theme found")
+	}
+	os.WriteFile(path, []byte(`{"themes": {"bad": {"board": "brown"}}}`), 0o644)
+	if _, err := FindTheme(path, "bad"); err == nil {
+		t.Error("colour brown accepted")
+	}
+
+	// Wood is the zero Theme, with the colours images always had.
+	if c := (Theme{}).or().palette()[rasterWhiteShade]; c != (color.RGBA{0xe5, 0xc6, 0x85, 0xff}) {
+		t.Errorf("wood's lightest shade %v", c)
+	}
+
+	b := NewBoard(9)
+	b.grid[4][4] = Black
+	opts := DefaultSVGOptions()
+	opts.Theme, opts.LastMove = builtinThemes["print"], 4*9+4
+	var svg bytes.Buffer
+	WriteSVG(&svg, b, opts)
+	if s := svg.String(); strings.Contains(s, "#dcb35c") || !strings.Contains(s, `fill="#ffffff"/>`) || !strings.Contains(s, `stroke="#ffffff" stroke-width="2"`) {
+		t.Errorf("print SVG is not black on white with a white mark on the black stone:\n%s", s)
+	}
+	img := RenderBoard(b, RasterOptions{Cell: 16, LastMove: 4*9 + 4, Theme: builtinThemes["print"]})
+	if c := img.At(16+16*4, 16+16*4); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("print PNG last move marked %v", c)
+	}
+}
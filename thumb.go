@@ -0,0 +1,42 @@
+package main
+
+import "image"
+
+// Scaled-down renderers for thumbnails of positions in move lists.
+
+// Thumbnail draws b in at most side x side characters. Each character
+// stands for a square of points and shows the colour with more stones in
+// it, or an empty point if neither has any.
+func Thumbnail(b BoardView, side int) []string {
+	size := b.Size()
+	side = max(side, 1)
+	k := (size + side - 1) / side
+	n := (size + k - 1) / k
+	lines := make([]string, n)
+	for tr := 0; tr < n; tr++ {
+		var line []rune
+		for tc := 0; tc < n; tc++ {
+			var count [3]int
+			for r := tr * k; r < min((tr+1)*k, size); r++ {
+				for c := tc * k; c < min((tc+1)*k, size); c++ {
+					count[b.At(r, c)]++
+				}
+			}
+			switch {
+			case count[Black] == 0 && count[White] == 0:
+				line = append(line, '·')
+			case count[Black] >= count[White]:
+				line = append(line, []rune(Black.String())...)
+			default:
+				line = append(line, []rune(White.String())...)
+			}
+		}
+		lines[tr] = string(line)
+	}
+	return lines
+}
+
+// RenderThumbnail draws b as an image about px pixels square.
+func RenderThumbnail(b BoardView, px int) *image.Paletted {
+	return RenderBoard(b, RasterOptions{Cell: max(px/(b.Size()+1), 2), LastMove: PassMove})
+}
@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// Time management. In a game with a clock the engine does not think a
+// fixed time per move: a TimeManager shares out what is left of the clock
+// by how many moves the game probably has to go, gives the opening less
+// than the middle game, uses what it may of each byo-yomi period, and
+// leaves the rest to the search, which stops at the plan's target when
+// its choice is clear and thinks on towards the limit when it is not (see
+// TimePlan). The limit is never more than the time before a period, or
+// the game, would be lost.
+
+// TimeManager plans the engine's time on the moves of one game.
+type TimeManager struct {
+	Period time.Duration // of byo-yomi, 0 if none
+	Size   int           // of the board
+}
+
+// Plan is the time for the next move, after moves moves of the game, with
+// the engine's clock s.
+func (m TimeManager) Plan(s ClockState, moves int) TimePlan {
+	hard := s.MoveTime(m.Period)
+	if s.Flagged || hard <= 0 {
+		return TimePlan{Target: time.Millisecond, Limit: time.Millisecond}
+	}
+	if s.Byoyomi {
+		// The period starts again after the move, so what is left of it
+		// is there to be used.
+		return TimePlan{Target: hard / 2, Limit: hard}
+	}
+	target := s.Main / time.Duration(m.movesLeft(moves))
+	if s.Periods > 0 {
+		// Main time spent at half a period a move is no loss: once it is
+		// gone every move gets a period anyway.
+		target = max(target, m.Period/2)
+	}
+	if moves < m.Size {
+		target /= 2
+	}
+	limit := min(3*target, hard)
+	if s.Periods == 0 {
+		limit = min(limit, s.Main/4)
+	}
+	return TimePlan{Target: min(target, limit), Limit: limit}
+}
+
+// movesLeft is how many more moves the engine expects to play after moves
+// moves: half of what is left of a game of 0.7 moves a point, and never
+// fewer than the board is wide, as games run long.
+func (m TimeManager) movesLeft(moves int) int {
+	return max((m.Size*m.Size*7/10-moves)/2, m.Size, 1)
+}
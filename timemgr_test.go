@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimeManagerPlan(t *testing.T) {
+	s := time.Second
+	for _, tc := range []struct {
+		name   string
+		m      TimeManager
+		clock  ClockState
+		moves  int
+		target time.Duration
+		limit  time.Duration
+	}{
+		{"opening, half a period at least, halved", TimeManager{30 * s, 19}, ClockState{Main: 10 * time.Minute, Periods: 5}, 0, 15 * s / 2, 45 * s / 2},
+		{"middle game, sudden death", TimeManager{0, 19}, ClockState{Main: 10 * time.Minute}, 100, 10 * time.Minute / 76, 3 * (10 * time.Minute / 76)},
+		{"long game, a quarter of main at most", TimeManager{0, 9}, ClockState{Main: 30 * s}, 70, 30 * s / 9, 30 * s / 4},
+		{"byo-yomi", TimeManager{30 * s, 19}, ClockState{Periods: 3, PeriodLeft: 20 * s, Byoyomi: true}, 200, 10 * s, 20 * s},
+		{"out of time", TimeManager{0, 19}, ClockState{Byoyomi: true, Flagged: true}, 200, time.Millisecond, time.Millisecond},
+	} {
+		if p := tc.m.Plan(tc.clock, tc.moves); p.Target != tc.target || p.Limit != tc.limit {
+			t.Errorf("%s: %+v, want target %v, limit %v", tc.name, p, tc.target, tc.limit)
+		}
+	}
+
+	// In sudden death the engine never runs out, however long it thinks.
+	m, clock := TimeManager{Size: 19}, ClockState{Main: time.Minute}
+	for moves := 0; moves < 600; moves += 2 {
+		clock.Main -= m.Plan(clock, moves).Limit
+		if clock.Main <= 0 {
+			t.Fatalf("out of time at move %d", moves)
+		}
+	}
+}
+
+func TestTimePlanContinue(t *testing.T) {
+	p := TimePlan{Target: time.Second, Limit: 2 * time.Second}
+	for _, tc := range []struct {
+		elapsed     time.Duration
+		instability float64
+		want        bool
+	}{
+		{900 * time.Millisecond, 0, true},
+		{time.Second, 0, false},
+		{1200 * time.Millisecond, 0.2, true},
+		{1500 * time.Millisecond, 0.2, false},
+		{1900 * time.Millisecond, 1, true},
+		{2 * time.Second, 1, false},
+	} {
+		if got := p.Continue(tc.elapsed, tc.instability); got != tc.want {
+			t.Errorf("Continue(%v, %g) = %v", tc.elapsed, tc.instability, got)
+		}
+	}
+}
+
+func TestGenMoveFollowsTimePlan(t *testing.T) {
+	e := NewEngine(1<<30, 7.5)
+	plans := 0
+	e.Think = func() TimePlan {
+		plans++
+		return TimePlan{Target: 50 * time.Millisecond, Limit: 200 * time.Millisecond}
+	}
+	start := time.Now()
+	if _, _, _, err := e.GenMove(context.Background(), NewBoard(9)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); plans != 1 || elapsed > 250*time.Millisecond {
+		t.Errorf("%d plans, took %v", plans, elapsed)
+	}
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func init() {
+	commands["tournament"] = runTournament
+}
+
+type TournamentPlayer struct {
+	Name    string `json:"name"`
+	Rank    Rank   `json:"rank"`
+	Club    string `json:"club,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// TournamentGame is one board of one round. Winner is Black, White or Empty
+// for a jigo; Played is false until a result has been recorded. A bye has no
+// Black player. Adjudicated is set for a result given on the engine's
+// evaluation of an unfinished game (see adjudicate.go).
+type TournamentGame struct {
+	Round       int    `json:"round"`
+	Board       int    `json:"board"`
+	Black       string `json:"black,omitempty"`
+	White       string `json:"white"`
+	Winner      Stone  `json:"winner"`
+	Played      bool   `json:"played"`
+	Adjudicated bool   `json:"adjudicated,omitempty"`
+}
+
+func (g *TournamentGame) IsBye() bool {
+	return g.Black == ""
+}
+
+// score returns the points name earned from the game: 1 for a win or bye,
+// 1/2 for a jigo.
+func (g *TournamentGame) score(name string) float64 {
+	if g.IsBye() {
+		return 1
+	}
+	if !g.Played {
+		return 0
+	}
+	switch {
+	case g.Winner == Empty:
+		return 0.5
+	case g.Winner == Black && g.Black == name, g.Winner == White && g.White == name:
+		return 1
+	}
+	return 0
+}
+
+func (g *TournamentGame) opponent(name string) string {
+	if g.Black == name {
+		return g.White
+	}
+	return g.Black
+}
+
+type Tournament struct {
+	Name    string             `json:"name"`
+	System  string             `json:"system"`
+	Bar     Rank               `json:"bar"`
+	Floor   Rank               `json:"floor"`
+	Rounds  int                `json:"rounds"`
+	Komi    float64            `json:"komi"`
+	Players []TournamentPlayer `json:"players"`
+	Games   []TournamentGame   `json:"games"`
+}
+
+func (t *Tournament) player(name string) *TournamentPlayer {
+	for i := range t.Players {
+		if t.Players[i].Name == name {
+			return &t.Players[i]
+		}
+	}
+	return nil
+}
+
+func (t *Tournament) currentRound() int {
+	round := 0
+	for _, g := range t.Games {
+		if g.Round > round {
+			round = g.Round
+		}
+	}
+	return round
+}
+
+// initialScore is the McMahon starting score: the rank clamped between the
+// floor and the bar, relative to the floor. Swiss tournaments, and players
+// not registered, start at zero.
+func (t *Tournament) initialScore(p *TournamentPlayer) float64 {
+	if t.System != "mcmahon" || p == nil {
+		return 0
+	}
+	r := p.Rank
+	if r > t.Bar {
+		r = t.Bar
+	}
+	if r < t.Floor {
+		r = t.Floor
+	}
+	return float64(r - t.Floor)
+}
+
+func (t *Tournament) gamesOf(name string) []*TournamentGame {
+	var games []*TournamentGame
+	for i := range t.Games {
+		g := &t.Games[i]
+		if g.Black == name || g.White == name {
+			games = append(games, g)
+		}
+	}
+	return games
+}
+
+// Score is the McMahon score of the player name, or their points in a
+// Swiss tournament.
+func (t *Tournament) Score(name string) (float64, error) {
+	if t.player(name) == nil {
+		return 0, fmt.Errorf("no player %q in the tournament", name)
+	}
+	return t.score(name), nil
+}
+
+func (t *Tournament) score(name string) float64 {
+	score := t.initialScore(t.player(name))
+	for _, g := range t.gamesOf(name) {
+		score += g.score(name)
+	}
+	return score
+}
+
+// SOS is the sum of opponents' scores; SODOS only counts the opponents
+// that were beaten, with a jigo counting half.
+func (t *Tournament) SOS(name string) (sos, sodos float64) {
+	for _, g := range t.gamesOf(name) {
+		if g.IsBye() {
+			continue
+		}
+		opp := t.score(g.opponent(name))
+		sos += opp
+		sodos += opp * g.score(name)
+	}
+	return sos, sodos
+}
+
+func (t *Tournament) hasPlayed(a, b string) bool {
+	for _, g := range t.gamesOf(a) {
+		if g.opponent(a) == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tournament) hadBye(name string) bool {
+	for _, g := range t.gamesOf(name) {
+		if g.IsBye() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tournament) whiteCount(name string) int {
+	n := 0
+	for _, g := range t.gamesOf(name) {
+		if g.White == name && !g.IsBye() {
+			n++
+		}
+	}
+	return n
+}
+
+// Standings returns player names ordered by score, SOS and SODOS.
+func (t *Tournament) Standings() []string {
+	names := make([]string, len(t.Players))
+	for i, p := range t.Players {
+		names[i] = p.Name
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		si, sj := t.score(names[i]), t.score(names[j])
+		if si != sj {
+			return si > sj
+		}
+		sosI, sodosI := t.SOS(names[i])
+		sosJ, sodosJ := t.SOS(names[j])
+		if sosI != sosJ {
+			return sosI > sosJ
+		}
+		return sodosI > sodosJ
+	})
+	return names
+}
+
+// PairRound creates the pairings for the next round. Players are ordered by
+// score and rank, and each takes the highest placed opponent they have not
+// met yet. With an odd field the lowest placed player without a bye sits out.
+func (t *Tournament) PairRound() error {
+	round := t.currentRound()
+	for _, g := range t.Games {
+		if g.Round == round && !g.Played && !g.IsBye() {
+			return fmt.Errorf("round %d still has unfinished games", round)
+		}
+	}
+	if t.Rounds > 0 && round >= t.Rounds {
+		return fmt.Errorf("all %d rounds have been paired", t.Rounds)
+	}
+	if len(t.Players) < 2 {
+		return errors.New("need at least two players")
+	}
+	round++
+
+	names := make([]string, len(t.Players))
+	for i, p := range t.Players {
+		names[i] = p.Name
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		si, sj := t.score(names[i]), t.score(names[j])
+		if si != sj {
+			return si > sj
+		}
+		return t.player(names[i]).Rank > t.player(names[j]).Rank
+	})
+
+	if len(names)%2 == 1 {
+		bye := len(names) - 1
+		for i := len(names) - 1; i >= 0; i-- {
+			if !t.hadBye(names[i]) {
+				bye = i
+				break
+			}
+		}
+		t.Games = append(t.Games, TournamentGame{Round: round, White: names[bye], Played: true})
+		names = append(names[:bye], names[bye+1:]...)
+	}
+
+	pairs, ok := t.pairUp(names, new(int))
+	if !ok {
+		pairs = t.pairGreedy(names)
+	}
+	for i, pair := range pairs {
+		a, opp := pair[0], pair[1]
+		white, black := a, opp
+		wa, wb := t.whiteCount(a), t.whiteCount(opp)
+		if wb < wa || (wb == wa && t.player(opp).Rank > t.player(a).Rank) {
+			white, black = opp, a
+		}
+		t.Games = append(t.Games, TournamentGame{Round: round, Board: i + 1, Black: black, White: white})
+	}
+	return nil
+}
+
+// pairTries bounds pairUp's search, which in a field with no pairing free
+// of rematches would otherwise try every one.
+const pairTries = 100000
+
+// pairUp pairs names, in order of placing, each with the highest placed
+// player below not yet met around whom the rest can still be paired
+// without a rematch, backing up as far as it must. ok is false when there
+// is no such pairing, or none was found within pairTries, counted in
+// tries.
+func (t *Tournament) pairUp(names []string, tries *int) (pairs [][2]string, ok bool) {
+	if len(names) == 0 {
+		return nil, true
+	}
+	a := names[0]
+	for j, b := range names[1:] {
+		if *tries++; *tries > pairTries {
+			return nil, false
+		}
+		if t.hasPlayed(a, b) {
+			continue
+		}
+		rest := slices.Concat(names[1:j+1], names[j+2:])
+		if more, ok := t.pairUp(rest, tries); ok {
+			return append([][2]string{{a, b}}, more...), true
+		}
+	}
+	return nil, false
+}
+
+// pairGreedy pairs names in order of placing, each with the highest
+// placed player below not yet met, or the next one if all have been.
+func (t *Tournament) pairGreedy(names []string) [][2]string {
+	var pairs [][2]string
+	paired := make(map[string]bool)
+	for i, a := range names {
+		if paired[a] {
+			continue
+		}
+		opp := ""
+		for _, b := range names[i+1:] {
+			if paired[b] {
+				continue
+			}
+			if opp == "" {
+				opp = b // fall back to a rematch if nobody new is left
+			}
+			if !t.hasPlayed(a, b) {
+				opp = b
+				break
+			}
+		}
+		paired[a], paired[opp] = true, true
+		pairs = append(pairs, [2]string{a, opp})
+	}
+	return pairs
+}
+
+func (t *Tournament) RecordResult(round, board int, winner Stone) error {
+	return t.recordResult(round, board, winner, false)
+}
+
+// AdjudicateResult records a result an adjudicator gave.
+func (t *Tournament) AdjudicateResult(round, board int, winner Stone) error {
+	return t.recordResult(round, board, winner, true)
+}
+
+func (t *Tournament) recordResult(round, board int, winner Stone, adjudicated bool) error {
+	for i := range t.Games {
+		g := &t.Games[i]
+		if g.Round == round && g.Board == board && !g.IsBye() {
+			g.Winner = winner
+			g.Played = true
+			g.Adjudicated = adjudicated
+			return nil
+		}
+	}
+	return fmt.Errorf("no game on board %d in round %d", board, round)
+}
+
+func (t *Tournament) WriteStandings(w io.Writer) {
+	fmt.Fprintf(w, "%-4s %-24s %-4s %6s %6s %6s\n", "Pl", "Name", "Rank", "Score", "SOS", "SODOS")
+	for i, name := range t.Standings() {
+		sos, sodos := t.SOS(name)
+		fmt.Fprintf(w, "%-4d %-24s %-4s %6.1f %6.1f %6.1f\n",
+			i+1, name, t.player(name).Rank, t.score(name), sos, sodos)
+	}
+}
+
+// WriteEGD writes the results in the European Go Database h-file layout:
+// one line per player with their per-round results as
+// <opponent place><+|-|=><colour>, and "0+" for a bye. The format has no
+// way to show a game still being played, so every game must be finished.
+func (t *Tournament) WriteEGD(w io.Writer) error {
+	for _, g := range t.Games {
+		if !g.Played {
+			return fmt.Errorf("round %d still has unfinished games", g.Round)
+		}
+	}
+	standings := t.Standings()
+	place := make(map[string]int)
+	for i, name := range standings {
+		place[name] = i + 1
+	}
+	fmt.Fprintf(w, "; EV[%s]\n", t.Name)
+	fmt.Fprintf(w, "; KM[%g]\n", t.Komi)
+	fmt.Fprintln(w, "; Pl Name                     Rk Co Club MMS  SOS SODOS")
+	for _, name := range standings {
+		p := t.player(name)
+		sos, sodos := t.SOS(name)
+		fmt.Fprintf(w, "%4d %-24s %3s %-2s %-4s %3g %4g %5g",
+			place[name], p.Name, p.Rank, p.Country, p.Club, t.score(name), sos, sodos)
+		for round := 1; round <= t.currentRound(); round++ {
+			fmt.Fprint(w, " ", egdRoundResult(t.gamesOf(name), round, name, place))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func egdRoundResult(games []*TournamentGame, round int, name string, place map[string]int) string {
+	for _, g := range games {
+		if g.Round != round {
+			continue
+		}
+		if g.IsBye() {
+			return "0+"
+		}
+		sign := "-"
+		switch s := g.score(name); {
+		case s == 1:
+			sign = "+"
+		case s == 0.5:
+			sign = "="
+		}
+		colour := "b"
+		if g.White == name {
+			colour = "w"
+		}
+		return fmt.Sprintf("%d%s%s", place[g.opponent(name)], sign, colour)
+	}
+	return "0-"
+}
+
+func runTournament(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: tournament new|add|pair|result|standings|egd [flags]")
+	}
+	fs := flag.NewFlagSet("tournament "+args[0], flag.ExitOnError)
+	file := fs.String("file", "tournament.json", "tournament state file")
+
+	t := &Tournament{}
+	load := func() error {
+		if err := loadJSON(*file, t); err != nil {
+			return err
+		}
+		if t.Name == "" {
+			return fmt.Errorf("%s: no tournament, run 'tournament new' first", *file)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "new":
+		name := fs.String("name", "Tournament", "tournament name")
+		system := fs.String("system", "mcmahon", "pairing system: swiss or mcmahon")
+		bar := fs.String("bar", "1d", "McMahon bar")
+		floor := fs.String("floor", "20k", "McMahon floor")
+		rounds := fs.Int("rounds", 5, "number of rounds")
+		komi := fs.Float64("komi", 6.5, "komi")
+		fs.Parse(args[1:])
+		if *system != "swiss" && *system != "mcmahon" {
+			return fmt.Errorf("unknown pairing system %q", *system)
+		}
+		barRank, err := ParseRank(*bar)
+		if err != nil {
+			return err
+		}
+		floorRank, err := ParseRank(*floor)
+		if err != nil {
+			return err
+		}
+		t = &Tournament{Name: *name, System: *system, Bar: barRank, Floor: floorRank, Rounds: *rounds, Komi: *komi}
+		return saveJSON(*file, t)
+	case "add":
+		name := fs.String("name", "", "player name")
+		rank := fs.String("rank", "", "player rank, e.g. 5k or 2d")
+		club := fs.String("club", "", "club")
+		country := fs.String("country", "", "country code")
+		fs.Parse(args[1:])
+		if err := load(); err != nil {
+			return err
+		}
+		if *name == "" {
+			return errors.New("player name is required")
+		}
+		if t.player(*name) != nil {
+			return fmt.Errorf("player %q is already registered", *name)
+		}
+		r, err := ParseRank(*rank)
+		if err != nil {
+			return err
+		}
+		t.Players = append(t.Players, TournamentPlayer{Name: *name, Rank: r, Club: *club, Country: strings.ToUpper(*country)})
+		return saveJSON(*file, t)
+	case "pair":
+		fs.Parse(args[1:])
+		if err := load(); err != nil {
+			return err
+		}
+		if err := t.PairRound(); err != nil {
+			return err
+		}
+		round := t.currentRound()
+		fmt.Printf("Round %d\n", round)
+		for _, g := range t.Games {
+			if g.Round != round {
+				continue
+			}
+			if g.IsBye() {
+				fmt.Printf("  bye: %s\n", g.White)
+			} else {
+				fmt.Printf("  %2d: %s (W) - %s (B)\n", g.Board, g.White, g.Black)
+			}
+		}
+		return saveJSON(*file, t)
+	case "result":
+		round := fs.Int("round", 0, "round number (default: current round)")
+		board := fs.Int("board", 0, "board number")
+		winner := fs.String("winner", "", "black, white or jigo")
+		adjudicate := fs.String("adjudicate", "", "instead of -winner, adjudicate the unfinished game in this SGF file, recording the result in it too")
+		fs.Parse(args[1:])
+		if err := load(); err != nil {
+			return err
+		}
+		if *round == 0 {
+			*round = t.currentRound()
+		}
+		if *adjudicate != "" {
+			games, err := ReadSGFFile(*adjudicate)
+			if err != nil {
+				return err
+			}
+			if len(games) != 1 {
+				return fmt.Errorf("%s: want one game, found %d", *adjudicate, len(games))
+			}
+			a := NewAdjudicator(NewEngine(2000, t.Komi), nil)
+			verdict, err := adjudicateTree(context.Background(), a, games[0])
+			if err != nil {
+				return fmt.Errorf("%s: %v", *adjudicate, err)
+			}
+			fmt.Println(verdict.Note())
+			if verdict.Winner == Empty {
+				return errors.New("the game is undecided; record its result with -winner")
+			}
+			if err := t.AdjudicateResult(*round, *board, verdict.Winner); err != nil {
+				return err
+			}
+			if err := WriteSGFFile(*adjudicate, games); err != nil {
+				return err
+			}
+			return saveJSON(*file, t)
+		}
+		var w Stone
+		switch strings.ToLower(*winner) {
+		case "black", "b":
+			w = Black
+		case "white", "w":
+			w = White
+		case "jigo", "draw":
+			w = Empty
+		default:
+			return fmt.Errorf("invalid winner %q", *winner)
+		}
+		if err := t.RecordResult(*round, *board, w); err != nil {
+			return err
+		}
+		return saveJSON(*file, t)
+	case "standings":
+		fs.Parse(args[1:])
+		if err := load(); err != nil {
+			return err
+		}
+		t.WriteStandings(os.Stdout)
+		return nil
+	case "egd":
+		out := fs.String("o", "", "output file (default stdout)")
+		fs.Parse(args[1:])
+		if err := load(); err != nil {
+			return err
+		}
+		if *out == "" {
+			return t.WriteEGD(os.Stdout)
+		}
+		var buf strings.Builder
+		if err := t.WriteEGD(&buf); err != nil {
+			return err
+		}
+		return saveFile(*out, []byte(buf.String()))
+	}
+	return fmt.Errorf("unknown tournament command %q", args[0])
+}
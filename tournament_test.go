@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func testTournament(t *testing.T, system string, ranks ...string) *Tournament {
+	t.Helper()
+	bar, _ := ParseRank("1d")
+	floor, _ := ParseRank("20k")
+	tour := &Tournament{Name: "Test", System: system, Bar: bar, Floor: floor, Komi: 6.5}
+	for i, r := range ranks {
+		rank, err := ParseRank(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tour.Players = append(tour.Players, TournamentPlayer{Name: string(rune('A' + i)), Rank: rank})
+	}
+	return tour
+}
+
+// pairings lists a round as "white-black" boards and "bye:name".
+func pairings(tour *Tournament, round int) []string {
+	var out []string
+	for _, g := range tour.Games {
+		switch {
+		case g.Round != round:
+		case g.IsBye():
+			out = append(out, "bye:"+g.White)
+		default:
+			out = append(out, g.White+"-"+g.Black)
+		}
+	}
+	return out
+}
+
+func TestPairRoundSwiss(t *testing.T) {
+	tour := testTournament(t, "swiss", "3d", "1d", "1k", "5k", "10k")
+	for _, round := range []struct {
+		want    string
+		results map[int]Stone // winner by board
+	}{
+		// The lowest placed sits out, the stronger player takes white.
+		{"bye:E A-B C-D", map[int]Stone{1: White, 2: Black}},
+		// The next bye goes to the lowest placed without one; A has
+		// had white, so gets black.
+		{"bye:C D-A B-E", map[int]Stone{1: Black, 2: White}},
+		// A has met B, and meeting C would leave B and E to replay, so
+		// A meets E, who takes white, not having had it, and B meets C.
+		{"bye:D E-A B-C", nil},
+	} {
+		if err := tour.PairRound(); err != nil {
+			t.Fatal(err)
+		}
+		r := tour.currentRound()
+		if got := strings.Join(pairings(tour, r), " "); got != round.want {
+			t.Errorf("round %d: %s, want %s", r, got, round.want)
+		}
+		if r == 3 {
+			if err := tour.PairRound(); err == nil {
+				t.Error("paired round 4 with round 3 unfinished")
+			}
+			break
+		}
+		for board, winner := range round.results {
+			if err := tour.RecordResult(r, board, winner); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if r == 2 {
+			// Scores and tie-breaks after two rounds.
+			for _, p := range []struct {
+				name             string
+				score, sos, sodo float64
+			}{
+				{"A", 2, 2, 2},
+				{"B", 1, 3, 1},
+				{"C", 1, 1, 0},
+				{"D", 1, 3, 1},
+				{"E", 1, 1, 0},
+			} {
+				score, _ := tour.Score(p.name)
+				sos, sodos := tour.SOS(p.name)
+				if score != p.score || sos != p.sos || sodos != p.sodo {
+					t.Errorf("%s: score %g, SOS %g, SODOS %g", p.name, score, sos, sodos)
+				}
+			}
+			if got := strings.Join(tour.Standings(), ""); got != "ABDCE" {
+				t.Errorf("standings %s", got)
+			}
+		}
+	}
+	if _, err := tour.Score("Z"); err == nil {
+		t.Error("scored a player not in the tournament")
+	}
+
+	// Two players can only meet again.
+	tour = testTournament(t, "swiss", "1d", "1k")
+	tour.PairRound()
+	tour.RecordResult(1, 1, White)
+	if err := tour.PairRound(); err != nil || len(pairings(tour, 2)) != 1 {
+		t.Errorf("round 2 of two players: %v, %v", pairings(tour, 2), err)
+	}
+}
+
+func TestMcMahonScores(t *testing.T) {
+	tour := testTournament(t, "mcmahon", "3d", "1d", "5k", "20k", "25k")
+	for name, want := range map[string]float64{"A": 20, "B": 20, "C": 15, "D": 0, "E": 0} {
+		if got, err := tour.Score(name); err != nil || got != want {
+			t.Errorf("%s starts on %g, %v; want %g", name, got, err, want)
+		}
+	}
+	if _, err := tour.Score("Z"); err == nil {
+		t.Error("scored a player not in the tournament")
+	}
+	// McMahon pairs by score, so the two above the bar meet first.
+	if err := tour.PairRound(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(pairings(tour, 1), " "); got != "bye:E A-B C-D" {
+		t.Errorf("round 1: %s", got)
+	}
+}
+
+func TestWriteEGD(t *testing.T) {
+	tour := testTournament(t, "swiss", "3d", "1d", "1k", "5k", "10k")
+	tour.PairRound()
+	var out strings.Builder
+	if err := tour.WriteEGD(&out); err == nil {
+		t.Error("wrote an h-file with games unfinished")
+	}
+	tour.RecordResult(1, 1, White) // A beats B
+	tour.RecordResult(1, 2, Empty) // C and D draw
+	tour.PairRound()
+	tour.RecordResult(2, 1, Black) // C beats A, rather than C and D replaying
+	tour.RecordResult(2, 2, White) // D beats E
+	out.Reset()
+	if err := tour.WriteEGD(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if lines[0] != "; EV[Test]" || lines[1] != "; KM[6.5]" || len(lines) != 3+5 {
+		t.Fatalf("h-file:\n%s", out.String())
+	}
+	// Place, name, then a result for each round.
+	want := map[string]string{
+		"C": "1 C 2=w 3+b",
+		"D": "2 D 1=b 4+w",
+		"A": "3 A 5+w 1-w",
+		"E": "4 E 0+ 2-b",
+		"B": "5 B 3-b 0+",
+	}
+	for _, line := range lines[3:] {
+		f := strings.Fields(line)
+		if got := strings.Join(append(f[:2:2], f[len(f)-2:]...), " "); got != want[f[1]] {
+			t.Errorf("%q, want %q", line, want[f[1]])
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Full-screen terminal interface (-tui). The screen is redrawn from
+// scratch on every change and again whenever the terminal is resized.
+
+const (
+	tuiSideWidth   = 22 // move list and clock pane beside the board
+	tuiMaxMessages = 3
+	tuiThumbSide   = 7 // thumbnail size in the move list
+	tuiAnimFrame   = 80 * time.Millisecond
+)
+
+type tuiView struct {
+	mu       sync.Mutex
+	out      *os.File
+	in       *bufio.Scanner
+	board    *Board
+	focus    int // last move played, kept in view when scrolling
+	moves    []RecordedMove
+	used     [3]time.Duration // thinking time per colour
+	turnFrom time.Time
+	messages []string
+	thinking string // progress of the computer's search
+	prompt   string
+	resize   chan os.Signal
+	// thumbEvery, if positive, puts a thumbnail of the position after
+	// every thumbEvery moves in the move list.
+	thumbEvery int
+	thumbs     map[int][]string // by number of moves played
+	// clock, in games with one, replaces the time used and is redrawn
+	// every second.
+	clock *Clock
+	done  chan struct{}
+	// scoreBar shows the estimated score and captures under the clock;
+	// typing "score" turns it on and off. komi goes into the estimate.
+	scoreBar bool
+	komi     float64
+	captures [][3]int // stones captured by each colour, by moves played
+	// numbers is the move numbers setting, see movenumbers.go.
+	numbers int
+	// animate flashes the stones a move captures and marks the new stone
+	// for a moment before the board settles; marks are the frame shown.
+	animate bool
+	marks   map[int]string
+}
+
+func newTUIView(in io.Reader, out *os.File) *tuiView {
+	t := &tuiView{out: out, in: bufio.NewScanner(in), focus: PassMove, resize: make(chan os.Signal, 1), done: make(chan struct{})}
+	notifyResize(t.resize)
+	go func() {
+		for range t.resize {
+			t.mu.Lock()
+			t.render()
+			t.mu.Unlock()
+		}
+	}()
+	return t
+}
+
+func (t *tuiView) Show(v BoardView) {
+	b := v.Copy()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.captures == nil {
+		t.captures = [][3]int{{}}
+	}
+	before := t.board
+	played := before != nil && len(t.moves) > 0 && len(t.captures) <= len(t.moves)
+	for len(t.captures) <= len(t.moves) {
+		c := t.captures[len(t.captures)-1]
+		if n := len(t.captures); n == len(t.moves) && t.board != nil {
+			mover := t.moves[n-1].Color
+			if mover == Black || mover == White {
+				c[mover] += max(t.board.stoneCount(mover.Opponent())-b.stoneCount(mover.Opponent()), 0)
+			}
+		}
+		t.captures = append(t.captures, c)
+	}
+	t.board = b
+	t.turnFrom = time.Now()
+	if n := len(t.moves); t.thumbEvery > 0 && n > 0 && n%t.thumbEvery == 0 {
+		if t.thumbs == nil {
+			t.thumbs = make(map[int][]string)
+		}
+		t.thumbs[n] = Thumbnail(b, tuiThumbSide)
+	}
+	if t.animate && played {
+		for _, marks := range moveFrames(before, b, t.moves[len(t.moves)-1].Move) {
+			t.marks = marks
+			t.render()
+			time.Sleep(tuiAnimFrame)
+		}
+		t.marks = nil
+	}
+	t.render()
+}
+
+// moveFrames are the frames of the animation of move from before to
+// after: the new stone marked, and the stones it captured flashing
+// between themselves and an x.
+func moveFrames(before, after *Board, move int) []map[int]string {
+	if move == PassMove || before.size != after.size {
+		return nil
+	}
+	n := after.size
+	placed := map[Stone]string{Black: "◉", White: "◎"}[after.grid[move/n][move%n]]
+	if placed == "" {
+		return nil
+	}
+	var captured []int
+	for p := 0; p < n*n; p++ {
+		if before.grid[p/n][p%n] != Empty && after.grid[p/n][p%n] == Empty {
+			captured = append(captured, p)
+		}
+	}
+	frames := make([]map[int]string, 2)
+	if len(captured) > 0 {
+		frames = make([]map[int]string, 4)
+	}
+	for i := range frames {
+		frames[i] = map[int]string{move: placed}
+		for _, p := range captured {
+			frames[i][p] = "×"
+			if i%2 == 0 {
+				frames[i][p] = before.grid[p/n][p%n].String()
+			}
+		}
+	}
+	return frames
+}
+
+func (t *tuiView) Played(color Stone, move int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if color == Black || color == White {
+		t.used[color] += time.Since(t.turnFrom)
+	}
+	t.moves = append(t.moves, RecordedMove{Color: color, Move: move})
+	if move != PassMove {
+		t.focus = move
+	}
+}
+
+func (t *tuiView) Message(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, strings.Split(text, "\n")...)
+	if len(t.messages) > tuiMaxMessages {
+		t.messages = t.messages[len(t.messages)-tuiMaxMessages:]
+	}
+	t.render()
+}
+
+func (t *tuiView) Invalid(text string) { t.Message(text) }
+
+func (t *tuiView) Result(winner Stone, margin float64) {
+	if winner == Empty {
+		t.Message("Result: jigo")
+	} else {
+		t.Message(fmt.Sprintf("Result: %s wins by %.1f", winner, margin))
+	}
+}
+
+func (t *tuiView) Thinking(p SearchProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.thinking = ""
+	if !p.Done {
+		t.thinking = thinkingText(p, t.board.size)
+	}
+	t.render()
+}
+
+func (t *tuiView) ReadMove(color Stone) (string, bool) {
+	for {
+		t.mu.Lock()
+		t.prompt = fmt.Sprintf("Enter move for %s: ", color)
+		t.render()
+		t.mu.Unlock()
+
+		ok := t.in.Scan()
+
+		t.mu.Lock()
+		t.prompt = ""
+		t.messages = nil
+		if ok && strings.TrimSpace(t.in.Text()) == "score" {
+			t.scoreBar = !t.scoreBar
+			t.mu.Unlock()
+			continue
+		}
+		if last, isNumbers, err := numbersCommand(t.in.Text()); ok && isNumbers {
+			if err != nil {
+				t.messages = []string{err.Error()}
+			} else {
+				t.numbers = last
+			}
+			t.mu.Unlock()
+			continue
+		}
+		t.mu.Unlock()
+		return t.in.Text(), ok
+	}
+}
+
+func (t *tuiView) Clocks(c *Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.clock == nil {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-t.done:
+					return
+				case <-ticker.C:
+					t.mu.Lock()
+					t.render()
+					t.mu.Unlock()
+				}
+			}
+		}()
+	}
+	t.clock = c
+	t.render()
+}
+
+func (t *tuiView) TimeWarning(color Stone, s ClockState) {
+	io.WriteString(t.out, "\a")
+	t.Message(timeWarningText(color, s))
+}
+
+func (t *tuiView) TimeUp(loser Stone) {
+	t.Message(fmt.Sprintf("Result: %s (%s ran out of time)", timeUpResult(loser), loser))
+}
+
+func (t *tuiView) Resigned(loser Stone) {
+	t.Message(fmt.Sprintf("Result: %s (%s resigned)", resignResult(loser), loser))
+}
+
+func (t *tuiView) Undone(moves int) {
+	t.moves = t.moves[:max(len(t.moves)-moves, 0)]
+	t.captures = t.captures[:min(len(t.moves)+1, len(t.captures))]
+	for n := range t.thumbs {
+		if n > len(t.moves) {
+			delete(t.thumbs, n)
+		}
+	}
+	t.Message(fmt.Sprintf("Took back %d move(s)", moves))
+}
+
+func (t *tuiView) Close() {
+	signal.Stop(t.resize)
+	close(t.resize)
+	close(t.done)
+	fmt.Fprintln(t.out)
+}
+
+func (t *tuiView) size() (int, int) {
+	return screenSize(t.out)
+}
+
+func (t *tuiView) render() {
+	width, height := t.size()
+	// Messages and the prompt go below everything; the clock and move
+	// list go beside the board if there is room, otherwise below it too.
+	// The side pane is only worth it if the board still fits unscrolled.
+	status := t.messages
+	if t.thinking != "" {
+		status = append(status[:len(status):len(status)], t.thinking)
+	}
+	reserved := len(status) + 1
+	beside := t.board != nil && width-3-tuiSideWidth >= 3+t.board.size
+	var board []string
+	var boardWidth int
+	if beside {
+		board, boardWidth = t.boardPane(width-3-tuiSideWidth, height-reserved)
+	} else {
+		below := 2
+		if t.scoreBar {
+			below++
+		}
+		board, boardWidth = t.boardPane(width, height-reserved-below)
+	}
+
+	var lines []string
+	if beside {
+		side := []string{t.clockLine()}
+		if t.scoreBar {
+			side = append(side, t.scoreLine(tuiSideWidth))
+		}
+		side = append(side, "", "Moves")
+		shown := max(len(board)-len(side), 1)
+		var list []string
+		for i := max(len(t.moves)-shown, 0); i < len(t.moves); i++ {
+			list = append(list, t.moveText(i))
+			for _, l := range t.thumbs[i+1] {
+				list = append(list, "    "+l)
+			}
+		}
+		side = append(side, list[max(len(list)-shown, 0):]...)
+		for i := 0; i < max(len(board), len(side)); i++ {
+			var l, s string
+			if i < len(board) {
+				l = board[i]
+			}
+			if i < len(side) {
+				s = side[i]
+			}
+			lines = append(lines, l+strings.Repeat(" ", boardWidth+3-displayWidth(l))+s)
+		}
+	} else {
+		lines = append(board, t.clockLine())
+		if t.scoreBar {
+			lines = append(lines, t.scoreLine(width))
+		}
+		recent := ""
+		for i := len(t.moves) - 1; i >= 0; i-- {
+			next := t.moveText(i) + "  " + recent
+			if utf8.RuneCountInString(next) > width {
+				break
+			}
+			recent = next
+		}
+		lines = append(lines, recent)
+	}
+	lines = append(lines, status...)
+	if len(lines) > height-1 {
+		lines = lines[len(lines)-(height-1):]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	for _, l := range lines {
+		sb.WriteString(truncateRunes(l, width))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(t.prompt)
+	io.WriteString(t.out, sb.String())
+}
+
+func (t *tuiView) boardPane(width, height int) ([]string, int) {
+	if t.board == nil {
+		return nil, 0
+	}
+	marks := numberMarks(moveNumbers(t.board, t.moves, t.numbers))
+	for p, m := range t.marks {
+		if marks == nil {
+			marks = make(map[int]string)
+		}
+		marks[p] = m
+	}
+	lines := markedBoardLines(t.board, width, height, t.focus, marks)
+	w := 0
+	for _, l := range lines {
+		w = max(w, displayWidth(l))
+	}
+	return lines, w
+}
+
+func (t *tuiView) clockLine() string {
+	if t.clock != nil {
+		now := time.Now()
+		return fmt.Sprintf("%s %s   %s %s", Black, t.clock.State(Black, now), White, t.clock.State(White, now))
+	}
+	used := t.used
+	if t.board != nil && (t.board.turn == Black || t.board.turn == White) {
+		used[t.board.turn] += time.Since(t.turnFrom)
+	}
+	return fmt.Sprintf("%s %s   %s %s", Black, clockText(used[Black]), White, clockText(used[White]))
+}
+
+func (t *tuiView) scoreLine(width int) string {
+	if t.board == nil {
+		return ""
+	}
+	var captures [3]int
+	if len(t.captures) > 0 {
+		captures = t.captures[len(t.captures)-1]
+	}
+	return scoreBarText(t.board, t.komi, captures, width)
+}
+
+func (t *tuiView) moveText(i int) string {
+	size := 0
+	if t.board != nil {
+		size = t.board.size
+	}
+	m := t.moves[i]
+	return fmt.Sprintf("%3d %s %s", i+1, m.Color, userCoords.Move(m.Move, size))
+}
+
+func clockText(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:max(n, 0)])
+}
+
+// boardLines renders b within width x height character cells. The board is
+// spaced out when it fits, condensed to one column per point when it does
+// not, and shown as a window scrolled to keep focus in view when even that
+// is too big. Arrows in the gutter mark columns scrolled out of view.
+func boardLines(b *Board, width, height, focus int) []string {
+	return markedBoardLines(b, width, height, focus, nil)
+}
+
+// markedBoardLines is boardLines with the points in marks drawn as the
+// given strings instead of their stones. A mark takes up to two
+// characters, the space before the point too, when the board is spaced
+// out, and its last character when it is condensed.
+func markedBoardLines(b *Board, width, height, focus int, marks map[int]string) []string {
+	n := b.size
+	point := func(r, c int) string {
+		if m, ok := marks[r*n+c]; ok {
+			_, last := utf8.DecodeLastRuneInString(m)
+			return m[len(m)-last:]
+		}
+		return b.grid[r][c].String()
+	}
+	spaced := func(r, c int) string {
+		if m, ok := marks[r*n+c]; ok {
+			return fmt.Sprintf("%2s", truncateRunes(m, 2))
+		}
+		return " " + b.grid[r][c].String()
+	}
+	colLabels, rowLabels, rowWidth := coordLabels(n)
+	if width >= rowWidth+2*n && height >= n+1 {
+		header := strings.Repeat(" ", rowWidth)
+		for c := 0; c < n; c++ {
+			header += fmt.Sprintf("%2s", colLabels[c])
+		}
+		lines := []string{header}
+		for r := 0; r < n; r++ {
+			line := rowLabels[r]
+			for c := 0; c < n; c++ {
+				line += spaced(r, c)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+
+	headers := 1
+	for _, l := range colLabels {
+		headers = max(headers, len(l))
+	}
+	cols, rows := n, min(max(height-headers, 1), n)
+	if width < rowWidth+1+n {
+		cols = max(width-rowWidth-2, 1)
+	}
+	focusRow, focusCol := n/2, n/2
+	if focus >= 0 {
+		focusRow, focusCol = focus/n, focus%n
+	}
+	c0 := scrollWindow(focusCol, cols, n)
+	r0 := scrollWindow(focusRow, rows, n)
+
+	// Column labels are written down the header lines, right-aligned.
+	var lines []string
+	for h := 0; h < headers; h++ {
+		line := []byte(strings.Repeat(" ", rowWidth+1))
+		for c := c0; c < c0+cols; c++ {
+			l := fmt.Sprintf("%*s", headers, colLabels[c])
+			line = append(line, l[h])
+		}
+		lines = append(lines, string(line))
+	}
+	for r := r0; r < r0+rows; r++ {
+		left := " "
+		if c0 > 0 {
+			left = "<"
+		}
+		var sb strings.Builder
+		sb.WriteString(rowLabels[r] + left)
+		for c := c0; c < c0+cols; c++ {
+			sb.WriteString(point(r, c))
+		}
+		if c0+cols < n {
+			sb.WriteString(">")
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// scrollWindow returns the first of visible consecutive indexes out of n,
+// centred on focus where possible.
+func scrollWindow(focus, visible, n int) int {
+	return max(0, min(focus-visible/2, n-visible))
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestMoveFrames(t *testing.T) {
+	b := NewBoard(9)
+	b.grid[0][0], b.grid[0][1] = White, Black
+	b.turn = Black
+	before := b.Copy()
+	if !b.Play(9) { // A8, taking the corner stone
+		t.Fatal("capture is illegal")
+	}
+	frames := moveFrames(before, b, 9)
+	if len(frames) != 4 {
+		t.Fatalf("%d frames, want 4 for a capture", len(frames))
+	}
+	for i, want := range []string{"○", "×", "○", "×"} {
+		if frames[i][0] != want || frames[i][9] != "◉" {
+			t.Errorf("frame %d: captured %q, new %q; want %q, ◉", i, frames[i][0], frames[i][9], want)
+		}
+	}
+
+	before = b.Copy()
+	b.Play(40)
+	if frames := moveFrames(before, b, 40); len(frames) != 2 || frames[0][40] != "◎" || len(frames[0]) != 1 {
+		t.Errorf("quiet move: %v", frames)
+	}
+	if frames := moveFrames(b, b, PassMove); frames != nil {
+		t.Errorf("pass: %v", frames)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// gameView is how playGame presents a game and reads the human's moves.
+type gameView interface {
+	Show(b BoardView)                    // before each move and at the end
+	Played(color Stone, move int)        // after every move, PassMove for a pass
+	Message(text string)                 // status lines
+	Invalid(text string)                 // rejected input
+	Result(winner Stone, margin float64) // at the end of a finished game
+	Thinking(p SearchProgress)           // while the computer searches
+	ReadMove(color Stone) (string, bool) // next input line; false at end of input
+	Clocks(c *Clock)                     // before each move, in games with a clock
+	TimeWarning(color Stone, s ClockState)
+	TimeUp(loser Stone)   // instead of Result when a player runs out of time
+	Resigned(loser Stone) // instead of Result when a player resigns
+	Undone(moves int)     // after moves were taken back, before Show
+	Close()
+}
+
+// consoleView is the plain line-oriented interface.
+type consoleView struct {
+	in   *bufio.Scanner
+	size int
+	// progress is shown on one overwritten line, only on a terminal.
+	progress bool
+	// numbers is the move numbers setting, see movenumbers.go; moves are
+	// kept for it.
+	numbers int
+	moves   []RecordedMove
+	board   *Board
+}
+
+func newConsoleView(in io.Reader) *consoleView {
+	return &consoleView{in: bufio.NewScanner(in), progress: isTerminal(os.Stdout)}
+}
+
+func (c *consoleView) Show(v BoardView) {
+	b := v.Copy()
+	c.size = b.size
+	c.board = b
+	marks := numberMarks(moveNumbers(b, c.moves, c.numbers))
+	if width, _ := screenSize(os.Stdout); c.progress && 2+2*b.size > width {
+		fmt.Println()
+		for _, line := range boardPages(b, width, marks) {
+			fmt.Println(line)
+		}
+		fmt.Printf("\nCurrent turn: %s\n", b.turn)
+		return
+	}
+	if marks != nil || userCoords != IndexCoords {
+		fmt.Println()
+		for _, line := range markedBoardLines(b, 8+2*b.size, b.size+1, PassMove, marks) {
+			fmt.Println(line)
+		}
+		fmt.Printf("\nCurrent turn: %s\n", b.turn)
+		return
+	}
+	b.Display()
+}
+
+// showBoard prints b as a console game shows it, in the user's
+// coordinates.
+func showBoard(b *Board) {
+	(&consoleView{progress: isTerminal(os.Stdout)}).Show(b)
+}
+
+// boardPages lays a board too wide for the terminal out as strips of
+// columns, one below the other, each with its own column numbers. Points
+// in marks are drawn as in markedBoardLines.
+func boardPages(b *Board, width int, marks map[int]string) []string {
+	colLabels, rowLabels, rowWidth := coordLabels(b.size)
+	perPage := max((width-rowWidth)/2, 1)
+	var lines []string
+	for c0 := 0; c0 < b.size; c0 += perPage {
+		c1 := min(c0+perPage, b.size)
+		if c0 > 0 {
+			lines = append(lines, "")
+		}
+		header := strings.Repeat(" ", rowWidth)
+		for c := c0; c < c1; c++ {
+			header += fmt.Sprintf("%2s", colLabels[c])
+		}
+		lines = append(lines, header)
+		for r := 0; r < b.size; r++ {
+			line := rowLabels[r]
+			for c := c0; c < c1; c++ {
+				if m, ok := marks[r*b.size+c]; ok {
+					line += fmt.Sprintf("%2s", truncateRunes(m, 2))
+				} else {
+					line += " " + b.grid[r][c].String()
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (c *consoleView) Played(color Stone, move int) {
+	c.moves = append(c.moves, RecordedMove{Color: color, Move: move})
+}
+
+func (c *consoleView) Clocks(clock *Clock) {
+	now := time.Now()
+	fmt.Printf("Clock: %s %s   %s %s\n", Black, clock.State(Black, now), White, clock.State(White, now))
+}
+
+// TimeWarning interrupts the prompt, ringing the terminal bell.
+func (c *consoleView) TimeWarning(color Stone, s ClockState) {
+	fmt.Printf("\a\n%s\n", timeWarningText(color, s))
+}
+
+func (c *consoleView) TimeUp(loser Stone) {
+	fmt.Printf("Result: %s (%s ran out of time)\n", timeUpResult(loser), loser)
+}
+
+func (c *consoleView) Resigned(loser Stone) {
+	fmt.Printf("Result: %s (%s resigned)\n", resignResult(loser), loser)
+}
+
+func (c *consoleView) Undone(moves int) {
+	c.moves = c.moves[:max(len(c.moves)-moves, 0)]
+	fmt.Printf("Took back %d move(s)\n", moves)
+}
+
+// timeWarningText is the warning when a byo-yomi period is running out.
+func timeWarningText(color Stone, s ClockState) string {
+	secs := int((s.PeriodLeft + time.Second - 1) / time.Second)
+	periods := fmt.Sprintf("%d periods", s.Periods)
+	if s.Periods == 1 {
+		periods = "last period"
+	}
+	return fmt.Sprintf("%s: %d seconds left (%s)", color, secs, periods)
+}
+
+// timeUpResult is the SGF result of a loss on time.
+func timeUpResult(loser Stone) string {
+	if loser == Black {
+		return "W+T"
+	}
+	return "B+T"
+}
+
+// resignResult is the SGF result of a resignation.
+func resignResult(loser Stone) string {
+	if loser == Black {
+		return "W+R"
+	}
+	return "B+R"
+}
+
+func (c *consoleView) Message(text string) { fmt.Println(text) }
+
+func (c *consoleView) Invalid(text string) { fmt.Println(text) }
+
+func (c *consoleView) Result(winner Stone, margin float64) {
+	if winner == Empty {
+		fmt.Println("Result: jigo")
+	} else {
+		fmt.Printf("Result: %s wins by %.1f\n", winner, margin)
+	}
+}
+
+func (c *consoleView) Thinking(p SearchProgress) {
+	if !c.progress {
+		return
+	}
+	line := ""
+	if !p.Done {
+		line = thinkingText(p, c.size)
+	}
+	fmt.Printf("\r%-60s\r", line)
+}
+
+// thinkingText is a one-line progress report with a spinner.
+func thinkingText(p SearchProgress, size int) string {
+	spinner := `|/-\`
+	s := fmt.Sprintf("%c thinking %3d%%  %d playouts  %.1fs  %.1f MB", spinner[int(p.Elapsed/(100*time.Millisecond))%len(spinner)],
+		100*p.Playouts/max(p.Total, 1), p.Playouts, p.Elapsed.Seconds(), float64(p.Memory)/(1<<20))
+	if p.Playouts > 0 {
+		s += fmt.Sprintf("  best %s (%.0f%%)", userCoords.Move(p.Best, size), 100*p.WinRate)
+	}
+	return strings.TrimRight(s, " ")
+}
+
+func (c *consoleView) ReadMove(color Stone) (string, bool) {
+	for {
+		fmt.Printf("Enter move for %s: ", color)
+		if !c.in.Scan() {
+			return "", false
+		}
+		last, ok, err := numbersCommand(c.in.Text())
+		switch {
+		case !ok:
+			return c.in.Text(), true
+		case err != nil:
+			fmt.Println(err)
+		case c.board != nil:
+			c.numbers = last
+			c.Show(c.board)
+		}
+	}
+}
+
+func (c *consoleView) Close() {}
@@ -0,0 +1,25 @@
+#!/bin/sh
+# Builds gogame.wasm and copies Go's wasm_exec.js loader next to it.
+#
+# The binding is compiled together with the rules and engine files of the
+# main program, which therefore must not depend on the terminal, the file
+# system or any other file of the program.
+set -e
+cd "$(dirname "$0")"
+core="board.go legal.go scoring.go engine.go eval.go arena.go zobrist.go events.go regions.go diversity.go tablebase.go scoredist.go explain.go playout.go splittree.go"
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+for f in $core; do
+	cp "../$f" "$tmp/"
+done
+cp main.go "$tmp/wasm.go"
+GOOS=js GOARCH=wasm go build -o gogame.wasm "$tmp"/*.go
+
+root=$(go env GOROOT)
+if [ -f "$root/lib/wasm/wasm_exec.js" ]; then
+	cp "$root/lib/wasm/wasm_exec.js" .
+else
+	cp "$root/misc/wasm/wasm_exec.js" .
+fi
+echo "wrote wasm/gogame.wasm and wasm/wasm_exec.js"
@@ -0,0 +1,159 @@
+package main
+
+// JavaScript binding of the rules and engine, for running the engine in a
+// browser with no server. build.sh compiles this file together with the
+// core files of the main program.
+//
+// The binding sets a global gogame object:
+//
+//	gogame.newGame(size, komi, playouts)  start an empty board; false if the size is invalid
+//	gogame.play(row, col)                 play for the side to move; false if illegal
+//	gogame.explain(row, col)              {verdict, text, captures, ataris, group, liberties} for the side to move
+//	gogame.pass()                         pass for the side to move
+//	gogame.genMove()                      Promise of {row, col, pass}; does not play it
+//	gogame.score()                        Promise of Black's estimated lead, komi included
+//	gogame.ownership()                    Promise of rows of -1 (White's) to +1 (Black's) per point
+//	gogame.board()                        rows of 0 (empty), 1 (black) or 2 (white)
+//	gogame.turn()                         1 or 2
+//	gogame.gameOver()                     true after two passes
+//
+// Searches run on their own goroutine so the page stays responsive.
+
+import (
+	"context"
+	"syscall/js"
+)
+
+var (
+	board  = NewBoard(9)
+	engine = NewEngine(1000, 6.5)
+)
+
+func main() {
+	api := map[string]any{
+		"newGame": js.FuncOf(func(this js.Value, args []js.Value) any {
+			size, komi, playouts := 9, 6.5, 1000
+			if len(args) > 0 {
+				size = args[0].Int()
+			}
+			if len(args) > 1 {
+				komi = args[1].Float()
+			}
+			if len(args) > 2 {
+				playouts = args[2].Int()
+			}
+			if size < 2 || size > MaxBoardSize {
+				return false
+			}
+			board, engine = NewBoard(size), NewEngine(playouts, komi)
+			return true
+		}),
+		"play": js.FuncOf(func(this js.Value, args []js.Value) any {
+			if len(args) < 2 {
+				return false
+			}
+			return board.PlaceStone(args[0].Int(), args[1].Int())
+		}),
+		"explain": js.FuncOf(func(this js.Value, args []js.Value) any {
+			if len(args) < 2 {
+				return nil
+			}
+			x := board.ExplainMove(args[0].Int(), args[1].Int(), board.Turn())
+			groups := func(gs [][]int) []any {
+				list := make([]any, len(gs))
+				for i, g := range gs {
+					list[i] = points(g)
+				}
+				return list
+			}
+			return map[string]any{
+				"verdict":   string(x.Verdict),
+				"text":      x.Text(),
+				"captures":  groups(x.Captures),
+				"ataris":    groups(x.Ataris),
+				"group":     points(x.Group),
+				"liberties": x.Liberties,
+			}
+		}),
+		"pass": js.FuncOf(func(this js.Value, args []js.Value) any {
+			board.Pass()
+			return nil
+		}),
+		"genMove": js.FuncOf(func(this js.Value, args []js.Value) any {
+			b, e := board.Copy(), engine
+			return promise(func() (any, error) {
+				row, col, pass, err := e.GenMove(context.Background(), b)
+				return map[string]any{"row": row, "col": col, "pass": pass}, err
+			})
+		}),
+		"score": js.FuncOf(func(this js.Value, args []js.Value) any {
+			b, e := board.Copy(), engine
+			return promise(func() (any, error) {
+				return e.Score(context.Background(), b)
+			})
+		}),
+		"ownership": js.FuncOf(func(this js.Value, args []js.Value) any {
+			b, e := board.Copy(), engine
+			return promise(func() (any, error) {
+				own, err := e.Ownership(context.Background(), b)
+				rows := make([]any, b.Size())
+				for r := range rows {
+					row := make([]any, b.Size())
+					for c := range row {
+						row[c] = own[r*b.Size()+c]
+					}
+					rows[r] = row
+				}
+				return rows, err
+			})
+		}),
+		"board": js.FuncOf(func(this js.Value, args []js.Value) any {
+			rows := make([]any, board.Size())
+			for r := range rows {
+				row := make([]any, board.Size())
+				for c := range row {
+					row[c] = int(board.At(r, c))
+				}
+				rows[r] = row
+			}
+			return rows
+		}),
+		"turn": js.FuncOf(func(this js.Value, args []js.Value) any {
+			return int(board.Turn())
+		}),
+		"gameOver": js.FuncOf(func(this js.Value, args []js.Value) any {
+			return board.IsGameOver()
+		}),
+	}
+	js.Global().Set("gogame", api)
+	select {}
+}
+
+// promise runs f on a new goroutine and returns a JavaScript Promise of
+// its result.
+func promise(f func() (any, error)) js.Value {
+	executor := js.FuncOf(func(this js.Value, args []js.Value) any {
+		resolve, reject := args[0], args[1]
+		go func() {
+			v, err := f()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	p := js.Global().Get("Promise").New(executor)
+	executor.Release()
+	return p
+}
+
+// points lists points as [row, col] pairs.
+func points(ps []int) []any {
+	list := make([]any, len(ps))
+	for i, p := range ps {
+		list[i] = []any{p / board.Size(), p % board.Size()}
+	}
+	return list
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Webhooks tell players in slow games, who are not sitting at connect,
+// what happens in them. serve -webhook URL posts a JSON object to URL when
+// a player is to move ("turn"), a game ends ("end") and a player runs out
+// of time ("timeout", instead of "end"); -webhook-events picks which. The
+// object carries a line of text as both "text" and "content", so it can
+// go straight to a Slack or a Discord incoming webhook, and anything else,
+// such as a mail gateway, can read the fields:
+//
+//	{"event": "turn", "game": "alice vs bob", "color": "white", "player": "bob", "number": 12,
+//	 "text": "alice vs bob: bob to play move 12", "content": "..."}
+
+var webhookEventNames = []string{"turn", "end", "timeout"}
+
+type webhookEvent struct {
+	Event   string `json:"event"`
+	Game    string `json:"game"`
+	Color   string `json:"color,omitempty"`
+	Player  string `json:"player,omitempty"`
+	Number  int    `json:"number,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Text    string `json:"text"`
+	Content string `json:"content"`
+}
+
+// webhooks are the URLs a server posts events to. A nil *webhooks posts
+// nothing.
+type webhooks struct {
+	urls    []string
+	events  map[string]bool
+	posting sync.WaitGroup
+}
+
+// newWebhooks reads the comma-separated -webhook and -webhook-events
+// lists; it returns nil if there are no URLs.
+func newWebhooks(urls, events string) (*webhooks, error) {
+	if urls == "" {
+		return nil, nil
+	}
+	w := &webhooks{events: map[string]bool{}}
+	for _, u := range strings.Split(urls, ",") {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("webhook %q: not an http or https URL", u)
+		}
+		w.urls = append(w.urls, u)
+	}
+	for _, e := range strings.Split(events, ",") {
+		known := false
+		for _, name := range webhookEventNames {
+			known = known || e == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown webhook event %q: use %s", e, strings.Join(webhookEventNames, ", "))
+		}
+		w.events[e] = true
+	}
+	return w, nil
+}
+
+// notify posts e to every URL, if its event was asked for, without
+// waiting for the posts.
+func (w *webhooks) notify(e webhookEvent) {
+	if w == nil || !w.events[e.Event] {
+		return
+	}
+	e.Content = e.Text
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	for _, u := range w.urls {
+		w.posting.Add(1)
+		go func(u string) {
+			defer w.posting.Done()
+			if err := postJSON(u, body); err != nil {
+				fmt.Printf("webhook: %v\n", err)
+			}
+		}(u)
+	}
+}
+
+// wait waits for the posts in flight, so that the last events of a
+// server are delivered before it exits.
+func (w *webhooks) wait() {
+	if w != nil {
+		w.posting.Wait()
+	}
+}
+
+func postJSON(u string, body []byte) error {
+	resp, err := httpClient.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", u, resp.Status)
+	}
+	return nil
+}
+
+// hook posts a webhook event about g: whose turn it is, for "turn"; who
+// ran out of time, for "timeout"; and the result, for "end" and
+// "timeout".
+func (g *netGame) hook(event string, color Stone, result string) {
+	if g.hooks == nil {
+		return
+	}
+	e := webhookEvent{Event: event, Game: g.title, Result: result}
+	if color != Empty {
+		e.Color, e.Player = colorKey(color), g.player(color)
+	}
+	switch event {
+	case "turn":
+		e.Number = g.game.Moves() + 1
+		e.Text = fmt.Sprintf("%s: %s to play move %d", g.title, e.Player, e.Number)
+	case "timeout":
+		e.Text = fmt.Sprintf("%s: %s ran out of time, %s", g.title, e.Player, result)
+	default:
+		e.Text = fmt.Sprintf("%s: %s", g.title, result)
+	}
+	g.hooks.notify(e)
+}
+
+// player is the name of color's player, or the colour if it has none.
+func (g *netGame) player(color Stone) string {
+	if g.names[color] == "" {
+		return colorKey(color)
+	}
+	return g.names[color]
+}
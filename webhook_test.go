@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhooks(t *testing.T) {
+	for _, bad := range [][2]string{{"ftp://example.net", "end"}, {"http://example.net", "won"}} {
+		if _, err := newWebhooks(bad[0], bad[1]); err == nil {
+			t.Errorf("newWebhooks(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+
+	posted := make(chan webhookEvent, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhookEvent
+		json.NewDecoder(r.Body).Decode(&e)
+		posted <- e
+	}))
+	defer srv.Close()
+	hooks, err := newWebhooks(srv.URL, "turn,timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := newNetGame(9, 6.5, 0)
+	g.hooks, g.title = hooks, "alice vs bob"
+	g.names[Black], g.names[White] = "alice", "bob"
+	g.hook("turn", Black, "")
+	g.hook("end", Empty, "B+R") // not asked for
+	g.hook("timeout", White, "B+T")
+	hooks.wait()
+	close(posted)
+
+	var got []webhookEvent
+	for e := range posted {
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("posted %+v", got)
+	}
+	if got[0].Event == "timeout" {
+		got[0], got[1] = got[1], got[0]
+	}
+	if e := got[0]; e.Player != "alice" || e.Number != 1 || e.Text != "alice vs bob: alice to play move 1" || e.Content != e.Text {
+		t.Errorf("turn = %+v", e)
+	}
+	if e := got[1]; e.Player != "bob" || e.Result != "B+T" {
+		t.Errorf("timeout = %+v", e)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket carries the network protocol for clients that cannot open a
+// TCP connection, such as a page in a browser or an app behind a proxy
+// that only passes HTTP: serve -ws speaks it instead of plain TCP, and
+// connect -ws to such a server. Every line goes as one text message and
+// every message is read as one line; nothing else about the protocol
+// changes. Only the parts of RFC 6455 the protocol needs are here: the
+// opening handshake, masking, fragmented messages, ping and close.
+
+const (
+	wsGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxMessage = 64 << 10 // longer than any line of the protocol
+)
+
+// WebSocket frame opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsListener serves the WebSocket handshake over HTTP on a listener and
+// hands out the connections that complete it, so that a server accepts
+// them as it would TCP connections.
+type wsListener struct {
+	net.Listener
+	conns chan net.Conn
+	done  chan struct{} // closed when the listener stops
+	err   error
+}
+
+func newWSListener(ln net.Listener) *wsListener {
+	l := &wsListener{Listener: ln, conns: make(chan net.Conn), done: make(chan struct{})}
+	go func() {
+		l.err = http.Serve(ln, l)
+		close(l.done)
+	}()
+	return l
+}
+
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// wsAcceptKey is the Sec-WebSocket-Accept for a Sec-WebSocket-Key.
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether the comma-separated header name of h has
+// token, ignoring case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeHTTP upgrades a request to a WebSocket connection.
+func (l *wsListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "this server speaks WebSocket", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+	select {
+	case l.conns <- &wsConn{Conn: conn, r: rw.Reader}:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// dialWS opens a WebSocket connection over conn, to host.
+func dialWS(conn net.Conn, host string) (net.Conn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", host, key)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("WebSocket handshake: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, errors.New("WebSocket handshake: wrong Sec-WebSocket-Accept")
+	}
+	return &wsConn{Conn: conn, r: r, client: true}, nil
+}
+
+// wsConn is a WebSocket connection read and written as lines.
+type wsConn struct {
+	net.Conn
+	r      *bufio.Reader
+	client bool // the client's end, which masks what it writes
+
+	rmu     sync.Mutex // guards r and pending
+	pending []byte     // the rest of the message being read
+	wmu     sync.Mutex
+}
+
+// Read reads the messages as lines.
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	for len(c.pending) == 0 {
+		msg, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = append(msg, '\n')
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write sends each line of p as a message.
+func (c *wsConn) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := c.writeFrame(wsText, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close says goodbye before closing the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(wsClose, []byte{0x03, 0xe8}) // 1000, a normal closure
+	return c.Conn.Close()
+}
+
+// readMessage reads the next data message, answering pings and closes on
+// the way.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			c.writeFrame(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, payload[:min(2, len(payload))])
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if started {
+				return nil, errors.New("websocket: new message inside a fragmented one")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, errors.New("websocket: continuation with no message")
+			}
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+		if len(msg)+len(payload) > wsMaxMessage {
+			return nil, errors.New("websocket: message too long")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0f
+	if masked := head[1]&0x80 != 0; masked == c.client {
+		return false, 0, nil, errors.New("websocket: frames from clients are masked, and only those")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxMessage {
+		return false, 0, nil, errors.New("websocket: frame too long")
+	}
+	var mask [4]byte
+	if !c.client {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if !c.client {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// writeFrame sends one whole frame, masked from the client.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := []byte{0x80 | op}
+	bit := byte(0)
+	if c.client {
+		bit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, bit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, bit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, bit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
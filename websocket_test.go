@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNetGameOverWebSocket(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := newWSListener(tcp)
+	defer ln.Close()
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("plain GET: %s", resp.Status)
+	}
+
+	dial := func() *netTestClient {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws, err := dialWS(conn, ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ws.Close() })
+		return newNetTestClient(ws)
+	}
+	g := newNetGame(5, 0.5, 20*time.Millisecond)
+	g.clock = NewClock(time.Minute, 0, 0)
+	clients := [3]*netTestClient{}
+	for _, color := range []Stone{Black, White} {
+		clients[color] = dial()
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.players[color] = newNetPlayer(color, conn)
+	}
+	go g.run()
+
+	// Black captures in the corner.
+	var last pipeEvent
+	for i, move := range []string{"0 1", "0 0", "1 0"} {
+		c := clients[Black+Stone(i%2)]
+		c.next(t, "turn")
+		json.NewEncoder(c.conn).Encode(netInput{Move: move, Seq: i + 1})
+		last = c.next(t, "move")
+	}
+	want := &pipeDelta{Black: []int{5}, Empty: []int{0}, Captures: [2]int{1, 0}}
+	if !reflect.DeepEqual(last.Delta, want) || last.Black == nil || last.White == nil {
+		t.Fatalf("capture = %+v, delta %+v", last, last.Delta)
+	}
+
+	white := clients[White]
+	json.NewEncoder(white.conn).Encode(netInput{Board: true})
+	e := white.next(t, "board")
+	want = &pipeDelta{Black: []int{1, 5}, Captures: [2]int{1, 0}}
+	if e.Number != 3 || e.ToMove != "white" || e.Hash != last.Hash || !reflect.DeepEqual(e.Delta, want) || e.Black == nil {
+		t.Fatalf("board = %+v, delta %+v", e, e.Delta)
+	}
+}
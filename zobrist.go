@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Zobrist hashing of positions. The tables are generated from a fixed
+// seed so hashes are stable across runs and can be stored.
+
+type zobristTable struct {
+	points [][2]uint64 // per move index, for Black and White
+	turn   uint64      // xor-ed in when White is to move
+}
+
+var (
+	zobristMu     sync.Mutex
+	zobristTables = map[int]*zobristTable{}
+)
+
+func zobristFor(size int) *zobristTable {
+	zobristMu.Lock()
+	defer zobristMu.Unlock()
+	if t, ok := zobristTables[size]; ok {
+		return t
+	}
+	rng := rand.New(rand.NewSource(int64(size)*7919 + 1))
+	t := &zobristTable{points: make([][2]uint64, size*size), turn: rng.Uint64()}
+	for i := range t.points {
+		t.points[i] = [2]uint64{rng.Uint64(), rng.Uint64()}
+	}
+	zobristTables[size] = t
+	return t
+}
+
+// stoneHash hashes the stones of b as seen through symmetry sym.
+func (b *Board) stoneHash(sym int) uint64 {
+	t := zobristFor(b.size)
+	var h uint64
+	for row := 0; row < b.size; row++ {
+		for col := 0; col < b.size; col++ {
+			if s := b.grid[row][col]; s != Empty {
+				r, c := transformPoint(sym, row, col, b.size)
+				h ^= t.points[r*b.size+c][s-1]
+			}
+		}
+	}
+	return h
+}
+
+// Hash returns the Zobrist hash of the stones and the side to move.
+func (b *Board) Hash() uint64 {
+	h := b.stoneHash(0)
+	if b.turn == White {
+		h ^= zobristFor(b.size).turn
+	}
+	return h
+}
+
+// CanonicalHash hashes the arrangement of stones up to rotation and
+// reflection, ignoring the side to move, so equivalent positions match.
+func (b *Board) CanonicalHash() uint64 {
+	h := b.stoneHash(0)
+	for sym := 1; sym < 8; sym++ {
+		if s := b.stoneHash(sym); s < h {
+			h = s
+		}
+	}
+	return h
+}
+
+// transformPoint maps a point through one of the eight symmetries of the
+// square: bit 0 mirrors columns, bit 1 mirrors rows, bit 2 transposes.
+func transformPoint(sym, row, col, size int) (int, int) {
+	if sym&1 != 0 {
+		col = size - 1 - col
+	}
+	if sym&2 != 0 {
+		row = size - 1 - row
+	}
+	if sym&4 != 0 {
+		row, col = col, row
+	}
+	return row, col
+}